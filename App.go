@@ -1,20 +1,29 @@
 package catu
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/Masterminds/sprig"
 	"github.com/go-catupiry/catu/acl"
+	"github.com/go-catupiry/catu/cache"
 	"github.com/go-catupiry/catu/configuration"
 	"github.com/go-catupiry/catu/helpers"
 	"github.com/go-catupiry/catu/http_client"
@@ -25,6 +34,7 @@ import (
 	"github.com/gookit/event"
 	"github.com/labstack/echo/v4"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/sqlite"
@@ -45,8 +55,81 @@ type App interface {
 	GetRouter() *echo.Echo
 	SetRouterGroup(name, path string) *echo.Group
 	GetRouterGroup(name string) *echo.Group
+	// Request body limit of one router group, ex: "50M", replaces the BODY_LIMIT
+	SetGroupBodyLimit(groupName, limit string) error
 	SetResource(name string, httpController HTTPController, routerGroup *echo.Group) error
+	// Link one resource to one SetModel model, used in the OpenAPI schemas
+	SetResourceModel(resourceName, modelName string) error
+	BuildOpenAPISpec() (*OpenAPIDocument, error)
+	// SetResource with options, ex: the soft delete routes
+	SetResourceWithOptions(name string, httpController HTTPController, routerGroup *echo.Group, opts ResourceOptions) error
+	// Returns the audit logs of the resources with the Audit option, newest first
+	QueryAuditLogs(filter AuditLogFilter) ([]AuditLog, error)
+	ReplaceResource(name string, httpController HTTPController, routerGroup *echo.Group) error
+	GetResource(name string) *HTTPResource
+	GetResources() map[string]*HTTPResource
+	ListResources() []*HTTPResource
+	SetResourceIDCodec(name string, codec IDCodec) error
+	GetResourceIDCodec(name string) IDCodec
+	SetResourceJSONAPI(name string, enabled bool) error
+	// Cache the resource GET responses, invalidated after the resource changes
+	SetResourceCache(name string, ttl time.Duration) error
+	SetCORSOriginChecker(checker func(origin string) bool)
+	// Add one response encoding of the COMPRESSION_ENABLED middleware, ex: "br"
+	SetCompressionEncoder(encoding string, encoder CompressionEncoder)
+	GetRateLimitStore() RateLimitStore
+	SetRateLimitStore(store RateLimitStore)
+	GetRateLimitKeyFunc() func(c echo.Context) string
+	SetRateLimitKeyFunc(fn func(c echo.Context) string)
+	GetSessionStore() SessionStore
+	SetSessionStore(store SessionStore)
+	// Storage of the HandleUpload files
+	GetUploadStorage() Storage
+	SetUploadStorage(s Storage)
+	GetResponseCacheStore() CacheStore
+	SetResponseCacheStore(store CacheStore)
+	// Remove the cached responses of the paths starting with prefix, see ResponseCache
+	CacheInvalidate(prefix string) error
+	// Generate one temporary signed URL for a named route, see ValidateSignature
+	SignedURL(routeName string, params *SignedURLParams, expiry time.Duration) (string, error)
+	// Serve the dir files in the prefix path, see AssetURL
+	ServeStatic(prefix, dir string) error
+	// URL of one ServeStatic file, fingerprinted with STATIC_FINGERPRINT
+	AssetURL(name string) string
+	// Static export of public HTML routes, see StaticRoutes.Register
+	StaticRoutes() *StaticRoutes
+	GetJobStore() JobStore
+	SetJobStore(store JobStore)
+	// Set the handler of the jobs with the name, see EnqueueJob
+	RegisterJobHandler(name string, fn JobHandler)
+	// Store one job to run in the StartWorkers workers
+	EnqueueJob(name string, payload interface{}) error
+	EnqueueJobWithContext(ctx context.Context, name string, payload interface{}) error
+	EnqueueJobWithProgress(ctx context.Context, name string, payload interface{}, userID string, total int64) (*Progress, error)
+	// Start n job workers, stopped with ctx or in the app shutdown
+	StartWorkers(ctx context.Context, n int) error
+	StopWorkers()
+	// Run fn in the cronSpec times after the Bootstrap, ex: "*/5 * * * *"
+	Schedule(name, cronSpec string, fn ScheduledTask) error
+	SetScheduleTimeout(name string, timeout time.Duration) error
+	// Upgrade the path requests to WebSocket connections, see GetWebSocketHub
+	SetWebSocketRoute(path string, handler WSHandler)
+	GetWebSocketHub() *Hub
+	// Translate one key in the locale, see LoadLocales
+	T(locale, key string, args ...interface{}) string
+	Translate(locale, key string, args ...interface{}) (string, bool)
+	GetDefaultLocale() string
+	GetLocales() []string
+	AddTranslations(locale string, translations map[string]interface{})
+	LoadLocales(folder string) error
+	// Locale of one request from the Accept-Language header, the cookie or the query param
+	DetectLocale(req *http.Request) string
+	// Set the validation error message of one tag, {0} is the field name and {1} the tag param
+	RegisterValidationMessage(tag, template string) error
+	RegisterValidation(tag string, fn validator.Func) error
 	StartHTTPServer() error
+	StartHTTPServerWithContext(ctx context.Context) error
+	StartHTTPServerWithListener(ctx context.Context, ln net.Listener) error
 	NewRequestContext(opts *RequestContextOpts) *RequestContext
 	// Get default app theme
 	GetTheme() string
@@ -56,14 +139,34 @@ type App interface {
 	GetLayout() string
 	// Set default app layout
 	SetLayout(layout string) error
+	// Layout of the HTML pages, empty to render without layout, see RenderPage
+	SetDefaultLayout(name string)
 	GetTemplates() *template.Template
 	LoadTemplates() error
+	// Load the templates from one fs.FS instead of the TEMPLATE_FOLDER, ex: one embed.FS
+	LoadTemplatesFromFS(fsys fs.FS, root string) error
+	// Add one fs.FS with templates merged in LoadTemplates, ex: one plugin embed.FS
+	AddTemplateFS(fsys fs.FS, prefix string)
+	// Parse the templates again, keeping the current templates on parse errors
+	ReloadTemplates() error
 	SetTemplateFunction(name string, f interface{})
 	RenderTemplate(wr io.Writer, name string, data interface{}) error
 
 	InitDatabase(name, engine string, isDefault bool) error
 	SetModel(name string, f interface{})
 	GetModel(name string) interface{}
+	// Add or replace one response format used in the content negotiation and RespondAuto
+	RegisterResponseFormat(mimeType string, renderer ResponseFormatRenderer)
+	GetResponseFormat(mimeType string) ResponseFormatRenderer
+	// Returns the response format of one request from the responseType query param, Accept header and router group
+	NegotiateResponseType(c echo.Context) string
+	// Add one RunCLI command, ex: "jobs:work"
+	RegisterCommand(name, help string, fn func(args []string) error)
+	GetCommands() []*Command
+	// Returns the SetModel models in registration order
+	GetModels() []RegisteredModel
+	// Run AutoMigrate in all SetModel models and seed the new tables
+	MigrateModels() error
 
 	Can(permission string, userRoles []string) bool
 	SetRole(name string, role acl.Role) error
@@ -71,13 +174,76 @@ type App interface {
 	GetRole(name string) *acl.Role
 	SetRolePermission(name string, permission string, hasAccess bool) error
 	GetRolePermission(name string, permission string) bool
+	// Add one new role, returns one error if the role already exists
+	AddRoleToList(role acl.Role) error
+	RemovePermissionFromRole(name string, permission string) error
+	// Get the role permissions with the permissions inherited from its parent roles
+	GetRolePermissions(name string) []string
+	// Role with all permissions, default "administrator"
+	GetSuperRole() string
+	SetSuperRole(name string)
+	// Role of the anonymous requests, default "unAuthenticated"
+	GetUnauthenticatedRole() string
+	GetRoleProvider() acl.RoleProvider
+	SetRoleProvider(provider acl.RoleProvider)
+	// Load the roles from the role provider, replacing the RolesList
+	ReloadRoles() error
 
 	GetEvents() *event.Manager
+	// Add one typed event listener, see AppEvent
+	On(eventName string, priority int, fn func(e *AppEvent) error)
+	// Run all event listeners and return the errors of the failing listeners
+	TriggerSync(ctx context.Context, eventName string, data map[string]interface{}) error
+	// Run the event listeners in goroutines, the errors and panics are logged
+	TriggerAsync(ctx context.Context, eventName string, data map[string]interface{}) *sync.WaitGroup
 
 	GetConfiguration() configuration.ConfigurationInterface
+	// Add required configuration keys, checked in the Bootstrap. Plugins call it in their Init
+	RequireConfig(keys ...string)
+	// Config driven header transformations, see HeaderTransformer.Reload
+	GetHeaderTransformer() *HeaderTransformer
+
+	// Shared app cache, default one memory store, replace it with one shared store for multiple instances
+	GetCache() *cache.Cache
+	SetCache(c *cache.Cache)
+
+	// Named HTTP clients shared by the plugins
+	RegisterHTTPService(name, baseURL string, opts http_client.ClientOptions) *http_client.Client
+	HTTPService(name string) *http_client.Client
 
 	GetDB() *gorm.DB
+	// Returns the request tenant database or the default database, see TENANT_DB_MODE
+	TenantDB(c echo.Context) *gorm.DB
+	// Add or replace one tenant, see TENANT_RESOLVER
+	RegisterTenant(tenant Tenant) error
+	GetTenant(id string) *Tenant
+	// Load the tenants table with TENANTS_SOURCE=db
+	ReloadTenants() error
+	// Run the migrations in the database of one tenant with TENANT_DB_MODE=database, Migrate runs it for all tenants
+	MigrateTenant(id string) error
+	// Replace the TENANT_RESOLVER resolver, ex: one resolver with one custom domain lookup
+	SetTenantResolver(resolver TenantResolver)
+	GetTenantResolver() TenantResolver
+	// Respond 503 to the requests until DisableMaintenanceMode, see MAINTENANCE_MODE
+	EnableMaintenanceMode(message string)
+	DisableMaintenanceMode()
+	IsMaintenanceMode() bool
+	// Keep one path working in the maintenance mode, ex: "/api/status" or "/admin/*"
+	ExemptFromMaintenance(path string)
+	// Run fn in one database transaction, nested calls reuse the current transaction
+	Transaction(ctx context.Context, fn func(tx *gorm.DB) error) error
+
+	// Add one health check to the /health and /ready endpoints
+	RegisterHealthCheck(name string, fn HealthCheckFunc)
+	RunHealthChecks(ctx context.Context) *HealthReport
+	// Returns true after the Bootstrap and until the shutdown starts
+	IsReady() bool
+	// Registry of the /metrics endpoint, register custom collectors in it
+	MetricsRegistry() *prometheus.Registry
 	SetDB(db *gorm.DB) error
+	// Get one database started with InitDatabase by name
+	GetNamedDB(name string) *gorm.DB
+	CloseDB(name string) error
 	Migrate() error
 
 	Bootstrap() error
@@ -101,6 +267,13 @@ type AppStruct struct {
 	Events *event.Manager
 
 	Configuration configuration.ConfigurationInterface
+	// RequireConfig keys
+	requiredConfig []string
+
+	// CONFIG_FILE path and watcher
+	configFile    string
+	configWatcher *configuration.FileWatcher
+
 	// Default database
 	DB *gorm.DB
 	// avaible databases
@@ -109,20 +282,78 @@ type AppStruct struct {
 	Plugins map[string]Pluginer
 
 	Models map[string]interface{}
-
-	router    *echo.Echo
-	Resources map[string]*HTTPResource
+	// Models names in registration order
+	modelNames []string
+	// RegisterResponseFormat formats
+	responseFormats *responseFormats
+	// RegisterCommand commands
+	commands     map[string]*Command
+	commandNames []string
+
+	router      *echo.Echo
+	Resources   map[string]*HTTPResource
+	resourcesMu sync.RWMutex
 
 	routerGroups map[string]*echo.Group
+	// router group paths by name
+	routerGroupPaths map[string]string
+	bodyLimits       *bodyLimits
 
 	RolesString string
 	RolesList   map[string]acl.Role
+	rolesMu     sync.RWMutex
+	superRole   string
+	// ACL_UNAUTHENTICATED_ROLE, see GetUnauthenticatedRole
+	unauthenticatedRole string
+	// ROLES_SOURCE provider, see ReloadRoles
+	roleProvider acl.RoleProvider
+	// RegisterHTTPService clients
+	httpServices   map[string]*http_client.Client
+	httpServicesMu sync.RWMutex
 	// default theme for HTML responses
 	Theme string
 	// default layout for HTML responses
-	Layout            string
-	templates         *template.Template
+	Layout        string
+	templates     *template.Template
+	templatesMu   sync.RWMutex
+	templateWatch *templateWatch
+	templateFSes  []*templateFS
+	// LoadTemplatesFromFS templates, nil to use the TEMPLATE_FOLDER
+	templateBase      *templateFS
 	templateFunctions template.FuncMap
+
+	headerTransformer *HeaderTransformer
+
+	corsOriginChecker func(origin string) bool
+	// SetCompressionEncoder encoders
+	compressionEncoders map[string]CompressionEncoder
+	rateLimitStore      RateLimitStore
+	rateLimitKeyFunc    func(c echo.Context) string
+	sessionStore        SessionStore
+	uploadStorage       Storage
+	// ResponseCache store
+	responseCacheStore CacheStore
+	staticRoutes       *StaticRoutes
+	staticMounts       []*staticMount
+	jobs               *jobRunner
+	scheduler          *scheduler
+	webSocketHub       *Hub
+	webSocketOnce      sync.Once
+	i18n               *i18nStore
+	cache              *cache.Cache
+	healthChecksMu     sync.RWMutex
+	healthChecks       map[string]HealthCheckFunc
+	ready              bool
+	metrics            *metrics
+	// TENANT_RESOLVER resolver and the registered tenants, see RegisterTenant
+	tenantResolver TenantResolver
+	tenants        map[string]*Tenant
+	tenantsMu      sync.RWMutex
+	// 1 in the maintenance mode, see EnableMaintenanceMode
+	maintenance        int32
+	maintenanceMessage atomic.Value
+	maintenanceExempt  []string
+	maintenanceMu      sync.RWMutex
 }
 
 func (r *AppStruct) RegisterPlugin(p Pluginer) {
@@ -249,53 +480,204 @@ func (r *AppStruct) SetLayout(layout string) error {
 	return nil
 }
 
+// SetDefaultLayout sets the layout of the HTML pages, empty to render the pages without layout.
+// The requests can override it in RequestContext.Layout, see RenderPage
+func (r *AppStruct) SetDefaultLayout(name string) {
+	r.Layout = name
+}
+
 func (r *AppStruct) GetTemplates() *template.Template {
+	r.templatesMu.RLock()
+	defer r.templatesMu.RUnlock()
+
 	return r.templates
 }
 
+func (r *AppStruct) setTemplates(tpls *template.Template) {
+	r.templatesMu.Lock()
+	defer r.templatesMu.Unlock()
+
+	r.templates = tpls
+}
+
 func (r *AppStruct) GetEvents() *event.Manager {
 	return r.Events
 }
 
+// RequireConfig adds keys that must be set before the Bootstrap, ex: app.RequireConfig("SMTP_HOST", "SMTP_PASSWORD").
+// The Bootstrap fails with one error listing all missing keys
+func (r *AppStruct) RequireConfig(keys ...string) {
+	r.requiredConfig = append(r.requiredConfig, keys...)
+}
+
 func (r *AppStruct) GetConfiguration() configuration.ConfigurationInterface {
 	return r.Configuration
 }
 
+func (r *AppStruct) GetHeaderTransformer() *HeaderTransformer {
+	return r.headerTransformer
+}
+
+func (r *AppStruct) GetCache() *cache.Cache {
+	return r.cache
+}
+
+func (r *AppStruct) SetCache(c *cache.Cache) {
+	r.cache = c
+}
+
 func (r *AppStruct) GetDB() *gorm.DB {
 	return r.DB
 }
+
+// SetDB sets the default database, ex: one database opened with custom gorm options.
+// The Bootstrap keeps it and doesn't start the DB_URI database
 func (r *AppStruct) SetDB(db *gorm.DB) error {
+	if err := r.registerDBCallbacks("default", db); err != nil {
+		return errors.Wrap(err, "catu.App.SetDB error on register callbacks")
+	}
+
 	r.DB = db
+	r.DBs["default"] = db
+	return nil
+}
+
+func (r *AppStruct) GetNamedDB(name string) *gorm.DB {
+	return r.DBs[name]
+}
+
+// CloseDB close the database connection pool and remove it from the app databases
+func (r *AppStruct) CloseDB(name string) error {
+	db := r.DBs[name]
+	if db == nil {
+		return errors.New("catu.App.CloseDB database not found: " + name)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return errors.Wrap(err, "catu.App.CloseDB error on get database "+name)
+	}
+
+	err = sqlDB.Close()
+	if err != nil {
+		return errors.Wrap(err, "catu.App.CloseDB error on close database "+name)
+	}
+
+	delete(r.DBs, name)
+
+	if r.DB == db {
+		r.DB = nil
+	}
+
 	return nil
 }
 
 func (r *AppStruct) Bootstrap() error {
+	return r.bootstrap(true)
+}
+
+// bootstrapCLI is the Bootstrap of the one-shot CLI commands, ex: migrate. It skips the schema drift check,
+// the database may be behind the models before the migration, and the scheduler and the configuration watcher
+func (r *AppStruct) bootstrapCLI() error {
+	return r.bootstrap(false)
+}
+
+// bootstrap runs the Bootstrap, with the schema drift check and the background tasks if background is true
+func (r *AppStruct) bootstrap(background bool) error {
 	var err error
 
 	logrus.Debug("catu.App.Bootstrap running")
-	// default roles and permissions, override it on your app
-	json.Unmarshal([]byte(r.RolesString), &r.RolesList)
+	plugins, err := sortPlugins(r.Plugins)
+	if err != nil {
+		return errors.Wrap(err, "App.Bootstrap | Error on sort plugins")
+	}
 
-	for _, p := range r.Plugins {
+	for _, p := range plugins {
 		err = p.Init(r)
 		if err != nil {
 			return errors.Wrap(err, "App.Bootstrap | Error on run plugin init "+p.GetName())
 		}
 	}
 
-	r.Events.MustTrigger("configuration", event.M{"app": r})
+	if err := r.TriggerSync(context.Background(), "configuration", event.M{"app": r}); err != nil {
+		return errors.Wrap(err, "App.Bootstrap error on configuration event")
+	}
 
-	err = r.InitDatabase("default", configuration.GetEnv("DB_ENGINE", "sqlite"), true)
+	err = r.Configuration.Require(r.requiredConfig...)
 	if err != nil {
-		return err
+		return errors.Wrap(err, "App.Bootstrap Error on check required configuration")
+	}
+
+	// keep the SetDB database
+	if r.GetNamedDB("default") == nil {
+		err = r.InitDatabase("default", configuration.GetEnv("DB_ENGINE", "sqlite"), true)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.bindMaintenance()
+
+	err = r.initTenancy()
+	if err != nil {
+		return errors.Wrap(err, "App.Bootstrap Error on start tenancy")
+	}
+
+	err = r.initRoleProvider()
+	if err != nil {
+		return errors.Wrap(err, "App.Bootstrap Error on start role provider")
+	}
+
+	err = r.initJobStore()
+	if err != nil {
+		return errors.Wrap(err, "App.Bootstrap Error on start job store")
+	}
+
+	r.initAudit()
+
+	err = r.LoadLocales(r.Configuration.GetF("LOCALES_FOLDER", "locales"))
+	if err != nil {
+		return errors.Wrap(err, "App.Bootstrap Error on load locales")
+	}
+	// the db roles tables may not exist before the first migration
+	if err := r.ReloadRoles(); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": fmt.Sprintf("%+v\n", err),
+		}).Error("App.Bootstrap error on load roles")
 	}
 
 	http_client.Init()
 
-	r.Events.MustTrigger("bindMiddlewares", event.M{"app": r})
-	r.Events.MustTrigger("bindRoutes", event.M{"app": r})
-	r.Events.MustTrigger("setResponseFormats", event.M{"app": r})
-	r.Events.MustTrigger("setTemplateFunctions", event.M{"app": r})
+	r.bindMetrics()
+	r.bindRequestLog()
+	r.bindRequestTimeout()
+	r.bindBodyLimit()
+	r.bindCORS()
+	r.bindCompression()
+
+	if err := r.TriggerSync(context.Background(), "bindMiddlewares", event.M{"app": r}); err != nil {
+		return errors.Wrap(err, "App.Bootstrap error on bindMiddlewares event")
+	}
+	// after the plugin middlewares, to limit by the authenticated user
+	bindRateLimiter(r)
+	// after the sessions middleware
+	bindCSRF(r)
+	// after the plugin middlewares, to check the authenticated user permission
+	bindDryRun(r)
+	// after the dry run, to reuse the dry run transaction
+	if r.Configuration.GetBool("DB_REQUEST_TRANSACTIONS") {
+		r.router.Use(TransactionMiddleware(r))
+	}
+	if err := r.TriggerSync(context.Background(), "bindRoutes", event.M{"app": r}); err != nil {
+		return errors.Wrap(err, "App.Bootstrap error on bindRoutes event")
+	}
+	r.bindOpenAPI()
+	if err := r.TriggerSync(context.Background(), "setResponseFormats", event.M{"app": r}); err != nil {
+		return errors.Wrap(err, "App.Bootstrap error on setResponseFormats event")
+	}
+	if err := r.TriggerSync(context.Background(), "setTemplateFunctions", event.M{"app": r}); err != nil {
+		return errors.Wrap(err, "App.Bootstrap error on setTemplateFunctions event")
+	}
 
 	logrus.WithFields(logrus.Fields{
 		"count": len(r.templateFunctions),
@@ -307,27 +689,181 @@ func (r *AppStruct) Bootstrap() error {
 	}
 
 	r.router.Renderer = &TemplateRenderer{
-		templates: r.GetTemplates(),
+		app: r,
+	}
+
+	if background {
+		err = r.checkSchemaDrift()
+		if err != nil {
+			return errors.Wrap(err, "App.Bootstrap Error on check database schema")
+		}
 	}
 
-	r.Events.MustTrigger("bootstrap", event.M{"app": r})
+	if err := r.TriggerSync(context.Background(), "bootstrap", event.M{"app": r}); err != nil {
+		return errors.Wrap(err, "App.Bootstrap error on bootstrap event")
+	}
+
+	if background {
+		r.startScheduler()
+
+		err = r.startConfigWatch()
+		if err != nil {
+			return errors.Wrap(err, "App.Bootstrap Error on watch configuration file")
+		}
+	}
+
+	r.setReady(true)
 
 	return nil
 }
 
 func (r *AppStruct) StartHTTPServer() error {
+	return r.StartHTTPServerWithContext(context.Background())
+}
+
+// StartHTTPServerWithContext starts the HTTP server and blocks until the ctx is canceled or
+// the process receives SIGINT / SIGTERM, then shutdown the server gracefully
+func (r *AppStruct) StartHTTPServerWithContext(ctx context.Context) error {
 	port := r.Configuration.Get("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
+	ln, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return errors.Wrap(err, "catu.App.StartHTTPServerWithContext error on listen port "+port)
+	}
+
 	logrus.Info("Server listening on port " + port)
-	return http.ListenAndServe(":"+port, r.GetRouter())
+	return r.StartHTTPServerWithListener(ctx, ln)
+}
+
+// StartHTTPServerWithListener serves HTTP requests from the listener with graceful shutdown support.
+// In-flight requests have SHUTDOWN_TIMEOUT (default 10s) to finish after the stop signal.
+// Serves HTTPS with HTTP/2 if TLS_CERT_FILE and TLS_KEY_FILE or TLS_AUTOCERT_DOMAINS are set,
+// and with PORT_REDIRECT one HTTP server redirects all requests to HTTPS.
+func (r *AppStruct) StartHTTPServerWithListener(ctx context.Context, ln net.Listener) error {
+	tlsConfig, certManager, err := r.getTLSConfig()
+	if err != nil {
+		ln.Close()
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := r.newHTTPServer(tlsConfig)
+
+	var redirectServer *http.Server
+	if port := r.Configuration.Get("PORT_REDIRECT"); port != "" && tlsConfig != nil {
+		redirectServer, err = r.startRedirectServer(port, certManager)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			// certificates are in the TLSConfig, ServeTLS also enables HTTP/2
+			serverErr <- server.ServeTLS(ln, "", "")
+			return
+		}
+		serverErr <- server.Serve(ln)
+	}()
+
+	select {
+	case err := <-serverErr:
+		shutdownRedirectServer(context.Background(), redirectServer)
+		if err != nil && err != http.ErrServerClosed {
+			return errors.Wrap(err, "catu.App.StartHTTPServerWithListener error on serve")
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	// load balancers stop sending new requests
+	r.setReady(false)
+
+	timeout := r.getShutdownTimeout()
+	logrus.WithFields(logrus.Fields{
+		"timeout": timeout.String(),
+	}).Info("catu.App shutting down HTTP server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	shutdownRedirectServer(shutdownCtx, redirectServer)
+
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": fmt.Sprintf("%+v\n", err),
+		}).Error("catu.App.StartHTTPServerWithListener error on shutdown HTTP server")
+	}
+
+	r.shutdown()
+
+	return err
+}
+
+func (r *AppStruct) getShutdownTimeout() time.Duration {
+	return r.getDurationConfig("SHUTDOWN_TIMEOUT", "10s")
+}
+
+// getDurationConfig parses one duration config like "10s", invalid values use the default
+func (r *AppStruct) getDurationConfig(key, defaultValue string) time.Duration {
+	v := r.Configuration.GetF(key, defaultValue)
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			key: v,
+		}).Warn("catu.App invalid " + key + ", using the default " + defaultValue)
+		d, _ = time.ParseDuration(defaultValue)
+	}
+
+	return d
+}
+
+// newHTTPServer returns the server with the HTTP_READ_TIMEOUT (default 30s), HTTP_WRITE_TIMEOUT (default 0, streams
+// like server-sent events need it disabled), HTTP_IDLE_TIMEOUT (default 120s) and HTTP_MAX_HEADER_BYTES (default 1MB)
+func (r *AppStruct) newHTTPServer(tlsConfig *tls.Config) *http.Server {
+	return &http.Server{
+		Handler:        r.GetRouter(),
+		TLSConfig:      tlsConfig,
+		ReadTimeout:    r.getDurationConfig("HTTP_READ_TIMEOUT", "30s"),
+		WriteTimeout:   r.getDurationConfig("HTTP_WRITE_TIMEOUT", "0s"),
+		IdleTimeout:    r.getDurationConfig("HTTP_IDLE_TIMEOUT", "120s"),
+		MaxHeaderBytes: r.Configuration.GetIntF("HTTP_MAX_HEADER_BYTES", http.DefaultMaxHeaderBytes),
+	}
+}
+
+// shutdown notify plugins with the "shutdown" event and close all database connection pools
+func (r *AppStruct) shutdown() {
+	err, _ := r.Events.Fire("shutdown", event.M{"app": r})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": fmt.Sprintf("%+v\n", err),
+		}).Error("catu.App.shutdown error on shutdown event")
+	}
+
+	for name := range r.DBs {
+		err = r.CloseDB(name)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"name":  name,
+				"error": fmt.Sprintf("%+v\n", err),
+			}).Error("catu.App.shutdown error on close database")
+		}
+	}
 }
 
 func (r *AppStruct) SetRouterGroup(name, path string) *echo.Group {
 	if r.routerGroups[name] == nil {
 		r.routerGroups[name] = r.router.Group(path)
+		r.routerGroupPaths[name] = path
 	}
 	return r.routerGroups[name]
 }
@@ -336,42 +872,207 @@ func (r *AppStruct) GetRouterGroup(name string) *echo.Group {
 	return r.routerGroups[name]
 }
 
+// ResourceOptions are the SetResourceWithOptions options
+type ResourceOptions struct {
+	// Binds the GET /trash, POST /:id/restore and DELETE /:id/force routes for gorm soft deleted models
+	SoftDelete bool
+	// Soft delete column used in the trash list, default "deleted_at"
+	DeletedAtColumn string
+	// Permissions checked in the resource routes with RequirePermission, nil to not check permissions.
+	// Empty fields default to "<resource>.<action>", ex: &catu.ResourcePermissions{Query: "content.find"}
+	Permissions *ResourcePermissions
+	// Record the Create, Update and Delete requests in the audit_logs table, see App.QueryAuditLogs.
+	// The changes use the resource model, see App.SetResourceModel, fields with the audit:"-" tag are not recorded.
+	// The controllers must create the records with the request database, see RequestContext.GetDB
+	Audit bool
+	// Binds the POST, PATCH and DELETE /bulk routes, see HTTPResource.BulkCreate
+	Bulk bool
+	// Max items of one bulk request, default BULK_MAX_SIZE or 100
+	BulkMaxSize int
+}
+
 // Set Resource CRUD.
 // Now we only supports HTTP Resources / Ex Rest
+// Returns one error if the resource name is already registered, use ReplaceResource to override it
 func (r *AppStruct) SetResource(name string, httpController HTTPController, routerGroup *echo.Group) error {
-	routerGroup.GET("", httpController.Query)
-	routerGroup.GET("/count", httpController.Count)
-	routerGroup.POST("", httpController.Create)
-	routerGroup.GET("/:id", httpController.FindOne)
-	routerGroup.POST("/:id", httpController.Update)
-	routerGroup.PATCH("/:id", httpController.Update)
-	routerGroup.PUT("/:id", httpController.Update)
-	routerGroup.DELETE("/:id", httpController.Delete)
+	return r.SetResourceWithOptions(name, httpController, routerGroup, ResourceOptions{})
+}
+
+// SetResourceWithOptions is SetResource with the resource options, ex:
+//
+//	app.SetResourceWithOptions("articles", ctl, api.Group("/articles"), catu.ResourceOptions{SoftDelete: true})
+//
+// The soft delete routes require the "<resource>.restore" permission to list the trash and restore records
+// and the "<resource>.forceDelete" permission to delete records permanently
+func (r *AppStruct) SetResourceWithOptions(name string, httpController HTTPController, routerGroup *echo.Group, opts ResourceOptions) error {
+	if r.GetResource(name) != nil {
+		return errors.New("catu.App.SetResource resource already registered: " + name + ", use ReplaceResource to override it")
+	}
+
+	if opts.DeletedAtColumn == "" {
+		opts.DeletedAtColumn = "deleted_at"
+	}
+
+	resource := &HTTPResource{
+		Name:            name,
+		Controller:      &httpController,
+		RouterGroup:     routerGroup,
+		SoftDelete:      opts.SoftDelete,
+		DeletedAtColumn: opts.DeletedAtColumn,
+		Audit:           opts.Audit,
+		Bulk:            opts.Bulk,
+		BulkMaxSize:     opts.BulkMaxSize,
+	}
+
+	if resource.BulkMaxSize <= 0 {
+		resource.BulkMaxSize = r.Configuration.GetIntF("BULK_MAX_SIZE", 100)
+	}
 
-	r.Resources[name] = &HTTPResource{
-		Name:       name,
-		Controller: &httpController,
+	if opts.Permissions != nil {
+		resource.Permissions = opts.Permissions.withDefaults(name)
 	}
 
+	bindResourceRoutes(r, resource, routerGroup)
+
+	r.resourcesMu.Lock()
+	defer r.resourcesMu.Unlock()
+
+	if r.Resources[name] != nil {
+		return errors.New("catu.App.SetResource resource already registered: " + name + ", use ReplaceResource to override it")
+	}
+	r.Resources[name] = resource
+
 	return nil
 }
 
+// ReplaceResource replaces the controller of one registered resource, the current routes will call the new controller.
+// If the resource is not registered or the routerGroup is other group it also binds the resource routes in routerGroup.
+// The controller is replaced safely while the server runs, new routes should be bound before the server starts
+func (r *AppStruct) ReplaceResource(name string, httpController HTTPController, routerGroup *echo.Group) error {
+	resource := r.GetResource(name)
+	if resource == nil {
+		return r.SetResource(name, httpController, routerGroup)
+	}
+
+	resource.setController(httpController)
+
+	if routerGroup != nil && routerGroup != resource.RouterGroup {
+		bindResourceRoutes(r, resource, routerGroup)
+		resource.RouterGroup = routerGroup
+	}
+
+	return nil
+}
+
+func bindResourceRoutes(app App, resource *HTTPResource, routerGroup *echo.Group) {
+	contentType := resourceContentType(app, resource.Name)
+	cache := resourceCache(app, resource.Name)
+	// the permissions are checked before the cache responses
+	canQuery := resourcePermission(resource, func(p *ResourcePermissions) string { return p.Query })
+	canCount := resourcePermission(resource, func(p *ResourcePermissions) string { return p.Count })
+	canCreate := resourcePermission(resource, func(p *ResourcePermissions) string { return p.Create })
+	canFindOne := resourcePermission(resource, func(p *ResourcePermissions) string { return p.FindOne })
+	canUpdate := resourcePermission(resource, func(p *ResourcePermissions) string { return p.Update })
+	canDelete := resourcePermission(resource, func(p *ResourcePermissions) string { return p.Delete })
+
+	route := routerGroup.GET("", resource.Query, contentType, canQuery, cache)
+	resource.Path = route.Path
+	routerGroup.GET("/count", resource.Count, contentType, canCount, cache)
+	routerGroup.POST("", resource.Create, contentType, canCreate)
+	idDecoder := decodeResourceID(app, resource.Name)
+	conditional := resourceConditional(app, resource.Name)
+	routerGroup.GET("/:id", resource.FindOne, contentType, canFindOne, idDecoder, conditional, cache)
+	routerGroup.POST("/:id", resource.Update, contentType, canUpdate, idDecoder, conditional)
+	routerGroup.PATCH("/:id", resource.Update, contentType, canUpdate, idDecoder, conditional)
+	routerGroup.PUT("/:id", resource.Update, contentType, canUpdate, idDecoder, conditional)
+	routerGroup.DELETE("/:id", resource.Delete, contentType, canDelete, idDecoder, conditional)
+
+	if resource.Bulk {
+		routerGroup.POST("/bulk", resource.BulkCreate, contentType, canCreate)
+		routerGroup.PATCH("/bulk", resource.BulkUpdate, contentType, canUpdate)
+		routerGroup.DELETE("/bulk", resource.BulkDelete, contentType, canDelete)
+	}
+
+	if resource.SoftDelete {
+		routerGroup.GET("/trash", resource.Trash, contentType)
+		routerGroup.POST("/:id/restore", resource.Restore, contentType, idDecoder)
+		routerGroup.DELETE("/:id/force", resource.ForceDelete, contentType, idDecoder)
+	}
+}
+
+func (r *AppStruct) GetResource(name string) *HTTPResource {
+	r.resourcesMu.RLock()
+	defer r.resourcesMu.RUnlock()
+	return r.Resources[name]
+}
+
+// GetResources returns one copy of the registered resources map
+func (r *AppStruct) GetResources() map[string]*HTTPResource {
+	r.resourcesMu.RLock()
+	defer r.resourcesMu.RUnlock()
+
+	resources := make(map[string]*HTTPResource, len(r.Resources))
+	for name, resource := range r.Resources {
+		resources[name] = resource
+	}
+	return resources
+}
+
+// ListResources returns the registered resources sorted by name
+func (r *AppStruct) ListResources() []*HTTPResource {
+	r.resourcesMu.RLock()
+	defer r.resourcesMu.RUnlock()
+
+	names := make([]string, 0, len(r.Resources))
+	for name := range r.Resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]*HTTPResource, len(names))
+	for i, name := range names {
+		list[i] = r.Resources[name]
+	}
+
+	return list
+}
+
+// InitDatabase start one database connection and store it in App.DBs with the given name.
+//
+// The "default" database uses the DB_URI configuration and others use DB_URI_[NAME], ex: DB_URI_REPORTS.
+// If engine is empty it is read from DB_ENGINE_[NAME], default: sqlite.
+// Returns one error if one database with the same name already exists, use CloseDB before starting it again.
 func (r *AppStruct) InitDatabase(name, engine string, isDefault bool) error {
 	var err error
 	var db *gorm.DB
 
-	dbURI := r.Configuration.GetF("DB_URI", "test.sqlite?charset=utf8mb4")
+	if r.DBs[name] != nil {
+		return errors.New("catu.App.InitDatabase database already started: " + name)
+	}
+
+	var dbURI string
+	if name == "default" {
+		dbURI = r.Configuration.GetF("DB_URI", "test.sqlite?charset=utf8mb4")
+	} else {
+		dbURI = r.Configuration.Get("DB_URI_" + strings.ToUpper(name))
+	}
+
+	if engine == "" {
+		engine = r.Configuration.GetF("DB_ENGINE_"+strings.ToUpper(name), "sqlite")
+	}
+
 	dbSlowThreshold := r.Configuration.GetInt64F("DB_SLOW_THRESHOLD", 400)
 	logQuery := r.Configuration.GetF("LOG_QUERY", "")
 
 	logrus.WithFields(logrus.Fields{
+		"name":            name,
 		"dbURI":           dbURI,
 		"dbSlowThreshold": dbSlowThreshold,
 		"logQuery":        logQuery,
 	}).Debug("catu.App.InitDatabase starting db with configs")
 
 	if dbURI == "" {
-		return errors.New("catu.App.InitDatabase DB_URI environment variable is required")
+		return errors.New("catu.App.InitDatabase DB_URI environment variable is required for database " + name)
 	}
 
 	dsn := dbURI + "?charset=utf8mb4&parseTime=True&loc=Local"
@@ -415,6 +1116,12 @@ func (r *AppStruct) InitDatabase(name, engine string, isDefault bool) error {
 		return errors.Wrap(err, "catu.App.InitDatabase error on database connection")
 	}
 
+	if err := r.registerDBCallbacks(name, db); err != nil {
+		return errors.Wrap(err, "catu.App.InitDatabase error on register callbacks")
+	}
+
+	r.DBs[name] = db
+
 	if isDefault {
 		r.DB = db
 	}
@@ -422,7 +1129,32 @@ func (r *AppStruct) InitDatabase(name, engine string, isDefault bool) error {
 	return nil
 }
 
+// registerDBCallbacks registers the dry run, audit and conditional write callbacks and the metrics plugin,
+// databases that already have them are skipped
+func (r *AppStruct) registerDBCallbacks(name string, db *gorm.DB) error {
+	if db.Callback().Create().Get(auditCallbackName) != nil {
+		return nil
+	}
+
+	if err := registerDryRunCallbacks(db); err != nil {
+		return errors.Wrap(err, "dry run callbacks")
+	}
+	if err := registerAuditCallback(db); err != nil {
+		return errors.Wrap(err, "audit callback")
+	}
+	if err := registerConditionalWriteCallbacks(db); err != nil {
+		return errors.Wrap(err, "conditional write callbacks")
+	}
+
+	r.useGormMetrics(name, db)
+
+	return nil
+}
+
 func (r *AppStruct) SetModel(name string, f interface{}) {
+	if _, ok := r.Models[name]; !ok {
+		r.modelNames = append(r.modelNames, name)
+	}
 	r.Models[name] = f
 }
 
@@ -434,22 +1166,47 @@ func (r *AppStruct) SetTemplateFunction(name string, f interface{}) {
 	r.templateFunctions[name] = f
 }
 
+// RegisterValidationMessage sets the validation error message of one tag in all locales,
+// ex: app.RegisterValidationMessage("cpf", "{0} must be a valid CPF")
+func (r *AppStruct) RegisterValidationMessage(tag, template string) error {
+	cv, ok := r.router.Validator.(*helpers.CustomValidator)
+	if !ok {
+		return errors.New("catu.App.RegisterValidationMessage router validator is not one helpers.CustomValidator")
+	}
+	return cv.RegisterMessage(tag, template)
+}
+
+// RegisterValidation adds one validation tag, ex: app.RegisterValidation("cpf", isCPF)
+func (r *AppStruct) RegisterValidation(tag string, fn validator.Func) error {
+	cv, ok := r.router.Validator.(*helpers.CustomValidator)
+	if !ok {
+		return errors.New("catu.App.RegisterValidation router validator is not one helpers.CustomValidator")
+	}
+	return cv.RegisterValidation(tag, fn)
+}
+
 // RenderTemplate - Render template with default app theme
 func (app *AppStruct) RenderTemplate(wr io.Writer, name string, data interface{}) error {
+	app.reloadChangedTemplates()
 	return app.GetTemplates().ExecuteTemplate(wr, path.Join(app.Theme, name), data)
 }
 
+// Can checks the permission in the user roles and in their parent roles.
+// Users with the super role, default "administrator", can do everything, see SetSuperRole
 func (r *AppStruct) Can(permission string, userRoles []string) bool {
-	// first check if user is administrator
-	for i := range userRoles {
-		if userRoles[i] == "administrator" {
-			return true
+	r.rolesMu.RLock()
+	defer r.rolesMu.RUnlock()
+
+	if r.superRole != "" {
+		for i := range userRoles {
+			if userRoles[i] == r.superRole {
+				return true
+			}
 		}
 	}
 
 	for j := range userRoles {
-		R := r.RolesList[userRoles[j]]
-		if R.Can(permission) {
+		if acl.Can(r.RolesList, userRoles[j], permission) {
 			return true
 		}
 	}
@@ -457,16 +1214,48 @@ func (r *AppStruct) Can(permission string, userRoles []string) bool {
 	return false
 }
 
+func (r *AppStruct) GetSuperRole() string {
+	r.rolesMu.RLock()
+	defer r.rolesMu.RUnlock()
+
+	return r.superRole
+}
+
+// SetSuperRole sets the role that has all permissions, empty to disable it. The default is the ACL_SUPER_ROLE configuration or "administrator"
+func (r *AppStruct) SetSuperRole(name string) {
+	r.rolesMu.Lock()
+	r.superRole = name
+	r.rolesMu.Unlock()
+}
+
 func (r *AppStruct) SetRole(name string, role acl.Role) error {
+	r.rolesMu.Lock()
 	r.RolesList[name] = role
+	r.rolesMu.Unlock()
+
+	r.triggerRolesUpdated(name)
 	return nil
 }
 
+// GetRoles returns one copy of the roles, changes in it don't change the app roles
 func (r *AppStruct) GetRoles() map[string]acl.Role {
-	return r.RolesList
+	r.rolesMu.RLock()
+	defer r.rolesMu.RUnlock()
+
+	roles := make(map[string]acl.Role, len(r.RolesList))
+	for name, role := range r.RolesList {
+		role.Permissions = append([]string(nil), role.Permissions...)
+		role.Parents = append([]string(nil), role.Parents...)
+		roles[name] = role
+	}
+
+	return roles
 }
 
 func (r *AppStruct) GetRole(name string) *acl.Role {
+	r.rolesMu.RLock()
+	defer r.rolesMu.RUnlock()
+
 	if v, ok := r.RolesList[name]; ok {
 		return &v
 	}
@@ -474,24 +1263,134 @@ func (r *AppStruct) GetRole(name string) *acl.Role {
 	return nil
 }
 
+// AddRoleToList adds one new role, returns one error if the role already exists
+func (r *AppStruct) AddRoleToList(role acl.Role) error {
+	if role.Name == "" {
+		return errors.New("catu.App.AddRoleToList role name is required")
+	}
+
+	r.rolesMu.Lock()
+	if _, ok := r.RolesList[role.Name]; ok {
+		r.rolesMu.Unlock()
+		return errors.New("catu.App.AddRoleToList role already exists: " + role.Name)
+	}
+	r.RolesList[role.Name] = role
+	r.rolesMu.Unlock()
+
+	r.triggerRolesUpdated(role.Name)
+	return nil
+}
+
 func (r *AppStruct) SetRolePermission(name string, permission string, hasAccess bool) error {
-	role := r.GetRole(name)
-	if role == nil {
+	r.rolesMu.Lock()
+	role, ok := r.RolesList[name]
+	if !ok {
+		r.rolesMu.Unlock()
 		return nil
 	}
 
+	// the permissions slice may be shared with GetRole copies
+	role.Permissions = append([]string{}, role.Permissions...)
 	if hasAccess {
 		role.AddPermission(permission)
 	} else {
 		role.RemovePermission(permission)
 	}
+	r.RolesList[name] = role
+	r.rolesMu.Unlock()
 
+	r.triggerRolesUpdated(name)
 	return nil
 }
 
+// RemovePermissionFromRole removes one permission from the role, the parent roles are not changed
+func (r *AppStruct) RemovePermissionFromRole(name string, permission string) error {
+	if r.GetRole(name) == nil {
+		return errors.New("catu.App.RemovePermissionFromRole role not found: " + name)
+	}
+
+	return r.SetRolePermission(name, permission, false)
+}
+
+// GetRolePermission checks the permission in the role and in its parent roles
 func (r *AppStruct) GetRolePermission(name string, permission string) bool {
+	r.rolesMu.RLock()
+	defer r.rolesMu.RUnlock()
 
-	return false
+	return acl.Can(r.RolesList, name, permission)
+}
+
+// GetRolePermissions returns the role permissions with the permissions inherited from its parent roles
+func (r *AppStruct) GetRolePermissions(name string) []string {
+	r.rolesMu.RLock()
+	defer r.rolesMu.RUnlock()
+
+	return acl.Permissions(r.RolesList, name)
+}
+
+func (r *AppStruct) GetRoleProvider() acl.RoleProvider {
+	return r.roleProvider
+}
+
+// SetRoleProvider replaces the ROLES_SOURCE role provider, set it before the Bootstrap or call ReloadRoles after it
+func (r *AppStruct) SetRoleProvider(provider acl.RoleProvider) {
+	r.roleProvider = provider
+}
+
+// ReloadRoles loads the roles from the role provider and replaces the RolesList.
+// On errors the current roles are kept. The roles added with SetRole or AddRoleToList are replaced
+func (r *AppStruct) ReloadRoles() error {
+	roles, err := r.roleProvider.LoadRoles()
+	if err != nil {
+		return errors.Wrap(err, "catu.App.ReloadRoles error on load roles")
+	}
+
+	r.rolesMu.Lock()
+	r.RolesList = roles
+	r.rolesMu.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"count": len(roles),
+	}).Debug("catu.App.ReloadRoles roles loaded")
+
+	r.triggerRolesUpdated("")
+	return nil
+}
+
+// initRoleProvider creates the ROLES_SOURCE role provider: "json" (default) with the acl.json file roles or "db".
+// The db provider creates its tables in the "migrate" event
+func (r *AppStruct) initRoleProvider() error {
+	if r.roleProvider != nil {
+		return nil
+	}
+
+	switch source := r.Configuration.GetF("ROLES_SOURCE", "json"); source {
+	case "json":
+		r.roleProvider = &acl.JSONRoleProvider{JSON: r.RolesString}
+	case "db":
+		provider := acl.NewDBRoleProvider(r.GetDB())
+		r.roleProvider = provider
+
+		r.Events.On("migrate", event.ListenerFunc(func(e event.Event) error {
+			return provider.Migrate()
+		}), event.Normal)
+	default:
+		return errors.New("catu.App.initRoleProvider invalid ROLES_SOURCE: " + source)
+	}
+
+	return nil
+}
+
+// triggerRolesUpdated notifies the "roles-updated" listeners, ex: to save the roles or clear one permissions cache.
+// The role is empty if all roles were reloaded
+func (r *AppStruct) triggerRolesUpdated(name string) {
+	err, _ := r.Events.Fire("roles-updated", event.M{"app": r, "role": name})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"role":  name,
+			"error": fmt.Sprintf("%+v\n", err),
+		}).Error("catu.App error on roles-updated event")
+	}
 }
 
 func (r *AppStruct) LoadTemplates() error {
@@ -502,32 +1401,51 @@ func (r *AppStruct) LoadTemplates() error {
 		return nil
 	}
 
-	tpls, err := findAndParseTemplates(rootDir, r.templateFunctions)
+	if r.templateBase == nil && r.Configuration.GetBool("TEMPLATE_WATCH") {
+		r.templateWatch = &templateWatch{rootDir: rootDir}
+		r.templateWatch.changed()
+	}
+
+	tpls, err := r.parseTemplates()
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			// "error":   errHealthCheckHandlerr,
 			"rootDir": rootDir,
 		}).Error("catu.App.LoadTemplates Error on parse templates")
-		r.templates = tpls
+		r.setTemplates(tpls)
 		return err
 	}
 
-	r.templates = tpls
+	r.setTemplates(tpls)
 
 	logrus.WithFields(logrus.Fields{
-		"count": len(r.templates.Templates()),
+		"count": len(tpls.Templates()),
 	}).Debug("catu.App.ParseTemplates templates loaded")
 
 	return nil
 }
 
-// Run migrations
+// Run migrations, with AUTO_MIGRATE=true the SetModel models are migrated before the "migrate" event.
+// Then the tenant databases are migrated, see MigrateTenant
 func (r *AppStruct) Migrate() error {
+	if r.Configuration.GetBool("AUTO_MIGRATE") {
+		if err := r.MigrateModels(); err != nil {
+			return errors.Wrap(err, "App.Migrate migrate models error")
+		}
+	}
+
 	err, _ := r.Events.Fire("migrate", event.M{"app": r})
 	if err != nil {
 		return errors.Wrap(err, "App.Migrate migrate error")
 	}
 
+	// after the "migrate" event, it loads the tenants table with TENANTS_SOURCE=db
+	for _, id := range r.tenantIDs() {
+		if err := r.MigrateTenant(id); err != nil {
+			return errors.Wrap(err, "App.Migrate migrate tenant error")
+		}
+	}
+
 	return nil
 }
 
@@ -544,6 +1462,7 @@ func (r *AppStruct) Close() error {
 }
 
 func newApp(options *AppOptions) App {
+	configFile := loadConfigFile()
 	cfg := configuration.NewCfg()
 	logger.Init()
 
@@ -564,18 +1483,31 @@ func newApp(options *AppOptions) App {
 	}
 
 	app := AppStruct{
-		Options:       options,
-		Theme:         cfg.GetF("THEME", "site"),
-		Layout:        "layouts/default",
-		Configuration: cfg,
-		Events:        event.NewManager("app"),
-		router:        echo.New(),
-		routerGroups:  make(map[string]*echo.Group),
-		Resources:     make(map[string]*HTTPResource),
+		Options:          options,
+		Theme:            cfg.GetF("THEME", "site"),
+		Layout:           "layouts/default",
+		Configuration:    cfg,
+		configFile:       configFile,
+		Events:           event.NewManager("app"),
+		router:           echo.New(),
+		routerGroups:     make(map[string]*echo.Group),
+		routerGroupPaths: make(map[string]string),
+		bodyLimits:       &bodyLimits{groups: map[string]int64{}},
+		Resources:        make(map[string]*HTTPResource),
+		DBs:              make(map[string]*gorm.DB),
 	}
 
 	app.RolesString, _ = acl.LoadRoles()
+	app.RolesList = make(map[string]acl.Role)
+	app.superRole = cfg.GetF("ACL_SUPER_ROLE", "administrator")
+	app.unauthenticatedRole = cfg.GetF("ACL_UNAUTHENTICATED_ROLE", "unAuthenticated")
 
+	app.router.Pre(clientIPMiddleware(cfg))
+	if !cfg.GetBool("REQUEST_ID_DISABLE") {
+		app.router.Pre(requestIDMiddleware())
+	}
+
+	app.router.Use(recoverMiddleware(&app))
 	app.router.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			cc := &RequestContext{
@@ -586,15 +1518,38 @@ func newApp(options *AppOptions) App {
 	})
 
 	app.router.Binder = &CustomBinder{}
-	app.router.HTTPErrorHandler = CustomHTTPErrorHandler
-	app.router.Validator = &helpers.CustomValidator{Validator: validator.New()}
+	app.router.JSONSerializer = &JSONSerializer{app: &app}
+	app.headerTransformer = NewHeaderTransformer(&app)
+	app.staticRoutes = NewStaticRoutes(&app)
+	app.cache = cache.New(cache.NewMemoryStore())
+	app.uploadStorage = &DirStorage{Dir: cfg.GetF("UPLOAD_PATH", "uploads")}
+	app.jobs = newJobRunner()
+	app.httpServices = map[string]*http_client.Client{}
+	app.scheduler = newScheduler()
+	app.webSocketHub = NewHub(&app)
+	app.i18n = newI18nStore()
+	app.responseCacheStore = cache.NewLRUStore(int(cfg.GetInt64F("RESPONSE_CACHE_MAX_ENTRIES", 1000)))
+	app.router.HTTPErrorHandler = app.httpErrorHandler
+	// VALIDATION_FIELD_NAMES=struct keeps the Go struct field names in validation errors
+	if cfg.Get("VALIDATION_FIELD_NAMES") == "struct" {
+		app.router.Validator = helpers.NewCustomValidator(validator.New())
+	} else {
+		app.router.Validator = helpers.NewCustomValidator(helpers.NewValidator())
+	}
 
 	app.router.GET("/health", HealthCheckHandler)
+	app.router.GET("/ready", ReadyHandler)
+	app.RegisterHealthCheck("database", app.databaseHealthCheck)
+	app.router.GET("/_progress/:token", progressHandler(&app))
+	app.router.GET(UploadsURLPath+"/*", UploadedFileHandler)
 	app.Plugins = make(map[string]Pluginer)
 
 	app.Models = make(map[string]interface{})
+	app.commands = make(map[string]*Command)
+	app.responseFormats = newResponseFormats()
 
-	app.templates = &template.Template{}
+	// empty until LoadTemplates, renders return one template not found error
+	app.templates = template.New("")
 
 	app.SetRouterGroup("main", "/")
 	app.SetRouterGroup("public", "/public")
@@ -603,6 +1558,10 @@ func newApp(options *AppOptions) App {
 	apiRouterGroup.GET("", HealthCheckHandler)
 
 	app.templateFunctions = sprig.FuncMap()
+	app.templateFunctions["assetURL"] = app.AssetURL
+	app.templateFunctions["t"] = app.templateTranslate
+	app.templateFunctions["partial"] = app.templatePartial
+	app.templateFunctions["flashes"] = templateFlashes
 
 	return &app
 }
@@ -1,5 +1,19 @@
 package catu
 
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/go-catupiry/catu/acl"
+	"github.com/go-catupiry/catu/configuration"
+	"github.com/gookit/event"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
 var testAppInstance App
 
 func GetTestAppInstance() App {
@@ -18,3 +32,280 @@ func GetTestAppInstance() App {
 
 	return app
 }
+
+// newTestApp returns one not bootstrapped app with a temporary sqlite database and templates disabled
+func newTestApp(t *testing.T) App {
+	t.Setenv("DB_URI", filepath.Join(t.TempDir(), "test.sqlite"))
+	t.Setenv("TEMPLATE_DISABLE", "true")
+
+	return Init(&AppOptions{})
+}
+
+// overrideTestCfg overrides some configuration values of one app, ex: to check the request app is used and not GetApp
+type overrideTestCfg struct {
+	configuration.ConfigurationInterface
+	values map[string]string
+}
+
+func (c *overrideTestCfg) GetF(key, fallback string) string {
+	if v, ok := c.values[key]; ok {
+		return v
+	}
+	return c.ConfigurationInterface.GetF(key, fallback)
+}
+
+func (c *overrideTestCfg) GetBool(key string) bool {
+	if v, ok := c.values[key]; ok {
+		return v == "true"
+	}
+	return c.ConfigurationInterface.GetBool(key)
+}
+
+func (c *overrideTestCfg) GetInt(key string) int {
+	if v, ok := c.values[key]; ok {
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	return c.ConfigurationInterface.GetInt(key)
+}
+
+func (c *overrideTestCfg) GetIntF(key string, fallback int) int {
+	if v, ok := c.values[key]; ok {
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	return c.ConfigurationInterface.GetIntF(key, fallback)
+}
+
+func (c *overrideTestCfg) GetInt64F(key string, fallback int64) int64 {
+	if v, ok := c.values[key]; ok {
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return n
+	}
+	return c.ConfigurationInterface.GetInt64F(key, fallback)
+}
+
+// newGlobalTestApp returns one other app as the GetApp app until the test end, with the configuration values
+func newGlobalTestApp(t *testing.T, values map[string]string) App {
+	previous := appInstance
+	t.Cleanup(func() { appInstance = previous })
+
+	app := newTestApp(t)
+	app.(*AppStruct).Configuration = &overrideTestCfg{ConfigurationInterface: app.GetConfiguration(), values: values}
+	return app
+}
+
+// testUser is one minimal UserInterface implementation for tests
+type testUser struct {
+	ID    string
+	Roles []string
+}
+
+func (u *testUser) GetID() string                 { return u.ID }
+func (u *testUser) SetID(id string) error         { u.ID = id; return nil }
+func (u *testUser) GetRoles() []string            { return u.Roles }
+func (u *testUser) SetRoles(v []string) error     { u.Roles = v; return nil }
+func (u *testUser) AddRole(role string) error     { u.Roles = append(u.Roles, role); return nil }
+func (u *testUser) RemoveRole(role string) error  { return nil }
+func (u *testUser) GetEmail() string              { return "" }
+func (u *testUser) SetEmail(v string) error       { return nil }
+func (u *testUser) GetUsername() string           { return "" }
+func (u *testUser) SetUsername(v string) error    { return nil }
+func (u *testUser) GetDisplayName() string        { return "" }
+func (u *testUser) SetDisplayName(v string) error { return nil }
+func (u *testUser) GetFullName() string           { return "" }
+func (u *testUser) SetFullName(v string) error    { return nil }
+func (u *testUser) GetLanguage() string           { return "" }
+func (u *testUser) SetLanguage(v string) error    { return nil }
+func (u *testUser) IsActive() bool                { return true }
+func (u *testUser) SetActive(blocked bool) error  { return nil }
+func (u *testUser) IsBlocked() bool               { return false }
+func (u *testUser) SetBlocked(blocked bool) error { return nil }
+func (u *testUser) FillById(ID string) error      { return nil }
+
+func TestAppRoles(t *testing.T) {
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+
+	updated := []string{}
+	app.GetEvents().On("roles-updated", event.ListenerFunc(func(e event.Event) error {
+		updated = append(updated, e.Get("role").(string))
+		return nil
+	}))
+
+	t.Run("Should add roles with parents", func(t *testing.T) {
+		assert.Nil(t, app.AddRoleToList(acl.Role{Name: "contributor", Permissions: []string{"content.create"}}))
+		assert.Nil(t, app.AddRoleToList(acl.Role{Name: "editor", Permissions: []string{"content.*"}, Parents: []string{"contributor"}}))
+
+		err := app.AddRoleToList(acl.Role{Name: "editor"})
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "role already exists: editor")
+		assert.NotNil(t, app.AddRoleToList(acl.Role{}))
+
+		assert.True(t, app.Can("content.delete", []string{"editor"}))
+		assert.False(t, app.Can("content.delete", []string{"contributor"}))
+		assert.Equal(t, []string{"content.*", "content.create"}, app.GetRolePermissions("editor"))
+		assert.Equal(t, []string{"contributor", "editor"}, updated)
+	})
+
+	t.Run("Should change the role permissions", func(t *testing.T) {
+		assert.Nil(t, app.SetRolePermission("contributor", "image.upload", true))
+		assert.True(t, app.GetRolePermission("editor", "image.upload"))
+
+		assert.Nil(t, app.RemovePermissionFromRole("contributor", "image.upload"))
+		assert.False(t, app.Can("image.upload", []string{"editor"}))
+
+		err := app.RemovePermissionFromRole("unknown", "image.upload")
+		assert.NotNil(t, err)
+		assert.Equal(t, []string{}, app.GetRolePermissions("unknown"))
+		assert.False(t, app.Can("content.create", []string{"unknown"}))
+	})
+
+	t.Run("Should return one copy of the roles", func(t *testing.T) {
+		roles := app.GetRoles()
+		editor := roles["editor"]
+		editor.Permissions[0] = "users.delete"
+		roles["editor"] = editor
+		delete(roles, "contributor")
+
+		assert.Equal(t, []string{"content.*", "content.create"}, app.GetRolePermissions("editor"))
+		assert.NotNil(t, app.GetRole("contributor"))
+	})
+
+	t.Run("Should use one configurable super role", func(t *testing.T) {
+		assert.True(t, app.Can("anything", []string{"administrator"}))
+
+		app.SetSuperRole("root")
+		assert.False(t, app.Can("anything", []string{"administrator"}))
+		assert.True(t, app.Can("anything", []string{"root"}))
+
+		app.SetSuperRole("")
+		assert.False(t, app.Can("anything", []string{""}))
+
+		t.Setenv("ACL_SUPER_ROLE", "owner")
+		assert.Equal(t, "owner", newTestApp(t).GetSuperRole())
+	})
+}
+
+type testRoleProvider struct {
+	roles map[string]acl.Role
+	err   error
+}
+
+func (p *testRoleProvider) LoadRoles() (map[string]acl.Role, error) {
+	return p.roles, p.err
+}
+
+func TestReloadRoles(t *testing.T) {
+	t.Run("Should load the default json roles", func(t *testing.T) {
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		assert.IsType(t, &acl.JSONRoleProvider{}, app.GetRoleProvider())
+		assert.NotNil(t, app.GetRole("authenticated"))
+	})
+
+	t.Run("Should replace the roles with one custom provider", func(t *testing.T) {
+		provider := &testRoleProvider{roles: map[string]acl.Role{
+			"editor": {Name: "editor", Permissions: []string{"content.update"}},
+		}}
+
+		app := newTestApp(t)
+		app.SetRoleProvider(provider)
+		assert.Nil(t, app.Bootstrap())
+		assert.True(t, app.Can("content.update", []string{"editor"}))
+		assert.False(t, app.Can("content.delete", []string{"editor"}))
+		assert.Nil(t, app.GetRole("authenticated"))
+
+		provider.roles = map[string]acl.Role{
+			"editor": {Name: "editor", Permissions: []string{"content.*"}},
+		}
+		assert.Nil(t, app.ReloadRoles())
+		assert.True(t, app.Can("content.delete", []string{"editor"}))
+
+		provider.err = errors.New("connection refused")
+		provider.roles = nil
+		assert.NotNil(t, app.ReloadRoles())
+		assert.True(t, app.Can("content.delete", []string{"editor"}))
+	})
+
+	t.Run("Should load the roles from the database", func(t *testing.T) {
+		t.Setenv("ROLES_SOURCE", "db")
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		assert.Nil(t, app.Migrate())
+
+		db := app.GetDB()
+		assert.Nil(t, db.Create(&acl.RoleRecord{Name: "editor"}).Error)
+		assert.Nil(t, db.Create(&acl.RolePermissionRecord{RoleName: "editor", Permission: "content.update"}).Error)
+		assert.False(t, app.Can("content.update", []string{"editor"}))
+
+		assert.Nil(t, app.ReloadRoles())
+		assert.True(t, app.Can("content.update", []string{"editor"}))
+
+		assert.Nil(t, db.Where("role_name = ?", "editor").Delete(&acl.RolePermissionRecord{}).Error)
+		assert.Nil(t, app.ReloadRoles())
+		assert.False(t, app.Can("content.update", []string{"editor"}))
+	})
+
+	t.Run("Should reload while checking permissions", func(t *testing.T) {
+		provider := &testRoleProvider{roles: map[string]acl.Role{
+			"editor": {Name: "editor", Permissions: []string{"content.update"}},
+		}}
+		app := newTestApp(t)
+		app.SetRoleProvider(provider)
+		assert.Nil(t, app.Bootstrap())
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				assert.True(t, app.Can("content.update", []string{"editor"}))
+			}()
+			go func() {
+				defer wg.Done()
+				assert.Nil(t, app.ReloadRoles())
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("Should reject one invalid ROLES_SOURCE", func(t *testing.T) {
+		t.Setenv("ROLES_SOURCE", "ldap")
+		app := newTestApp(t)
+		assert.NotNil(t, app.Bootstrap())
+	})
+}
+
+type requireConfigPlugin struct{}
+
+func (p *requireConfigPlugin) GetName() string { return "requireConfig" }
+
+func (p *requireConfigPlugin) Init(app App) error {
+	app.RequireConfig("TEST_SMTP_HOST", "TEST_SMTP_PASSWORD")
+	return nil
+}
+
+func TestRequireConfig(t *testing.T) {
+	t.Run("Should fail the Bootstrap with all missing keys", func(t *testing.T) {
+		app := newTestApp(t)
+		app.RequireConfig("TEST_API_KEY")
+		app.RegisterPlugin(&requireConfigPlugin{})
+
+		err := app.Bootstrap()
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "configuration missing required keys: TEST_API_KEY, TEST_SMTP_HOST, TEST_SMTP_PASSWORD")
+	})
+
+	t.Run("Should bootstrap with the keys and the _FILE variants", func(t *testing.T) {
+		secret := filepath.Join(t.TempDir(), "smtp_password")
+		assert.Nil(t, os.WriteFile(secret, []byte("s3cret\n"), 0600))
+		t.Setenv("TEST_SMTP_HOST", "localhost")
+		t.Setenv("TEST_SMTP_PASSWORD_FILE", secret)
+
+		app := newTestApp(t)
+		app.RegisterPlugin(&requireConfigPlugin{})
+		assert.Nil(t, app.Bootstrap())
+		assert.Equal(t, "s3cret", app.GetConfiguration().Get("TEST_SMTP_PASSWORD"))
+	})
+}
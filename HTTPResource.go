@@ -1,6 +1,122 @@
 package catu
 
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
 type HTTPResource struct {
-	Name       string
-	Controller *HTTPController
+	Name string
+	// Current controller, use GetController to read it while the server runs
+	Controller   *HTTPController
+	controllerMu sync.RWMutex
+	// Router group with the resource routes
+	RouterGroup *echo.Group
+	// Optional public ID obfuscation, see App.SetResourceIDCodec
+	IDCodec IDCodec
+	// Respond with JSON:API documents, see App.SetResourceJSONAPI
+	JSONAPI bool
+	// Path prefix of the resource routes, ex: "/api/articles"
+	Path string
+	// Cache the GET responses, see App.SetResourceCache
+	CacheTTL time.Duration
+	// Model name, see App.SetResourceModel
+	Model string
+	// Has the soft delete routes, see App.SetResourceWithOptions
+	SoftDelete      bool
+	DeletedAtColumn string
+	// Permissions checked in the routes, nil without checks, see ResourceOptions
+	Permissions *ResourcePermissions
+	// Record the mutations in the audit logs, see ResourceOptions
+	Audit bool
+	// Has the bulk routes, see App.SetResourceWithOptions
+	Bulk        bool
+	BulkMaxSize int
+}
+
+// GetController returns the current controller, replaced with App.ReplaceResource
+func (r *HTTPResource) GetController() HTTPController {
+	r.controllerMu.RLock()
+	defer r.controllerMu.RUnlock()
+	return *r.Controller
+}
+
+func (r *HTTPResource) setController(httpController HTTPController) {
+	r.controllerMu.Lock()
+	defer r.controllerMu.Unlock()
+	r.Controller = &httpController
+}
+
+// The resource routes call the current Controller, so it can be replaced with App.ReplaceResource.
+// Successful mutations trigger the resource events, ex: EventResourceUpdated, and are recorded in the audit logs
+
+func (r *HTTPResource) Query(c echo.Context) error {
+	return r.GetController().Query(c)
+}
+
+func (r *HTTPResource) Create(c echo.Context) error {
+	audit := r.startAudit(c, AuditCreate)
+	err := r.GetController().Create(c)
+	audit.finish(c, err)
+	triggerResourceEvent(c, EventResourceCreated, r.Name, err)
+	r.invalidateCache(c, err)
+	return err
+}
+
+func (r *HTTPResource) Count(c echo.Context) error {
+	return r.GetController().Count(c)
+}
+
+func (r *HTTPResource) FindOne(c echo.Context) error {
+	return r.GetController().FindOne(c)
+}
+
+func (r *HTTPResource) Update(c echo.Context) error {
+	audit := r.startAudit(c, AuditUpdate)
+	err := r.GetController().Update(c)
+	audit.finish(c, err)
+	triggerResourceEvent(c, EventResourceUpdated, r.Name, err)
+	r.invalidateCache(c, err)
+	return err
+}
+
+func (r *HTTPResource) Delete(c echo.Context) error {
+	audit := r.startAudit(c, AuditDelete)
+	err := r.GetController().Delete(c)
+	audit.finish(c, err)
+	triggerResourceEvent(c, EventResourceDeleted, r.Name, err)
+	r.invalidateCache(c, err)
+	return err
+}
+
+// invalidateCache removes the cached resource responses after one successful mutation,
+// or after the bulk transaction in bulk items, see deferBulkEffect
+func (r *HTTPResource) invalidateCache(c echo.Context, err error) {
+	if r.CacheTTL == 0 || err != nil || c.Response().Status >= http.StatusBadRequest {
+		return
+	}
+
+	invalidate := func(ctx context.Context) {
+		r.runInvalidateCache(ctx, c)
+	}
+	if !deferBulkEffect(c, invalidate) {
+		invalidate(c.Request().Context())
+	}
+}
+
+func (r *HTTPResource) runInvalidateCache(ctx context.Context, c echo.Context) {
+	err := SideEffect(ctx, "cache", "invalidate "+r.Path, nil, func() error {
+		return handlerApp(c).CacheInvalidate(r.Path)
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"resource": r.Name,
+			"error":    err.Error(),
+		}).Error("catu.HTTPResource error on invalidate cache")
+	}
 }
@@ -0,0 +1,103 @@
+package catu
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type resourceTestController struct {
+	idCodecTestController
+	name string
+}
+
+func (ctl *resourceTestController) Query(c echo.Context) error {
+	return c.String(http.StatusOK, ctl.name+" query")
+}
+
+type resourceTestPlugin struct{}
+
+func (p *resourceTestPlugin) GetName() string { return "resourceTest" }
+
+// overrides the core articles resource Query handler
+func (p *resourceTestPlugin) Init(app App) error {
+	app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+		return app.ReplaceResource("articles", &resourceTestController{name: "plugin"}, nil)
+	}), event.Low)
+
+	return nil
+}
+
+func TestSetResource(t *testing.T) {
+	app := newTestApp(t)
+	app.RegisterPlugin(&resourceTestPlugin{})
+
+	api := app.GetRouterGroup("api")
+	assert.Nil(t, app.SetResource("articles", &resourceTestController{name: "core"}, api.Group("/articles")))
+	assert.Nil(t, app.SetResource("authors", &resourceTestController{name: "authors"}, api.Group("/authors")))
+
+	request := func(path string) string {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	t.Run("Should return error if the resource is already registered", func(t *testing.T) {
+		err := app.SetResource("articles", &resourceTestController{name: "duplicated"}, api.Group("/articles"))
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "resource already registered: articles")
+		assert.Equal(t, "core query", request("/api/articles"))
+	})
+
+	t.Run("Should allow plugins to replace one resource controller", func(t *testing.T) {
+		assert.Nil(t, app.Bootstrap())
+		assert.Equal(t, "plugin query", request("/api/articles"))
+		assert.Equal(t, "10", request("/api/articles/10"))
+		assert.Equal(t, "authors query", request("/api/authors"))
+	})
+
+	t.Run("Should bind the routes in a new router group", func(t *testing.T) {
+		assert.Nil(t, app.ReplaceResource("authors", &resourceTestController{name: "v2"}, api.Group("/v2/authors")))
+		assert.Equal(t, "v2 query", request("/api/v2/authors"))
+		assert.Equal(t, "v2 query", request("/api/authors"))
+
+		assert.Nil(t, app.ReplaceResource("tags", &resourceTestController{name: "tags"}, api.Group("/tags")))
+		assert.Equal(t, "tags query", request("/api/tags"))
+	})
+
+	t.Run("Should get and list resources", func(t *testing.T) {
+		assert.Equal(t, "articles", app.GetResource("articles").Name)
+		assert.Nil(t, app.GetResource("unknown"))
+
+		names := []string{}
+		for _, r := range app.ListResources() {
+			names = append(names, r.Name)
+		}
+		assert.Equal(t, []string{"articles", "authors", "tags"}, names)
+	})
+
+	t.Run("Should replace one resource controller while serving requests", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					request("/api/tags")
+				}
+			}()
+		}
+		for i := 0; i < 20; i++ {
+			assert.Nil(t, app.ReplaceResource("tags", &resourceTestController{name: "v3"}, nil))
+		}
+		wg.Wait()
+
+		assert.Equal(t, "v3 query", request("/api/tags"))
+	})
+}
@@ -1,6 +1,80 @@
 package catu
 
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
 type Pluginer interface {
 	Init(app App) error
 	GetName() string
 }
+
+// PluginerWithDeps is one plugin that needs other plugins initialized before it
+type PluginerWithDeps interface {
+	Pluginer
+	// Names of the plugins that should be initialized before this plugin
+	GetDependencies() []string
+}
+
+// sortPlugins returns plugins in initialization order, dependencies first.
+// Plugins without dependencies are sorted by name to keep the Bootstrap deterministic
+func sortPlugins(plugins map[string]Pluginer) ([]Pluginer, error) {
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sorted := make([]Pluginer, 0, len(plugins))
+	// 1 = visiting, 2 = done
+	state := make(map[string]int, len(plugins))
+	path := []string{}
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case 2:
+			return nil
+		case 1:
+			for i := range path {
+				if path[i] == name {
+					cycle := append(append([]string{}, path[i:]...), name)
+					return errors.New("dependency cycle between plugins: " + strings.Join(cycle, " -> "))
+				}
+			}
+		}
+
+		state[name] = 1
+		path = append(path, name)
+
+		p := plugins[name]
+		if pd, ok := p.(PluginerWithDeps); ok {
+			for _, dep := range pd.GetDependencies() {
+				if plugins[dep] == nil {
+					return errors.New("plugin " + name + " depends on missing plugin " + dep)
+				}
+
+				if err := visit(dep); err != nil {
+					return err
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[name] = 2
+		sorted = append(sorted, p)
+
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
@@ -0,0 +1,112 @@
+package catu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testPlugin struct {
+	Name         string
+	Dependencies []string
+	initOrder    *[]string
+}
+
+func (p *testPlugin) Init(app App) error {
+	*p.initOrder = append(*p.initOrder, p.Name)
+	return nil
+}
+
+func (p *testPlugin) GetName() string {
+	return p.Name
+}
+
+type testPluginWithDeps struct {
+	testPlugin
+}
+
+func (p *testPluginWithDeps) GetDependencies() []string {
+	return p.Dependencies
+}
+
+func TestSortPlugins(t *testing.T) {
+	order := []string{}
+
+	newPlugin := func(name string, deps ...string) Pluginer {
+		if deps == nil {
+			return &testPlugin{Name: name, initOrder: &order}
+		}
+		return &testPluginWithDeps{testPlugin{Name: name, Dependencies: deps, initOrder: &order}}
+	}
+
+	sortedNames := func(plugins map[string]Pluginer) ([]string, error) {
+		sorted, err := sortPlugins(plugins)
+		names := []string{}
+		for _, p := range sorted {
+			names = append(names, p.GetName())
+		}
+		return names, err
+	}
+
+	t.Run("Should sort plugins without dependencies by name", func(t *testing.T) {
+		names, err := sortedNames(map[string]Pluginer{
+			"c": newPlugin("c"),
+			"a": newPlugin("a"),
+			"b": newPlugin("b"),
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b", "c"}, names)
+	})
+
+	t.Run("Should sort dependencies first", func(t *testing.T) {
+		names, err := sortedNames(map[string]Pluginer{
+			"auth":    newPlugin("auth", "session", "acl"),
+			"session": newPlugin("session", "cache"),
+			"acl":     newPlugin("acl"),
+			"cache":   newPlugin("cache"),
+			"blog":    newPlugin("blog", "auth"),
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"acl", "cache", "session", "auth", "blog"}, names)
+	})
+
+	t.Run("Should return error on missing dependency", func(t *testing.T) {
+		_, err := sortedNames(map[string]Pluginer{
+			"auth": newPlugin("auth", "session"),
+		})
+		assert.NotNil(t, err)
+		assert.Equal(t, "plugin auth depends on missing plugin session", err.Error())
+	})
+
+	t.Run("Should return error on dependency cycle", func(t *testing.T) {
+		_, err := sortedNames(map[string]Pluginer{
+			"a": newPlugin("a", "b"),
+			"b": newPlugin("b", "c"),
+			"c": newPlugin("c", "a"),
+		})
+		assert.NotNil(t, err)
+		assert.Equal(t, "dependency cycle between plugins: a -> b -> c -> a", err.Error())
+	})
+
+	t.Run("Should init plugins in dependency order on Bootstrap", func(t *testing.T) {
+		order = []string{}
+		app := newTestApp(t)
+		app.RegisterPlugin(newPlugin("auth", "session"))
+		app.RegisterPlugin(newPlugin("session"))
+		app.RegisterPlugin(newPlugin("aaa"))
+
+		assert.Nil(t, app.Bootstrap())
+		assert.Equal(t, []string{"aaa", "session", "auth"}, order)
+	})
+
+	t.Run("Should return one wrapped error on Bootstrap", func(t *testing.T) {
+		order = []string{}
+		app := newTestApp(t)
+		app.RegisterPlugin(newPlugin("auth", "session"))
+
+		err := app.Bootstrap()
+		assert.NotNil(t, err)
+		assert.Equal(t, "App.Bootstrap | Error on sort plugins: plugin auth depends on missing plugin session", err.Error())
+		assert.Empty(t, order)
+	})
+}
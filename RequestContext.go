@@ -25,36 +25,45 @@ type RequestContextOpts struct {
 }
 
 func NewRequestContext(opts *RequestContextOpts) *RequestContext {
-	app := GetApp()
+	ctx := RequestContext{}
+	ctx.fill(GetApp(), opts.EchoContext)
+	return &ctx
+}
+
+// fill the context attributes from app configurations and the echo request
+func (ctx *RequestContext) fill(app App, c echo.Context) {
 	cfg := app.GetConfiguration()
-	ctx := RequestContext{
-		App:         app,
-		EchoContext: opts.EchoContext,
-		// Title:               "",
-		Theme:  app.GetTheme(),
-		Layout: app.GetLayout(),
-		ENV:    cfg.GetF("GO_ENV", "development"),
-		Query:  query_parser_to_db.NewQuery(50),
-		Pager:  pagination.NewPager(),
+
+	ctx.App = app
+	ctx.EchoContext = c
+	ctx.Theme = app.GetTheme()
+	ctx.Layout = app.GetLayout()
+	ctx.ENV = cfg.GetF("GO_ENV", "development")
+	ctx.Query = query_parser_to_db.NewQuery(50)
+	ctx.Locale = app.GetDefaultLocale()
+
+	if ctx.Pager == nil {
+		ctx.Pager = pagination.NewPager()
 	}
 
 	// Is a context used on CLIs, not in HTTP request / echo then skip it
-	if opts.EchoContext == nil || ctx.Request().URL == nil {
-		return &ctx
+	if c == nil || ctx.Request().URL == nil {
+		return
 	}
 
+	ctx.RequestID, _ = c.Get(requestIDKey).(string)
+	ctx.Tenant, _ = c.Get(tenantKey).(*Tenant)
+	ctx.Locale = app.DetectLocale(c.Request())
 	ctx.Pager.CurrentUrl = ctx.Request().URL.Path
 	ctx.Pager.Limit, _ = strconv.ParseInt(cfg.GetF("PAGER_LIMIT", "20"), 10, 64)
 
-	if opts.EchoContext.Request().Method != "GET" {
-		return &ctx
+	if c.Request().Method != "GET" {
+		return
 	}
 
-	limitMax, _ := strconv.ParseInt(app.GetConfiguration().GetF("PAGER_LIMIT_MAX", "50"), 10, 64)
-
-	rawParams := opts.EchoContext.QueryParams()
+	limitMax, _ := strconv.ParseInt(cfg.GetF("PAGER_LIMIT_MAX", "50"), 10, 64)
 
-	filteredParamArray := []string{}
+	rawParams := c.QueryParams()
 
 	for key, param := range rawParams {
 		// get limit with max value for security:
@@ -80,12 +89,6 @@ func NewRequestContext(opts *RequestContextOpts) *RequestContext {
 
 		ctx.Query.AddQueryParamFromRaw(key, param)
 	}
-
-	if len(filteredParamArray) > 0 {
-		strings.Join(filteredParamArray[:], ",")
-	}
-
-	return &ctx
 }
 
 type RequestContext struct {
@@ -110,6 +113,15 @@ type RequestContext struct {
 	Pager     *pagination.Pager
 
 	ENV string
+	// Request ID from the X-Request-ID header or generated, see GetRequestID
+	RequestID string
+	// Request locale, see App.DetectLocale
+	Locale string
+	// Request tenant, nil without the TENANT_RESOLVER, see GetTenant
+	Tenant *Tenant
+
+	// released to the pool, see IsReleased
+	released bool
 }
 
 /// --- Start echo.Context overrides
@@ -447,7 +459,21 @@ func (r *RequestContext) RenderPagination(name string) string {
 
 // Render one template, with support for themes
 func (r *RequestContext) RenderTemplate(wr io.Writer, name string, data interface{}) error {
-	return r.App.GetTemplates().ExecuteTemplate(wr, path.Join(r.Theme, name), data)
+	return r.App.GetTemplates().ExecuteTemplate(wr, r.templateName(name), data)
+}
+
+// templateName returns the theme template name or the tenant override if it exists,
+// ex: "tenants/acme/site/home" overrides "site/home" in the acme requests
+func (r *RequestContext) templateName(name string) string {
+	name = path.Join(r.Theme, name)
+	if r.Tenant == nil {
+		return name
+	}
+
+	if override := path.Join("tenants", r.Tenant.ID, name); r.App.GetTemplates().Lookup(override) != nil {
+		return override
+	}
+	return name
 }
 
 // Partial - Include and render one template inside other
@@ -488,10 +514,13 @@ func (r *RequestContext) GetBodyClassText() string {
 	return strings.Join(r.BodyClass, " ")
 }
 
-// Get selected response type
+// Get selected response type, without one selected type it returns the negotiated type, see App.NegotiateResponseType
 func (r *RequestContext) GetResponseContentType() string {
 	v := r.GetString("responseContentType")
 	if v == "" {
+		if r.App != nil {
+			return r.App.NegotiateResponseType(r)
+		}
 		return r.Request().Header.Get(echo.HeaderContentType) // default ...
 	}
 
@@ -532,12 +561,20 @@ func (r *RequestContext) GetAuthenticatedRoles() *[]string {
 		return &r.Roles
 	}
 
+	if r.App != nil {
+		return &[]string{r.App.GetUnauthenticatedRole()}
+	}
+
 	return &[]string{"unAuthenticated"}
 }
 
 func (r *RequestContext) SetAuthenticatedUser(user UserInterface) {
 	r.AuthenticatedUser = user
 	r.IsAuthenticated = true
+	// for the request log after the RequestContext release
+	if r.EchoContext != nil {
+		r.Set(requestUserIDKey, user.GetID())
+	}
 }
 
 func (r *RequestContext) SetAuthenticatedUserAndFillRoles(user UserInterface) {
@@ -2,6 +2,7 @@ package acl
 
 import (
 	"io/ioutil"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -9,6 +10,7 @@ import (
 type NewRoleOpts struct {
 	Name          string
 	Permissions   []string
+	Parents       []string
 	CanAddInUsers bool
 	IsSystemRole  bool
 }
@@ -22,6 +24,7 @@ func NewRole(opts *NewRoleOpts) (*Role, error) {
 		Name:          opts.Name,
 		CanAddInUsers: opts.CanAddInUsers,
 		Permissions:   opts.Permissions,
+		Parents:       opts.Parents,
 		IsSystemRole:  opts.IsSystemRole,
 	}
 
@@ -29,15 +32,19 @@ func NewRole(opts *NewRoleOpts) (*Role, error) {
 }
 
 type Role struct {
-	Name          string   `json:"name"`
-	Permissions   []string `json:"permissions"`
+	Name string `json:"name"`
+	// Permission names, one trailing "*" matches all permissions with the prefix, ex: "content.*" matches "content.create"
+	Permissions []string `json:"permissions"`
+	// Roles with permissions inherited by this role, see Can
+	Parents       []string `json:"parents,omitempty"`
 	CanAddInUsers bool     `json:"canAddInUsers"`
 	IsSystemRole  bool     `json:"isSystemRole"`
 }
 
+// Can checks the role permissions without the parents, use the Can function to check the inherited permissions
 func (r *Role) Can(permission string) bool {
 	for i := range r.Permissions {
-		if permission == r.Permissions[i] {
+		if MatchPermission(r.Permissions[i], permission) {
 			return true
 		}
 	}
@@ -45,6 +52,71 @@ func (r *Role) Can(permission string) bool {
 	return false
 }
 
+// MatchPermission returns true if the permission matches the pattern, one exact permission or one with trailing "*"
+func MatchPermission(pattern, permission string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(permission, pattern[:len(pattern)-1])
+	}
+
+	return pattern == permission
+}
+
+// Can checks the permission in the role and in its parents recursively.
+// Returns false for unknown roles, unknown parents and parents cycles are ignored
+func Can(roles map[string]Role, roleName, permission string) bool {
+	found := false
+
+	walkRoles(roles, roleName, map[string]bool{}, func(r *Role) bool {
+		found = r.Can(permission)
+		return !found
+	})
+
+	return found
+}
+
+// Permissions returns the role permissions with the permissions inherited from its parents, without duplicates
+func Permissions(roles map[string]Role, roleName string) []string {
+	permissions := []string{}
+	added := map[string]bool{}
+
+	walkRoles(roles, roleName, map[string]bool{}, func(r *Role) bool {
+		for _, p := range r.Permissions {
+			if !added[p] {
+				added[p] = true
+				permissions = append(permissions, p)
+			}
+		}
+		return true
+	})
+
+	return permissions
+}
+
+// walkRoles visits the role and its parents once, until visit returns false
+func walkRoles(roles map[string]Role, roleName string, visited map[string]bool, visit func(r *Role) bool) bool {
+	if visited[roleName] {
+		return true
+	}
+	visited[roleName] = true
+
+	r, ok := roles[roleName]
+	if !ok {
+		return true
+	}
+
+	if !visit(&r) {
+		return false
+	}
+
+	for _, parent := range r.Parents {
+		if !walkRoles(roles, parent, visited, visit) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (r *Role) AddPermission(permission string) {
 	for i := range r.Permissions {
 		if permission == r.Permissions[i] {
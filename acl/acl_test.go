@@ -203,3 +203,57 @@ func TestLoadRoles(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchPermission(t *testing.T) {
+	assert.True(t, acl.MatchPermission("content.create", "content.create"))
+	assert.True(t, acl.MatchPermission("content.*", "content.create"))
+	assert.True(t, acl.MatchPermission("content.*", "content.image.delete"))
+	assert.True(t, acl.MatchPermission("*", "users.delete"))
+	assert.False(t, acl.MatchPermission("content.*", "content"))
+	assert.False(t, acl.MatchPermission("content.*", "contents.create"))
+	assert.False(t, acl.MatchPermission("content.create", "content.delete"))
+	// only one trailing wildcard is supported
+	assert.False(t, acl.MatchPermission("*.create", "content.create"))
+}
+
+func TestCan(t *testing.T) {
+	roles := map[string]acl.Role{
+		"contributor": {Name: "contributor", Permissions: []string{"content.create"}},
+		"editor":      {Name: "editor", Permissions: []string{"content.update"}, Parents: []string{"contributor"}},
+		"chief":       {Name: "chief", Permissions: []string{"users.*"}, Parents: []string{"editor", "missing"}},
+		"a":           {Name: "a", Permissions: []string{"a.read"}, Parents: []string{"b"}},
+		"b":           {Name: "b", Permissions: []string{"b.read"}, Parents: []string{"a"}},
+		"self":        {Name: "self", Parents: []string{"self"}},
+	}
+
+	t.Run("Should check the inheritance chain", func(t *testing.T) {
+		assert.True(t, acl.Can(roles, "editor", "content.update"))
+		assert.True(t, acl.Can(roles, "editor", "content.create"))
+		assert.True(t, acl.Can(roles, "chief", "content.create"))
+		assert.False(t, acl.Can(roles, "contributor", "content.update"))
+		assert.False(t, acl.Can(roles, "editor", "users.delete"))
+	})
+
+	t.Run("Should match the wildcard permissions", func(t *testing.T) {
+		assert.True(t, acl.Can(roles, "chief", "users.delete"))
+		assert.False(t, acl.Can(roles, "chief", "settings.update"))
+	})
+
+	t.Run("Should stop in cycles", func(t *testing.T) {
+		assert.True(t, acl.Can(roles, "a", "b.read"))
+		assert.True(t, acl.Can(roles, "b", "a.read"))
+		assert.False(t, acl.Can(roles, "a", "c.read"))
+		assert.False(t, acl.Can(roles, "self", "a.read"))
+	})
+
+	t.Run("Should not allow unknown roles", func(t *testing.T) {
+		assert.False(t, acl.Can(roles, "unknown", "content.create"))
+		assert.False(t, acl.Can(nil, "editor", "content.create"))
+	})
+
+	t.Run("Should list the inherited permissions", func(t *testing.T) {
+		assert.Equal(t, []string{"users.*", "content.update", "content.create"}, acl.Permissions(roles, "chief"))
+		assert.Equal(t, []string{"a.read", "b.read"}, acl.Permissions(roles, "a"))
+		assert.Equal(t, []string{}, acl.Permissions(roles, "unknown"))
+	})
+}
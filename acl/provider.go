@@ -0,0 +1,108 @@
+package acl
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// RoleProvider loads the roles list by name, ex: from one JSON file or from the database
+type RoleProvider interface {
+	LoadRoles() (map[string]Role, error)
+}
+
+// JSONRoleProvider loads the roles from one JSON object by role name, see LoadRoles
+type JSONRoleProvider struct {
+	JSON string
+}
+
+func (p *JSONRoleProvider) LoadRoles() (map[string]Role, error) {
+	roles := map[string]Role{}
+
+	if err := json.Unmarshal([]byte(p.JSON), &roles); err != nil {
+		return nil, errors.Wrap(err, "acl.JSONRoleProvider.LoadRoles error on parse roles")
+	}
+
+	return roles, nil
+}
+
+// RoleRecord is one role in the roles table of the DBRoleProvider
+type RoleRecord struct {
+	Name string `gorm:"column:name;primaryKey;size:100"`
+	// Comma separated parent role names
+	Parents       string `gorm:"column:parents;type:text"`
+	CanAddInUsers bool   `gorm:"column:can_add_in_users"`
+	IsSystemRole  bool   `gorm:"column:is_system_role"`
+}
+
+func (RoleRecord) TableName() string {
+	return "roles"
+}
+
+// RolePermissionRecord is one role permission in the role_permissions table of the DBRoleProvider
+type RolePermissionRecord struct {
+	ID         uint64 `gorm:"column:id;primaryKey"`
+	RoleName   string `gorm:"column:role_name;size:100;index"`
+	Permission string `gorm:"column:permission;size:255"`
+}
+
+func (RolePermissionRecord) TableName() string {
+	return "role_permissions"
+}
+
+// DBRoleProvider loads the roles from the roles and role_permissions tables, ex: roles managed in one admin UI
+type DBRoleProvider struct {
+	DB *gorm.DB
+}
+
+func NewDBRoleProvider(db *gorm.DB) *DBRoleProvider {
+	return &DBRoleProvider{DB: db}
+}
+
+// Migrate creates the roles tables
+func (p *DBRoleProvider) Migrate() error {
+	return p.DB.AutoMigrate(&RoleRecord{}, &RolePermissionRecord{})
+}
+
+func (p *DBRoleProvider) LoadRoles() (map[string]Role, error) {
+	records := []RoleRecord{}
+	if err := p.DB.Order("name").Find(&records).Error; err != nil {
+		return nil, errors.Wrap(err, "acl.DBRoleProvider.LoadRoles error on find roles")
+	}
+
+	permissions := []RolePermissionRecord{}
+	if err := p.DB.Order("id").Find(&permissions).Error; err != nil {
+		return nil, errors.Wrap(err, "acl.DBRoleProvider.LoadRoles error on find permissions")
+	}
+
+	roles := make(map[string]Role, len(records))
+	for _, record := range records {
+		role := Role{
+			Name:          record.Name,
+			Permissions:   []string{},
+			CanAddInUsers: record.CanAddInUsers,
+			IsSystemRole:  record.IsSystemRole,
+		}
+
+		for _, parent := range strings.Split(record.Parents, ",") {
+			if parent = strings.TrimSpace(parent); parent != "" {
+				role.Parents = append(role.Parents, parent)
+			}
+		}
+
+		roles[record.Name] = role
+	}
+
+	for _, p := range permissions {
+		role, ok := roles[p.RoleName]
+		if !ok {
+			continue
+		}
+		role.AddPermission(p.Permission)
+		roles[p.RoleName] = role
+	}
+
+	return roles, nil
+}
@@ -0,0 +1,58 @@
+package acl_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/go-catupiry/catu/acl"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestJSONRoleProvider(t *testing.T) {
+	p := &acl.JSONRoleProvider{JSON: `{"editor":{"name":"editor","permissions":["content.*"],"parents":["authenticated"]}}`}
+
+	roles, err := p.LoadRoles()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]acl.Role{
+		"editor": {Name: "editor", Permissions: []string{"content.*"}, Parents: []string{"authenticated"}},
+	}, roles)
+
+	_, err = (&acl.JSONRoleProvider{JSON: "{"}).LoadRoles()
+	assert.NotNil(t, err)
+}
+
+func TestDBRoleProvider(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "acl.sqlite")), &gorm.Config{})
+	assert.Nil(t, err)
+
+	p := acl.NewDBRoleProvider(db)
+
+	t.Run("Should return one error without the tables", func(t *testing.T) {
+		_, err := p.LoadRoles()
+		assert.NotNil(t, err)
+	})
+
+	assert.Nil(t, p.Migrate())
+
+	t.Run("Should load the roles with permissions and parents", func(t *testing.T) {
+		assert.Nil(t, db.Create(&[]acl.RoleRecord{
+			{Name: "contributor"},
+			{Name: "editor", Parents: "contributor, authenticated", CanAddInUsers: true},
+		}).Error)
+		assert.Nil(t, db.Create(&[]acl.RolePermissionRecord{
+			{RoleName: "contributor", Permission: "content.create"},
+			{RoleName: "editor", Permission: "content.*"},
+			{RoleName: "editor", Permission: "content.*"},
+			{RoleName: "removed", Permission: "content.delete"},
+		}).Error)
+
+		roles, err := p.LoadRoles()
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]acl.Role{
+			"contributor": {Name: "contributor", Permissions: []string{"content.create"}},
+			"editor":      {Name: "editor", Permissions: []string{"content.*"}, Parents: []string{"contributor", "authenticated"}, CanAddInUsers: true},
+		}, roles)
+	})
+}
@@ -0,0 +1,361 @@
+package catu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Audit log actions
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// EventAuditRecorded is triggered after one audit log is saved, with the "log"
+const EventAuditRecorded = "audit.recorded"
+
+// gorm create callback that captures the created records of the audited requests
+const auditCallbackName = "catu:audit"
+
+type auditContextKey struct{}
+
+// AuditLog is one resource mutation in the audit_logs table
+type AuditLog struct {
+	ID       uint64 `gorm:"column:id;primaryKey" json:"id"`
+	Resource string `gorm:"column:resource;size:100;index:idx_audit_logs_resource_record" json:"resource"`
+	RecordID string `gorm:"column:record_id;size:100;index:idx_audit_logs_resource_record" json:"recordId"`
+	Action   string `gorm:"column:action;size:20" json:"action"`
+	UserID   string `gorm:"column:user_id;size:100;index" json:"userId"`
+	IP       string `gorm:"column:ip;size:100" json:"ip"`
+	// JSON encoded changes by column, see GetChanges
+	Changes   string    `gorm:"column:changes;type:text" json:"changes"`
+	CreatedAt time.Time `gorm:"column:created_at;index" json:"createdAt"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+// AuditChange is the value of one column before and after the mutation, nil in the created and deleted records
+type AuditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// GetChanges decodes the changed columns, ex: {"title": {"from": "Draft", "to": "Final"}}
+func (l *AuditLog) GetChanges() (map[string]AuditChange, error) {
+	changes := map[string]AuditChange{}
+	if l.Changes == "" {
+		return changes, nil
+	}
+
+	err := json.Unmarshal([]byte(l.Changes), &changes)
+	return changes, err
+}
+
+// AuditLogFilter are the QueryAuditLogs filters, empty fields don't filter
+type AuditLogFilter struct {
+	Resource string
+	RecordID string
+	UserID   string
+	Action   string
+	Since    time.Time
+	Until    time.Time
+	// Max logs, default 50
+	Limit  int
+	Offset int
+}
+
+// QueryAuditLogs returns the audit logs newest first, ex: the changes of one record in one admin page
+func (r *AppStruct) QueryAuditLogs(filter AuditLogFilter) ([]AuditLog, error) {
+	db := r.GetDB()
+	if db == nil {
+		return nil, errors.New("catu.App.QueryAuditLogs database not initialized")
+	}
+
+	q := db.Model(&AuditLog{})
+	if filter.Resource != "" {
+		q = q.Where("resource = ?", filter.Resource)
+	}
+	if filter.RecordID != "" {
+		q = q.Where("record_id = ?", filter.RecordID)
+	}
+	if filter.UserID != "" {
+		q = q.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Action != "" {
+		q = q.Where("action = ?", filter.Action)
+	}
+	if !filter.Since.IsZero() {
+		q = q.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		q = q.Where("created_at < ?", filter.Until)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	logs := []AuditLog{}
+	err := q.Order("created_at DESC, id DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&logs).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "catu.App.QueryAuditLogs error on find logs")
+	}
+
+	return logs, nil
+}
+
+// initAudit creates the audit_logs table in the "migrate" event if one resource has the Audit option
+func (r *AppStruct) initAudit() {
+	r.Events.On("migrate", event.ListenerFunc(func(e event.Event) error {
+		if r.hasAuditedResources() {
+			return r.GetDB().AutoMigrate(&AuditLog{})
+		}
+		return nil
+	}), event.Normal)
+}
+
+func (r *AppStruct) hasAuditedResources() bool {
+	for _, resource := range r.ListResources() {
+		if resource.Audit {
+			return true
+		}
+	}
+	return false
+}
+
+// registerAuditCallback registers the gorm callback that captures the records created in the audited requests
+func registerAuditCallback(db *gorm.DB) error {
+	return db.Callback().Create().After("gorm:create").Register(auditCallbackName, auditCreateCallback)
+}
+
+// resourceAudit is the audit of one resource mutation request
+type resourceAudit struct {
+	resource *HTTPResource
+	action   string
+	model    interface{}
+	recordID string
+	before   map[string]interface{}
+	after    map[string]interface{}
+	created  bool
+}
+
+// startAudit loads the record before one Update or Delete, the creates capture the record with the request database.
+// Returns nil in resources without the Audit option
+func (r *HTTPResource) startAudit(c echo.Context, action string) *resourceAudit {
+	if !r.Audit {
+		return nil
+	}
+
+	a := &resourceAudit{resource: r, action: action, recordID: c.Param("id")}
+	if r.Model != "" {
+		a.model = handlerApp(c).GetModel(r.Model)
+	}
+	if a.model == nil {
+		return a
+	}
+
+	if action == AuditCreate {
+		ctx := context.WithValue(c.Request().Context(), auditContextKey{}, a)
+		c.SetRequest(c.Request().WithContext(ctx))
+		c.Set(requestDBKey, GetTX(c).WithContext(ctx))
+		return a
+	}
+
+	before, err := a.load(c)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"resource": r.Name,
+			"id":       a.recordID,
+			"error":    err.Error(),
+		}).Warn("catu.HTTPResource error on load the audit record")
+	}
+	a.before = before
+
+	return a
+}
+
+// finish saves the audit log of one successful mutation. The errors are logged and don't change the response
+func (a *resourceAudit) finish(c echo.Context, err error) {
+	if a == nil || err != nil || c.Response().Status >= http.StatusBadRequest {
+		return
+	}
+
+	if a.action == AuditUpdate && a.model != nil {
+		after, err := a.load(c)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"resource": a.resource.Name,
+				"id":       a.recordID,
+				"error":    err.Error(),
+			}).Error("catu.HTTPResource error on load the audit record")
+		}
+		a.after = after
+	}
+
+	changes, err := json.Marshal(auditDiff(a.before, a.after))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"resource": a.resource.Name,
+			"id":       a.recordID,
+			"error":    err.Error(),
+		}).Error("catu.HTTPResource error on encode the audit changes")
+		return
+	}
+
+	log := &AuditLog{
+		Resource:  a.resource.Name,
+		RecordID:  a.recordID,
+		Action:    a.action,
+		UserID:    progressUserID(c),
+		IP:        c.RealIP(),
+		Changes:   string(changes),
+		CreatedAt: time.Now(),
+	}
+
+	app := handlerApp(c)
+	ctx := c.Request().Context()
+	err = SideEffect(ctx, "audit", a.action+" "+a.resource.Name+" "+a.recordID, log, func() error {
+		if err := saveAuditLog(app, GetTX(c), log); err != nil {
+			return err
+		}
+		return app.TriggerSync(ctx, EventAuditRecorded, event.M{"app": app, "log": log})
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"resource": a.resource.Name,
+			"id":       a.recordID,
+			"action":   a.action,
+			"userId":   log.UserID,
+			"error":    fmt.Sprintf("%+v", err),
+		}).Error("catu.HTTPResource error on record the audit log, the mutation was not audited")
+	}
+}
+
+// saveAuditLog saves the log in the request transaction, with one savepoint to keep the transaction on errors
+func saveAuditLog(app App, db *gorm.DB, log *AuditLog) error {
+	if !isTransaction(db) {
+		db = app.GetDB()
+		return errors.Wrap(db.Create(log).Error, "catu.saveAuditLog error on create log")
+	}
+
+	db = db.Session(&gorm.Session{NewDB: true})
+	if err := db.SavePoint("catu_audit").Error; err != nil {
+		return errors.Wrap(err, "catu.saveAuditLog error on create savepoint")
+	}
+	if err := db.Create(log).Error; err != nil {
+		db.RollbackTo("catu_audit")
+		return errors.Wrap(err, "catu.saveAuditLog error on create log")
+	}
+	return nil
+}
+
+// load returns the audited columns of the record with the route id
+func (a *resourceAudit) load(c echo.Context) (map[string]interface{}, error) {
+	db := DBWithContext(c)
+
+	record, s, err := findModelRecord(db, a.model, a.recordID)
+	if err != nil || record == nil {
+		return nil, err
+	}
+
+	return auditFields(db.Statement.Context, s, reflect.ValueOf(record).Elem()), nil
+}
+
+// findModelRecord returns one new record of the model type with the primary key id, nil if it doesn't exist
+func findModelRecord(db *gorm.DB, model interface{}, id string) (interface{}, *schema.Schema, error) {
+	db = db.Session(&gorm.Session{NewDB: true})
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, nil, err
+	}
+	s := stmt.Schema
+	if s.PrioritizedPrimaryField == nil {
+		return nil, s, errors.New("model without primary key")
+	}
+
+	record := reflect.New(s.ModelType).Interface()
+	result := db.Where(s.PrioritizedPrimaryField.DBName+" = ?", id).Limit(1).Find(record)
+	if result.Error != nil {
+		return nil, s, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, s, nil
+	}
+
+	return record, s, nil
+}
+
+// auditCreateCallback captures the first created record with the audited model
+func auditCreateCallback(db *gorm.DB) {
+	stmt := db.Statement
+	a, ok := stmt.Context.Value(auditContextKey{}).(*resourceAudit)
+	if !ok || a.created || db.Error != nil || stmt.Schema == nil || stmt.ReflectValue.Kind() != reflect.Struct {
+		return
+	}
+
+	modelType := reflect.Indirect(reflect.ValueOf(a.model)).Type()
+	if stmt.Schema.ModelType != modelType {
+		return
+	}
+
+	a.created = true
+	a.after = auditFields(stmt.Context, stmt.Schema, stmt.ReflectValue)
+	if pk := stmt.Schema.PrioritizedPrimaryField; pk != nil {
+		if v, zero := pk.ValueOf(stmt.Context, stmt.ReflectValue); !zero {
+			a.recordID = fmt.Sprint(v)
+		}
+	}
+}
+
+// auditFields returns the record column values, without the fields with the audit:"-" tag, ex: password hashes
+func auditFields(ctx context.Context, s *schema.Schema, record reflect.Value) map[string]interface{} {
+	fields := map[string]interface{}{}
+	for _, f := range s.Fields {
+		if f.DBName == "" || f.Tag.Get("audit") == "-" {
+			continue
+		}
+		fields[f.DBName] = f.ReflectValueOf(ctx, record).Interface()
+	}
+	return fields
+}
+
+// auditDiff returns the changed columns, all columns of the created and deleted records
+func auditDiff(before, after map[string]interface{}) map[string]AuditChange {
+	changes := map[string]AuditChange{}
+
+	for name, from := range before {
+		to, ok := after[name]
+		if !ok {
+			changes[name] = AuditChange{From: from}
+			continue
+		}
+
+		fromJSON, _ := json.Marshal(from)
+		toJSON, _ := json.Marshal(to)
+		if string(fromJSON) != string(toJSON) {
+			changes[name] = AuditChange{From: from, To: to}
+		}
+	}
+
+	for name, to := range after {
+		if _, ok := before[name]; !ok {
+			changes[name] = AuditChange{To: to}
+		}
+	}
+
+	return changes
+}
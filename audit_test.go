@@ -0,0 +1,154 @@
+package catu
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type auditTestUser struct {
+	ID           uint64 `gorm:"primaryKey" json:"id"`
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	PasswordHash string `json:"-" audit:"-"`
+}
+
+type auditTestController struct {
+	idCodecTestController
+}
+
+func (ctl *auditTestController) Create(c echo.Context) error {
+	user := auditTestUser{}
+	if err := c.Bind(&user); err != nil {
+		return err
+	}
+	user.PasswordHash = "hash-1"
+
+	if err := c.(*RequestContext).GetDB().Create(&user).Error; err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, user)
+}
+
+func (ctl *auditTestController) Update(c echo.Context) error {
+	body := auditTestUser{}
+	if err := c.Bind(&body); err != nil {
+		return err
+	}
+
+	err := c.(*RequestContext).GetDB().Model(&auditTestUser{}).Where("id = ?", c.Param("id")).
+		Updates(map[string]interface{}{"name": body.Name, "password_hash": "hash-2"}).Error
+	if err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (ctl *auditTestController) Delete(c echo.Context) error {
+	if err := c.(*RequestContext).GetDB().Delete(&auditTestUser{}, c.Param("id")).Error; err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func TestAudit(t *testing.T) {
+	app := newTestApp(t)
+	app.GetEvents().On("bindMiddlewares", event.ListenerFunc(func(e event.Event) error {
+		app.GetRouter().Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				if id := c.Request().Header.Get("X-Test-User"); id != "" {
+					c.(*RequestContext).SetAuthenticatedUser(&testUser{ID: id})
+				}
+				return next(c)
+			}
+		})
+		return nil
+	}), event.Low)
+
+	app.SetModel("user", &auditTestUser{})
+	app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+		api := app.GetRouterGroup("api")
+		if err := app.SetResourceWithOptions("users", &auditTestController{}, api.Group("/users"), ResourceOptions{Audit: true}); err != nil {
+			return err
+		}
+		return app.SetResourceModel("users", "user")
+	}))
+
+	t.Setenv("AUTO_MIGRATE", "true")
+	assert.Nil(t, app.Bootstrap())
+	assert.Nil(t, app.Migrate())
+
+	recorded := []*AuditLog{}
+	app.On(EventAuditRecorded, event.Normal, func(e *AppEvent) error {
+		recorded = append(recorded, e.Get("log").(*AuditLog))
+		return nil
+	})
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("X-Test-User", "42")
+		req.Header.Set(echo.HeaderXRealIP, "10.0.0.5")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Should record the changed fields of one update without the excluded fields", func(t *testing.T) {
+		rec := request(http.MethodPost, "/api/users", `{"name":"Ana","email":"ana@example.com"}`)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		rec = request(http.MethodPatch, "/api/users/1", `{"name":"Ana Maria"}`)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		logs, err := app.QueryAuditLogs(AuditLogFilter{Resource: "users", RecordID: "1"})
+		assert.Nil(t, err)
+		if !assert.Len(t, logs, 2) {
+			return
+		}
+
+		update := logs[0]
+		assert.Equal(t, AuditUpdate, update.Action)
+		assert.Equal(t, "42", update.UserID)
+		assert.Equal(t, "10.0.0.5", update.IP)
+
+		changes, err := update.GetChanges()
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]AuditChange{"name": {From: "Ana", To: "Ana Maria"}}, changes)
+
+		create := logs[1]
+		assert.Equal(t, AuditCreate, create.Action)
+		changes, err = create.GetChanges()
+		assert.Nil(t, err)
+		assert.Equal(t, AuditChange{From: nil, To: "ana@example.com"}, changes["email"])
+		assert.NotContains(t, changes, "password_hash")
+		assert.NotContains(t, create.Changes, "hash-1")
+
+		assert.Len(t, recorded, 2)
+	})
+
+	t.Run("Should record the deleted record", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, "/api/users/1", "").Code)
+
+		logs, err := app.QueryAuditLogs(AuditLogFilter{Action: AuditDelete})
+		assert.Nil(t, err)
+		if assert.Len(t, logs, 1) {
+			changes, err := logs[0].GetChanges()
+			assert.Nil(t, err)
+			assert.Equal(t, AuditChange{From: "Ana Maria", To: nil}, changes["name"])
+		}
+	})
+
+	t.Run("Should respond the mutation when the audit log can't be saved", func(t *testing.T) {
+		assert.Nil(t, app.GetDB().Migrator().DropTable(&AuditLog{}))
+
+		rec := request(http.MethodPost, "/api/users", `{"name":"Bia"}`)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Bia")
+	})
+}
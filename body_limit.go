@@ -0,0 +1,132 @@
+package catu
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/bytes"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// bodyLimits has the global request body limit and the router group overrides, in bytes
+type bodyLimits struct {
+	mu     sync.RWMutex
+	global int64
+	// group path prefix limits
+	groups map[string]int64
+}
+
+// parseBodyLimit parses one size like "2M", "512K" or "1048576", 0 is no limit
+func parseBodyLimit(limit string) (int64, error) {
+	n, err := bytes.Parse(strings.TrimSpace(limit))
+	if err != nil {
+		return 0, errors.Wrap(err, "catu invalid body limit "+limit)
+	}
+	return n, nil
+}
+
+// SetGroupBodyLimit sets the request body limit of one router group, ex:
+//
+//	app.SetRouterGroup("uploads", "/uploads")
+//	app.SetGroupBodyLimit("uploads", "50M")
+//
+// The limit of the group with the longest path is used, "0" disables the limit in the group
+func (r *AppStruct) SetGroupBodyLimit(groupName, limit string) error {
+	path, ok := r.routerGroupPaths[groupName]
+	if !ok {
+		return errors.New("catu.App.SetGroupBodyLimit router group not found: " + groupName)
+	}
+
+	n, err := parseBodyLimit(limit)
+	if err != nil {
+		return err
+	}
+
+	r.bodyLimits.mu.Lock()
+	r.bodyLimits.groups[strings.TrimSuffix(path, "/")] = n
+	r.bodyLimits.mu.Unlock()
+
+	return nil
+}
+
+// limit returns the body limit of one request path
+func (l *bodyLimits) limit(path string) int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	limit, matched := l.global, -1
+	for prefix, n := range l.groups {
+		if len(prefix) <= matched {
+			continue
+		}
+		if prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/") {
+			limit, matched = n, len(prefix)
+		}
+	}
+	return limit
+}
+
+// bindBodyLimit limits the request bodies to BODY_LIMIT (default "2M", "0" disables it).
+// Larger bodies respond 413, with one unknown Content-Length the body read fails after the limit
+func (r *AppStruct) bindBodyLimit() {
+	v := r.Configuration.GetF("BODY_LIMIT", "2M")
+
+	global, err := parseBodyLimit(v)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"BODY_LIMIT": v,
+		}).Warn("catu.App invalid BODY_LIMIT, using the default 2M")
+		global = 2 * bytes.MB
+	}
+
+	r.bodyLimits.mu.Lock()
+	r.bodyLimits.global = global
+	r.bodyLimits.mu.Unlock()
+
+	// echo Group.Use overrides the group root route, so the middleware is global and selects the limit by path
+	r.router.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			limit := r.bodyLimits.limit(req.URL.Path)
+			if limit <= 0 || req.Body == nil || req.Body == http.NoBody {
+				return next(c)
+			}
+
+			if req.ContentLength > limit {
+				return echo.ErrStatusRequestEntityTooLarge
+			}
+
+			req.Body = &limitedBody{ReadCloser: req.Body, remaining: limit}
+			return next(c)
+		}
+	})
+}
+
+// limitedBody returns echo.ErrStatusRequestEntityTooLarge after the limit, for bodies without Content-Length
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, echo.ErrStatusRequestEntityTooLarge
+	}
+
+	// reads one extra byte to detect bodies larger than the limit
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), echo.ErrStatusRequestEntityTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,128 @@
+package catu
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyLimit(t *testing.T) {
+	newBodyLimitApp := func(t *testing.T) App {
+		dir := t.TempDir()
+		assert.Nil(t, os.MkdirAll(filepath.Join(dir, "site", "layouts"), 0755))
+		templates := map[string]string{
+			"html.html":            "{{ .Ctx.Content }}",
+			"layouts/default.html": "{{ .Ctx.Content }}",
+			"413.html":             "<h1>{{ .Ctx.Title }}</h1>",
+		}
+		for name, content := range templates {
+			assert.Nil(t, os.WriteFile(filepath.Join(dir, "site", name), []byte(content), 0644))
+		}
+
+		t.Setenv("BODY_LIMIT", "1K")
+		app := newTestApp(t)
+		t.Setenv("TEMPLATE_DISABLE", "false")
+		t.Setenv("TEMPLATE_FOLDER", dir)
+		app.SetRouterGroup("uploads", "/uploads")
+		assert.Nil(t, app.Bootstrap())
+
+		handler := func(c echo.Context) error {
+			body := map[string]interface{}{}
+			if err := c.Bind(&body); err != nil {
+				return err
+			}
+			return c.JSON(http.StatusOK, body)
+		}
+		app.GetRouter().POST("/api/posts", handler)
+		app.GetRouter().POST("/uploads/files", handler)
+		return app
+	}
+
+	request := func(app App, path, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		req.Header.Set(echo.HeaderXRequestID, "body-limit-test")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	large := `{"text":"` + strings.Repeat("a", 2048) + `"}`
+
+	t.Run("Should accept the bodies in the limit", func(t *testing.T) {
+		app := newBodyLimitApp(t)
+		rec := request(app, "/api/posts", echo.MIMEApplicationJSON, `{"text":"small"}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"text":"small"}`, rec.Body.String())
+	})
+
+	t.Run("Should respond 413 JSON for large bodies", func(t *testing.T) {
+		app := newBodyLimitApp(t)
+		rec := request(app, "/api/posts", echo.MIMEApplicationJSON, large)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.JSONEq(t, `{"code":413,"message":"Request Entity Too Large","requestId":"body-limit-test"}`, rec.Body.String())
+	})
+
+	t.Run("Should respond 413 HTML for large bodies", func(t *testing.T) {
+		app := newBodyLimitApp(t)
+		rec := request(app, "/api/posts", echo.MIMETextHTML, large)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Equal(t, "<h1>Request Entity Too Large</h1>", rec.Body.String())
+	})
+
+	t.Run("Should respond 413 for large bodies without Content-Length", func(t *testing.T) {
+		app := newBodyLimitApp(t)
+		req := httptest.NewRequest(http.MethodPost, "/api/posts", io.NopCloser(strings.NewReader(large)))
+		req.ContentLength = -1
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderXRequestID, "body-limit-test")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.JSONEq(t, `{"code":413,"message":"Request Entity Too Large","requestId":"body-limit-test"}`, rec.Body.String())
+	})
+
+	t.Run("Should use the router group limit", func(t *testing.T) {
+		app := newBodyLimitApp(t)
+		assert.Nil(t, app.SetGroupBodyLimit("uploads", "4K"))
+		assert.NotNil(t, app.SetGroupBodyLimit("unknown", "4K"))
+		assert.NotNil(t, app.SetGroupBodyLimit("uploads", "4 apples"))
+
+		rec := request(app, "/uploads/files", echo.MIMEApplicationJSON, large)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		rec = request(app, "/uploads/files", echo.MIMEApplicationJSON, `{"text":"`+strings.Repeat("a", 5000)+`"}`)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		// other groups keep the BODY_LIMIT
+		rec = request(app, "/api/posts", echo.MIMEApplicationJSON, large)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+}
+
+func TestNewHTTPServer(t *testing.T) {
+	t.Run("Should set the server timeouts", func(t *testing.T) {
+		app := newTestApp(t).(*AppStruct)
+		server := app.newHTTPServer(nil)
+		assert.Equal(t, "30s", server.ReadTimeout.String())
+		assert.Equal(t, "0s", server.WriteTimeout.String())
+		assert.Equal(t, "2m0s", server.IdleTimeout.String())
+		assert.Equal(t, http.DefaultMaxHeaderBytes, server.MaxHeaderBytes)
+
+		t.Setenv("HTTP_READ_TIMEOUT", "5s")
+		t.Setenv("HTTP_WRITE_TIMEOUT", "1m")
+		t.Setenv("HTTP_IDLE_TIMEOUT", "invalid")
+		t.Setenv("HTTP_MAX_HEADER_BYTES", "4096")
+		server = app.newHTTPServer(nil)
+		assert.Equal(t, "5s", server.ReadTimeout.String())
+		assert.Equal(t, "1m0s", server.WriteTimeout.String())
+		assert.Equal(t, "2m0s", server.IdleTimeout.String())
+		assert.Equal(t, 4096, server.MaxHeaderBytes)
+	})
+}
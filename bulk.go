@@ -0,0 +1,412 @@
+package catu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// BulkResult is the result of one bulk item, the Status is the status of one single item request
+type BulkResult struct {
+	Index  int    `json:"index"`
+	Status int    `json:"status"`
+	ID     string `json:"id,omitempty"`
+	// Response body of one successful item, ex: the created record
+	Data    json.RawMessage         `json:"data,omitempty"`
+	Message string                  `json:"message,omitempty"`
+	Errors  []*ValidationFieldError `json:"errors,omitempty"`
+}
+
+func (r *BulkResult) OK() bool {
+	return r.Status >= 200 && r.Status < 300
+}
+
+// BulkResponse is the bulk routes response body
+type BulkResponse struct {
+	Atomic  bool          `json:"atomic"`
+	Results []*BulkResult `json:"results"`
+}
+
+// bulkEffectsKey keeps the bulkEffects of one bulk or one bulk item
+const bulkEffectsKey = "catu.bulkEffects"
+
+// bulkEffects keeps the resource events and cache invalidations of the bulk items, see deferBulkEffect.
+// They run after the bulk transaction, so the rolled back items don't run them
+type bulkEffects struct {
+	effects []func(ctx context.Context)
+}
+
+// deferBulkEffect adds fn to the bulk effects of one bulk request. Returns false outside bulks, then the caller runs it
+func deferBulkEffect(c echo.Context, fn func(ctx context.Context)) bool {
+	e, ok := c.Get(bulkEffectsKey).(*bulkEffects)
+	if !ok {
+		return false
+	}
+
+	e.effects = append(e.effects, fn)
+	return true
+}
+
+// BulkID is one record ID sent as one JSON string or number
+type BulkID string
+
+func (id *BulkID) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*id = BulkID(s)
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return errors.New("invalid bulk id: " + string(b))
+	}
+	*id = BulkID(n.String())
+	return nil
+}
+
+// BulkUpdateItem is one PATCH /bulk item
+type BulkUpdateItem struct {
+	ID      BulkID          `json:"id"`
+	Changes json.RawMessage `json:"changes"`
+}
+
+// BulkCreateController is one HTTPController with its own bulk create, optional in Bulk resources.
+// It runs in the bulk transaction, see RequestContext.GetDB, and returns one result per item.
+// Controllers without it run Create once per item
+type BulkCreateController interface {
+	BulkCreate(c echo.Context, items []json.RawMessage) ([]*BulkResult, error)
+}
+
+// BulkUpdateController is the optional bulk update, see BulkCreateController
+type BulkUpdateController interface {
+	BulkUpdate(c echo.Context, items []BulkUpdateItem) ([]*BulkResult, error)
+}
+
+// BulkDeleteController is the optional bulk delete, see BulkCreateController
+type BulkDeleteController interface {
+	BulkDelete(c echo.Context, ids []BulkID) ([]*BulkResult, error)
+}
+
+// BulkCreate creates one JSON array of records with the POST /bulk route
+func (r *HTTPResource) BulkCreate(c echo.Context) error {
+	items := []json.RawMessage{}
+	if err := r.bindBulk(c, &items); err != nil {
+		return err
+	}
+
+	return r.runBulk(c, func(ctx *RequestContext, tx *gorm.DB) ([]*BulkResult, error) {
+		if ctl, ok := r.GetController().(BulkCreateController); ok {
+			return ctl.BulkCreate(ctx, items)
+		}
+
+		results := make([]*BulkResult, len(items))
+		for i, item := range items {
+			result, err := r.runBulkItem(ctx, tx, i, "", item, r.Create)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = result
+		}
+		return results, nil
+	})
+}
+
+// BulkUpdate updates one JSON array of {"id", "changes"} with the PATCH /bulk route
+func (r *HTTPResource) BulkUpdate(c echo.Context) error {
+	items := []BulkUpdateItem{}
+	if err := r.bindBulk(c, &items); err != nil {
+		return err
+	}
+
+	return r.runBulk(c, func(ctx *RequestContext, tx *gorm.DB) ([]*BulkResult, error) {
+		if ctl, ok := r.GetController().(BulkUpdateController); ok {
+			return ctl.BulkUpdate(ctx, items)
+		}
+
+		results := make([]*BulkResult, len(items))
+		for i, item := range items {
+			result, err := r.runBulkItem(ctx, tx, i, string(item.ID), item.Changes, r.Update)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = result
+		}
+		return results, nil
+	})
+}
+
+// BulkDelete deletes one JSON array of ids with the DELETE /bulk route
+func (r *HTTPResource) BulkDelete(c echo.Context) error {
+	ids := []BulkID{}
+	if err := r.bindBulk(c, &ids); err != nil {
+		return err
+	}
+
+	return r.runBulk(c, func(ctx *RequestContext, tx *gorm.DB) ([]*BulkResult, error) {
+		if ctl, ok := r.GetController().(BulkDeleteController); ok {
+			return ctl.BulkDelete(ctx, ids)
+		}
+
+		results := make([]*BulkResult, len(ids))
+		for i, id := range ids {
+			result, err := r.runBulkItem(ctx, tx, i, string(id), nil, r.Delete)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = result
+		}
+		return results, nil
+	})
+}
+
+// bindBulk decodes the JSON array body, with max BulkMaxSize items
+func (r *HTTPResource) bindBulk(c echo.Context, items interface{}) error {
+	if err := json.NewDecoder(c.Request().Body).Decode(items); err != nil {
+		return &HTTPError{Code: http.StatusBadRequest, Message: "Invalid bulk body, one JSON array is required", Internal: err}
+	}
+
+	if size := reflect.ValueOf(items).Elem().Len(); size > r.BulkMaxSize {
+		return &HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: "Bulk with " + strconv.Itoa(size) + " items, the max is " + strconv.Itoa(r.BulkMaxSize),
+		}
+	}
+
+	return nil
+}
+
+// runBulk runs fn in one database transaction. The atomic bulks, default, roll back all items if one item fails and
+// the successful items respond 424. With ?atomic=false only the failing items are rolled back.
+// The response is 200 if all items succeed or 207 with the failures.
+// The resource events and cache invalidations of the saved items run after the transaction, see deferBulkEffect
+func (r *HTTPResource) runBulk(c echo.Context, fn func(ctx *RequestContext, tx *gorm.DB) ([]*BulkResult, error)) error {
+	ctx, ok := c.(*RequestContext)
+	if !ok {
+		return errors.New("catu.HTTPResource bulk routes require the RequestContext")
+	}
+
+	atomic := c.QueryParam("atomic") != "false"
+	req := c.Request()
+	previousDB := c.Get(requestDBKey)
+
+	var results []*BulkResult
+	failed := false
+	effects := &bulkEffects{}
+
+	err := runTransaction(req.Context(), GetTX(c), 1, func(tx *gorm.DB) error {
+		c.Set(requestDBKey, tx)
+		c.Set(bulkEffectsKey, effects)
+		c.SetRequest(req.WithContext(tx.Statement.Context))
+		defer func() {
+			c.Set(requestDBKey, previousDB)
+			c.Set(bulkEffectsKey, nil)
+			c.SetRequest(req)
+		}()
+
+		// one savepoint rolls back the atomic bulks in the request transactions too, see TransactionMiddleware
+		if err := tx.SavePoint("catu_bulk").Error; err != nil {
+			return errors.Wrap(err, "catu.HTTPResource error on create bulk savepoint")
+		}
+
+		var err error
+		results, err = fn(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, result := range results {
+			if !result.OK() {
+				failed = true
+			}
+		}
+
+		if failed && atomic {
+			return errors.Wrap(tx.RollbackTo("catu_bulk").Error, "catu.HTTPResource error on roll back bulk")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if failed && atomic {
+		for _, result := range results {
+			if result.OK() {
+				result.Status = http.StatusFailedDependency
+				result.Message = http.StatusText(http.StatusFailedDependency)
+				result.Data = nil
+			}
+		}
+	} else {
+		for _, fn := range effects.effects {
+			fn(req.Context())
+		}
+	}
+
+	status := http.StatusOK
+	if failed {
+		status = http.StatusMultiStatus
+	}
+
+	return c.JSON(status, &BulkResponse{Atomic: atomic, Results: results})
+}
+
+// runBulkItem runs one single item handler, ex: HTTPResource.Create, with one item request and the bulk transaction.
+// The items are validated with the resource model before the handler and one failing item is rolled back.
+// Savepoint errors return one error, they fail the bulk because the item changes may be kept
+func (r *HTTPResource) runBulkItem(ctx *RequestContext, tx *gorm.DB, index int, id string, body []byte, handler echo.HandlerFunc) (*BulkResult, error) {
+	result := &BulkResult{Index: index, ID: id}
+
+	parent := ctx.Request()
+	req, err := http.NewRequestWithContext(parent.Context(), parent.Method, parent.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		result.Status = http.StatusInternalServerError
+		result.Message = err.Error()
+		return result, nil
+	}
+	req.Header = parent.Header.Clone()
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Del(echo.HeaderContentLength)
+	req.RemoteAddr = parent.RemoteAddr
+
+	w := &bulkItemWriter{header: http.Header{}}
+	ec := ctx.Echo().NewContext(req, w)
+	ec.SetPath(ctx.Path())
+	ec.Set(requestDBKey, tx)
+	ec.Set(requestIDKey, GetRequestID(ctx))
+	ec.Set(tenantKey, GetTenant(ctx))
+	ec.Set("responseContentType", echo.MIMEApplicationJSON)
+	if s := GetDryRun(ctx); s != nil {
+		ec.Set(dryRunKey, s)
+	}
+	effects := &bulkEffects{}
+	ec.Set(bulkEffectsKey, effects)
+
+	item := *ctx
+	item.EchoContext = ec
+
+	if err := tx.SavePoint("catu_bulk_item").Error; err != nil {
+		return nil, errors.Wrap(err, "catu.HTTPResource error on create bulk item savepoint")
+	}
+
+	err = r.decodeBulkItemID(&item, id)
+	if err == nil {
+		err = r.validateBulkItem(&item, tx, id, body)
+	}
+	if err == nil {
+		err = handler(&item)
+	}
+	if err != nil {
+		ctx.Echo().HTTPErrorHandler(err, &item)
+	}
+
+	result.Status = w.code
+	if result.Status == 0 {
+		result.Status = http.StatusOK
+	}
+
+	if !result.OK() {
+		if err := tx.RollbackTo("catu_bulk_item").Error; err != nil {
+			return nil, errors.Wrap(err, "catu.HTTPResource error on roll back bulk item")
+		}
+
+		resp := ErrorResponse{}
+		if json.Unmarshal(w.body.Bytes(), &resp) == nil {
+			result.Message = resp.Message
+			result.Errors = resp.Errors
+		}
+		return result, nil
+	}
+
+	if err := tx.Exec("RELEASE SAVEPOINT catu_bulk_item").Error; err != nil {
+		return nil, errors.Wrap(err, "catu.HTTPResource error on release bulk item savepoint")
+	}
+	if json.Valid(w.body.Bytes()) {
+		result.Data = json.RawMessage(w.body.Bytes())
+	}
+
+	// the saved item effects run with the bulk effects
+	if bulk, ok := ctx.Get(bulkEffectsKey).(*bulkEffects); ok {
+		bulk.effects = append(bulk.effects, effects.effects...)
+	}
+
+	return result, nil
+}
+
+// decodeBulkItemID sets the item id route param, decoded with the resource IDCodec. Invalid IDs respond 404
+func (r *HTTPResource) decodeBulkItemID(c echo.Context, id string) error {
+	if id == "" {
+		return nil
+	}
+
+	if r.IDCodec != nil {
+		decoded, err := r.IDCodec.Decode(id)
+		if err != nil {
+			return &HTTPError{Code: http.StatusNotFound, Message: "Not Found"}
+		}
+		id = strconv.FormatInt(decoded, 10)
+	}
+
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	return nil
+}
+
+// validateBulkItem validates one new record or the record with the update changes with the resource model,
+// see App.SetResourceModel. Resources without model are validated by the controllers
+func (r *HTTPResource) validateBulkItem(c echo.Context, tx *gorm.DB, id string, body []byte) error {
+	if r.Model == "" || body == nil {
+		return nil
+	}
+	model := handlerApp(c).GetModel(r.Model)
+	if model == nil {
+		return nil
+	}
+
+	var record interface{}
+	if id == "" {
+		record = reflect.New(reflect.Indirect(reflect.ValueOf(model)).Type()).Interface()
+	} else {
+		var err error
+		record, _, err = findModelRecord(tx, model, c.Param("id"))
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return &HTTPError{Code: http.StatusNotFound, Message: "Not Found"}
+		}
+	}
+
+	if err := json.Unmarshal(body, record); err != nil {
+		return &HTTPError{Code: http.StatusBadRequest, Message: "Invalid bulk item", Internal: err}
+	}
+
+	return c.Validate(record)
+}
+
+// bulkItemWriter keeps one bulk item response
+type bulkItemWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (w *bulkItemWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bulkItemWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+func (w *bulkItemWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
@@ -0,0 +1,213 @@
+package catu
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type bulkTestProduct struct {
+	ID    uint64 `gorm:"primaryKey" json:"id"`
+	Name  string `json:"name" validate:"required"`
+	Price int    `json:"price" validate:"min=0"`
+}
+
+type bulkTestController struct {
+	idCodecTestController
+}
+
+func (ctl *bulkTestController) Create(c echo.Context) error {
+	product := bulkTestProduct{}
+	if err := c.Bind(&product); err != nil {
+		return err
+	}
+	if err := c.(*RequestContext).GetDB().Create(&product).Error; err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, product)
+}
+
+func (ctl *bulkTestController) Update(c echo.Context) error {
+	db := c.(*RequestContext).GetDB()
+
+	product := bulkTestProduct{}
+	if err := db.First(&product, "id = ?", c.Param("id")).Error; err != nil {
+		return err
+	}
+	if err := c.Bind(&product); err != nil {
+		return err
+	}
+	if err := db.Save(&product).Error; err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, product)
+}
+
+func (ctl *bulkTestController) Delete(c echo.Context) error {
+	result := c.(*RequestContext).GetDB().Delete(&bulkTestProduct{}, "id = ?", c.Param("id"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return &HTTPError{Code: http.StatusNotFound, Message: http.StatusText(http.StatusNotFound)}
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func TestBulk(t *testing.T) {
+	newBulkApp := func(t *testing.T) App {
+		app := newTestApp(t)
+		app.SetModel("product", &bulkTestProduct{})
+		// authenticates the X-Test-Role header for the dry runs
+		app.GetEvents().On("bindMiddlewares", event.ListenerFunc(func(e event.Event) error {
+			app.GetRouter().Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					if role := c.Request().Header.Get("X-Test-Role"); role != "" {
+						c.(*RequestContext).SetAuthenticatedUserAndFillRoles(&testUser{ID: "1", Roles: []string{role}})
+					}
+					return next(c)
+				}
+			})
+			return nil
+		}), event.Low)
+		app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+			api := app.GetRouterGroup("api")
+			opts := ResourceOptions{Bulk: true, BulkMaxSize: 3}
+			if err := app.SetResourceWithOptions("products", &bulkTestController{}, api.Group("/products"), opts); err != nil {
+				return err
+			}
+			return app.SetResourceModel("products", "product")
+		}))
+
+		assert.Nil(t, app.Bootstrap())
+		assert.Nil(t, app.GetDB().AutoMigrate(&bulkTestProduct{}))
+		return app
+	}
+
+	request := func(app App, method, path, body string) (*httptest.ResponseRecorder, *BulkResponse) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		resp := &BulkResponse{}
+		json.Unmarshal(rec.Body.Bytes(), resp)
+		return rec, resp
+	}
+
+	created := func(app App) *int {
+		count := 0
+		app.GetEvents().On(EventResourceCreated, event.ListenerFunc(func(e event.Event) error {
+			count++
+			return nil
+		}))
+		return &count
+	}
+
+	statuses := func(resp *BulkResponse) []int {
+		list := []int{}
+		for _, result := range resp.Results {
+			list = append(list, result.Status)
+		}
+		return list
+	}
+
+	names := func(app App) []string {
+		list := []string{}
+		assert.Nil(t, app.GetDB().Model(&bulkTestProduct{}).Order("id").Pluck("name", &list).Error)
+		return list
+	}
+
+	t.Run("Should roll back all items of one atomic bulk with one invalid item", func(t *testing.T) {
+		app := newBulkApp(t)
+		events := created(app)
+
+		rec, resp := request(app, http.MethodPost, "/api/products/bulk", `[{"name":"pen","price":2},{"price":-1},{"name":"ink","price":5}]`)
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		assert.True(t, resp.Atomic)
+		assert.Equal(t, []int{http.StatusFailedDependency, http.StatusBadRequest, http.StatusFailedDependency}, statuses(resp))
+		if assert.Len(t, resp.Results, 3) {
+			assert.Equal(t, 1, resp.Results[1].Index)
+			fields := []string{}
+			for _, fe := range resp.Results[1].Errors {
+				fields = append(fields, fe.Field)
+			}
+			assert.ElementsMatch(t, []string{"name", "price"}, fields)
+		}
+		assert.Empty(t, names(app))
+		assert.Equal(t, 0, *events, "the rolled back items don't fire events")
+	})
+
+	t.Run("Should save the valid items of one non atomic bulk", func(t *testing.T) {
+		app := newBulkApp(t)
+		events := created(app)
+
+		rec, resp := request(app, http.MethodPost, "/api/products/bulk?atomic=false", `[{"name":"pen","price":2},{"price":-1},{"name":"ink","price":5}]`)
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		assert.False(t, resp.Atomic)
+		assert.Equal(t, 2, *events)
+		assert.Equal(t, []int{http.StatusCreated, http.StatusBadRequest, http.StatusCreated}, statuses(resp))
+		assert.JSONEq(t, `{"id":1,"name":"pen","price":2}`, string(resp.Results[0].Data))
+		assert.Equal(t, []string{"pen", "ink"}, names(app))
+
+		rec, resp = request(app, http.MethodPatch, "/api/products/bulk?atomic=false", `[{"id":1,"changes":{"name":"blue pen"}},{"id":"9","changes":{"name":"lost"}},{"id":2,"changes":{"price":-3}}]`)
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		assert.Equal(t, []int{http.StatusOK, http.StatusNotFound, http.StatusBadRequest}, statuses(resp))
+		assert.Equal(t, "9", resp.Results[1].ID)
+		assert.Equal(t, []string{"blue pen", "ink"}, names(app))
+
+		rec, resp = request(app, http.MethodDelete, "/api/products/bulk", `[1,"2"]`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []int{http.StatusNoContent, http.StatusNoContent}, statuses(resp))
+		assert.Empty(t, names(app))
+	})
+
+	t.Run("Should reject bulks with more than the max items", func(t *testing.T) {
+		app := newBulkApp(t)
+
+		rec, _ := request(app, http.MethodPost, "/api/products/bulk", `[{"name":"a"},{"name":"b"},{"name":"c"},{"name":"d"}]`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "the max is 3")
+
+		rec, _ = request(app, http.MethodPost, "/api/products/bulk", `{"name":"a"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Empty(t, names(app))
+	})
+
+	t.Run("Should roll back the dry run bulks", func(t *testing.T) {
+		t.Setenv("DRY_RUN_ENABLED", "true")
+		app := newBulkApp(t)
+		events := created(app)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/products/bulk?atomic=false", strings.NewReader(`[{"name":"pen","price":2},{"price":-1},{"name":"ink","price":5}]`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+		req.Header.Set(HeaderDryRun, "true")
+		req.Header.Set("X-Test-Role", "administrator")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		resp := &BulkResponse{}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), resp))
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		assert.Equal(t, []int{http.StatusCreated, http.StatusBadRequest, http.StatusCreated}, statuses(resp))
+		assert.Equal(t, "true", rec.Header().Get(HeaderDryRun))
+
+		summary := struct {
+			RowsAffected int64          `json:"rowsAffected"`
+			Effects      []DryRunEffect `json:"effects"`
+		}{}
+		assert.Nil(t, json.Unmarshal([]byte(rec.Header().Get(HeaderDryRunSummary)), &summary))
+		assert.Equal(t, int64(2), summary.RowsAffected)
+		assert.Len(t, summary.Effects, 2, "one recorded event per saved item")
+		assert.Equal(t, 0, *events)
+		assert.Empty(t, names(app))
+	})
+}
@@ -0,0 +1,269 @@
+// Package cache is one key value cache with stampede protection.
+//
+// Remember loads missing keys once per key, with stale-while-revalidate support: between the soft and the hard TTL
+// readers get the stale value and one background refresh runs, deduplicated across instances with one short lock.
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Entry is one cached value with the soft (stale) and hard expiration times
+type Entry struct {
+	Value         interface{}
+	SoftExpiresAt time.Time
+	HardExpiresAt time.Time
+}
+
+// Store saves the cache entries, ex: memory or redis
+type Store interface {
+	// Get returns false if the key doesn't exists
+	Get(key string) (*Entry, bool, error)
+	Set(key string, entry *Entry, ttl time.Duration) error
+	Delete(key string) error
+}
+
+// Locker is one distributed lock used to run only one background refresh per key across instances
+type Locker interface {
+	// TryLock returns false if the lock is already taken
+	TryLock(key string, ttl time.Duration) (bool, error)
+	Unlock(key string) error
+}
+
+// Options of one cached key
+type Options struct {
+	// The value is fresh until the SoftTTL and served stale until the HardTTL.
+	// Without SoftTTL stale values are never served
+	SoftTTL time.Duration
+	HardTTL time.Duration
+}
+
+// Stats are the cache counters since the cache creation
+type Stats struct {
+	Hits            int64
+	Misses          int64
+	StaleServes     int64
+	Refreshes       int64
+	RefreshFailures int64
+}
+
+type call struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+type Cache struct {
+	Store Store
+	// Optional distributed lock for background refreshes, default: the Store if it implements Locker
+	Locker Locker
+	// Lock TTL for background refreshes, default: 10s
+	LockTTL time.Duration
+	// Time source, replace it in tests
+	Clock func() time.Time
+
+	mu    sync.Mutex
+	calls map[string]*call
+	// running background refreshes
+	refreshes sync.WaitGroup
+
+	hits            int64
+	misses          int64
+	staleServes     int64
+	refreshCount    int64
+	refreshFailures int64
+}
+
+// New cache with the store, it also uses the store as Locker if supported
+func New(store Store) *Cache {
+	c := &Cache{
+		Store:   store,
+		LockTTL: 10 * time.Second,
+		Clock:   time.Now,
+		calls:   make(map[string]*call),
+	}
+
+	if l, ok := store.(Locker); ok {
+		c.Locker = l
+	}
+
+	return c
+}
+
+// Get one value, returns false for missing or hard expired keys
+func (c *Cache) Get(key string) (interface{}, bool) {
+	e, ok, err := c.Store.Get(key)
+	if err != nil || !ok || !c.Clock().Before(e.HardExpiresAt) {
+		return nil, false
+	}
+
+	return e.Value, true
+}
+
+// Set one value without stale period
+func (c *Cache) Set(key string, value interface{}, ttl time.Duration) error {
+	return c.set(key, value, &Options{HardTTL: ttl})
+}
+
+func (c *Cache) Delete(key string) error {
+	return c.Store.Delete(key)
+}
+
+// Remember returns the cached value or loads it with fn.
+// Concurrent calls for the same missing key wait for one fn call.
+// Stale values are returned immediately while one background refresh runs, errors in the refresh keep the stale value
+func (c *Cache) Remember(key string, opts *Options, fn func() (interface{}, error)) (interface{}, error) {
+	now := c.Clock()
+
+	e, ok, err := c.Store.Get(key)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"key":   key,
+			"error": fmt.Sprintf("%+v\n", err),
+		}).Warn("cache.Remember error on get key from store")
+	}
+
+	if ok && now.Before(e.HardExpiresAt) {
+		if now.Before(e.SoftExpiresAt) {
+			atomic.AddInt64(&c.hits, 1)
+			return e.Value, nil
+		}
+
+		atomic.AddInt64(&c.staleServes, 1)
+		c.refreshInBackground(key, opts, fn)
+		return e.Value, nil
+	}
+
+	atomic.AddInt64(&c.misses, 1)
+
+	return c.load(key, opts, fn)
+}
+
+// Prime loads the key with fn and stores it, use it to warm the cache before serving requests
+func (c *Cache) Prime(key string, opts *Options, fn func() (interface{}, error)) error {
+	_, err := c.load(key, opts, fn)
+	return err
+}
+
+// Wait for the running background refreshes
+func (c *Cache) Wait() {
+	c.refreshes.Wait()
+}
+
+func (c *Cache) Stats() Stats {
+	return Stats{
+		Hits:            atomic.LoadInt64(&c.hits),
+		Misses:          atomic.LoadInt64(&c.misses),
+		StaleServes:     atomic.LoadInt64(&c.staleServes),
+		Refreshes:       atomic.LoadInt64(&c.refreshCount),
+		RefreshFailures: atomic.LoadInt64(&c.refreshFailures),
+	}
+}
+
+// load runs fn once for concurrent calls with the same key and stores the result.
+// One fn panic returns one error to the waiting calls and is raised again in the caller
+func (c *Cache) load(key string, opts *Options, fn func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	if cl, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		cl.wg.Wait()
+		return cl.value, cl.err
+	}
+
+	cl := &call{}
+	cl.wg.Add(1)
+	c.calls[key] = cl
+	c.mu.Unlock()
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			cl.value, cl.err = nil, errors.Errorf("cache.load panic on load key %s: %v", key, rec)
+			c.release(key, cl)
+			panic(rec)
+		}
+	}()
+
+	atomic.AddInt64(&c.refreshCount, 1)
+
+	cl.value, cl.err = fn()
+	if cl.err == nil {
+		if err := c.set(key, cl.value, opts); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"key":   key,
+				"error": fmt.Sprintf("%+v\n", err),
+			}).Warn("cache.load error on set key in store")
+		}
+	}
+
+	c.release(key, cl)
+
+	return cl.value, cl.err
+}
+
+// release removes the running call and releases its waiting calls
+func (c *Cache) release(key string, cl *call) {
+	c.mu.Lock()
+	delete(c.calls, key)
+	c.mu.Unlock()
+	cl.wg.Done()
+}
+
+func (c *Cache) refreshInBackground(key string, opts *Options, fn func() (interface{}, error)) {
+	c.mu.Lock()
+	_, running := c.calls[key]
+	c.mu.Unlock()
+
+	if running {
+		return
+	}
+
+	if c.Locker != nil {
+		locked, err := c.Locker.TryLock("lock:"+key, c.LockTTL)
+		if err != nil || !locked {
+			return
+		}
+	}
+
+	c.refreshes.Add(1)
+	go func() {
+		defer c.refreshes.Done()
+
+		if c.Locker != nil {
+			defer c.Locker.Unlock("lock:" + key)
+		}
+
+		_, err := c.load(key, opts, fn)
+		if err != nil {
+			atomic.AddInt64(&c.refreshFailures, 1)
+			logrus.WithFields(logrus.Fields{
+				"key":   key,
+				"error": fmt.Sprintf("%+v\n", err),
+			}).Error("cache.refreshInBackground error on refresh, keeping the stale value")
+		}
+	}()
+}
+
+func (c *Cache) set(key string, value interface{}, opts *Options) error {
+	if opts == nil || opts.HardTTL <= 0 {
+		return errors.New("cache.set HardTTL is required")
+	}
+
+	soft := opts.SoftTTL
+	if soft <= 0 || soft > opts.HardTTL {
+		soft = opts.HardTTL
+	}
+
+	now := c.Clock()
+
+	return c.Store.Set(key, &Entry{
+		Value:         value,
+		SoftExpiresAt: now.Add(soft),
+		HardExpiresAt: now.Add(opts.HardTTL),
+	}, opts.HardTTL)
+}
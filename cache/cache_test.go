@@ -0,0 +1,275 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestCache() (*Cache, *MemoryStore, *testClock) {
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	store := NewMemoryStore()
+	store.Clock = clock.Now
+	c := New(store)
+	c.Clock = clock.Now
+	return c, store, clock
+}
+
+func TestRemember(t *testing.T) {
+	opts := &Options{SoftTTL: time.Minute, HardTTL: time.Hour}
+
+	t.Run("Should load missing keys and return fresh values", func(t *testing.T) {
+		c, _, clock := newTestCache()
+		calls := 0
+		fn := func() (interface{}, error) {
+			calls++
+			return calls, nil
+		}
+
+		v, err := c.Remember("k", opts, fn)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, v)
+
+		clock.Add(59 * time.Second)
+		v, err = c.Remember("k", opts, fn)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, v)
+		assert.Equal(t, 1, calls)
+
+		assert.Equal(t, Stats{Hits: 1, Misses: 1, Refreshes: 1}, c.Stats())
+	})
+
+	t.Run("Should call fn once for concurrent misses", func(t *testing.T) {
+		c, _, _ := newTestCache()
+		var calls int64
+		release := make(chan struct{})
+
+		fn := func() (interface{}, error) {
+			atomic.AddInt64(&calls, 1)
+			<-release
+			return "value", nil
+		}
+
+		wg := sync.WaitGroup{}
+		results := make(chan interface{}, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, _ := c.Remember("k", opts, fn)
+				results <- v
+			}()
+		}
+
+		// wait until the first call is running
+		for atomic.LoadInt64(&calls) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		close(results)
+
+		assert.Equal(t, int64(1), atomic.LoadInt64(&calls))
+		for v := range results {
+			assert.Equal(t, "value", v)
+		}
+	})
+
+	t.Run("Should release the concurrent misses if fn panics", func(t *testing.T) {
+		c, _, _ := newTestCache()
+		running := make(chan struct{})
+		release := make(chan struct{})
+
+		go func() {
+			defer func() { recover() }()
+			c.Remember("k", opts, func() (interface{}, error) {
+				close(running)
+				<-release
+				panic("boom")
+			})
+		}()
+		<-running
+
+		done := make(chan error)
+		go func() {
+			_, err := c.Remember("k", opts, func() (interface{}, error) { return "value", nil })
+			done <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+
+		select {
+		case err := <-done:
+			assert.EqualError(t, err, "cache.load panic on load key k: boom")
+		case <-time.After(time.Second):
+			t.Fatal("the waiting call is blocked")
+		}
+
+		assert.PanicsWithValue(t, "boom", func() {
+			c.Remember("k", opts, func() (interface{}, error) { panic("boom") })
+		})
+		v, err := c.Remember("k", opts, func() (interface{}, error) { return "value", nil })
+		assert.Nil(t, err)
+		assert.Equal(t, "value", v)
+	})
+
+	t.Run("Should serve stale values and refresh in background", func(t *testing.T) {
+		c, _, clock := newTestCache()
+		version := 0
+		fn := func() (interface{}, error) {
+			version++
+			return version, nil
+		}
+
+		assert.Nil(t, c.Prime("k", opts, fn))
+
+		clock.Add(time.Minute)
+		v, err := c.Remember("k", opts, fn)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, v)
+
+		c.Wait()
+
+		v, err = c.Remember("k", opts, fn)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, v)
+
+		assert.Equal(t, Stats{Hits: 1, StaleServes: 1, Refreshes: 2}, c.Stats())
+	})
+
+	t.Run("Should keep the stale value if the refresh fails", func(t *testing.T) {
+		c, _, clock := newTestCache()
+		fail := false
+		fn := func() (interface{}, error) {
+			if fail {
+				return nil, errors.New("database down")
+			}
+			return "ok", nil
+		}
+
+		_, err := c.Remember("k", opts, fn)
+		assert.Nil(t, err)
+
+		fail = true
+		clock.Add(2 * time.Minute)
+
+		for i := 0; i < 2; i++ {
+			v, err := c.Remember("k", opts, fn)
+			assert.Nil(t, err)
+			assert.Equal(t, "ok", v)
+			c.Wait()
+		}
+
+		assert.Equal(t, int64(2), c.Stats().StaleServes)
+		assert.Equal(t, int64(2), c.Stats().RefreshFailures)
+	})
+
+	t.Run("Should block and refresh after the hard TTL", func(t *testing.T) {
+		c, _, clock := newTestCache()
+		version := 0
+		fn := func() (interface{}, error) {
+			version++
+			return version, nil
+		}
+
+		_, err := c.Remember("k", opts, fn)
+		assert.Nil(t, err)
+
+		clock.Add(time.Hour)
+		v, err := c.Remember("k", opts, fn)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, v)
+		assert.Equal(t, int64(0), c.Stats().StaleServes)
+
+		fail := errors.New("fail")
+		clock.Add(time.Hour)
+		_, err = c.Remember("k", opts, func() (interface{}, error) { return nil, fail })
+		assert.Equal(t, fail, err)
+		_, ok := c.Get("k")
+		assert.False(t, ok)
+	})
+
+	t.Run("Should refresh only once across instances sharing the lock", func(t *testing.T) {
+		c1, store, clock := newTestCache()
+		c2 := New(store)
+		c2.Clock = clock.Now
+
+		var calls int64
+		release := make(chan struct{})
+		fn := func() (interface{}, error) {
+			if atomic.AddInt64(&calls, 1) > 1 {
+				<-release
+			}
+			return atomic.LoadInt64(&calls), nil
+		}
+
+		_, err := c1.Remember("k", opts, fn)
+		assert.Nil(t, err)
+		clock.Add(2 * time.Minute)
+
+		v, err := c1.Remember("k", opts, fn)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), v)
+
+		for atomic.LoadInt64(&calls) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+
+		// the other instance serves stale without a second refresh
+		v, err = c2.Remember("k", opts, fn)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), v)
+
+		close(release)
+		c1.Wait()
+		c2.Wait()
+
+		assert.Equal(t, int64(2), atomic.LoadInt64(&calls))
+		assert.Equal(t, int64(0), c2.Stats().Refreshes)
+
+		v, _ = c2.Remember("k", opts, fn)
+		assert.Equal(t, int64(2), v)
+	})
+
+	t.Run("Should not serve stale values without SoftTTL", func(t *testing.T) {
+		c, _, clock := newTestCache()
+		version := 0
+		fn := func() (interface{}, error) {
+			version++
+			return version, nil
+		}
+
+		assert.Nil(t, c.Set("k", "cached", time.Minute))
+		v, ok := c.Get("k")
+		assert.True(t, ok)
+		assert.Equal(t, "cached", v)
+
+		clock.Add(time.Minute)
+		v, err := c.Remember("k", &Options{HardTTL: time.Minute}, fn)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, v)
+		assert.Equal(t, int64(0), c.Stats().StaleServes)
+	})
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+type lruItem struct {
+	key string
+	memoryItem
+}
+
+// LRUStore is one in process Store with max entries, the least recently used entry is removed when the store is full
+type LRUStore struct {
+	// Time source, replace it in tests
+	Clock func() time.Time
+	// Max entries, 0 is unlimited
+	MaxEntries int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func NewLRUStore(maxEntries int) *LRUStore {
+	return &LRUStore{
+		Clock:      time.Now,
+		MaxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (s *LRUStore) Get(key string) (*Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	item := el.Value.(*lruItem)
+	if !s.Clock().Before(item.expiresAt) {
+		s.remove(el)
+		return nil, false, nil
+	}
+
+	s.ll.MoveToFront(el)
+	return item.entry, true, nil
+}
+
+func (s *LRUStore) Set(key string, entry *Entry, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item := &lruItem{key: key, memoryItem: memoryItem{entry: entry, expiresAt: s.Clock().Add(ttl)}}
+
+	if el, ok := s.items[key]; ok {
+		el.Value = item
+		s.ll.MoveToFront(el)
+		return nil
+	}
+
+	s.items[key] = s.ll.PushFront(item)
+
+	if s.MaxEntries > 0 && s.ll.Len() > s.MaxEntries {
+		s.remove(s.ll.Back())
+	}
+
+	return nil
+}
+
+func (s *LRUStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+// DeletePrefix removes all keys starting with prefix
+func (s *LRUStore) DeletePrefix(prefix string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, el := range s.items {
+		if strings.HasPrefix(key, prefix) {
+			s.remove(el)
+		}
+	}
+	return nil
+}
+
+// Len returns the entries count, with expired entries not removed yet
+func (s *LRUStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ll.Len()
+}
+
+func (s *LRUStore) remove(el *list.Element) {
+	s.ll.Remove(el)
+	delete(s.items, el.Value.(*lruItem).key)
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUStore(t *testing.T) {
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	store := NewLRUStore(2)
+	store.Clock = clock.Now
+
+	get := func(key string) interface{} {
+		e, ok, err := store.Get(key)
+		assert.Nil(t, err)
+		if !ok {
+			return nil
+		}
+		return e.Value
+	}
+
+	t.Run("Should remove the least recently used entry", func(t *testing.T) {
+		store.Set("a", &Entry{Value: 1}, time.Minute)
+		store.Set("b", &Entry{Value: 2}, time.Minute)
+		assert.Equal(t, 1, get("a"))
+
+		store.Set("c", &Entry{Value: 3}, time.Minute)
+		assert.Equal(t, 2, store.Len())
+		assert.Nil(t, get("b"))
+		assert.Equal(t, 1, get("a"))
+		assert.Equal(t, 3, get("c"))
+	})
+
+	t.Run("Should expire the entries", func(t *testing.T) {
+		clock.Add(time.Minute)
+		assert.Nil(t, get("a"))
+		assert.Equal(t, 1, store.Len())
+	})
+
+	t.Run("Should delete the keys with one prefix", func(t *testing.T) {
+		store := NewLRUStore(0)
+		store.Set("/api/articles?page=1", &Entry{Value: 1}, time.Minute)
+		store.Set("/api/articles/2?", &Entry{Value: 2}, time.Minute)
+		store.Set("/api/authors?", &Entry{Value: 3}, time.Minute)
+
+		assert.Nil(t, store.DeletePrefix("/api/articles"))
+		assert.Equal(t, 1, store.Len())
+		_, ok, _ := store.Get("/api/authors?")
+		assert.True(t, ok)
+	})
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryItem struct {
+	entry     *Entry
+	expiresAt time.Time
+}
+
+// MemoryStore is one in process Store and Locker, locks are only shared by caches using the same store
+type MemoryStore struct {
+	// Time source, replace it in tests
+	Clock func() time.Time
+
+	mu    sync.Mutex
+	items map[string]*memoryItem
+	locks map[string]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		Clock: time.Now,
+		items: make(map[string]*memoryItem),
+		locks: make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryStore) Get(key string) (*Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	if !s.Clock().Before(item.expiresAt) {
+		delete(s.items, key)
+		return nil, false, nil
+	}
+
+	return item.entry, true, nil
+}
+
+func (s *MemoryStore) Set(key string, entry *Entry, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[key] = &memoryItem{entry: entry, expiresAt: s.Clock().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.items, key)
+	return nil
+}
+
+func (s *MemoryStore) TryLock(key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Clock()
+	if expiresAt, ok := s.locks[key]; ok && now.Before(expiresAt) {
+		return false, nil
+	}
+
+	s.locks[key] = now.Add(ttl)
+	return true, nil
+}
+
+func (s *MemoryStore) Unlock(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.locks, key)
+	return nil
+}
+
+// DeletePrefix removes all keys starting with prefix
+func (s *MemoryStore) DeletePrefix(prefix string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.items {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.items, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package catu
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
+)
+
+// CommandFunc runs one CLI command with the arguments after the command name
+type CommandFunc func(args []string) error
+
+// Command is one CLI command registered with RegisterCommand
+type Command struct {
+	Name string
+	Help string
+	Run  CommandFunc
+}
+
+// builtinCommands are the RunCLI commands, the names are reserved
+var builtinCommands = []Command{
+	{Name: "serve", Help: "Bootstrap the app and start the HTTP server"},
+	{Name: "migrate", Help: "Run the migrations, without the schema drift check"},
+	{Name: "routes", Help: "Print the route table"},
+	{Name: "roles", Help: "Print the roles and permissions"},
+	{Name: "export:static", Help: "Render the static routes to one directory, default STATIC_EXPORT_DIR"},
+	{Name: "help", Help: "Print this help"},
+}
+
+// RegisterCommand adds one RunCLI command, ex: "jobs:work". Register the commands before RunCLI, in the app main or
+// in the plugin constructors. The command should Bootstrap the app if it needs it
+func (r *AppStruct) RegisterCommand(name, help string, fn func(args []string) error) {
+	for _, c := range builtinCommands {
+		if c.Name == name {
+			panic("catu.App.RegisterCommand reserved command name: " + name)
+		}
+	}
+
+	if _, ok := r.commands[name]; !ok {
+		r.commandNames = append(r.commandNames, name)
+	}
+	r.commands[name] = &Command{Name: name, Help: help, Run: fn}
+}
+
+// GetCommands returns the RegisterCommand commands in registration order
+func (r *AppStruct) GetCommands() []*Command {
+	commands := make([]*Command, 0, len(r.commandNames))
+	for _, name := range r.commandNames {
+		commands = append(commands, r.commands[name])
+	}
+
+	return commands
+}
+
+// RunCLI runs one command from the process arguments and returns the exit code, ex:
+//
+//	func main() {
+//		app := catu.Init(&catu.AppOptions{})
+//		os.Exit(catu.RunCLI(app, os.Args[1:]))
+//	}
+//
+// The --env-file and --port flags override the configuration
+func RunCLI(app App, args []string) int {
+	return RunCLIWithOutput(app, args, os.Stdout, os.Stderr)
+}
+
+// RunCLIWithOutput is RunCLI with the command output and the errors written to stdout and stderr
+func RunCLIWithOutput(app App, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("catu", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	envFile := fs.String("env-file", "", "Load one .env file overriding the environment variables")
+	port := fs.String("port", "", "HTTP server port, overrides PORT")
+	fs.Usage = func() { printCLIUsage(app, fs, stderr) }
+
+	if err := fs.Parse(args); err != nil {
+		return cliFlagErrorCode(err)
+	}
+	if fs.NArg() == 0 {
+		printCLIUsage(app, fs, stderr)
+		return 2
+	}
+
+	name := fs.Arg(0)
+	cmdArgs := fs.Args()[1:]
+
+	command := findCommand(app, name)
+	if command == nil {
+		fmt.Fprintf(stderr, "unknown command: %s\n\n", name)
+		printCLIUsage(app, fs, stderr)
+		return 2
+	}
+
+	// the built-in commands accept the flags after the command name
+	if command.Run == nil {
+		if err := fs.Parse(cmdArgs); err != nil {
+			return cliFlagErrorCode(err)
+		}
+		cmdArgs = fs.Args()
+	}
+
+	if *envFile != "" {
+		if err := godotenv.Overload(*envFile); err != nil {
+			fmt.Fprintf(stderr, "error on load env file %s: %s\n", *envFile, err)
+			return 1
+		}
+	}
+	if *port != "" {
+		os.Setenv("PORT", *port)
+	}
+
+	var err error
+	if command.Run != nil {
+		err = command.Run(cmdArgs)
+	} else {
+		err = runBuiltinCommand(app, name, cmdArgs, fs, stdout)
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %+v\n", name, err)
+		return 1
+	}
+
+	return 0
+}
+
+// cliFlagErrorCode is 0 for -h and --help, the usage is printed by the flag set
+func cliFlagErrorCode(err error) int {
+	if err == flag.ErrHelp {
+		return 0
+	}
+	return 2
+}
+
+// findCommand returns one built-in command, without Run, or one registered command
+func findCommand(app App, name string) *Command {
+	for i := range builtinCommands {
+		if builtinCommands[i].Name == name {
+			return &builtinCommands[i]
+		}
+	}
+
+	for _, c := range app.GetCommands() {
+		if c.Name == name {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// cliApp is the AppStruct CLI bootstrap, apps embedding the AppStruct implement it
+type cliApp interface {
+	bootstrapCLI() error
+	shutdown()
+}
+
+func runBuiltinCommand(app App, name string, args []string, fs *flag.FlagSet, stdout io.Writer) error {
+	if name == "help" {
+		printCLIUsage(app, fs, stdout)
+		return nil
+	}
+
+	if name == "serve" {
+		if err := app.Bootstrap(); err != nil {
+			return errors.Wrap(err, "catu.RunCLI error on bootstrap")
+		}
+		return app.StartHTTPServer()
+	}
+
+	// the one-shot commands run without the schema drift check and the background tasks, and shutdown the app
+	if a, ok := app.(cliApp); ok {
+		if err := a.bootstrapCLI(); err != nil {
+			return errors.Wrap(err, "catu.RunCLI error on bootstrap")
+		}
+		defer a.shutdown()
+	} else {
+		if err := app.Bootstrap(); err != nil {
+			return errors.Wrap(err, "catu.RunCLI error on bootstrap")
+		}
+		defer app.Close()
+	}
+
+	switch name {
+	case "migrate":
+		return app.Migrate()
+	case "routes":
+		printRoutes(app, stdout)
+	case "roles":
+		printRoles(app, stdout)
+	case "export:static":
+		dir := ""
+		if len(args) > 0 {
+			dir = args[0]
+		}
+
+		manifest, err := ExportStatic(app, dir)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%d pages exported\n", len(manifest.Pages))
+	}
+
+	return nil
+}
+
+// printRoutes prints the echo routes sorted by path and method
+func printRoutes(app App, w io.Writer) {
+	routes := app.GetRouter().Routes()
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATH\tHANDLER")
+	for _, route := range routes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", route.Method, route.Path, route.Name)
+	}
+	tw.Flush()
+}
+
+// printRoles prints the roles sorted by name with the sorted permissions
+func printRoles(app App, w io.Writer) {
+	roles := app.GetRoles()
+
+	names := make([]string, 0, len(roles))
+	for name := range roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROLE\tPARENTS\tPERMISSIONS")
+	for _, name := range names {
+		role := roles[name]
+		permissions := append([]string{}, role.Permissions...)
+		sort.Strings(permissions)
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", name, strings.Join(role.Parents, ","), strings.Join(permissions, ","))
+	}
+	tw.Flush()
+}
+
+func printCLIUsage(app App, fs *flag.FlagSet, w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s [flags] <command> [args]\n\nCommands:\n", os.Args[0])
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range builtinCommands {
+		fmt.Fprintf(tw, "  %s\t%s\n", c.Name, c.Help)
+	}
+	for _, c := range app.GetCommands() {
+		fmt.Fprintf(tw, "  %s\t%s\n", c.Name, c.Help)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w, "\nFlags:")
+	out := fs.Output()
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+	fs.SetOutput(out)
+}
@@ -0,0 +1,100 @@
+package catu
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-catupiry/catu/acl"
+	"github.com/gookit/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCLI(t *testing.T) {
+	run := func(app App, args ...string) (int, string, string) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		code := RunCLIWithOutput(app, args, stdout, stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	t.Run("Should print the route table", func(t *testing.T) {
+		app := newTestApp(t)
+		api := app.GetRouterGroup("api")
+		assert.Nil(t, app.SetResource("articles", &resourceTestController{name: "articles"}, api.Group("/articles")))
+
+		code, out, _ := run(app, "routes")
+		assert.Equal(t, 0, code)
+		assert.Regexp(t, `(?m)^METHOD\s+PATH\s+HANDLER$`, out)
+		assert.Regexp(t, `(?m)^GET\s+/api/articles/:id\s+\S+FindOne`, out)
+		assert.Regexp(t, `(?m)^GET\s+/health\s+`, out)
+	})
+
+	t.Run("Should print the roles", func(t *testing.T) {
+		app := newTestApp(t)
+		app.GetEvents().On("bootstrap", event.ListenerFunc(func(e event.Event) error {
+			return app.SetRole("editor", acl.Role{Name: "editor", Permissions: []string{"content.update", "content.create"}, Parents: []string{"authenticated"}})
+		}))
+
+		code, out, _ := run(app, "roles")
+		assert.Equal(t, 0, code)
+		assert.Regexp(t, `(?m)^editor\s+authenticated\s+content.create,content.update$`, out)
+	})
+
+	t.Run("Should run the migrations with one strict schema and shutdown", func(t *testing.T) {
+		t.Setenv("AUTO_MIGRATE", "true")
+		t.Setenv("STRICT_SCHEMA", "true")
+		app := newTestApp(t)
+		app.SetModel("post", &migrateTestPost{})
+
+		migrated := false
+		app.GetEvents().On("shutdown", event.ListenerFunc(func(e event.Event) error {
+			migrated = app.GetDB().Migrator().HasTable(&migrateTestPost{})
+			return nil
+		}))
+
+		code, _, errOut := run(app, "migrate")
+		assert.Equal(t, 0, code, errOut)
+		assert.True(t, migrated)
+		assert.Nil(t, app.GetDB(), "the shutdown closes the databases")
+	})
+
+	t.Run("Should run the registered commands with the flags", func(t *testing.T) {
+		app := newTestApp(t)
+		envFile := filepath.Join(t.TempDir(), "test.env")
+		assert.Nil(t, os.WriteFile(envFile, []byte("CLI_TEST_QUEUE=emails\n"), 0600))
+		t.Setenv("PORT", "8080")
+		t.Setenv("CLI_TEST_QUEUE", "default")
+
+		var received []string
+		app.RegisterCommand("jobs:work", "Run the job workers", func(args []string) error {
+			received = append(args, app.GetConfiguration().Get("CLI_TEST_QUEUE"), app.GetConfiguration().Get("PORT"))
+			return nil
+		})
+
+		code, _, _ := run(app, "--env-file", envFile, "--port", "3000", "jobs:work", "--concurrency", "2")
+		assert.Equal(t, 0, code)
+		assert.Equal(t, []string{"--concurrency", "2", "emails", "3000"}, received)
+
+		_, out, _ := run(app, "help")
+		assert.Regexp(t, `(?m)^  jobs:work\s+Run the job workers$`, out)
+
+		assert.Panics(t, func() { app.RegisterCommand("serve", "", nil) })
+	})
+
+	t.Run("Should print the usage and exit non-zero on unknown commands", func(t *testing.T) {
+		app := newTestApp(t)
+
+		code, out, errOut := run(app, "unknown")
+		assert.Equal(t, 2, code)
+		assert.Empty(t, out)
+		assert.Contains(t, errOut, "unknown command: unknown")
+		assert.Contains(t, errOut, "Commands:")
+		assert.Contains(t, errOut, "-env-file")
+
+		code, _, errOut = run(app)
+		assert.Equal(t, 2, code)
+		assert.Contains(t, errOut, "Usage:")
+	})
+}
@@ -0,0 +1,83 @@
+package catu
+
+import (
+	"net"
+	"strings"
+
+	"github.com/go-catupiry/catu/configuration"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+const clientIPKey = "catu.clientIP"
+
+// ClientIP returns the trusted client IP of the maintenance allowlist, the rate limits and the signed URLs.
+// Without TRUSTED_PROXIES it is the connection IP and the X-Forwarded-For and X-Real-IP headers are ignored, so clients
+// can't spoof one IP. TRUSTED_PROXIES is one list of proxy IPs and CIDRs, ex: "10.0.0.0/8", then the client IP is the
+// first X-Forwarded-For IP not sent by one trusted proxy. echo.Context.RealIP keeps the echo default extractor
+func ClientIP(c echo.Context) string {
+	if ip, ok := c.Get(clientIPKey).(string); ok {
+		return ip
+	}
+
+	return echo.ExtractIPDirect()(c.Request())
+}
+
+// clientIPMiddleware keeps the request ClientIP, it runs before the other Pre middlewares
+func clientIPMiddleware(cfg configuration.ConfigurationInterface) echo.MiddlewareFunc {
+	extractor := newClientIPExtractor(cfg)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(clientIPKey, extractor(c.Request()))
+			return next(c)
+		}
+	}
+}
+
+// newClientIPExtractor returns the ClientIP extractor with the TRUSTED_PROXIES
+func newClientIPExtractor(cfg configuration.ConfigurationInterface) echo.IPExtractor {
+	proxies := parseIPNets(splitConfigList(cfg.Get("TRUSTED_PROXIES")), "TRUSTED_PROXIES")
+	if len(proxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, proxy := range proxies {
+		options = append(options, echo.TrustIPRange(proxy))
+	}
+
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// parseIPNets parses one list of IPs and CIDRs, ex: "10.0.0.1" or "192.168.0.0/16". Invalid items are logged and skipped
+func parseIPNets(items []string, key string) []*net.IPNet {
+	list := []*net.IPNet{}
+
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if !strings.Contains(item, "/") {
+			if strings.Contains(item, ":") {
+				item += "/128"
+			} else {
+				item += "/32"
+			}
+		}
+
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"ip":    item,
+				"error": err.Error(),
+			}).Warn("catu.parseIPNets invalid " + key + " item")
+			continue
+		}
+		list = append(list, ipNet)
+	}
+
+	return list
+}
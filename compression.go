@@ -0,0 +1,323 @@
+package catu
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// CompressionEncoder returns one writer compressing to w, ex: one brotli writer registered with App.SetCompressionEncoder
+type CompressionEncoder func(w io.Writer) (io.WriteCloser, error)
+
+type CompressionConfig struct {
+	Skipper middleware.Skipper
+	// Encoders by Accept-Encoding name, default gzip
+	Encoders map[string]CompressionEncoder
+	// Encodings in the server preference order for clients with the same quality, ex: ["br", "gzip"]
+	Preference []string
+	// Responses with less bytes are not compressed, default 1024
+	MinSize int
+	// Content type prefixes that are not compressed, default images, videos, audios, archives and event streams
+	SkipTypes []string
+}
+
+var defaultCompressionSkipTypes = []string{
+	"image/", "video/", "audio/", "font/woff", MIMETextEventStream,
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-7z-compressed",
+	"application/x-rar-compressed", "application/x-bzip2", "application/x-xz", "application/zstd",
+}
+
+// NewGzipEncoder returns one gzip CompressionEncoder, level is one compress/gzip level
+func NewGzipEncoder(level int) CompressionEncoder {
+	return func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, level)
+	}
+}
+
+// SetCompressionEncoder adds one response encoding, ex: "br". Set it before the Bootstrap and add it in COMPRESSION_ENCODINGS
+func (r *AppStruct) SetCompressionEncoder(encoding string, encoder CompressionEncoder) {
+	if r.compressionEncoders == nil {
+		r.compressionEncoders = map[string]CompressionEncoder{}
+	}
+	r.compressionEncoders[encoding] = encoder
+}
+
+// Compression compresses the responses with the Accept-Encoding encoding, after the first MinSize bytes.
+// Responses with Content-Encoding or with one SkipTypes content type are not compressed
+func Compression(config CompressionConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if len(config.Encoders) == 0 {
+		config.Encoders = map[string]CompressionEncoder{"gzip": NewGzipEncoder(gzip.DefaultCompression)}
+	}
+	if len(config.Preference) == 0 {
+		config.Preference = []string{"gzip"}
+	}
+	if config.MinSize == 0 {
+		config.MinSize = 1024
+	}
+	if config.SkipTypes == nil {
+		config.SkipTypes = defaultCompressionSkipTypes
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			res := c.Response()
+			res.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+
+			encoding := negotiateEncoding(c.Request().Header.Get(echo.HeaderAcceptEncoding), config.Preference, config.Encoders)
+			if encoding == "" || c.Request().Method == http.MethodHead {
+				return next(c)
+			}
+
+			w := &compressionWriter{
+				ResponseWriter: res.Writer,
+				config:         &config,
+				encoding:       encoding,
+				code:           http.StatusOK,
+			}
+			res.Writer = w
+			defer func() {
+				if err := w.Close(); err != nil {
+					logrus.WithFields(logrus.Fields{
+						"error":     err.Error(),
+						"requestId": GetRequestID(c),
+					}).Warn("catu.Compression error on close writer")
+				}
+				res.Writer = w.ResponseWriter
+			}()
+
+			return next(c)
+		}
+	}
+}
+
+// negotiateEncoding returns the accepted encoding with the highest quality, empty for identity
+func negotiateEncoding(accept string, preference []string, encoders map[string]CompressionEncoder) string {
+	if accept == "" {
+		return ""
+	}
+
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		name, q := part, 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			name = part[:i]
+			param := strings.TrimSpace(part[i+1:])
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		qualities[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, name := range preference {
+		if encoders[name] == nil {
+			continue
+		}
+
+		q, ok := qualities[name]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+
+	return best
+}
+
+// compressionWriter buffers the first MinSize bytes to decide if the response is compressed
+type compressionWriter struct {
+	http.ResponseWriter
+	config   *CompressionConfig
+	encoding string
+
+	code        int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	encoder     io.WriteCloser
+}
+
+func (w *compressionWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.code = code
+
+	// responses without body
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		w.decide(false)
+	}
+}
+
+func (w *compressionWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.decided {
+		if w.encoder != nil {
+			return w.encoder.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.config.MinSize {
+		if err := w.decide(w.compressible()); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+// Flush sends the buffered bytes, streaming responses are compressed below the MinSize
+func (w *compressionWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(w.compressible()); err != nil {
+			return
+		}
+	}
+
+	if f, ok := w.encoder.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressionWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("catu.compressionWriter response writer is not one http.Hijacker")
+	}
+	w.decided = true
+	return h.Hijack()
+}
+
+// Close sends the small responses and closes the encoder
+func (w *compressionWriter) Close() error {
+	if !w.decided {
+		if !w.wroteHeader {
+			// the handler wrote nothing
+			return nil
+		}
+		if err := w.decide(false); err != nil {
+			return err
+		}
+	}
+
+	if w.encoder != nil {
+		return w.encoder.Close()
+	}
+	return nil
+}
+
+func (w *compressionWriter) compressible() bool {
+	header := w.Header()
+	if header.Get(echo.HeaderContentEncoding) != "" {
+		return false
+	}
+
+	contentType := strings.ToLower(header.Get(echo.HeaderContentType))
+	if contentType == "" && len(w.buf) > 0 {
+		contentType = http.DetectContentType(w.buf)
+	}
+	for _, t := range w.config.SkipTypes {
+		if strings.HasPrefix(contentType, t) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// decide writes the header and the buffered bytes, compressed or not
+func (w *compressionWriter) decide(compress bool) error {
+	w.decided = true
+
+	if compress {
+		encoder, err := w.config.Encoders[w.encoding](w.ResponseWriter)
+		if err != nil {
+			return errors.Wrap(err, "catu.Compression error on create "+w.encoding+" encoder")
+		}
+		w.encoder = encoder
+
+		header := w.Header()
+		header.Set(echo.HeaderContentEncoding, w.encoding)
+		header.Del(echo.HeaderContentLength)
+	}
+
+	w.ResponseWriter.WriteHeader(w.code)
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if w.encoder != nil {
+		_, err := w.encoder.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// bindCompression registers the Compression middleware if COMPRESSION_ENABLED is true, replacing the default gzip middleware.
+// COMPRESSION_ENCODINGS is the server preference order, default "br,gzip" with the encodings added by SetCompressionEncoder
+func (r *AppStruct) bindCompression() {
+	cfg := r.Configuration
+	if !cfg.GetBool("COMPRESSION_ENABLED") {
+		return
+	}
+
+	level := cfg.GetIntF("COMPRESSION_LEVEL", gzip.DefaultCompression)
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"level": level,
+		}).Warn("catu.App.bindCompression invalid COMPRESSION_LEVEL, using the default level")
+		level = gzip.DefaultCompression
+	}
+
+	encoders := map[string]CompressionEncoder{"gzip": NewGzipEncoder(level)}
+	for name, encoder := range r.compressionEncoders {
+		encoders[name] = encoder
+	}
+
+	config := CompressionConfig{
+		Encoders:   encoders,
+		Preference: splitConfigList(cfg.GetF("COMPRESSION_ENCODINGS", "br,gzip")),
+		MinSize:    cfg.GetIntF("COMPRESSION_MIN_SIZE", 1024),
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"encodings": config.Preference,
+		"minSize":   config.MinSize,
+	}).Debug("catu.App.bindCompression compression enabled")
+
+	r.router.Use(Compression(config))
+}
@@ -0,0 +1,162 @@
+package catu
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompression(t *testing.T) {
+	t.Setenv("COMPRESSION_ENABLED", "true")
+	t.Setenv("COMPRESSION_MIN_SIZE", "100")
+	t.Setenv("COMPRESSION_ENCODINGS", "deflate,gzip")
+
+	app := newTestApp(t)
+	app.SetCompressionEncoder("deflate", func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, flate.DefaultCompression)
+	})
+	assert.Nil(t, app.Bootstrap())
+
+	large := strings.Repeat(`{"title":"one article"},`, 100)
+
+	router := app.GetRouter()
+	router.GET("/large", func(c echo.Context) error {
+		return c.String(http.StatusOK, large)
+	})
+	router.GET("/sized", func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderContentLength, strconv.Itoa(len(large)))
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, []byte(large))
+	})
+	router.GET("/small", func(c echo.Context) error {
+		return c.String(http.StatusOK, "small")
+	})
+	router.GET("/image", func(c echo.Context) error {
+		return c.Blob(http.StatusOK, "image/png", []byte(large))
+	})
+	router.GET("/encoded", func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderContentEncoding, "gzip")
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, []byte(large))
+	})
+	router.GET("/stream", func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderContentType, "application/x-ndjson")
+		c.Response().WriteHeader(http.StatusOK)
+		for i := 0; i < 3; i++ {
+			c.Response().Write([]byte("{\"event\":1}\n"))
+			c.Response().Flush()
+		}
+		return nil
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	get := func(path, acceptEncoding string) (*http.Response, []byte) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		// one explicit Accept-Encoding disables the http.Client decompression
+		req.Header.Set(echo.HeaderAcceptEncoding, acceptEncoding)
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(res.Body)
+		return res, body
+	}
+
+	gunzip := func(b []byte) string {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		assert.Nil(t, err)
+		out, _ := ioutil.ReadAll(r)
+		return string(out)
+	}
+
+	t.Run("Should compress the large responses", func(t *testing.T) {
+		res, body := get("/large", "gzip")
+		assert.Equal(t, "gzip", res.Header.Get(echo.HeaderContentEncoding))
+		assert.Contains(t, res.Header.Values(echo.HeaderVary), echo.HeaderAcceptEncoding)
+		assert.Less(t, len(body), len(large))
+		assert.Equal(t, large, gunzip(body))
+
+		// the handler Content-Length is the uncompressed length
+		res, body = get("/sized", "gzip")
+		assert.Equal(t, "gzip", res.Header.Get(echo.HeaderContentEncoding))
+		assert.NotEqual(t, int64(len(large)), res.ContentLength)
+		assert.Equal(t, large, gunzip(body))
+	})
+
+	t.Run("Should negotiate the encoding with the Accept-Encoding qualities", func(t *testing.T) {
+		res, body := get("/large", "gzip, deflate")
+		assert.Equal(t, "deflate", res.Header.Get(echo.HeaderContentEncoding))
+		out, _ := ioutil.ReadAll(flate.NewReader(bytes.NewReader(body)))
+		assert.Equal(t, large, string(out))
+
+		res, _ = get("/large", "gzip;q=1, deflate;q=0.5")
+		assert.Equal(t, "gzip", res.Header.Get(echo.HeaderContentEncoding))
+
+		res, _ = get("/large", "br, *;q=0.1")
+		assert.Equal(t, "deflate", res.Header.Get(echo.HeaderContentEncoding))
+
+		res, _ = get("/large", "gzip;q=0, deflate;q=0")
+		assert.Equal(t, "", res.Header.Get(echo.HeaderContentEncoding))
+	})
+
+	t.Run("Should not compress without Accept-Encoding", func(t *testing.T) {
+		res, body := get("/large", "identity")
+		assert.Equal(t, "", res.Header.Get(echo.HeaderContentEncoding))
+		assert.Equal(t, large, string(body))
+		assert.Contains(t, res.Header.Values(echo.HeaderVary), echo.HeaderAcceptEncoding)
+	})
+
+	t.Run("Should not compress the small responses", func(t *testing.T) {
+		res, body := get("/small", "gzip")
+		assert.Equal(t, "", res.Header.Get(echo.HeaderContentEncoding))
+		assert.Equal(t, "small", string(body))
+		assert.Equal(t, int64(5), res.ContentLength)
+		assert.Empty(t, res.TransferEncoding)
+	})
+
+	t.Run("Should not compress compressed content types", func(t *testing.T) {
+		res, body := get("/image", "gzip")
+		assert.Equal(t, "", res.Header.Get(echo.HeaderContentEncoding))
+		assert.Equal(t, large, string(body))
+	})
+
+	t.Run("Should not compress responses with Content-Encoding", func(t *testing.T) {
+		res, body := get("/encoded", "deflate")
+		assert.Equal(t, "gzip", res.Header.Get(echo.HeaderContentEncoding))
+		assert.Equal(t, large, string(body))
+	})
+
+	t.Run("Should flush the streaming responses", func(t *testing.T) {
+		res, body := get("/stream", "gzip")
+		assert.Equal(t, "gzip", res.Header.Get(echo.HeaderContentEncoding))
+		assert.Equal(t, int64(-1), res.ContentLength)
+		assert.Equal(t, []string{"chunked"}, res.TransferEncoding)
+		assert.Equal(t, strings.Repeat("{\"event\":1}\n", 3), gunzip(body))
+	})
+
+	t.Run("Should use the default gzip middleware without COMPRESSION_ENABLED", func(t *testing.T) {
+		t.Setenv("COMPRESSION_ENABLED", "false")
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		app.GetRouter().GET("/small", func(c echo.Context) error {
+			return c.String(http.StatusOK, "small")
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/small", nil)
+		req.Header.Set(echo.HeaderAcceptEncoding, "gzip")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		assert.Equal(t, "gzip", rec.Header().Get(echo.HeaderContentEncoding))
+		assert.Equal(t, "small", gunzip(rec.Body.Bytes()))
+	})
+}
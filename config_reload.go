@@ -0,0 +1,86 @@
+package catu
+
+import (
+	"github.com/go-catupiry/catu/configuration"
+	"github.com/go-catupiry/catu/logger"
+	"github.com/gookit/event"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// EventConfigurationChanged is triggered after one CONFIG_FILE reload, with the changed "keys"
+const EventConfigurationChanged = "configuration.changed"
+
+// loadConfigFile loads the CONFIG_FILE values before the app reads the configuration, the environment variables
+// always win over the file values
+func loadConfigFile() string {
+	path := configuration.GetEnv("CONFIG_FILE", "")
+	if path == "" {
+		return ""
+	}
+
+	if _, err := configuration.LoadFile(path); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"file":  path,
+			"error": err.Error(),
+		}).Error("catu.loadConfigFile error on load configuration file")
+	}
+
+	return path
+}
+
+// startConfigWatch reloads the CONFIG_FILE on changes every CONFIG_WATCH_INTERVAL, 0 disables the watcher
+func (r *AppStruct) startConfigWatch() error {
+	if r.configFile == "" || r.configWatcher != nil {
+		return nil
+	}
+
+	interval := r.getDurationConfig("CONFIG_WATCH_INTERVAL", "2s")
+	if interval <= 0 {
+		return nil
+	}
+
+	w := &configuration.FileWatcher{
+		Path:     r.configFile,
+		Interval: interval,
+		OnChange: r.configurationChanged,
+		OnError: func(err error) {
+			logrus.WithFields(logrus.Fields{
+				"file":  r.configFile,
+				"error": err.Error(),
+			}).Error("catu.App.startConfigWatch error on reload configuration file, keeping the current values")
+		},
+	}
+
+	if err := w.Start(); err != nil {
+		return errors.Wrap(err, "catu.App.startConfigWatch error on load configuration file")
+	}
+	r.configWatcher = w
+
+	r.Events.On("shutdown", event.ListenerFunc(func(e event.Event) error {
+		w.Stop()
+		return nil
+	}), event.High)
+
+	return nil
+}
+
+// configurationChanged updates the log level and notifies the plugins with the changed keys
+func (r *AppStruct) configurationChanged(keys []string) {
+	logrus.WithFields(logrus.Fields{
+		"keys": keys,
+	}).Info("catu.App.configurationChanged configuration file reloaded")
+
+	for _, key := range keys {
+		if key == "LOG_LV" {
+			logger.SetLevel()
+		}
+	}
+
+	err, _ := r.Events.Fire(EventConfigurationChanged, event.M{"app": r, "keys": keys})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Error("catu.App.configurationChanged error on configuration changed event")
+	}
+}
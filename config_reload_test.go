@@ -0,0 +1,74 @@
+package catu
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-catupiry/catu/configuration"
+	"github.com/gookit/event"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigFileReload(t *testing.T) {
+	level := logrus.GetLevel()
+	t.Cleanup(func() {
+		configuration.SetFileValues(nil)
+		logrus.SetLevel(level)
+	})
+
+	file := filepath.Join(t.TempDir(), "app.yaml")
+	assert.Nil(t, os.WriteFile(file, []byte("SITE_NAME: First\nTEST_RELOAD_ENV: file\nLOG_LV: warn\n"), 0600))
+
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("CONFIG_WATCH_INTERVAL", "10ms")
+	t.Setenv("TEST_RELOAD_ENV", "env")
+	app := newTestApp(t)
+	cfg := app.GetConfiguration()
+
+	mu := sync.Mutex{}
+	changes := [][]string{}
+	app.GetEvents().On(EventConfigurationChanged, event.ListenerFunc(func(e event.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, e.Get("keys").([]string))
+		return nil
+	}))
+
+	assert.Nil(t, app.Bootstrap())
+	defer app.(*AppStruct).shutdown()
+
+	t.Run("Should load the file under the environment variables", func(t *testing.T) {
+		assert.Equal(t, "First", cfg.Get("SITE_NAME"))
+		assert.Equal(t, "env", cfg.Get("TEST_RELOAD_ENV"))
+		assert.Equal(t, logrus.WarnLevel, logrus.GetLevel())
+	})
+
+	t.Run("Should reload the file and trigger the changed event", func(t *testing.T) {
+		assert.Nil(t, os.WriteFile(file, []byte("SITE_NAME: Second\nTEST_RELOAD_ENV: new file\nLOG_LV: verbose\n"), 0600))
+
+		assert.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(changes) == 1
+		}, time.Second, 5*time.Millisecond)
+
+		assert.Equal(t, []string{"LOG_LV", "SITE_NAME"}, changes[0])
+		assert.Equal(t, "Second", cfg.Get("SITE_NAME"))
+		assert.Equal(t, "env", cfg.Get("TEST_RELOAD_ENV"))
+		assert.Equal(t, logrus.DebugLevel, logrus.GetLevel())
+	})
+
+	t.Run("Should keep the current values on invalid files", func(t *testing.T) {
+		assert.Nil(t, os.WriteFile(file, []byte("SITE_NAME: [invalid\n"), 0600))
+		time.Sleep(50 * time.Millisecond)
+
+		assert.Equal(t, "Second", cfg.Get("SITE_NAME"))
+		mu.Lock()
+		assert.Len(t, changes, 1)
+		mu.Unlock()
+	})
+}
@@ -1,8 +1,8 @@
 package configuration
 
 import (
-	"os"
 	"strconv"
+	"time"
 )
 
 // Configuration object with usefull methods
@@ -15,7 +15,7 @@ func (c Cfg) Init() error {
 
 // Get - Get environment variable value or "" if not exists
 func (c Cfg) Get(key string) string {
-	if value, ok := os.LookupEnv(key); ok {
+	if value, ok := LookupEnv(key); ok {
 		return value
 	}
 	return ""
@@ -23,7 +23,7 @@ func (c Cfg) Get(key string) string {
 
 // GetBoolEnv - Get an boolean env var. This returns false to invalid values
 func (c Cfg) GetBool(key string) bool {
-	if value, ok := os.LookupEnv(key); ok {
+	if value, ok := LookupEnv(key); ok {
 		boolV, err := strconv.ParseBool(value)
 		if err == nil {
 			return boolV
@@ -36,7 +36,7 @@ func (c Cfg) GetBool(key string) bool {
 }
 
 func (c Cfg) GetInt(key string) int {
-	if value, ok := os.LookupEnv(key); ok {
+	if value, ok := LookupEnv(key); ok {
 		v, err := strconv.Atoi(value)
 		if err == nil {
 			return v
@@ -49,7 +49,7 @@ func (c Cfg) GetInt(key string) int {
 }
 
 func (c Cfg) GetInt64(key string) int64 {
-	if value, ok := os.LookupEnv(key); ok {
+	if value, ok := LookupEnv(key); ok {
 		v, err := strconv.ParseInt(value, 10, 64)
 		if err == nil {
 			return v
@@ -77,3 +77,22 @@ func (c Cfg) GetIntF(key string, fallback int) int {
 func (c Cfg) GetInt64F(key string, fallback int64) int64 {
 	return GetInt64Env(key, fallback)
 }
+
+func (c Cfg) GetFloat64F(key string, fallback float64) float64 {
+	return GetFloat64Env(key, fallback)
+}
+
+// GetDuration - Get one duration like "30s" or one integer in seconds. This returns the fallback to invalid values
+func (c Cfg) GetDuration(key string, fallback time.Duration) time.Duration {
+	return GetDurationEnv(key, fallback)
+}
+
+// GetStringSlice - Get one list split by sep, ex: GetStringSlice("CORS_ORIGINS", ",", nil)
+func (c Cfg) GetStringSlice(key, sep string, fallback []string) []string {
+	return GetStringSliceEnv(key, sep, fallback)
+}
+
+// Require - Returns one error with all missing or empty keys
+func (c Cfg) Require(keys ...string) error {
+	return RequireEnv(keys...)
+}
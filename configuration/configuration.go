@@ -3,6 +3,10 @@ package configuration
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 // Default interface to be used on others modules
@@ -17,6 +21,11 @@ type ConfigurationInterface interface {
 	GetBoolF(key string, fallback bool) bool
 	GetIntF(key string, fallback int) int
 	GetInt64F(key string, fallback int64) int64
+	GetFloat64F(key string, fallback float64) float64
+	GetDuration(key string, fallback time.Duration) time.Duration
+	GetStringSlice(key, sep string, fallback []string) []string
+	// Require returns one error with all missing or empty keys
+	Require(keys ...string) error
 }
 
 // Build and get a new Cfg object
@@ -26,9 +35,30 @@ func NewCfg() ConfigurationInterface {
 	return c
 }
 
+// LookupEnv returns the environment variable value or the content of the file in the [KEY]_FILE variable,
+// ex: DB_URI_FILE=/run/secrets/db_uri. The variable has priority and one trailing line break is removed from the file.
+// Keys missing in the environment are read from the loaded configuration file, see LoadFile
+func LookupEnv(key string) (string, bool) {
+	if value, ok := os.LookupEnv(key); ok {
+		return value, true
+	}
+
+	file, ok := os.LookupEnv(key + "_FILE")
+	if !ok || file == "" {
+		return lookupFile(key)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", false
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), true
+}
+
 // Get environment variable value as string
 func GetEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
+	if value, ok := LookupEnv(key); ok {
 		return value
 	}
 	return fallback
@@ -36,7 +66,7 @@ func GetEnv(key, fallback string) string {
 
 // Get environment variable value as boolean
 func GetBoolEnv(key string, fallback bool) bool {
-	if value, ok := os.LookupEnv(key); ok {
+	if value, ok := LookupEnv(key); ok {
 		boolV, err := strconv.ParseBool(value)
 		if err == nil {
 			return boolV
@@ -50,7 +80,7 @@ func GetBoolEnv(key string, fallback bool) bool {
 
 // Get environment variable value as int
 func GetIntEnv(key string, fallback int) int {
-	if value, ok := os.LookupEnv(key); ok {
+	if value, ok := LookupEnv(key); ok {
 		v, err := strconv.Atoi(value)
 		if err == nil {
 			return v
@@ -64,7 +94,7 @@ func GetIntEnv(key string, fallback int) int {
 
 // Get environment variable value as int64
 func GetInt64Env(key string, fallback int64) int64 {
-	if value, ok := os.LookupEnv(key); ok {
+	if value, ok := LookupEnv(key); ok {
 		v, err := strconv.ParseInt(value, 10, 64)
 		if err == nil {
 			return v
@@ -75,3 +105,76 @@ func GetInt64Env(key string, fallback int64) int64 {
 
 	return fallback
 }
+
+// Get environment variable value as float64, invalid values return the fallback
+func GetFloat64Env(key string, fallback float64) float64 {
+	if value, ok := LookupEnv(key); ok {
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err == nil {
+			return v
+		}
+	}
+
+	return fallback
+}
+
+// Get environment variable value as duration, ex: "1m30s". Integers are seconds and invalid values return the fallback
+func GetDurationEnv(key string, fallback time.Duration) time.Duration {
+	value, ok := LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	value = strings.TrimSpace(value)
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(n) * time.Second
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fallback
+	}
+
+	return d
+}
+
+// Get environment variable value as one list split by sep, without empty items.
+// Missing variables return the fallback and empty variables one empty list
+func GetStringSliceEnv(key, sep string, fallback []string) []string {
+	value, ok := LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	list := []string{}
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+// RequireEnv returns one error with all missing or empty environment variables
+func RequireEnv(keys ...string) error {
+	missing := []string{}
+	seen := map[string]bool{}
+
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if value, ok := LookupEnv(key); !ok || strings.TrimSpace(value) == "" {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		return errors.New("configuration missing required keys: " + strings.Join(missing, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package configuration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCfg(t *testing.T) {
+	cfg := NewCfg()
+
+	t.Run("Should parse durations", func(t *testing.T) {
+		t.Setenv("TEST_DURATION", "1m30s")
+		assert.Equal(t, 90*time.Second, cfg.GetDuration("TEST_DURATION", time.Second))
+
+		t.Setenv("TEST_DURATION", " 15 ")
+		assert.Equal(t, 15*time.Second, cfg.GetDuration("TEST_DURATION", time.Second))
+
+		t.Setenv("TEST_DURATION", "0")
+		assert.Equal(t, time.Duration(0), cfg.GetDuration("TEST_DURATION", time.Second))
+
+		for _, invalid := range []string{"", "ten seconds", "10x", "1.5"} {
+			t.Setenv("TEST_DURATION", invalid)
+			assert.Equal(t, time.Second, cfg.GetDuration("TEST_DURATION", time.Second), invalid)
+		}
+
+		assert.Equal(t, time.Minute, cfg.GetDuration("TEST_MISSING_DURATION", time.Minute))
+	})
+
+	t.Run("Should split string slices", func(t *testing.T) {
+		t.Setenv("TEST_SLICE", "a, b,,c ,")
+		assert.Equal(t, []string{"a", "b", "c"}, cfg.GetStringSlice("TEST_SLICE", ",", nil))
+
+		t.Setenv("TEST_SLICE", "a b|c")
+		assert.Equal(t, []string{"a b", "c"}, cfg.GetStringSlice("TEST_SLICE", "|", nil))
+
+		// set and empty is one empty list
+		t.Setenv("TEST_SLICE", "")
+		assert.Equal(t, []string{}, cfg.GetStringSlice("TEST_SLICE", ",", []string{"default"}))
+		t.Setenv("TEST_SLICE", " , ")
+		assert.Equal(t, []string{}, cfg.GetStringSlice("TEST_SLICE", ",", []string{"default"}))
+
+		assert.Equal(t, []string{"default"}, cfg.GetStringSlice("TEST_MISSING_SLICE", ",", []string{"default"}))
+		assert.Nil(t, cfg.GetStringSlice("TEST_MISSING_SLICE", ",", nil))
+	})
+
+	t.Run("Should parse numbers", func(t *testing.T) {
+		t.Setenv("TEST_FLOAT", "0.25")
+		assert.Equal(t, 0.25, cfg.GetFloat64F("TEST_FLOAT", 1))
+		t.Setenv("TEST_FLOAT", "1e3")
+		assert.Equal(t, 1000.0, cfg.GetFloat64F("TEST_FLOAT", 1))
+		t.Setenv("TEST_FLOAT", "invalid")
+		assert.Equal(t, 1.5, cfg.GetFloat64F("TEST_FLOAT", 1.5))
+		assert.Equal(t, 1.5, cfg.GetFloat64F("TEST_MISSING_FLOAT", 1.5))
+
+		t.Setenv("TEST_INT", "42")
+		assert.Equal(t, 42, cfg.GetIntF("TEST_INT", 1))
+		assert.Equal(t, 7, cfg.GetIntF("TEST_MISSING_INT", 7))
+	})
+
+	t.Run("Should read the _FILE variants", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "secret")
+		assert.Nil(t, os.WriteFile(file, []byte("s3cret\n"), 0600))
+
+		t.Setenv("TEST_SECRET_FILE", file)
+		assert.Equal(t, "s3cret", cfg.Get("TEST_SECRET"))
+		assert.Equal(t, "s3cret", cfg.GetF("TEST_SECRET", "default"))
+
+		assert.Nil(t, os.WriteFile(file, []byte("30s\r\n"), 0600))
+		assert.Equal(t, 30*time.Second, cfg.GetDuration("TEST_SECRET", time.Second))
+
+		// the variable has priority
+		t.Setenv("TEST_SECRET", "from env")
+		assert.Equal(t, "from env", cfg.Get("TEST_SECRET"))
+
+		t.Setenv("TEST_UNREADABLE_FILE", filepath.Join(t.TempDir(), "missing"))
+		assert.Equal(t, "default", cfg.GetF("TEST_UNREADABLE", "default"))
+	})
+
+	t.Run("Should list all missing required keys", func(t *testing.T) {
+		t.Setenv("TEST_REQUIRED_A", "a")
+		t.Setenv("TEST_REQUIRED_EMPTY", " ")
+
+		file := filepath.Join(t.TempDir(), "secret")
+		assert.Nil(t, os.WriteFile(file, []byte("b"), 0600))
+		t.Setenv("TEST_REQUIRED_B_FILE", file)
+
+		assert.Nil(t, cfg.Require())
+		assert.Nil(t, cfg.Require("TEST_REQUIRED_A", "TEST_REQUIRED_B"))
+
+		err := cfg.Require("TEST_REQUIRED_A", "TEST_REQUIRED_MISSING", "TEST_REQUIRED_EMPTY", "TEST_REQUIRED_MISSING")
+		assert.EqualError(t, err, "configuration missing required keys: TEST_REQUIRED_MISSING, TEST_REQUIRED_EMPTY")
+	})
+}
+
+func TestFileValues(t *testing.T) {
+	t.Cleanup(func() { SetFileValues(nil) })
+	cfg := NewCfg()
+	dir := t.TempDir()
+
+	t.Run("Should parse env, JSON and YAML files", func(t *testing.T) {
+		files := map[string]string{
+			"env":  "TEST_FILE_NAME=env\nTEST_FILE_PORT=8081\n# comment\nTEST_FILE_HOSTS=\"a,b\"\n",
+			"json": `{"TEST_FILE_NAME": "json", "TEST_FILE_PORT": 8081, "TEST_FILE_HOSTS": ["a", "b"]}`,
+			"yaml": "TEST_FILE_NAME: yaml\nTEST_FILE_PORT: 8081\nTEST_FILE_HOSTS:\n  - a\n  - b\n",
+			"yml":  "TEST_FILE_NAME: yml\nTEST_FILE_PORT: 8081\nTEST_FILE_HOSTS: [a, b]\n",
+		}
+
+		for ext, content := range files {
+			path := filepath.Join(dir, "app."+ext)
+			assert.Nil(t, os.WriteFile(path, []byte(content), 0600))
+
+			_, err := LoadFile(path)
+			assert.Nil(t, err, ext)
+			assert.Equal(t, ext, cfg.Get("TEST_FILE_NAME"), ext)
+			assert.Equal(t, 8081, cfg.GetIntF("TEST_FILE_PORT", 1), ext)
+			assert.Equal(t, []string{"a", "b"}, cfg.GetStringSlice("TEST_FILE_HOSTS", ",", nil), ext)
+		}
+
+		path := filepath.Join(dir, "nested.json")
+		assert.Nil(t, os.WriteFile(path, []byte(`{"TEST_FILE_DB": {"host": "localhost"}}`), 0600))
+		_, err := LoadFile(path)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should return the changed keys and keep the environment priority", func(t *testing.T) {
+		SetFileValues(map[string]string{"TEST_FILE_A": "1", "TEST_FILE_B": "2", "TEST_FILE_ENV": "file"})
+		t.Setenv("TEST_FILE_ENV", "env")
+
+		changed := SetFileValues(map[string]string{"TEST_FILE_A": "1", "TEST_FILE_C": "3", "TEST_FILE_ENV": "new file"})
+		assert.Equal(t, []string{"TEST_FILE_B", "TEST_FILE_C"}, changed)
+		assert.Equal(t, "env", cfg.Get("TEST_FILE_ENV"))
+		assert.Equal(t, "3", cfg.Get("TEST_FILE_C"))
+		assert.Equal(t, "default", cfg.GetF("TEST_FILE_B", "default"))
+	})
+}
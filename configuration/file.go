@@ -0,0 +1,221 @@
+package configuration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v3"
+)
+
+// fileValues are the values of the loaded configuration file, replaced atomically in each load
+var fileValues = struct {
+	sync.RWMutex
+	values map[string]string
+}{}
+
+// lookupFile returns one value of the loaded configuration file
+func lookupFile(key string) (string, bool) {
+	fileValues.RLock()
+	defer fileValues.RUnlock()
+
+	value, ok := fileValues.values[key]
+	return value, ok
+}
+
+// ParseFile reads one configuration file with the format from the extension: .json, .yaml, .yml or .env for others.
+// JSON and YAML files are one object with scalar values, lists are joined with "," for GetStringSlice
+func ParseFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "configuration.ParseFile error on read file")
+	}
+
+	return parseData(path, data)
+}
+
+func parseData(path string, data []byte) (map[string]string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		raw := map[string]interface{}{}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, errors.Wrap(err, "configuration.ParseFile error on parse JSON file")
+		}
+		return stringValues(raw)
+	case ".yaml", ".yml":
+		raw := map[string]interface{}{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, errors.Wrap(err, "configuration.ParseFile error on parse YAML file")
+		}
+		return stringValues(raw)
+	default:
+		values, err := godotenv.Parse(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrap(err, "configuration.ParseFile error on parse env file")
+		}
+		return values, nil
+	}
+}
+
+func stringValues(raw map[string]interface{}) (map[string]string, error) {
+	values := make(map[string]string, len(raw))
+
+	for key, value := range raw {
+		switch v := value.(type) {
+		case nil:
+			values[key] = ""
+		case []interface{}:
+			items := make([]string, len(v))
+			for i := range v {
+				items[i] = cast.ToString(v[i])
+			}
+			values[key] = strings.Join(items, ",")
+		case map[string]interface{}:
+			return nil, fmt.Errorf("configuration.ParseFile nested objects are not supported, key: %s", key)
+		default:
+			values[key] = cast.ToString(v)
+		}
+	}
+
+	return values, nil
+}
+
+// LoadFile parses one configuration file and replaces the file values, see SetFileValues
+func LoadFile(path string) ([]string, error) {
+	values, err := ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return SetFileValues(values), nil
+}
+
+// SetFileValues replaces the configuration file values used after the environment variables and returns the
+// sorted keys with one new value. Keys set in the environment are not returned because the environment always wins
+func SetFileValues(values map[string]string) []string {
+	fileValues.Lock()
+	old := fileValues.values
+	fileValues.values = values
+	fileValues.Unlock()
+
+	changed := []string{}
+	for key, value := range values {
+		if oldValue, ok := old[key]; !ok || oldValue != value {
+			changed = append(changed, key)
+		}
+	}
+	for key := range old {
+		if _, ok := values[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+
+	keys := []string{}
+	for _, key := range changed {
+		if _, ok := os.LookupEnv(key); !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// FileWatcher reloads one configuration file when the content changes
+type FileWatcher struct {
+	Path     string
+	Interval time.Duration
+	// OnChange is called after each reload with changed keys
+	OnChange func(changed []string)
+	// OnError is called when one reload fails, the current values are kept
+	OnError func(err error)
+
+	signature uint64
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// Start loads the file and checks it for changes in background until Stop, one Interval <= 0 only loads the file
+func (w *FileWatcher) Start() error {
+	if _, err := w.reload(); err != nil {
+		return err
+	}
+
+	if w.Interval <= 0 {
+		return nil
+	}
+
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(w.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				changed, err := w.reload()
+				if err != nil {
+					if w.OnError != nil {
+						w.OnError(err)
+					}
+					continue
+				}
+				if len(changed) > 0 && w.OnChange != nil {
+					w.OnChange(changed)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop the background checks and wait for the current reload
+func (w *FileWatcher) Stop() {
+	if w.stop == nil {
+		return
+	}
+
+	close(w.stop)
+	<-w.done
+	w.stop = nil
+}
+
+// reload loads the file if the content changed since the last load
+func (w *FileWatcher) reload() ([]string, error) {
+	data, err := os.ReadFile(w.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "configuration.FileWatcher error on read file")
+	}
+
+	h := fnv.New64a()
+	h.Write(data)
+	signature := h.Sum64()
+	if signature == w.signature {
+		return nil, nil
+	}
+
+	values, err := parseData(w.Path, data)
+	if err != nil {
+		return nil, err
+	}
+	w.signature = signature
+
+	return SetFileValues(values), nil
+}
@@ -0,0 +1,173 @@
+package catu
+
+import (
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ResponseFormatRenderer writes one response in one format, see RegisterResponseFormat and RespondAuto
+type ResponseFormatRenderer func(c echo.Context, status int, data interface{}) error
+
+// responseFormats are the negotiable response formats in registration order
+type responseFormats struct {
+	mimes     []string
+	renderers map[string]ResponseFormatRenderer
+}
+
+func newResponseFormats() *responseFormats {
+	f := &responseFormats{renderers: map[string]ResponseFormatRenderer{}}
+	f.set(echo.MIMEApplicationJSON, func(c echo.Context, status int, data interface{}) error {
+		return c.JSON(status, data)
+	})
+	// string data is HTML, other data responds as JSON
+	f.set(echo.MIMETextHTML, func(c echo.Context, status int, data interface{}) error {
+		if html, ok := data.(string); ok {
+			return c.HTML(status, html)
+		}
+		return c.JSON(status, data)
+	})
+
+	return f
+}
+
+func (f *responseFormats) set(mimeType string, renderer ResponseFormatRenderer) {
+	if _, ok := f.renderers[mimeType]; !ok {
+		f.mimes = append(f.mimes, mimeType)
+	}
+	f.renderers[mimeType] = renderer
+}
+
+// RegisterResponseFormat adds or replaces one response format used in the content negotiation and RespondAuto,
+// register it in the "setResponseFormats" event, ex:
+//
+//	app.RegisterResponseFormat("text/csv", func(c echo.Context, status int, data interface{}) error { ... })
+func (r *AppStruct) RegisterResponseFormat(mimeType string, renderer ResponseFormatRenderer) {
+	r.responseFormats.set(strings.ToLower(mimeType), renderer)
+}
+
+// GetResponseFormat returns the renderer of one response format or nil
+func (r *AppStruct) GetResponseFormat(mimeType string) ResponseFormatRenderer {
+	return r.responseFormats.renderers[mimeType]
+}
+
+// NegotiateResponseType returns the response format of one request from the ?responseType= query param,
+// ex: "csv" or "text/csv", or the Accept header q-values. Without one match or with */* the request Content-Type
+// format is used and then JSON in the API_ROUTER_GROUPS (default "api") routes or RESPONSE_TYPE_DEFAULT in other routes.
+// One empty RESPONSE_TYPE_DEFAULT, the default, returns one empty type and the error handlers respond JSON
+func (r *AppStruct) NegotiateResponseType(c echo.Context) string {
+	req := c.Request()
+
+	if v := strings.ToLower(c.QueryParam("responseType")); v != "" {
+		for _, m := range r.responseFormats.mimes {
+			if v == m || v == m[strings.Index(m, "/")+1:] {
+				return m
+			}
+		}
+	}
+
+	for _, accepted := range parseAccept(req.Header.Get(echo.HeaderAccept)) {
+		if accepted == "*/*" {
+			break
+		}
+
+		for _, m := range r.responseFormats.mimes {
+			if accepted == m || (strings.HasSuffix(accepted, "/*") && strings.HasPrefix(m, accepted[:len(accepted)-1])) {
+				return m
+			}
+		}
+	}
+
+	// ex: one JSON:API request
+	if ct, _, err := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType)); err == nil && ct != echo.MIMEApplicationForm && ct != echo.MIMEMultipartForm {
+		return ct
+	}
+
+	if r.isAPIRequest(req) {
+		return echo.MIMEApplicationJSON
+	}
+
+	return r.Configuration.Get("RESPONSE_TYPE_DEFAULT")
+}
+
+// isAPIRequest returns true for requests in the API_ROUTER_GROUPS router groups
+func (r *AppStruct) isAPIRequest(req *http.Request) bool {
+	for _, name := range r.Configuration.GetStringSlice("API_ROUTER_GROUPS", ",", []string{"api"}) {
+		prefix, ok := r.routerGroupPaths[name]
+		if !ok {
+			continue
+		}
+
+		prefix = strings.TrimSuffix(prefix, "/")
+		if req.URL.Path == prefix || strings.HasPrefix(req.URL.Path, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseAccept returns the Accept media types ordered by q-value, keeping the header order for the same q-value.
+// Media types with q=0 are not acceptable and are removed
+func parseAccept(header string) []string {
+	type acceptItem struct {
+		mime string
+		q    float64
+	}
+
+	items := []acceptItem{}
+	for _, part := range strings.Split(header, ",") {
+		m, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		items = append(items, acceptItem{mime: m, q: q})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].q > items[j].q
+	})
+
+	mimes := make([]string, len(items))
+	for i := range items {
+		mimes[i] = items[i].mime
+	}
+
+	return mimes
+}
+
+// RespondAuto responds data with the renderer of the request response format, see RegisterResponseFormat.
+// Formats without one renderer, ex: JSON:API, respond as JSON
+func RespondAuto(c echo.Context, status int, data interface{}) error {
+	app := GetApp()
+	contentType := ""
+
+	if ctx, ok := c.(*RequestContext); ok {
+		app = ctx.App
+		contentType = ctx.GetResponseContentType()
+	} else if v, ok := c.Get("responseContentType").(string); ok {
+		contentType = v
+	}
+
+	renderer := app.GetResponseFormat(contentType)
+	if renderer == nil {
+		renderer = app.GetResponseFormat(echo.MIMEApplicationJSON)
+	}
+
+	return renderer(c, status, data)
+}
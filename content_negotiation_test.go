@@ -0,0 +1,108 @@
+package catu
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAccept(t *testing.T) {
+	t.Run("Should order the media types by q-value", func(t *testing.T) {
+		assert.Equal(t, []string{"text/csv", "application/json", "text/html"}, parseAccept("text/html;q=0.5, application/json;q=0.9, text/csv"))
+		assert.Equal(t, []string{"text/html", "application/xhtml+xml", "*/*"}, parseAccept("text/html,application/xhtml+xml,*/*;q=0.8"))
+	})
+
+	t.Run("Should skip invalid and not acceptable media types", func(t *testing.T) {
+		assert.Equal(t, []string{"application/json"}, parseAccept("text/html;q=0, application/json, text/csv;q=invalid, ;;"))
+		assert.Empty(t, parseAccept(""))
+	})
+}
+
+func TestContentNegotiation(t *testing.T) {
+	app := newTestApp(t)
+
+	app.GetEvents().On("setResponseFormats", event.ListenerFunc(func(e event.Event) error {
+		app.RegisterResponseFormat("text/csv", func(c echo.Context, status int, data interface{}) error {
+			return c.Blob(status, "text/csv", []byte(fmt.Sprintf("%v", data)))
+		})
+		return nil
+	}))
+
+	app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+		handler := func(c echo.Context) error {
+			return RespondAuto(c, http.StatusOK, []string{"a", "b"})
+		}
+		app.GetRouter().GET("/report", handler)
+		app.GetRouterGroup("api").GET("/report", handler)
+		return nil
+	}))
+
+	assert.Nil(t, app.Bootstrap())
+
+	request := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	negotiate := func(path string, header http.Header) string {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		return app.NegotiateResponseType(app.GetRouter().NewContext(req, httptest.NewRecorder()))
+	}
+
+	t.Run("Should select the format with the highest q-value", func(t *testing.T) {
+		assert.Equal(t, "text/csv", negotiate("/report", http.Header{"Accept": {"application/json;q=0.5, text/csv;q=0.8"}}))
+		assert.Equal(t, "application/json", negotiate("/report", http.Header{"Accept": {"image/png, application/json;q=0.5, text/csv;q=0.1"}}))
+		assert.Equal(t, "text/html", negotiate("/report", http.Header{"Accept": {"text/*"}}))
+	})
+
+	t.Run("Should override the Accept header with the responseType query param", func(t *testing.T) {
+		assert.Equal(t, "application/json", negotiate("/report?responseType=json", http.Header{"Accept": {"text/csv"}}))
+		assert.Equal(t, "text/csv", negotiate("/report?responseType=text/csv", http.Header{"Accept": {"text/html"}}))
+		// unknown types are ignored
+		assert.Equal(t, "text/html", negotiate("/report?responseType=pdf", http.Header{"Accept": {"text/html"}}))
+	})
+
+	t.Run("Should use the defaults with wildcard or missing Accept headers", func(t *testing.T) {
+		assert.Equal(t, "application/json", negotiate("/api/report", http.Header{"Accept": {"*/*"}}))
+		assert.Equal(t, "application/json", negotiate("/api/report", nil))
+		assert.Equal(t, JSONAPIContentType, negotiate("/report", http.Header{"Content-Type": {JSONAPIContentType}}))
+		assert.Equal(t, "", negotiate("/report", http.Header{"Accept": {"*/*"}}))
+
+		t.Setenv("RESPONSE_TYPE_DEFAULT", "text/html")
+		assert.Equal(t, "text/html", negotiate("/report", nil))
+		assert.Equal(t, "application/json", negotiate("/api/report", nil))
+	})
+
+	t.Run("Should respond with the negotiated format", func(t *testing.T) {
+		rec := request("/report?responseType=csv", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, "[a b]", rec.Body.String())
+
+		rec = request("/api/report", http.Header{"Accept": {"*/*"}})
+		assert.JSONEq(t, `["a","b"]`, rec.Body.String())
+	})
+
+	t.Run("Should respond errors in the negotiated format", func(t *testing.T) {
+		rec := request("/missing", http.Header{"Accept": {"text/html,*/*;q=0.8"}})
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, http.StatusText(http.StatusNotFound), rec.Body.String())
+
+		rec = request("/missing", http.Header{"Accept": {"application/json"}})
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Header().Get(echo.HeaderContentType), "application/json")
+	})
+}
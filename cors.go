@@ -0,0 +1,93 @@
+package catu
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/sirupsen/logrus"
+)
+
+// NewCORSOriginChecker returns one origin checker for the origin list.
+// "*" allows all origins and "*" inside one origin matches one or more host chars, ex: "https://*.example.com"
+func NewCORSOriginChecker(origins []string) func(origin string) bool {
+	exact := map[string]bool{}
+	patterns := []*regexp.Regexp{}
+
+	for _, o := range origins {
+		switch {
+		case o == "*":
+			return func(origin string) bool { return true }
+		case strings.Contains(o, "*"):
+			pattern := strings.ReplaceAll(regexp.QuoteMeta(o), `\*`, `[a-zA-Z0-9.-]+`)
+			patterns = append(patterns, regexp.MustCompile("^"+pattern+"$"))
+		default:
+			exact[o] = true
+		}
+	}
+
+	return func(origin string) bool {
+		if exact[origin] {
+			return true
+		}
+
+		for _, p := range patterns {
+			if p.MatchString(origin) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// SetCORSOriginChecker replaces the CORS_ALLOWED_ORIGINS check, should be called before the server starts
+func (r *AppStruct) SetCORSOriginChecker(checker func(origin string) bool) {
+	r.corsOriginChecker = checker
+}
+
+// bindCORS registers the CORS middleware from the CORS_* configurations, disable it with CORS_DISABLE=true
+func (r *AppStruct) bindCORS() {
+	cfg := r.Configuration
+
+	if cfg.GetBool("CORS_DISABLE") {
+		return
+	}
+
+	origins := splitConfigList(cfg.GetF("CORS_ALLOWED_ORIGINS", "*"))
+	if r.corsOriginChecker == nil {
+		r.corsOriginChecker = NewCORSOriginChecker(origins)
+	}
+
+	corsConfig := middleware.CORSConfig{
+		AllowMethods:     splitConfigList(cfg.Get("CORS_ALLOWED_METHODS")),
+		AllowHeaders:     splitConfigList(cfg.Get("CORS_ALLOWED_HEADERS")),
+		AllowCredentials: cfg.GetBoolF("CORS_ALLOW_CREDENTIALS", true),
+		MaxAge:           cfg.GetIntF("CORS_MAX_AGE", 18000), // seconds
+		// reads the current checker to allow plugins to replace it after the bootstrap
+		AllowOriginFunc: func(origin string) (bool, error) {
+			return r.corsOriginChecker(origin), nil
+		},
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"origins":          origins,
+		"methods":          corsConfig.AllowMethods,
+		"headers":          corsConfig.AllowHeaders,
+		"allowCredentials": corsConfig.AllowCredentials,
+		"maxAge":           corsConfig.MaxAge,
+	}).Debug("catu.App.bindCORS CORS enabled")
+
+	r.router.Use(middleware.CORSWithConfig(corsConfig))
+}
+
+// splitConfigList splits one comma separated configuration, ignoring empty items
+func splitConfigList(v string) []string {
+	list := []string{}
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
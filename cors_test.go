@@ -0,0 +1,98 @@
+package catu
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	newCORSApp := func(t *testing.T) App {
+		t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com, https://*.example.org")
+		t.Setenv("CORS_ALLOWED_METHODS", "GET,POST,DELETE")
+		t.Setenv("CORS_ALLOWED_HEADERS", "Authorization,Content-Type")
+		t.Setenv("CORS_MAX_AGE", "600")
+
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		assert.Nil(t, app.SetResource("articles", &resourceTestController{name: "core"}, app.GetRouterGroup("api").Group("/articles")))
+		return app
+	}
+
+	request := func(app App, method, path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(echo.HeaderOrigin, origin)
+		if method == http.MethodOptions {
+			req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodPost)
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Should allow configured origins in resource preflight requests", func(t *testing.T) {
+		app := newCORSApp(t)
+
+		for _, origin := range []string{"https://app.example.com", "https://api.example.org", "https://a.b.example.org"} {
+			rec := request(app, http.MethodOptions, "/api/articles/10", origin)
+			assert.Equal(t, http.StatusNoContent, rec.Code, origin)
+			assert.Equal(t, origin, rec.Header().Get(echo.HeaderAccessControlAllowOrigin), origin)
+			assert.Equal(t, "GET,POST,DELETE", rec.Header().Get(echo.HeaderAccessControlAllowMethods))
+			assert.Equal(t, "Authorization,Content-Type", rec.Header().Get(echo.HeaderAccessControlAllowHeaders))
+			assert.Equal(t, "true", rec.Header().Get(echo.HeaderAccessControlAllowCredentials))
+			assert.Equal(t, "600", rec.Header().Get(echo.HeaderAccessControlMaxAge))
+		}
+
+		rec := request(app, http.MethodGet, "/api/articles", "https://app.example.com")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "core query", rec.Body.String())
+		assert.Equal(t, "https://app.example.com", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+		assert.Equal(t, "true", rec.Header().Get(echo.HeaderAccessControlAllowCredentials))
+	})
+
+	t.Run("Should reject other origins", func(t *testing.T) {
+		app := newCORSApp(t)
+
+		for _, origin := range []string{"https://evil.com", "https://example.org", "https://app.example.com.evil.com", "http://app.example.com"} {
+			rec := request(app, http.MethodOptions, "/api/articles", origin)
+			assert.Equal(t, http.StatusNoContent, rec.Code, origin)
+			assert.Equal(t, "", rec.Header().Get(echo.HeaderAccessControlAllowOrigin), origin)
+
+			rec = request(app, http.MethodGet, "/api/articles", origin)
+			assert.Equal(t, "", rec.Header().Get(echo.HeaderAccessControlAllowOrigin), origin)
+		}
+	})
+
+	t.Run("Should not allow credentials with CORS_ALLOW_CREDENTIALS=false", func(t *testing.T) {
+		t.Setenv("CORS_ALLOW_CREDENTIALS", "false")
+		app := newCORSApp(t)
+
+		rec := request(app, http.MethodOptions, "/api/articles", "https://app.example.com")
+		assert.Equal(t, "https://app.example.com", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+		assert.Equal(t, "", rec.Header().Get(echo.HeaderAccessControlAllowCredentials))
+	})
+
+	t.Run("Should use the origin checker set by plugins", func(t *testing.T) {
+		app := newCORSApp(t)
+		app.SetCORSOriginChecker(func(origin string) bool {
+			return origin == "https://partner.com"
+		})
+
+		rec := request(app, http.MethodOptions, "/api/articles", "https://partner.com")
+		assert.Equal(t, "https://partner.com", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+
+		rec = request(app, http.MethodOptions, "/api/articles", "https://app.example.com")
+		assert.Equal(t, "", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	})
+
+	t.Run("Should allow all origins by default", func(t *testing.T) {
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+
+		rec := request(app, http.MethodOptions, "/api", "https://any.com")
+		assert.Equal(t, "https://any.com", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	})
+}
@@ -0,0 +1,265 @@
+package catu
+
+import (
+	"crypto/subtle"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/sirupsen/logrus"
+)
+
+// CSRF token storage modes
+const (
+	// CSRFModeSession stores the token in the request session
+	CSRFModeSession = "session"
+	// CSRFModeDoubleSubmit stores the token in one cookie readable by javascript, ex: SPA clients send it back in the header
+	CSRFModeDoubleSubmit = "double_submit"
+)
+
+const (
+	// echo context keys
+	csrfConfigKey = "csrfConfig"
+	csrfTokenKey  = "csrfToken"
+	// session value key
+	csrfSessionKey = "csrfToken"
+)
+
+// CSRFConfig are the CSRF middleware options, see bindCSRF for the configurations
+type CSRFConfig struct {
+	Skipper middleware.Skipper
+	// CSRFModeSession or CSRFModeDoubleSubmit
+	Mode string
+	// Request header with the token, default X-CSRF-Token
+	HeaderName string
+	// Form field with the token, default _csrf
+	FieldName string
+	// Cookie attributes of the double submit mode, default name catu_csrf
+	Cookie SessionCookieConfig
+	// Also check the JSON requests, by default only the content types that browsers send cross site are checked
+	CheckJSON bool
+}
+
+// CSRF checks the token of the POST, PUT, PATCH and DELETE requests with form, multipart, text or without content types.
+// The token is read from the HeaderName header or the FieldName form field, see GetCSRFToken and RotateCSRFToken
+func CSRF(app App, config CSRFConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.Mode == "" {
+		config.Mode = CSRFModeSession
+	}
+	if config.Mode == CSRFModeSession && app.GetSessionStore() == nil {
+		logrus.Warn("catu.CSRF session mode without sessions, using the double submit mode")
+		config.Mode = CSRFModeDoubleSubmit
+	}
+	if config.HeaderName == "" {
+		config.HeaderName = "X-CSRF-Token"
+	}
+	if config.FieldName == "" {
+		config.FieldName = "_csrf"
+	}
+	if config.Cookie.Name == "" {
+		config.Cookie.Name = "catu_csrf"
+	}
+	if config.Cookie.Path == "" {
+		config.Cookie.Path = "/"
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			c.Set(csrfConfigKey, &config)
+
+			// SPA clients read the token from the cookie before the first form
+			if config.Mode == CSRFModeDoubleSubmit {
+				GetCSRFToken(c)
+			}
+
+			if !config.shouldCheck(c.Request()) {
+				return next(c)
+			}
+
+			sent := c.Request().Header.Get(config.HeaderName)
+			if sent == "" {
+				sent = c.FormValue(config.FieldName)
+			}
+
+			expected := config.storedToken(c)
+			if sent == "" || expected == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(expected)) != 1 {
+				return csrfError(c, sent == "")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func (cfg *CSRFConfig) shouldCheck(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+
+	if cfg.CheckJSON {
+		return true
+	}
+
+	// browsers send cross site requests without content type too, ex: one no-cors fetch with one blob body
+	contentType := strings.ToLower(strings.TrimSpace(req.Header.Get(echo.HeaderContentType)))
+	return contentType == "" ||
+		strings.HasPrefix(contentType, echo.MIMEApplicationForm) ||
+		strings.HasPrefix(contentType, echo.MIMEMultipartForm) ||
+		strings.HasPrefix(contentType, echo.MIMETextPlain)
+}
+
+// storedToken returns the request session or cookie token, empty if it is missing
+func (cfg *CSRFConfig) storedToken(c echo.Context) string {
+	if cfg.Mode == CSRFModeDoubleSubmit {
+		cookie, err := c.Cookie(cfg.Cookie.Name)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+
+	s := GetSession(c)
+	if s == nil {
+		return ""
+	}
+	return s.GetString(csrfSessionKey)
+}
+
+func (cfg *CSRFConfig) saveToken(c echo.Context, token string) {
+	if cfg.Mode == CSRFModeDoubleSubmit {
+		maxAge := cfg.Cookie.MaxAge
+		if maxAge == 0 {
+			maxAge = 24 * time.Hour
+		}
+
+		cookie := cfg.Cookie.cookie(token, time.Now().Add(maxAge))
+		cookie.HttpOnly = false
+		c.SetCookie(cookie)
+		return
+	}
+
+	if s := GetSession(c); s != nil {
+		s.Set(csrfSessionKey, token)
+	}
+}
+
+// csrfError is one 403 error, HTML requests render the site/403 template
+func csrfError(c echo.Context, missing bool) error {
+	logrus.WithFields(logrus.Fields{
+		"path":      c.Path(),
+		"method":    c.Request().Method,
+		"missing":   missing,
+		"requestId": GetRequestID(c),
+	}).Debug("catu.CSRF invalid token")
+
+	if ctx, ok := c.(*RequestContext); ok && strings.Contains(c.Request().Header.Get(echo.HeaderAccept), echo.MIMETextHTML) {
+		ctx.SetResponseContentType("text/html")
+	}
+
+	return &HTTPError{Code: http.StatusForbidden, Message: "Invalid CSRF token"}
+}
+
+// GetCSRFToken returns the request CSRF token, creating one if it is missing.
+// Returns one empty string if the CSRF middleware is disabled
+func GetCSRFToken(c echo.Context) string {
+	if token, ok := c.Get(csrfTokenKey).(string); ok {
+		return token
+	}
+
+	cfg, ok := c.Get(csrfConfigKey).(*CSRFConfig)
+	if !ok {
+		return ""
+	}
+
+	token := cfg.storedToken(c)
+	if token == "" {
+		return RotateCSRFToken(c)
+	}
+
+	c.Set(csrfTokenKey, token)
+	return token
+}
+
+// RotateCSRFToken replaces the request CSRF token and returns the new one, call it on login.
+// RegenerateSession rotates the token
+func RotateCSRFToken(c echo.Context) string {
+	cfg, ok := c.Get(csrfConfigKey).(*CSRFConfig)
+	if !ok {
+		return ""
+	}
+
+	token, err := newSessionID()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Error("catu.RotateCSRFToken error on generate token")
+		return ""
+	}
+
+	cfg.saveToken(c, token)
+	c.Set(csrfTokenKey, token)
+	return token
+}
+
+// csrfField is the csrfField template function, it returns the hidden input with the request token, ex:
+//
+//	<form method="post">{{ csrfField .Ctx }}</form>
+func csrfField(c echo.Context) template.HTML {
+	token := GetCSRFToken(c)
+	if token == "" {
+		return template.HTML("")
+	}
+
+	cfg := c.Get(csrfConfigKey).(*CSRFConfig)
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(cfg.FieldName) +
+		`" value="` + template.HTMLEscapeString(token) + `">`)
+}
+
+// bindCSRF registers the CSRF middleware if CSRF_ENABLED is true.
+// CSRF_MODE is session or double_submit, the default is session with SESSION_STORE and double_submit without it
+func bindCSRF(app App) {
+	// templates may use csrfField with CSRF disabled
+	app.SetTemplateFunction("csrfField", csrfField)
+
+	cfg := app.GetConfiguration()
+	if !cfg.GetBool("CSRF_ENABLED") {
+		return
+	}
+
+	mode := cfg.Get("CSRF_MODE")
+	if mode == "" {
+		mode = CSRFModeSession
+		if app.GetSessionStore() == nil {
+			mode = CSRFModeDoubleSubmit
+		}
+	}
+
+	cookie := NewSessionCookieConfig(app)
+	cookie.Name = cfg.GetF("CSRF_COOKIE_NAME", "catu_csrf")
+
+	config := CSRFConfig{
+		Mode:       mode,
+		HeaderName: cfg.GetF("CSRF_HEADER", "X-CSRF-Token"),
+		FieldName:  cfg.GetF("CSRF_FIELD", "_csrf"),
+		Cookie:     cookie,
+		CheckJSON:  cfg.GetBool("CSRF_CHECK_JSON"),
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"mode":      config.Mode,
+		"checkJSON": config.CheckJSON,
+	}).Debug("catu.bindCSRF CSRF enabled")
+
+	app.GetRouter().Use(CSRF(app, config))
+}
@@ -0,0 +1,207 @@
+package catu
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSRF(t *testing.T) {
+	newCSRFApp := func(t *testing.T, sessionStore string) App {
+		t.Setenv("CSRF_ENABLED", "true")
+		t.Setenv("SESSION_STORE", sessionStore)
+		t.Setenv("SESSION_SECRET", "test-secret")
+		t.Setenv("SESSION_COOKIE_SECURE", "false")
+
+		dir := t.TempDir()
+		assert.Nil(t, os.MkdirAll(filepath.Join(dir, "site", "layouts"), 0755))
+		templates := map[string]string{
+			"html.html":            "{{ .Ctx.Content }}",
+			"layouts/default.html": "{{ .Ctx.Content }}",
+			"form.html":            `<form method="post">{{ csrfField .Ctx }}</form>`,
+			"403.html":             "csrf forbidden page",
+		}
+		for name, content := range templates {
+			assert.Nil(t, os.WriteFile(filepath.Join(dir, "site", name), []byte(content), 0644))
+		}
+
+		app := newTestApp(t)
+		t.Setenv("TEMPLATE_DISABLE", "false")
+		t.Setenv("TEMPLATE_FOLDER", dir)
+		assert.Nil(t, app.Bootstrap())
+
+		router := app.GetRouter()
+		router.GET("/form", func(c echo.Context) error {
+			return c.Render(http.StatusOK, "form", &TemplateCTX{Ctx: c})
+		})
+		router.GET("/token", func(c echo.Context) error {
+			return c.String(http.StatusOK, GetCSRFToken(c))
+		})
+		router.POST("/comments", func(c echo.Context) error {
+			return c.String(http.StatusCreated, "created")
+		})
+		router.POST("/login", func(c echo.Context) error {
+			if err := RegenerateSession(c); err != nil {
+				return err
+			}
+			return c.String(http.StatusOK, GetCSRFToken(c))
+		})
+
+		return app
+	}
+
+	type requestOpts struct {
+		method      string
+		path        string
+		form        url.Values
+		contentType string
+		// send the request without Content-Type
+		noContentType bool
+		accept        string
+		header        string
+		cookies       []*http.Cookie
+	}
+
+	request := func(app App, o requestOpts) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(o.method, o.path, strings.NewReader(o.form.Encode()))
+		if o.contentType == "" {
+			o.contentType = echo.MIMEApplicationForm
+		}
+		if !o.noContentType {
+			req.Header.Set(echo.HeaderContentType, o.contentType)
+		}
+		if o.accept != "" {
+			req.Header.Set(echo.HeaderAccept, o.accept)
+		}
+		if o.header != "" {
+			req.Header.Set("X-CSRF-Token", o.header)
+		}
+		for _, c := range o.cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	findCookie := func(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == name {
+				return c
+			}
+		}
+		return nil
+	}
+
+	t.Run("Should accept one valid session token", func(t *testing.T) {
+		app := newCSRFApp(t, "cookie")
+
+		rec := request(app, requestOpts{method: http.MethodGet, path: "/form"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		session := findCookie(rec, "catu_session")
+		assert.NotNil(t, session)
+		assert.Nil(t, findCookie(rec, "catu_csrf"))
+
+		body := rec.Body.String()
+		assert.Contains(t, body, `<input type="hidden" name="_csrf" value="`)
+		token := strings.Split(strings.Split(body, `value="`)[1], `"`)[0]
+		assert.NotEmpty(t, token)
+
+		rec = request(app, requestOpts{
+			method:  http.MethodPost,
+			path:    "/comments",
+			form:    url.Values{"_csrf": {token}},
+			cookies: []*http.Cookie{session},
+		})
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		t.Run("Should rotate the token on login", func(t *testing.T) {
+			rec := request(app, requestOpts{method: http.MethodPost, path: "/login", header: token, cookies: []*http.Cookie{session}})
+			assert.Equal(t, http.StatusOK, rec.Code)
+			newToken := rec.Body.String()
+			assert.NotEqual(t, token, newToken)
+			newSession := findCookie(rec, "catu_session")
+
+			rec = request(app, requestOpts{method: http.MethodPost, path: "/comments", header: token, cookies: []*http.Cookie{newSession}})
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+
+			rec = request(app, requestOpts{method: http.MethodPost, path: "/comments", header: newToken, cookies: []*http.Cookie{newSession}})
+			assert.Equal(t, http.StatusCreated, rec.Code)
+		})
+	})
+
+	t.Run("Should reject one request without token", func(t *testing.T) {
+		app := newCSRFApp(t, "cookie")
+
+		rec := request(app, requestOpts{method: http.MethodGet, path: "/token"})
+		session := findCookie(rec, "catu_session")
+
+		rec = request(app, requestOpts{method: http.MethodPost, path: "/comments", cookies: []*http.Cookie{session}})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Invalid CSRF token")
+
+		rec = request(app, requestOpts{
+			method:      http.MethodPost,
+			path:        "/comments",
+			contentType: echo.MIMEMultipartForm + "; boundary=x",
+			cookies:     []*http.Cookie{session},
+		})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+			rec = request(app, requestOpts{method: method, path: "/comments", noContentType: true, cookies: []*http.Cookie{session}})
+			assert.Equal(t, http.StatusForbidden, rec.Code, method)
+		}
+		rec = request(app, requestOpts{method: http.MethodPost, path: "/comments", contentType: " ", cookies: []*http.Cookie{session}})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		t.Run("Should render the 403 template in HTML requests", func(t *testing.T) {
+			rec := request(app, requestOpts{method: http.MethodPost, path: "/comments", accept: "text/html,*/*", cookies: []*http.Cookie{session}})
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+			assert.Equal(t, "csrf forbidden page", rec.Body.String())
+		})
+
+		t.Run("Should skip the JSON requests", func(t *testing.T) {
+			rec := request(app, requestOpts{method: http.MethodPost, path: "/comments", contentType: echo.MIMEApplicationJSON})
+			assert.Equal(t, http.StatusCreated, rec.Code)
+
+			t.Setenv("CSRF_CHECK_JSON", "true")
+			app := newCSRFApp(t, "cookie")
+			rec = request(app, requestOpts{method: http.MethodPost, path: "/comments", contentType: echo.MIMEApplicationJSON})
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		})
+	})
+
+	t.Run("Should compare the double submit cookie with the header", func(t *testing.T) {
+		app := newCSRFApp(t, "")
+
+		rec := request(app, requestOpts{method: http.MethodGet, path: "/token"})
+		cookie := findCookie(rec, "catu_csrf")
+		assert.NotNil(t, cookie)
+		assert.False(t, cookie.HttpOnly)
+		assert.Equal(t, cookie.Value, rec.Body.String())
+
+		rec = request(app, requestOpts{method: http.MethodPost, path: "/comments", header: cookie.Value, cookies: []*http.Cookie{cookie}})
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		t.Run("Should reject one token and cookie mismatch", func(t *testing.T) {
+			rec := request(app, requestOpts{method: http.MethodPost, path: "/comments", header: "other-token", cookies: []*http.Cookie{cookie}})
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+
+			// one new cookie without the token sent by the client
+			rec = request(app, requestOpts{method: http.MethodPost, path: "/comments", header: cookie.Value})
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		})
+	})
+
+	t.Run("Should render one empty csrfField with CSRF disabled", func(t *testing.T) {
+		assert.Equal(t, "", string(csrfField(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), nil))))
+	})
+}
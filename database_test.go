@@ -0,0 +1,84 @@
+package catu
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type dbTestRecord struct {
+	ID   uint64 `gorm:"primaryKey"`
+	Name string
+}
+
+func TestInitDatabase(t *testing.T) {
+	t.Setenv("DB_URI_REPORTS", filepath.Join(t.TempDir(), "reports.sqlite"))
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+
+	t.Run("Should store the default database by name", func(t *testing.T) {
+		assert.NotNil(t, app.GetDB())
+		assert.Equal(t, app.GetDB(), app.GetNamedDB("default"))
+	})
+
+	t.Run("Should start one named database with DB_URI_[NAME]", func(t *testing.T) {
+		assert.Nil(t, app.InitDatabase("reports", "", false))
+
+		reports := app.GetNamedDB("reports")
+		assert.NotNil(t, reports)
+		assert.NotEqual(t, app.GetDB(), reports)
+
+		assert.Nil(t, reports.AutoMigrate(&dbTestRecord{}))
+		assert.Nil(t, reports.Create(&dbTestRecord{Name: "report"}).Error)
+
+		assert.True(t, reports.Migrator().HasTable(&dbTestRecord{}))
+		assert.False(t, app.GetDB().Migrator().HasTable(&dbTestRecord{}))
+	})
+
+	t.Run("Should return error if the database name is already in use", func(t *testing.T) {
+		err := app.InitDatabase("reports", "sqlite", false)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "already started")
+	})
+
+	t.Run("Should return error without DB_URI_[NAME]", func(t *testing.T) {
+		err := app.InitDatabase("missing", "sqlite", false)
+		assert.NotNil(t, err)
+		assert.Nil(t, app.GetNamedDB("missing"))
+	})
+
+	t.Run("Should close and remove one named database", func(t *testing.T) {
+		sqlDB, err := app.GetNamedDB("reports").DB()
+		assert.Nil(t, err)
+
+		assert.Nil(t, app.CloseDB("reports"))
+		assert.Nil(t, app.GetNamedDB("reports"))
+		assert.NotNil(t, sqlDB.Ping())
+		assert.NotNil(t, app.CloseDB("reports"))
+
+		// can be started again after close
+		assert.Nil(t, app.InitDatabase("reports", "sqlite", false))
+		assert.True(t, app.GetNamedDB("reports").Migrator().HasTable(&dbTestRecord{}))
+	})
+}
+
+func TestSetDB(t *testing.T) {
+	app := newTestApp(t)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "custom.sqlite")), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, app.SetDB(db))
+	// one database with the callbacks
+	assert.Nil(t, app.SetDB(db))
+
+	assert.Nil(t, app.Bootstrap())
+	assert.Equal(t, db, app.GetDB())
+	assert.Equal(t, db, app.GetNamedDB("default"))
+
+	assert.NotNil(t, db.Callback().Create().Get("catu:dry_run"))
+	assert.NotNil(t, db.Callback().Create().Get(auditCallbackName))
+	assert.NotNil(t, db.Callback().Update().Get(conditionalWriteCallbackName))
+}
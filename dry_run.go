@@ -0,0 +1,278 @@
+package catu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// HeaderDryRun with "true" runs the request in dry run mode, see DryRun
+	HeaderDryRun = "X-Dry-Run"
+	// HeaderDryRunSummary has the DryRunSummary JSON of dry run responses
+	HeaderDryRunSummary = "X-Dry-Run-Summary"
+	// PermissionDryRun is required to send dry run requests
+	PermissionDryRun = "dry_run"
+)
+
+const (
+	dryRunKey    = "catu.dryRun"
+	requestDBKey = "catu.requestDB"
+)
+
+type dryRunContextKey struct{}
+
+// DryRunEffect is one side effect that would run without the dry run, ex: one mail or one webhook
+type DryRunEffect struct {
+	// Effect type, ex: "mail", "webhook", "job", "storage" or "event"
+	Type        string      `json:"type"`
+	Description string      `json:"description"`
+	Data        interface{} `json:"data,omitempty"`
+}
+
+// DryRunSummary records the would-be effects of one dry run request
+type DryRunSummary struct {
+	mu           sync.Mutex
+	rowsAffected int64
+	effects      []DryRunEffect
+}
+
+// Record adds one side effect that was skipped by the dry run
+func (s *DryRunSummary) Record(effectType, description string, data interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.effects = append(s.effects, DryRunEffect{
+		Type:        effectType,
+		Description: description,
+		Data:        data,
+	})
+}
+
+// RowsAffected returns the database rows changed by the rolled back transaction
+func (s *DryRunSummary) RowsAffected() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rowsAffected
+}
+
+func (s *DryRunSummary) Effects() []DryRunEffect {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]DryRunEffect{}, s.effects...)
+}
+
+func (s *DryRunSummary) addRows(rows int64) {
+	s.mu.Lock()
+	s.rowsAffected += rows
+	s.mu.Unlock()
+}
+
+func (s *DryRunSummary) MarshalJSON() ([]byte, error) {
+	effects := s.Effects()
+	if effects == nil {
+		effects = []DryRunEffect{}
+	}
+
+	return json.Marshal(struct {
+		RowsAffected int64          `json:"rowsAffected"`
+		Effects      []DryRunEffect `json:"effects"`
+	}{s.RowsAffected(), effects})
+}
+
+// GetDryRun returns the dry run summary of one dry run request or nil
+func GetDryRun(c echo.Context) *DryRunSummary {
+	s, _ := c.Get(dryRunKey).(*DryRunSummary)
+	return s
+}
+
+func IsDryRun(c echo.Context) bool {
+	return GetDryRun(c) != nil
+}
+
+// DryRunFromContext returns the dry run summary from one request context.Context, used in integrations without the echo context
+func DryRunFromContext(ctx context.Context) *DryRunSummary {
+	if ctx == nil {
+		return nil
+	}
+
+	s, _ := ctx.Value(dryRunContextKey{}).(*DryRunSummary)
+	return s
+}
+
+// SideEffect runs one side effect or only records it in dry run requests.
+// Integrations like mailers, webhooks, job queues and storages must run their writes with it, ex:
+//
+//	catu.SideEffect(c.Request().Context(), "mail", "welcome mail to "+to, nil, func() error { return send(to) })
+func SideEffect(ctx context.Context, effectType, description string, data interface{}, run func() error) error {
+	if s := DryRunFromContext(ctx); s != nil {
+		s.Record(effectType, description, data)
+		return nil
+	}
+
+	return run()
+}
+
+// GetDB returns the request database, one transaction that is rolled back in dry run requests.
+// Handlers must use it for writes to support dry runs. Requests with one tenant use the tenant database, see App.TenantDB
+func (r *RequestContext) GetDB() *gorm.DB {
+	if db, ok := r.Get(requestDBKey).(*gorm.DB); ok {
+		return db
+	}
+
+	return r.App.TenantDB(r)
+}
+
+// bindDryRun registers the DryRun middleware if DRY_RUN_ENABLED is true. Without it the dry run requests
+// respond 400, so one preview never runs for real
+func bindDryRun(app App) {
+	if app.GetConfiguration().GetBool("DRY_RUN_ENABLED") {
+		app.GetRouter().Use(DryRun(app))
+		return
+	}
+
+	app.GetRouter().Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if isDryRunRequest(c) {
+				return &HTTPError{Code: http.StatusBadRequest, Message: "Dry run is not enabled"}
+			}
+			return next(c)
+		}
+	})
+}
+
+func isDryRunRequest(c echo.Context) bool {
+	return strings.EqualFold(c.Request().Header.Get(HeaderDryRun), "true")
+}
+
+// NoDryRun rejects dry run requests in one route that can't be made safe, ex:
+//
+//	router.POST("/payments", handler, catu.NoDryRun)
+func NoDryRun(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if IsDryRun(c) {
+			return &HTTPError{Code: http.StatusBadRequest, Message: "Dry run is not supported"}
+		}
+
+		return next(c)
+	}
+}
+
+// DryRun runs requests with the X-Dry-Run: true header inside one database transaction that is always rolled back.
+// The SideEffect integrations only record their effects and the response has the X-Dry-Run-Summary header.
+// Requires the PermissionDryRun permission
+func DryRun(app App) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !isDryRunRequest(c) {
+				return next(c)
+			}
+
+			if ctx, ok := c.(*RequestContext); !ok || !ctx.Can(PermissionDryRun) {
+				return &HTTPError{Code: http.StatusForbidden, Message: "Dry run is not allowed"}
+			}
+
+			summary := &DryRunSummary{}
+			reqCtx := context.WithValue(c.Request().Context(), dryRunContextKey{}, summary)
+			c.SetRequest(c.Request().WithContext(reqCtx))
+
+			tx := app.TenantDB(c).WithContext(reqCtx).Begin()
+			if tx.Error != nil {
+				return tx.Error
+			}
+			defer tx.Rollback()
+
+			c.Set(dryRunKey, summary)
+			c.Set(requestDBKey, tx)
+
+			// the response is buffered to set the summary with the effects recorded after the handler writes
+			res := c.Response()
+			w := res.Writer
+			buf := &dryRunResponseWriter{ResponseWriter: w}
+			res.Writer = buf
+
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+
+			res.Writer = w
+
+			h := w.Header()
+			h.Set(HeaderDryRun, "true")
+
+			data, err := json.Marshal(summary)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"error":     err.Error(),
+					"requestId": GetRequestID(c),
+				}).Error("catu.DryRun error on marshal summary")
+			} else {
+				h.Set(HeaderDryRunSummary, string(data))
+			}
+
+			if buf.code != 0 {
+				w.WriteHeader(buf.code)
+			}
+			_, err = w.Write(buf.body.Bytes())
+			return err
+		}
+	}
+}
+
+// dryRunResponseWriter holds the dry run response until the summary is complete
+type dryRunResponseWriter struct {
+	http.ResponseWriter
+	code int
+	body bytes.Buffer
+}
+
+func (w *dryRunResponseWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+func (w *dryRunResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+// Flush is a no-op, the dry run responses are not streamed
+func (w *dryRunResponseWriter) Flush() {}
+
+// registerDryRunCallbacks counts the rows changed in dry run transactions
+func registerDryRunCallbacks(db *gorm.DB) error {
+	count := func(db *gorm.DB) {
+		if s := DryRunFromContext(db.Statement.Context); s != nil && db.Error == nil {
+			s.addRows(db.Statement.RowsAffected)
+		}
+	}
+
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register("catu:dry_run", count); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("catu:dry_run", count); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register("catu:dry_run", count); err != nil {
+		return err
+	}
+	return cb.Raw().After("gorm:raw").Register("catu:dry_run", func(db *gorm.DB) {
+		// the savepoint statements report the rows of the previous statement in sqlite
+		if !isSavePointSQL(db.Statement.SQL.String()) {
+			count(db)
+		}
+	})
+}
+
+func isSavePointSQL(sql string) bool {
+	sql = strings.ToUpper(strings.TrimSpace(sql))
+	return strings.HasPrefix(sql, "SAVEPOINT ") || strings.HasPrefix(sql, "RELEASE ") || strings.HasPrefix(sql, "ROLLBACK TO ")
+}
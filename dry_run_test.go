@@ -0,0 +1,197 @@
+package catu
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-catupiry/catu/acl"
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type dryRunTestNote struct {
+	ID    uint64 `gorm:"primaryKey"`
+	Title string
+}
+
+type dryRunTestController struct {
+	idCodecTestController
+}
+
+func (ctl *dryRunTestController) Create(c echo.Context) error {
+	ctx := c.(*RequestContext)
+
+	notes := []dryRunTestNote{{Title: "one"}, {Title: "two"}}
+	if err := ctx.GetDB().Create(&notes).Error; err != nil {
+		return err
+	}
+
+	err := SideEffect(c.Request().Context(), "mail", "note created", nil, func() error {
+		c.Response().Header().Set("X-Mail-Sent", "true")
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, notes)
+}
+
+func (ctl *dryRunTestController) Delete(c echo.Context) error {
+	ctx := c.(*RequestContext)
+
+	if err := ctx.GetDB().Where("1 = 1").Delete(&dryRunTestNote{}).Error; err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func TestDryRun(t *testing.T) {
+	t.Setenv("DRY_RUN_ENABLED", "true")
+	app := newTestApp(t)
+	// authenticates the X-Test-Role header
+	app.GetEvents().On("bindMiddlewares", event.ListenerFunc(func(e event.Event) error {
+		app.GetRouter().Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				if role := c.Request().Header.Get("X-Test-Role"); role != "" {
+					c.(*RequestContext).SetAuthenticatedUserAndFillRoles(&testUser{ID: "1", Roles: []string{role}})
+				}
+				return next(c)
+			}
+		})
+		return nil
+	}), event.Low)
+	assert.Nil(t, app.Bootstrap())
+	assert.Nil(t, app.GetDB().AutoMigrate(&dryRunTestNote{}))
+
+	router := app.GetRouter()
+	assert.Nil(t, app.SetResource("notes", &dryRunTestController{}, router.Group("/api/notes")))
+
+	dir := t.TempDir()
+	router.POST("/export", func(c echo.Context) error {
+		storage := &DirStorage{Dir: dir}
+		return storage.Save(c.Request().Context(), "index.html", strings.NewReader("ok"), "text/html")
+	})
+	router.POST("/payments", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, NoDryRun)
+
+	events := 0
+	app.GetEvents().On(EventResourceCreated, event.ListenerFunc(func(e event.Event) error {
+		events++
+		return nil
+	}))
+
+	request := func(method, path, role string, dryRun bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if role != "" {
+			req.Header.Set("X-Test-Role", role)
+		}
+		if dryRun {
+			req.Header.Set(HeaderDryRun, "true")
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	countNotes := func() int64 {
+		var count int64
+		assert.Nil(t, app.GetDB().Model(&dryRunTestNote{}).Count(&count).Error)
+		return count
+	}
+
+	t.Run("Should require the dry run permission", func(t *testing.T) {
+		rec := request(http.MethodPost, "/api/notes", "", true)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = request(http.MethodPost, "/api/notes", "authenticated", true)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, int64(0), countNotes())
+	})
+
+	t.Run("Should roll back the changes and record the effects", func(t *testing.T) {
+		rec := request(http.MethodPost, "/api/notes", "administrator", true)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "true", rec.Header().Get(HeaderDryRun))
+		assert.Equal(t, "", rec.Header().Get("X-Mail-Sent"))
+
+		summary := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal([]byte(rec.Header().Get(HeaderDryRunSummary)), &summary))
+		assert.Equal(t, float64(2), summary["rowsAffected"])
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"type": "mail", "description": "note created"},
+			map[string]interface{}{"type": "event", "description": EventResourceCreated, "data": map[string]interface{}{"resource": "notes", "id": ""}},
+		}, summary["effects"])
+
+		assert.Equal(t, int64(0), countNotes())
+		assert.Equal(t, 0, events)
+	})
+
+	t.Run("Should allow the dry run permission", func(t *testing.T) {
+		app.SetRole("manager", acl.Role{Name: "manager", Permissions: []string{PermissionDryRun}})
+
+		rec := request(http.MethodPost, "/api/notes", "manager", true)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, int64(0), countNotes())
+	})
+
+	t.Run("Should run the changes without the header", func(t *testing.T) {
+		rec := request(http.MethodPost, "/api/notes", "administrator", false)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "true", rec.Header().Get("X-Mail-Sent"))
+		assert.Equal(t, "", rec.Header().Get(HeaderDryRunSummary))
+		assert.Equal(t, int64(2), countNotes())
+		assert.Equal(t, 1, events)
+	})
+
+	t.Run("Should preview one bulk delete", func(t *testing.T) {
+		rec := request(http.MethodDelete, "/api/notes/all", "administrator", true)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.JSONEq(t, `{"rowsAffected":2,"effects":[{"type":"event","description":"resource.deleted","data":{"resource":"notes","id":"all"}}]}`, rec.Header().Get(HeaderDryRunSummary))
+		assert.Equal(t, int64(2), countNotes())
+	})
+
+	t.Run("Should skip the storage writes", func(t *testing.T) {
+		rec := request(http.MethodPost, "/export", "administrator", true)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get(HeaderDryRunSummary), `"type":"storage"`)
+
+		_, err := os.Stat(filepath.Join(dir, "index.html"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Should reject dry runs in opted out routes", func(t *testing.T) {
+		rec := request(http.MethodPost, "/payments", "administrator", true)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec = request(http.MethodPost, "/payments", "administrator", false)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestDryRunDisabled(t *testing.T) {
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+	assert.Nil(t, app.GetDB().AutoMigrate(&dryRunTestNote{}))
+	assert.Nil(t, app.SetResource("notes", &dryRunTestController{}, app.GetRouter().Group("/api/notes")))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/notes", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(HeaderDryRun, "true")
+	rec := httptest.NewRecorder()
+	app.GetRouter().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	var count int64
+	assert.Nil(t, app.GetDB().Model(&dryRunTestNote{}).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+}
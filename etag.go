@@ -0,0 +1,355 @@
+package catu
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// conditional request headers
+const (
+	headerETag        = "ETag"
+	headerIfNoneMatch = "If-None-Match"
+	headerIfMatch     = "If-Match"
+)
+
+// echo context key set by the ETag middleware, the resource routes only load the record versions with it
+const etagEnabledKey = "catu.etag"
+
+type ETagConfig struct {
+	Skipper middleware.Skipper
+}
+
+// ETag sets one strong ETag in the successful GET responses, the hash of the uncompressed body, and responds
+// 304 without body to the requests with one matching If-None-Match, ex:
+//
+//	g := app.GetRouterGroup("api")
+//	g.Use(catu.ETag(catu.ETagConfig{}))
+//
+// Responses with one ETag header keep it, ex: the FindOne routes of resources with one model with one
+// Version or UpdatedAt field, see App.SetResourceModel, that also have Last-Modified and handle If-Modified-Since
+func ETag(config ETagConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) || c.Request().Method != http.MethodGet {
+				return next(c)
+			}
+
+			c.Set(etagEnabledKey, true)
+
+			res := c.Response()
+			w := &etagResponseWriter{ResponseWriter: res.Writer}
+			res.Writer = w
+			err := next(c)
+			res.Writer = w.ResponseWriter
+
+			if w.streaming {
+				return err
+			}
+			if err != nil {
+				// the error response is written without the buffered body
+				res.Committed = false
+				res.Size = 0
+				return err
+			}
+
+			header := res.Header()
+			if w.code == http.StatusOK && header.Get(echo.HeaderSetCookie) == "" {
+				if header.Get(headerETag) == "" {
+					header.Set(headerETag, bodyETag(w.body.Bytes()))
+				}
+
+				if etagMatch(c.Request().Header.Get(headerIfNoneMatch), header.Get(headerETag), false) {
+					header.Del(echo.HeaderContentLength)
+					w.ResponseWriter.WriteHeader(http.StatusNotModified)
+					res.Status = http.StatusNotModified
+					res.Size = 0
+					return nil
+				}
+			}
+
+			if w.code != 0 {
+				w.ResponseWriter.WriteHeader(w.code)
+			}
+			_, err = w.ResponseWriter.Write(w.body.Bytes())
+			return err
+		}
+	}
+}
+
+// bodyETag returns one strong ETag with the body SHA-256
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:18]) + `"`
+}
+
+// etagMatch checks one If-None-Match or If-Match header value, one ETag list or "*".
+// If-None-Match uses the weak comparison and If-Match the strong comparison
+func etagMatch(header, etag string, strong bool) bool {
+	if header == "" || etag == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	if strong && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if strong && strings.HasPrefix(v, "W/") {
+			continue
+		}
+		if strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resourceConditional middleware handles the conditional requests of the resource routes with one model with one
+// Version or UpdatedAt field. The FindOne routes with the ETag middleware respond the record ETag and Last-Modified
+// and 304 if the record didn't change. The update and delete routes with If-Match respond 412 if the record changed,
+// checked again in the record update or delete, see conditionalWriteCallback
+func resourceConditional(app App, name string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			read := req.Method == http.MethodGet
+			if read && c.Get(etagEnabledKey) == nil {
+				return next(c)
+			}
+			if !read && req.Header.Get(headerIfMatch) == "" {
+				return next(c)
+			}
+
+			resource := app.GetResource(name)
+			if resource == nil || resource.Model == "" || app.GetModel(resource.Model) == nil {
+				return next(c)
+			}
+
+			version, err := resourceRecordVersion(c, app.GetModel(resource.Model))
+			if err != nil {
+				return err
+			}
+			// records without one version are handled by the controllers, ex: one 404
+			if version == nil {
+				return next(c)
+			}
+
+			if !read {
+				if !etagMatch(req.Header.Get(headerIfMatch), version.etag, true) {
+					return &HTTPError{Code: http.StatusPreconditionFailed, Message: "The record was changed by other request"}
+				}
+
+				// one concurrent request may change the record before the handler write
+				ctx := context.WithValue(req.Context(), conditionalWriteKey{}, version)
+				c.SetRequest(req.WithContext(ctx))
+				c.Set(requestDBKey, GetTX(c).WithContext(ctx))
+				return next(c)
+			}
+
+			header := c.Response().Header()
+			header.Set(headerETag, version.etag)
+			if !version.modified.IsZero() {
+				header.Set(echo.HeaderLastModified, version.modified.UTC().Format(http.TimeFormat))
+			}
+
+			if inm := req.Header.Get(headerIfNoneMatch); inm != "" {
+				if etagMatch(inm, version.etag, false) {
+					return &HTTPError{Code: http.StatusNotModified}
+				}
+			} else if ims, err := http.ParseTime(req.Header.Get(echo.HeaderIfModifiedSince)); err == nil && !version.modified.IsZero() {
+				if !version.modified.Truncate(time.Second).After(ims) {
+					return &HTTPError{Code: http.StatusNotModified}
+				}
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// recordVersion is the version of one resource record
+type recordVersion struct {
+	etag     string
+	modified time.Time
+	// the record and the Version or UpdatedAt column with its value, checked in the conditional writes
+	table  string
+	pk     string
+	id     string
+	column string
+	value  interface{}
+}
+
+type conditionalWriteKey struct{}
+
+// resourceRecordVersion returns the route record version, from the Version field or the UpdatedAt field.
+// Nil for records that don't exist and models without these fields
+func resourceRecordVersion(c echo.Context, model interface{}) (*recordVersion, error) {
+	db := DBWithContext(c)
+
+	record, s, err := findModelRecord(db, model, c.Param("id"))
+	if err != nil || record == nil {
+		return nil, err
+	}
+
+	ctx := db.Statement.Context
+	rv := reflect.Indirect(reflect.ValueOf(record))
+	v := &recordVersion{table: s.Table, pk: s.PrioritizedPrimaryField.DBName, id: c.Param("id")}
+
+	if f := s.LookUpField("UpdatedAt"); f != nil {
+		if t, ok := f.ReflectValueOf(ctx, rv).Interface().(time.Time); ok {
+			v.modified = t
+			if !t.IsZero() {
+				v.etag = `"` + strconv.FormatInt(t.UnixNano(), 36) + `"`
+				v.column, v.value = f.DBName, t
+			}
+		}
+	}
+
+	if f := s.LookUpField("Version"); f != nil {
+		value, _ := f.ValueOf(ctx, rv)
+		v.etag = `"v` + fmt.Sprint(value) + `"`
+		v.column, v.value = f.DBName, value
+	}
+
+	if v.etag == "" {
+		return nil, nil
+	}
+
+	return v, nil
+}
+
+const conditionalWriteCallbackName = "catu:conditional_write"
+
+// registerConditionalWriteCallbacks adds the If-Match version condition in the updates and deletes of the request record
+func registerConditionalWriteCallbacks(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Update().Before("gorm:update").Register(conditionalWriteCallbackName, conditionalWriteCallback); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register(conditionalWriteCallbackName+"_check", conditionalWriteCheckCallback); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register(conditionalWriteCallbackName, conditionalWriteCallback); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register(conditionalWriteCallbackName+"_check", conditionalWriteCheckCallback)
+}
+
+// conditionalWriteCallback only writes the request record if its version is the If-Match version.
+// Statements with one WHERE get one condition for the record, statements with one model record get the version
+func conditionalWriteCallback(db *gorm.DB) {
+	stmt := db.Statement
+	v, ok := stmt.Context.Value(conditionalWriteKey{}).(*recordVersion)
+	if !ok || db.Error != nil || stmt.Schema == nil || stmt.Schema.Table != v.table {
+		return
+	}
+
+	pk := clause.Column{Table: clause.CurrentTable, Name: v.pk}
+	column := clause.Column{Table: clause.CurrentTable, Name: v.column}
+
+	if _, ok := stmt.Clauses["WHERE"]; ok {
+		stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "(? <> ? OR ? = ?)", Vars: []interface{}{pk, v.id, column, v.value}},
+		}})
+	} else if stmt.ReflectValue.Kind() == reflect.Struct && stmt.Schema.PrioritizedPrimaryField != nil {
+		// gorm adds the primary key condition, models without it are rejected as global updates
+		id, zero := stmt.Schema.PrioritizedPrimaryField.ValueOf(stmt.Context, stmt.ReflectValue)
+		if zero || fmt.Sprint(id) != v.id {
+			return
+		}
+		stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.Eq{Column: column, Value: v.value}}})
+	} else {
+		return
+	}
+
+	db.InstanceSet(conditionalWriteCallbackName, true)
+}
+
+// conditionalWriteCheckCallback returns one 412 error if the conditional write didn't change the record
+// because one concurrent request changed its version
+func conditionalWriteCheckCallback(db *gorm.DB) {
+	if _, ok := db.InstanceGet(conditionalWriteCallbackName); !ok || db.Error != nil || db.Statement.RowsAffected > 0 {
+		return
+	}
+	v := db.Statement.Context.Value(conditionalWriteKey{}).(*recordVersion)
+
+	var changed int64
+	err := db.Session(&gorm.Session{NewDB: true}).Table(v.table).
+		Where(clause.Eq{Column: clause.Column{Name: v.pk}, Value: v.id}).
+		Where(clause.Neq{Column: clause.Column{Name: v.column}, Value: v.value}).
+		Count(&changed).Error
+	if err != nil {
+		db.AddError(errors.Wrap(err, "catu.conditionalWriteCheckCallback error on check the record version"))
+		return
+	}
+
+	if changed > 0 {
+		db.AddError(&HTTPError{Code: http.StatusPreconditionFailed, Message: "The record was changed by other request"})
+	}
+}
+
+// etagResponseWriter buffers the response to set the ETag before the header is sent.
+// Streaming responses are sent after the first Flush without ETag
+type etagResponseWriter struct {
+	http.ResponseWriter
+	code      int
+	body      bytes.Buffer
+	streaming bool
+}
+
+func (w *etagResponseWriter) WriteHeader(code int) {
+	if w.streaming {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.code = code
+}
+
+func (w *etagResponseWriter) Write(b []byte) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *etagResponseWriter) Flush() {
+	if !w.streaming {
+		w.streaming = true
+		if w.code != 0 {
+			w.ResponseWriter.WriteHeader(w.code)
+		}
+		w.ResponseWriter.Write(w.body.Bytes())
+		w.body.Reset()
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,211 @@
+package catu
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type etagTestPost struct {
+	ID        uint64    `gorm:"primaryKey" json:"id"`
+	Title     string    `json:"title"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type etagTestPostController struct {
+	idCodecTestController
+	// runs between the record read and the save, to simulate one concurrent update
+	beforeSave func(db *gorm.DB)
+}
+
+func (ctl *etagTestPostController) FindOne(c echo.Context) error {
+	post := etagTestPost{}
+	if err := c.(*RequestContext).GetDB().First(&post, "id = ?", c.Param("id")).Error; err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, post)
+}
+
+func (ctl *etagTestPostController) Update(c echo.Context) error {
+	db := c.(*RequestContext).GetDB()
+
+	post := etagTestPost{}
+	if err := db.First(&post, "id = ?", c.Param("id")).Error; err != nil {
+		return err
+	}
+	if err := c.Bind(&post); err != nil {
+		return err
+	}
+	if ctl.beforeSave != nil {
+		ctl.beforeSave(db)
+	}
+	if err := db.Save(&post).Error; err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, post)
+}
+
+func TestETag(t *testing.T) {
+	t.Setenv("COMPRESSION_ENABLED", "true")
+	t.Setenv("COMPRESSION_MIN_SIZE", "100")
+
+	app := newTestApp(t)
+	app.SetModel("post", &etagTestPost{})
+	ctl := &etagTestPostController{}
+
+	large := strings.Repeat(`{"title":"one post"},`, 100)
+
+	app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+		api := app.GetRouterGroup("api")
+		api.Use(ETag(ETagConfig{}))
+		api.GET("/feed", func(c echo.Context) error {
+			return c.String(http.StatusOK, large)
+		})
+
+		if err := app.SetResource("posts", ctl, api.Group("/posts")); err != nil {
+			return err
+		}
+		return app.SetResourceModel("posts", "post")
+	}))
+
+	assert.Nil(t, app.Bootstrap())
+	assert.Nil(t, app.GetDB().AutoMigrate(&etagTestPost{}))
+
+	updatedAt := time.Date(2022, 5, 10, 12, 30, 0, 0, time.UTC)
+	assert.Nil(t, app.GetDB().Create(&etagTestPost{ID: 1, Title: "First", UpdatedAt: updatedAt}).Error)
+
+	request := func(method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Should respond 304 with one body ETag and hash the uncompressed body", func(t *testing.T) {
+		rec := request(http.MethodGet, "/api/feed", "", map[string]string{echo.HeaderAcceptEncoding: "gzip"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "gzip", rec.Header().Get(echo.HeaderContentEncoding))
+
+		etag := rec.Header().Get("ETag")
+		assert.Equal(t, bodyETag([]byte(large)), etag)
+
+		gz, err := gzip.NewReader(rec.Body)
+		assert.Nil(t, err)
+		body, _ := ioutil.ReadAll(gz)
+		assert.Equal(t, large, string(body))
+
+		// the identity response has the same ETag
+		rec = request(http.MethodGet, "/api/feed", "", nil)
+		assert.Equal(t, etag, rec.Header().Get("ETag"))
+
+		rec = request(http.MethodGet, "/api/feed", "", map[string]string{"If-None-Match": etag, echo.HeaderAcceptEncoding: "gzip"})
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Empty(t, rec.Body.String())
+		assert.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+
+		rec = request(http.MethodGet, "/api/feed", "", map[string]string{"If-None-Match": `"other", W/` + etag})
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+
+		rec = request(http.MethodGet, "/api/feed", "", map[string]string{"If-None-Match": `"other"`})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, large, rec.Body.String())
+	})
+
+	t.Run("Should respond 304 to FindOne requests of records that didn't change", func(t *testing.T) {
+		rec := request(http.MethodGet, "/api/posts/1", "", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Tue, 10 May 2022 12:30:00 GMT", rec.Header().Get(echo.HeaderLastModified))
+
+		etag := rec.Header().Get("ETag")
+		assert.NotEmpty(t, etag)
+
+		rec = request(http.MethodGet, "/api/posts/1", "", map[string]string{"If-None-Match": etag})
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Empty(t, rec.Body.String())
+		assert.Equal(t, etag, rec.Header().Get("ETag"))
+
+		rec = request(http.MethodGet, "/api/posts/1", "", map[string]string{echo.HeaderIfModifiedSince: "Tue, 10 May 2022 12:30:00 GMT"})
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+
+		rec = request(http.MethodGet, "/api/posts/1", "", map[string]string{echo.HeaderIfModifiedSince: "Tue, 10 May 2022 12:29:59 GMT"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "/api/posts/9", "", nil).Code)
+	})
+
+	t.Run("Should respond 412 to updates with one stale If-Match", func(t *testing.T) {
+		etag := request(http.MethodGet, "/api/posts/1", "", nil).Header().Get("ETag")
+
+		rec := request(http.MethodPatch, "/api/posts/1", `{"title":"Second"}`, map[string]string{"If-Match": etag})
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = request(http.MethodPatch, "/api/posts/1", `{"title":"Third"}`, map[string]string{"If-Match": etag})
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+		post := etagTestPost{}
+		assert.Nil(t, app.GetDB().First(&post, 1).Error)
+		assert.Equal(t, "Second", post.Title)
+
+		fresh := request(http.MethodGet, "/api/posts/1", "", nil).Header().Get("ETag")
+		assert.NotEqual(t, etag, fresh)
+		rec = request(http.MethodPatch, "/api/posts/1", `{"title":"Third"}`, map[string]string{"If-Match": fresh})
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Should respond 412 to the second of two concurrent updates with the same If-Match", func(t *testing.T) {
+		etag := request(http.MethodGet, "/api/posts/1", "", nil).Header().Get("ETag")
+
+		var concurrent *httptest.ResponseRecorder
+		ctl.beforeSave = func(db *gorm.DB) {
+			ctl.beforeSave = nil
+			// the other request passes the If-Match check and saves first
+			concurrent = request(http.MethodPatch, "/api/posts/1", `{"title":"Fourth"}`, map[string]string{"If-Match": etag})
+		}
+		defer func() { ctl.beforeSave = nil }()
+
+		rec := request(http.MethodPatch, "/api/posts/1", `{"title":"Fifth"}`, map[string]string{"If-Match": etag})
+		assert.Equal(t, http.StatusOK, concurrent.Code)
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+		post := etagTestPost{}
+		assert.Nil(t, app.GetDB().First(&post, 1).Error)
+		assert.Equal(t, "Fourth", post.Title)
+
+		var count int64
+		assert.Nil(t, app.GetDB().Model(&etagTestPost{}).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Should keep the updates of other records in the If-Match requests", func(t *testing.T) {
+		assert.Nil(t, app.GetDB().Create(&etagTestPost{ID: 2, Title: "Other", UpdatedAt: updatedAt}).Error)
+		etag := request(http.MethodGet, "/api/posts/1", "", nil).Header().Get("ETag")
+
+		ctl.beforeSave = func(db *gorm.DB) {
+			ctl.beforeSave = nil
+			// one update of other record with the request database
+			assert.Nil(t, db.Model(&etagTestPost{}).Where("id = ?", 2).Update("title", "Changed").Error)
+		}
+		defer func() { ctl.beforeSave = nil }()
+
+		rec := request(http.MethodPatch, "/api/posts/1", `{"title":"Sixth"}`, map[string]string{"If-Match": etag})
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		titles := []string{}
+		assert.Nil(t, app.GetDB().Model(&etagTestPost{}).Order("id").Pluck("title", &titles).Error)
+		assert.Equal(t, []string{"Sixth", "Changed"}, titles)
+	})
+}
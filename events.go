@@ -0,0 +1,208 @@
+package catu
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gookit/event"
+	"github.com/sirupsen/logrus"
+)
+
+// AppEvent is the event of the App.On listeners
+type AppEvent struct {
+	Name string
+	App  App
+	// Event data, the legacy listeners get it in event.Event.Data(), ex: the "app" key
+	Data map[string]interface{}
+	// Context of the TriggerSync and TriggerAsync calls, context.Background() for other triggers
+	Context context.Context
+}
+
+// Get returns one Data value
+func (e *AppEvent) Get(key string) interface{} {
+	return e.Data[key]
+}
+
+// ListenerError is one listener error returned by TriggerSync
+type ListenerError struct {
+	Event    string
+	Listener string
+	Err      error
+}
+
+func (e *ListenerError) Error() string {
+	return "listener " + e.Listener + " of the event " + e.Event + ": " + e.Err.Error()
+}
+
+func (e *ListenerError) Unwrap() error {
+	return e.Err
+}
+
+// ListenerErrors are the listener errors of one TriggerSync call
+type ListenerErrors []*ListenerError
+
+func (e ListenerErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, le := range e {
+		msgs[i] = le.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// appEventListener adapts one App.On listener to the gookit event manager,
+// so the legacy triggers also call it
+type appEventListener struct {
+	app  App
+	name string
+	fn   func(e *AppEvent) error
+}
+
+func (l *appEventListener) Handle(e event.Event) error {
+	ctx := context.Background()
+	if ce, ok := e.(*contextEvent); ok {
+		ctx = ce.ctx
+	}
+
+	return l.fn(&AppEvent{Name: e.Name(), App: l.app, Data: e.Data(), Context: ctx})
+}
+
+// contextEvent is one gookit event with the TriggerSync and TriggerAsync context
+type contextEvent struct {
+	*event.BasicEvent
+	ctx context.Context
+}
+
+// On adds one listener with one priority, the higher priorities run first, ex: event.High.
+// The listeners also run in the legacy GetEvents().Fire triggers, ex: the "bootstrap" event
+//
+//	app.On("bindRoutes", event.Normal, func(e *catu.AppEvent) error {
+//		e.App.GetRouter().GET("/hello", hello)
+//		return nil
+//	})
+func (r *AppStruct) On(eventName string, priority int, fn func(e *AppEvent) error) {
+	r.Events.On(eventName, &appEventListener{app: r, name: funcName(fn), fn: fn}, priority)
+}
+
+// TriggerSync runs all listeners of one event in priority order and returns one ListenerErrors with
+// the failing listeners. One listener can stop the next listeners with the gookit event Abort
+func (r *AppStruct) TriggerSync(ctx context.Context, eventName string, data map[string]interface{}) error {
+	e := r.newContextEvent(ctx, eventName, data)
+
+	var errs ListenerErrors
+	for _, li := range r.eventListeners(eventName) {
+		if err := li.Listener.Handle(e); err != nil {
+			errs = append(errs, &ListenerError{Event: eventName, Listener: listenerName(li.Listener), Err: err})
+		}
+		if e.IsAborted() {
+			break
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// TriggerAsync runs each listener of one event in one goroutine, wait the returned WaitGroup to wait the listeners.
+// Each listener gets one copy of data. The errors and panics are logged with the listener name
+func (r *AppStruct) TriggerAsync(ctx context.Context, eventName string, data map[string]interface{}) *sync.WaitGroup {
+	wg := &sync.WaitGroup{}
+
+	for _, li := range r.eventListeners(eventName) {
+		listener := li.Listener
+		listenerData := make(map[string]interface{}, len(data)+1)
+		for k, v := range data {
+			listenerData[k] = v
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if rec := recover(); rec != nil {
+					logrus.WithFields(logrus.Fields{
+						"event":    eventName,
+						"listener": listenerName(listener),
+						"panic":    fmt.Sprintf("%v", rec),
+						"stack":    string(debug.Stack()),
+					}).Error("catu.App.TriggerAsync listener panic")
+				}
+			}()
+
+			if err := listener.Handle(r.newContextEvent(ctx, eventName, listenerData)); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"event":    eventName,
+					"listener": listenerName(listener),
+					"error":    fmt.Sprintf("%+v", err),
+				}).Error("catu.App.TriggerAsync listener error")
+			}
+		}()
+	}
+
+	return wg
+}
+
+func (r *AppStruct) newContextEvent(ctx context.Context, eventName string, data map[string]interface{}) *contextEvent {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	if _, ok := data["app"]; !ok {
+		data["app"] = r
+	}
+
+	return &contextEvent{BasicEvent: event.NewBasic(eventName, data), ctx: ctx}
+}
+
+// eventListeners returns the listeners in the gookit manager order: the event listeners by priority,
+// then the group listeners, ex: "jobs.*", and the "*" listeners
+func (r *AppStruct) eventListeners(eventName string) []*event.ListenerItem {
+	names := []string{eventName}
+	if pos := strings.LastIndexByte(eventName, '.'); pos > 0 {
+		names = append(names, eventName[:pos+1]+event.Wildcard)
+	}
+	names = append(names, event.Wildcard)
+
+	items := []*event.ListenerItem{}
+	for _, name := range names {
+		lq := r.Events.ListenersByName(name)
+		if lq == nil {
+			continue
+		}
+
+		group := append([]*event.ListenerItem{}, lq.Items()...)
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].Priority > group[j].Priority
+		})
+		items = append(items, group...)
+	}
+
+	return items
+}
+
+// listenerName returns one listener function name for the error messages, ex: "main.bindRoutes"
+func listenerName(l event.Listener) string {
+	switch v := l.(type) {
+	case *appEventListener:
+		return v.name
+	case event.ListenerFunc:
+		return funcName(v)
+	}
+	return fmt.Sprintf("%T", l)
+}
+
+func funcName(fn interface{}) string {
+	if f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()); f != nil {
+		return f.Name()
+	}
+	return fmt.Sprintf("%T", fn)
+}
@@ -0,0 +1,119 @@
+package catu
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/gookit/event"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+type eventsTestKey struct{}
+
+func eventsTestFailingListener(e *AppEvent) error {
+	return errors.New("plugin broke")
+}
+
+func TestAppEvents(t *testing.T) {
+	t.Run("Should run the listeners by priority with the typed event", func(t *testing.T) {
+		app := newTestApp(t)
+		calls := []string{}
+		var ctxValue interface{}
+
+		app.On("report", event.Low, func(e *AppEvent) error {
+			calls = append(calls, "low")
+			return nil
+		})
+		app.GetEvents().On("report", event.ListenerFunc(func(e event.Event) error {
+			calls = append(calls, "legacy")
+			return nil
+		}), event.Normal)
+		app.On("report", event.High, func(e *AppEvent) error {
+			assert.Equal(t, app, e.App)
+			assert.Equal(t, "report", e.Name)
+			assert.Equal(t, "monthly", e.Get("kind"))
+			ctxValue = e.Context.Value(eventsTestKey{})
+			calls = append(calls, "high")
+			return nil
+		})
+
+		ctx := context.WithValue(context.Background(), eventsTestKey{}, "value")
+		assert.Nil(t, app.TriggerSync(ctx, "report", map[string]interface{}{"kind": "monthly"}))
+		assert.Equal(t, []string{"high", "legacy", "low"}, calls)
+		assert.Equal(t, "value", ctxValue)
+
+		// the legacy triggers call the typed listeners
+		calls = []string{}
+		err, _ := app.GetEvents().Fire("report", event.M{"app": app, "kind": "monthly"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"high", "legacy", "low"}, calls)
+	})
+
+	t.Run("Should run all listeners and return the failing listener names", func(t *testing.T) {
+		app := newTestApp(t)
+		ran := 0
+
+		app.On("bindRoutes", event.High, eventsTestFailingListener)
+		app.On("bindRoutes", event.Normal, func(e *AppEvent) error {
+			ran++
+			return nil
+		})
+		app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+			return errors.New("legacy broke")
+		}), event.Low)
+
+		err := app.Bootstrap()
+		assert.Equal(t, 1, ran)
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "App.Bootstrap error on bindRoutes event")
+			assert.Contains(t, err.Error(), "catu.eventsTestFailingListener of the event bindRoutes: plugin broke")
+			assert.Contains(t, err.Error(), "legacy broke")
+		}
+
+		errs := ListenerErrors{}
+		assert.True(t, errors.As(err, &errs))
+		assert.Len(t, errs, 2)
+	})
+
+	t.Run("Should run the async listeners and recover their panics", func(t *testing.T) {
+		app := newTestApp(t)
+		hooks := logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+		t.Cleanup(func() { logrus.StandardLogger().ReplaceHooks(hooks) })
+		hook := test.NewGlobal()
+
+		var mu sync.Mutex
+		received := []interface{}{}
+		for i := 0; i < 3; i++ {
+			app.On("imported", event.Normal, func(e *AppEvent) error {
+				mu.Lock()
+				defer mu.Unlock()
+				received = append(received, e.Get("count"))
+				e.Data["count"] = 0
+				return nil
+			})
+		}
+		app.On("imported", event.Normal, func(e *AppEvent) error {
+			var items map[string]int
+			items["boom"]++
+			return nil
+		})
+
+		app.TriggerAsync(context.Background(), "imported", map[string]interface{}{"count": 10}).Wait()
+
+		assert.Equal(t, []interface{}{10, 10, 10}, received)
+
+		panics := 0
+		for _, e := range hook.AllEntries() {
+			if e.Message == "catu.App.TriggerAsync listener panic" {
+				panics++
+				assert.Equal(t, "imported", e.Data["event"])
+				assert.Contains(t, e.Data["stack"], "runtime/debug.Stack")
+			}
+		}
+		assert.Equal(t, 1, panics)
+	})
+}
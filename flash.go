@@ -0,0 +1,230 @@
+package catu
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// echo context key with the request *flashState
+const flashStateKey = "flashes"
+
+// session key with the JSON encoded flashes
+const flashSessionKey = "_flashes"
+
+// the flashes are shown in one of the next requests, ex: after one redirect
+const flashCookieMaxAge = 10 * time.Minute
+
+// Flash is one message shown in the next HTML page, ex: one "success" message after one redirect.
+// The messages are text, html/template escapes them in the templates
+type Flash struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// flashState are the request flashes, loaded from the session or the flash cookie
+type flashState struct {
+	flashes    []Flash
+	cookieHook bool
+}
+
+var (
+	flashRandomKey     []byte
+	flashRandomKeyOnce sync.Once
+)
+
+// AddFlash adds one message shown by the next GetFlashes, in this request or after one redirect, ex:
+//
+//	ctx.AddFlash("success", "Article saved")
+//	return ctx.Redirect(http.StatusSeeOther, "/articles")
+//
+// The flashes are saved in the session or, without sessions, in one signed cookie, FLASH_COOKIE_NAME
+func (r *RequestContext) AddFlash(kind, message string) {
+	state := loadFlashState(r)
+	state.flashes = append(state.flashes, Flash{Kind: kind, Message: message})
+	saveFlashState(r, state)
+}
+
+// GetFlashes returns and clears the flashes, use the "flashes" template function in the layouts, ex:
+//
+//	{{ range flashes .Ctx }}<p class="flash-{{ .Kind }}">{{ .Message }}</p>{{ end }}
+func (r *RequestContext) GetFlashes() []Flash {
+	state := loadFlashState(r)
+	flashes := state.flashes
+	if len(flashes) == 0 {
+		return []Flash{}
+	}
+
+	state.flashes = nil
+	saveFlashState(r, state)
+	return flashes
+}
+
+// templateFlashes is the "flashes" template function
+func templateFlashes(ctx *RequestContext) []Flash {
+	if ctx == nil {
+		return []Flash{}
+	}
+	return ctx.GetFlashes()
+}
+
+func loadFlashState(r *RequestContext) *flashState {
+	if state, ok := r.Get(flashStateKey).(*flashState); ok {
+		return state
+	}
+
+	state := &flashState{}
+	r.Set(flashStateKey, state)
+
+	if session := GetSession(r); session != nil {
+		if data := session.GetString(flashSessionKey); data != "" {
+			if err := json.Unmarshal([]byte(data), &state.flashes); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"error":     err.Error(),
+					"requestId": GetRequestID(r),
+				}).Warn("catu.loadFlashState invalid session flashes")
+			}
+		}
+		return state
+	}
+
+	cookie, err := r.Cookie(flashCookieName(r.App))
+	if err != nil {
+		return state
+	}
+
+	flashes, ok := decodeFlashCookie(r.App, cookie.Value)
+	if !ok {
+		logrus.WithFields(logrus.Fields{
+			"requestId": GetRequestID(r),
+		}).Warn("catu.loadFlashState invalid flash cookie signature")
+		// expires the invalid cookie
+		ensureFlashCookieHook(r, state)
+		return state
+	}
+
+	state.flashes = flashes
+	return state
+}
+
+func saveFlashState(r *RequestContext, state *flashState) {
+	if session := GetSession(r); session != nil {
+		if len(state.flashes) == 0 {
+			session.Delete(flashSessionKey)
+			return
+		}
+
+		data, _ := json.Marshal(state.flashes)
+		session.Set(flashSessionKey, string(data))
+		return
+	}
+
+	ensureFlashCookieHook(r, state)
+}
+
+// ensureFlashCookieHook writes the flash cookie before the response headers
+func ensureFlashCookieHook(r *RequestContext, state *flashState) {
+	if state.cookieHook {
+		return
+	}
+	state.cookieHook = true
+
+	app := r.App
+	// the hook may run in the error handler, after the RequestContext release
+	c := r.EchoContext
+
+	c.Response().Before(func() {
+		cfg := NewSessionCookieConfig(app)
+		cfg.Name = flashCookieName(app)
+
+		if len(state.flashes) == 0 {
+			cfg.expireCookie(c)
+			return
+		}
+
+		value := encodeFlashCookie(app, state.flashes)
+		// the oldest flashes are dropped to fit in one cookie
+		for len(value) > maxSessionCookieSize && len(state.flashes) > 0 {
+			state.flashes = state.flashes[1:]
+			value = encodeFlashCookie(app, state.flashes)
+
+			logrus.WithFields(logrus.Fields{
+				"requestId": GetRequestID(c),
+			}).Warn("catu.AddFlash flashes too large for one cookie, dropping the oldest flash")
+		}
+
+		if len(state.flashes) == 0 {
+			cfg.expireCookie(c)
+			return
+		}
+
+		cfg.setCookie(c, value, time.Now().Add(flashCookieMaxAge))
+	})
+}
+
+func flashCookieName(app App) string {
+	return app.GetConfiguration().GetF("FLASH_COOKIE_NAME", "catu_flash")
+}
+
+// flashKey derives the cookie signature key from FLASH_SECRET or SESSION_SECRET.
+// Without them one random key is used, valid only in this process
+func flashKey(app App) []byte {
+	cfg := app.GetConfiguration()
+	secret := cfg.GetF("FLASH_SECRET", cfg.Get("SESSION_SECRET"))
+	if secret == "" {
+		flashRandomKeyOnce.Do(func() {
+			flashRandomKey = make([]byte, 32)
+			if _, err := rand.Read(flashRandomKey); err != nil {
+				panic("catu.flashKey error on generate key: " + err.Error())
+			}
+		})
+		return flashRandomKey
+	}
+
+	key := sha256.Sum256([]byte("catu.flash:" + secret))
+	return key[:]
+}
+
+// encodeFlashCookie returns the base64 JSON flashes and the HMAC-SHA256 signature, ex: "data.signature"
+func encodeFlashCookie(app App, flashes []Flash) string {
+	data, _ := json.Marshal(flashes)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	mac := hmac.New(sha256.New, flashKey(app))
+	mac.Write([]byte(payload))
+
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func decodeFlashCookie(app App, value string) ([]Flash, bool) {
+	i := strings.LastIndexByte(value, '.')
+	if i < 0 {
+		return nil, false
+	}
+	payload, signature := value[:i], value[i+1:]
+
+	mac := hmac.New(sha256.New, flashKey(app))
+	mac.Write([]byte(payload))
+	if !hmac.Equal([]byte(base64.RawURLEncoding.EncodeToString(mac.Sum(nil))), []byte(signature)) {
+		return nil, false
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, false
+	}
+
+	flashes := []Flash{}
+	if err := json.Unmarshal(data, &flashes); err != nil {
+		return nil, false
+	}
+
+	return flashes, true
+}
@@ -0,0 +1,125 @@
+package catu
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlash(t *testing.T) {
+	newFlashApp := func(t *testing.T) App {
+		app := newTestApp(t)
+		t.Setenv("TEMPLATE_DISABLE", "false")
+		t.Setenv("TEMPLATE_FOLDER", t.TempDir())
+
+		templates := fstest.MapFS{
+			"site/layouts/main.html": &fstest.MapFile{Data: []byte(`{{ range flashes .Ctx }}<p class="{{ .Kind }}">{{ .Message }}</p>{{ end }}{{ .Content }}`)},
+			"site/home.html":         &fstest.MapFile{Data: []byte(`<h1>home</h1>`)},
+		}
+		assert.Nil(t, app.LoadTemplatesFromFS(templates, ""))
+		app.SetDefaultLayout("layouts/main")
+
+		app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+			router := app.GetRouter()
+			router.POST("/articles", func(c echo.Context) error {
+				ctx := c.(*RequestContext)
+				ctx.AddFlash("success", "Article <b>saved</b>")
+				ctx.AddFlash("info", c.FormValue("message"))
+				return c.Redirect(http.StatusSeeOther, "/")
+			})
+			router.GET("/", func(c echo.Context) error {
+				return RenderPage(c, http.StatusOK, "home", nil)
+			})
+			return nil
+		}))
+
+		assert.Nil(t, app.Bootstrap())
+		return app
+	}
+
+	// browser sends the cookies of the previous responses
+	type browser struct {
+		app     App
+		cookies map[string]*http.Cookie
+	}
+
+	do := func(b *browser, req *http.Request) *httptest.ResponseRecorder {
+		req.Header.Set(echo.HeaderAccept, echo.MIMETextHTML)
+		for _, cookie := range b.cookies {
+			req.AddCookie(cookie)
+		}
+
+		rec := httptest.NewRecorder()
+		b.app.GetRouter().ServeHTTP(rec, req)
+
+		for _, cookie := range rec.Result().Cookies() {
+			if cookie.MaxAge < 0 {
+				delete(b.cookies, cookie.Name)
+				continue
+			}
+			b.cookies[cookie.Name] = cookie
+		}
+		return rec
+	}
+
+	post := func(b *browser, message string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/articles", strings.NewReader(url.Values{"message": {message}}.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		return do(b, req)
+	}
+
+	testRedirect := func(t *testing.T, b *browser) {
+		rec := post(b, "Second")
+		assert.Equal(t, http.StatusSeeOther, rec.Code)
+
+		rec = do(b, httptest.NewRequest(http.MethodGet, rec.Header().Get(echo.HeaderLocation), nil))
+		assert.Equal(t, `<p class="success">Article &lt;b&gt;saved&lt;/b&gt;</p><p class="info">Second</p><h1>home</h1>`, rec.Body.String())
+
+		rec = do(b, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, `<h1>home</h1>`, rec.Body.String())
+	}
+
+	t.Run("Should show the flashes once after the redirect with one signed cookie", func(t *testing.T) {
+		b := &browser{app: newFlashApp(t), cookies: map[string]*http.Cookie{}}
+		testRedirect(t, b)
+	})
+
+	t.Run("Should show the flashes once after the redirect with the session", func(t *testing.T) {
+		t.Setenv("SESSION_STORE", "cookie")
+		t.Setenv("SESSION_SECRET", "flash-test-secret")
+		b := &browser{app: newFlashApp(t), cookies: map[string]*http.Cookie{}}
+		testRedirect(t, b)
+		assert.NotContains(t, b.cookies, "catu_flash")
+	})
+
+	t.Run("Should ignore tampered flash cookies", func(t *testing.T) {
+		b := &browser{app: newFlashApp(t), cookies: map[string]*http.Cookie{}}
+		post(b, "Second")
+
+		cookie := b.cookies["catu_flash"]
+		if assert.NotNil(t, cookie) {
+			signed := encodeFlashCookie(b.app, []Flash{{Kind: "error", Message: "forged"}})
+			cookie.Value = signed[:len(signed)-2] + "xx"
+		}
+
+		rec := do(b, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, `<h1>home</h1>`, rec.Body.String())
+		assert.NotContains(t, b.cookies, "catu_flash")
+	})
+
+	t.Run("Should drop the oldest flashes that don't fit in one cookie", func(t *testing.T) {
+		b := &browser{app: newFlashApp(t), cookies: map[string]*http.Cookie{}}
+		post(b, strings.Repeat("a", 3000))
+
+		rec := do(b, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.NotContains(t, rec.Body.String(), "saved")
+		assert.Contains(t, rec.Body.String(), `<p class="info">aaa`)
+	})
+}
@@ -0,0 +1,571 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-catupiry/catu/helpers"
+	"github.com/go-catupiry/query_parser_to_db"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Response is the GraphQL response document
+type Response struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []*Error               `json:"errors,omitempty"`
+}
+
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// ExecuteOpts are the request scoped options to run one query
+type ExecuteOpts struct {
+	Context       context.Context
+	DB            *gorm.DB
+	Query         string
+	OperationName string
+	Variables     map[string]interface{}
+	// Permission checker, like RequestContext.Can
+	Can           func(permission string) bool
+	DefaultLimit  int64
+	LimitMax      int64
+	MaxDepth      int
+	MaxComplexity int
+}
+
+// ValidationError is returned for invalid documents, before any database query
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+type executor struct {
+	schema *Schema
+	opts   *ExecuteOpts
+	vars   map[string]interface{}
+	errors []*Error
+}
+
+// Execute one GraphQL query document
+func (s *Schema) Execute(opts *ExecuteOpts) (*Response, error) {
+	doc, err := Parse(opts.Query)
+	if err != nil {
+		return nil, &ValidationError{Message: err.Error()}
+	}
+
+	op, err := selectOperation(doc, opts.OperationName)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &executor{schema: s, opts: opts, vars: make(map[string]interface{})}
+
+	for _, def := range op.Variables {
+		if v, ok := opts.Variables[def.Name]; ok {
+			e.vars[def.Name] = v
+		} else if def.DefaultValue != nil {
+			e.vars[def.Name] = def.DefaultValue.Resolve(nil)
+		}
+	}
+
+	complexity, err := e.validateRoot(op.SelectionSet)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.MaxComplexity > 0 && complexity > opts.MaxComplexity {
+		return nil, &ValidationError{Message: fmt.Sprintf("query complexity %d exceeds the maximum allowed complexity %d", complexity, opts.MaxComplexity)}
+	}
+
+	data := e.executeRoot(op.SelectionSet)
+
+	return &Response{Data: data, Errors: e.errors}, nil
+}
+
+func selectOperation(doc *Document, name string) (*Operation, error) {
+	if name == "" {
+		if len(doc.Operations) > 1 {
+			return nil, &ValidationError{Message: "operationName is required for documents with multiple operations"}
+		}
+		return doc.Operations[0], nil
+	}
+
+	for _, op := range doc.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+
+	return nil, &ValidationError{Message: fmt.Sprintf("unknown operation named %q", name)}
+}
+
+func (e *executor) validateRoot(sels []*Selection) (int, error) {
+	complexity := 0
+
+	for _, s := range sels {
+		if s.Name == "__typename" {
+			complexity++
+			continue
+		}
+
+		rf := e.schema.QueryFields[s.Name]
+		if rf == nil {
+			return 0, &ValidationError{Message: fmt.Sprintf("Cannot query field %q on type \"Query\"", s.Name)}
+		}
+
+		if len(s.SelectionSet) == 0 {
+			return 0, &ValidationError{Message: fmt.Sprintf("Field %q of type %q must have a selection of subfields", s.Name, rf.Type.TypeName)}
+		}
+
+		child, err := e.validateSelections(rf.Type, s.SelectionSet, 2)
+		if err != nil {
+			return 0, err
+		}
+
+		multiplier := 1
+		if rf.IsList {
+			multiplier = int(e.limit(s))
+		}
+
+		complexity += 1 + child*multiplier
+	}
+
+	return complexity, nil
+}
+
+func (e *executor) validateSelections(t *ObjectType, sels []*Selection, depth int) (int, error) {
+	if e.opts.MaxDepth > 0 && depth > e.opts.MaxDepth {
+		return 0, &ValidationError{Message: fmt.Sprintf("query depth exceeds the maximum allowed depth %d", e.opts.MaxDepth)}
+	}
+
+	complexity := 0
+
+	for _, s := range sels {
+		if s.Name == "__typename" {
+			complexity++
+			continue
+		}
+
+		f := t.Fields[s.Name]
+		if f == nil {
+			return 0, &ValidationError{Message: fmt.Sprintf("Cannot query field %q on type %q", s.Name, t.TypeName)}
+		}
+
+		if f.DBField != nil {
+			if len(s.SelectionSet) > 0 {
+				return 0, &ValidationError{Message: fmt.Sprintf("Field %q must not have a selection since it is a scalar", s.Name)}
+			}
+			complexity++
+			continue
+		}
+
+		if len(s.SelectionSet) == 0 {
+			return 0, &ValidationError{Message: fmt.Sprintf("Field %q of type %q must have a selection of subfields", s.Name, f.Target.TypeName)}
+		}
+
+		child, err := e.validateSelections(f.Target, s.SelectionSet, depth+1)
+		if err != nil {
+			return 0, err
+		}
+
+		multiplier := 1
+		if f.IsList {
+			multiplier = int(e.limit(s))
+		}
+
+		complexity += 1 + child*multiplier
+	}
+
+	return complexity, nil
+}
+
+func (e *executor) addError(err error, path ...interface{}) {
+	e.errors = append(e.errors, &Error{Message: err.Error(), Path: path})
+}
+
+func (e *executor) arg(s *Selection, name string) interface{} {
+	if v, ok := s.Arguments[name]; ok {
+		return v.Resolve(e.vars)
+	}
+	return nil
+}
+
+func (e *executor) limit(s *Selection) int64 {
+	limit, ok := toInt64(e.arg(s, "limit"))
+	if !ok || limit <= 0 {
+		return e.opts.DefaultLimit
+	}
+
+	if e.opts.LimitMax > 0 && limit > e.opts.LimitMax {
+		return e.opts.LimitMax
+	}
+
+	return limit
+}
+
+func (e *executor) executeRoot(sels []*Selection) map[string]interface{} {
+	data := make(map[string]interface{})
+
+	for _, s := range sels {
+		key := s.ResponseKey()
+
+		if s.Name == "__typename" {
+			data[key] = "Query"
+			continue
+		}
+
+		rf := e.schema.QueryFields[s.Name]
+		data[key] = nil
+
+		if !e.can(rf.Type) {
+			e.addError(errors.New("Forbidden"), key)
+			continue
+		}
+
+		records, err := e.loadRoot(rf, s)
+		if err != nil {
+			e.addError(err, key)
+			continue
+		}
+
+		items := e.resolveObjects(rf.Type, records, s.SelectionSet, key)
+
+		if rf.IsList {
+			data[key] = items
+		} else if len(items) > 0 {
+			data[key] = items[0]
+		}
+	}
+
+	return data
+}
+
+func (e *executor) can(t *ObjectType) bool {
+	if e.opts.Can == nil {
+		return true
+	}
+	return e.opts.Can(t.Permission)
+}
+
+func (e *executor) loadRoot(rf *RootField, s *Selection) (reflect.Value, error) {
+	t := rf.Type
+	records := reflect.New(reflect.SliceOf(t.modelType))
+	model := reflect.New(t.modelType).Interface()
+
+	query := e.opts.DB.WithContext(e.context()).Model(model).Select(e.columns(t, s.SelectionSet, ""))
+
+	if !rf.IsList {
+		id := e.arg(s, "id")
+		if id == nil {
+			return records.Elem(), errors.New("argument \"id\" is required")
+		}
+
+		err := query.Where(clause.Eq{Column: clause.Column{Name: t.schema.PrioritizedPrimaryField.DBName}, Value: id}).
+			Limit(1).
+			Find(records.Interface()).Error
+		return records.Elem(), err
+	}
+
+	// same filter and pagination semantics of the REST Query routes:
+	values := url.Values{}
+	if filter, ok := e.arg(s, "filter").(map[string]interface{}); ok {
+		for k, v := range filter {
+			if list, ok := v.([]interface{}); ok {
+				for i := range list {
+					values.Add(k, fmt.Sprint(list[i]))
+				}
+				continue
+			}
+			values.Set(k, fmt.Sprint(v))
+		}
+	}
+
+	q := query_parser_to_db.NewQuery(e.opts.LimitMax)
+	if err := q.ParseFromURLValues(values); err != nil {
+		return records.Elem(), err
+	}
+
+	q.SetLimit(e.limit(s))
+	if page, ok := toInt64(e.arg(s, "page")); ok {
+		q.SetPage(page)
+	}
+
+	qi, err := q.SetDatabaseQueryForModel(query, model)
+	if err != nil {
+		return records.Elem(), err
+	}
+	query = qi.(*gorm.DB)
+
+	order, _ := e.arg(s, "order").(string)
+	sort, _ := e.arg(s, "sort").(string)
+	sortDirection, _ := e.arg(s, "sortDirection").(string)
+
+	field, isDesc, isValid := helpers.ParseUrlQueryOrder(order, sort, sortDirection)
+	if isValid {
+		column := t.getColumn(field)
+		if column == "" {
+			return records.Elem(), fmt.Errorf("invalid sort field %q", field)
+		}
+
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: isDesc})
+	}
+
+	err = query.Find(records.Interface()).Error
+	return records.Elem(), err
+}
+
+func (e *executor) context() context.Context {
+	if e.opts.Context != nil {
+		return e.opts.Context
+	}
+	return context.Background()
+}
+
+// columns to select for the selection set, with primary and relation keys
+func (e *executor) columns(t *ObjectType, sels []*Selection, extra string) []string {
+	columns := []string{}
+	seen := map[string]bool{}
+
+	add := func(c string) {
+		if c != "" && !seen[c] {
+			seen[c] = true
+			columns = append(columns, c)
+		}
+	}
+
+	for _, f := range t.schema.PrimaryFields {
+		add(f.DBName)
+	}
+
+	add(extra)
+
+	for _, s := range sels {
+		f := t.Fields[s.Name]
+		if f == nil {
+			continue
+		}
+
+		if f.DBField != nil {
+			add(f.DBField.DBName)
+			continue
+		}
+
+		own, _ := relationKeys(f.Relation)
+		add(own.DBName)
+	}
+
+	return columns
+}
+
+// relationKeys returns the field in the own model and the field in the related model
+func relationKeys(rel *schema.Relationship) (own *schema.Field, target *schema.Field) {
+	ref := rel.References[0]
+	if ref.OwnPrimaryKey {
+		return ref.PrimaryKey, ref.ForeignKey
+	}
+	return ref.ForeignKey, ref.PrimaryKey
+}
+
+func (e *executor) resolveObjects(t *ObjectType, records reflect.Value, sels []*Selection, path ...interface{}) []interface{} {
+	n := records.Len()
+	items := make([]interface{}, n)
+
+	// relations are loaded once for all records, avoiding N+1 queries
+	relations := make(map[string][]interface{})
+	for _, s := range sels {
+		f := t.Fields[s.Name]
+		if f != nil && f.Relation != nil {
+			relations[s.ResponseKey()] = e.loadRelation(f, records, s, childPath(path, s.ResponseKey())...)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		base := toMap(records.Index(i).Addr().Interface())
+		obj := make(map[string]interface{}, len(sels))
+
+		for _, s := range sels {
+			key := s.ResponseKey()
+
+			if s.Name == "__typename" {
+				obj[key] = t.TypeName
+				continue
+			}
+
+			f := t.Fields[s.Name]
+			if f.DBField != nil {
+				obj[key] = base[f.Name]
+			} else {
+				obj[key] = relations[key][i]
+			}
+		}
+
+		items[i] = obj
+	}
+
+	return items
+}
+
+func (e *executor) loadRelation(f *Field, parents reflect.Value, s *Selection, path ...interface{}) []interface{} {
+	n := parents.Len()
+	results := make([]interface{}, n)
+
+	if f.IsList {
+		for i := range results {
+			results[i] = []interface{}{}
+		}
+	}
+
+	if n == 0 {
+		return results
+	}
+
+	if !e.can(f.Target) {
+		e.addError(errors.New("Forbidden"), path...)
+		return make([]interface{}, n)
+	}
+
+	own, target := relationKeys(f.Relation)
+	ctx := e.context()
+
+	parentKeys := make([]string, n)
+	keys := []interface{}{}
+	seen := map[string]bool{}
+
+	for i := 0; i < n; i++ {
+		v, zero := own.ValueOf(ctx, parents.Index(i))
+		if zero {
+			continue
+		}
+
+		k := keyString(v)
+		parentKeys[i] = k
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, reflect.Indirect(reflect.ValueOf(v)).Interface())
+		}
+	}
+
+	if len(keys) == 0 {
+		return results
+	}
+
+	t := f.Target
+	pk := clause.Column{Name: t.schema.PrioritizedPrimaryField.DBName}
+	columns := e.columns(t, s.SelectionSet, target.DBName)
+	children := reflect.New(reflect.SliceOf(t.modelType))
+	query := e.opts.DB.WithContext(ctx).
+		Model(reflect.New(t.modelType).Interface()).
+		Where(clause.IN{Column: clause.Column{Name: target.DBName}, Values: keys})
+
+	if f.IsList {
+		// has-many relations are limited per parent with one window function in the same batched query,
+		// so one parent can't load one whole table
+		vars := []interface{}{}
+		for _, c := range columns {
+			vars = append(vars, clause.Column{Name: c})
+		}
+		vars = append(vars, clause.Column{Name: target.DBName}, pk)
+
+		query = e.opts.DB.WithContext(ctx).
+			Unscoped().
+			Table("(?) AS catu_children", query.Select(strings.Repeat("?, ", len(columns))+
+				"ROW_NUMBER() OVER (PARTITION BY ? ORDER BY ?) AS catu_row", vars...)).
+			Select(columns).
+			Where("catu_row <= ?", e.limit(s))
+	} else {
+		query = query.Select(columns)
+	}
+
+	err := query.Order(clause.OrderByColumn{Column: pk}).Find(children.Interface()).Error
+	if err != nil {
+		e.addError(err, path...)
+		return results
+	}
+
+	childItems := e.resolveObjects(t, children.Elem(), s.SelectionSet, path...)
+
+	grouped := make(map[string][]interface{})
+	for j := range childItems {
+		v, _ := target.ValueOf(ctx, children.Elem().Index(j))
+		k := keyString(v)
+		grouped[k] = append(grouped[k], childItems[j])
+	}
+
+	for i := 0; i < n; i++ {
+		items := grouped[parentKeys[i]]
+		if parentKeys[i] == "" || len(items) == 0 {
+			continue
+		}
+
+		if f.IsList {
+			results[i] = items
+		} else {
+			results[i] = items[0]
+		}
+	}
+
+	return results
+}
+
+// toMap converts one record to the same JSON representation used in REST responses
+func toMap(record interface{}) map[string]interface{} {
+	m := make(map[string]interface{})
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		return m
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	d.Decode(&m)
+
+	return m
+}
+
+func childPath(path []interface{}, key string) []interface{} {
+	p := make([]interface{}, len(path), len(path)+1)
+	copy(p, path)
+	return append(p, key)
+}
+
+func keyString(v interface{}) string {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if !rv.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(rv.Interface())
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case float64:
+		return int64(n), true
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	case string:
+		i, err := strconv.ParseInt(n, 10, 64)
+		return i, err == nil
+	}
+
+	return 0, false
+}
@@ -0,0 +1,302 @@
+package graphql_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-catupiry/catu"
+	"github.com/go-catupiry/catu/acl"
+	"github.com/go-catupiry/catu/graphql"
+	"github.com/go-catupiry/catu/helpers"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Author struct {
+	ID       uint64    `gorm:"primaryKey" json:"id" filter:"param:id;type:number"`
+	Name     string    `json:"name" filter:"param:name;type:string"`
+	Secret   string    `json:"-"`
+	Articles []Article `json:"articles"`
+}
+
+type Article struct {
+	ID       uint64  `gorm:"primaryKey" json:"id" filter:"param:id;type:number"`
+	Title    string  `json:"title" filter:"param:title;type:string"`
+	Views    int64   `json:"views"`
+	AuthorID uint64  `json:"authorId" filter:"param:author_id;type:number"`
+	Author   *Author `json:"author"`
+}
+
+type articleListJSON struct {
+	Records []Article `json:"article"`
+}
+
+// REST controller with the same query semantics used in catu apps
+type articleController struct{}
+
+func (ctl *articleController) Query(c echo.Context) error {
+	ctx := c.(*catu.RequestContext)
+	records := []Article{}
+
+	query := ctx.App.GetDB().Model(&Article{})
+	queryI, err := ctx.Query.SetDatabaseQueryForModel(query, &Article{})
+	if err != nil {
+		return err
+	}
+	query = queryI.(*gorm.DB)
+
+	field, isDesc, isValid := helpers.ParseUrlQueryOrder(c.QueryParam("order"), c.QueryParam("sort"), c.QueryParam("sortDirection"))
+	if isValid {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: field}, Desc: isDesc})
+	}
+
+	err = query.Limit(ctx.GetLimit()).Offset(ctx.GetOffset()).Find(&records).Error
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &articleListJSON{Records: records})
+}
+
+func (ctl *articleController) Create(c echo.Context) error  { return nil }
+func (ctl *articleController) Count(c echo.Context) error   { return nil }
+func (ctl *articleController) FindOne(c echo.Context) error { return nil }
+func (ctl *articleController) Update(c echo.Context) error  { return nil }
+func (ctl *articleController) Delete(c echo.Context) error  { return nil }
+
+func newTestApp(t *testing.T) (catu.App, *int) {
+	t.Setenv("DB_URI", filepath.Join(t.TempDir(), "test.sqlite"))
+	t.Setenv("TEMPLATE_DISABLE", "true")
+	t.Setenv("GRAPHQL_MAX_DEPTH", "4")
+
+	app := catu.Init(&catu.AppOptions{})
+	app.RegisterPlugin(graphql.NewPlugin())
+
+	app.SetModel("articles", &Article{})
+	app.SetModel("authors", &Author{})
+	app.SetResource("articles", &articleController{}, app.GetRouterGroup("api").Group("/articles"))
+	app.SetResource("authors", &articleController{}, app.GetRouterGroup("api").Group("/authors"))
+
+	assert.Nil(t, app.Bootstrap())
+
+	db := app.GetDB()
+	assert.Nil(t, db.AutoMigrate(&Author{}, &Article{}))
+
+	authors := []Author{{Name: "Alberto"}, {Name: "Maria"}, {Name: "Ana", Secret: "s3cr3t"}}
+	assert.Nil(t, db.Create(&authors).Error)
+
+	articles := []Article{
+		{Title: "Go generics", Views: 10, AuthorID: authors[0].ID},
+		{Title: "Go modules", Views: 30, AuthorID: authors[1].ID},
+		{Title: "Rust", Views: 20, AuthorID: authors[0].ID},
+		{Title: "Go templates", Views: 5, AuthorID: authors[2].ID},
+	}
+	assert.Nil(t, db.Create(&articles).Error)
+
+	app.SetRole("unAuthenticated", acl.Role{
+		Name:        "unAuthenticated",
+		Permissions: []string{"articles.find", "authors.find"},
+	})
+
+	queryCount := 0
+	db.Callback().Query().After("gorm:query").Register("test:count_queries", func(tx *gorm.DB) {
+		// the subqueries are built with dry runs
+		if !tx.DryRun {
+			queryCount++
+		}
+	})
+
+	return app, &queryCount
+}
+
+func doQuery(t *testing.T, app catu.App, query string, variables map[string]interface{}) (int, map[string]interface{}) {
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	app.GetRouter().ServeHTTP(rec, req)
+
+	res := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	return rec.Code, res
+}
+
+func TestGraphQLCompareWithREST(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	for _, tc := range []struct {
+		name      string
+		restQuery string
+		filter    map[string]interface{}
+		args      string
+	}{
+		{"contains filter sorted", "title_starts-with=Go&sort=views&sortDirection=ASC", map[string]interface{}{"title_starts-with": "Go"}, `sort: "views", sortDirection: "ASC"`},
+		{"equal filter", "author_id=1", map[string]interface{}{"author_id": "1"}, ``},
+		{"pagination", "limit=2&page=2&sort=id&sortDirection=ASC", nil, `limit: 2, page: 2, sort: "id", sortDirection: "ASC"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/articles?"+tc.restQuery, nil)
+			rec := httptest.NewRecorder()
+			app.GetRouter().ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			rest := articleListJSON{}
+			assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &rest))
+			assert.NotEmpty(t, rest.Records)
+
+			args := "filter: $filter"
+			if tc.args != "" {
+				args += ", " + tc.args
+			}
+
+			code, res := doQuery(t, app, `query Q($filter: ArticleFilter) { articles(`+args+`) { id title views authorId } }`, map[string]interface{}{"filter": tc.filter})
+			assert.Equal(t, http.StatusOK, code)
+			assert.Nil(t, res["errors"])
+
+			items := res["data"].(map[string]interface{})["articles"].([]interface{})
+			assert.Equal(t, len(rest.Records), len(items))
+
+			for i, item := range items {
+				obj := item.(map[string]interface{})
+				assert.Equal(t, float64(rest.Records[i].ID), obj["id"])
+				assert.Equal(t, rest.Records[i].Title, obj["title"])
+				assert.Equal(t, float64(rest.Records[i].Views), obj["views"])
+			}
+		})
+	}
+}
+
+func TestGraphQLRelationsBatching(t *testing.T) {
+	app, queryCount := newTestApp(t)
+
+	*queryCount = 0
+	code, res := doQuery(t, app, `{
+		list: articles(sort: "id", sortDirection: "ASC") { id author { name articles { title } } }
+		author(id: 1) { __typename name }
+	}`, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, res["errors"])
+
+	// articles + authors + authors.articles + author by id
+	assert.Equal(t, 4, *queryCount)
+
+	data := res["data"].(map[string]interface{})
+	items := data["list"].([]interface{})
+	assert.Equal(t, 4, len(items))
+
+	first := items[0].(map[string]interface{})
+	author := first["author"].(map[string]interface{})
+	assert.Equal(t, "Alberto", author["name"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"title": "Go generics"},
+		map[string]interface{}{"title": "Rust"},
+	}, author["articles"])
+
+	assert.Equal(t, map[string]interface{}{"__typename": "Author", "name": "Alberto"}, data["author"])
+}
+
+func TestGraphQLRelationsLimit(t *testing.T) {
+	t.Setenv("PAGER_LIMIT_MAX", "3")
+	app, queryCount := newTestApp(t)
+	assert.Nil(t, app.GetDB().Create(&[]Article{
+		{Title: "Go tests", AuthorID: 1},
+		{Title: "Go errors", AuthorID: 1},
+	}).Error)
+
+	titles := func(res map[string]interface{}) map[string][]interface{} {
+		list := map[string][]interface{}{}
+		for _, item := range res["data"].(map[string]interface{})["authors"].([]interface{}) {
+			author := item.(map[string]interface{})
+			for _, article := range author["articles"].([]interface{}) {
+				list[author["name"].(string)] = append(list[author["name"].(string)], article.(map[string]interface{})["title"])
+			}
+		}
+		return list
+	}
+
+	t.Run("Should limit the nested lists per parent", func(t *testing.T) {
+		*queryCount = 0
+		code, res := doQuery(t, app, `{ authors(sort: "id", sortDirection: "ASC") { name articles(limit: 1) { title } } }`, nil)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Nil(t, res["errors"])
+		assert.Equal(t, 2, *queryCount)
+
+		assert.Equal(t, map[string][]interface{}{
+			"Alberto": {"Go generics"},
+			"Maria":   {"Go modules"},
+			"Ana":     {"Go templates"},
+		}, titles(res))
+	})
+
+	t.Run("Should cap the nested list limit with PAGER_LIMIT_MAX", func(t *testing.T) {
+		code, res := doQuery(t, app, `{ authors(sort: "id", sortDirection: "ASC") { name articles(limit: 10) { title } } }`, nil)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Nil(t, res["errors"])
+
+		assert.Equal(t, []interface{}{"Go generics", "Rust", "Go tests"}, titles(res)["Alberto"])
+	})
+}
+
+func TestGraphQLValidation(t *testing.T) {
+	app, queryCount := newTestApp(t)
+
+	t.Run("Should reject fields hidden from json", func(t *testing.T) {
+		code, res := doQuery(t, app, `{ authors { secret } }`, nil)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Contains(t, res["errors"].([]interface{})[0].(map[string]interface{})["message"], `Cannot query field "secret"`)
+	})
+
+	t.Run("Should reject deep queries", func(t *testing.T) {
+		*queryCount = 0
+		code, res := doQuery(t, app, `{ articles { author { articles { author { name } } } } }`, nil)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Contains(t, res["errors"].([]interface{})[0].(map[string]interface{})["message"], "depth")
+		assert.Equal(t, 0, *queryCount)
+	})
+
+	t.Run("Should reject complex queries", func(t *testing.T) {
+		code, res := doQuery(t, app, `{ articles(limit: 50) { id author { articles { id title views authorId } } } }`, nil)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Contains(t, res["errors"].([]interface{})[0].(map[string]interface{})["message"], "complexity")
+	})
+
+	t.Run("Should reject invalid sort fields", func(t *testing.T) {
+		code, res := doQuery(t, app, `{ articles(sort: "id; drop table articles") { id } }`, nil)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Nil(t, res["data"].(map[string]interface{})["articles"])
+		assert.Contains(t, res["errors"].([]interface{})[0].(map[string]interface{})["message"], "invalid sort field")
+	})
+
+	t.Run("Should reject mutations", func(t *testing.T) {
+		code, _ := doQuery(t, app, `mutation { createArticle { id } }`, nil)
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+func TestGraphQLPermissions(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	app.SetRole("unAuthenticated", acl.Role{
+		Name:        "unAuthenticated",
+		Permissions: []string{"articles.find"},
+	})
+
+	code, res := doQuery(t, app, `{ articles(limit: 1) { title author { name } } authors { name } }`, nil)
+	assert.Equal(t, http.StatusOK, code)
+
+	data := res["data"].(map[string]interface{})
+	assert.Nil(t, data["authors"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"title": "Go generics", "author": nil},
+	}, data["articles"])
+
+	errs := res["errors"].([]interface{})
+	assert.Equal(t, 2, len(errs))
+	assert.Equal(t, "Forbidden", errs[0].(map[string]interface{})["message"])
+}
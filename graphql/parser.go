@@ -0,0 +1,526 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Document is one parsed GraphQL request document.
+// Only query operations without fragments and directives are supported
+type Document struct {
+	Operations []*Operation
+}
+
+type Operation struct {
+	Type         string
+	Name         string
+	Variables    []*VariableDefinition
+	SelectionSet []*Selection
+}
+
+type VariableDefinition struct {
+	Name         string
+	Type         string
+	DefaultValue Value
+}
+
+// Selection is one field selected in a selection set
+type Selection struct {
+	Alias        string
+	Name         string
+	Arguments    map[string]Value
+	SelectionSet []*Selection
+}
+
+// ResponseKey returns the alias if set or the field name
+func (s *Selection) ResponseKey() string {
+	if s.Alias != "" {
+		return s.Alias
+	}
+	return s.Name
+}
+
+// Value is one argument value, resolved with the request variables
+type Value interface {
+	Resolve(vars map[string]interface{}) interface{}
+}
+
+type literalValue struct{ v interface{} }
+
+func (l literalValue) Resolve(vars map[string]interface{}) interface{} { return l.v }
+
+type variableValue struct{ name string }
+
+func (v variableValue) Resolve(vars map[string]interface{}) interface{} { return vars[v.name] }
+
+type listValue []Value
+
+func (l listValue) Resolve(vars map[string]interface{}) interface{} {
+	out := make([]interface{}, len(l))
+	for i := range l {
+		out[i] = l[i].Resolve(vars)
+	}
+	return out
+}
+
+type objectValue map[string]Value
+
+func (o objectValue) Resolve(vars map[string]interface{}) interface{} {
+	out := make(map[string]interface{}, len(o))
+	for k, v := range o {
+		out[k] = v.Resolve(vars)
+	}
+	return out
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunctuator
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+type parser struct {
+	src string
+	pos int
+	tok token
+}
+
+// Parse one GraphQL query document
+func Parse(src string) (*Document, error) {
+	p := &parser{src: src}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	doc := &Document{}
+	for p.tok.kind != tokenEOF {
+		op, err := p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		doc.Operations = append(doc.Operations, op)
+	}
+
+	if len(doc.Operations) == 0 {
+		return nil, p.errorf("empty document")
+	}
+
+	return doc, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("syntax error at position %d: %s", p.tok.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) next() error {
+	// skip ignored tokens: white spaces, commas and comments
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+			continue
+		}
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		break
+	}
+
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokenEOF, pos: start}
+		return nil
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case strings.IndexByte("!$():=@[]{}|", c) >= 0:
+		p.pos++
+		p.tok = token{kind: tokenPunctuator, value: string(c), pos: start}
+	case c == '.':
+		if !strings.HasPrefix(p.src[p.pos:], "...") {
+			return fmt.Errorf("syntax error at position %d: unexpected character %q", start, c)
+		}
+		p.pos += 3
+		p.tok = token{kind: tokenPunctuator, value: "...", pos: start}
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokenName, value: p.src[start:p.pos], pos: start}
+	case c == '-' || isDigit(c):
+		p.pos++
+		kind := tokenInt
+		for p.pos < len(p.src) {
+			d := p.src[p.pos]
+			if isDigit(d) {
+				p.pos++
+			} else if d == '.' || d == 'e' || d == 'E' || ((d == '+' || d == '-') && kind == tokenFloat) {
+				kind = tokenFloat
+				p.pos++
+			} else {
+				break
+			}
+		}
+		p.tok = token{kind: kind, value: p.src[start:p.pos], pos: start}
+	case c == '"':
+		v, err := p.readString()
+		if err != nil {
+			return err
+		}
+		p.tok = token{kind: tokenString, value: v, pos: start}
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		return fmt.Errorf("syntax error at position %d: unexpected character %q", start, r)
+	}
+
+	return nil
+}
+
+func (p *parser) readString() (string, error) {
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos += 2
+		case '\n':
+			return "", fmt.Errorf("syntax error at position %d: unterminated string", start)
+		case '"':
+			p.pos++
+			v, err := strconv.Unquote(p.src[start:p.pos])
+			if err != nil {
+				return "", fmt.Errorf("syntax error at position %d: invalid string", start)
+			}
+			return v, nil
+		default:
+			p.pos++
+		}
+	}
+
+	return "", fmt.Errorf("syntax error at position %d: unterminated string", start)
+}
+
+func (p *parser) peek(value string) bool {
+	return p.tok.kind == tokenPunctuator && p.tok.value == value
+}
+
+func (p *parser) expect(value string) error {
+	if !p.peek(value) {
+		return p.errorf("expected %q, found %q", value, p.tok.value)
+	}
+	return p.next()
+}
+
+func (p *parser) expectName() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.errorf("expected name, found %q", p.tok.value)
+	}
+	name := p.tok.value
+	return name, p.next()
+}
+
+func (p *parser) parseOperation() (*Operation, error) {
+	op := &Operation{Type: "query"}
+
+	if p.peek("{") {
+		sel, err := p.parseSelectionSet()
+		op.SelectionSet = sel
+		return op, err
+	}
+
+	if p.tok.kind != tokenName {
+		return nil, p.errorf("unexpected %q", p.tok.value)
+	}
+
+	switch p.tok.value {
+	case "query":
+	case "mutation", "subscription":
+		return nil, p.errorf("%s operations are not supported", p.tok.value)
+	case "fragment":
+		return nil, p.errorf("fragments are not supported")
+	default:
+		return nil, p.errorf("unexpected %q", p.tok.value)
+	}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind == tokenName {
+		op.Name = p.tok.value
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek("(") {
+		vars, err := p.parseVariableDefinitions()
+		if err != nil {
+			return nil, err
+		}
+		op.Variables = vars
+	}
+
+	if p.peek("@") {
+		return nil, p.errorf("directives are not supported")
+	}
+
+	sel, err := p.parseSelectionSet()
+	op.SelectionSet = sel
+	return op, err
+}
+
+func (p *parser) parseVariableDefinitions() ([]*VariableDefinition, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	var defs []*VariableDefinition
+	for !p.peek(")") {
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		typ, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+
+		def := &VariableDefinition{Name: name, Type: typ}
+		if p.peek("=") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			def.DefaultValue, err = p.parseValue(true)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		defs = append(defs, def)
+	}
+
+	return defs, p.next()
+}
+
+func (p *parser) parseType() (string, error) {
+	var typ string
+
+	if p.peek("[") {
+		if err := p.next(); err != nil {
+			return "", err
+		}
+		inner, err := p.parseType()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		typ = "[" + inner + "]"
+	} else {
+		name, err := p.expectName()
+		if err != nil {
+			return "", err
+		}
+		typ = name
+	}
+
+	if p.peek("!") {
+		typ += "!"
+		return typ, p.next()
+	}
+
+	return typ, nil
+}
+
+func (p *parser) parseSelectionSet() ([]*Selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var selections []*Selection
+	for !p.peek("}") {
+		if p.tok.kind == tokenEOF {
+			return nil, p.errorf("unexpected end of document")
+		}
+		if p.peek("...") {
+			return nil, p.errorf("fragments are not supported")
+		}
+
+		sel, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, sel)
+	}
+
+	if len(selections) == 0 {
+		return nil, p.errorf("empty selection set")
+	}
+
+	return selections, p.next()
+}
+
+func (p *parser) parseField() (*Selection, error) {
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+
+	sel := &Selection{Name: name}
+
+	if p.peek(":") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		sel.Alias = name
+		sel.Name, err = p.expectName()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek("(") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		sel.Arguments = make(map[string]Value)
+		for !p.peek(")") {
+			argName, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			sel.Arguments[argName], err = p.parseValue(false)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek("@") {
+		return nil, p.errorf("directives are not supported")
+	}
+
+	if p.peek("{") {
+		sel.SelectionSet, err = p.parseSelectionSet()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sel, nil
+}
+
+func (p *parser) parseValue(isConst bool) (Value, error) {
+	tok := p.tok
+
+	switch {
+	case p.peek("$"):
+		if isConst {
+			return nil, p.errorf("unexpected variable")
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		return variableValue{name: name}, err
+	case p.peek("["):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		list := listValue{}
+		for !p.peek("]") {
+			v, err := p.parseValue(isConst)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, p.next()
+	case p.peek("{"):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		obj := objectValue{}
+		for !p.peek("}") {
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			obj[name], err = p.parseValue(isConst)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return obj, p.next()
+	case tok.kind == tokenInt:
+		v, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, p.errorf("invalid int %q", tok.value)
+		}
+		return literalValue{v}, p.next()
+	case tok.kind == tokenFloat:
+		v, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, p.errorf("invalid float %q", tok.value)
+		}
+		return literalValue{v}, p.next()
+	case tok.kind == tokenString:
+		return literalValue{tok.value}, p.next()
+	case tok.kind == tokenName:
+		switch tok.value {
+		case "true":
+			return literalValue{true}, p.next()
+		case "false":
+			return literalValue{false}, p.next()
+		case "null":
+			return literalValue{nil}, p.next()
+		default:
+			// enum values are handled as strings
+			return literalValue{tok.value}, p.next()
+		}
+	}
+
+	return nil, p.errorf("unexpected %q", tok.value)
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
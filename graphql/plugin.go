@@ -0,0 +1,164 @@
+// Package graphql exposes one read only /graphql endpoint with a schema generated from the app resources.
+//
+// Resources registered with App.SetResource that have one model registered with App.SetModel
+// using the same name are exposed as two query fields, the list (ex: "articles") with the same
+// filter, sort and pagination arguments of the REST Query routes and the single record by id (ex: "article").
+// Relations between exposed models are loaded in batches, one query for each relation field.
+// List relations accept one "limit" argument, per parent record and capped by PAGER_LIMIT_MAX, and use one
+// window function, so MySQL needs the version 8 or newer.
+//
+// Mutations, fragments and directives are not supported.
+package graphql
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/go-catupiry/catu"
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+type Plugin struct {
+	Name string
+	// Endpoint path, default: /graphql
+	Path string
+	// Max selection depth, from GRAPHQL_MAX_DEPTH, default: 5
+	MaxDepth int
+	// Max query complexity, from GRAPHQL_MAX_COMPLEXITY, default: 1000
+	MaxComplexity int
+	// Permission overrides by resource name, the default permission is "[resourceName].find"
+	Permissions map[string]string
+
+	app    catu.App
+	schema *Schema
+	mu     sync.RWMutex
+}
+
+func NewPlugin() *Plugin {
+	return &Plugin{
+		Name:        "graphql",
+		Path:        "/graphql",
+		Permissions: make(map[string]string),
+	}
+}
+
+func (p *Plugin) GetName() string {
+	return p.Name
+}
+
+func (p *Plugin) Init(app catu.App) error {
+	logrus.WithFields(logrus.Fields{
+		"PluginName": p.Name,
+	}).Debug("graphql.Plugin.Init Running init")
+
+	p.app = app
+	cfg := app.GetConfiguration()
+
+	if p.MaxDepth == 0 {
+		p.MaxDepth = cfg.GetIntF("GRAPHQL_MAX_DEPTH", 5)
+	}
+
+	if p.MaxComplexity == 0 {
+		p.MaxComplexity = cfg.GetIntF("GRAPHQL_MAX_COMPLEXITY", 1000)
+	}
+
+	app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+		app.GetRouter().GET(p.Path, p.Handler)
+		app.GetRouter().POST(p.Path, p.Handler)
+		return nil
+	}), event.Normal)
+
+	// resources and models are registered until the bindRoutes event
+	app.GetEvents().On("bootstrap", event.ListenerFunc(func(e event.Event) error {
+		return p.BuildSchema()
+	}), event.Normal)
+
+	return nil
+}
+
+// BuildSchema (re)generate the schema from the app resources
+func (p *Plugin) BuildSchema() error {
+	s, err := BuildSchema(p.app, p.app.GetDB(), p.Permissions)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.schema = s
+	p.mu.Unlock()
+
+	return nil
+}
+
+func (p *Plugin) GetSchema() *Schema {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.schema
+}
+
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Handler - GET or POST /graphql
+func (p *Plugin) Handler(c echo.Context) error {
+	req := request{}
+
+	if c.Request().Method == http.MethodGet {
+		req.Query = c.QueryParam("query")
+		req.OperationName = c.QueryParam("operationName")
+
+		if v := c.QueryParam("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				return c.JSON(http.StatusBadRequest, &Response{Errors: []*Error{{Message: "invalid variables"}}})
+			}
+		}
+	} else if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, &Response{Errors: []*Error{{Message: "invalid request body"}}})
+	}
+
+	s := p.GetSchema()
+	if s == nil {
+		return errors.New("graphql.Handler schema not built, the app should be bootstrapped")
+	}
+
+	cfg := p.app.GetConfiguration()
+
+	res, err := s.Execute(&ExecuteOpts{
+		Context:       c.Request().Context(),
+		DB:            p.app.GetDB(),
+		Query:         req.Query,
+		OperationName: req.OperationName,
+		Variables:     req.Variables,
+		Can:           p.permissionChecker(c),
+		DefaultLimit:  cfg.GetInt64F("PAGER_LIMIT", 20),
+		LimitMax:      cfg.GetInt64F("PAGER_LIMIT_MAX", 50),
+		MaxDepth:      p.MaxDepth,
+		MaxComplexity: p.MaxComplexity,
+	})
+	if err != nil {
+		if ve, ok := err.(*ValidationError); ok {
+			return c.JSON(http.StatusBadRequest, &Response{Errors: []*Error{{Message: ve.Message}}})
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// permissionChecker uses the same roles and permissions of the REST routes
+func (p *Plugin) permissionChecker(c echo.Context) func(permission string) bool {
+	if ctx, ok := c.(*catu.RequestContext); ok {
+		return ctx.Can
+	}
+
+	return func(permission string) bool {
+		return p.app.Can(permission, []string{p.app.GetUnauthenticatedRole()})
+	}
+}
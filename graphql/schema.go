@@ -0,0 +1,170 @@
+package graphql
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/go-catupiry/catu"
+	"github.com/jinzhu/inflection"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Schema with one object type for each registered resource with a model
+type Schema struct {
+	Types map[string]*ObjectType
+	// root query fields, like "articles" (list) and "article" (single by id)
+	QueryFields map[string]*RootField
+}
+
+// ObjectType is one resource exposed in the GraphQL schema
+type ObjectType struct {
+	// Resource name
+	Name string
+	// GraphQL type name, the model struct name
+	TypeName string
+	// Permission checked with App.Can before read this type, default: "[resourceName].find"
+	Permission string
+	Fields     map[string]*Field
+
+	modelType reflect.Type
+	schema    *schema.Schema
+}
+
+// Field is one scalar or relation field of an ObjectType
+type Field struct {
+	Name string
+	// Set for scalar fields
+	DBField *schema.Field
+	// Set for relation fields
+	Relation *schema.Relationship
+	Target   *ObjectType
+	IsList   bool
+}
+
+// RootField is one query root field
+type RootField struct {
+	Name   string
+	Type   *ObjectType
+	IsList bool
+}
+
+// BuildSchema from app resources with models registered with the same name in App.SetModel
+func BuildSchema(app catu.App, db *gorm.DB, permissions map[string]string) (*Schema, error) {
+	s := &Schema{
+		Types:       make(map[string]*ObjectType),
+		QueryFields: make(map[string]*RootField),
+	}
+
+	byModel := make(map[reflect.Type]*ObjectType)
+
+	for _, resource := range app.ListResources() {
+		name := resource.Name
+		model := app.GetModel(name)
+		if model == nil {
+			continue
+		}
+
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, errors.Wrap(err, "graphql.BuildSchema error on parse model for resource "+name)
+		}
+
+		t := &ObjectType{
+			Name:       name,
+			TypeName:   stmt.Schema.Name,
+			Permission: name + ".find",
+			Fields:     make(map[string]*Field),
+			modelType:  stmt.Schema.ModelType,
+			schema:     stmt.Schema,
+		}
+
+		if p, ok := permissions[name]; ok {
+			t.Permission = p
+		}
+
+		for _, f := range stmt.Schema.Fields {
+			if f.DBName == "" || !f.Readable {
+				continue
+			}
+
+			fieldName := jsonName(f.StructField)
+			if fieldName == "" {
+				continue
+			}
+
+			t.Fields[fieldName] = &Field{Name: fieldName, DBField: f}
+		}
+
+		s.Types[name] = t
+		byModel[t.modelType] = t
+
+		single := inflection.Singular(name)
+		if single == name {
+			single = name + "ById"
+		}
+
+		s.QueryFields[name] = &RootField{Name: name, Type: t, IsList: true}
+		s.QueryFields[single] = &RootField{Name: single, Type: t}
+	}
+
+	// relations are only exposed if the related model is also one resource
+	for _, t := range s.Types {
+		for _, rel := range t.schema.Relationships.Relations {
+			if len(rel.References) != 1 || rel.Polymorphic != nil {
+				continue
+			}
+
+			if rel.Type != schema.HasOne && rel.Type != schema.HasMany && rel.Type != schema.BelongsTo {
+				continue
+			}
+
+			target := byModel[rel.FieldSchema.ModelType]
+			if target == nil {
+				continue
+			}
+
+			fieldName := jsonName(rel.Field.StructField)
+			if fieldName == "" {
+				continue
+			}
+
+			t.Fields[fieldName] = &Field{
+				Name:     fieldName,
+				Relation: rel,
+				Target:   target,
+				IsList:   rel.Type == schema.HasMany,
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// getColumn returns the db column for one field json name or db column name, used in sort params
+func (t *ObjectType) getColumn(name string) string {
+	if f, ok := t.Fields[name]; ok && f.DBField != nil {
+		return f.DBField.DBName
+	}
+
+	if f, ok := t.schema.FieldsByDBName[name]; ok {
+		return f.DBName
+	}
+
+	return ""
+}
+
+func jsonName(f reflect.StructField) string {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		name = f.Name
+	}
+
+	return name
+}
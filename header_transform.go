@@ -0,0 +1,301 @@
+package catu
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"text/template"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Headers never changed by the header transformations unless the rule sets allowSensitive
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// HeaderTransformConfig is the headers.json file format, ex:
+//
+//	{"rules": [
+//	  {"group": "/", "response": {"remove": ["X-Powered-By"]}},
+//	  {"group": "/legacy", "request": {"rename": {"X-Old-Token": "X-Api-Token"}}, "response": {"set": {"X-Frame-Options": "DENY"}}}
+//	]}
+type HeaderTransformConfig struct {
+	Rules []HeaderTransformRule `json:"rules"`
+}
+
+type HeaderTransformRule struct {
+	// Path prefix, empty or "/" for all routes
+	Group    string           `json:"group"`
+	Request  HeaderTransforms `json:"request"`
+	Response HeaderTransforms `json:"response"`
+	// Allow changes in Authorization and Cookie headers
+	AllowSensitive bool `json:"allowSensitive"`
+}
+
+// HeaderTransforms are applied in the order: remove, rename, set and add.
+// Values are templates with the fields .RequestID, .Env, .Host, .Method and .Path
+type HeaderTransforms struct {
+	Remove []string          `json:"remove"`
+	Rename map[string]string `json:"rename"`
+	Set    map[string]string `json:"set"`
+	Add    map[string]string `json:"add"`
+}
+
+type HeaderTransformData struct {
+	RequestID string
+	Env       string
+	Host      string
+	Method    string
+	Path      string
+}
+
+type headerValue struct {
+	static string
+	tpl    *template.Template
+}
+
+type headerPair struct {
+	name  string
+	value *headerValue
+}
+
+type compiledHeaderTransforms struct {
+	remove []string
+	rename [][2]string
+	set    []headerPair
+	add    []headerPair
+}
+
+type compiledHeaderRule struct {
+	group    string
+	request  *compiledHeaderTransforms
+	response *compiledHeaderTransforms
+}
+
+// HeaderTransformer is one middleware with config driven request and response header changes.
+// Rules are compiled on load and can be reloaded without restart with Reload
+type HeaderTransformer struct {
+	// Rules file, default: HEADER_TRANSFORMS_FILE configuration or headers.json
+	File string
+	Env  string
+
+	rules atomic.Value // []*compiledHeaderRule
+}
+
+func NewHeaderTransformer(app App) *HeaderTransformer {
+	h := &HeaderTransformer{
+		File: app.GetConfiguration().GetF("HEADER_TRANSFORMS_FILE", "headers.json"),
+		Env:  app.GetConfiguration().Get("GO_ENV"),
+	}
+	h.rules.Store([]*compiledHeaderRule{})
+	return h
+}
+
+// Reload the rules from the File, a missing file clears the rules.
+// Invalid files return one error and keep the current rules
+func (h *HeaderTransformer) Reload() error {
+	data, err := ioutil.ReadFile(h.File)
+	if err != nil {
+		if os.IsNotExist(err) {
+			h.rules.Store([]*compiledHeaderRule{})
+			return nil
+		}
+		return errors.Wrap(err, "catu.HeaderTransformer.Reload error on read "+h.File)
+	}
+
+	cfg := HeaderTransformConfig{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return errors.Wrap(err, "catu.HeaderTransformer.Reload invalid json in "+h.File)
+	}
+
+	return h.Load(&cfg)
+}
+
+// Load compiles and replaces the rules
+func (h *HeaderTransformer) Load(cfg *HeaderTransformConfig) error {
+	rules := []*compiledHeaderRule{}
+
+	for i, r := range cfg.Rules {
+		req, err := compileHeaderTransforms(&r.Request, r.AllowSensitive)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("catu.HeaderTransformer.Load invalid request transforms in rule %d", i))
+		}
+
+		res, err := compileHeaderTransforms(&r.Response, r.AllowSensitive)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("catu.HeaderTransformer.Load invalid response transforms in rule %d", i))
+		}
+
+		group := strings.TrimRight(r.Group, "/")
+
+		rules = append(rules, &compiledHeaderRule{group: group, request: req, response: res})
+	}
+
+	h.rules.Store(rules)
+
+	logrus.WithFields(logrus.Fields{
+		"rules": len(rules),
+	}).Debug("catu.HeaderTransformer.Load rules loaded")
+
+	return nil
+}
+
+func (h *HeaderTransformer) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			rules := h.rules.Load().([]*compiledHeaderRule)
+			if len(rules) == 0 {
+				return next(c)
+			}
+
+			req := c.Request()
+			var data *HeaderTransformData
+			getData := func() *HeaderTransformData {
+				if data == nil {
+					requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+					if requestID == "" {
+						requestID = req.Header.Get(echo.HeaderXRequestID)
+					}
+
+					data = &HeaderTransformData{
+						RequestID: requestID,
+						Env:       h.Env,
+						Host:      req.Host,
+						Method:    req.Method,
+						Path:      req.URL.Path,
+					}
+				}
+				return data
+			}
+
+			matched := []*compiledHeaderRule{}
+			for _, r := range rules {
+				if r.group == "" || req.URL.Path == r.group || strings.HasPrefix(req.URL.Path, r.group+"/") {
+					matched = append(matched, r)
+					r.request.apply(req.Header, getData)
+				}
+			}
+
+			if len(matched) > 0 {
+				c.Response().Before(func() {
+					for _, r := range matched {
+						r.response.apply(c.Response().Header(), getData)
+					}
+				})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func compileHeaderTransforms(t *HeaderTransforms, allowSensitive bool) (*compiledHeaderTransforms, error) {
+	c := &compiledHeaderTransforms{}
+
+	check := func(name string) (string, error) {
+		name = http.CanonicalHeaderKey(name)
+		if !allowSensitive && sensitiveHeaders[name] {
+			return "", errors.New("the " + name + " header is sensitive, set allowSensitive to change it")
+		}
+		return name, nil
+	}
+
+	for _, name := range t.Remove {
+		name, err := check(name)
+		if err != nil {
+			return nil, err
+		}
+		c.remove = append(c.remove, name)
+	}
+
+	for _, from := range sortedKeys(t.Rename) {
+		f, err := check(from)
+		if err != nil {
+			return nil, err
+		}
+		to, err := check(t.Rename[from])
+		if err != nil {
+			return nil, err
+		}
+		c.rename = append(c.rename, [2]string{f, to})
+	}
+
+	compilePairs := func(values map[string]string) ([]headerPair, error) {
+		pairs := []headerPair{}
+		for _, name := range sortedKeys(values) {
+			n, err := check(name)
+			if err != nil {
+				return nil, err
+			}
+
+			v := &headerValue{static: values[name]}
+			if strings.Contains(v.static, "{{") {
+				v.tpl, err = template.New(n).Option("missingkey=error").Parse(v.static)
+				if err != nil {
+					return nil, errors.Wrap(err, "invalid template in header "+n)
+				}
+			}
+
+			pairs = append(pairs, headerPair{name: n, value: v})
+		}
+		return pairs, nil
+	}
+
+	var err error
+	if c.set, err = compilePairs(t.Set); err != nil {
+		return nil, err
+	}
+	if c.add, err = compilePairs(t.Add); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (t *compiledHeaderTransforms) apply(header http.Header, getData func() *HeaderTransformData) {
+	for _, name := range t.remove {
+		delete(header, name)
+	}
+
+	for _, r := range t.rename {
+		if v, ok := header[r[0]]; ok {
+			delete(header, r[0])
+			header[r[1]] = v
+		}
+	}
+
+	for _, p := range t.set {
+		header.Set(p.name, p.value.render(getData))
+	}
+
+	for _, p := range t.add {
+		header.Add(p.name, p.value.render(getData))
+	}
+}
+
+func (v *headerValue) render(getData func() *HeaderTransformData) string {
+	if v.tpl == nil {
+		return v.static
+	}
+
+	buf := bytes.Buffer{}
+	if err := v.tpl.Execute(&buf, getData()); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Warn("catu.HeaderTransformer error on render header value")
+		return ""
+	}
+
+	return buf.String()
+}
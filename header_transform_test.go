@@ -0,0 +1,122 @@
+package catu
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderTransformer(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "headers.json")
+	t.Setenv("HEADER_TRANSFORMS_FILE", file)
+	t.Setenv("GO_ENV", "test")
+
+	assert.Nil(t, ioutil.WriteFile(file, []byte(`{"rules": [
+		{"group": "/", "response": {"remove": ["X-Powered-By"], "set": {"X-Env": "{{.Env}}"}}},
+		{"group": "/legacy", "request": {
+			"rename": {"X-Old-Token": "X-Api-Token"},
+			"remove": ["X-Debug"],
+			"set": {"X-Client": "legacy"},
+			"add": {"X-Trace": "{{.RequestID}}-{{.Method}}"}
+		}, "response": {"set": {"X-Frame-Options": "DENY"}, "add": {"Vary": "X-Client"}}}
+	]}`), 0600))
+
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+
+	handler := func(c echo.Context) error {
+		h := c.Request().Header
+		c.Response().Header().Set("X-Powered-By", "catu")
+		c.Response().Header().Set("Vary", "Accept")
+		return c.String(http.StatusOK, strings.Join([]string{
+			h.Get("X-Old-Token"), h.Get("X-Api-Token"), h.Get("X-Debug"), h.Get("X-Client"), strings.Join(h.Values("X-Trace"), ","),
+		}, "|"))
+	}
+	app.GetRouter().GET("/legacy/items", handler)
+	app.GetRouter().GET("/legacyfoo", handler)
+	app.GetRouter().GET("/items", handler)
+
+	request := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Old-Token", "abc")
+		req.Header.Set("X-Debug", "1")
+		req.Header.Set("X-Trace", "client")
+		req.Header.Set(echo.HeaderXRequestID, "req-1")
+		req.Header.Set("Authorization", "Bearer x")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Should apply group request and response rules", func(t *testing.T) {
+		rec := request("/legacy/items")
+		assert.Equal(t, "|abc||legacy|client,req-1-GET", rec.Body.String())
+		assert.Equal(t, "", rec.Header().Get("X-Powered-By"))
+		assert.Equal(t, "test", rec.Header().Get("X-Env"))
+		assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+		assert.Equal(t, []string{"Accept", "X-Client"}, rec.Header().Values("Vary"))
+	})
+
+	t.Run("Should only apply the global rules outside the group", func(t *testing.T) {
+		for _, path := range []string{"/items", "/legacyfoo"} {
+			rec := request(path)
+			assert.Equal(t, "abc||1||client", rec.Body.String(), path)
+			assert.Equal(t, "", rec.Header().Get("X-Powered-By"), path)
+			assert.Equal(t, "test", rec.Header().Get("X-Env"), path)
+			assert.Equal(t, "", rec.Header().Get("X-Frame-Options"), path)
+		}
+	})
+
+	t.Run("Should reload the rules", func(t *testing.T) {
+		assert.Nil(t, ioutil.WriteFile(file, []byte(`{"rules": [
+			{"response": {"set": {"X-Version": "2"}}}
+		]}`), 0600))
+		assert.Nil(t, app.GetHeaderTransformer().Reload())
+
+		rec := request("/legacy/items")
+		assert.Equal(t, "abc||1||client", rec.Body.String())
+		assert.Equal(t, "catu", rec.Header().Get("X-Powered-By"))
+		assert.Equal(t, "2", rec.Header().Get("X-Version"))
+	})
+
+	t.Run("Should keep the current rules if the file is invalid", func(t *testing.T) {
+		for _, content := range []string{
+			`{"rules": [`,
+			`{"rules": [{"response": {"set": {"X-Bad": "{{.Unknown"}}}]}`,
+			`{"rules": [{"request": {"remove": ["authorization"]}}]}`,
+			`{"rules": [{"request": {"rename": {"X-Token": "Cookie"}}}]}`,
+		} {
+			assert.Nil(t, ioutil.WriteFile(file, []byte(content), 0600))
+			assert.NotNil(t, app.GetHeaderTransformer().Reload(), content)
+		}
+
+		assert.Equal(t, "2", request("/items").Header().Get("X-Version"))
+	})
+
+	t.Run("Should allow sensitive headers with allowSensitive", func(t *testing.T) {
+		assert.Nil(t, app.GetHeaderTransformer().Load(&HeaderTransformConfig{Rules: []HeaderTransformRule{
+			{AllowSensitive: true, Request: HeaderTransforms{Remove: []string{"X-Old-Token", "authorization"}}},
+		}}))
+
+		app.GetRouter().GET("/auth", func(c echo.Context) error {
+			return c.String(http.StatusOK, c.Request().Header.Get("Authorization"))
+		})
+		assert.Equal(t, "", request("/auth").Body.String())
+	})
+
+	t.Run("Should clear the rules if the file is removed", func(t *testing.T) {
+		assert.Nil(t, ioutil.WriteFile(file, []byte(`{"rules": [{"response": {"set": {"X-Version": "3"}}}]}`), 0600))
+		assert.Nil(t, app.GetHeaderTransformer().Reload())
+		assert.Equal(t, "3", request("/items").Header().Get("X-Version"))
+
+		app.GetHeaderTransformer().File = filepath.Join(t.TempDir(), "missing.json")
+		assert.Nil(t, app.GetHeaderTransformer().Reload())
+		assert.Equal(t, "", request("/items").Header().Get("X-Version"))
+	})
+}
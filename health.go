@@ -0,0 +1,163 @@
+package catu
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Health check statuses
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+// HealthCheckFunc checks one dependency, ex: one database ping. It must return when ctx is done
+type HealthCheckFunc func(ctx context.Context) error
+
+type HealthCheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"durationMs"`
+}
+
+// HealthReport is the /health and /ready response body
+type HealthReport struct {
+	Status string                        `json:"status"`
+	Checks map[string]*HealthCheckResult `json:"checks"`
+}
+
+func (r *HealthReport) OK() bool {
+	return r.Status == HealthStatusOK
+}
+
+var errHealthCheckTimeout = errors.New("timeout")
+
+// RegisterHealthCheck adds one health check to the /health and /ready endpoints, replacing the check with the same name.
+// The "database" check pings the default database
+func (r *AppStruct) RegisterHealthCheck(name string, fn HealthCheckFunc) {
+	r.healthChecksMu.Lock()
+	defer r.healthChecksMu.Unlock()
+
+	if r.healthChecks == nil {
+		r.healthChecks = make(map[string]HealthCheckFunc)
+	}
+	r.healthChecks[name] = fn
+}
+
+// RunHealthChecks runs all health checks concurrently, each one with HEALTH_CHECK_TIMEOUT milliseconds (default 2000)
+func (r *AppStruct) RunHealthChecks(ctx context.Context) *HealthReport {
+	timeout := time.Duration(r.Configuration.GetInt64F("HEALTH_CHECK_TIMEOUT", 2000)) * time.Millisecond
+
+	r.healthChecksMu.RLock()
+	names := make([]string, 0, len(r.healthChecks))
+	checks := make([]HealthCheckFunc, 0, len(r.healthChecks))
+	for name := range r.healthChecks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		checks = append(checks, r.healthChecks[name])
+	}
+	r.healthChecksMu.RUnlock()
+
+	report := &HealthReport{
+		Status: HealthStatusOK,
+		Checks: make(map[string]*HealthCheckResult, len(names)),
+	}
+	results := make([]*HealthCheckResult, len(names))
+
+	wg := sync.WaitGroup{}
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = runHealthCheck(ctx, checks[i], timeout)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, name := range names {
+		report.Checks[name] = results[i]
+
+		if results[i].Status != HealthStatusOK {
+			report.Status = HealthStatusFail
+
+			logrus.WithFields(logrus.Fields{
+				"name":  name,
+				"error": results[i].Error,
+			}).Warn("catu.App.RunHealthChecks check failed")
+		}
+	}
+
+	return report
+}
+
+// runHealthCheck returns on timeout without waiting for the check
+func runHealthCheck(ctx context.Context, check HealthCheckFunc, timeout time.Duration) *HealthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- errors.New("health check panic")
+			}
+		}()
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errHealthCheckTimeout
+	}
+
+	result := &HealthCheckResult{
+		Status:     HealthStatusOK,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = HealthStatusFail
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// IsReady returns true after the Bootstrap and until the shutdown starts
+func (r *AppStruct) IsReady() bool {
+	r.healthChecksMu.RLock()
+	defer r.healthChecksMu.RUnlock()
+
+	return r.ready
+}
+
+func (r *AppStruct) setReady(ready bool) {
+	r.healthChecksMu.Lock()
+	r.ready = ready
+	r.healthChecksMu.Unlock()
+}
+
+// databaseHealthCheck pings the default database
+func (r *AppStruct) databaseHealthCheck(ctx context.Context) error {
+	db := r.GetDB()
+	if db == nil {
+		return errors.New("database not started")
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,104 @@
+package catu
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthChecks(t *testing.T) {
+	t.Setenv("HEALTH_CHECK_TIMEOUT", "100")
+	app := newTestApp(t)
+
+	request := func(path string) (*httptest.ResponseRecorder, *HealthReport) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		report := &HealthReport{}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), report))
+		return rec, report
+	}
+
+	t.Run("Should not be ready before the bootstrap", func(t *testing.T) {
+		rec, report := request("/ready")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, HealthStatusFail, report.Status)
+		assert.Equal(t, "not ready", report.Checks["bootstrap"].Error)
+	})
+
+	assert.Nil(t, app.Bootstrap())
+
+	t.Run("Should respond the checks status", func(t *testing.T) {
+		for _, path := range []string{"/health", "/ready"} {
+			rec, report := request(path)
+			assert.Equal(t, http.StatusOK, rec.Code, path)
+			assert.Equal(t, HealthStatusOK, report.Status)
+			assert.Equal(t, HealthStatusOK, report.Checks["database"].Status)
+		}
+	})
+
+	t.Run("Should respond 503 with one failing check", func(t *testing.T) {
+		app.RegisterHealthCheck("queue", func(ctx context.Context) error {
+			return errors.New("connection refused")
+		})
+
+		rec, report := request("/health")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		for _, check := range report.Checks {
+			check.DurationMS = 0
+		}
+		assert.Equal(t, &HealthReport{
+			Status: HealthStatusFail,
+			Checks: map[string]*HealthCheckResult{
+				"database": {Status: HealthStatusOK},
+				"queue":    {Status: HealthStatusFail, Error: "connection refused"},
+			},
+		}, report)
+		assert.Contains(t, rec.Body.String(), `"durationMs":`)
+
+		rec, _ = request("/ready")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+		app.RegisterHealthCheck("queue", func(ctx context.Context) error { return nil })
+		rec, _ = request("/health")
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Should run the checks concurrently with timeout", func(t *testing.T) {
+		block := make(chan struct{})
+		defer close(block)
+
+		for _, name := range []string{"slow1", "slow2", "slow3"} {
+			app.RegisterHealthCheck(name, func(ctx context.Context) error {
+				// ignores the ctx
+				<-block
+				return nil
+			})
+		}
+
+		start := time.Now()
+		rec, report := request("/health")
+		assert.Less(t, time.Since(start), 300*time.Millisecond)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "timeout", report.Checks["slow1"].Error)
+		assert.Equal(t, "timeout", report.Checks["slow3"].Error)
+		assert.Equal(t, HealthStatusOK, report.Checks["database"].Status)
+	})
+
+	t.Run("Should fail the database check with the database closed", func(t *testing.T) {
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		assert.Nil(t, app.CloseDB("default"))
+
+		report := app.RunHealthChecks(context.Background())
+		assert.False(t, report.OK())
+		assert.Equal(t, HealthStatusFail, report.Checks["database"].Status)
+	})
+}
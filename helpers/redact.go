@@ -0,0 +1,37 @@
+package helpers
+
+import "strings"
+
+// SensitiveFields is the list of field names redacted from logs and error responses.
+// Names are matched ignoring case, "_" and "-", and also as part of other names, ex: "password" matches "new_password"
+var SensitiveFields = []string{
+	"password",
+	"passwd",
+	"secret",
+	"token",
+	"authorization",
+	"cookie",
+	"apikey",
+	"creditcard",
+	"cardnumber",
+	"cvv",
+}
+
+// RedactedValue replaces sensitive values
+const RedactedValue = "[REDACTED]"
+
+// IsSensitiveField check if one field name is in the SensitiveFields list
+func IsSensitiveField(name string) bool {
+	name = strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(name))
+	if name == "" {
+		return false
+	}
+
+	for _, f := range SensitiveFields {
+		if strings.Contains(name, f) {
+			return true
+		}
+	}
+
+	return false
+}
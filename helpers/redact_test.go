@@ -0,0 +1,23 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsSensitiveField(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"password":      true,
+		"newPassword":   true,
+		"user_password": true,
+		"api-key":       true,
+		"Authorization": true,
+		"access_token":  true,
+		"email":         false,
+		"username":      false,
+		"":              false,
+	} {
+		assert.Equal(t, expected, IsSensitiveField(name), name)
+	}
+}
@@ -1,13 +1,150 @@
 package helpers
 
-import "github.com/go-playground/validator/v10"
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/pt_BR"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	pt_BR_translations "github.com/go-playground/validator/v10/translations/pt_BR"
+	"github.com/pkg/errors"
+)
+
+// invalidMessageKey is the message of tags without translation
+const invalidMessageKey = "catu.invalid"
 
 // CutstomValidator :
 type CustomValidator struct {
 	Validator *validator.Validate
+
+	// translators by lower case locale, ex: "pt-br"
+	translators   map[string]ut.Translator
+	defaultLocale string
 }
 
 // Validate : Validate Data
 func (cv *CustomValidator) Validate(i interface{}) error {
 	return cv.Validator.Struct(i)
 }
+
+// NewValidator returns one validator that reports field names from the json or form tags
+func NewValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(TagFieldName)
+	return v
+}
+
+// NewCustomValidator returns one CustomValidator with the english and brazilian portuguese messages
+func NewCustomValidator(v *validator.Validate) *CustomValidator {
+	cv := &CustomValidator{
+		Validator:     v,
+		translators:   map[string]ut.Translator{},
+		defaultLocale: "en",
+	}
+
+	enLocale := en.New()
+	uni := ut.New(enLocale, enLocale, pt_BR.New())
+
+	cv.addTranslator(uni, enLocale, en_translations.RegisterDefaultTranslations, "{0} is invalid")
+	cv.addTranslator(uni, pt_BR.New(), pt_BR_translations.RegisterDefaultTranslations, "{0} é inválido")
+
+	return cv
+}
+
+func (cv *CustomValidator) addTranslator(uni *ut.UniversalTranslator, l locales.Translator, register func(*validator.Validate, ut.Translator) error, invalid string) {
+	trans, _ := uni.GetTranslator(l.Locale())
+	// the default translations only fail with invalid templates
+	_ = register(cv.Validator, trans)
+	_ = trans.Add(invalidMessageKey, invalid, true)
+
+	cv.translators[strings.ToLower(strings.ReplaceAll(l.Locale(), "_", "-"))] = trans
+}
+
+// Locales returns the locales with translated messages, ex: ["en", "pt-br"]
+func (cv *CustomValidator) Locales() []string {
+	names := make([]string, 0, len(cv.translators))
+	for name := range cv.translators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetTranslator returns the translator of the locale, of the locale language or the english translator
+func (cv *CustomValidator) GetTranslator(locale string) ut.Translator {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	if t, ok := cv.translators[locale]; ok {
+		return t
+	}
+
+	base := strings.SplitN(locale, "-", 2)[0]
+	if t, ok := cv.translators[base]; ok {
+		return t
+	}
+	for name, t := range cv.translators {
+		if strings.HasPrefix(name, base+"-") {
+			return t
+		}
+	}
+
+	return cv.translators[cv.defaultLocale]
+}
+
+// Translate returns the field error message in the locale, ex: "email must be a valid email address"
+func (cv *CustomValidator) Translate(fe validator.FieldError, locale string) string {
+	trans := cv.GetTranslator(locale)
+	if trans == nil {
+		return fe.Error()
+	}
+
+	msg := fe.Translate(trans)
+	if msg == fe.Error() {
+		// tags without translation
+		msg, _ = trans.T(invalidMessageKey, fe.Field())
+	}
+	return msg
+}
+
+// RegisterMessage sets the message of one tag in all locales, {0} is the field name and {1} the tag param,
+// ex: RegisterMessage("cpf", "{0} must be a valid CPF")
+func (cv *CustomValidator) RegisterMessage(tag, template string) error {
+	for _, trans := range cv.translators {
+		err := cv.Validator.RegisterTranslation(tag, trans, func(t ut.Translator) error {
+			return t.Add(tag, template, true)
+		}, func(t ut.Translator, fe validator.FieldError) string {
+			msg, err := t.T(fe.Tag(), fe.Field(), fe.Param())
+			if err != nil {
+				return fe.Error()
+			}
+			return msg
+		})
+		if err != nil {
+			return errors.Wrap(err, "helpers.CustomValidator.RegisterMessage error on register "+tag)
+		}
+	}
+
+	return nil
+}
+
+// RegisterValidation adds one validation tag, see validator.Validate.RegisterValidation
+func (cv *CustomValidator) RegisterValidation(tag string, fn validator.Func) error {
+	return cv.Validator.RegisterValidation(tag, fn)
+}
+
+// TagFieldName returns the field name from the json tag, then the form tag.
+// Returns empty string to use the struct field name
+func TagFieldName(f reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name := strings.Split(f.Tag.Get(tag), ",")[0]
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,92 @@
+package http_client
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by the Client requests while the circuit breaker is open, wrapped in one CircuitOpenError
+var ErrCircuitOpen = errors.New("http_client circuit breaker is open")
+
+// CircuitOpenError is the error of the requests rejected by the open circuit breaker, errors.Is(err, ErrCircuitOpen) is true.
+// The catu error handler responds it with 503 and the Retry-After header
+type CircuitOpenError struct {
+	// Time until the circuit breaker allows one request again
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return ErrCircuitOpen.Error()
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitBreakerOptions opens the circuit after the consecutive failed attempts, connection errors or 5xx responses.
+// After the OpenTimeout one request is allowed and its result closes or opens the circuit again
+type CircuitBreakerOptions struct {
+	// Default 5
+	Failures int
+	// Default 30s
+	OpenTimeout time.Duration
+}
+
+type circuitBreaker struct {
+	mu       sync.Mutex
+	opts     CircuitBreakerOptions
+	failures int
+	openedAt time.Time
+	// one request is running after the OpenTimeout
+	halfOpen bool
+}
+
+func newCircuitBreaker(opts CircuitBreakerOptions) *circuitBreaker {
+	if opts.Failures <= 0 {
+		opts.Failures = 5
+	}
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = 30 * time.Second
+	}
+
+	return &circuitBreaker{opts: opts}
+}
+
+// allow returns one CircuitOpenError while the circuit is open
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openedAt.IsZero() {
+		return nil
+	}
+	if wait := b.opts.OpenTimeout - time.Since(b.openedAt); b.halfOpen || wait > 0 {
+		// the half open request is running
+		if wait < time.Second {
+			wait = time.Second
+		}
+		return &CircuitOpenError{RetryAfter: wait}
+	}
+
+	b.halfOpen = true
+	return nil
+}
+
+func (b *circuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.halfOpen = false
+
+	if success {
+		b.failures = 0
+		b.openedAt = time.Time{}
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.opts.Failures || !b.openedAt.IsZero() {
+		b.openedAt = time.Now()
+	}
+}
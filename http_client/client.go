@@ -1,10 +1,17 @@
 package http_client
 
 import (
+	"context"
+	"io"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/go-catupiry/catu/configuration"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
 // CustomHTTPClient - Custom http client required to make requests testable
@@ -16,9 +23,202 @@ var (
 	HttpClient CustomHTTPClient
 )
 
+// DefaultTimeout is the timeout of the clients without one ClientOptions.Timeout
+const DefaultTimeout = 120 * time.Second
+
+// Init sets the global HttpClient used by Get, Post and DownloadFile with the HTTP_CLIENT_TIMEOUT seconds, default 120,
+// and the HTTP_CLIENT_RETRIES retries of the idempotent requests, default 2
 func Init() {
 	httpClientTimeout := configuration.GetInt64Env("HTTP_CLIENT_TIMEOUT", 120)
 
-	timeout := time.Second * time.Duration(httpClientTimeout)
-	HttpClient = &http.Client{Timeout: timeout}
+	HttpClient = NewClient(ClientOptions{
+		Timeout: time.Second * time.Duration(httpClientTimeout),
+		Retries: int(configuration.GetInt64Env("HTTP_CLIENT_RETRIES", 2)),
+	})
+}
+
+// ClientOptions are the NewClient options
+type ClientOptions struct {
+	// Prefix of the relative request URLs, ex: "https://api.example.com/v1"
+	BaseURL string
+	// Timeout of each request attempt, default DefaultTimeout
+	Timeout time.Duration
+	// Retries of the idempotent requests after one connection error or one 5xx response
+	Retries int
+	// Exponential backoff between the retries, default 100ms to 2s, with jitter
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+	// Headers added to the requests without them
+	Header http.Header
+	// Stops the requests after consecutive failures, nil to disable it
+	CircuitBreaker *CircuitBreakerOptions
+	// Default http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+// Client is one HTTP client with timeout, retries and one optional circuit breaker. It propagates the request ID
+// of the request context, see WithRequestID
+type Client struct {
+	opts    ClientOptions
+	http    *http.Client
+	breaker *circuitBreaker
+}
+
+// NewClient creates one Client, ex:
+//
+//	client := http_client.NewClient(http_client.ClientOptions{BaseURL: "https://api.example.com", Timeout: 5 * time.Second, Retries: 3})
+func NewClient(opts ClientOptions) *Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.RetryWaitMin <= 0 {
+		opts.RetryWaitMin = 100 * time.Millisecond
+	}
+	if opts.RetryWaitMax < opts.RetryWaitMin {
+		opts.RetryWaitMax = 2 * time.Second
+		if opts.RetryWaitMax < opts.RetryWaitMin {
+			opts.RetryWaitMax = opts.RetryWaitMin
+		}
+	}
+
+	c := &Client{
+		opts: opts,
+		http: &http.Client{Timeout: opts.Timeout, Transport: opts.Transport},
+	}
+
+	if opts.CircuitBreaker != nil {
+		c.breaker = newCircuitBreaker(*opts.CircuitBreaker)
+	}
+
+	return c
+}
+
+// GetBaseURL returns the ClientOptions.BaseURL
+func (c *Client) GetBaseURL() string {
+	return c.opts.BaseURL
+}
+
+// NewRequest creates one request with the path relative to the BaseURL, absolute URLs are used as is
+func (c *Client) NewRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, method, c.resolveURL(path), body)
+}
+
+func (c *Client) resolveURL(path string) string {
+	if c.opts.BaseURL == "" || strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	if path == "" {
+		return c.opts.BaseURL
+	}
+
+	return strings.TrimSuffix(c.opts.BaseURL, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// Do sends the request, retrying the idempotent requests after connection errors and 5xx responses.
+// The last 5xx response is returned without error, like http.Client.Do
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if !req.URL.IsAbs() && c.opts.BaseURL != "" {
+		u, err := url.Parse(c.resolveURL(req.URL.String()))
+		if err != nil {
+			return nil, errors.Wrap(err, "http_client.Client.Do invalid url")
+		}
+		req.URL = u
+		req.Host = u.Host
+	}
+
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	for name, values := range c.opts.Header {
+		if req.Header.Get(name) == "" {
+			req.Header[name] = values
+		}
+	}
+	if id := RequestIDFromContext(req.Context()); id != "" && req.Header.Get(RequestIDHeader) == "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+
+	attempts := 1
+	if isIdempotent(req) {
+		attempts += c.opts.Retries
+	}
+
+	for attempt := 0; ; attempt++ {
+		if c.breaker != nil {
+			if err := c.breaker.allow(); err != nil {
+				return nil, err
+			}
+		}
+
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, errors.Wrap(err, "http_client.Client.Do error on reset the request body")
+			}
+			req.Body = body
+		}
+
+		res, err := c.http.Do(req)
+		failed := err != nil || res.StatusCode >= http.StatusInternalServerError
+		if c.breaker != nil {
+			c.breaker.record(!failed)
+		}
+
+		// canceled requests are not retried
+		if !failed || attempt+1 >= attempts || req.Context().Err() != nil {
+			return res, err
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"url":     req.URL.String(),
+			"method":  req.Method,
+			"attempt": attempt + 1,
+			"error":   errorString(err),
+			"status":  responseStatus(res),
+		}).Debug("http_client.Client.Do retrying request")
+
+		if res != nil {
+			res.Body.Close()
+		}
+
+		select {
+		case <-time.After(c.backoff(attempt)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// backoff is the exponential wait of one retry with jitter, between the half and the full wait
+func (c *Client) backoff(attempt int) time.Duration {
+	wait := c.opts.RetryWaitMin << uint(attempt)
+	if wait <= 0 || wait > c.opts.RetryWaitMax {
+		wait = c.opts.RetryWaitMax
+	}
+
+	half := int64(wait / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// isIdempotent checks the method and if the body can be sent again
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	}
+	return false
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func responseStatus(res *http.Response) int {
+	if res == nil {
+		return 0
+	}
+	return res.StatusCode
 }
@@ -0,0 +1,144 @@
+package http_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientRetries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"id":"1","name":"Alice"}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(ClientOptions{BaseURL: srv.URL, Retries: 2, RetryWaitMin: time.Millisecond, RetryWaitMax: 5 * time.Millisecond})
+
+	t.Run("Should retry the idempotent requests after 5xx responses", func(t *testing.T) {
+		user := struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}{}
+		assert.Nil(t, client.GetJSON(context.Background(), "/users/1", &user))
+		assert.Equal(t, "Alice", user.Name)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("Should not retry the POST requests", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		err := client.PostJSON(context.Background(), "/users", map[string]string{"name": "Bob"}, nil)
+
+		statusErr := &StatusError{}
+		assert.True(t, errors.As(err, &statusErr))
+		assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("Should return the last 5xx response after the retries", func(t *testing.T) {
+		atomic.StoreInt32(&calls, -10)
+		req, err := client.NewRequest(context.Background(), http.MethodGet, "/users/1", nil)
+		assert.Nil(t, err)
+
+		res, err := client.Do(req)
+		assert.Nil(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		assert.Equal(t, int32(-7), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestClientTimeout(t *testing.T) {
+	release := make(chan struct{})
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	t.Run("Should stop the requests to one hanging upstream", func(t *testing.T) {
+		client := NewClient(ClientOptions{BaseURL: srv.URL, Timeout: 50 * time.Millisecond, Retries: 1, RetryWaitMin: time.Millisecond})
+
+		start := time.Now()
+		err := client.GetJSON(context.Background(), "/slow", nil)
+		assert.NotNil(t, err)
+		assert.Less(t, int64(time.Since(start)), int64(time.Second))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("Should not retry canceled requests", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		client := NewClient(ClientOptions{BaseURL: srv.URL, Timeout: time.Minute, Retries: 3})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := client.GetJSON(ctx, "/slow", nil)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	client := NewClient(ClientOptions{BaseURL: srv.URL, CircuitBreaker: &CircuitBreakerOptions{Failures: 2, OpenTimeout: 50 * time.Millisecond}})
+
+	t.Run("Should open the circuit after the consecutive failures", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			assert.NotNil(t, client.GetJSON(context.Background(), "/", nil))
+		}
+
+		err := client.GetJSON(context.Background(), "/", nil)
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+		openErr := &CircuitOpenError{}
+		assert.True(t, errors.As(err, &openErr))
+		assert.Equal(t, time.Second, openErr.RetryAfter)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+		time.Sleep(60 * time.Millisecond)
+		statusErr := &StatusError{}
+		assert.True(t, errors.As(client.GetJSON(context.Background(), "/", nil), &statusErr))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+		assert.ErrorIs(t, client.GetJSON(context.Background(), "/", nil), ErrCircuitOpen)
+	})
+}
+
+func TestClientHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"requestId": r.Header.Get(RequestIDHeader),
+			"token":     r.Header.Get("Authorization"),
+		})
+	}))
+	defer srv.Close()
+
+	client := NewClient(ClientOptions{BaseURL: srv.URL + "/", Header: http.Header{"Authorization": {"Bearer abc"}}})
+
+	t.Run("Should send the request ID of the context and the default headers", func(t *testing.T) {
+		data := map[string]string{}
+		assert.Nil(t, client.GetJSON(WithRequestID(context.Background(), "req-1"), "/echo", &data))
+		assert.Equal(t, map[string]string{"requestId": "req-1", "token": "Bearer abc"}, data)
+	})
+}
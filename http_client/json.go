@@ -0,0 +1,84 @@
+package http_client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// maximum size of the StatusError body
+const statusErrorBodyLimit = 4096
+
+// StatusError is returned by the JSON helpers for responses with status code >= 400
+type StatusError struct {
+	StatusCode int
+	// Start of the response body
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("http_client unexpected status code %d: %s", e.StatusCode, e.Body)
+}
+
+// GetJSON sends one GET request and decodes the JSON response in target, ex:
+//
+//	user := User{}
+//	err := client.GetJSON(c.Request().Context(), "/users/1", &user)
+func (c *Client) GetJSON(ctx context.Context, path string, target interface{}) error {
+	return c.DoJSON(ctx, http.MethodGet, path, nil, target)
+}
+
+// PostJSON sends body as JSON and decodes the JSON response in target, one nil target ignores the response body
+func (c *Client) PostJSON(ctx context.Context, path string, body, target interface{}) error {
+	return c.DoJSON(ctx, http.MethodPost, path, body, target)
+}
+
+// DoJSON sends one request with the optional JSON body and decodes the JSON response in target.
+// Responses with status code >= 400 return one *StatusError
+func (c *Client) DoJSON(ctx context.Context, method, path string, body, target interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "http_client.Client.DoJSON error on encode body")
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := c.NewRequest(ctx, method, path, reader)
+	if err != nil {
+		return errors.Wrap(err, "http_client.Client.DoJSON error on create request")
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		data, _ := ioutil.ReadAll(io.LimitReader(res.Body, statusErrorBodyLimit))
+		return &StatusError{StatusCode: res.StatusCode, Body: string(data)}
+	}
+
+	if target == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(target); err != nil {
+		return errors.Wrap(err, "http_client.Client.DoJSON error on decode response")
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package http_client
+
+import "context"
+
+// RequestIDHeader is the header with the request ID sent by the Client
+const RequestIDHeader = "X-Request-Id"
+
+type requestIDContextKey struct{}
+
+// WithRequestID returns one context with the request ID sent in the Client requests.
+// The catu request ID middleware sets it in the echo request context, ex:
+//
+//	req, err := client.NewRequest(c.Request().Context(), http.MethodGet, "/users", nil)
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestIDFromContext returns the WithRequestID request ID or one empty string
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
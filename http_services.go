@@ -0,0 +1,36 @@
+package catu
+
+import (
+	"time"
+
+	"github.com/go-catupiry/catu/http_client"
+)
+
+// RegisterHTTPService creates one named HTTP client shared by the plugins, ex:
+//
+//	app.RegisterHTTPService("payments", "https://payments.example.com/v1", http_client.ClientOptions{Retries: 3})
+//	err := app.HTTPService("payments").GetJSON(c.Request().Context(), "/invoices/1", &invoice)
+//
+// Options without one Timeout use the HTTP_CLIENT_TIMEOUT seconds, default 120. Registering one name again replaces its client
+func (r *AppStruct) RegisterHTTPService(name, baseURL string, opts http_client.ClientOptions) *http_client.Client {
+	opts.BaseURL = baseURL
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Duration(r.Configuration.GetInt64F("HTTP_CLIENT_TIMEOUT", 120)) * time.Second
+	}
+
+	client := http_client.NewClient(opts)
+
+	r.httpServicesMu.Lock()
+	r.httpServices[name] = client
+	r.httpServicesMu.Unlock()
+
+	return client
+}
+
+// HTTPService returns one RegisterHTTPService client or nil
+func (r *AppStruct) HTTPService(name string) *http_client.Client {
+	r.httpServicesMu.RLock()
+	defer r.httpServicesMu.RUnlock()
+
+	return r.httpServices[name]
+}
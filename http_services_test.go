@@ -0,0 +1,81 @@
+package catu
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-catupiry/catu/http_client"
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPService(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`"` + r.URL.Path + " " + r.Header.Get(http_client.RequestIDHeader) + `"`))
+	}))
+	defer upstream.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	app := newTestApp(t)
+	client := app.RegisterHTTPService("users", upstream.URL+"/v1", http_client.ClientOptions{})
+	app.RegisterHTTPService("payments", failing.URL, http_client.ClientOptions{
+		CircuitBreaker: &http_client.CircuitBreakerOptions{Failures: 1, OpenTimeout: time.Minute},
+	})
+
+	app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+		app.GetRouter().GET("/proxy", func(c echo.Context) error {
+			var data string
+			if err := app.HTTPService("users").GetJSON(c.Request().Context(), "/users", &data); err != nil {
+				return err
+			}
+			return c.String(http.StatusOK, data)
+		})
+		app.GetRouter().GET("/payments", func(c echo.Context) error {
+			return app.HTTPService("payments").GetJSON(c.Request().Context(), "/", nil)
+		})
+		return nil
+	}))
+
+	assert.Nil(t, app.Bootstrap())
+
+	t.Run("Should share the named clients", func(t *testing.T) {
+		assert.Same(t, client, app.HTTPService("users"))
+		assert.Equal(t, upstream.URL+"/v1", client.GetBaseURL())
+		assert.Nil(t, app.HTTPService("unknown"))
+	})
+
+	t.Run("Should propagate the request ID to the upstream", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/proxy", nil)
+		req.Header.Set(echo.HeaderXRequestID, "req-123")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "/v1/users req-123", rec.Body.String())
+	})
+
+	t.Run("Should respond 503 with Retry-After while the circuit is open", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/payments", nil)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/payments", nil)
+		req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+		rec = httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+		assert.Nil(t, err)
+		assert.True(t, retryAfter > 0 && retryAfter <= 66, "up to one minute plus the jitter")
+	})
+}
@@ -0,0 +1,422 @@
+package catu
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v3"
+)
+
+// translation is one translated text or one plural text with the "zero", "one" and "other" forms
+type translation struct {
+	text   string
+	plural map[string]string
+}
+
+// i18nStore has the translations by locale and key
+type i18nStore struct {
+	mu      sync.RWMutex
+	locales map[string]map[string]*translation
+	// missing keys already logged
+	missing sync.Map
+}
+
+func newI18nStore() *i18nStore {
+	s := &i18nStore{locales: map[string]map[string]*translation{}}
+	s.add("en", defaultTranslations["en"])
+	s.add("pt-br", defaultTranslations["pt-br"])
+	return s
+}
+
+// defaultTranslations are the error titles and messages, files in the LOCALES_FOLDER can replace them
+var defaultTranslations = map[string]map[string]interface{}{
+	"en": {
+		"errors": map[string]interface{}{
+			"400":        "Bad Request",
+			"401":        "Unauthorized",
+			"403":        "Forbidden",
+			"404":        "Not Found",
+			"410":        "Gone",
+			"412":        "Precondition Failed",
+			"413":        "Request Entity Too Large",
+			"422":        "Unprocessable Entity",
+			"429":        "Too Many Requests",
+			"500":        "Internal Server Error",
+			"503":        "Service Unavailable",
+			"retryLater": "Please try again later.",
+			"retryIn": map[string]interface{}{
+				"one":   "Please try again in %d second.",
+				"other": "Please try again in %d seconds.",
+			},
+		},
+	},
+	"pt-br": {
+		"errors": map[string]interface{}{
+			"400":        "Requisição inválida",
+			"401":        "Não autorizado",
+			"403":        "Acesso restrito",
+			"404":        "Não encontrado",
+			"410":        "Link expirado",
+			"412":        "Falha na pré-condição",
+			"413":        "Conteúdo muito grande",
+			"422":        "Dados inválidos",
+			"429":        "Muitas requisições",
+			"500":        "Erro interno no servidor",
+			"503":        "Serviço indisponível",
+			"retryLater": "Tente novamente mais tarde.",
+			"retryIn": map[string]interface{}{
+				"one":   "Tente novamente em %d segundo.",
+				"other": "Tente novamente em %d segundos.",
+			},
+		},
+	},
+}
+
+// normalizeLocale returns the lower case locale with "-", ex: "pt_BR" is "pt-br"
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+func (s *i18nStore) add(locale string, translations map[string]interface{}) {
+	locale = normalizeLocale(locale)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.locales[locale] == nil {
+		s.locales[locale] = map[string]*translation{}
+	}
+	flattenTranslations(s.locales[locale], "", translations)
+}
+
+// flattenTranslations adds the nested keys with dots, ex: {"errors": {"404": "Not Found"}} is "errors.404"
+func flattenTranslations(dst map[string]*translation, prefix string, src map[string]interface{}) {
+	for k, v := range src {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+
+		switch value := v.(type) {
+		case map[string]interface{}:
+			if plural := pluralForms(value); plural != nil {
+				dst[key] = &translation{plural: plural}
+				continue
+			}
+			flattenTranslations(dst, key, value)
+		default:
+			dst[key] = &translation{text: cast.ToString(value)}
+		}
+	}
+}
+
+// pluralForms returns the forms of one map with only "zero", "one" and "other" keys
+func pluralForms(m map[string]interface{}) map[string]string {
+	if _, ok := m["other"]; !ok {
+		return nil
+	}
+
+	forms := map[string]string{}
+	for k, v := range m {
+		if k != "zero" && k != "one" && k != "other" {
+			return nil
+		}
+		forms[k] = cast.ToString(v)
+	}
+	return forms
+}
+
+// lookup returns the text of one key in the locale, in the locale language or in the fallback locale
+func (s *i18nStore) lookup(locale, fallback, key string) (*translation, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	candidates := []string{locale}
+	if i := strings.Index(locale, "-"); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+	candidates = append(candidates, fallback)
+
+	for _, l := range candidates {
+		if t, ok := s.locales[l][key]; ok {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// match returns the loaded locale for one requested locale, ex: "pt-BR" matches "pt-br" or "pt" and "pt" matches "pt-br"
+func (s *i18nStore) match(locale string) string {
+	locale = normalizeLocale(locale)
+	if locale == "" {
+		return ""
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.locales[locale]; ok {
+		return locale
+	}
+
+	base := locale
+	if i := strings.Index(locale, "-"); i > 0 {
+		base = locale[:i]
+		if _, ok := s.locales[base]; ok {
+			return base
+		}
+	}
+
+	names := make([]string, 0, len(s.locales))
+	for name := range s.locales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.HasPrefix(name, base+"-") {
+			return name
+		}
+	}
+
+	return ""
+}
+
+func (t *translation) format(args []interface{}) string {
+	text := t.text
+
+	if t.plural != nil {
+		var count int64
+		if len(args) > 0 {
+			count = cast.ToInt64(args[0])
+		}
+
+		form := "other"
+		if count == 0 && t.plural["zero"] != "" {
+			form = "zero"
+		} else if count == 1 && t.plural["one"] != "" {
+			form = "one"
+		}
+		text = t.plural[form]
+	}
+
+	if len(args) > 0 && strings.Contains(text, "%") {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// T translates one key in the locale, falling back to the locale language and to the LOCALE_DEFAULT locale (default "en").
+// Missing keys return the key. With args the text is one fmt format and the first arg selects the plural form, ex:
+//
+//	{"items": {"one": "%d item", "other": "%d items"}}
+//	app.T("en", "items", 2) // "2 items"
+func (r *AppStruct) T(locale, key string, args ...interface{}) string {
+	text, ok := r.Translate(locale, key, args...)
+	if !ok {
+		if _, logged := r.i18n.missing.LoadOrStore(locale+"\x00"+key, true); !logged {
+			logrus.WithFields(logrus.Fields{
+				"locale": locale,
+				"key":    key,
+			}).Warn("catu.App.T missing translation")
+		}
+		return key
+	}
+
+	return text
+}
+
+// Translate is T without the missing key log, returns false for missing keys
+func (r *AppStruct) Translate(locale, key string, args ...interface{}) (string, bool) {
+	t, ok := r.i18n.lookup(normalizeLocale(locale), r.GetDefaultLocale(), key)
+	if !ok {
+		return "", false
+	}
+
+	return t.format(args), true
+}
+
+// GetDefaultLocale returns the LOCALE_DEFAULT locale, default "en"
+func (r *AppStruct) GetDefaultLocale() string {
+	return normalizeLocale(r.Configuration.GetF("LOCALE_DEFAULT", "en"))
+}
+
+// GetLocales returns the loaded locales
+func (r *AppStruct) GetLocales() []string {
+	r.i18n.mu.RLock()
+	defer r.i18n.mu.RUnlock()
+
+	locales := make([]string, 0, len(r.i18n.locales))
+	for l := range r.i18n.locales {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// AddTranslations adds nested translations to one locale, replacing the existing keys
+func (r *AppStruct) AddTranslations(locale string, translations map[string]interface{}) {
+	r.i18n.add(locale, translations)
+}
+
+// LoadLocales loads the folder translation files named by locale, ex: "en.json", "pt-BR.yaml".
+// It is called in the Bootstrap with the LOCALES_FOLDER, default "locales"
+func (r *AppStruct) LoadLocales(folder string) error {
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		if os.IsNotExist(err) {
+			logrus.WithFields(logrus.Fields{
+				"folder": folder,
+			}).Debug("catu.App.LoadLocales locales folder not found")
+			return nil
+		}
+		return errors.Wrap(err, "catu.App.LoadLocales error on read folder")
+	}
+
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".json" && ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		file := filepath.Join(folder, entry.Name())
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return errors.Wrap(err, "catu.App.LoadLocales error on read "+file)
+		}
+
+		translations := map[string]interface{}{}
+		if ext == ".json" {
+			err = json.Unmarshal(data, &translations)
+		} else {
+			err = yaml.Unmarshal(data, &translations)
+		}
+		if err != nil {
+			return errors.Wrap(err, "catu.App.LoadLocales error on parse "+file)
+		}
+
+		r.AddTranslations(strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())), translations)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"folder":  folder,
+		"locales": r.GetLocales(),
+	}).Debug("catu.App.LoadLocales locales loaded")
+
+	return nil
+}
+
+// DetectLocale returns the request locale from the Accept-Language header, the LOCALE_COOKIE cookie (default "locale")
+// or the LOCALE_QUERY_PARAM query param (default "locale"), in that order.
+// Only loaded locales are used, the default is LOCALE_DEFAULT
+func (r *AppStruct) DetectLocale(req *http.Request) string {
+	cfg := r.Configuration
+
+	for _, tag := range parseAcceptLanguage(req.Header.Get("Accept-Language")) {
+		if l := r.i18n.match(tag); l != "" {
+			return l
+		}
+	}
+
+	if cookie, err := req.Cookie(cfg.GetF("LOCALE_COOKIE", "locale")); err == nil {
+		if l := r.i18n.match(cookie.Value); l != "" {
+			return l
+		}
+	}
+
+	if v := req.URL.Query().Get(cfg.GetF("LOCALE_QUERY_PARAM", "locale")); v != "" {
+		if l := r.i18n.match(v); l != "" {
+			return l
+		}
+	}
+
+	return r.GetDefaultLocale()
+}
+
+// parseAcceptLanguage returns the Accept-Language tags sorted by quality, without "*" and q=0 tags
+func parseAcceptLanguage(header string) []string {
+	type tag struct {
+		name string
+		q    float64
+	}
+
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		name, q := strings.TrimSpace(part), 1.0
+		if i := strings.Index(name, ";"); i >= 0 {
+			param := strings.TrimSpace(name[i+1:])
+			name = strings.TrimSpace(name[:i])
+			if strings.HasPrefix(param, "q=") {
+				v, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					continue
+				}
+				q = v
+			}
+		}
+
+		if name == "" || name == "*" || q <= 0 {
+			continue
+		}
+		tags = append(tags, tag{name: name, q: q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	names := make([]string, len(tags))
+	for i := range tags {
+		names[i] = tags[i].name
+	}
+	return names
+}
+
+// templateTranslate is the t template function, with the default locale or with the request locale, ex:
+//
+//	{{ t "title" }}
+//	{{ t .Ctx "items" 2 }}
+func (r *AppStruct) templateTranslate(v interface{}, args ...interface{}) (string, error) {
+	locale := r.GetDefaultLocale()
+
+	if ctx, ok := v.(*RequestContext); ok {
+		if len(args) == 0 {
+			return "", errors.New("catu.t template function key is required")
+		}
+		locale = ctx.Locale
+		v, args = args[0], args[1:]
+	}
+
+	key, ok := v.(string)
+	if !ok {
+		return "", errors.New("catu.t template function key must be one string")
+	}
+
+	return r.T(locale, key, args...), nil
+}
+
+// T translates one key in the request locale, see App.T
+func (r *RequestContext) T(key string, args ...interface{}) string {
+	return r.App.T(r.Locale, key, args...)
+}
+
+// translatedStatusText returns the errors.<code> translation for the status text messages
+func translatedStatusText(c echo.Context, code int, message string) string {
+	ctx, ok := c.(*RequestContext)
+	if !ok || ctx.App == nil || message != http.StatusText(code) {
+		return message
+	}
+
+	if text, ok := ctx.App.Translate(ctx.Locale, "errors."+strconv.Itoa(code)); ok {
+		return text
+	}
+	return message
+}
@@ -0,0 +1,142 @@
+package catu
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	assert.Equal(t, []string{"pt-BR", "pt", "en"}, parseAcceptLanguage("pt-BR,pt;q=0.9,en;q=0.8"))
+	assert.Equal(t, []string{"en", "fr", "de"}, parseAcceptLanguage("de;q=0.5, en, fr;q=0.7"))
+	assert.Equal(t, []string{"es"}, parseAcceptLanguage("*, es;q=0.1, it;q=0, fr;q=invalid"))
+	assert.Empty(t, parseAcceptLanguage(""))
+}
+
+func TestI18n(t *testing.T) {
+	locales := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(locales, "en.json"), []byte(`{
+		"title": "Articles",
+		"greeting": "Hello %s",
+		"items": {"zero": "No items", "one": "%d item", "other": "%d items"},
+		"menu": {"home": "Home"}
+	}`), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(locales, "pt-BR.yaml"), []byte("title: Artigos\nitems:\n  one: \"%d item\"\n  other: \"%d itens\"\nerrors:\n  \"404\": Página não encontrada\n"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(locales, "es.yml"), []byte("title: Artículos\n"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(locales, "README.md"), []byte("ignored"), 0644))
+
+	newI18nApp := func(t *testing.T) App {
+		t.Setenv("LOCALES_FOLDER", locales)
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		return app
+	}
+
+	t.Run("Should translate with the fallback chain", func(t *testing.T) {
+		app := newI18nApp(t)
+		assert.Equal(t, []string{"en", "es", "pt-br"}, app.GetLocales())
+
+		assert.Equal(t, "Artigos", app.T("pt-br", "title"))
+		assert.Equal(t, "Artigos", app.T("pt-BR", "title"))
+		// pt-br without the key uses the default locale
+		assert.Equal(t, "Home", app.T("pt-br", "menu.home"))
+		// es-mx uses es
+		assert.Equal(t, "Artículos", app.T("es-mx", "title"))
+		assert.Equal(t, "Articles", app.T("fr", "title"))
+		assert.Equal(t, "Hello Alice", app.T("en", "greeting", "Alice"))
+
+		assert.Equal(t, "missing.key", app.T("en", "missing.key"))
+		_, ok := app.Translate("en", "missing.key")
+		assert.False(t, ok)
+
+		t.Run("Should use the LOCALE_DEFAULT locale", func(t *testing.T) {
+			t.Setenv("LOCALE_DEFAULT", "es")
+			assert.Equal(t, "Artículos", app.T("fr", "title"))
+		})
+	})
+
+	t.Run("Should select the plural forms", func(t *testing.T) {
+		app := newI18nApp(t)
+
+		assert.Equal(t, "No items", app.T("en", "items", 0))
+		assert.Equal(t, "1 item", app.T("en", "items", 1))
+		assert.Equal(t, "3 items", app.T("en", "items", 3))
+		// without one zero form
+		assert.Equal(t, "0 itens", app.T("pt-br", "items", 0))
+		assert.Equal(t, "1 item", app.T("pt-br", "items", int64(1)))
+	})
+
+	t.Run("Should detect the request locale", func(t *testing.T) {
+		app := newI18nApp(t)
+
+		detect := func(target, cookie, acceptLanguage string) string {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			if cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "locale", Value: cookie})
+			}
+			req.Header.Set("Accept-Language", acceptLanguage)
+			return app.DetectLocale(req)
+		}
+
+		assert.Equal(t, "pt-br", detect("/", "", "pt-BR,pt;q=0.9,en;q=0.8"))
+		assert.Equal(t, "pt-br", detect("/", "", "pt"))
+		assert.Equal(t, "es", detect("/", "", "fr, es-AR;q=0.5"))
+		assert.Equal(t, "en", detect("/", "", "fr, de"))
+		assert.Equal(t, "pt-br", detect("/?locale=en", "es", "pt-BR"))
+		assert.Equal(t, "es", detect("/?locale=en", "es", ""))
+		assert.Equal(t, "es", detect("/?locale=es", "", ""))
+		// unknown values use the next source
+		assert.Equal(t, "es", detect("/?locale=pt-BR", "es", "fr, de"))
+		assert.Equal(t, "pt-br", detect("/?locale=pt-BR", "de", "fr"))
+		assert.Equal(t, "en", detect("/?locale=fr", "de", "fr"))
+	})
+
+	t.Run("Should translate in the templates", func(t *testing.T) {
+		templates := t.TempDir()
+		assert.Nil(t, os.MkdirAll(filepath.Join(templates, "site"), 0755))
+		assert.Nil(t, os.WriteFile(filepath.Join(templates, "site", "i18n.html"), []byte(`{{ t "title" }} {{ t .Ctx "title" }} {{ t .Ctx "items" 2 }}`), 0644))
+
+		t.Setenv("LOCALES_FOLDER", locales)
+		app := newTestApp(t)
+		t.Setenv("TEMPLATE_DISABLE", "false")
+		t.Setenv("TEMPLATE_FOLDER", templates)
+		assert.Nil(t, app.Bootstrap())
+
+		var buf bytes.Buffer
+		ctx := &RequestContext{App: app, Locale: "pt-br"}
+		assert.Nil(t, app.RenderTemplate(&buf, "i18n", &TemplateCTX{Ctx: ctx}))
+		assert.Equal(t, "Articles Artigos 2 itens", buf.String())
+	})
+
+	t.Run("Should translate the error responses", func(t *testing.T) {
+		app := newI18nApp(t)
+		app.GetRouter().GET("/missing", func(c echo.Context) error {
+			return &HTTPError{Code: http.StatusNotFound, Message: http.StatusText(http.StatusNotFound)}
+		})
+		app.GetRouter().GET("/forbidden", func(c echo.Context) error {
+			return &HTTPError{Code: http.StatusForbidden, Message: "Custom message"}
+		})
+
+		request := func(path, acceptLanguage string) string {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set("Accept-Language", acceptLanguage)
+			rec := httptest.NewRecorder()
+			app.GetRouter().ServeHTTP(rec, req)
+			return rec.Body.String()
+		}
+
+		assert.Contains(t, request("/missing", "pt-BR"), `"message":"Página não encontrada"`)
+		assert.Contains(t, request("/missing", "en"), `"message":"Not Found"`)
+		// the default translations
+		assert.Contains(t, request("/unknown-route", "pt-BR"), `"message":"Página não encontrada"`)
+		// custom messages are not translated
+		assert.Contains(t, request("/forbidden", "pt-BR"), `"message":"Custom message"`)
+	})
+}
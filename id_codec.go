@@ -0,0 +1,191 @@
+package catu
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidID is returned by IDCodec.Decode for invalid or tampered IDs
+var ErrInvalidID = errors.New("invalid id")
+
+// IDCodec obfuscates resource IDs in public APIs.
+// Set it in one resource with App.SetResourceIDCodec and tag the model fields with `idcodec:"[resourceName]"`
+type IDCodec interface {
+	Encode(id int64) string
+	Decode(id string) (int64, error)
+}
+
+const hashIDAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// HashIDCodec is one hashids style IDCodec with one alphabet shuffled by the salt and a signed checksum.
+// Encoded IDs only have letters so they never collide with plain numeric IDs
+type HashIDCodec struct {
+	// Accept plain numeric IDs in Decode, use it while clients migrate to the encoded IDs
+	AcceptPlain bool
+
+	alphabet string
+	key      []byte
+}
+
+// NewHashIDCodec creates one codec keyed by the salt, ex: from ID_CODEC_SALT configuration
+func NewHashIDCodec(salt string) (*HashIDCodec, error) {
+	if salt == "" {
+		return nil, errors.New("catu.NewHashIDCodec salt is required")
+	}
+
+	sum := sha256.Sum256([]byte(salt))
+
+	return &HashIDCodec{
+		alphabet: consistentShuffle(hashIDAlphabet, hex.EncodeToString(sum[:])),
+		key:      []byte(salt),
+	}, nil
+}
+
+// Encode one non negative ID
+func (h *HashIDCodec) Encode(id int64) string {
+	check := h.checksum(id)
+	alphabet := h.rotate(check[0])
+
+	n := uint64(id)
+	base := uint64(len(alphabet))
+	payload := []byte{}
+	for {
+		payload = append(payload, alphabet[n%base])
+		n = n / base
+		if n == 0 {
+			break
+		}
+	}
+
+	// most significant digit first
+	for i, j := 0, len(payload)-1; i < j; i, j = i+1, j-1 {
+		payload[i], payload[j] = payload[j], payload[i]
+	}
+
+	return string(check[0]) + string(payload) + string(check[1:])
+}
+
+// Decode one ID, returns ErrInvalidID for invalid or tampered IDs
+func (h *HashIDCodec) Decode(id string) (int64, error) {
+	if h.AcceptPlain && id != "" && strings.Trim(id, "0123456789") == "" {
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return 0, ErrInvalidID
+		}
+		return n, nil
+	}
+
+	// prefix + payload + 2 checksum chars, max int64 has 12 chars in base 52
+	if len(id) < 4 || len(id) > 15 {
+		return 0, ErrInvalidID
+	}
+
+	alphabet := h.rotate(id[0])
+	base := uint64(len(alphabet))
+
+	var n uint64
+	for i := 1; i < len(id)-2; i++ {
+		d := strings.IndexByte(alphabet, id[i])
+		if d < 0 || n > (1<<63-1)/base {
+			return 0, ErrInvalidID
+		}
+		n = n*base + uint64(d)
+	}
+
+	if n > 1<<63-1 || h.Encode(int64(n)) != id {
+		return 0, ErrInvalidID
+	}
+
+	return int64(n), nil
+}
+
+func (h *HashIDCodec) checksum(id int64) []byte {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(strconv.FormatInt(id, 10)))
+	sum := mac.Sum(nil)
+
+	check := make([]byte, 3)
+	for i := range check {
+		check[i] = h.alphabet[int(sum[i])%len(h.alphabet)]
+	}
+
+	return check
+}
+
+// rotate the alphabet by the prefix char so sequential IDs don't share the same digits
+func (h *HashIDCodec) rotate(prefix byte) string {
+	i := strings.IndexByte(h.alphabet, prefix)
+	if i < 0 {
+		i = 0
+	}
+	return h.alphabet[i:] + h.alphabet[:i]
+}
+
+// consistentShuffle is the hashids alphabet shuffle
+func consistentShuffle(alphabet, salt string) string {
+	a := []byte(alphabet)
+	for i, v, p := len(a)-1, 0, 0; i > 0; i-- {
+		v %= len(salt)
+		p += int(salt[v])
+		j := (int(salt[v]) + v + p) % i
+		a[i], a[j] = a[j], a[i]
+		v++
+	}
+	return string(a)
+}
+
+// SetResourceIDCodec enables ID obfuscation for one resource registered with SetResource
+func (r *AppStruct) SetResourceIDCodec(name string, codec IDCodec) error {
+	resource := r.GetResource(name)
+	if resource == nil {
+		return errors.New("catu.App.SetResourceIDCodec resource not found: " + name)
+	}
+
+	resource.IDCodec = codec
+	return nil
+}
+
+// GetResourceIDCodec returns the resource IDCodec or nil if the resource IDs are not obfuscated
+func (r *AppStruct) GetResourceIDCodec(name string) IDCodec {
+	resource := r.GetResource(name)
+	if resource == nil {
+		return nil
+	}
+
+	return resource.IDCodec
+}
+
+// decodeResourceID middleware replaces the encoded :id route param with the numeric ID.
+// Invalid IDs respond with 404
+func decodeResourceID(app App, name string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			codec := app.GetResourceIDCodec(name)
+			if codec == nil {
+				return next(c)
+			}
+
+			id, err := codec.Decode(c.Param("id"))
+			if err != nil {
+				return &HTTPError{Code: http.StatusNotFound, Message: "Not Found"}
+			}
+
+			values := c.ParamValues()
+			for i, n := range c.ParamNames() {
+				if n == "id" && i < len(values) {
+					values[i] = strconv.FormatInt(id, 10)
+				}
+			}
+			c.SetParamValues(values...)
+
+			return next(c)
+		}
+	}
+}
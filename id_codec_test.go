@@ -0,0 +1,191 @@
+package catu
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashIDCodec(t *testing.T) {
+	codec, err := NewHashIDCodec("test-salt")
+	assert.Nil(t, err)
+
+	t.Run("Should encode and decode IDs", func(t *testing.T) {
+		ids := []int64{0, 1, 2, 3, 51, 52, 53, 1000, 123456789, math.MaxInt32, math.MaxInt64}
+		for i := int64(4); i < 2000; i += 7 {
+			ids = append(ids, i)
+		}
+
+		seen := map[string]int64{}
+		for _, id := range ids {
+			encoded := codec.Encode(id)
+			assert.Equal(t, "", strings.Trim(encoded, hashIDAlphabet))
+
+			decoded, err := codec.Decode(encoded)
+			assert.Nil(t, err, encoded)
+			assert.Equal(t, id, decoded)
+
+			if other, ok := seen[encoded]; ok {
+				assert.Equal(t, id, other, "collision")
+			}
+			seen[encoded] = id
+		}
+	})
+
+	t.Run("Should reject tampered IDs", func(t *testing.T) {
+		for _, id := range []int64{1, 2, 1000, 123456789} {
+			encoded := codec.Encode(id)
+
+			for i := range encoded {
+				for _, c := range []byte("aZq") {
+					if encoded[i] == c {
+						continue
+					}
+					tampered := encoded[:i] + string(c) + encoded[i+1:]
+					_, err := codec.Decode(tampered)
+					assert.Equal(t, ErrInvalidID, err, tampered)
+				}
+			}
+
+			for _, tampered := range []string{"", encoded[1:], encoded + "a", encoded[:len(encoded)-1], "1000", "aaaaaaaaaaaaaaaaaaaa"} {
+				_, err := codec.Decode(tampered)
+				assert.Equal(t, ErrInvalidID, err, tampered)
+			}
+		}
+	})
+
+	t.Run("Should use the salt", func(t *testing.T) {
+		other, err := NewHashIDCodec("other-salt")
+		assert.Nil(t, err)
+
+		assert.NotEqual(t, codec.Encode(10), other.Encode(10))
+		_, err = other.Decode(codec.Encode(10))
+		assert.Equal(t, ErrInvalidID, err)
+
+		_, err = NewHashIDCodec("")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should accept plain IDs in migration mode", func(t *testing.T) {
+		migration, err := NewHashIDCodec("test-salt")
+		assert.Nil(t, err)
+		migration.AcceptPlain = true
+
+		id, err := migration.Decode("1000")
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1000), id)
+
+		id, err = migration.Decode(codec.Encode(1000))
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1000), id)
+
+		_, err = migration.Decode("99999999999999999999")
+		assert.Equal(t, ErrInvalidID, err)
+	})
+}
+
+type idCodecTestAuthor struct {
+	ID   uint64 `json:"id" idcodec:"authors"`
+	Name string `json:"name"`
+}
+
+type idCodecTestBase struct {
+	ID        uint64 `json:"id" idcodec:"articles"`
+	CreatedAt string `json:"createdAt,omitempty"`
+}
+
+type idCodecTestArticle struct {
+	idCodecTestBase
+	Title    string             `json:"title"`
+	AuthorID *uint64            `json:"authorId" idcodec:"authors"`
+	Author   *idCodecTestAuthor `json:"author,omitempty"`
+	EditorID uint64             `json:"editorId,omitempty" idcodec:"authors"`
+	Views    int64              `json:"views"`
+	Secret   string             `json:"-"`
+	internal string
+}
+
+type idCodecTestController struct{}
+
+func (ctl *idCodecTestController) Query(c echo.Context) error {
+	authorID := uint64(7)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"articles": []idCodecTestArticle{
+			{idCodecTestBase: idCodecTestBase{ID: 1}, Title: "One", AuthorID: &authorID, Author: &idCodecTestAuthor{ID: 7, Name: "Ana"}, Secret: "s", internal: "i"},
+			{idCodecTestBase: idCodecTestBase{ID: 2, CreatedAt: "today"}, Title: "Two", Views: 3},
+		},
+	})
+}
+
+func (ctl *idCodecTestController) FindOne(c echo.Context) error {
+	return c.String(http.StatusOK, c.Param("id"))
+}
+
+func (ctl *idCodecTestController) Create(c echo.Context) error { return nil }
+func (ctl *idCodecTestController) Count(c echo.Context) error  { return nil }
+func (ctl *idCodecTestController) Update(c echo.Context) error { return nil }
+func (ctl *idCodecTestController) Delete(c echo.Context) error { return nil }
+
+func TestResourceIDCodec(t *testing.T) {
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+
+	api := app.GetRouterGroup("api")
+	app.SetResource("articles", &idCodecTestController{}, api.Group("/articles"))
+	app.SetResource("authors", &idCodecTestController{}, api.Group("/authors"))
+
+	request := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Should keep numeric IDs without codecs", func(t *testing.T) {
+		rec := request("/api/articles")
+		assert.JSONEq(t, `{"articles":[
+			{"id":1,"title":"One","authorId":7,"author":{"id":7,"name":"Ana"},"views":0},
+			{"id":2,"createdAt":"today","title":"Two","authorId":null,"views":3}
+		]}`, rec.Body.String())
+
+		assert.Equal(t, "10", request("/api/articles/10").Body.String())
+	})
+
+	articlesCodec, _ := NewHashIDCodec("articles-salt")
+	authorsCodec, _ := NewHashIDCodec("authors-salt")
+	assert.Nil(t, app.SetResourceIDCodec("articles", articlesCodec))
+	assert.Nil(t, app.SetResourceIDCodec("authors", authorsCodec))
+	assert.NotNil(t, app.SetResourceIDCodec("unknown", authorsCodec))
+
+	t.Run("Should encode IDs and relation IDs in responses", func(t *testing.T) {
+		rec := request("/api/articles")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `{"articles":[`+
+			`{"id":"`+articlesCodec.Encode(1)+`","title":"One","authorId":"`+authorsCodec.Encode(7)+`","author":{"id":"`+authorsCodec.Encode(7)+`","name":"Ana"},"views":0},`+
+			`{"id":"`+articlesCodec.Encode(2)+`","createdAt":"today","title":"Two","authorId":null,"views":3}`+
+			`]}`+"\n", rec.Body.String())
+	})
+
+	t.Run("Should decode the :id route param", func(t *testing.T) {
+		rec := request("/api/articles/" + articlesCodec.Encode(10))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "10", rec.Body.String())
+
+		assert.Equal(t, http.StatusNotFound, request("/api/articles/10").Code)
+		assert.Equal(t, http.StatusNotFound, request("/api/articles/"+authorsCodec.Encode(10)).Code)
+	})
+
+	t.Run("Should accept both forms in migration mode", func(t *testing.T) {
+		articlesCodec.AcceptPlain = true
+		defer func() { articlesCodec.AcceptPlain = false }()
+
+		assert.Equal(t, "10", request("/api/articles/10").Body.String())
+		assert.Equal(t, "10", request("/api/articles/"+articlesCodec.Encode(10)).Body.String())
+	})
+}
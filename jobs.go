@@ -0,0 +1,645 @@
+package catu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gookit/event"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Job statuses
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// EventJobFailed is triggered after the last attempt of one job fails, with the "job" and the "error"
+const EventJobFailed = "job.failed"
+
+// max delay between two attempts
+const maxJobRetryDelay = time.Hour
+
+// JobHandler runs one job, returned errors retry the job until its MaxAttempts
+type JobHandler func(ctx context.Context, job *JobRecord) error
+
+// JobRecord is one job in the jobs table
+type JobRecord struct {
+	ID   uint64 `gorm:"column:id;primaryKey" json:"id"`
+	Name string `gorm:"column:name;size:100;index" json:"name"`
+	// JSON encoded payload, see Bind
+	Payload     string    `gorm:"column:payload;type:text" json:"payload"`
+	Status      string    `gorm:"column:status;size:20;index:idx_jobs_status_run_at" json:"status"`
+	Attempts    int       `gorm:"column:attempts" json:"attempts"`
+	MaxAttempts int       `gorm:"column:max_attempts" json:"maxAttempts"`
+	RunAt       time.Time `gorm:"column:run_at;index:idx_jobs_status_run_at" json:"runAt"`
+	LastError   string    `gorm:"column:last_error;type:text" json:"lastError,omitempty"`
+	// token of the job progress, see EnqueueJobWithProgress
+	ProgressToken string `gorm:"column:progress_token;size:64" json:"progressToken,omitempty"`
+	// Claim of one running job, renewed by the worker heartbeat. Expired claims are claimed again, see DBJobStore
+	LockedUntil *time.Time `gorm:"column:locked_until" json:"lockedUntil,omitempty"`
+	CreatedAt   time.Time  `gorm:"column:created_at" json:"createdAt"`
+	UpdatedAt   time.Time  `gorm:"column:updated_at" json:"updatedAt"`
+}
+
+func (JobRecord) TableName() string {
+	return "jobs"
+}
+
+// Bind decodes the job payload in v
+func (j *JobRecord) Bind(v interface{}) error {
+	return json.Unmarshal([]byte(j.Payload), v)
+}
+
+// JobStore persists the jobs, see JOB_STORE
+type JobStore interface {
+	Enqueue(ctx context.Context, job *JobRecord) error
+	// Claim marks the next pending job with RunAt before now as running, returns nil without jobs to run
+	Claim(ctx context.Context, now time.Time) (*JobRecord, error)
+	// Save the job status, attempts, RunAt and LastError
+	Update(ctx context.Context, job *JobRecord) error
+}
+
+// HeartbeatJobStore is one JobStore with claims that expire, the workers renew the claim while the job runs
+type HeartbeatJobStore interface {
+	JobStore
+	// Heartbeat renews the claim of one running job
+	Heartbeat(ctx context.Context, job *JobRecord, now time.Time) error
+	// Time between two heartbeats, shorter than the claim duration
+	HeartbeatInterval() time.Duration
+}
+
+// default DBJobStore LockTimeout
+const defaultJobLockTimeout = 5 * time.Minute
+
+// DBJobStore stores the jobs in the jobs table, shared by all app instances.
+// The running jobs of one stopped instance are claimed again after the LockTimeout
+type DBJobStore struct {
+	DB *gorm.DB
+	// Claim duration of the running jobs, renewed every LockTimeout/3. Default 5 minutes, see JOB_LOCK_TIMEOUT
+	LockTimeout time.Duration
+}
+
+func NewDBJobStore(db *gorm.DB) *DBJobStore {
+	return &DBJobStore{DB: db, LockTimeout: defaultJobLockTimeout}
+}
+
+func (s *DBJobStore) lockTimeout() time.Duration {
+	if s.LockTimeout <= 0 {
+		return defaultJobLockTimeout
+	}
+	return s.LockTimeout
+}
+
+func (s *DBJobStore) HeartbeatInterval() time.Duration {
+	return s.lockTimeout() / 3
+}
+
+func (s *DBJobStore) Heartbeat(ctx context.Context, job *JobRecord, now time.Time) error {
+	err := s.DB.WithContext(ctx).Model(&JobRecord{}).
+		Where("id = ? AND status = ?", job.ID, JobRunning).
+		Update("locked_until", now.Add(s.lockTimeout())).Error
+	if err != nil {
+		return errors.Wrap(err, "catu.DBJobStore.Heartbeat error on update job")
+	}
+	return nil
+}
+
+// Migrate creates the jobs table
+func (s *DBJobStore) Migrate() error {
+	return s.DB.AutoMigrate(&JobRecord{})
+}
+
+func (s *DBJobStore) Enqueue(ctx context.Context, job *JobRecord) error {
+	if err := s.DB.WithContext(ctx).Create(job).Error; err != nil {
+		return errors.Wrap(err, "catu.DBJobStore.Enqueue error on create job")
+	}
+	return nil
+}
+
+// Claim claims one pending job or one running job with one expired claim, ex: the job of one stopped instance
+func (s *DBJobStore) Claim(ctx context.Context, now time.Time) (*JobRecord, error) {
+	db := s.DB.WithContext(ctx)
+	claimable := "(status = ? AND run_at <= ?) OR (status = ? AND (locked_until IS NULL OR locked_until < ?))"
+	lockedUntil := now.Add(s.lockTimeout())
+
+	// other workers may claim the same job first
+	for i := 0; i < 3; i++ {
+		job := &JobRecord{}
+		err := db.Where(claimable, JobPending, now, JobRunning, now).Order("run_at, id").Limit(1).Find(job).Error
+		if err != nil {
+			return nil, errors.Wrap(err, "catu.DBJobStore.Claim error on find job")
+		}
+		if job.ID == 0 {
+			return nil, nil
+		}
+
+		result := db.Model(&JobRecord{}).
+			Where("id = ?", job.ID).
+			Where(claimable, JobPending, now, JobRunning, now).
+			Updates(map[string]interface{}{"status": JobRunning, "locked_until": lockedUntil, "updated_at": now})
+		if result.Error != nil {
+			return nil, errors.Wrap(result.Error, "catu.DBJobStore.Claim error on update job")
+		}
+		if result.RowsAffected == 1 {
+			if job.Status == JobRunning {
+				logrus.WithFields(logrus.Fields{
+					"id":   job.ID,
+					"name": job.Name,
+				}).Warn("catu.DBJobStore.Claim job claim expired, running it again")
+			}
+
+			job.Status = JobRunning
+			job.LockedUntil = &lockedUntil
+			job.UpdatedAt = now
+			return job, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *DBJobStore) Update(ctx context.Context, job *JobRecord) error {
+	err := s.DB.WithContext(ctx).Model(&JobRecord{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+		"status":     job.Status,
+		"attempts":   job.Attempts,
+		"run_at":     job.RunAt,
+		"last_error": job.LastError,
+		"updated_at": job.UpdatedAt,
+	}).Error
+	if err != nil {
+		return errors.Wrap(err, "catu.DBJobStore.Update error on update job")
+	}
+	return nil
+}
+
+// MemoryJobStore keeps the jobs in memory, the jobs are lost on restart. Use it in tests
+type MemoryJobStore struct {
+	mu     sync.Mutex
+	lastID uint64
+	jobs   map[uint64]*JobRecord
+}
+
+func NewMemoryJobStore() *MemoryJobStore {
+	return &MemoryJobStore{jobs: map[uint64]*JobRecord{}}
+}
+
+func (s *MemoryJobStore) Enqueue(ctx context.Context, job *JobRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	job.ID = s.lastID
+	stored := *job
+	s.jobs[job.ID] = &stored
+	return nil
+}
+
+func (s *MemoryJobStore) Claim(ctx context.Context, now time.Time) (*JobRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next *JobRecord
+	for _, job := range s.jobs {
+		if job.Status != JobPending || job.RunAt.After(now) {
+			continue
+		}
+		if next == nil || job.RunAt.Before(next.RunAt) || (job.RunAt.Equal(next.RunAt) && job.ID < next.ID) {
+			next = job
+		}
+	}
+
+	if next == nil {
+		return nil, nil
+	}
+
+	next.Status = JobRunning
+	next.UpdatedAt = now
+	claimed := *next
+	return &claimed, nil
+}
+
+func (s *MemoryJobStore) Update(ctx context.Context, job *JobRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.jobs[job.ID] == nil {
+		return errors.New("catu.MemoryJobStore.Update job not found")
+	}
+	stored := *job
+	s.jobs[job.ID] = &stored
+	return nil
+}
+
+// Jobs returns one copy of the stored jobs sorted by ID
+func (s *MemoryJobStore) Jobs() []JobRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]JobRecord, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs
+}
+
+// jobRunner dispatches the jobs to the registered handlers
+type jobRunner struct {
+	mu       sync.RWMutex
+	store    JobStore
+	handlers map[string]JobHandler
+	// wakes up one idle worker after EnqueueJob
+	notify chan struct{}
+
+	workersMu sync.Mutex
+	cancel    context.CancelFunc
+	workers   sync.WaitGroup
+}
+
+func newJobRunner() *jobRunner {
+	return &jobRunner{
+		handlers: map[string]JobHandler{},
+		notify:   make(chan struct{}, 1),
+	}
+}
+
+func (r *AppStruct) GetJobStore() JobStore {
+	r.jobs.mu.RLock()
+	defer r.jobs.mu.RUnlock()
+
+	return r.jobs.store
+}
+
+// SetJobStore replaces the JOB_STORE store, set it in the "configuration" event
+func (r *AppStruct) SetJobStore(store JobStore) {
+	r.jobs.mu.Lock()
+	defer r.jobs.mu.Unlock()
+
+	r.jobs.store = store
+}
+
+// RegisterJobHandler sets the handler of the jobs with the name, replacing the current handler
+func (r *AppStruct) RegisterJobHandler(name string, fn JobHandler) {
+	r.jobs.mu.Lock()
+	defer r.jobs.mu.Unlock()
+
+	r.jobs.handlers[name] = fn
+}
+
+// EnqueueJob stores one job to run in the workers, the payload is JSON encoded. See StartWorkers
+func (r *AppStruct) EnqueueJob(name string, payload interface{}) error {
+	return r.EnqueueJobWithContext(context.Background(), name, payload)
+}
+
+// EnqueueJobWithContext is EnqueueJob recorded as one side effect in dry run requests, see SideEffect
+func (r *AppStruct) EnqueueJobWithContext(ctx context.Context, name string, payload interface{}) error {
+	return r.enqueueJob(ctx, name, payload, "")
+}
+
+// EnqueueJobWithProgress is EnqueueJobWithContext with one progress owned by userID, so the client can read it
+// in GET /_progress/:token. The job handlers report it with JobProgress, the workers complete it after the job
+// runs and fail it after the last attempt
+func (r *AppStruct) EnqueueJobWithProgress(ctx context.Context, name string, payload interface{}, userID string, total int64) (*Progress, error) {
+	p, err := NewProgress(r, userID, total)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.enqueueJob(ctx, name, payload, p.Token()); err != nil {
+		p.Fail(err)
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (r *AppStruct) enqueueJob(ctx context.Context, name string, payload interface{}, progressToken string) error {
+	store := r.GetJobStore()
+	if store == nil {
+		return errors.New("catu.App.EnqueueJob job store not started, call it after the Bootstrap")
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "catu.App.EnqueueJob error on encode payload")
+	}
+
+	now := time.Now()
+	job := &JobRecord{
+		Name:          name,
+		Payload:       string(data),
+		Status:        JobPending,
+		MaxAttempts:   int(r.Configuration.GetInt64F("JOB_MAX_ATTEMPTS", 5)),
+		RunAt:         now,
+		ProgressToken: progressToken,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	return SideEffect(ctx, "job", "enqueue "+name, payload, func() error {
+		if err := store.Enqueue(ctx, job); err != nil {
+			return err
+		}
+
+		select {
+		case r.jobs.notify <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+}
+
+// StartWorkers starts n workers running the pending jobs until ctx is canceled or the app shutdown.
+// Failed jobs are retried after JOB_RETRY_DELAY milliseconds (default 1000), doubled on each attempt.
+// Idle workers check new jobs every JOB_POLL_INTERVAL milliseconds, default 1000
+func (r *AppStruct) StartWorkers(ctx context.Context, n int) error {
+	if r.GetJobStore() == nil {
+		return errors.New("catu.App.StartWorkers job store not started, call it after the Bootstrap")
+	}
+
+	r.jobs.workersMu.Lock()
+	defer r.jobs.workersMu.Unlock()
+
+	if r.jobs.cancel != nil {
+		return errors.New("catu.App.StartWorkers workers already started")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r.jobs.cancel = cancel
+
+	interval := time.Duration(r.Configuration.GetInt64F("JOB_POLL_INTERVAL", 1000)) * time.Millisecond
+
+	for i := 0; i < n; i++ {
+		r.jobs.workers.Add(1)
+		go func() {
+			defer r.jobs.workers.Done()
+			r.runWorker(ctx, interval)
+		}()
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"workers": n,
+	}).Debug("catu.App.StartWorkers workers started")
+
+	return nil
+}
+
+// StopWorkers stops the workers and waits for the running jobs until SHUTDOWN_TIMEOUT (default 10s),
+// it is called in the app shutdown. The DBJobStore jobs still running are claimed again after the claim expires
+func (r *AppStruct) StopWorkers() {
+	r.jobs.workersMu.Lock()
+	defer r.jobs.workersMu.Unlock()
+
+	if r.jobs.cancel == nil {
+		return
+	}
+
+	r.jobs.cancel()
+	r.jobs.cancel = nil
+
+	done := make(chan struct{})
+	go func() {
+		r.jobs.workers.Wait()
+		close(done)
+	}()
+
+	timeout := r.getShutdownTimeout()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		logrus.WithFields(logrus.Fields{
+			"timeout": timeout.String(),
+		}).Warn("catu.App.StopWorkers timeout, stopping with running jobs")
+	}
+}
+
+func (r *AppStruct) runWorker(ctx context.Context, interval time.Duration) {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		ran, err := r.runNextJob()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": fmt.Sprintf("%+v\n", err),
+			}).Error("catu.App.runWorker error on run job")
+		}
+		if ran {
+			continue
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(interval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.jobs.notify:
+		case <-timer.C:
+		}
+	}
+}
+
+// runNextJob runs one pending job, returns false without pending jobs.
+// The job runs with one new context so the shutdown waits for it
+func (r *AppStruct) runNextJob() (bool, error) {
+	ctx := context.Background()
+	store := r.GetJobStore()
+
+	job, err := store.Claim(ctx, time.Now())
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	r.jobs.mu.RLock()
+	handler := r.jobs.handlers[job.Name]
+	r.jobs.mu.RUnlock()
+
+	job.Attempts++
+
+	var progress *Progress
+	if job.ProgressToken != "" {
+		if progress = openProgress(r, job.ProgressToken); progress != nil {
+			ctx = context.WithValue(ctx, jobProgressKey{}, progress)
+		}
+	}
+
+	if handler == nil {
+		err = errors.New("job handler not registered: " + job.Name)
+		// no retries
+		job.Attempts = job.MaxAttempts
+	} else {
+		stopHeartbeat := startJobHeartbeat(store, job)
+		err = runJobHandler(ctx, handler, job)
+		stopHeartbeat()
+	}
+
+	now := time.Now()
+	job.UpdatedAt = now
+
+	switch {
+	case err == nil:
+		job.Status = JobCompleted
+		job.LastError = ""
+	case job.Attempts < job.MaxAttempts:
+		job.Status = JobPending
+		job.LastError = err.Error()
+		job.RunAt = now.Add(r.jobRetryDelay(job.Attempts))
+	default:
+		job.Status = JobFailed
+		job.LastError = err.Error()
+	}
+
+	if progress != nil {
+		var progressErr error
+		switch job.Status {
+		case JobCompleted:
+			progressErr = progress.Complete()
+		case JobFailed:
+			progressErr = progress.Fail(err)
+		}
+		if progressErr != nil {
+			logrus.WithFields(logrus.Fields{
+				"id":    job.ID,
+				"error": progressErr.Error(),
+			}).Error("catu.App error on save job progress")
+		}
+	}
+
+	if updateErr := store.Update(ctx, job); updateErr != nil {
+		return true, updateErr
+	}
+
+	if job.Status == JobFailed {
+		logrus.WithFields(logrus.Fields{
+			"id":       job.ID,
+			"name":     job.Name,
+			"attempts": job.Attempts,
+			"error":    job.LastError,
+		}).Warn("catu.App job failed")
+
+		if err, _ := r.Events.Fire(EventJobFailed, event.M{"app": r, "job": job, "error": err}); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": fmt.Sprintf("%+v\n", err),
+			}).Error("catu.App error on job failed event")
+		}
+	}
+
+	return true, nil
+}
+
+// startJobHeartbeat renews the job claim of one HeartbeatJobStore until the returned stop func is called
+func startJobHeartbeat(store JobStore, job *JobRecord) func() {
+	hs, ok := store.(HeartbeatJobStore)
+	if !ok || hs.HeartbeatInterval() <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(hs.HeartbeatInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				if err := hs.Heartbeat(context.Background(), job, now); err != nil {
+					logrus.WithFields(logrus.Fields{
+						"id":    job.ID,
+						"error": err.Error(),
+					}).Error("catu.App error on job heartbeat")
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+type jobProgressKey struct{}
+
+// JobProgress returns the progress of the running job, nil for jobs enqueued without EnqueueJobWithProgress
+func JobProgress(ctx context.Context) ProgressReporter {
+	if p, ok := ctx.Value(jobProgressKey{}).(*Progress); ok {
+		return p
+	}
+	return nil
+}
+
+func runJobHandler(ctx context.Context, handler JobHandler, job *JobRecord) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("job handler panic: %v", rec)
+		}
+	}()
+
+	return handler(ctx, job)
+}
+
+// jobRetryDelay is JOB_RETRY_DELAY doubled on each attempt, max one hour
+func (r *AppStruct) jobRetryDelay(attempts int) time.Duration {
+	base := time.Duration(r.Configuration.GetInt64F("JOB_RETRY_DELAY", 1000)) * time.Millisecond
+
+	delay := float64(base) * math.Pow(2, float64(attempts-1))
+	if delay > float64(maxJobRetryDelay) {
+		return maxJobRetryDelay
+	}
+	return time.Duration(delay)
+}
+
+// initJobStore creates the JOB_STORE store (db or memory, default db) and stops the workers in the shutdown.
+// The db store creates the jobs table in the "migrate" event
+func (r *AppStruct) initJobStore() error {
+	r.Events.On("shutdown", event.ListenerFunc(func(e event.Event) error {
+		r.StopWorkers()
+		return nil
+	}), event.High)
+
+	if r.GetJobStore() != nil {
+		return nil
+	}
+
+	switch r.Configuration.GetF("JOB_STORE", "db") {
+	case "db":
+		store := NewDBJobStore(r.GetDB())
+		store.LockTimeout = time.Duration(r.Configuration.GetInt64F("JOB_LOCK_TIMEOUT", int64(defaultJobLockTimeout/time.Millisecond))) * time.Millisecond
+		r.SetJobStore(store)
+
+		r.Events.On("migrate", event.ListenerFunc(func(e event.Event) error {
+			return store.Migrate()
+		}), event.Normal)
+	case "memory":
+		r.SetJobStore(NewMemoryJobStore())
+	default:
+		return errors.New("catu.App invalid JOB_STORE: " + r.Configuration.Get("JOB_STORE"))
+	}
+
+	return nil
+}
@@ -0,0 +1,321 @@
+package catu
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gookit/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobs(t *testing.T) {
+	t.Setenv("JOB_STORE", "memory")
+	t.Setenv("JOB_POLL_INTERVAL", "10")
+	t.Setenv("JOB_RETRY_DELAY", "1")
+
+	waitJobs := func(t *testing.T, store *MemoryJobStore, status string, count int) []JobRecord {
+		var jobs []JobRecord
+		assert.Eventually(t, func() bool {
+			jobs = store.Jobs()
+			done := 0
+			for _, job := range jobs {
+				if job.Status == status {
+					done++
+				}
+			}
+			return done == count
+		}, 2*time.Second, 5*time.Millisecond)
+		return jobs
+	}
+
+	t.Run("Should run the enqueued jobs", func(t *testing.T) {
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		store := app.GetJobStore().(*MemoryJobStore)
+
+		received := make(chan string, 3)
+		app.RegisterJobHandler("email", func(ctx context.Context, job *JobRecord) error {
+			var payload struct {
+				To string `json:"to"`
+			}
+			if err := job.Bind(&payload); err != nil {
+				return err
+			}
+			received <- payload.To
+			return nil
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		assert.Nil(t, app.StartWorkers(ctx, 2))
+		assert.NotNil(t, app.StartWorkers(ctx, 1))
+
+		for _, to := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+			assert.Nil(t, app.EnqueueJob("email", map[string]string{"to": to}))
+		}
+
+		jobs := waitJobs(t, store, JobCompleted, 3)
+		assert.Len(t, received, 3)
+		for _, job := range jobs {
+			assert.Equal(t, 1, job.Attempts)
+			assert.Equal(t, 5, job.MaxAttempts)
+		}
+
+		app.StopWorkers()
+	})
+
+	t.Run("Should retry the failed jobs with backoff and fire job.failed", func(t *testing.T) {
+		t.Setenv("JOB_MAX_ATTEMPTS", "3")
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		store := app.GetJobStore().(*MemoryJobStore)
+
+		var flakyCalls, brokenCalls int32
+		app.RegisterJobHandler("flaky", func(ctx context.Context, job *JobRecord) error {
+			if atomic.AddInt32(&flakyCalls, 1) < 2 {
+				return errors.New("temporary error")
+			}
+			return nil
+		})
+		app.RegisterJobHandler("broken", func(ctx context.Context, job *JobRecord) error {
+			if atomic.AddInt32(&brokenCalls, 1) == 2 {
+				panic("broken job")
+			}
+			return errors.New("broken job error")
+		})
+
+		failed := make(chan *JobRecord, 3)
+		app.GetEvents().On(EventJobFailed, event.ListenerFunc(func(e event.Event) error {
+			failed <- e.Get("job").(*JobRecord)
+			return nil
+		}))
+
+		assert.Nil(t, app.EnqueueJob("flaky", nil))
+		assert.Nil(t, app.EnqueueJob("broken", nil))
+		assert.Nil(t, app.EnqueueJob("unknown", nil))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		assert.Nil(t, app.StartWorkers(ctx, 1))
+
+		jobs := waitJobs(t, store, JobFailed, 2)
+		app.StopWorkers()
+
+		assert.Equal(t, JobCompleted, jobs[0].Status)
+		assert.Equal(t, 2, jobs[0].Attempts)
+		assert.Empty(t, jobs[0].LastError)
+
+		assert.Equal(t, 3, jobs[1].Attempts)
+		assert.Equal(t, "broken job error", jobs[1].LastError)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&brokenCalls))
+
+		// jobs without handler are not retried
+		assert.Equal(t, 3, jobs[2].Attempts)
+		assert.Contains(t, jobs[2].LastError, "job handler not registered")
+
+		assert.Len(t, failed, 2)
+	})
+
+	t.Run("Should double the retry delay on each attempt", func(t *testing.T) {
+		t.Setenv("JOB_RETRY_DELAY", "1000")
+		app := newTestApp(t).(*AppStruct)
+
+		assert.Equal(t, time.Second, app.jobRetryDelay(1))
+		assert.Equal(t, 2*time.Second, app.jobRetryDelay(2))
+		assert.Equal(t, 8*time.Second, app.jobRetryDelay(4))
+		assert.Equal(t, time.Hour, app.jobRetryDelay(30))
+	})
+
+	t.Run("Should wait for the running jobs in the shutdown", func(t *testing.T) {
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		store := app.GetJobStore().(*MemoryJobStore)
+
+		started := make(chan struct{})
+		app.RegisterJobHandler("slow", func(ctx context.Context, job *JobRecord) error {
+			close(started)
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		})
+
+		assert.Nil(t, app.StartWorkers(context.Background(), 1))
+		assert.Nil(t, app.EnqueueJob("slow", nil))
+		<-started
+
+		app.(*AppStruct).shutdown()
+
+		jobs := store.Jobs()
+		assert.Equal(t, JobCompleted, jobs[0].Status)
+	})
+
+	t.Run("Should stop waiting the running jobs after the shutdown timeout", func(t *testing.T) {
+		t.Setenv("SHUTDOWN_TIMEOUT", "20ms")
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		app.RegisterJobHandler("stuck", func(ctx context.Context, job *JobRecord) error {
+			close(started)
+			<-release
+			return nil
+		})
+
+		assert.Nil(t, app.StartWorkers(context.Background(), 1))
+		assert.Nil(t, app.EnqueueJob("stuck", nil))
+		<-started
+
+		stopped := make(chan struct{})
+		go func() {
+			app.StopWorkers()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("StopWorkers without timeout")
+		}
+	})
+
+	t.Run("Should claim again the running jobs with one expired claim", func(t *testing.T) {
+		t.Setenv("JOB_STORE", "db")
+		t.Setenv("JOB_LOCK_TIMEOUT", "60")
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		assert.Nil(t, app.Migrate())
+		store := app.GetJobStore().(*DBJobStore)
+		assert.Equal(t, 60*time.Millisecond, store.LockTimeout)
+
+		assert.Nil(t, app.EnqueueJob("report", nil))
+		now := time.Now()
+		job, err := store.Claim(context.Background(), now)
+		assert.Nil(t, err)
+		if assert.NotNil(t, job) {
+			assert.Equal(t, JobRunning, job.Status)
+		}
+
+		// the claim is valid until the lock timeout
+		again, err := store.Claim(context.Background(), now.Add(30*time.Millisecond))
+		assert.Nil(t, err)
+		assert.Nil(t, again)
+
+		// the heartbeat renews the claim
+		assert.Nil(t, store.Heartbeat(context.Background(), job, now.Add(50*time.Millisecond)))
+		again, err = store.Claim(context.Background(), now.Add(100*time.Millisecond))
+		assert.Nil(t, err)
+		assert.Nil(t, again)
+
+		// one stopped worker
+		again, err = store.Claim(context.Background(), now.Add(120*time.Millisecond))
+		assert.Nil(t, err)
+		if assert.NotNil(t, again) {
+			assert.Equal(t, job.ID, again.ID)
+		}
+	})
+
+	t.Run("Should store the jobs in the database", func(t *testing.T) {
+		t.Setenv("JOB_STORE", "db")
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		assert.Nil(t, app.Migrate())
+
+		done := make(chan uint64, 1)
+		app.RegisterJobHandler("report", func(ctx context.Context, job *JobRecord) error {
+			done <- job.ID
+			return nil
+		})
+
+		assert.Nil(t, app.EnqueueJob("report", map[string]int{"id": 10}))
+		assert.Nil(t, app.StartWorkers(context.Background(), 2))
+		defer app.StopWorkers()
+
+		var id uint64
+		select {
+		case id = <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("job not run")
+		}
+
+		assert.Eventually(t, func() bool {
+			job := JobRecord{}
+			app.GetDB().First(&job, id)
+			return job.Status == JobCompleted
+		}, 2*time.Second, 5*time.Millisecond)
+
+		job := JobRecord{}
+		assert.Nil(t, app.GetDB().First(&job, id).Error)
+		assert.Equal(t, `{"id":10}`, job.Payload)
+		assert.Equal(t, 1, job.Attempts)
+	})
+
+	t.Run("Should report the job progress", func(t *testing.T) {
+		t.Setenv("JOB_MAX_ATTEMPTS", "1")
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		store := app.GetJobStore().(*MemoryJobStore)
+
+		steps := make(chan ProgressState, 1)
+		app.RegisterJobHandler("import", func(ctx context.Context, job *JobRecord) error {
+			progress := JobProgress(ctx)
+			if job.Payload == "false" {
+				return errors.New("invalid file")
+			}
+			if err := progress.Advance(2, "rows"); err != nil {
+				return err
+			}
+			steps <- *GetProgress(app, progress.Token())
+			return nil
+		})
+		app.RegisterJobHandler("email", func(ctx context.Context, job *JobRecord) error {
+			assert.Nil(t, JobProgress(ctx))
+			return nil
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		assert.Nil(t, app.StartWorkers(ctx, 1))
+		defer app.StopWorkers()
+
+		progress, err := app.EnqueueJobWithProgress(context.Background(), "import", true, "7", 4)
+		assert.Nil(t, err)
+		failed, err := app.EnqueueJobWithProgress(context.Background(), "import", false, "7", 4)
+		assert.Nil(t, err)
+		assert.Nil(t, app.EnqueueJob("email", nil))
+
+		jobs := waitJobs(t, store, JobCompleted, 2)
+		assert.Equal(t, progress.Token(), jobs[0].ProgressToken)
+		assert.Empty(t, jobs[2].ProgressToken)
+
+		step := <-steps
+		assert.Equal(t, ProgressRunning, step.Status)
+		assert.Equal(t, int64(2), step.Current)
+		assert.Equal(t, "rows", step.Step)
+
+		state := GetProgress(app, progress.Token())
+		assert.Equal(t, ProgressCompleted, state.Status)
+		assert.Equal(t, "7", state.UserID)
+		assert.Equal(t, int64(4), state.Current)
+
+		waitJobs(t, store, JobFailed, 1)
+		state = GetProgress(app, failed.Token())
+		assert.Equal(t, ProgressFailed, state.Status)
+		assert.Equal(t, "invalid file", state.Error)
+	})
+
+	t.Run("Should not enqueue jobs in dry run requests", func(t *testing.T) {
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		store := app.GetJobStore().(*MemoryJobStore)
+
+		summary := &DryRunSummary{}
+		ctx := context.WithValue(context.Background(), dryRunContextKey{}, summary)
+		assert.Nil(t, app.EnqueueJobWithContext(ctx, "email", nil))
+		assert.Empty(t, store.Jobs())
+		assert.Equal(t, "job", summary.Effects()[0].Type)
+	})
+}
@@ -0,0 +1,334 @@
+package catu
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// JSONSerializer is the app echo JSON serializer.
+// Encodes model fields tagged with `idcodec:"[resourceName]"` with the resource IDCodec, see App.SetResourceIDCodec
+type JSONSerializer struct {
+	echo.DefaultJSONSerializer
+	app App
+}
+
+func (s *JSONSerializer) Serialize(c echo.Context, i interface{}, indent string) error {
+	enc := json.NewEncoder(c.Response())
+	if indent != "" {
+		enc.SetIndent("", indent)
+	}
+
+	if !s.hasIDCodecs() {
+		return enc.Encode(i)
+	}
+
+	return enc.Encode(s.encodeIDs(reflect.ValueOf(i)))
+}
+
+func (s *JSONSerializer) hasIDCodecs() bool {
+	for _, r := range s.app.GetResources() {
+		if r.IDCodec != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeIDs returns one value with the same json output of v but with the tagged IDs encoded
+func (s *JSONSerializer) encodeIDs(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	if !mayHaveIDFields(v.Type()) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return s.encodeIDs(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = s.encodeIDs(v.Index(i))
+		}
+		return items
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := reflect.MakeMapWithSize(reflect.MapOf(v.Type().Key(), emptyInterfaceType), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			item := reflect.ValueOf(s.encodeIDs(iter.Value()))
+			if !item.IsValid() {
+				item = reflect.Zero(emptyInterfaceType)
+			}
+			m.SetMapIndex(iter.Key(), item)
+		}
+		return m.Interface()
+	case reflect.Struct:
+		obj := orderedObject{}
+		for _, f := range cachedJSONFields(v.Type()) {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+				continue
+			}
+
+			if f.codec != "" {
+				if codec := s.app.GetResourceIDCodec(f.codec); codec != nil {
+					obj = append(obj, objectField{name: f.name, value: encodeIDValue(codec, fv)})
+					continue
+				}
+			}
+
+			obj = append(obj, objectField{name: f.name, value: s.encodeIDs(fv)})
+		}
+		return obj
+	}
+
+	return v.Interface()
+}
+
+func encodeIDValue(codec IDCodec, v reflect.Value) interface{} {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return codec.Encode(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return codec.Encode(int64(v.Uint()))
+	}
+
+	return v.Interface()
+}
+
+type objectField struct {
+	name  string
+	value interface{}
+}
+
+// orderedObject is one json object that keeps the struct field order
+type orderedObject []objectField
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		name, err := json.Marshal(f.name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+type jsonField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	tagged    bool
+	// resource name from the idcodec tag
+	codec string
+}
+
+var (
+	emptyInterfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+	jsonMarshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType  = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+	jsonFieldsCache sync.Map // map[reflect.Type][]jsonField
+	idFieldsCache   sync.Map // map[reflect.Type]bool
+)
+
+// mayHaveIDFields check if values of the type can have idcodec fields, types with interfaces are checked at runtime
+func mayHaveIDFields(t reflect.Type) bool {
+	if v, ok := idFieldsCache.Load(t); ok {
+		return v.(bool)
+	}
+
+	result := checkIDFields(t, map[reflect.Type]bool{})
+	idFieldsCache.Store(t, result)
+	return result
+}
+
+func checkIDFields(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if visiting[t] {
+		return false
+	}
+	visiting[t] = true
+
+	if t.Kind() != reflect.Interface && (t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType)) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return checkIDFields(t.Elem(), visiting)
+	case reflect.Struct:
+		for _, f := range cachedJSONFields(t) {
+			if f.codec != "" || checkIDFields(t.FieldByIndex(f.index).Type, visiting) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func cachedJSONFields(t reflect.Type) []jsonField {
+	if f, ok := jsonFieldsCache.Load(t); ok {
+		return f.([]jsonField)
+	}
+
+	f, _ := jsonFieldsCache.LoadOrStore(t, typeJSONFields(t))
+	return f.([]jsonField)
+}
+
+// typeJSONFields returns the fields encoded by encoding/json, with embedded struct fields promoted
+func typeJSONFields(t reflect.Type) []jsonField {
+	type candidate struct {
+		jsonField
+		depth int
+	}
+
+	candidates := []candidate{}
+
+	var walk func(t reflect.Type, index []int, depth int, visited map[reflect.Type]bool)
+	walk = func(t reflect.Type, index []int, depth int, visited map[reflect.Type]bool) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+
+			name := strings.Split(tag, ",")[0]
+			idx := append(append([]int{}, index...), i)
+
+			if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+				walk(ft, idx, depth+1, visited)
+				continue
+			}
+
+			if sf.PkgPath != "" {
+				continue
+			}
+
+			f := candidate{
+				jsonField: jsonField{
+					name:      name,
+					index:     idx,
+					omitEmpty: strings.Contains(tag, ",omitempty"),
+					tagged:    name != "",
+					codec:     sf.Tag.Get("idcodec"),
+				},
+				depth: depth,
+			}
+			if f.name == "" {
+				f.name = sf.Name
+			}
+
+			candidates = append(candidates, f)
+		}
+	}
+	walk(t, nil, 0, map[reflect.Type]bool{})
+
+	// encoding/json dominant field rules: lowest depth, then the tagged field, duplicates are ignored
+	fields := []jsonField{}
+	for i, c := range candidates {
+		dominant := true
+		for j, o := range candidates {
+			if i == j || o.name != c.name {
+				continue
+			}
+
+			if o.depth < c.depth || (o.depth == c.depth && (o.tagged && !c.tagged || o.tagged == c.tagged)) {
+				dominant = false
+				break
+			}
+		}
+
+		if dominant {
+			fields = append(fields, c.jsonField)
+		}
+	}
+
+	return fields
+}
+
+// fieldByIndex returns false if one embedded pointer in the path is nil
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// isEmptyValue is the encoding/json omitempty rule
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,348 @@
+package catu
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+const JSONAPIContentType = "application/vnd.api+json"
+
+// JSONAPIDocument is one JSON:API success document, Data is one *JSONAPIResource or one []*JSONAPIResource
+type JSONAPIDocument struct {
+	Data interface{}            `json:"data"`
+	Meta map[string]interface{} `json:"meta,omitempty"`
+}
+
+// JSONAPIResource is one JSON:API resource object.
+// The id is the field tagged with `jsonapi:"id"` or the "id" json field, the other json fields are the attributes.
+// Use `jsonapi:"-"` to skip one field
+type JSONAPIResource struct {
+	Type       string      `json:"type"`
+	ID         interface{} `json:"id,omitempty"`
+	Attributes interface{} `json:"attributes,omitempty"`
+}
+
+type JSONAPIErrorDocument struct {
+	Errors []*JSONAPIError        `json:"errors"`
+	Meta   map[string]interface{} `json:"meta,omitempty"`
+}
+
+type JSONAPIError struct {
+	// HTTP status code as string
+	Status string                 `json:"status"`
+	Code   string                 `json:"code,omitempty"`
+	Title  string                 `json:"title"`
+	Detail string                 `json:"detail,omitempty"`
+	Source *JSONAPIErrorSource    `json:"source,omitempty"`
+	Meta   map[string]interface{} `json:"meta,omitempty"`
+}
+
+type JSONAPIErrorSource struct {
+	// JSON pointer to the request body value, ex: "/data/attributes/title"
+	Pointer string `json:"pointer,omitempty"`
+	// Query param name
+	Parameter string `json:"parameter,omitempty"`
+}
+
+// RespondJSONAPI responds data as one JSON:API document.
+// Data can be one struct, one slice of structs or nil for one empty collection
+func RespondJSONAPI(c echo.Context, status int, resourceType string, data interface{}, meta map[string]interface{}) error {
+	doc, err := newJSONAPIDocument(handlerApp(c), resourceType, data, meta)
+	if err != nil {
+		return err
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, JSONAPIContentType)
+	return c.JSON(status, doc)
+}
+
+// NewJSONAPIDocument builds the JSON:API document for RespondJSONAPI, with the IDCodecs of the GetApp app.
+// Handlers use RespondJSONAPI, with the request app
+func NewJSONAPIDocument(resourceType string, data interface{}, meta map[string]interface{}) (*JSONAPIDocument, error) {
+	return newJSONAPIDocument(GetApp(), resourceType, data, meta)
+}
+
+func newJSONAPIDocument(app App, resourceType string, data interface{}, meta map[string]interface{}) (*JSONAPIDocument, error) {
+	doc := &JSONAPIDocument{Meta: meta}
+
+	v := reflect.ValueOf(data)
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			v = reflect.Value{}
+			break
+		}
+		v = v.Elem()
+	}
+
+	switch {
+	case !v.IsValid():
+		doc.Data = []*JSONAPIResource{}
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		list := make([]*JSONAPIResource, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			r, err := newJSONAPIResource(app, resourceType, v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, r)
+		}
+		doc.Data = list
+	default:
+		r, err := newJSONAPIResource(app, resourceType, v)
+		if err != nil {
+			return nil, err
+		}
+		doc.Data = r
+	}
+
+	return doc, nil
+}
+
+func newJSONAPIResource(app App, resourceType string, v reflect.Value) (*JSONAPIResource, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, errors.New("catu.NewJSONAPIDocument nil record in " + resourceType + " data")
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil, errors.New("catu.NewJSONAPIDocument unsupported data type: " + v.Type().String())
+	}
+
+	s := &JSONSerializer{app: app}
+	r := &JSONAPIResource{Type: resourceType}
+	attributes := orderedObject{}
+
+	for _, f := range cachedJSONAPIFields(v.Type()) {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok {
+			continue
+		}
+
+		if f.isID {
+			r.ID = jsonAPIID(app, f.codec, fv)
+			continue
+		}
+
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		if f.codec != "" {
+			if codec := app.GetResourceIDCodec(f.codec); codec != nil {
+				attributes = append(attributes, objectField{name: f.name, value: encodeIDValue(codec, fv)})
+				continue
+			}
+		}
+
+		attributes = append(attributes, objectField{name: f.name, value: s.encodeIDs(fv)})
+	}
+
+	if len(attributes) > 0 {
+		r.Attributes = attributes
+	}
+
+	return r, nil
+}
+
+// jsonAPIID returns the resource id as string, empty ids are omitted
+func jsonAPIID(app App, codecName string, v reflect.Value) interface{} {
+	if isEmptyValue(v) {
+		return nil
+	}
+
+	if codecName != "" {
+		if codec := app.GetResourceIDCodec(codecName); codec != nil {
+			return encodeIDValue(codec, v)
+		}
+	}
+
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	}
+
+	return fmt.Sprint(v.Interface())
+}
+
+type jsonAPIField struct {
+	jsonField
+	isID bool
+}
+
+var jsonAPIFieldsCache sync.Map // map[reflect.Type][]jsonAPIField
+
+func cachedJSONAPIFields(t reflect.Type) []jsonAPIField {
+	if f, ok := jsonAPIFieldsCache.Load(t); ok {
+		return f.([]jsonAPIField)
+	}
+
+	fields := []jsonAPIField{}
+	idIndex := -1
+
+	for _, f := range cachedJSONFields(t) {
+		tag := t.FieldByIndex(f.index).Tag.Get("jsonapi")
+		if tag == "-" {
+			continue
+		}
+
+		if tag == "id" {
+			idIndex = len(fields)
+		}
+
+		fields = append(fields, jsonAPIField{jsonField: f})
+	}
+
+	if idIndex == -1 {
+		for i, f := range fields {
+			if f.name == "id" || f.name == "ID" {
+				idIndex = i
+				break
+			}
+		}
+	}
+
+	if idIndex >= 0 {
+		fields[idIndex].isID = true
+	}
+
+	f, _ := jsonAPIFieldsCache.LoadOrStore(t, fields)
+	return f.([]jsonAPIField)
+}
+
+// SetResourceJSONAPI enables JSON:API responses for one resource registered with SetResource.
+// The resource routes set the response content type to JSON:API, used by the controllers and the error handler
+func (r *AppStruct) SetResourceJSONAPI(name string, enabled bool) error {
+	resource := r.GetResource(name)
+	if resource == nil {
+		return errors.New("catu.App.SetResourceJSONAPI resource not found: " + name)
+	}
+
+	resource.JSONAPI = enabled
+	return nil
+}
+
+// resourceContentType middleware sets the JSON:API response content type for resources with JSON:API enabled
+func resourceContentType(app App, name string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if resource := app.GetResource(name); resource != nil && resource.JSONAPI {
+				if ctx, ok := c.(*RequestContext); ok {
+					ctx.SetResponseContentType(JSONAPIContentType)
+				} else {
+					c.Set("responseContentType", JSONAPIContentType)
+				}
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// jsonAPIErrorHandler responds errors as one JSON:API errors document, with the ErrorResponse message as detail
+func jsonAPIErrorHandler(err error, ctx *RequestContext) error {
+	ctx.Response().Header().Set(echo.HeaderContentType, JSONAPIContentType)
+
+	if ve, ok := err.(validator.ValidationErrors); ok {
+		code := validationStatusCode(ctx.App)
+		doc := newJSONAPIValidationErrors(ctx, ve, code)
+		doc.Meta = jsonAPIErrorMeta(ctx)
+		return ctx.JSON(code, doc)
+	}
+
+	code := errorStatusCode(ctx.App, err)
+	if code == 0 {
+		code = http.StatusInternalServerError
+	}
+
+	var retryAfter int64
+	if e, ok := err.(*ThrottledError); ok {
+		countThrottle(e.Source)
+		if retryAfter = retryAfterSeconds(ctx.App, e.RetryAfter); retryAfter > 0 {
+			ctx.Response().Header().Set(echo.HeaderRetryAfter, strconv.FormatInt(retryAfter, 10))
+		}
+	}
+
+	if code >= http.StatusInternalServerError {
+		logrus.WithFields(logrus.Fields{
+			"err":       fmt.Sprintf("%+v\n", err),
+			"code":      code,
+			"path":      ctx.Path(),
+			"method":    ctx.Request().Method,
+			"requestId": GetRequestID(ctx),
+		}).Warn("catu.jsonAPIErrorHandler error")
+	}
+
+	resp := NewErrorResponse(ctx, code, err)
+
+	e := &JSONAPIError{Status: strconv.Itoa(code), Title: http.StatusText(code)}
+	if e.Title == "" {
+		e.Title = "Unknown Error"
+	}
+	if resp.Message != e.Title {
+		e.Detail = resp.Message
+	}
+	if resp.Details != nil || retryAfter > 0 {
+		e.Meta = map[string]interface{}{}
+	}
+	if resp.Details != nil {
+		e.Meta["details"] = resp.Details
+	}
+	if retryAfter > 0 {
+		e.Meta["retry_after_seconds"] = retryAfter
+	}
+
+	return ctx.JSON(code, &JSONAPIErrorDocument{
+		Errors: []*JSONAPIError{e},
+		Meta:   jsonAPIErrorMeta(ctx),
+	})
+}
+
+func jsonAPIErrorMeta(ctx *RequestContext) map[string]interface{} {
+	if id := GetRequestID(ctx); id != "" {
+		return map[string]interface{}{"requestId": id}
+	}
+	return nil
+}
+
+func newJSONAPIValidationErrors(ctx *RequestContext, ve validator.ValidationErrors, code int) *JSONAPIErrorDocument {
+	doc := &JSONAPIErrorDocument{Errors: []*JSONAPIError{}}
+
+	for _, fe := range ve {
+		e := &JSONAPIError{
+			Status: strconv.Itoa(code),
+			Code:   fe.Tag(),
+			Title:  "Invalid Attribute",
+			Detail: validationMessage(ctx, fe),
+		}
+
+		if strings.HasPrefix(fe.Namespace(), "query.") {
+			e.Title = "Invalid Query Parameter"
+			e.Source = &JSONAPIErrorSource{Parameter: fe.Field()}
+		} else {
+			e.Source = &JSONAPIErrorSource{
+				Pointer: "/data/attributes/" + strings.ReplaceAll(validationFieldPath(fe), ".", "/"),
+			}
+		}
+
+		doc.Errors = append(doc.Errors, e)
+	}
+
+	return doc
+}
@@ -0,0 +1,202 @@
+package catu
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type jsonAPITestArticle struct {
+	ID       uint64 `json:"id" idcodec:"articles"`
+	Title    string `json:"title" validate:"required"`
+	Body     string `json:"body,omitempty"`
+	AuthorID uint64 `json:"authorId" idcodec:"authors"`
+	Token    string `json:"token" jsonapi:"-"`
+}
+
+type jsonAPITestTag struct {
+	Slug string `json:"slug" jsonapi:"id"`
+	Name string `json:"name"`
+}
+
+type jsonAPITestController struct{}
+
+func (ctl *jsonAPITestController) Query(c echo.Context) error {
+	if c.QueryParam("empty") != "" {
+		return RespondJSONAPI(c, http.StatusOK, "articles", nil, map[string]interface{}{"count": 0})
+	}
+
+	if _, err := ParseQuery(c, &QueryConfig{SortableFields: map[string]string{"id": "id"}}); err != nil {
+		return err
+	}
+
+	return RespondJSONAPI(c, http.StatusOK, "articles", []*jsonAPITestArticle{
+		{ID: 1, Title: "One", AuthorID: 7, Token: "secret"},
+		{ID: 2, Title: "Two", Body: "text"},
+	}, map[string]interface{}{"count": 2})
+}
+
+func (ctl *jsonAPITestController) Create(c echo.Context) error {
+	return c.Validate(&jsonAPITestArticle{})
+}
+
+func (ctl *jsonAPITestController) FindOne(c echo.Context) error {
+	switch c.Param("id") {
+	case "1":
+		return RespondJSONAPI(c, http.StatusOK, "articles", &jsonAPITestArticle{ID: 1, Title: "One"}, nil)
+	case "2":
+		return &HTTPError{Code: http.StatusForbidden, Message: "Only for authors"}
+	case "3":
+		return gorm.ErrRecordNotFound
+	}
+
+	return echo.NewHTTPError(http.StatusInternalServerError, "database password is 123")
+}
+
+func (ctl *jsonAPITestController) Count(c echo.Context) error  { return nil }
+func (ctl *jsonAPITestController) Update(c echo.Context) error { return nil }
+func (ctl *jsonAPITestController) Delete(c echo.Context) error { return nil }
+
+func TestNewJSONAPIDocument(t *testing.T) {
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+
+	marshal := func(resourceType string, data interface{}) string {
+		doc, err := NewJSONAPIDocument(resourceType, data, nil)
+		assert.Nil(t, err)
+		b, err := json.Marshal(doc)
+		assert.Nil(t, err)
+		return string(b)
+	}
+
+	t.Run("Should serialize single records", func(t *testing.T) {
+		assert.Equal(t,
+			`{"data":{"type":"articles","id":"1","attributes":{"title":"One","authorId":7}}}`,
+			marshal("articles", &jsonAPITestArticle{ID: 1, Title: "One", AuthorID: 7, Token: "x"}))
+		assert.Equal(t,
+			`{"data":{"type":"tags","id":"go","attributes":{"name":"Go"}}}`,
+			marshal("tags", jsonAPITestTag{Slug: "go", Name: "Go"}))
+	})
+
+	t.Run("Should serialize slices and nil data as collections", func(t *testing.T) {
+		assert.Equal(t,
+			`{"data":[{"type":"tags","id":"go","attributes":{"name":"Go"}},{"type":"tags","id":"js","attributes":{"name":"JS"}}]}`,
+			marshal("tags", []jsonAPITestTag{{Slug: "go", Name: "Go"}, {Slug: "js", Name: "JS"}}))
+		assert.Equal(t, `{"data":[]}`, marshal("tags", []jsonAPITestTag{}))
+		assert.Equal(t, `{"data":[]}`, marshal("tags", nil))
+
+		var article *jsonAPITestArticle
+		assert.Equal(t, `{"data":[]}`, marshal("articles", article))
+	})
+
+	t.Run("Should return error for unsupported data", func(t *testing.T) {
+		_, err := NewJSONAPIDocument("tags", []interface{}{"go"}, nil)
+		assert.NotNil(t, err)
+		_, err = NewJSONAPIDocument("tags", []*jsonAPITestTag{nil}, nil)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestResourceJSONAPI(t *testing.T) {
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+
+	api := app.GetRouterGroup("api")
+	assert.Nil(t, app.SetResource("articles", &jsonAPITestController{}, api.Group("/articles")))
+	assert.Nil(t, app.SetResource("authors", &jsonAPITestController{}, api.Group("/authors")))
+	assert.Nil(t, app.SetResourceJSONAPI("articles", true))
+	assert.NotNil(t, app.SetResourceJSONAPI("unknown", true))
+
+	request := func(method, path string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		body := map[string]interface{}{}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec, body
+	}
+
+	t.Run("Should respond collections with meta", func(t *testing.T) {
+		rec, body := request(http.MethodGet, "/api/articles")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, JSONAPIContentType, rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, map[string]interface{}{"count": float64(2)}, body["meta"])
+		assert.Len(t, body["data"], 2)
+
+		rec, body = request(http.MethodGet, "/api/articles?empty=1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []interface{}{}, body["data"])
+	})
+
+	t.Run("Should encode ids with the resource IDCodec", func(t *testing.T) {
+		// the request app codecs, not the GetApp codecs
+		assert.Same(t, newGlobalTestApp(t, nil), GetApp())
+
+		codec, err := NewHashIDCodec("jsonapi-test")
+		assert.Nil(t, err)
+		assert.Nil(t, app.SetResourceIDCodec("articles", codec))
+		assert.Nil(t, app.SetResourceIDCodec("authors", codec))
+		defer app.SetResourceIDCodec("articles", nil)
+		defer app.SetResourceIDCodec("authors", nil)
+
+		_, body := request(http.MethodGet, "/api/articles")
+		first := body["data"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, codec.Encode(1), first["id"])
+		assert.Equal(t, codec.Encode(7), first["attributes"].(map[string]interface{})["authorId"])
+
+		rec, body := request(http.MethodGet, "/api/articles/"+codec.Encode(1))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "articles", body["data"].(map[string]interface{})["type"])
+
+		rec, body = request(http.MethodGet, "/api/articles/invalid")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, JSONAPIContentType, rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, []interface{}{map[string]interface{}{"status": "404", "title": "Not Found"}}, body["errors"])
+	})
+
+	t.Run("Should respond errors as error objects", func(t *testing.T) {
+		cases := []struct {
+			path   string
+			code   int
+			errors []interface{}
+		}{
+			{"/api/articles/2", 403, []interface{}{map[string]interface{}{"status": "403", "title": "Forbidden", "detail": "Only for authors"}}},
+			{"/api/articles/3", 404, []interface{}{map[string]interface{}{"status": "404", "title": "Not Found"}}},
+			{"/api/articles/4", 500, []interface{}{map[string]interface{}{"status": "500", "title": "Internal Server Error"}}},
+			{"/api/articles?sort=title", 400, []interface{}{map[string]interface{}{
+				"status": "400", "code": "oneof", "title": "Invalid Query Parameter",
+				"detail": "sort must be one of [id]",
+				"source": map[string]interface{}{"parameter": "sort"},
+			}}},
+		}
+
+		for _, tc := range cases {
+			rec, body := request(http.MethodGet, tc.path)
+			assert.Equal(t, tc.code, rec.Code, tc.path)
+			assert.Equal(t, JSONAPIContentType, rec.Header().Get(echo.HeaderContentType), tc.path)
+			assert.Equal(t, tc.errors, body["errors"], tc.path)
+		}
+
+		rec, body := request(http.MethodPost, "/api/articles")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		errs := body["errors"].([]interface{})
+		assert.Len(t, errs, 1)
+		assert.Equal(t, map[string]interface{}{"pointer": "/data/attributes/title"}, errs[0].(map[string]interface{})["source"])
+		assert.Equal(t, "required", errs[0].(map[string]interface{})["code"])
+	})
+
+	t.Run("Should keep the default errors in other resources", func(t *testing.T) {
+		rec, body := request(http.MethodGet, "/api/authors/2")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "Only for authors", body["message"])
+		assert.Nil(t, body["errors"])
+	})
+}
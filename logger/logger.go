@@ -23,6 +23,11 @@ func Init() {
 	// Can be any io.Writer, see below for File example
 	logrus.SetOutput(os.Stdout)
 
+	SetLevel()
+}
+
+// SetLevel sets the log level from the LOG_LV configuration, "verbose", "warn" or info by default
+func SetLevel() {
 	LOG_LV := configuration.GetEnv("LOG_LV", "")
 
 	switch LOG_LV {
@@ -0,0 +1,122 @@
+package catu
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// Maintenance mode events, with the "message" in the on event
+const (
+	EventMaintenanceOn  = "maintenance.on"
+	EventMaintenanceOff = "maintenance.off"
+)
+
+// EnableMaintenanceMode responds 503 with Retry-After to all requests, except the /health route, the
+// MAINTENANCE_ALLOW_IPS clients and the ExemptFromMaintenance routes. Safe to call at runtime, calls with the
+// maintenance mode on only replace the message
+func (r *AppStruct) EnableMaintenanceMode(message string) {
+	r.maintenanceMessage.Store(message)
+
+	if atomic.SwapInt32(&r.maintenance, 1) == 1 {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"message": message,
+	}).Info("catu.App maintenance mode on")
+
+	if err := r.TriggerSync(context.Background(), EventMaintenanceOn, event.M{"app": r, "message": message}); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Error("catu.App.EnableMaintenanceMode error on maintenance.on event")
+	}
+}
+
+// DisableMaintenanceMode stops the EnableMaintenanceMode responses
+func (r *AppStruct) DisableMaintenanceMode() {
+	if atomic.SwapInt32(&r.maintenance, 0) == 0 {
+		return
+	}
+
+	logrus.Info("catu.App maintenance mode off")
+
+	if err := r.TriggerSync(context.Background(), EventMaintenanceOff, event.M{"app": r}); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Error("catu.App.DisableMaintenanceMode error on maintenance.off event")
+	}
+}
+
+func (r *AppStruct) IsMaintenanceMode() bool {
+	return atomic.LoadInt32(&r.maintenance) == 1
+}
+
+// ExemptFromMaintenance keeps one request path working in the maintenance mode, ex: "/api/status".
+// Paths ending with "*" exempt all paths with the prefix, ex: "/admin/*"
+func (r *AppStruct) ExemptFromMaintenance(path string) {
+	r.maintenanceMu.Lock()
+	defer r.maintenanceMu.Unlock()
+
+	r.maintenanceExempt = append(r.maintenanceExempt, path)
+}
+
+func (r *AppStruct) maintenanceExempted(path string) bool {
+	r.maintenanceMu.RLock()
+	defer r.maintenanceMu.RUnlock()
+
+	for _, p := range r.maintenanceExempt {
+		if p == path || (strings.HasSuffix(p, "*") && strings.HasPrefix(path, strings.TrimSuffix(p, "*"))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bindMaintenance registers the maintenance middleware before the routing, so the unknown routes respond 503 too.
+// MAINTENANCE_MODE=true starts the app in the maintenance mode with the MAINTENANCE_MESSAGE,
+// MAINTENANCE_ALLOW_IPS is one list of IPs and CIDRs, ex: "10.0.0.1,192.168.0.0/16", and
+// MAINTENANCE_RETRY_AFTER is the Retry-After hint, default 5m. The client IP is the trusted ClientIP, see TRUSTED_PROXIES
+func (r *AppStruct) bindMaintenance() {
+	cfg := r.Configuration
+
+	allowed := parseIPNets(splitConfigList(cfg.Get("MAINTENANCE_ALLOW_IPS")), "MAINTENANCE_ALLOW_IPS")
+
+	retryAfter := cfg.GetDuration("MAINTENANCE_RETRY_AFTER", 5*time.Minute)
+
+	r.ExemptFromMaintenance("/health")
+
+	r.router.Pre(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !r.IsMaintenanceMode() || r.maintenanceExempted(c.Request().URL.Path) {
+				return next(c)
+			}
+
+			if ip := net.ParseIP(ClientIP(c)); ip != nil {
+				for _, ipNet := range allowed {
+					if ipNet.Contains(ip) {
+						return next(c)
+					}
+				}
+			}
+
+			err := NewThrottledError(http.StatusServiceUnavailable, ThrottleSourceMaintenance, retryAfter)
+			if message, _ := r.maintenanceMessage.Load().(string); message != "" {
+				err.Message = message
+			}
+			return err
+		}
+	})
+
+	if cfg.GetBool("MAINTENANCE_MODE") {
+		r.EnableMaintenanceMode(cfg.Get("MAINTENANCE_MESSAGE"))
+	}
+}
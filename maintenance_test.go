@@ -0,0 +1,163 @@
+package catu
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	newMaintenanceApp := func(t *testing.T) App {
+		t.Setenv("MAINTENANCE_ALLOW_IPS", "10.0.0.7, 192.168.0.0/16")
+		t.Setenv("MAINTENANCE_RETRY_AFTER", "120s")
+		t.Setenv("THROTTLE_JITTER_PERCENT", "0")
+
+		app := newTestApp(t)
+		t.Setenv("TEMPLATE_DISABLE", "false")
+		t.Setenv("TEMPLATE_FOLDER", t.TempDir())
+
+		templates := fstest.MapFS{
+			"site/503.html": &fstest.MapFile{Data: []byte(`<h1>Maintenance</h1><p>{{ .Message }}</p>`)},
+		}
+		assert.Nil(t, app.LoadTemplatesFromFS(templates, ""))
+		app.SetDefaultLayout("")
+
+		app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+			app.GetRouter().GET("/articles", func(c echo.Context) error {
+				return c.String(http.StatusOK, "articles")
+			})
+			app.GetRouter().GET("/api/status", func(c echo.Context) error {
+				return c.String(http.StatusOK, "status")
+			})
+			return nil
+		}))
+
+		assert.Nil(t, app.Bootstrap())
+		return app
+	}
+
+	request := func(app App, path, accept, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderAccept, accept)
+		if remoteAddr != "" {
+			req.RemoteAddr = remoteAddr
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Should respond 503 with one JSON body and one HTML page", func(t *testing.T) {
+		app := newMaintenanceApp(t)
+
+		events := []string{}
+		for _, name := range []string{EventMaintenanceOn, EventMaintenanceOff} {
+			name := name
+			app.GetEvents().On(name, event.ListenerFunc(func(e event.Event) error {
+				events = append(events, name)
+				return nil
+			}))
+		}
+
+		assert.Equal(t, http.StatusOK, request(app, "/articles", echo.MIMEApplicationJSON, "").Code)
+
+		app.EnableMaintenanceMode("Back at 10:00")
+		app.EnableMaintenanceMode("Back at 10:00")
+		assert.True(t, app.IsMaintenanceMode())
+
+		rec := request(app, "/articles", echo.MIMEApplicationJSON, "")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "120", rec.Header().Get(echo.HeaderRetryAfter))
+
+		body := ErrorResponse{}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, http.StatusServiceUnavailable, body.Code)
+		assert.Equal(t, "Back at 10:00", body.Message)
+		assert.Equal(t, int64(120), body.RetryAfterSeconds)
+
+		rec = request(app, "/articles", echo.MIMETextHTML, "")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "<h1>Maintenance</h1><p>Back at 10:00</p>", rec.Body.String())
+
+		// unknown routes too
+		assert.Equal(t, http.StatusServiceUnavailable, request(app, "/unknown", echo.MIMEApplicationJSON, "").Code)
+		assert.Equal(t, http.StatusOK, request(app, "/health", echo.MIMEApplicationJSON, "").Code)
+
+		app.DisableMaintenanceMode()
+		app.DisableMaintenanceMode()
+		assert.False(t, app.IsMaintenanceMode())
+		assert.Equal(t, http.StatusOK, request(app, "/articles", echo.MIMEApplicationJSON, "").Code)
+
+		assert.Equal(t, []string{EventMaintenanceOn, EventMaintenanceOff}, events)
+	})
+
+	t.Run("Should keep the allowed IPs and the exempted routes working", func(t *testing.T) {
+		app := newMaintenanceApp(t)
+		app.ExemptFromMaintenance("/api/*")
+		app.EnableMaintenanceMode("")
+
+		for addr, code := range map[string]int{
+			"10.0.0.7:5000":    http.StatusOK,
+			"192.168.4.2:5000": http.StatusOK,
+			"10.0.0.8:5000":    http.StatusServiceUnavailable,
+		} {
+			assert.Equal(t, code, request(app, "/articles", echo.MIMEApplicationJSON, addr).Code, addr)
+		}
+
+		rec := request(app, "/api/status", echo.MIMEApplicationJSON, "10.0.0.8:5000")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "status", rec.Body.String())
+
+		rec = request(app, "/articles", echo.MIMEApplicationJSON, "")
+		body := ErrorResponse{}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, http.StatusText(http.StatusServiceUnavailable), body.Message)
+	})
+
+	t.Run("Should ignore one spoofed allowed IP in the proxy headers", func(t *testing.T) {
+		app := newMaintenanceApp(t)
+		app.EnableMaintenanceMode("")
+
+		spoofed := func(remoteAddr string) int {
+			req := httptest.NewRequest(http.MethodGet, "/articles", nil)
+			req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+			req.Header.Set(echo.HeaderXForwardedFor, "10.0.0.7")
+			req.Header.Set(echo.HeaderXRealIP, "10.0.0.7")
+			req.RemoteAddr = remoteAddr
+			rec := httptest.NewRecorder()
+			app.GetRouter().ServeHTTP(rec, req)
+			return rec.Code
+		}
+
+		assert.Equal(t, http.StatusServiceUnavailable, spoofed("203.0.113.9:5000"))
+
+		// the X-Forwarded-For of one trusted proxy is the client IP
+		t.Setenv("TRUSTED_PROXIES", "172.16.0.1")
+		app = newMaintenanceApp(t)
+		app.EnableMaintenanceMode("")
+
+		assert.Equal(t, http.StatusOK, spoofed("172.16.0.1:5000"))
+		assert.Equal(t, http.StatusServiceUnavailable, spoofed("203.0.113.9:5000"))
+	})
+
+	t.Run("Should start in the maintenance mode with MAINTENANCE_MODE", func(t *testing.T) {
+		t.Setenv("MAINTENANCE_MODE", "true")
+		t.Setenv("MAINTENANCE_MESSAGE", "Migrating")
+		app := newMaintenanceApp(t)
+
+		assert.True(t, app.IsMaintenanceMode())
+		rec := request(app, "/articles", echo.MIMEApplicationJSON, "")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		retryAfter, err := strconv.Atoi(rec.Header().Get(echo.HeaderRetryAfter))
+		assert.Nil(t, err)
+		assert.Equal(t, 120, retryAfter)
+		assert.Contains(t, rec.Body.String(), "Migrating")
+	})
+}
@@ -0,0 +1,249 @@
+package catu
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const metricsNamespace = "catu"
+
+// metrics has the collectors of the HTTP requests and database queries
+type metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	inFlight        *prometheus.GaugeVec
+	queryDuration   *prometheus.HistogramVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route path and status code.",
+		}, []string{"method", "path", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request duration by method, route path and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "path", "status"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests in progress by method and route path.",
+		}, []string{"method", "path"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Database query duration by database and operation.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"db", "operation"}),
+	}
+
+	m.registry.MustRegister(
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		prometheus.NewGoCollector(),
+		m.requests,
+		m.requestDuration,
+		m.inFlight,
+		m.queryDuration,
+		&throttleCollector{desc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "throttled_responses_total"),
+			"Throttled responses by source, see ThrottledError.",
+			[]string{"source"}, nil,
+		)},
+	)
+
+	return m
+}
+
+var metricsMu sync.Mutex
+
+func (r *AppStruct) getMetrics() *metrics {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	if r.metrics == nil {
+		r.metrics = newMetrics()
+	}
+	return r.metrics
+}
+
+// MetricsRegistry returns the registry of the /metrics endpoint, plugins register custom collectors in it
+func (r *AppStruct) MetricsRegistry() *prometheus.Registry {
+	return r.getMetrics().registry
+}
+
+// bindMetrics registers the /metrics endpoint and the requests instrumentation if METRICS_ENABLED is true.
+// METRICS_PATH changes the endpoint path
+func (r *AppStruct) bindMetrics() {
+	if !r.Configuration.GetBool("METRICS_ENABLED") {
+		return
+	}
+
+	m := r.getMetrics()
+
+	r.router.Use(metricsMiddleware(r, m))
+	r.router.GET(r.Configuration.GetF("METRICS_PATH", "/metrics"), echo.WrapHandler(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})))
+}
+
+// metricsMiddleware records the requests count, duration and in flight requests.
+// The path label is the echo route path, ex: "/users/:id", so the label values are limited to the app routes
+func metricsMiddleware(app App, m *metrics) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			method := c.Request().Method
+			path := c.Path()
+			// without one route the path is the request URL
+			if isUnmatchedRoute(c) {
+				path = "unmatched"
+			}
+
+			inFlight := m.inFlight.WithLabelValues(method, path)
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			start := time.Now()
+			err := next(c)
+
+			status := c.Response().Status
+			if err != nil && !c.Response().Committed {
+				status = metricsErrorStatus(app, err)
+			}
+			statusLabel := strconv.Itoa(status)
+
+			m.requests.WithLabelValues(method, path, statusLabel).Inc()
+			m.requestDuration.WithLabelValues(method, path, statusLabel).Observe(time.Since(start).Seconds())
+
+			return err
+		}
+	}
+}
+
+var (
+	notFoundHandler         = reflect.ValueOf(echo.NotFoundHandler).Pointer()
+	methodNotAllowedHandler = reflect.ValueOf(echo.MethodNotAllowedHandler).Pointer()
+)
+
+func isUnmatchedRoute(c echo.Context) bool {
+	h := reflect.ValueOf(c.Handler()).Pointer()
+	return h == notFoundHandler || h == methodNotAllowedHandler
+}
+
+// metricsErrorStatus is the status responded by the app error handler
+func metricsErrorStatus(app App, err error) int {
+	if _, ok := err.(validator.ValidationErrors); ok {
+		return validationStatusCode(app)
+	}
+
+	if code := errorStatusCode(app, err); code != 0 {
+		return code
+	}
+	return http.StatusInternalServerError
+}
+
+// throttleCollector exports ThrottleCounts
+type throttleCollector struct {
+	desc *prometheus.Desc
+}
+
+func (c *throttleCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *throttleCollector) Collect(ch chan<- prometheus.Metric) {
+	for source, count := range ThrottleCounts() {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, float64(count), source)
+	}
+}
+
+const gormMetricsStartKey = "catu:metrics_start"
+
+// GormMetricsPlugin is one gorm plugin that records the query duration by operation: create, query, update, delete, row and raw
+type GormMetricsPlugin struct {
+	// Database name label
+	DBName   string
+	duration *prometheus.HistogramVec
+}
+
+// NewGormMetricsPlugin returns one gorm plugin that records the query durations in the app metrics, ex:
+//
+//	db.Use(catu.NewGormMetricsPlugin(app, "reports"))
+func NewGormMetricsPlugin(app *AppStruct, dbName string) *GormMetricsPlugin {
+	return &GormMetricsPlugin{
+		DBName:   dbName,
+		duration: app.getMetrics().queryDuration,
+	}
+}
+
+func (p *GormMetricsPlugin) Name() string {
+	return "catu:metrics"
+}
+
+func (p *GormMetricsPlugin) Initialize(db *gorm.DB) error {
+	before := func(db *gorm.DB) {
+		db.InstanceSet(gormMetricsStartKey, time.Now())
+	}
+
+	after := func(operation string) func(db *gorm.DB) {
+		return func(db *gorm.DB) {
+			start, ok := db.InstanceGet(gormMetricsStartKey)
+			if !ok {
+				return
+			}
+			p.duration.WithLabelValues(p.DBName, operation).Observe(time.Since(start.(time.Time)).Seconds())
+		}
+	}
+
+	cb := db.Callback()
+	errs := []error{
+		cb.Create().Before("gorm:create").Register("catu:metrics_before_create", before),
+		cb.Create().After("gorm:create").Register("catu:metrics_after_create", after("create")),
+		cb.Query().Before("gorm:query").Register("catu:metrics_before_query", before),
+		cb.Query().After("gorm:query").Register("catu:metrics_after_query", after("query")),
+		cb.Update().Before("gorm:update").Register("catu:metrics_before_update", before),
+		cb.Update().After("gorm:update").Register("catu:metrics_after_update", after("update")),
+		cb.Delete().Before("gorm:delete").Register("catu:metrics_before_delete", before),
+		cb.Delete().After("gorm:delete").Register("catu:metrics_after_delete", after("delete")),
+		cb.Row().Before("gorm:row").Register("catu:metrics_before_row", before),
+		cb.Row().After("gorm:row").Register("catu:metrics_after_row", after("row")),
+		cb.Raw().Before("gorm:raw").Register("catu:metrics_before_raw", before),
+		cb.Raw().After("gorm:raw").Register("catu:metrics_after_raw", after("raw")),
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// useGormMetrics records the database query durations if METRICS_ENABLED is true
+func (r *AppStruct) useGormMetrics(name string, db *gorm.DB) {
+	if !r.Configuration.GetBool("METRICS_ENABLED") {
+		return
+	}
+
+	if err := db.Use(NewGormMetricsPlugin(r, name)); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"name":  name,
+			"error": err.Error(),
+		}).Error("catu.App.useGormMetrics error on register gorm plugin")
+	}
+}
@@ -0,0 +1,92 @@
+package catu
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Setenv("METRICS_ENABLED", "true")
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+
+	router := app.GetRouter()
+	router.GET("/items/:id", func(c echo.Context) error {
+		return c.String(http.StatusOK, c.Param("id"))
+	})
+	router.GET("/broken", func(c echo.Context) error {
+		return &HTTPError{Code: http.StatusBadGateway, Message: "Bad Gateway"}
+	})
+
+	jobs := prometheus.NewCounter(prometheus.CounterOpts{Name: "app_jobs_total", Help: "Jobs."})
+	app.MetricsRegistry().MustRegister(jobs)
+	jobs.Add(3)
+
+	request := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	request("/items/1")
+	request("/items/2")
+	request("/broken")
+	request("/missing/3")
+
+	assert.Nil(t, app.GetDB().Exec("SELECT 1").Error)
+
+	rec := request("/metrics")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+
+	t.Run("Should count the requests by route path", func(t *testing.T) {
+		assert.Contains(t, body, `catu_http_requests_total{method="GET",path="/items/:id",status="200"} 2`)
+		assert.Contains(t, body, `catu_http_requests_total{method="GET",path="/broken",status="502"} 1`)
+		assert.Contains(t, body, `catu_http_request_duration_seconds_count{method="GET",path="/items/:id",status="200"} 2`)
+		assert.Contains(t, body, `catu_http_requests_in_flight{method="GET",path="/items/:id"} 0`)
+		// the scrape request is in flight
+		assert.Contains(t, body, `catu_http_requests_in_flight{method="GET",path="/metrics"} 1`)
+
+		assert.NotContains(t, body, `path="/items/1"`)
+		assert.NotContains(t, body, `/missing/3`)
+		assert.Contains(t, body, `catu_http_requests_total{method="GET",path="unmatched",status="404"} 1`)
+	})
+
+	t.Run("Should record the database queries", func(t *testing.T) {
+		assert.Contains(t, body, `catu_db_query_duration_seconds_count{db="default",operation="raw"}`)
+	})
+
+	t.Run("Should export the custom collectors", func(t *testing.T) {
+		assert.Contains(t, body, "app_jobs_total 3")
+	})
+
+	t.Run("Should count the status responded by the request app", func(t *testing.T) {
+		t.Setenv("VALIDATION_ERROR_STATUS", "422")
+		newGlobalTestApp(t, map[string]string{"VALIDATION_ERROR_STATUS": "400"})
+
+		router.GET("/invalid", func(c echo.Context) error {
+			return c.Validate(&validationTestBody{UserEmail: "invalid", Password: "12345678", Address: validationTestAddress{Street: "x"}})
+		})
+		assert.Equal(t, http.StatusUnprocessableEntity, request("/invalid").Code)
+		assert.Contains(t, request("/metrics").Body.String(), `catu_http_requests_total{method="GET",path="/invalid",status="422"} 1`)
+	})
+
+	t.Run("Should not register the endpoint without METRICS_ENABLED", func(t *testing.T) {
+		t.Setenv("METRICS_ENABLED", "false")
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -20,12 +20,25 @@ func BindMiddlewares(app App, p *Plugin) {
 		RedirectCode: http.StatusMovedPermanently,
 	}))
 
-	router.Use(middleware.Gzip())
-	router.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowCredentials: app.GetConfiguration().GetBoolF("CORS_ALLOW_CREDENTIALS", true),
-		MaxAge:           app.GetConfiguration().GetIntF("CORS_MAX_AGE", 18000), // seccounds
-	}))
-	router.Use(initAppCtx())
+	headerTransformer := app.GetHeaderTransformer()
+	if err := headerTransformer.Reload(); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Error("catu.BindMiddlewares error on load header transformations")
+	}
+	router.Use(headerTransformer.Middleware())
+
+	// COMPRESSION_ENABLED replaces it with the Compression middleware
+	if !app.GetConfiguration().GetBool("COMPRESSION_ENABLED") {
+		router.Use(middleware.GzipWithConfig(middleware.GzipConfig{Skipper: isEventStreamRequest}))
+	}
+	router.Use(initAppCtx(app))
+
+	if err := bindSessions(app); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Error("catu.BindMiddlewares error on start sessions")
+	}
 
 	if goEnv == "dev" {
 		router.Debug = true
@@ -36,11 +49,22 @@ func isPublicRoute(url string) bool {
 	return strings.HasPrefix(url, "/health") || strings.HasPrefix(url, "/public")
 }
 
-// Middleare that update echo context to use custom methods
-func initAppCtx() echo.MiddlewareFunc {
+// Middleare that update echo context to use custom methods.
+// The RequestContext is reused between requests, set POOL_POISON_CHECK=true to detect handlers using it after the request ends
+func initAppCtx(app App) echo.MiddlewareFunc {
+	poison := app.GetConfiguration().GetBoolF("POOL_POISON_CHECK", app.GetConfiguration().Get("GO_ENV") == "dev")
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			ctx := NewRequestContext(&RequestContextOpts{EchoContext: c})
+			ctx := acquireRequestContext(app, c)
+			defer releaseRequestContext(ctx, poison)
+
+			if ctx.Get("responseContentType") == nil {
+				if v := app.NegotiateResponseType(ctx); v != "" {
+					ctx.SetResponseContentType(v)
+				}
+			}
+
 			return next(ctx)
 		}
 	}
@@ -0,0 +1,111 @@
+package catu
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// RegisteredModel is one model registered with SetModel
+type RegisteredModel struct {
+	Name  string
+	Model interface{}
+}
+
+// ModelWithSeed is one model with initial records, Seed runs after MigrateModels creates the model table
+type ModelWithSeed interface {
+	Seed(db *gorm.DB) error
+}
+
+// modelSeed is one pending or done Seed, so one failed Seed runs again in the next MigrateModels
+type modelSeed struct {
+	Model     string    `gorm:"column:model;primaryKey;size:100"`
+	Seeded    bool      `gorm:"column:seeded"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+func (modelSeed) TableName() string {
+	return "model_seeds"
+}
+
+// GetModels returns the SetModel models in registration order
+func (r *AppStruct) GetModels() []RegisteredModel {
+	models := make([]RegisteredModel, 0, len(r.modelNames))
+	for _, name := range r.modelNames {
+		models = append(models, RegisteredModel{Name: name, Model: r.Models[name]})
+	}
+
+	return models
+}
+
+// MigrateModels runs AutoMigrate in the SetModel models in registration order.
+// Models with one new table and the ModelWithSeed interface are seeded once, in one transaction.
+// The seeds are recorded in the model_seeds table and one failed Seed runs again in the next MigrateModels
+func (r *AppStruct) MigrateModels() error {
+	db := r.GetDB()
+	if db == nil {
+		return errors.New("catu.App.MigrateModels database not initialized")
+	}
+
+	return r.migrateModels(db)
+}
+
+// migrateModels runs MigrateModels in db, ex: one tenant database
+func (r *AppStruct) migrateModels(db *gorm.DB) error {
+	models := r.GetModels()
+
+	for _, m := range models {
+		if _, ok := m.Model.(ModelWithSeed); ok {
+			if err := db.AutoMigrate(&modelSeed{}); err != nil {
+				return errors.Wrap(err, "catu.App.MigrateModels error on migrate model seeds")
+			}
+			break
+		}
+	}
+
+	for _, m := range models {
+		created := !db.Migrator().HasTable(m.Model)
+
+		if err := db.AutoMigrate(m.Model); err != nil {
+			return errors.Wrap(err, "catu.App.MigrateModels error on migrate model "+m.Name)
+		}
+
+		seeder, ok := m.Model.(ModelWithSeed)
+		if !ok {
+			continue
+		}
+
+		// the tables created before the seeds table are not seeded
+		if created {
+			if err := db.Save(&modelSeed{Model: m.Name}).Error; err != nil {
+				return errors.Wrap(err, "catu.App.MigrateModels error on save the seed of model "+m.Name)
+			}
+		}
+
+		seed := modelSeed{}
+		if err := db.Where("model = ?", m.Name).Limit(1).Find(&seed).Error; err != nil {
+			return errors.Wrap(err, "catu.App.MigrateModels error on find the seed of model "+m.Name)
+		}
+		if seed.Model == "" || seed.Seeded {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := seeder.Seed(tx); err != nil {
+				return err
+			}
+			return tx.Model(&modelSeed{}).Where("model = ?", m.Name).Update("seeded", true).Error
+		})
+		if err != nil {
+			return errors.Wrap(err, "catu.App.MigrateModels error on seed model "+m.Name)
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"model": m.Name,
+		}).Debug("catu.App.MigrateModels model seeded")
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package catu
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type migrateTestCategory struct {
+	ID   uint64 `gorm:"primaryKey"`
+	Name string
+}
+
+func (m *migrateTestCategory) Seed(db *gorm.DB) error {
+	return db.Create(&[]migrateTestCategory{{Name: "news"}, {Name: "sports"}}).Error
+}
+
+// migrateTestTag fails the first Seed
+type migrateTestTag struct {
+	ID   uint64 `gorm:"primaryKey"`
+	Name string
+}
+
+var migrateTestTagSeeds = 0
+
+func (m *migrateTestTag) Seed(db *gorm.DB) error {
+	migrateTestTagSeeds++
+	if err := db.Create(&migrateTestTag{Name: "go"}).Error; err != nil {
+		return err
+	}
+	if migrateTestTagSeeds == 1 {
+		return errors.New("seed failed")
+	}
+	return nil
+}
+
+type migrateTestPost struct {
+	ID         uint64 `gorm:"primaryKey"`
+	CategoryID uint64
+	Title      string
+}
+
+type migrateTestBroken struct {
+	ID      uint64 `gorm:"primaryKey"`
+	Options map[string]int
+}
+
+func TestMigrateModels(t *testing.T) {
+	t.Setenv("AUTO_MIGRATE", "true")
+	t.Setenv("SCHEMA_DRIFT_CHECK", "false")
+
+	t.Run("Should migrate and seed the models in registration order", func(t *testing.T) {
+		app := newTestApp(t)
+		app.SetModel("post", &migrateTestPost{})
+		app.SetModel("category", &migrateTestCategory{})
+		app.SetModel("post", &migrateTestPost{})
+		assert.Nil(t, app.Bootstrap())
+
+		names := []string{}
+		for _, m := range app.GetModels() {
+			names = append(names, m.Name)
+		}
+		assert.Equal(t, []string{"post", "category"}, names)
+
+		assert.Nil(t, app.Migrate())
+		db := app.GetDB()
+		assert.True(t, db.Migrator().HasTable(&migrateTestPost{}))
+		assert.True(t, db.Migrator().HasTable(&migrateTestCategory{}))
+
+		count := func() int64 {
+			var n int64
+			assert.Nil(t, db.Model(&migrateTestCategory{}).Count(&n).Error)
+			return n
+		}
+		assert.Equal(t, int64(2), count())
+
+		// the seed runs only with one new table
+		assert.Nil(t, app.Migrate())
+		assert.Equal(t, int64(2), count())
+	})
+
+	t.Run("Should run one failed seed again", func(t *testing.T) {
+		app := newTestApp(t)
+		app.SetModel("tag", &migrateTestTag{})
+		assert.Nil(t, app.Bootstrap())
+
+		err := app.Migrate()
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "error on seed model tag")
+
+		count := func() int64 {
+			var n int64
+			assert.Nil(t, app.GetDB().Model(&migrateTestTag{}).Count(&n).Error)
+			return n
+		}
+		assert.Equal(t, int64(0), count(), "the failed seed is rolled back")
+
+		assert.Nil(t, app.Migrate())
+		assert.Equal(t, int64(1), count())
+		assert.Nil(t, app.Migrate())
+		assert.Equal(t, 2, migrateTestTagSeeds)
+	})
+
+	t.Run("Should return the model with errors", func(t *testing.T) {
+		app := newTestApp(t)
+		app.SetModel("category", &migrateTestCategory{})
+		app.SetModel("broken", &migrateTestBroken{})
+		assert.Nil(t, app.Bootstrap())
+
+		err := app.Migrate()
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "error on migrate model broken")
+		assert.True(t, app.GetDB().Migrator().HasTable(&migrateTestCategory{}))
+	})
+}
@@ -0,0 +1,145 @@
+package oembed
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-catupiry/catu"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// oEmbed response as described in https://oembed.com
+type Response struct {
+	XMLName         xml.Name `json:"-" xml:"oembed"`
+	Type            string   `json:"type" xml:"type"`
+	Version         string   `json:"version" xml:"version"`
+	Title           string   `json:"title,omitempty" xml:"title,omitempty"`
+	AuthorName      string   `json:"author_name,omitempty" xml:"author_name,omitempty"`
+	AuthorURL       string   `json:"author_url,omitempty" xml:"author_url,omitempty"`
+	ProviderName    string   `json:"provider_name,omitempty" xml:"provider_name,omitempty"`
+	ProviderURL     string   `json:"provider_url,omitempty" xml:"provider_url,omitempty"`
+	CacheAge        int      `json:"cache_age,omitempty" xml:"cache_age,omitempty"`
+	ThumbnailURL    string   `json:"thumbnail_url,omitempty" xml:"thumbnail_url,omitempty"`
+	ThumbnailWidth  int      `json:"thumbnail_width,omitempty" xml:"thumbnail_width,omitempty"`
+	ThumbnailHeight int      `json:"thumbnail_height,omitempty" xml:"thumbnail_height,omitempty"`
+	HTML            string   `json:"html,omitempty" xml:"html,omitempty"`
+	Width           int      `json:"width,omitempty" xml:"width,omitempty"`
+	Height          int      `json:"height,omitempty" xml:"height,omitempty"`
+}
+
+// Request data passed to one provider after the URL pattern matched
+type Request struct {
+	URL *url.URL
+	// Regexp sub matches, index 0 is the full match
+	Matches   []string
+	MaxWidth  int
+	MaxHeight int
+}
+
+// ProviderFunc resolves one matched URL to an oEmbed response.
+// Return nil response to respond with 404.
+type ProviderFunc func(c echo.Context, req *Request) (*Response, error)
+
+type provider struct {
+	pattern *regexp.Regexp
+	fn      ProviderFunc
+}
+
+// AddProvider register one provider for URLs matching the pattern.
+// The pattern is a regular expression checked against the full URL.
+func (p *Plugin) AddProvider(pattern string, fn ProviderFunc) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return errors.Wrap(err, "oembed.AddProvider invalid pattern "+pattern)
+	}
+
+	p.providers = append(p.providers, &provider{pattern: re, fn: fn})
+	return nil
+}
+
+// Resolve the first provider registered for the url
+func (p *Plugin) Resolve(c echo.Context, rawURL string, maxWidth, maxHeight int) (*Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, nil
+	}
+
+	for _, pr := range p.providers {
+		matches := pr.pattern.FindStringSubmatch(rawURL)
+		if matches == nil {
+			continue
+		}
+
+		res, err := pr.fn(c, &Request{
+			URL:       u,
+			Matches:   matches,
+			MaxWidth:  maxWidth,
+			MaxHeight: maxHeight,
+		})
+		if err != nil || res == nil {
+			return res, err
+		}
+
+		if res.Version == "" {
+			res.Version = "1.0"
+		}
+		if res.Type == "" {
+			res.Type = "rich"
+		}
+		if res.CacheAge == 0 {
+			res.CacheAge = p.CacheAge
+		}
+
+		return res, nil
+	}
+
+	return nil, nil
+}
+
+// OEmbedHandler - GET /oembed?url=...&format=json|xml
+func (p *Plugin) OEmbedHandler(c echo.Context) error {
+	rawURL := c.QueryParam("url")
+	if rawURL == "" {
+		return c.JSON(http.StatusBadRequest, &catu.HTTPError{Code: http.StatusBadRequest, Message: "url query param is required"})
+	}
+
+	format := strings.ToLower(c.QueryParam("format"))
+	if format == "" {
+		format = "json"
+	}
+
+	if format != "json" && format != "xml" {
+		return c.JSON(http.StatusNotImplemented, &catu.HTTPError{Code: http.StatusNotImplemented, Message: "format not supported"})
+	}
+
+	maxWidth, _ := strconv.Atoi(c.QueryParam("maxwidth"))
+	maxHeight, _ := strconv.Atoi(c.QueryParam("maxheight"))
+
+	res, err := p.Resolve(c, rawURL, maxWidth, maxHeight)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"url":   rawURL,
+			"error": err,
+		}).Error("oembed.OEmbedHandler error on resolve url")
+		return err
+	}
+
+	if res == nil {
+		return &catu.HTTPError{Code: http.StatusNotFound, Message: "Not Found"}
+	}
+
+	c.Response().Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(res.CacheAge))
+	c.Response().Header().Set("Access-Control-Allow-Origin", "*")
+
+	if format == "xml" {
+		return c.XML(http.StatusOK, res)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,155 @@
+package oembed_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-catupiry/catu"
+	"github.com/go-catupiry/catu/oembed"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestApp(t *testing.T) (catu.App, *oembed.Plugin) {
+	t.Setenv("DB_URI", filepath.Join(t.TempDir(), "test.sqlite"))
+	t.Setenv("TEMPLATE_DISABLE", "true")
+
+	app := catu.Init(&catu.AppOptions{})
+
+	p := oembed.NewPlugin()
+	p.BaseURL = "https://example.com"
+
+	err := p.AddProvider(`^https://example\.com/content/(\d+)$`, func(c echo.Context, req *oembed.Request) (*oembed.Response, error) {
+		if req.Matches[1] == "404" {
+			return nil, nil
+		}
+
+		return &oembed.Response{
+			Title:        "Content " + req.Matches[1],
+			HTML:         `<iframe src="https://example.com/widget/content"></iframe>`,
+			ThumbnailURL: "https://example.com/content/" + req.Matches[1] + ".png",
+		}, nil
+	})
+	assert.Nil(t, err)
+
+	err = p.AddWidget(&oembed.Widget{
+		Name:           "latest",
+		Title:          "Latest",
+		AllowedOrigins: []string{"https://partner.com", "https://other.com"},
+		Template:       `<p>{{ .Data }}</p>`,
+		Data: func(c echo.Context) (interface{}, error) {
+			return "hello", nil
+		},
+	})
+	assert.Nil(t, err)
+
+	app.RegisterPlugin(p)
+
+	err = app.Bootstrap()
+	assert.Nil(t, err)
+
+	return app, p
+}
+
+func doRequest(app catu.App, target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	app.GetRouter().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestOEmbedHandler(t *testing.T) {
+	app, _ := newTestApp(t)
+	contentURL := url.QueryEscape("https://example.com/content/10")
+
+	t.Run("Should return json format by default", func(t *testing.T) {
+		rec := doRequest(app, "/oembed?url="+contentURL, nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get(echo.HeaderContentType), "application/json")
+		assert.Equal(t, "public, max-age=3600", rec.Header().Get("Cache-Control"))
+
+		res := oembed.Response{}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "Content 10", res.Title)
+		assert.Equal(t, "rich", res.Type)
+		assert.Equal(t, "1.0", res.Version)
+		assert.Equal(t, "https://example.com/content/10.png", res.ThumbnailURL)
+	})
+
+	t.Run("Should return xml format", func(t *testing.T) {
+		rec := doRequest(app, "/oembed?format=xml&url="+contentURL, nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get(echo.HeaderContentType), "application/xml")
+
+		res := oembed.Response{}
+		assert.Nil(t, xml.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "oembed", res.XMLName.Local)
+		assert.Equal(t, "Content 10", res.Title)
+	})
+
+	t.Run("Should return 501 for unknown formats", func(t *testing.T) {
+		rec := doRequest(app, "/oembed?format=yaml&url="+contentURL, nil)
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	})
+
+	t.Run("Should return 404 for unknown urls", func(t *testing.T) {
+		rec := doRequest(app, "/oembed?url="+url.QueryEscape("https://unknown.com/content/10"), nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = doRequest(app, "/oembed?url="+url.QueryEscape("https://example.com/content/404"), nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("Should return 400 without url", func(t *testing.T) {
+		rec := doRequest(app, "/oembed", nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestWidgetHandler(t *testing.T) {
+	app, p := newTestApp(t)
+
+	t.Run("Should set frame-ancestors for the allowed origin", func(t *testing.T) {
+		rec := doRequest(app, "/widget/latest", map[string]string{"Origin": "https://partner.com"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "frame-ancestors https://partner.com")
+		assert.NotContains(t, rec.Header().Get("Content-Security-Policy"), "https://other.com")
+		assert.Contains(t, rec.Body.String(), "<p>hello</p>")
+
+		rec = doRequest(app, "/widget/latest", map[string]string{"Referer": "https://other.com/some/page"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "frame-ancestors https://other.com")
+	})
+
+	t.Run("Should list all allowed origins without origin", func(t *testing.T) {
+		rec := doRequest(app, "/widget/latest", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "frame-ancestors https://partner.com https://other.com")
+		assert.Equal(t, "public, max-age=3600", rec.Header().Get("Cache-Control"))
+	})
+
+	t.Run("Should reject not allowed origins", func(t *testing.T) {
+		rec := doRequest(app, "/widget/latest", map[string]string{"Origin": "https://evil.com"})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Empty(t, rec.Header().Get("Content-Security-Policy"))
+	})
+
+	t.Run("Should return 404 for unknown widgets", func(t *testing.T) {
+		rec := doRequest(app, "/widget/unknown", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("Should render the widget snippet", func(t *testing.T) {
+		html := string(p.EmbedSnippet("latest"))
+		assert.Contains(t, html, `<div id="catu-widget-latest"></div>`)
+		assert.Contains(t, html, `f.src="https://example.com/widget/latest"`)
+	})
+}
@@ -0,0 +1,75 @@
+// Package oembed adds one oEmbed endpoint and iframe embeddable widgets to catu apps.
+//
+// Usage:
+//
+//	p := oembed.NewPlugin()
+//	p.AddProvider(`^https://example\.com/content/(\d+)$`, contentProvider)
+//	p.AddWidget(&oembed.Widget{Name: "latest", AllowedOrigins: []string{"https://partner.com"}, Template: "..."})
+//	app.RegisterPlugin(p)
+package oembed
+
+import (
+	"github.com/go-catupiry/catu"
+	"github.com/gookit/event"
+	"github.com/sirupsen/logrus"
+)
+
+type Plugin struct {
+	Name string
+	// Base URL used in widget snippets, defaults to the app BaseURL
+	BaseURL string
+	// oEmbed endpoint path, default: /oembed
+	OEmbedPath string
+	// Widgets route prefix, default: /widget
+	WidgetPath string
+	// Default cache age in seconds, from OEMBED_CACHE_AGE, default: 3600
+	CacheAge int
+
+	providers []*provider
+	widgets   map[string]*Widget
+}
+
+func NewPlugin() *Plugin {
+	return &Plugin{
+		Name:       "oembed",
+		OEmbedPath: "/oembed",
+		WidgetPath: "/widget",
+		widgets:    make(map[string]*Widget),
+	}
+}
+
+func (p *Plugin) GetName() string {
+	return p.Name
+}
+
+func (p *Plugin) Init(app catu.App) error {
+	logrus.WithFields(logrus.Fields{
+		"PluginName": p.Name,
+	}).Debug("oembed.Plugin.Init Running init")
+
+	if p.CacheAge == 0 {
+		p.CacheAge = app.GetConfiguration().GetIntF("OEMBED_CACHE_AGE", 3600)
+	}
+
+	if p.BaseURL == "" {
+		p.BaseURL = app.GetOptions().BaseURL
+	}
+
+	app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+		return p.BindRoutes(app)
+	}), event.Normal)
+
+	app.GetEvents().On("setTemplateFunctions", event.ListenerFunc(func(e event.Event) error {
+		app.SetTemplateFunction("embedWidget", p.EmbedSnippet)
+		return nil
+	}), event.Normal)
+
+	return nil
+}
+
+func (p *Plugin) BindRoutes(app catu.App) error {
+	router := app.GetRouter()
+	router.GET(p.OEmbedPath, p.OEmbedHandler)
+	router.GET(p.WidgetPath+"/:name", p.WidgetHandler)
+	return nil
+}
@@ -0,0 +1,182 @@
+package oembed
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-catupiry/catu"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+// Widget is one page that partners can embed inside an iframe
+type Widget struct {
+	Name  string
+	Title string
+	// Origins allowed to embed this widget, like "https://partner.com". Use "*" to allow any origin
+	AllowedOrigins []string
+	// html/template source for the widget body, rendered inside the widget layout
+	Template string
+	// Data passed to the widget template as .Data
+	Data func(c echo.Context) (interface{}, error)
+	// Cache-Control max-age in seconds, defaults to the plugin CacheAge
+	MaxAge int
+
+	tpl *template.Template
+}
+
+type widgetTemplateCTX struct {
+	Name    string
+	Title   string
+	Data    interface{}
+	Content template.HTML
+}
+
+// AddWidget register one embeddable widget
+func (p *Plugin) AddWidget(w *Widget) error {
+	if w.Name == "" {
+		return errors.New("oembed.AddWidget Name is required")
+	}
+
+	if _, ok := p.widgets[w.Name]; ok {
+		return errors.New("oembed.AddWidget widget already registered " + w.Name)
+	}
+
+	tpl, err := template.New("layout.html").ParseFS(templatesFS, "templates/layout.html")
+	if err != nil {
+		return errors.Wrap(err, "oembed.AddWidget error on parse widget layout")
+	}
+
+	_, err = tpl.New("content").Parse(w.Template)
+	if err != nil {
+		return errors.Wrap(err, "oembed.AddWidget error on parse widget template "+w.Name)
+	}
+
+	w.tpl = tpl
+	p.widgets[w.Name] = w
+	return nil
+}
+
+// GetWidget returns one registered widget or nil
+func (p *Plugin) GetWidget(name string) *Widget {
+	return p.widgets[name]
+}
+
+// WidgetHandler - GET /widget/:name
+func (p *Plugin) WidgetHandler(c echo.Context) error {
+	w := p.widgets[c.Param("name")]
+	if w == nil {
+		return &catu.HTTPError{Code: http.StatusNotFound, Message: "Not Found"}
+	}
+
+	origin := requestOrigin(c.Request())
+	if origin != "" && !w.IsOriginAllowed(origin) {
+		logrus.WithFields(logrus.Fields{
+			"widget": w.Name,
+			"origin": origin,
+		}).Debug("oembed.WidgetHandler origin not allowed")
+
+		return c.String(http.StatusForbidden, "Forbidden")
+	}
+
+	var data interface{}
+	if w.Data != nil {
+		var err error
+		data, err = w.Data(c)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx := widgetTemplateCTX{Name: w.Name, Title: w.Title, Data: data}
+
+	var contentBuffer bytes.Buffer
+	if err := w.tpl.ExecuteTemplate(&contentBuffer, "content", &ctx); err != nil {
+		return errors.Wrap(err, "oembed.WidgetHandler error on render widget "+w.Name)
+	}
+
+	ctx.Content = template.HTML(contentBuffer.String())
+
+	var pageBuffer bytes.Buffer
+	if err := w.tpl.ExecuteTemplate(&pageBuffer, "layout.html", &ctx); err != nil {
+		return errors.Wrap(err, "oembed.WidgetHandler error on render widget layout "+w.Name)
+	}
+
+	maxAge := w.MaxAge
+	if maxAge == 0 {
+		maxAge = p.CacheAge
+	}
+
+	h := c.Response().Header()
+	h.Set("Content-Security-Policy", "sandbox allow-scripts allow-popups allow-popups-to-escape-sandbox; frame-ancestors "+w.frameAncestors(origin))
+	h.Set("Cache-Control", "public, max-age="+strconv.Itoa(maxAge))
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Referer")
+	h.Del("X-Frame-Options")
+
+	return c.HTMLBlob(http.StatusOK, pageBuffer.Bytes())
+}
+
+// IsOriginAllowed check if the origin is in the widget allow-list
+func (w *Widget) IsOriginAllowed(origin string) bool {
+	for _, o := range w.AllowedOrigins {
+		if o == "*" || strings.EqualFold(strings.TrimRight(o, "/"), origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// frame-ancestors value for the embedding origin, with all allowed origins when the origin is unknown
+func (w *Widget) frameAncestors(origin string) string {
+	if origin != "" {
+		return origin
+	}
+
+	if len(w.AllowedOrigins) == 0 {
+		return "'none'"
+	}
+
+	return strings.Join(w.AllowedOrigins, " ")
+}
+
+// requestOrigin returns the embedding page origin from Origin or Referer headers
+func requestOrigin(req *http.Request) string {
+	origin := req.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		origin = req.Header.Get("Referer")
+	}
+
+	if origin == "" {
+		return ""
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// EmbedSnippet renders the JS snippet that partners paste in their pages, registered as the embedWidget template function
+func (p *Plugin) EmbedSnippet(name string) template.HTML {
+	src, _ := json.Marshal(strings.TrimRight(p.BaseURL, "/") + p.WidgetPath + "/" + url.PathEscape(name))
+	id, _ := json.Marshal("catu-widget-" + name)
+
+	return template.HTML(`<div id="` + template.HTMLEscapeString("catu-widget-"+name) + `"></div>` +
+		`<script>(function(){var d=document,c=d.getElementById(` + string(id) + `),f=d.createElement("iframe");` +
+		`f.src=` + string(src) + `;f.setAttribute("sandbox","allow-scripts allow-popups allow-popups-to-escape-sandbox");` +
+		`f.setAttribute("loading","lazy");f.style.border="0";f.style.width="100%";c.appendChild(f);})();</script>`)
+}
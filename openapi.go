@@ -0,0 +1,532 @@
+package catu
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// OpenAPIDocument is one OpenAPI 3 document, see App.BuildOpenAPISpec
+type OpenAPIDocument struct {
+	OpenAPI    string                     `json:"openapi"`
+	Info       OpenAPIInfo                `json:"info"`
+	Servers    []OpenAPIServer            `json:"servers,omitempty"`
+	Paths      map[string]OpenAPIPathItem `json:"paths"`
+	Components OpenAPIComponents          `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+// OpenAPIPathItem has the path operations by lower case method, ex: "get"
+type OpenAPIPathItem map[string]*OpenAPIOperation
+
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*OpenAPISchema `json:"schemas"`
+}
+
+// OpenAPISchema is one OpenAPI 3.0 schema object, the empty schema accepts any value
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Enum                 []string                  `json:"enum,omitempty"`
+	MinLength            *int64                    `json:"minLength,omitempty"`
+	MaxLength            *int64                    `json:"maxLength,omitempty"`
+	Minimum              *float64                  `json:"minimum,omitempty"`
+	Maximum              *float64                  `json:"maximum,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+}
+
+// SetResourceModel links one resource to one model registered with SetModel, used in the OpenAPI schemas
+func (r *AppStruct) SetResourceModel(resourceName, modelName string) error {
+	resource := r.GetResource(resourceName)
+	if resource == nil {
+		return errors.New("catu.App.SetResourceModel resource not found: " + resourceName)
+	}
+
+	resource.Model = modelName
+	return nil
+}
+
+// BuildOpenAPISpec returns the OpenAPI 3 document of the resource routes.
+// Resources linked to one model with SetResourceModel have the model schema in the request and response bodies,
+// list responses have the records in one property with the resource name, ex: {"meta": {"count": 1}, "articles": [...]}.
+// The schemas use the model json tags, the validate tag required, email, url, uuid, oneof, min and max rules
+// and fields tagged with idcodec are strings in resources with one IDCodec
+func (r *AppStruct) BuildOpenAPISpec() (*OpenAPIDocument, error) {
+	cfg := r.Configuration
+
+	doc := &OpenAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: OpenAPIInfo{
+			Title:       cfg.GetF("SITE_NAME", "catu API"),
+			Description: cfg.Get("SITE_DESCRIPTION"),
+			Version:     cfg.GetF("OPENAPI_VERSION", "1.0.0"),
+		},
+		Paths:      map[string]OpenAPIPathItem{},
+		Components: OpenAPIComponents{Schemas: map[string]*OpenAPISchema{}},
+	}
+
+	if baseURL := cfg.Get("SITE_BASE_URL"); baseURL != "" {
+		doc.Servers = []OpenAPIServer{{URL: baseURL}}
+	}
+
+	b := &openAPISchemaBuilder{
+		app:      r,
+		models:   map[reflect.Type]string{},
+		visiting: map[reflect.Type]bool{},
+	}
+
+	doc.Components.Schemas["ErrorResponse"] = b.schemaOf(reflect.TypeOf(ErrorResponse{}))
+
+	resources := r.ListResources()
+	modelSchemas := map[string]*OpenAPISchema{}
+
+	for _, resource := range resources {
+		if resource.Model == "" {
+			continue
+		}
+
+		model := r.GetModel(resource.Model)
+		if model == nil {
+			return nil, errors.New("catu.App.BuildOpenAPISpec model not found: " + resource.Model)
+		}
+
+		t := reflect.TypeOf(model)
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return nil, errors.New("catu.App.BuildOpenAPISpec model is not one struct: " + resource.Model)
+		}
+
+		b.models[t] = t.Name()
+		modelSchemas[resource.Name] = &OpenAPISchema{Ref: "#/components/schemas/" + t.Name()}
+	}
+
+	for t, name := range b.models {
+		doc.Components.Schemas[name] = b.objectSchema(t)
+	}
+
+	for _, route := range r.router.Routes() {
+		resource, action := openAPIResourceRoute(resources, route)
+		if resource == nil {
+			continue
+		}
+
+		path, params := openAPIPath(route.Path, resource)
+		item := doc.Paths[path]
+		if item == nil {
+			item = OpenAPIPathItem{}
+			doc.Paths[path] = item
+		}
+
+		op := newOpenAPIOperation(r, resource, action, route.Method, modelSchemas[resource.Name])
+		op.Parameters = append(params, op.Parameters...)
+		item[strings.ToLower(route.Method)] = op
+	}
+
+	return doc, nil
+}
+
+// openAPIResourceRoute returns the resource and the HTTPResource action of one route, ex: "FindOne"
+func openAPIResourceRoute(resources []*HTTPResource, route *echo.Route) (*HTTPResource, string) {
+	i := strings.Index(route.Name, "(*HTTPResource).")
+	if i < 0 {
+		return nil, ""
+	}
+	action := strings.TrimSuffix(route.Name[i+len("(*HTTPResource)."):], "-fm")
+
+	// the longest resource path, for nested resources
+	var match *HTTPResource
+	for _, resource := range resources {
+		if resource.Path == "" || (route.Path != resource.Path && !strings.HasPrefix(route.Path, resource.Path+"/")) {
+			continue
+		}
+		if match == nil || len(resource.Path) > len(match.Path) {
+			match = resource
+		}
+	}
+
+	return match, action
+}
+
+// openAPIPath returns the OpenAPI path and path params of one echo route path, ex: "/articles/{id}"
+func openAPIPath(path string, resource *HTTPResource) (string, []*OpenAPIParameter) {
+	var params []*OpenAPIParameter
+
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if !strings.HasPrefix(s, ":") {
+			continue
+		}
+
+		name := s[1:]
+		segments[i] = "{" + name + "}"
+
+		schema := &OpenAPISchema{Type: "string"}
+		if name == "id" && resource.IDCodec == nil {
+			schema = &OpenAPISchema{Type: "integer", Format: "int64"}
+		}
+		params = append(params, &OpenAPIParameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+func newOpenAPIOperation(app App, resource *HTTPResource, action, method string, model *OpenAPISchema) *OpenAPIOperation {
+	name := resource.Name
+
+	op := &OpenAPIOperation{
+		OperationID: name + "." + strings.ToLower(action[:1]) + action[1:],
+		Tags:        []string{name},
+		Responses:   map[string]*OpenAPIResponse{},
+	}
+	// Update is also bound to POST and PUT
+	if action == "Update" && method != http.MethodPatch {
+		op.OperationID += "." + strings.ToLower(method)
+	}
+
+	if model == nil {
+		model = &OpenAPISchema{Type: "object"}
+	}
+	single := &OpenAPISchema{Type: "object", Properties: map[string]*OpenAPISchema{name: model}}
+
+	ok := func(description string, schema *OpenAPISchema) {
+		op.Responses["200"] = openAPIJSONResponse(description, schema)
+	}
+
+	switch action {
+	case "Query", "Trash":
+		op.Summary = "List " + name
+		if action == "Trash" {
+			op.Summary = "List the soft deleted " + name
+		}
+		op.Parameters = []*OpenAPIParameter{
+			{Name: "limit", In: "query", Schema: &OpenAPISchema{Type: "integer"}},
+			{Name: "page", In: "query", Schema: &OpenAPISchema{Type: "integer"}},
+			{Name: "offset", In: "query", Schema: &OpenAPISchema{Type: "integer"}},
+			{Name: "sort", In: "query", Description: "Comma separated fields, descending with one - prefix", Schema: &OpenAPISchema{Type: "string"}},
+		}
+		ok("List of "+name, &OpenAPISchema{
+			Type: "object",
+			Properties: map[string]*OpenAPISchema{
+				"meta": {Type: "object", Properties: map[string]*OpenAPISchema{"count": {Type: "integer", Format: "int64"}}},
+				name:   {Type: "array", Items: model},
+			},
+		})
+	case "Count":
+		op.Summary = "Count " + name
+		ok("Count of "+name, &OpenAPISchema{Type: "object", Properties: map[string]*OpenAPISchema{"count": {Type: "integer", Format: "int64"}}})
+	case "Create":
+		op.Summary = "Create one " + name + " record"
+		op.RequestBody = &OpenAPIRequestBody{Required: true, Content: map[string]*OpenAPIMediaType{echo.MIMEApplicationJSON: {Schema: model}}}
+		ok("Created record", single)
+	case "FindOne":
+		op.Summary = "Find one " + name + " record"
+		ok("Record", single)
+	case "Update":
+		op.Summary = "Update one " + name + " record"
+		op.RequestBody = &OpenAPIRequestBody{Required: true, Content: map[string]*OpenAPIMediaType{echo.MIMEApplicationJSON: {Schema: model}}}
+		ok("Updated record", single)
+	case "Delete":
+		op.Summary = "Delete one " + name + " record"
+		op.Responses["200"] = &OpenAPIResponse{Description: "Deleted"}
+	case "Restore":
+		op.Summary = "Restore one soft deleted " + name + " record"
+		op.Responses["200"] = &OpenAPIResponse{Description: "Restored"}
+	case "ForceDelete":
+		op.Summary = "Delete one " + name + " record permanently"
+		op.Responses["200"] = &OpenAPIResponse{Description: "Deleted"}
+	}
+
+	errorRef := &OpenAPISchema{Ref: "#/components/schemas/ErrorResponse"}
+	switch action {
+	case "Query", "Trash", "Create", "Update":
+		code := validationStatusCode(app)
+		op.Responses[strconv.Itoa(code)] = openAPIJSONResponse("Invalid params", errorRef)
+	}
+	if openAPIHasID(action) {
+		op.Responses["404"] = openAPIJSONResponse("Not Found", errorRef)
+	}
+	op.Responses["500"] = openAPIJSONResponse("Internal Server Error", errorRef)
+
+	return op
+}
+
+func openAPIHasID(action string) bool {
+	switch action {
+	case "FindOne", "Update", "Delete", "Restore", "ForceDelete":
+		return true
+	}
+	return false
+}
+
+func openAPIJSONResponse(description string, schema *OpenAPISchema) *OpenAPIResponse {
+	return &OpenAPIResponse{
+		Description: description,
+		Content:     map[string]*OpenAPIMediaType{echo.MIMEApplicationJSON: {Schema: schema}},
+	}
+}
+
+// openAPISchemaBuilder builds the schemas with reflection, models are component references
+type openAPISchemaBuilder struct {
+	app App
+	// component names of the resource models
+	models map[reflect.Type]string
+	// struct types in build, to stop recursive types
+	visiting map[reflect.Type]bool
+}
+
+var (
+	openAPITimeType      = reflect.TypeOf(time.Time{})
+	openAPIDeletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+	openAPIRawType       = reflect.TypeOf(json.RawMessage{})
+)
+
+func (b *openAPISchemaBuilder) schemaOf(t reflect.Type) *OpenAPISchema {
+	switch t {
+	case openAPITimeType:
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	case openAPIDeletedAtType:
+		return &OpenAPISchema{Type: "string", Format: "date-time", Nullable: true}
+	case openAPIRawType:
+		return &OpenAPISchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := b.schemaOf(t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+	case reflect.Bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &OpenAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &OpenAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &OpenAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &OpenAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &OpenAPISchema{Type: "string", Format: "byte"}
+		}
+		return &OpenAPISchema{Type: "array", Items: b.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &OpenAPISchema{Type: "object", AdditionalProperties: b.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if name, ok := b.models[t]; ok {
+			return &OpenAPISchema{Ref: "#/components/schemas/" + name}
+		}
+		if b.visiting[t] {
+			return &OpenAPISchema{Type: "object"}
+		}
+		return b.objectSchema(t)
+	}
+
+	return &OpenAPISchema{}
+}
+
+func (b *openAPISchemaBuilder) objectSchema(t reflect.Type) *OpenAPISchema {
+	b.visiting[t] = true
+	defer delete(b.visiting, t)
+
+	s := &OpenAPISchema{Type: "object", Properties: map[string]*OpenAPISchema{}}
+	b.addFields(s, t)
+	sort.Strings(s.Required)
+	return s
+}
+
+func (b *openAPISchemaBuilder) addFields(s *OpenAPISchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		// embedded structs without json name have the fields in the parent
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				b.addFields(s, ft)
+				continue
+			}
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fs := b.schemaOf(f.Type)
+		if resource := f.Tag.Get("idcodec"); resource != "" && b.app.GetResourceIDCodec(resource) != nil {
+			fs = &OpenAPISchema{Type: "string", Nullable: f.Type.Kind() == reflect.Ptr}
+		}
+
+		if applyOpenAPIValidation(fs, f.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+
+		s.Properties[name] = fs
+	}
+}
+
+// applyOpenAPIValidation sets the validate tag rules in the schema, returns true for required fields
+func applyOpenAPIValidation(s *OpenAPISchema, tag string) bool {
+	required := false
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, param := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, param = rule[:i], rule[i+1:]
+		}
+
+		switch name {
+		case "dive":
+			// next rules are of the slice items
+			return required
+		case "required":
+			required = true
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		case "uuid", "uuid4":
+			s.Format = "uuid"
+		case "oneof":
+			s.Enum = strings.Fields(param)
+		case "min", "max", "len", "gte", "lte":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+
+			min := name == "min" || name == "gte" || name == "len"
+			max := name == "max" || name == "lte" || name == "len"
+
+			if s.Type == "string" {
+				length := int64(n)
+				if min {
+					s.MinLength = &length
+				}
+				if max {
+					s.MaxLength = &length
+				}
+			} else if s.Type == "integer" || s.Type == "number" {
+				if min {
+					s.Minimum = &n
+				}
+				if max {
+					s.Maximum = &n
+				}
+			}
+		}
+	}
+
+	return required
+}
+
+// bindOpenAPI serves the OpenAPI document in OPENAPI_PATH (default "/api/openapi.json") with OPENAPI_ENABLED=true
+// and one Swagger UI page in OPENAPI_UI_PATH (default "/api/docs") with OPENAPI_UI=true
+func (r *AppStruct) bindOpenAPI() {
+	cfg := r.Configuration
+	if !cfg.GetBool("OPENAPI_ENABLED") {
+		return
+	}
+
+	specPath := cfg.GetF("OPENAPI_PATH", "/api/openapi.json")
+
+	r.router.GET(specPath, func(c echo.Context) error {
+		doc, err := r.BuildOpenAPISpec()
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, doc)
+	})
+
+	if cfg.GetBool("OPENAPI_UI") {
+		page := strings.ReplaceAll(openAPIUIPage, "{{specPath}}", strconv.Quote(specPath))
+		r.router.GET(cfg.GetF("OPENAPI_UI_PATH", "/api/docs"), func(c echo.Context) error {
+			return c.HTML(http.StatusOK, page)
+		})
+	}
+}
+
+const openAPIUIPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>API docs</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@4/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@4/swagger-ui-bundle.js"></script>
+<script>SwaggerUIBundle({url: {{specPath}}, dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
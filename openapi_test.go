@@ -0,0 +1,119 @@
+package catu
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files")
+
+type openAPITestAuthor struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type openAPITestArticle struct {
+	ID        uint64             `json:"id" idcodec:"articles"`
+	Title     string             `json:"title" validate:"required,max=120"`
+	Email     string             `json:"email,omitempty" validate:"omitempty,email"`
+	Status    string             `json:"status" validate:"oneof=draft published"`
+	Views     int64              `json:"views" validate:"min=0"`
+	Tags      []string           `json:"tags" validate:"dive,min=2"`
+	Author    *openAPITestAuthor `json:"author,omitempty"`
+	Meta      json.RawMessage    `json:"meta"`
+	CreatedAt time.Time          `json:"createdAt"`
+	DeletedAt gorm.DeletedAt     `json:"deletedAt"`
+	Secret    string             `json:"-"`
+	internal  string
+}
+
+func TestBuildOpenAPISpec(t *testing.T) {
+	newOpenAPIApp := func(t *testing.T) App {
+		t.Setenv("SITE_NAME", "Blog API")
+		t.Setenv("OPENAPI_ENABLED", "true")
+		t.Setenv("OPENAPI_UI", "true")
+		t.Setenv("SCHEMA_DRIFT_CHECK", "false")
+		app := newTestApp(t)
+
+		api := app.GetRouterGroup("api")
+		assert.Nil(t, app.SetResourceWithOptions("articles", &idCodecTestController{}, api.Group("/articles"), ResourceOptions{SoftDelete: true}))
+		codec, err := NewHashIDCodec("test-salt")
+		assert.Nil(t, err)
+		assert.Nil(t, app.SetResourceIDCodec("articles", codec))
+		app.SetModel("article", &openAPITestArticle{})
+		assert.Nil(t, app.SetResourceModel("articles", "article"))
+		assert.NotNil(t, app.SetResourceModel("unknown", "article"))
+
+		assert.Nil(t, app.Bootstrap())
+		return app
+	}
+
+	t.Run("Should match the golden document", func(t *testing.T) {
+		app := newOpenAPIApp(t)
+
+		doc, err := app.BuildOpenAPISpec()
+		assert.Nil(t, err)
+		data, err := json.MarshalIndent(doc, "", "  ")
+		assert.Nil(t, err)
+
+		golden := filepath.Join("testdata", "openapi_articles.golden.json")
+		if *updateGolden {
+			assert.Nil(t, os.MkdirAll("testdata", 0755))
+			assert.Nil(t, os.WriteFile(golden, append(data, '\n'), 0644))
+		}
+
+		expected, err := os.ReadFile(golden)
+		assert.Nil(t, err)
+		assert.JSONEq(t, string(expected), string(data))
+	})
+
+	t.Run("Should serve the document and the UI page", func(t *testing.T) {
+		app := newOpenAPIApp(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		doc := OpenAPIDocument{}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+		assert.Equal(t, "Blog API", doc.Info.Title)
+		assert.NotNil(t, doc.Paths["/api/articles/{id}"]["get"])
+
+		req = httptest.NewRequest(http.MethodGet, "/api/docs", nil)
+		rec = httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `SwaggerUIBundle({url: "/api/openapi.json"`)
+	})
+
+	t.Run("Should not serve the document without OPENAPI_ENABLED", func(t *testing.T) {
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+
+		req := httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("Should return one error for missing models", func(t *testing.T) {
+		app := newTestApp(t)
+		api := app.GetRouterGroup("api")
+		assert.Nil(t, app.SetResource("articles", &idCodecTestController{}, api.Group("/articles")))
+		assert.Nil(t, app.SetResourceModel("articles", "missing"))
+
+		_, err := app.BuildOpenAPISpec()
+		assert.NotNil(t, err)
+	})
+}
@@ -54,3 +54,12 @@ func NewPager() *Pager {
 
 	return &p
 }
+
+// Reset the pager to the NewPager state keeping the allocated links slice for reuse
+func (r *Pager) Reset() {
+	links := r.Links[:0]
+	*r = Pager{}
+	r.MaxLinks = 2
+	r.Page = 1
+	r.Links = links
+}
@@ -0,0 +1,85 @@
+package catu
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// ResourcePermissions are the permissions checked in the resource routes, see ResourceOptions.
+// Empty permissions default to "<resource>.<action>", ex: "articles.find"
+type ResourcePermissions struct {
+	// GET /, default "<resource>.find"
+	Query string
+	// GET /count, default "<resource>.count"
+	Count string
+	// POST /, default "<resource>.create"
+	Create string
+	// GET /:id, default "<resource>.findOne"
+	FindOne string
+	// POST, PATCH and PUT /:id, default "<resource>.update"
+	Update string
+	// DELETE /:id, default "<resource>.delete"
+	Delete string
+}
+
+// withDefaults returns one copy with the "<resource>.<action>" permissions in the empty fields
+func (p ResourcePermissions) withDefaults(resourceName string) *ResourcePermissions {
+	set := func(v *string, action string) {
+		if *v == "" {
+			*v = resourceName + "." + action
+		}
+	}
+
+	set(&p.Query, "find")
+	set(&p.Count, "count")
+	set(&p.Create, "create")
+	set(&p.FindOne, "findOne")
+	set(&p.Update, "update")
+	set(&p.Delete, "delete")
+
+	return &p
+}
+
+// RequirePermission returns one route middleware that responds 403 if the request roles can't do the permission, ex:
+//
+//	app.GetRouter().GET("/reports", handler, catu.RequirePermission("reports.find"))
+//
+// Anonymous requests have the ACL_UNAUTHENTICATED_ROLE role, default "unAuthenticated", see App.Can
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx, ok := c.(*RequestContext)
+			if !ok || ctx.App == nil || !ctx.Can(permission) {
+				logrus.WithFields(logrus.Fields{
+					"permission": permission,
+					"path":       c.Path(),
+					"method":     c.Request().Method,
+					"requestId":  GetRequestID(c),
+				}).Debug("catu.RequirePermission forbidden")
+
+				return &HTTPError{Code: http.StatusForbidden, Message: http.StatusText(http.StatusForbidden)}
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// resourcePermission returns the RequirePermission middleware of one resource action or one middleware that does nothing
+func resourcePermission(resource *HTTPResource, permission func(p *ResourcePermissions) string) echo.MiddlewareFunc {
+	if resource.Permissions == nil {
+		return func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+	}
+
+	return RequirePermission(permission(resource.Permissions))
+}
+
+// GetUnauthenticatedRole returns the role of anonymous requests, default "unAuthenticated"
+func (r *AppStruct) GetUnauthenticatedRole() string {
+	r.rolesMu.RLock()
+	defer r.rolesMu.RUnlock()
+
+	return r.unauthenticatedRole
+}
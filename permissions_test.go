@@ -0,0 +1,82 @@
+package catu
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-catupiry/catu/acl"
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequirePermission(t *testing.T) {
+	t.Setenv("ACL_UNAUTHENTICATED_ROLE", "anonymous")
+	app := newTestApp(t)
+
+	// authenticates the X-Test-User header with the X-Test-Role role
+	app.GetEvents().On("bindMiddlewares", event.ListenerFunc(func(e event.Event) error {
+		app.GetRouter().Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				if id := c.Request().Header.Get("X-Test-User"); id != "" {
+					c.(*RequestContext).SetAuthenticatedUserAndFillRoles(&testUser{ID: id, Roles: []string{c.Request().Header.Get("X-Test-Role")}})
+				}
+				return next(c)
+			}
+		})
+		return nil
+	}), event.Low)
+
+	app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+		app.GetRouter().GET("/reports", func(c echo.Context) error {
+			return c.String(http.StatusOK, "reports")
+		}, RequirePermission("reports.find"))
+
+		return app.SetResourceWithOptions("articles", &resourceTestController{name: "articles"}, app.GetRouterGroup("api").Group("/articles"), ResourceOptions{
+			Permissions: &ResourcePermissions{Query: "content.find"},
+		})
+	}))
+
+	app.GetEvents().On("bootstrap", event.ListenerFunc(func(e event.Event) error {
+		if err := app.SetRole("anonymous", acl.Role{Name: "anonymous", Permissions: []string{"content.find"}}); err != nil {
+			return err
+		}
+		return app.SetRole("editor", acl.Role{Name: "editor", Permissions: []string{"content.find", "reports.find", "articles.update"}})
+	}))
+
+	assert.Nil(t, app.Bootstrap())
+
+	request := func(method, path, role string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+		if role != "" {
+			req.Header.Set("X-Test-User", "1")
+			req.Header.Set("X-Test-Role", role)
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	t.Run("Should allow the administrator in all routes", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/reports", "administrator"))
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/api/articles", "administrator"))
+		assert.Equal(t, http.StatusOK, request(http.MethodDelete, "/api/articles/1", "administrator"))
+	})
+
+	t.Run("Should check the editor permissions", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/reports", "editor"))
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/api/articles", "editor"))
+		assert.Equal(t, http.StatusOK, request(http.MethodPatch, "/api/articles/1", "editor"))
+		assert.Equal(t, http.StatusForbidden, request(http.MethodDelete, "/api/articles/1", "editor"))
+		assert.Equal(t, http.StatusForbidden, request(http.MethodPost, "/api/articles", "editor"))
+	})
+
+	t.Run("Should use the unauthenticated role for anonymous requests", func(t *testing.T) {
+		assert.Equal(t, "anonymous", app.GetUnauthenticatedRole())
+		assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/reports", ""))
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/api/articles", ""))
+		assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/api/articles/1", ""))
+	})
+}
@@ -0,0 +1,99 @@
+package catu
+
+import (
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Pools for objects allocated in every request.
+// Objects returned to the pools must not be retained after the request ends.
+var (
+	requestContextPool = sync.Pool{
+		New: func() interface{} {
+			return &RequestContext{}
+		},
+	}
+
+	validationResponsePool = sync.Pool{
+		New: func() interface{} {
+			return &ErrorResponse{}
+		},
+	}
+)
+
+// releasedContext is set as EchoContext in released RequestContexts when the poison check is enabled.
+// Any use after release panics with catu.releasedContext in the stack trace.
+type releasedContext struct {
+	echo.Context
+}
+
+// acquireRequestContext get one RequestContext from the pool filled for the echo request
+func acquireRequestContext(app App, c echo.Context) *RequestContext {
+	ctx := requestContextPool.Get().(*RequestContext)
+	ctx.fill(app, c)
+	return ctx
+}
+
+// releaseRequestContext clear and return the ctx to the pool.
+// With poison enabled the ctx is never reused and any later access panics.
+func releaseRequestContext(ctx *RequestContext, poison bool) {
+	ctx.clear()
+
+	if poison {
+		ctx.EchoContext = &releasedContext{}
+		ctx.released = true
+		return
+	}
+
+	requestContextPool.Put(ctx)
+}
+
+// clear all request data keeping allocated memory that is safe to reuse
+func (r *RequestContext) clear() {
+	pager := r.Pager
+	bodyClass := r.BodyClass[:0]
+
+	*r = RequestContext{}
+
+	if pager != nil {
+		pager.Reset()
+	}
+
+	r.Pager = pager
+	r.BodyClass = bodyClass
+}
+
+// IsReleased returns true if the ctx was released to the pool with the poison check enabled
+func (r *RequestContext) IsReleased() bool {
+	return r.released
+}
+
+func acquireValidationResponse() *ErrorResponse {
+	return validationResponsePool.Get().(*ErrorResponse)
+}
+
+func releaseValidationResponse(resp *ErrorResponse) {
+	for i := range resp.Errors {
+		*resp.Errors[i] = ValidationFieldError{}
+	}
+
+	*resp = ErrorResponse{Errors: resp.Errors[:0]}
+	validationResponsePool.Put(resp)
+}
+
+// next ValidationFieldError reusing the pooled items if available
+func (r *ErrorResponse) nextFieldError() *ValidationFieldError {
+	if len(r.Errors) < cap(r.Errors) {
+		r.Errors = r.Errors[:len(r.Errors)+1]
+		if el := r.Errors[len(r.Errors)-1]; el != nil {
+			return el
+		}
+	} else {
+		r.Errors = append(r.Errors, nil)
+	}
+
+	el := &ValidationFieldError{}
+	r.Errors[len(r.Errors)-1] = el
+	return el
+}
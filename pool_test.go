@@ -0,0 +1,151 @@
+package catu
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestContextClear(t *testing.T) {
+	app := newTestApp(t)
+	e := app.GetRouter()
+	req := httptest.NewRequest(http.MethodGet, "/content?page=3&limit=10", nil)
+	c := e.NewContext(req, httptest.NewRecorder())
+
+	ctx := acquireRequestContext(app, c)
+	assert.Equal(t, int64(3), ctx.Pager.Page)
+	assert.Equal(t, int64(10), ctx.Pager.Limit)
+
+	ctx.Title = "Some title"
+	ctx.IsAuthenticated = true
+	ctx.Roles = []string{"administrator"}
+	ctx.AddBodyClass("page-content")
+	pager := ctx.Pager
+
+	ctx.clear()
+
+	assert.Nil(t, ctx.App)
+	assert.Nil(t, ctx.EchoContext)
+	assert.Equal(t, "", ctx.Title)
+	assert.False(t, ctx.IsAuthenticated)
+	assert.Nil(t, ctx.Roles)
+	assert.Equal(t, "", ctx.GetBodyClassText())
+	assert.Same(t, pager, ctx.Pager)
+	assert.Equal(t, int64(1), ctx.Pager.Page)
+	assert.Equal(t, int64(0), ctx.Pager.Limit)
+	assert.Equal(t, "", ctx.Pager.CurrentUrl)
+}
+
+func TestReleaseRequestContextPoison(t *testing.T) {
+	app := newTestApp(t)
+	c := app.GetRouter().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+	ctx := acquireRequestContext(app, c)
+	releaseRequestContext(ctx, true)
+
+	assert.True(t, ctx.IsReleased())
+	assert.Panics(t, func() {
+		ctx.Request()
+	})
+}
+
+func TestRequestContextPoolConcurrentRequests(t *testing.T) {
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+
+	app.GetRouter().GET("/echo-title", func(c echo.Context) error {
+		ctx := c.(*RequestContext)
+		ctx.Title = c.QueryParam("title")
+		ctx.AddBodyClass("title-" + ctx.Title)
+		return c.String(http.StatusOK, ctx.Title+"|"+ctx.GetBodyClassText()+"|"+strconv.FormatInt(ctx.Pager.Page, 10))
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			title := strconv.Itoa(i)
+			req := httptest.NewRequest(http.MethodGet, "/echo-title?title="+title, nil)
+			rec := httptest.NewRecorder()
+			app.GetRouter().ServeHTTP(rec, req)
+			assert.Equal(t, title+"|title-"+title+"|1", rec.Body.String())
+		}(i)
+	}
+	wg.Wait()
+}
+
+type poolTestUser struct {
+	Email string `validate:"required,email"`
+	Name  string `validate:"required"`
+}
+
+func TestValidationResponsePoolReuse(t *testing.T) {
+	app := newTestApp(t)
+	v := validator.New()
+
+	for _, tc := range []struct {
+		user   poolTestUser
+		errors int
+	}{
+		{poolTestUser{}, 2},
+		{poolTestUser{Name: "Alberto"}, 1},
+		{poolTestUser{}, 2},
+	} {
+		err := v.Struct(tc.user)
+		rec := httptest.NewRecorder()
+		c := app.GetRouter().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+		ctx := NewRequestContext(&RequestContextOpts{EchoContext: c})
+
+		validationError(err.(validator.ValidationErrors), err, ctx)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, tc.errors, strings.Count(rec.Body.String(), `"tag":`))
+	}
+}
+
+func BenchmarkNewRequestContext(b *testing.B) {
+	app := Init(&AppOptions{})
+	c := app.GetRouter().NewContext(httptest.NewRequest(http.MethodGet, "/content?page=2", nil), httptest.NewRecorder())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx := NewRequestContext(&RequestContextOpts{EchoContext: c})
+		_ = ctx
+	}
+}
+
+func BenchmarkAcquireRequestContext(b *testing.B) {
+	app := Init(&AppOptions{})
+	c := app.GetRouter().NewContext(httptest.NewRequest(http.MethodGet, "/content?page=2", nil), httptest.NewRecorder())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx := acquireRequestContext(app, c)
+		releaseRequestContext(ctx, false)
+	}
+}
+
+func BenchmarkValidationError(b *testing.B) {
+	app := Init(&AppOptions{})
+	v := validator.New()
+	err := v.Struct(poolTestUser{})
+	c := app.GetRouter().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())
+	ctx := NewRequestContext(&RequestContextOpts{EchoContext: c})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.SetResponse(echo.NewResponse(httptest.NewRecorder(), app.GetRouter()))
+		validationError(err.(validator.ValidationErrors), err, ctx)
+	}
+}
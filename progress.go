@@ -0,0 +1,370 @@
+package catu
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-catupiry/catu/cache"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Progress status
+const (
+	ProgressRunning   = "running"
+	ProgressCompleted = "completed"
+	ProgressFailed    = "failed"
+	ProgressCanceled  = "canceled"
+)
+
+// response header with the token of the progress started in the request, see RequestContext.StartProgress
+const HeaderProgressToken = "X-Progress-Token"
+
+// ProgressState is the progress stored in the app cache and returned by GET /_progress/:token
+type ProgressState struct {
+	Token string `json:"token"`
+	// ID of the user that started the progress, empty for anonymous users
+	UserID    string    `json:"-"`
+	Status    string    `json:"status"`
+	Total     int64     `json:"total"`
+	Current   int64     `json:"current"`
+	Percent   float64   `json:"percent"`
+	Step      string    `json:"step,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// progressRecord is the ProgressState saved in the app cache as JSON, with the user ID
+type progressRecord struct {
+	ProgressState
+	UserID string `json:"userId"`
+}
+
+// Done returns true for completed, failed and canceled progresses
+func (s *ProgressState) Done() bool {
+	return s.Status != ProgressRunning
+}
+
+// ProgressReporter is the interface used by request handlers and async jobs to report one progress
+type ProgressReporter interface {
+	Token() string
+	// Advance adds n to the current value
+	Advance(n int64, step string) error
+	// Set the current value
+	Set(current int64, step string) error
+	Complete() error
+	Fail(err error) error
+	Cancel() error
+}
+
+// Progress is one ProgressReporter saved in the app cache, so any instance with the same cache store can read it
+type Progress struct {
+	cache *cache.Cache
+	ttl   time.Duration
+
+	mu    sync.Mutex
+	state ProgressState
+	done  chan struct{}
+}
+
+// NewProgress starts one progress owned by userID, use it in async jobs.
+// The state is kept in the app cache for PROGRESS_TTL seconds after the last update, default 3600
+func NewProgress(app App, userID string, total int64) (*Progress, error) {
+	token, err := newProgressToken()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Progress{
+		cache: app.GetCache(),
+		ttl:   time.Duration(app.GetConfiguration().GetInt64F("PROGRESS_TTL", 3600)) * time.Second,
+		state: ProgressState{
+			Token:  token,
+			UserID: userID,
+			Status: ProgressRunning,
+			Total:  total,
+		},
+		done: make(chan struct{}),
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p, p.save()
+}
+
+// openProgress resumes one progress saved in the app cache, ex: in the job workers. Returns nil if the token doesn't exists
+func openProgress(app App, token string) *Progress {
+	state := GetProgress(app, token)
+	if state == nil {
+		return nil
+	}
+
+	p := &Progress{
+		cache: app.GetCache(),
+		ttl:   time.Duration(app.GetConfiguration().GetInt64F("PROGRESS_TTL", 3600)) * time.Second,
+		state: *state,
+		done:  make(chan struct{}),
+	}
+	if p.state.Done() {
+		close(p.done)
+	}
+
+	return p
+}
+
+// newProgressToken returns one unguessable token with 256 random bits
+func newProgressToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "catu.NewProgress error on generate token")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func progressCacheKey(token string) string {
+	return "progress:" + token
+}
+
+func (p *Progress) Token() string {
+	return p.state.Token
+}
+
+// State returns one copy of the current state
+func (p *Progress) State() ProgressState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.state
+}
+
+func (p *Progress) Advance(n int64, step string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.update(p.state.Current+n, step)
+}
+
+func (p *Progress) Set(current int64, step string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.update(current, step)
+}
+
+func (p *Progress) Complete() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state.Total > 0 {
+		p.state.Current = p.state.Total
+	}
+	return p.finish(ProgressCompleted, "")
+}
+
+func (p *Progress) Fail(err error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	msg := "failed"
+	if err != nil {
+		msg = err.Error()
+	}
+	return p.finish(ProgressFailed, msg)
+}
+
+func (p *Progress) Cancel() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.finish(ProgressCanceled, "")
+}
+
+// update ignores updates after the progress is done
+func (p *Progress) update(current int64, step string) error {
+	if p.state.Done() {
+		return nil
+	}
+
+	p.state.Current = current
+	if step != "" {
+		p.state.Step = step
+	}
+
+	return p.save()
+}
+
+func (p *Progress) finish(status, errorMessage string) error {
+	if p.state.Done() {
+		return nil
+	}
+
+	p.state.Status = status
+	p.state.Error = errorMessage
+	close(p.done)
+
+	return p.save()
+}
+
+func (p *Progress) save() error {
+	p.state.UpdatedAt = time.Now()
+	p.state.Percent = 0
+	if p.state.Total > 0 {
+		p.state.Percent = float64(p.state.Current) * 100 / float64(p.state.Total)
+	}
+
+	// the state is saved as JSON, so the cache stores shared between instances can serialize it
+	data, err := json.Marshal(&progressRecord{ProgressState: p.state, UserID: p.state.UserID})
+	if err != nil {
+		return errors.Wrap(err, "catu.Progress error on encode state")
+	}
+	if err := p.cache.Set(progressCacheKey(p.state.Token), string(data), p.ttl); err != nil {
+		return errors.Wrap(err, "catu.Progress error on save state")
+	}
+
+	return nil
+}
+
+// GetProgress reads one progress state from the app cache, returns nil if the token doesn't exists
+func GetProgress(app App, token string) *ProgressState {
+	v, ok := app.GetCache().Get(progressCacheKey(token))
+	if !ok {
+		return nil
+	}
+
+	var data []byte
+	switch value := v.(type) {
+	case string:
+		data = []byte(value)
+	case []byte:
+		data = value
+	default:
+		// ex: one cache store that decodes the JSON values
+		data, _ = json.Marshal(value)
+	}
+
+	record := &progressRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"token": token,
+			"error": fmt.Sprintf("%+v\n", err),
+		}).Warn("catu.GetProgress error on decode state")
+		return nil
+	}
+
+	state := record.ProgressState
+	state.UserID = record.UserID
+	return &state
+}
+
+// StartProgress starts one progress bound to the request and sets its token in the X-Progress-Token header.
+// The progress completes when the handler responds with success, fails with error status codes
+// and is canceled if the client disconnects before the response. Use NewProgress for work after the response
+func (r *RequestContext) StartProgress(total int64) (*Progress, error) {
+	app := r.App
+	if app == nil {
+		app = GetApp()
+	}
+
+	p, err := NewProgress(app, progressUserID(r), total)
+	if err != nil {
+		return nil, err
+	}
+
+	res := r.Response()
+	reqCtx := r.Request().Context()
+	res.Header().Set(HeaderProgressToken, p.Token())
+	res.Before(func() {
+		// ex: the 408 response after one client cancel
+		if reqCtx.Err() == context.Canceled {
+			p.Cancel()
+			return
+		}
+		if res.Status >= http.StatusBadRequest {
+			p.Fail(errors.New(http.StatusText(res.Status)))
+			return
+		}
+		p.Complete()
+	})
+
+	requestDone := reqCtx.Done()
+	go func() {
+		select {
+		case <-requestDone:
+			p.Cancel()
+		case <-p.done:
+		}
+	}()
+
+	return p, nil
+}
+
+func progressUserID(c echo.Context) string {
+	if ctx, ok := c.(*RequestContext); ok && ctx.IsAuthenticated && ctx.AuthenticatedUser != nil {
+		return ctx.AuthenticatedUser.GetID()
+	}
+	return ""
+}
+
+// progressHandler responds the progress state as JSON or streams it as server-sent events with Accept: text/event-stream.
+// Only the user that started the progress can read it, other users get 404
+func progressHandler(app App) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		token := c.Param("token")
+
+		state := GetProgress(app, token)
+		if state == nil || state.UserID != progressUserID(c) {
+			return echo.NewHTTPError(http.StatusNotFound, "progress not found")
+		}
+
+		if !isEventStreamRequest(c) {
+			return c.JSON(http.StatusOK, state)
+		}
+
+		interval := time.Duration(app.GetConfiguration().GetInt64F("PROGRESS_SSE_INTERVAL_MS", 500)) * time.Millisecond
+
+		stream, err := NewSSEStream(c)
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var last time.Time
+		for {
+			if !state.UpdatedAt.Equal(last) {
+				last = state.UpdatedAt
+
+				if err := stream.Send("progress", "", state); err != nil {
+					return nil
+				}
+			}
+
+			if state.Done() {
+				return nil
+			}
+
+			select {
+			case <-stream.Done():
+				return nil
+			case <-ticker.C:
+			}
+
+			if state = GetProgress(app, token); state == nil {
+				logrus.WithFields(logrus.Fields{
+					"requestId": GetRequestID(c),
+				}).Debug("catu.progressHandler progress expired")
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package catu
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-catupiry/catu/cache"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// jsonTestStore saves the entries as JSON like one shared cache store, ex: redis
+type jsonTestStore struct {
+	*cache.MemoryStore
+}
+
+func (s *jsonTestStore) Get(key string) (*cache.Entry, bool, error) {
+	e, ok, err := s.MemoryStore.Get(key)
+	if !ok || err != nil {
+		return e, ok, err
+	}
+
+	decoded := &cache.Entry{}
+	err = json.Unmarshal(e.Value.([]byte), decoded)
+	return decoded, true, err
+}
+
+func (s *jsonTestStore) Set(key string, entry *cache.Entry, ttl time.Duration) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return s.MemoryStore.Set(key, &cache.Entry{Value: data, SoftExpiresAt: entry.SoftExpiresAt, HardExpiresAt: entry.HardExpiresAt}, ttl)
+}
+
+func TestProgress(t *testing.T) {
+	newProgressApp := func(t *testing.T) App {
+		t.Setenv("PROGRESS_SSE_INTERVAL_MS", "5")
+
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		// authenticates the X-Test-User header
+		app.GetRouter().Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				if id := c.Request().Header.Get("X-Test-User"); id != "" {
+					c.(*RequestContext).SetAuthenticatedUser(&testUser{ID: id})
+				}
+				return next(c)
+			}
+		})
+		return app
+	}
+
+	poll := func(app App, token, userID string) (int, *ProgressState) {
+		req := httptest.NewRequest(http.MethodGet, "/_progress/"+token, nil)
+		if userID != "" {
+			req.Header.Set("X-Test-User", userID)
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		state := &ProgressState{}
+		json.Unmarshal(rec.Body.Bytes(), state)
+		return rec.Code, state
+	}
+
+	t.Run("Should poll the progress state", func(t *testing.T) {
+		app := newProgressApp(t)
+
+		p, err := NewProgress(app, "", 4)
+		assert.Nil(t, err)
+		assert.Len(t, p.Token(), 43)
+
+		assert.Nil(t, p.Advance(1, "reading file"))
+
+		code, state := poll(app, p.Token(), "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, ProgressRunning, state.Status)
+		assert.Equal(t, int64(1), state.Current)
+		assert.Equal(t, float64(25), state.Percent)
+		assert.Equal(t, "reading file", state.Step)
+
+		assert.Nil(t, p.Complete())
+		assert.Nil(t, p.Advance(1, "ignored"))
+
+		_, state = poll(app, p.Token(), "")
+		assert.Equal(t, ProgressCompleted, state.Status)
+		assert.Equal(t, float64(100), state.Percent)
+		assert.Equal(t, "reading file", state.Step)
+
+		code, _ = poll(app, "unknown", "")
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+
+	t.Run("Should only respond the progress to the user that started it", func(t *testing.T) {
+		app := newProgressApp(t)
+
+		p, err := NewProgress(app, "1", 10)
+		assert.Nil(t, err)
+
+		code, _ := poll(app, p.Token(), "")
+		assert.Equal(t, http.StatusNotFound, code)
+		code, _ = poll(app, p.Token(), "2")
+		assert.Equal(t, http.StatusNotFound, code)
+		code, _ = poll(app, p.Token(), "1")
+		assert.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("Should complete, fail and cancel with the request", func(t *testing.T) {
+		app := newProgressApp(t)
+
+		started := make(chan *Progress, 1)
+		app.GetRouter().POST("/import", func(c echo.Context) error {
+			p, err := c.(*RequestContext).StartProgress(2)
+			if err != nil {
+				return err
+			}
+			p.Advance(1, "first")
+			started <- p
+
+			switch c.QueryParam("result") {
+			case "error":
+				return errors.New("import failed")
+			case "wait":
+				<-c.Request().Context().Done()
+				return nil
+			}
+			return c.NoContent(http.StatusNoContent)
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/import", nil)
+		req.Header.Set("X-Test-User", "1")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		progress := <-started
+
+		assert.Equal(t, progress.Token(), rec.Header().Get(HeaderProgressToken))
+		_, state := poll(app, progress.Token(), "1")
+		assert.Equal(t, ProgressCompleted, state.Status)
+		assert.Equal(t, int64(2), state.Current)
+
+		app.GetRouter().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/import?result=error", nil))
+		progress = <-started
+		_, state = poll(app, progress.Token(), "")
+		assert.Equal(t, ProgressFailed, state.Status)
+		assert.Equal(t, "Internal Server Error", state.Error)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		req = httptest.NewRequest(http.MethodPost, "/import?result=wait", nil).WithContext(ctx)
+		done := make(chan struct{})
+		go func() {
+			app.GetRouter().ServeHTTP(httptest.NewRecorder(), req)
+			close(done)
+		}()
+
+		progress = <-started
+		assert.Equal(t, ProgressRunning, progress.State().Status)
+		cancel()
+		<-done
+
+		assert.Eventually(t, func() bool {
+			_, state := poll(app, progress.Token(), "")
+			return state.Status == ProgressCanceled
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("Should stream the progress with server-sent events", func(t *testing.T) {
+		app := newProgressApp(t)
+
+		p, err := NewProgress(app, "", 2)
+		assert.Nil(t, err)
+
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			p.Advance(1, "half")
+			time.Sleep(20 * time.Millisecond)
+			p.Complete()
+		}()
+
+		req := httptest.NewRequest(http.MethodGet, "/_progress/"+p.Token(), nil)
+		req.Header.Set(echo.HeaderAccept, "text/event-stream")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.Equal(t, "text/event-stream", rec.Header().Get(echo.HeaderContentType))
+
+		events := strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n")
+		assert.GreaterOrEqual(t, len(events), 3)
+		assert.Contains(t, events[0], `"status":"running","total":2,"current":0`)
+		assert.Contains(t, rec.Body.String(), `"current":1,"percent":50,"step":"half"`)
+		assert.Contains(t, events[len(events)-1], `"status":"completed"`)
+		for _, e := range events {
+			assert.True(t, strings.HasPrefix(e, "event: progress\ndata: {"))
+		}
+	})
+
+	t.Run("Should read the progress from other instances with the same cache store", func(t *testing.T) {
+		store := cache.NewMemoryStore()
+
+		worker := newProgressApp(t)
+		worker.SetCache(cache.New(store))
+		web := newProgressApp(t)
+		web.SetCache(cache.New(store))
+
+		p, err := NewProgress(worker, "1", 3)
+		assert.Nil(t, err)
+		assert.Nil(t, p.Set(2, "processing"))
+
+		code, state := poll(web, p.Token(), "1")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, int64(2), state.Current)
+		assert.Equal(t, "processing", state.Step)
+	})
+
+	t.Run("Should decode the progress saved as JSON in the cache store", func(t *testing.T) {
+		app := newProgressApp(t)
+		app.SetCache(cache.New(&jsonTestStore{MemoryStore: cache.NewMemoryStore()}))
+
+		p, err := NewProgress(app, "1", 4)
+		assert.Nil(t, err)
+		assert.Nil(t, p.Advance(1, "started"))
+
+		code, state := poll(app, p.Token(), "1")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, int64(1), state.Current)
+		assert.Equal(t, float64(25), state.Percent)
+
+		code, _ = poll(app, p.Token(), "2")
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+}
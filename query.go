@@ -0,0 +1,258 @@
+package catu
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// QueryConfig is the list of query params accepted by ParseQuery
+type QueryConfig struct {
+	// Sortable fields, param name to db column, ex: {"createdAt": "created_at"}
+	SortableFields map[string]string
+	// Filterable fields, param name to db column.
+	// ?name=x filters by equality and ?name_like=x filters by contains
+	FilterableFields map[string]string
+	// Text sort fields compared case or accent insensitive, param name to collation, ex: {"title": CollationAccentInsensitive}.
+	// Use QueryOptions.Find for the same order in MySQL and sqlite
+	SortCollations map[string]SortCollation
+	// Default sort, ex: "-createdAt"
+	DefaultSort string
+	// Default: PAGER_LIMIT configuration or 20
+	DefaultLimit int
+	// Default: QUERY_MAX_LIMIT configuration or 50
+	MaxLimit int
+}
+
+type QuerySort struct {
+	Field     string
+	Column    string
+	Desc      bool
+	Collation SortCollation
+}
+
+type QueryFilter struct {
+	Field  string
+	Column string
+	// eq or like
+	Operator string
+	Value    string
+}
+
+// QueryOptions are the parsed pagination, sort and filter params of one list request
+type QueryOptions struct {
+	Limit   int
+	Offset  int
+	Page    int
+	Sort    []QuerySort
+	Filters []QueryFilter
+	// Total records, set by Count handlers
+	Total int64
+	// Sort strategy used by Find, ex: "collate" or "memory"
+	SortStrategy string
+
+	// app of the parsed request, with the collation configuration
+	app App
+}
+
+// getApp returns the ParseQuery request app or GetApp
+func (o *QueryOptions) getApp() App {
+	if o.app != nil {
+		return o.app
+	}
+	return GetApp()
+}
+
+// ParseQuery parses limit/offset or page/perPage, sort=-createdAt,name and field filters from the request query.
+// Invalid values and unknown sort fields return validation errors, responded with 400 by the app error handler
+func ParseQuery(c echo.Context, cfg *QueryConfig) (*QueryOptions, error) {
+	if cfg == nil {
+		cfg = &QueryConfig{}
+	}
+
+	app := handlerApp(c)
+	appCfg := app.GetConfiguration()
+
+	defaultLimit := cfg.DefaultLimit
+	if defaultLimit == 0 {
+		defaultLimit = appCfg.GetIntF("PAGER_LIMIT", 20)
+	}
+
+	maxLimit := cfg.MaxLimit
+	if maxLimit == 0 {
+		maxLimit = appCfg.GetIntF("QUERY_MAX_LIMIT", 50)
+	}
+
+	params := c.QueryParams()
+	errs := validator.ValidationErrors{}
+
+	intParam := func(name string, min int) (int, bool) {
+		v := params.Get(name)
+		if v == "" {
+			return 0, false
+		}
+
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, newQueryFieldError(name, "number", v, ""))
+			return 0, false
+		}
+
+		if n < min {
+			errs = append(errs, newQueryFieldError(name, "min", v, strconv.Itoa(min)))
+			return 0, false
+		}
+
+		return n, true
+	}
+
+	opts := &QueryOptions{Limit: defaultLimit, Page: 1, app: app}
+
+	if limit, ok := intParam("perPage", 1); ok {
+		opts.Limit = limit
+	}
+	if limit, ok := intParam("limit", 1); ok {
+		opts.Limit = limit
+	}
+	if opts.Limit > maxLimit {
+		opts.Limit = maxLimit
+	}
+
+	if page, ok := intParam("page", 1); ok {
+		opts.Page = page
+	}
+	opts.Offset = (opts.Page - 1) * opts.Limit
+
+	if offset, ok := intParam("offset", 0); ok {
+		opts.Offset = offset
+		opts.Page = offset/opts.Limit + 1
+	}
+
+	sortParam := params.Get("sort")
+	if sortParam == "" {
+		sortParam = cfg.DefaultSort
+	}
+
+	for _, s := range strings.Split(sortParam, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		desc := strings.HasPrefix(s, "-")
+		field := strings.TrimLeft(s, "-+")
+
+		column, ok := cfg.SortableFields[field]
+		if !ok {
+			errs = append(errs, newQueryFieldError("sort", "oneof", s, strings.Join(sortedKeys(cfg.SortableFields), " ")))
+			continue
+		}
+
+		opts.Sort = append(opts.Sort, QuerySort{Field: field, Column: column, Desc: desc, Collation: cfg.SortCollations[field]})
+	}
+
+	for _, field := range sortedKeys(cfg.FilterableFields) {
+		column := cfg.FilterableFields[field]
+
+		if v, ok := params[field]; ok && len(v) > 0 {
+			opts.Filters = append(opts.Filters, QueryFilter{Field: field, Column: column, Operator: "eq", Value: v[0]})
+		}
+
+		if v, ok := params[field+"_like"]; ok && len(v) > 0 && v[0] != "" {
+			opts.Filters = append(opts.Filters, QueryFilter{Field: field, Column: column, Operator: "like", Value: v[0]})
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return opts, nil
+}
+
+// ApplyFiltersToGorm adds the filters to the db query, use it in Count handlers
+func (o *QueryOptions) ApplyFiltersToGorm(db *gorm.DB) *gorm.DB {
+	for _, f := range o.Filters {
+		column := clause.Column{Name: f.Column}
+
+		switch f.Operator {
+		case "like":
+			// "!" is one escape char supported by mysql and sqlite
+			value := strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`).Replace(f.Value)
+			db = db.Where(clause.Expr{SQL: "? LIKE ? ESCAPE '!'", Vars: []interface{}{column, "%" + value + "%"}})
+		default:
+			db = db.Where(clause.Eq{Column: column, Value: f.Value})
+		}
+	}
+
+	return db
+}
+
+// ApplyToGorm adds the filters, sort, limit and offset to the db query.
+// Sort collations use the engine COLLATE clause, in sqlite it is NOCASE, see Find
+func (o *QueryOptions) ApplyToGorm(db *gorm.DB) *gorm.DB {
+	db = o.ApplyFiltersToGorm(db)
+
+	for i := range o.Sort {
+		db = db.Order(o.Sort[i].orderByColumn(o.getApp(), db))
+	}
+
+	return db.Limit(o.Limit).Offset(o.Offset)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// queryFieldError is one validator.FieldError for query params,
+// to respond invalid params with the same format of the body validation errors
+type queryFieldError struct {
+	field string
+	tag   string
+	value string
+	param string
+}
+
+func newQueryFieldError(field, tag, value, param string) *queryFieldError {
+	return &queryFieldError{field: field, tag: tag, value: value, param: param}
+}
+
+func (e *queryFieldError) Tag() string             { return e.tag }
+func (e *queryFieldError) ActualTag() string       { return e.tag }
+func (e *queryFieldError) Namespace() string       { return "query." + e.field }
+func (e *queryFieldError) StructNamespace() string { return "query." + e.field }
+func (e *queryFieldError) Field() string           { return e.field }
+func (e *queryFieldError) StructField() string     { return e.field }
+func (e *queryFieldError) Value() interface{}      { return e.value }
+func (e *queryFieldError) Param() string           { return e.param }
+func (e *queryFieldError) Kind() reflect.Kind      { return reflect.String }
+func (e *queryFieldError) Type() reflect.Type      { return reflect.TypeOf("") }
+
+// Translate returns the registered message of the tag. The query params are numbers or strings, so the
+// tags with one message per kind use the number message, ex: "min-number"
+func (e *queryFieldError) Translate(trans ut.Translator) string {
+	for _, key := range []string{e.tag, e.tag + "-number"} {
+		if msg, err := trans.T(key, e.field, e.param); err == nil {
+			return msg
+		}
+	}
+
+	return e.Error()
+}
+
+func (e *queryFieldError) Error() string {
+	return fmt.Sprintf("Key: 'query.%s' Error:Field validation for '%s' failed on the '%s' tag", e.field, e.field, e.tag)
+}
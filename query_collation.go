@@ -0,0 +1,285 @@
+package catu
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// SortCollation is the text comparison used to sort one field, see QueryConfig.SortCollations
+type SortCollation int
+
+const (
+	// Database default, usually binary in sqlite
+	CollationDefault SortCollation = iota
+	// Case insensitive, ex: "apple" and "Apple" are equal
+	CollationCaseInsensitive
+	// Case and accent insensitive, ex: "Éden" and "eden" are equal
+	CollationAccentInsensitive
+)
+
+// Sort strategies set in QueryOptions.SortStrategy
+const (
+	// ORDER BY without COLLATE
+	SortStrategyDefault = "default"
+	// ORDER BY with the engine COLLATE clause, ex: utf8mb4_unicode_ci in MySQL
+	SortStrategyCollate = "collate"
+	// Rows sorted in Go with one locale collator, used in sqlite for small result sets
+	SortStrategyMemory = "memory"
+	// sqlite COLLATE NOCASE, only ASCII letters are case insensitive and accents are not ignored
+	SortStrategyNoCase = "nocase"
+)
+
+// sortCollation returns the COLLATE name for the db engine, empty if the engine has no equivalent collation.
+// MySQL collations are configurable with DB_COLLATION_CI and DB_COLLATION_CIAI
+func sortCollation(app App, db *gorm.DB, c SortCollation) string {
+	cfg := app.GetConfiguration()
+
+	switch db.Dialector.Name() {
+	case "mysql":
+		if c == CollationCaseInsensitive {
+			return cfg.GetF("DB_COLLATION_CI", "utf8mb4_0900_as_ci")
+		}
+		return cfg.GetF("DB_COLLATION_CIAI", "utf8mb4_unicode_ci")
+	case "sqlite":
+		return "NOCASE"
+	}
+
+	return ""
+}
+
+func (o *QueryOptions) hasCollations() bool {
+	for _, s := range o.Sort {
+		if s.Collation != CollationDefault {
+			return true
+		}
+	}
+	return false
+}
+
+// orderByColumn returns the ORDER BY column with the COLLATE clause if the sort has one collation
+func (s *QuerySort) orderByColumn(app App, db *gorm.DB) clause.OrderByColumn {
+	column := clause.OrderByColumn{Column: clause.Column{Name: s.Column}, Desc: s.Desc}
+
+	if s.Collation == CollationDefault {
+		return column
+	}
+
+	name := sortCollation(app, db, s.Collation)
+	if name == "" {
+		return column
+	}
+
+	column.Column = clause.Column{Name: db.Statement.Quote(s.Column) + " COLLATE " + name, Raw: true}
+	return column
+}
+
+// Find loads the records in dest with the filters, sort, limit and offset, dest is one pointer to one slice of models.
+//
+// Sort fields with collation are sorted with the primary key as tie breaker, so the order is the same across engines.
+// MySQL sorts with the COLLATE clause in the database. sqlite without ICU only has the ASCII NOCASE collation,
+// so if the filtered count is up to QUERY_COLLATION_MEMORY_LIMIT (default 1000) all filtered rows are loaded
+// and sorted in Go with the QUERY_COLLATION_LOCALE (default "und") collator, bigger result sets use NOCASE.
+// The memory strategy runs one count query and loads all filtered rows, keep the limit small.
+// The strategy is set in o.SortStrategy and in the debug log
+func (o *QueryOptions) Find(db *gorm.DB, dest interface{}) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(dest); err != nil {
+		return errors.Wrap(err, "catu.QueryOptions.Find error on parse model")
+	}
+
+	query := o.ApplyFiltersToGorm(db.Model(dest))
+
+	o.SortStrategy = SortStrategyDefault
+
+	if o.hasCollations() {
+		o.SortStrategy = SortStrategyCollate
+
+		if db.Dialector.Name() == "sqlite" {
+			o.SortStrategy = SortStrategyNoCase
+
+			limit := o.getApp().GetConfiguration().GetInt64F("QUERY_COLLATION_MEMORY_LIMIT", 1000)
+
+			var count int64
+			if err := o.ApplyFiltersToGorm(db.Model(dest)).Count(&count).Error; err != nil {
+				return errors.Wrap(err, "catu.QueryOptions.Find error on count records")
+			}
+
+			if count <= limit {
+				o.SortStrategy = SortStrategyMemory
+			}
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"strategy": o.SortStrategy,
+		"engine":   db.Dialector.Name(),
+		"sort":     o.Sort,
+	}).Debug("catu.QueryOptions.Find sort strategy")
+
+	primaryKeys := []clause.OrderByColumn{}
+	if o.SortStrategy != SortStrategyDefault {
+		for _, f := range stmt.Schema.PrimaryFields {
+			primaryKeys = append(primaryKeys, clause.OrderByColumn{Column: clause.Column{Name: f.DBName}})
+		}
+	}
+
+	if o.SortStrategy == SortStrategyMemory {
+		for _, pk := range primaryKeys {
+			query = query.Order(pk)
+		}
+
+		if err := query.Find(dest).Error; err != nil {
+			return err
+		}
+
+		return o.sortInMemory(stmt.Schema, dest)
+	}
+
+	for i := range o.Sort {
+		query = query.Order(o.Sort[i].orderByColumn(o.getApp(), query))
+	}
+	for _, pk := range primaryKeys {
+		query = query.Order(pk)
+	}
+
+	return query.Limit(o.Limit).Offset(o.Offset).Find(dest).Error
+}
+
+// sortInMemory sorts the loaded rows, stable to keep the primary key order in ties, then applies offset and limit
+func (o *QueryOptions) sortInMemory(s *schema.Schema, dest interface{}) error {
+	rows := reflect.ValueOf(dest).Elem()
+
+	fields := make([]*schema.Field, len(o.Sort))
+	collators := make([]*collate.Collator, len(o.Sort))
+
+	locale := language.Make(o.getApp().GetConfiguration().GetF("QUERY_COLLATION_LOCALE", "und"))
+
+	for i, srt := range o.Sort {
+		fields[i] = s.LookUpField(srt.Column)
+		if fields[i] == nil {
+			return errors.New("catu.QueryOptions.Find sort column not found in model: " + srt.Column)
+		}
+
+		switch srt.Collation {
+		case CollationCaseInsensitive:
+			collators[i] = collate.New(locale, collate.IgnoreCase)
+		case CollationAccentInsensitive:
+			collators[i] = collate.New(locale, collate.IgnoreCase, collate.IgnoreDiacritics)
+		}
+	}
+
+	ctx := context.Background()
+
+	sort.SliceStable(rows.Interface(), func(a, b int) bool {
+		for i, srt := range o.Sort {
+			va := fields[i].ReflectValueOf(ctx, reflect.Indirect(rows.Index(a)))
+			vb := fields[i].ReflectValueOf(ctx, reflect.Indirect(rows.Index(b)))
+
+			var r int
+			if collators[i] != nil {
+				r = collators[i].CompareString(sortString(va), sortString(vb))
+			} else {
+				r = compareSortValues(va, vb)
+			}
+
+			if r == 0 {
+				continue
+			}
+			if srt.Desc {
+				return r > 0
+			}
+			return r < 0
+		}
+		return false
+	})
+
+	start := o.Offset
+	if start > rows.Len() {
+		start = rows.Len()
+	}
+	end := start + o.Limit
+	if o.Limit <= 0 || end > rows.Len() {
+		end = rows.Len()
+	}
+
+	page := reflect.MakeSlice(rows.Type(), end-start, end-start)
+	reflect.Copy(page, rows.Slice(start, end))
+	rows.Set(page)
+
+	return nil
+}
+
+// sortString returns the text value, nil values are empty
+func sortString(v reflect.Value) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	return v.String()
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// compareSortValues compares basic values like the sqlite default order, nil values first
+func compareSortValues(a, b reflect.Value) int {
+	if a.Kind() == reflect.Ptr || b.Kind() == reflect.Ptr {
+		switch {
+		case a.IsNil() && b.IsNil():
+			return 0
+		case a.IsNil():
+			return -1
+		case b.IsNil():
+			return 1
+		}
+		a, b = a.Elem(), b.Elem()
+	}
+
+	if a.Type() == timeType {
+		ta, tb := a.Interface().(time.Time), b.Interface().(time.Time)
+		switch {
+		case ta.Before(tb):
+			return -1
+		case ta.After(tb):
+			return 1
+		}
+		return 0
+	}
+
+	switch a.Kind() {
+	case reflect.String:
+		return strings.Compare(a.String(), b.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(a.Int() < b.Int(), a.Int() > b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return compareOrdered(a.Uint() < b.Uint(), a.Uint() > b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return compareOrdered(a.Float() < b.Float(), a.Float() > b.Float())
+	case reflect.Bool:
+		return compareOrdered(!a.Bool() && b.Bool(), a.Bool() && !b.Bool())
+	}
+
+	return 0
+}
+
+func compareOrdered(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
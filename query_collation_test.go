@@ -0,0 +1,171 @@
+package catu
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type collationTestRecord struct {
+	ID    uint64
+	Title string
+	Views int64
+}
+
+var collationFixture = []string{"Zebra", "árvore", "Abacate", "Éden", "eden", "Ágata", "avião", "óculos", "Ovo", "Uva", "úmido", "eco"}
+
+var collationExpected = map[string][]string{
+	"title":  {"Abacate", "Ágata", "árvore", "avião", "eco", "Éden", "eden", "óculos", "Ovo", "úmido", "Uva", "Zebra"},
+	"-title": {"Zebra", "Uva", "úmido", "Ovo", "óculos", "Éden", "eden", "eco", "avião", "árvore", "Ágata", "Abacate"},
+}
+
+func collationTestConfig(collation SortCollation) *QueryConfig {
+	return &QueryConfig{
+		SortableFields: map[string]string{"title": "title", "views": "views"},
+		SortCollations: map[string]SortCollation{"title": collation},
+		MaxLimit:       100,
+	}
+}
+
+func parseCollationQuery(t *testing.T, app App, query string, cfg *QueryConfig) *QueryOptions {
+	req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+	opts, err := ParseQuery(app.GetRouter().NewContext(req, httptest.NewRecorder()), cfg)
+	assert.Nil(t, err)
+	return opts
+}
+
+func titles(records []collationTestRecord) []string {
+	list := []string{}
+	for _, r := range records {
+		list = append(list, r.Title)
+	}
+	return list
+}
+
+// assertCollationOrder checks the fixture order, shared by the sqlite and MySQL tests
+func assertCollationOrder(t *testing.T, app App, db *gorm.DB, strategy string) {
+	for sort, expected := range collationExpected {
+		opts := parseCollationQuery(t, app, "limit=100&sort="+sort, collationTestConfig(CollationAccentInsensitive))
+		records := []collationTestRecord{}
+		assert.Nil(t, opts.Find(db, &records))
+		assert.Equal(t, expected, titles(records), sort)
+		assert.Equal(t, strategy, opts.SortStrategy)
+	}
+
+	opts := parseCollationQuery(t, app, "limit=3&page=2&sort=title", collationTestConfig(CollationAccentInsensitive))
+	records := []collationTestRecord{}
+	assert.Nil(t, opts.Find(db, &records))
+	assert.Equal(t, []string{"avião", "eco", "Éden"}, titles(records))
+
+	opts = parseCollationQuery(t, app, "limit=100&sort=-views,title", collationTestConfig(CollationAccentInsensitive))
+	records = []collationTestRecord{}
+	assert.Nil(t, opts.Find(db, &records))
+	assert.Equal(t, []string{"Éden", "eden", "Abacate", "Ágata", "árvore", "avião", "eco", "óculos", "Ovo", "úmido", "Uva", "Zebra"}, titles(records))
+}
+
+func seedCollationFixture(t *testing.T, db *gorm.DB) {
+	assert.Nil(t, db.Migrator().DropTable(&collationTestRecord{}))
+	assert.Nil(t, db.AutoMigrate(&collationTestRecord{}))
+
+	for _, title := range collationFixture {
+		r := collationTestRecord{Title: title}
+		if title == "Éden" || title == "eden" {
+			r.Views = 10
+		}
+		assert.Nil(t, db.Create(&r).Error)
+	}
+}
+
+func TestQueryCollationSqlite(t *testing.T) {
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+	db := app.GetDB()
+	seedCollationFixture(t, db)
+
+	t.Run("Should sort accent and case insensitive in memory", func(t *testing.T) {
+		assertCollationOrder(t, app, db, SortStrategyMemory)
+	})
+
+	t.Run("Should sort case insensitive keeping accents as secondary difference", func(t *testing.T) {
+		opts := parseCollationQuery(t, app, "limit=100&sort=title", collationTestConfig(CollationCaseInsensitive))
+		records := []collationTestRecord{}
+		assert.Nil(t, opts.Find(db, &records))
+		assert.Equal(t, []string{"Abacate", "Ágata", "árvore", "avião", "eco", "eden", "Éden", "óculos", "Ovo", "úmido", "Uva", "Zebra"}, titles(records))
+	})
+
+	t.Run("Should use NOCASE above the memory limit", func(t *testing.T) {
+		t.Setenv("QUERY_COLLATION_MEMORY_LIMIT", "5")
+
+		opts := parseCollationQuery(t, app, "limit=100&sort=title", collationTestConfig(CollationAccentInsensitive))
+		records := []collationTestRecord{}
+		assert.Nil(t, opts.Find(db, &records))
+		assert.Equal(t, SortStrategyNoCase, opts.SortStrategy)
+		assert.Equal(t, []string{"Abacate", "avião", "eco", "eden", "Ovo", "Uva", "Zebra", "Ágata", "Éden", "árvore", "óculos", "úmido"}, titles(records))
+
+		stmt := opts.ApplyToGorm(db.Session(&gorm.Session{DryRun: true}).Model(&collationTestRecord{})).Find(&[]collationTestRecord{}).Statement
+		assert.Equal(t, "SELECT * FROM `collation_test_records` ORDER BY `title` COLLATE NOCASE LIMIT 100", stmt.SQL.String())
+	})
+
+	t.Run("Should use the request app configuration", func(t *testing.T) {
+		newGlobalTestApp(t, map[string]string{"QUERY_COLLATION_MEMORY_LIMIT": "5"})
+
+		req := httptest.NewRequest(http.MethodGet, "/?limit=100&sort=title", nil)
+		ctx := &RequestContext{EchoContext: app.GetRouter().NewContext(req, httptest.NewRecorder()), App: app}
+		opts, err := ParseQuery(ctx, collationTestConfig(CollationAccentInsensitive))
+		assert.Nil(t, err)
+		assert.Nil(t, opts.Find(db, &[]collationTestRecord{}))
+		assert.Equal(t, SortStrategyMemory, opts.SortStrategy)
+	})
+
+	t.Run("Should keep the default sort without collations", func(t *testing.T) {
+		opts := parseCollationQuery(t, app, "limit=3&sort=title", collationTestConfig(CollationDefault))
+		records := []collationTestRecord{}
+		assert.Nil(t, opts.Find(db, &records))
+		assert.Equal(t, SortStrategyDefault, opts.SortStrategy)
+		assert.Equal(t, []string{"Abacate", "Ovo", "Uva"}, titles(records))
+	})
+}
+
+func TestQueryCollationMySQL(t *testing.T) {
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+
+	t.Run("Should sort with the MySQL collations", func(t *testing.T) {
+		db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:1)/catu", SkipInitializeWithVersion: true}), &gorm.Config{
+			DryRun:               true,
+			DisableAutomaticPing: true,
+		})
+		assert.Nil(t, err)
+
+		opts := parseCollationQuery(t, app, "limit=10&sort=-title,views", collationTestConfig(CollationAccentInsensitive))
+		assert.Nil(t, opts.Find(db, &[]collationTestRecord{}))
+		assert.Equal(t, SortStrategyCollate, opts.SortStrategy)
+
+		query := opts.ApplyToGorm(db.Model(&collationTestRecord{})).Find(&[]collationTestRecord{}).Statement
+		assert.Equal(t, "SELECT * FROM `collation_test_records` ORDER BY `title` COLLATE utf8mb4_unicode_ci DESC,`views` LIMIT 10", query.SQL.String())
+
+		opts = parseCollationQuery(t, app, "sort=title", collationTestConfig(CollationCaseInsensitive))
+		query = opts.ApplyToGorm(db.Model(&collationTestRecord{})).Find(&[]collationTestRecord{}).Statement
+		assert.Contains(t, query.SQL.String(), "ORDER BY `title` COLLATE utf8mb4_0900_as_ci LIMIT")
+	})
+
+	// integration test with one MySQL database, ex: TEST_MYSQL_DB_URI="root:pass@tcp(127.0.0.1:3306)/catu_test"
+	t.Run("Should sort the fixture like sqlite", func(t *testing.T) {
+		uri := os.Getenv("TEST_MYSQL_DB_URI")
+		if uri == "" {
+			t.Skip("TEST_MYSQL_DB_URI is not set")
+		}
+
+		db, err := gorm.Open(mysql.Open(uri+"?charset=utf8mb4&parseTime=True&loc=Local"), &gorm.Config{})
+		assert.Nil(t, err)
+		seedCollationFixture(t, db)
+		defer db.Migrator().DropTable(&collationTestRecord{})
+
+		assertCollationOrder(t, app, db, SortStrategyCollate)
+	})
+}
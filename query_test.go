@@ -0,0 +1,184 @@
+package catu
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type queryTestRecord struct {
+	ID        uint64
+	Name      string
+	CreatedAt int64
+}
+
+func TestParseQuery(t *testing.T) {
+	t.Setenv("QUERY_MAX_LIMIT", "100")
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+
+	cfg := &QueryConfig{
+		SortableFields:   map[string]string{"createdAt": "created_at", "name": "name", "id": "id"},
+		FilterableFields: map[string]string{"name": "name", "id": "id"},
+		DefaultSort:      "-id",
+	}
+
+	parse := func(query string) (*QueryOptions, error) {
+		req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		return ParseQuery(app.GetRouter().NewContext(req, httptest.NewRecorder()), cfg)
+	}
+
+	t.Run("Should use the defaults", func(t *testing.T) {
+		opts, err := parse("")
+		assert.Nil(t, err)
+		assert.Equal(t, &QueryOptions{
+			Limit: 20, Offset: 0, Page: 1,
+			Sort: []QuerySort{{Field: "id", Column: "id", Desc: true}},
+			app:  app,
+		}, opts)
+	})
+
+	t.Run("Should parse pagination params", func(t *testing.T) {
+		for query, expected := range map[string][3]int{
+			"limit=10":           {10, 0, 1},
+			"limit=10&page=3":    {10, 20, 3},
+			"perPage=5&page=2":   {5, 5, 2},
+			"limit=10&offset=25": {10, 25, 3},
+			"limit=1000":         {100, 0, 1},
+			"limit=1000&page=2":  {100, 100, 2},
+		} {
+			opts, err := parse(query)
+			assert.Nil(t, err, query)
+			assert.Equal(t, expected, [3]int{opts.Limit, opts.Offset, opts.Page}, query)
+		}
+	})
+
+	t.Run("Should parse multi column sort and filters", func(t *testing.T) {
+		opts, err := parse("sort=-createdAt,%2Bname,%20id&name_like=an&id=2&unknown=1")
+		assert.Nil(t, err)
+		assert.Equal(t, []QuerySort{
+			{Field: "createdAt", Column: "created_at", Desc: true},
+			{Field: "name", Column: "name"},
+			{Field: "id", Column: "id"},
+		}, opts.Sort)
+		assert.Equal(t, []QueryFilter{
+			{Field: "id", Column: "id", Operator: "eq", Value: "2"},
+			{Field: "name", Column: "name", Operator: "like", Value: "an"},
+		}, opts.Filters)
+	})
+
+	t.Run("Should reject malformed values and negative pages", func(t *testing.T) {
+		for query, field := range map[string]string{
+			"limit=abc":   "limit",
+			"limit=0":     "limit",
+			"limit=-1":    "limit",
+			"page=-1":     "page",
+			"page=0":      "page",
+			"page=1.5":    "page",
+			"offset=-10":  "offset",
+			"perPage=1e3": "perPage",
+		} {
+			_, err := parse(query)
+			ve, ok := err.(validator.ValidationErrors)
+			assert.True(t, ok, query)
+			if ok {
+				assert.Equal(t, field, ve[0].Field(), query)
+			}
+		}
+	})
+
+	t.Run("Should reject unknown and injected sort fields", func(t *testing.T) {
+		for _, sort := range []string{
+			"password",
+			"id;drop table users",
+			"-id desc, (select 1)",
+			"name`",
+			"created_at",
+		} {
+			_, err := parse("sort=" + url.QueryEscape(sort))
+			ve, ok := err.(validator.ValidationErrors)
+			assert.True(t, ok, sort)
+			if ok {
+				assert.Equal(t, "sort", ve[0].Field(), sort)
+				assert.Equal(t, "oneof", ve[0].Tag(), sort)
+			}
+		}
+	})
+
+	t.Run("Should respond invalid params with 400", func(t *testing.T) {
+		app.GetRouter().GET("/query-test", func(c echo.Context) error {
+			_, err := ParseQuery(c, cfg)
+			if err != nil {
+				return err
+			}
+			return c.String(http.StatusOK, "ok")
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/query-test?sort=id%3Bdrop&page=-1", nil)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderXRequestID, "query-test")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"code":400,"message":"Bad Request","errors":[
+			{"field":"page","tag":"min","value":"-1","param":"1","message":"page must be 1 or greater"},
+			{"field":"sort","tag":"oneof","value":"id;drop","param":"createdAt id name","message":"sort must be one of [createdAt id name]"}
+		], "requestId": "query-test"}`, rec.Body.String())
+	})
+
+	t.Run("Should apply the options to gorm", func(t *testing.T) {
+		db := app.GetDB().Session(&gorm.Session{DryRun: true})
+
+		opts, err := parse("sort=-createdAt,name&name_like=50%25_a&id=2&page=2&limit=10")
+		assert.Nil(t, err)
+
+		stmt := opts.ApplyToGorm(db.Model(&queryTestRecord{})).Find(&[]queryTestRecord{}).Statement
+		assert.Equal(t, "SELECT * FROM `query_test_records` WHERE `id` = ? AND `name` LIKE ? ESCAPE '!' ORDER BY `created_at` DESC,`name` LIMIT 10 OFFSET 10", stmt.SQL.String())
+		assert.Equal(t, []interface{}{"2", `%50!%!_a%`}, stmt.Vars)
+
+		stmt = opts.ApplyFiltersToGorm(db.Model(&queryTestRecord{})).Count(&opts.Total).Statement
+		assert.Equal(t, "SELECT count(*) FROM `query_test_records` WHERE `id` = ? AND `name` LIKE ? ESCAPE '!'", stmt.SQL.String())
+	})
+
+	t.Run("Should match like filters literally", func(t *testing.T) {
+		db := app.GetDB()
+		assert.Nil(t, db.AutoMigrate(&queryTestRecord{}))
+		assert.Nil(t, db.Create(&[]queryTestRecord{{Name: "50% off"}, {Name: "500 off"}, {Name: "a_b"}, {Name: "acb"}}).Error)
+
+		for like, expected := range map[string][]string{
+			"50%25": {"50% off"},
+			"50":    {"50% off", "500 off"},
+			"a_b":   {"a_b"},
+			"!":     {},
+		} {
+			opts, err := parse("sort=id&name_like=" + like)
+			assert.Nil(t, err)
+
+			records := []queryTestRecord{}
+			assert.Nil(t, opts.ApplyToGorm(db.Model(&queryTestRecord{})).Find(&records).Error)
+
+			names := []string{}
+			for _, r := range records {
+				names = append(names, r.Name)
+			}
+			assert.Equal(t, expected, names, like)
+		}
+	})
+
+	t.Run("Should use the request app configuration", func(t *testing.T) {
+		newGlobalTestApp(t, map[string]string{"PAGER_LIMIT": "7"})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		ctx := &RequestContext{EchoContext: app.GetRouter().NewContext(req, httptest.NewRecorder()), App: app}
+		opts, err := ParseQuery(ctx, cfg)
+		assert.Nil(t, err)
+		assert.Equal(t, 20, opts.Limit)
+	})
+}
@@ -0,0 +1,260 @@
+package catu
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/sirupsen/logrus"
+)
+
+// RateLimitStore counts the hits of each rate limit key, ex: memory or redis
+type RateLimitStore interface {
+	// Get returns the key hits and the time until the key resets
+	Get(key string) (hits int64, resetIn time.Duration, err error)
+	// Incr adds one hit to the key, starting one new window with the ttl for missing keys.
+	// Returns the hits with the new one and the time until one new hit is accepted
+	Incr(key string, ttl time.Duration) (hits int64, resetIn time.Duration, err error)
+}
+
+type RateLimiterConfig struct {
+	Skipper middleware.Skipper
+	// Max requests per Window
+	Limit  int64
+	Window time.Duration
+	// Default: the App rate limit store, see App.SetRateLimitStore
+	Store RateLimitStore
+	// Default: the App rate limit key function, see App.SetRateLimitKeyFunc
+	KeyFunc func(c echo.Context) string
+}
+
+// RateLimiter limits the requests per key, the default key is the client IP.
+// Limited requests return one ThrottledError with the time until one new request is accepted.
+// Responses have the X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset headers
+func RateLimiter(app App, config RateLimiterConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			store := config.Store
+			if store == nil {
+				store = app.GetRateLimitStore()
+			}
+
+			keyFunc := config.KeyFunc
+			if keyFunc == nil {
+				keyFunc = app.GetRateLimitKeyFunc()
+			}
+
+			hits, resetIn, err := store.Incr(keyFunc(c), config.Window)
+			if err != nil {
+				// one store failure should not block the API
+				logrus.WithFields(logrus.Fields{
+					"error":     err.Error(),
+					"requestId": GetRequestID(c),
+				}).Error("catu.RateLimiter error on increment hits")
+				return next(c)
+			}
+
+			remaining := config.Limit - hits
+			if remaining < 0 {
+				remaining = 0
+			}
+
+			h := c.Response().Header()
+			h.Set("X-RateLimit-Limit", strconv.FormatInt(config.Limit, 10))
+			h.Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+			h.Set("X-RateLimit-Reset", strconv.FormatInt(durationSeconds(resetIn), 10))
+
+			if hits > config.Limit {
+				return NewThrottledError(http.StatusTooManyRequests, ThrottleSourceRateLimit, resetIn)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// RateLimitIPKey is the default rate limit key function. The X-Forwarded-For IPs are only used from the TRUSTED_PROXIES,
+// so clients can't get one new bucket with one new header
+func RateLimitIPKey(c echo.Context) string {
+	return "ip:" + ClientIP(c)
+}
+
+// RateLimitUserKey uses the authenticated user ID, anonymous requests use the client IP
+func RateLimitUserKey(c echo.Context) string {
+	if ctx, ok := c.(*RequestContext); ok && ctx.IsAuthenticated && ctx.AuthenticatedUser != nil {
+		return "user:" + ctx.AuthenticatedUser.GetID()
+	}
+	return RateLimitIPKey(c)
+}
+
+func (r *AppStruct) GetRateLimitStore() RateLimitStore {
+	return r.rateLimitStore
+}
+
+// SetRateLimitStore replaces the default memory store, ex: with one redis store shared by all instances
+func (r *AppStruct) SetRateLimitStore(store RateLimitStore) {
+	r.rateLimitStore = store
+}
+
+func (r *AppStruct) GetRateLimitKeyFunc() func(c echo.Context) string {
+	return r.rateLimitKeyFunc
+}
+
+// SetRateLimitKeyFunc replaces the rate limit key function, ex: RateLimitUserKey
+func (r *AppStruct) SetRateLimitKeyFunc(fn func(c echo.Context) string) {
+	r.rateLimitKeyFunc = fn
+}
+
+// bindRateLimiter limits the api group requests with RATE_LIMIT requests per RATE_LIMIT_WINDOW seconds (default 60).
+// RATE_LIMIT_KEY=user limits authenticated users by ID
+func bindRateLimiter(app App) {
+	cfg := app.GetConfiguration()
+
+	limit := cfg.GetInt64F("RATE_LIMIT", 0)
+	if limit <= 0 {
+		return
+	}
+
+	window := time.Duration(cfg.GetInt64F("RATE_LIMIT_WINDOW", 60)) * time.Second
+
+	if app.GetRateLimitStore() == nil {
+		app.SetRateLimitStore(NewMemoryRateLimitStore(limit))
+	}
+
+	if app.GetRateLimitKeyFunc() == nil {
+		if cfg.Get("RATE_LIMIT_KEY") == "user" {
+			app.SetRateLimitKeyFunc(RateLimitUserKey)
+		} else {
+			app.SetRateLimitKeyFunc(RateLimitIPKey)
+		}
+	}
+
+	// echo Group.Use overrides the group root route, so the middleware is global and skips other paths
+	prefix := cfg.GetF("RATE_LIMIT_PATH_PREFIX", "/api")
+
+	app.GetRouter().Use(RateLimiter(app, RateLimiterConfig{
+		Limit:  limit,
+		Window: window,
+		Skipper: func(c echo.Context) bool {
+			p := c.Request().URL.Path
+			return p != prefix && !strings.HasPrefix(p, prefix+"/")
+		},
+	}))
+}
+
+// durationSeconds rounds up, so clients don't retry before the reset
+func durationSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+type rateLimitBucket struct {
+	// used tokens
+	level     float64
+	updatedAt time.Time
+	ttl       time.Duration
+}
+
+// MemoryRateLimitStore is one in process token bucket store.
+// Each key has Limit tokens refilled in the ttl window, so requests are accepted in one steady rate without window bursts
+type MemoryRateLimitStore struct {
+	Limit int64
+	// Time source, replace it in tests
+	Clock func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*rateLimitBucket
+	incrs   int
+}
+
+func NewMemoryRateLimitStore(limit int64) *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		Limit:   limit,
+		Clock:   time.Now,
+		buckets: make(map[string]*rateLimitBucket),
+	}
+}
+
+func (s *MemoryRateLimitStore) Get(key string) (int64, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		return 0, 0, nil
+	}
+
+	s.refill(b)
+	return int64(math.Ceil(b.level)), s.timeUntil(b, 0), nil
+}
+
+// Incr takes one token, without tokens the hit is not counted and it returns Limit+1 with the time until the next token
+func (s *MemoryRateLimitStore) Incr(key string, ttl time.Duration) (int64, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.incrs++
+	if s.incrs%1000 == 0 {
+		s.sweep()
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &rateLimitBucket{updatedAt: s.Clock()}
+		s.buckets[key] = b
+	}
+	b.ttl = ttl
+	s.refill(b)
+
+	limit := float64(s.Limit)
+	if b.level+1 > limit {
+		return s.Limit + 1, s.timeUntil(b, limit-1), nil
+	}
+
+	b.level++
+	return int64(math.Ceil(b.level)), s.timeUntil(b, 0), nil
+}
+
+// refill returns the tokens of the elapsed time
+func (s *MemoryRateLimitStore) refill(b *rateLimitBucket) {
+	now := s.Clock()
+
+	if b.ttl > 0 && s.Limit > 0 {
+		b.level -= float64(now.Sub(b.updatedAt)) * float64(s.Limit) / float64(b.ttl)
+	}
+	if b.level < 0 {
+		b.level = 0
+	}
+
+	b.updatedAt = now
+}
+
+// timeUntil returns the time until the bucket level is down to level
+func (s *MemoryRateLimitStore) timeUntil(b *rateLimitBucket, level float64) time.Duration {
+	if b.level <= level || s.Limit <= 0 {
+		return 0
+	}
+	return time.Duration((b.level - level) * float64(b.ttl) / float64(s.Limit))
+}
+
+// sweep removes the full buckets
+func (s *MemoryRateLimitStore) sweep() {
+	for key, b := range s.buckets {
+		s.refill(b)
+		if b.level == 0 {
+			delete(s.buckets, key)
+		}
+	}
+}
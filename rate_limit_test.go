@@ -0,0 +1,194 @@
+package catu
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	t.Run("Should refill the tokens in the window", func(t *testing.T) {
+		now := time.Now()
+		s := NewMemoryRateLimitStore(3)
+		s.Clock = func() time.Time { return now }
+
+		for i := int64(1); i <= 3; i++ {
+			hits, _, err := s.Incr("k", 3*time.Second)
+			assert.Nil(t, err)
+			assert.Equal(t, i, hits)
+		}
+
+		hits, resetIn, _ := s.Incr("k", 3*time.Second)
+		assert.Equal(t, int64(4), hits)
+		assert.Equal(t, time.Second, resetIn)
+
+		// rejected hits don't take tokens
+		hits, _, _ = s.Get("k")
+		assert.Equal(t, int64(3), hits)
+
+		now = now.Add(time.Second)
+		hits, _, _ = s.Incr("k", 3*time.Second)
+		assert.Equal(t, int64(3), hits)
+
+		now = now.Add(3 * time.Second)
+		hits, resetIn, _ = s.Get("k")
+		assert.Equal(t, int64(0), hits)
+		assert.Equal(t, time.Duration(0), resetIn)
+
+		hits, _, _ = s.Get("other")
+		assert.Equal(t, int64(0), hits)
+	})
+
+	t.Run("Should count concurrent hits once", func(t *testing.T) {
+		now := time.Now()
+		s := NewMemoryRateLimitStore(500)
+		s.Clock = func() time.Time { return now }
+
+		var accepted int64
+		wg := sync.WaitGroup{}
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					hits, _, err := s.Incr("k", time.Minute)
+					assert.Nil(t, err)
+					if hits <= 500 {
+						atomic.AddInt64(&accepted, 1)
+					}
+					s.Get("k")
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int64(500), accepted)
+		hits, _, _ := s.Get("k")
+		assert.Equal(t, int64(500), hits)
+	})
+
+	t.Run("Should remove full buckets", func(t *testing.T) {
+		now := time.Now()
+		s := NewMemoryRateLimitStore(1)
+		s.Clock = func() time.Time { return now }
+
+		s.Incr("old", time.Second)
+		now = now.Add(2 * time.Second)
+		for i := 0; i < 999; i++ {
+			s.Incr("new", time.Second)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		assert.Nil(t, s.buckets["old"])
+		assert.NotNil(t, s.buckets["new"])
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	newRateLimitApp := func(t *testing.T, key string) App {
+		t.Setenv("RATE_LIMIT", "2")
+		t.Setenv("RATE_LIMIT_WINDOW", "60")
+		t.Setenv("RATE_LIMIT_KEY", key)
+		t.Setenv("THROTTLE_JITTER_PERCENT", "0")
+
+		app := newTestApp(t)
+		// authenticates the X-Test-User header
+		app.GetEvents().On("bindMiddlewares", event.ListenerFunc(func(e event.Event) error {
+			app.GetRouter().Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					if id := c.Request().Header.Get("X-Test-User"); id != "" {
+						c.(*RequestContext).SetAuthenticatedUser(&testUser{ID: id})
+					}
+					return next(c)
+				}
+			})
+			return nil
+		}), event.Low)
+
+		assert.Nil(t, app.Bootstrap())
+		return app
+	}
+
+	request := func(app App, path, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderXRequestID, "rate-limit-test")
+		if userID != "" {
+			req.Header.Set("X-Test-User", userID)
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Should respond 429 with Retry-After by client IP", func(t *testing.T) {
+		app := newRateLimitApp(t, "")
+
+		rec := request(app, "/api", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "2", rec.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Remaining"))
+
+		assert.Equal(t, http.StatusOK, request(app, "/api", "1").Code)
+
+		rec = request(app, "/api", "2")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "30", rec.Header().Get(echo.HeaderRetryAfter))
+		assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+		assert.JSONEq(t, `{"code":429,"message":"Too Many Requests","requestId":"rate-limit-test","retry_after_seconds":30}`, rec.Body.String())
+
+		// other groups are not limited
+		assert.Equal(t, http.StatusOK, request(app, "/health", "").Code)
+		assert.Equal(t, "", request(app, "/health", "").Header().Get("X-RateLimit-Limit"))
+	})
+
+	t.Run("Should ignore the client X-Forwarded-For IPs", func(t *testing.T) {
+		app := newRateLimitApp(t, "")
+
+		codes := []int{}
+		for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+			req := httptest.NewRequest(http.MethodGet, "/api", nil)
+			req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+			req.Header.Set(echo.HeaderXForwardedFor, ip)
+			req.Header.Set(echo.HeaderXRealIP, ip)
+			rec := httptest.NewRecorder()
+			app.GetRouter().ServeHTTP(rec, req)
+			codes = append(codes, rec.Code)
+		}
+
+		assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+	})
+
+	t.Run("Should limit by the authenticated user", func(t *testing.T) {
+		app := newRateLimitApp(t, "user")
+
+		assert.Equal(t, http.StatusOK, request(app, "/api", "1").Code)
+		assert.Equal(t, http.StatusOK, request(app, "/api", "1").Code)
+		assert.Equal(t, http.StatusTooManyRequests, request(app, "/api", "1").Code)
+
+		assert.Equal(t, http.StatusOK, request(app, "/api", "2").Code)
+		assert.Equal(t, http.StatusOK, request(app, "/api", "").Code)
+	})
+
+	t.Run("Should use one custom store", func(t *testing.T) {
+		app := newTestApp(t)
+		store := NewMemoryRateLimitStore(1)
+		app.SetRateLimitStore(store)
+		t.Setenv("RATE_LIMIT", "1")
+		assert.Nil(t, app.Bootstrap())
+
+		assert.Equal(t, http.StatusOK, request(app, "/api", "").Code)
+		assert.Equal(t, http.StatusTooManyRequests, request(app, "/api", "").Code)
+
+		hits, _, _ := store.Get("ip:192.0.2.1")
+		assert.Equal(t, int64(1), hits)
+	})
+}
@@ -0,0 +1,72 @@
+package catu
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// EventPanic is triggered after one request handler panic, with the "error", the "stack", the "route" and the "requestId"
+const EventPanic = "error.panic"
+
+// recoverMiddleware converts the handler panics in 500 errors responded by the CustomHTTPErrorHandler.
+// The panic is logged with the stack and the EventPanic is triggered, ex: to send it to one error tracking service
+func recoverMiddleware(app App) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				// aborts the response without one error response, see http.ErrAbortHandler
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				panicErr, ok := rec.(error)
+				if !ok {
+					panicErr = fmt.Errorf("%v", rec)
+				}
+				stack := string(debug.Stack())
+
+				fields := logrus.Fields{
+					"error":     panicErr.Error(),
+					"stack":     stack,
+					"route":     c.Path(),
+					"method":    c.Request().Method,
+					"requestId": GetRequestID(c),
+				}
+				if userID, ok := c.Get(requestUserIDKey).(string); ok {
+					fields["userId"] = userID
+				}
+				logrus.WithFields(fields).Error("catu.recoverMiddleware handler panic")
+
+				fireErr, _ := app.GetEvents().Fire(EventPanic, event.M{
+					"app":       app,
+					"error":     panicErr,
+					"stack":     stack,
+					"route":     c.Path(),
+					"requestId": GetRequestID(c),
+				})
+				if fireErr != nil {
+					logrus.WithFields(logrus.Fields{
+						"error": fireErr.Error(),
+					}).Error("catu.recoverMiddleware error on panic event")
+				}
+
+				err = &HTTPError{
+					Code:     http.StatusInternalServerError,
+					Message:  http.StatusText(http.StatusInternalServerError),
+					Internal: panicErr,
+				}
+			}()
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package catu
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverMiddleware(t *testing.T) {
+	app := newTestApp(t)
+
+	var panicEvent event.M
+	app.GetEvents().On(EventPanic, event.ListenerFunc(func(e event.Event) error {
+		panicEvent = e.Data()
+		return nil
+	}))
+
+	app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+		app.GetRouter().GET("/panic", func(c echo.Context) error {
+			var items map[string]string
+			items["boom"] = "nil map"
+			return nil
+		})
+		return nil
+	}))
+
+	assert.Nil(t, app.Bootstrap())
+
+	t.Run("Should respond one 500 error and trigger the panic event", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+		req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+		resp := ErrorResponse{}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusInternalServerError, resp.Code)
+		assert.NotEmpty(t, resp.RequestID)
+		assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), resp.RequestID)
+
+		if assert.NotNil(t, panicEvent) {
+			assert.Contains(t, panicEvent["error"].(error).Error(), "nil map")
+			assert.Contains(t, panicEvent["stack"], "runtime/debug.Stack")
+			assert.Equal(t, "/panic", panicEvent["route"])
+			assert.Equal(t, resp.RequestID, panicEvent["requestId"])
+		}
+	})
+}
+
+func TestCustomHTTPErrorHandlerWithoutAppContext(t *testing.T) {
+	e := echo.New()
+
+	t.Run("Should respond plain JSON errors without the app", func(t *testing.T) {
+		current := appInstance
+		appInstance = nil
+		t.Cleanup(func() { appInstance = current })
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/articles", nil), rec)
+		CustomHTTPErrorHandler(&HTTPError{Code: http.StatusNotFound, Message: "article not found"}, c)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.JSONEq(t, `{"code":404,"message":"article not found"}`, rec.Body.String())
+
+		rec = httptest.NewRecorder()
+		c = e.NewContext(httptest.NewRequest(http.MethodGet, "/articles", nil), rec)
+		CustomHTTPErrorHandler(errors.New("database is down"), c)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.JSONEq(t, `{"code":500,"message":"Internal Server Error"}`, rec.Body.String())
+	})
+
+	t.Run("Should respond with one request context without the app", func(t *testing.T) {
+		newTestApp(t)
+
+		rec := httptest.NewRecorder()
+		c := &RequestContext{EchoContext: e.NewContext(httptest.NewRequest(http.MethodGet, "/articles", nil), rec)}
+		CustomHTTPErrorHandler(&HTTPError{Code: http.StatusForbidden, Message: "forbidden"}, c)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON)
+	})
+}
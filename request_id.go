@@ -0,0 +1,63 @@
+package catu
+
+import (
+	"github.com/go-catupiry/catu/http_client"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// echo context key with the request ID
+const requestIDKey = "requestID"
+
+// maximum size of one request ID received in the X-Request-ID header
+const requestIDMaxLength = 128
+
+// requestIDMiddleware reuses the X-Request-ID request header or generates one UUID,
+// then sets it in the response header and in the context, see GetRequestID.
+// Disable it with REQUEST_ID_DISABLE=true
+func requestIDMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			id := c.Request().Header.Get(echo.HeaderXRequestID)
+			if !isValidRequestID(id) {
+				id = uuid.New().String()
+			}
+
+			c.Set(requestIDKey, id)
+			// sent in the http_client requests with the request context
+			req := c.Request()
+			c.SetRequest(req.WithContext(http_client.WithRequestID(req.Context(), id)))
+			c.Response().Header().Set(echo.HeaderXRequestID, id)
+
+			return next(c)
+		}
+	}
+}
+
+// isValidRequestID accepts printable ASCII without spaces, to keep client values out of log and header injections
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > requestIDMaxLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// GetRequestID returns the current request ID or one empty string if the request ID middleware is disabled
+func GetRequestID(c echo.Context) string {
+	if ctx, ok := c.(*RequestContext); ok && ctx.RequestID != "" {
+		return ctx.RequestID
+	}
+
+	if id, ok := c.Get(requestIDKey).(string); ok {
+		return id
+	}
+
+	return ""
+}
@@ -0,0 +1,102 @@
+package catu
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+
+	app.GetRouter().GET("/request-id", func(c echo.Context) error {
+		return c.String(http.StatusOK, GetRequestID(c)+"|"+c.(*RequestContext).RequestID)
+	})
+	app.GetRouter().GET("/request-id/error", func(c echo.Context) error {
+		return &HTTPError{Code: http.StatusInternalServerError, Message: "Internal Server Error", Internal: errors.New("database down")}
+	})
+	app.GetRouter().GET("/request-id/unknown-error", func(c echo.Context) error {
+		return errors.New("database down")
+	})
+
+	request := func(path, requestID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if requestID != "" {
+			req.Header.Set(echo.HeaderXRequestID, requestID)
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Should propagate the request header", func(t *testing.T) {
+		rec := request("/request-id", "abc-123")
+		assert.Equal(t, "abc-123|abc-123", rec.Body.String())
+		assert.Equal(t, "abc-123", rec.Header().Get(echo.HeaderXRequestID))
+	})
+
+	t.Run("Should generate one UUID if the header is missing or invalid", func(t *testing.T) {
+		for _, header := range []string{"", "with space", "line\x01break", string(make([]byte, 129))} {
+			rec := request("/request-id", header)
+			id := rec.Header().Get(echo.HeaderXRequestID)
+			_, err := uuid.Parse(id)
+			assert.Nil(t, err, header)
+			assert.Equal(t, id+"|"+id, rec.Body.String())
+		}
+	})
+
+	t.Run("Should add the request ID in error responses and logs", func(t *testing.T) {
+		hook := test.NewGlobal()
+		defer hook.Reset()
+
+		rec := request("/request-id/error", "req-500")
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		body := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, "req-500", body["requestId"])
+
+		rec = request("/request-id/unknown-error", "req-unknown")
+		assert.JSONEq(t, `{"code":500,"message":"Unknown Error","requestId":"req-unknown"}`, rec.Body.String())
+
+		logged := map[string]interface{}{}
+		for _, e := range hook.AllEntries() {
+			if e.Level == logrus.WarnLevel {
+				logged[e.Message] = e.Data["requestId"]
+			}
+		}
+		assert.Equal(t, map[string]interface{}{
+			"internalServerErrorHandler error":                 "req-500",
+			"customHTTPErrorHandler unknown error status code": "req-unknown",
+		}, logged)
+
+		rec = request("/request-id/unknown", "req-404")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.JSONEq(t, `{"code":404,"message":"Not Found","requestId":"req-404"}`, rec.Body.String())
+	})
+
+	t.Run("Should be disabled with REQUEST_ID_DISABLE", func(t *testing.T) {
+		t.Setenv("REQUEST_ID_DISABLE", "true")
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		app.GetRouter().GET("/request-id", func(c echo.Context) error {
+			return c.String(http.StatusOK, GetRequestID(c))
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/request-id", nil)
+		req.Header.Set(echo.HeaderXRequestID, "abc")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.Equal(t, "", rec.Body.String())
+		assert.Equal(t, "", rec.Header().Get(echo.HeaderXRequestID))
+	})
+}
@@ -0,0 +1,175 @@
+package catu
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// echo context key with the authenticated user ID, kept after the RequestContext release for the request log
+const requestUserIDKey = "requestUserID"
+
+// LOG_REQUESTS modes
+const (
+	LogRequestsOff    = "off"
+	LogRequestsAll    = "all"
+	LogRequestsErrors = "errors-only"
+)
+
+// default LOG_REQUESTS_REDACT query parameters and headers, redacted if the name contains one item, case insensitive.
+// Ex: "token" redacts X-CSRF-Token and refresh_token
+const defaultRequestLogRedact = "authorization,cookie,password,token,secret,key"
+
+const redactedValue = "[REDACTED]"
+
+// requestLogConfig is the request log configuration
+type requestLogConfig struct {
+	errorsOnly bool
+	// chance to log one 2xx response, from 0 to 1
+	sampleRate float64
+	headers    bool
+	redact     []string
+}
+
+// bindRequestLog logs one entry per request with LOG_REQUESTS=all or only 4xx and 5xx responses with LOG_REQUESTS=errors-only.
+// LOG_REQUESTS_SAMPLE_RATE logs one part of the 2xx responses, ex: 0.1 logs 10%.
+// LOG_REQUESTS_HEADERS=true adds the request headers and the query parameters and headers with one LOG_REQUESTS_REDACT item
+// in the name are redacted
+func (r *AppStruct) bindRequestLog() {
+	mode := r.Configuration.GetF("LOG_REQUESTS", LogRequestsOff)
+
+	switch mode {
+	case LogRequestsOff, "":
+		return
+	case LogRequestsAll, LogRequestsErrors:
+	default:
+		logrus.WithFields(logrus.Fields{
+			"LOG_REQUESTS": mode,
+		}).Warn("catu.App.bindRequestLog invalid LOG_REQUESTS, use off, all or errors-only")
+		return
+	}
+
+	cfg := requestLogConfig{
+		errorsOnly: mode == LogRequestsErrors,
+		sampleRate: r.Configuration.GetFloat64F("LOG_REQUESTS_SAMPLE_RATE", 1),
+		headers:    r.Configuration.GetBool("LOG_REQUESTS_HEADERS"),
+	}
+
+	for _, name := range r.Configuration.GetStringSlice("LOG_REQUESTS_REDACT", ",", strings.Split(defaultRequestLogRedact, ",")) {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			cfg.redact = append(cfg.redact, name)
+		}
+	}
+
+	r.router.Use(requestLogMiddleware(r, cfg))
+}
+
+// requestLogMiddleware logs the requests with the global logrus formatter.
+// The status of error responses is the status responded by the error handler
+func requestLogMiddleware(app App, cfg requestLogConfig) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			latency := time.Since(start)
+
+			status := c.Response().Status
+			if err != nil && !c.Response().Committed {
+				status = metricsErrorStatus(app, err)
+			}
+
+			if cfg.errorsOnly && status < http.StatusBadRequest {
+				return err
+			}
+			if status >= 200 && status < 300 && cfg.sampleRate < 1 && rand.Float64() >= cfg.sampleRate {
+				return err
+			}
+
+			route := c.Path()
+			if isUnmatchedRoute(c) {
+				route = "unmatched"
+			}
+
+			req := c.Request()
+			fields := logrus.Fields{
+				"method":    req.Method,
+				"route":     route,
+				"path":      req.URL.Path,
+				"status":    status,
+				"latencyMs": float64(latency.Microseconds()) / 1000,
+				"bytes":     c.Response().Size,
+				"ip":        c.RealIP(),
+				"requestId": GetRequestID(c),
+			}
+
+			if userID, ok := c.Get(requestUserIDKey).(string); ok {
+				fields["userId"] = userID
+			}
+			if req.URL.RawQuery != "" {
+				fields["query"] = redactQuery(req.URL.Query(), cfg.redact)
+			}
+			if cfg.headers {
+				fields["headers"] = redactHeaders(req.Header, cfg.redact)
+			}
+			if err != nil {
+				fields["error"] = err.Error()
+			}
+
+			entry := logrus.WithFields(fields)
+			switch {
+			case status >= http.StatusInternalServerError:
+				entry.Error("catu.request")
+			case status >= http.StatusBadRequest:
+				entry.Warn("catu.request")
+			default:
+				entry.Info("catu.request")
+			}
+
+			return err
+		}
+	}
+}
+
+// isRedacted returns true if the name contains one redact item, ex: "X-Api-Key" contains "key"
+func isRedacted(name string, redact []string) bool {
+	name = strings.ToLower(name)
+	for _, item := range redact {
+		if strings.Contains(name, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactQuery encodes the query replacing the redacted parameter values
+func redactQuery(query url.Values, redact []string) string {
+	for name, values := range query {
+		if isRedacted(name, redact) {
+			for i := range values {
+				values[i] = redactedValue
+			}
+		}
+	}
+
+	return query.Encode()
+}
+
+// redactHeaders returns one copy of the headers with the redacted values replaced
+func redactHeaders(header http.Header, redact []string) map[string]string {
+	headers := make(map[string]string, len(header))
+
+	for name, values := range header {
+		if isRedacted(name, redact) {
+			headers[name] = redactedValue
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+
+	return headers
+}
@@ -0,0 +1,169 @@
+package catu
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestLog(t *testing.T) {
+	newRequestLogApp := func(t *testing.T, mode string) App {
+		t.Setenv("LOG_REQUESTS", mode)
+		t.Setenv("LOG_REQUESTS_HEADERS", "true")
+		app := newTestApp(t)
+
+		app.GetEvents().On("bindMiddlewares", event.ListenerFunc(func(e event.Event) error {
+			app.GetRouter().Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					if id := c.Request().Header.Get("X-Test-User"); id != "" {
+						c.(*RequestContext).SetAuthenticatedUser(&testUser{ID: id})
+					}
+					return next(c)
+				}
+			})
+			return nil
+		}), event.Low)
+
+		app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+			app.GetRouter().GET("/articles/:id", func(c echo.Context) error {
+				return c.String(http.StatusOK, "article")
+			})
+			app.GetRouter().GET("/broken", func(c echo.Context) error {
+				return errors.New("broken")
+			})
+			return nil
+		}))
+
+		assert.Nil(t, app.Bootstrap())
+		return app
+	}
+
+	request := func(app App, path string, header http.Header) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		req.Header.Set(echo.HeaderXRequestID, "req-1")
+		app.GetRouter().ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	newLogHook := func(t *testing.T) *test.Hook {
+		hooks := logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+		t.Cleanup(func() { logrus.StandardLogger().ReplaceHooks(hooks) })
+		return test.NewGlobal()
+	}
+
+	requestEntries := func(hook *test.Hook) []*logrus.Entry {
+		entries := []*logrus.Entry{}
+		for _, e := range hook.AllEntries() {
+			if e.Message == "catu.request" {
+				entries = append(entries, e)
+			}
+		}
+		return entries
+	}
+
+	t.Run("Should log one entry per request with the request fields", func(t *testing.T) {
+		app := newRequestLogApp(t, "all")
+		hook := newLogHook(t)
+
+		request(app, "/articles/10?page=2", http.Header{"X-Test-User": {"7"}})
+
+		entries := requestEntries(hook)
+		assert.Len(t, entries, 1)
+		e := entries[0]
+		assert.Equal(t, logrus.InfoLevel, e.Level)
+		assert.Equal(t, http.MethodGet, e.Data["method"])
+		assert.Equal(t, "/articles/:id", e.Data["route"])
+		assert.Equal(t, "/articles/10", e.Data["path"])
+		assert.Equal(t, http.StatusOK, e.Data["status"])
+		assert.Equal(t, int64(len("article")), e.Data["bytes"])
+		assert.Equal(t, "7", e.Data["userId"])
+		assert.Equal(t, "req-1", e.Data["requestId"])
+		assert.Equal(t, "page=2", e.Data["query"])
+		assert.NotEmpty(t, e.Data["ip"])
+		assert.Contains(t, e.Data, "latencyMs")
+	})
+
+	t.Run("Should redact the sensitive query parameters and headers", func(t *testing.T) {
+		app := newRequestLogApp(t, "all")
+		hook := newLogHook(t)
+
+		request(app, "/articles/10?token=abc&Password=123&q=go", http.Header{
+			"Authorization": {"Bearer abc"},
+			"Accept":        {"text/html"},
+		})
+
+		entries := requestEntries(hook)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "Password=%5BREDACTED%5D&q=go&token=%5BREDACTED%5D", entries[0].Data["query"])
+		headers := entries[0].Data["headers"].(map[string]string)
+		assert.Equal(t, "[REDACTED]", headers["Authorization"])
+		assert.Equal(t, "text/html", headers["Accept"])
+	})
+
+	t.Run("Should redact the names containing one redact item", func(t *testing.T) {
+		app := newRequestLogApp(t, "all")
+		hook := newLogHook(t)
+
+		request(app, "/articles/10?refresh_token=abc&client_secret=s3&page=2", http.Header{
+			"X-Csrf-Token": {"csrf"},
+			"X-Api-Key":    {"k3y"},
+			"Set-Cookie":   {"sid=1"},
+		})
+
+		entries := requestEntries(hook)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "client_secret=%5BREDACTED%5D&page=2&refresh_token=%5BREDACTED%5D", entries[0].Data["query"])
+		headers := entries[0].Data["headers"].(map[string]string)
+		for _, name := range []string{"X-Csrf-Token", "X-Api-Key", "Set-Cookie"} {
+			assert.Equal(t, "[REDACTED]", headers[name], name)
+		}
+	})
+
+	t.Run("Should log only the errors with errors-only", func(t *testing.T) {
+		app := newRequestLogApp(t, "errors-only")
+		hook := newLogHook(t)
+
+		request(app, "/articles/10", nil)
+		request(app, "/missing", nil)
+		request(app, "/broken", nil)
+
+		entries := requestEntries(hook)
+		assert.Len(t, entries, 2)
+		assert.Equal(t, http.StatusNotFound, entries[0].Data["status"])
+		assert.Equal(t, "unmatched", entries[0].Data["route"])
+		assert.Equal(t, logrus.WarnLevel, entries[0].Level)
+		assert.Equal(t, http.StatusInternalServerError, entries[1].Data["status"])
+		assert.Equal(t, "broken", entries[1].Data["error"])
+		assert.Equal(t, logrus.ErrorLevel, entries[1].Level)
+	})
+
+	t.Run("Should sample the 2xx responses", func(t *testing.T) {
+		t.Setenv("LOG_REQUESTS_SAMPLE_RATE", "0")
+		app := newRequestLogApp(t, "all")
+		hook := newLogHook(t)
+
+		request(app, "/articles/10", nil)
+		request(app, "/broken", nil)
+
+		entries := requestEntries(hook)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, http.StatusInternalServerError, entries[0].Data["status"])
+	})
+
+	t.Run("Should not log requests by default", func(t *testing.T) {
+		app := newRequestLogApp(t, "")
+		hook := newLogHook(t)
+
+		request(app, "/articles/10", nil)
+		assert.Empty(t, requestEntries(hook))
+	})
+}
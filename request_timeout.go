@@ -0,0 +1,66 @@
+package catu
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// bindRequestTimeout adds the REQUEST_TIMEOUT deadline to the request context, ex: "30s", default 0 without deadline.
+// The handlers should pass the context to the database, see DBWithContext, and to the HTTP clients.
+// Handler errors after the deadline respond 503 and after one client cancel respond 408
+func (r *AppStruct) bindRequestTimeout() {
+	timeout := r.Configuration.GetDuration("REQUEST_TIMEOUT", 0)
+
+	r.router.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx := req.Context()
+
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+				c.SetRequest(req.WithContext(ctx))
+			}
+
+			err := next(c)
+			if ctx.Err() == nil || (err == nil && c.Response().Committed) {
+				return err
+			}
+			// the handler client errors are kept, ex: one 404
+			if code := errorStatusCode(handlerApp(c), err); code >= 400 && code < 500 {
+				return err
+			}
+
+			// ex: one interrupted database query, the sqlite driver may stop the rows without error
+			internal := ctx.Err()
+			if err != nil && err != internal {
+				internal = errors.Wrap(err, internal.Error())
+			}
+
+			code := http.StatusServiceUnavailable
+			if ctx.Err() == context.Canceled {
+				code = http.StatusRequestTimeout
+			}
+
+			return &HTTPError{Code: code, Message: http.StatusText(code), Internal: internal}
+		}
+	})
+}
+
+// Context returns the request context, canceled when the client disconnects or after the REQUEST_TIMEOUT
+func (r *RequestContext) Context() context.Context {
+	return r.Request().Context()
+}
+
+// DBWithContext returns the request database, or the request transaction, with the request context.
+// The queries stop when the client disconnects or after the REQUEST_TIMEOUT, ex:
+//
+//	err := catu.DBWithContext(c).Find(&articles).Error
+func DBWithContext(c echo.Context) *gorm.DB {
+	return GetTX(c).WithContext(c.Request().Context())
+}
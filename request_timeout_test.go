@@ -0,0 +1,58 @@
+package catu
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// counts to one billion, it runs for many seconds without one context cancel
+const slowSQLiteQuery = "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 1000000000) SELECT count(*) FROM c"
+
+func TestRequestTimeout(t *testing.T) {
+	t.Setenv("REQUEST_TIMEOUT", "100ms")
+	app := newTestApp(t)
+
+	app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+		app.GetRouter().GET("/slow", func(c echo.Context) error {
+			var count int64
+			return DBWithContext(c).Raw(slowSQLiteQuery).Scan(&count).Error
+		})
+		return nil
+	}))
+
+	assert.Nil(t, app.Bootstrap())
+
+	t.Run("Should stop the query and respond 503 after the request deadline", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+		req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		start := time.Now()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.Less(t, int64(time.Since(start)), int64(2*time.Second))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+
+	t.Run("Should stop the query and respond 408 after one client cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest(http.MethodGet, "/slow", nil).WithContext(ctx)
+		req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		start := time.Now()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.Less(t, int64(time.Since(start)), int64(90*time.Millisecond))
+		assert.Equal(t, http.StatusRequestTimeout, rec.Code)
+	})
+}
@@ -0,0 +1,228 @@
+package catu
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-catupiry/catu/cache"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// HeaderCache is HIT for responses served from the response cache and MISS for cached responses
+const HeaderCache = "X-Cache"
+
+// CacheStore stores the cached responses. The keys start with the request path, see App.CacheInvalidate
+type CacheStore interface {
+	cache.Store
+	// Remove all keys starting with prefix
+	DeletePrefix(prefix string) error
+}
+
+type ResponseCacheConfig struct {
+	Skipper middleware.Skipper
+	// Default RESPONSE_CACHE_TTL seconds or 60
+	TTL time.Duration
+	// Response headers cached with the body, Content-Type is always cached
+	Headers []string
+	// Cache the authenticated requests by user, by default they are not cached
+	VaryByUser bool
+	// Default: the app response cache store, see App.SetResponseCacheStore
+	Store CacheStore
+}
+
+// cachedResponse is one response in the cache store
+type cachedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// ResponseCache caches the successful GET responses by path, sorted query string, Accept header, locale,
+// host and tenant, ex:
+//
+//	g := app.GetRouterGroup("public")
+//	g.Use(catu.ResponseCache(app, catu.ResponseCacheConfig{TTL: time.Hour}))
+//
+// Responses with cookies are not cached. Controllers call App.CacheInvalidate after changes
+func ResponseCache(app App, config ResponseCacheConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.TTL == 0 {
+		config.TTL = time.Duration(app.GetConfiguration().GetInt64F("RESPONSE_CACHE_TTL", 60)) * time.Second
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			return serveCached(app, c, next, &config)
+		}
+	}
+}
+
+func serveCached(app App, c echo.Context, next echo.HandlerFunc, config *ResponseCacheConfig) error {
+	req := c.Request()
+	if req.Method != http.MethodGet {
+		return next(c)
+	}
+
+	key, ok := responseCacheKey(c, config.VaryByUser)
+	if !ok {
+		return next(c)
+	}
+
+	store := config.Store
+	if store == nil {
+		store = app.GetResponseCacheStore()
+	}
+
+	if e, ok, err := store.Get(key); err == nil && ok && time.Now().Before(e.HardExpiresAt) {
+		if cached, ok := e.Value.(*cachedResponse); ok {
+			header := c.Response().Header()
+			for name, values := range cached.Header {
+				header[name] = append([]string(nil), values...)
+			}
+			header.Set(HeaderCache, "HIT")
+			return c.Blob(cached.Status, cached.Header.Get(echo.HeaderContentType), cached.Body)
+		}
+	}
+
+	res := c.Response()
+	res.Header().Set(HeaderCache, "MISS")
+
+	w := &cacheResponseWriter{ResponseWriter: res.Writer}
+	res.Writer = w
+	err := next(c)
+	res.Writer = w.ResponseWriter
+
+	if err != nil || res.Status != http.StatusOK || res.Header().Get(echo.HeaderSetCookie) != "" {
+		return err
+	}
+
+	cached := &cachedResponse{
+		Status: res.Status,
+		Header: http.Header{},
+		Body:   w.buf.Bytes(),
+	}
+	for _, name := range append([]string{echo.HeaderContentType}, config.Headers...) {
+		if v := res.Header().Values(name); len(v) > 0 {
+			cached.Header[http.CanonicalHeaderKey(name)] = v
+		}
+	}
+
+	now := time.Now()
+	entry := &cache.Entry{Value: cached, SoftExpiresAt: now.Add(config.TTL), HardExpiresAt: now.Add(config.TTL)}
+	if err := store.Set(key, entry, config.TTL); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"key":   key,
+			"error": err.Error(),
+		}).Warn("catu.ResponseCache error on set response")
+	}
+
+	return nil
+}
+
+// responseCacheKey returns false for requests that can't be cached
+func responseCacheKey(c echo.Context, varyByUser bool) (string, bool) {
+	var user, locale string
+
+	authenticated := c.Request().Header.Get(echo.HeaderAuthorization) != ""
+	if ctx, ok := c.(*RequestContext); ok {
+		locale = ctx.Locale
+		if ctx.IsAuthenticated {
+			authenticated = true
+			if ctx.AuthenticatedUser != nil {
+				user = ctx.AuthenticatedUser.GetID()
+			}
+		}
+	}
+
+	if authenticated && !varyByUser {
+		return "", false
+	}
+
+	var b strings.Builder
+	b.WriteString(c.Request().URL.Path)
+	b.WriteString("?")
+	// Encode sorts by key
+	b.WriteString(c.QueryParams().Encode())
+	b.WriteString("\x00")
+	b.WriteString(c.Request().Header.Get(echo.HeaderAccept))
+	b.WriteString("\x00")
+	b.WriteString(locale)
+	// the tenant resolvers may remove the tenant from the path, ex: PathTenantResolver
+	b.WriteString("\x00")
+	b.WriteString(strings.ToLower(c.Request().Host))
+	if tenant := GetTenant(c); tenant != nil {
+		b.WriteString("\x00")
+		b.WriteString(tenant.ID)
+	}
+	if varyByUser {
+		b.WriteString("\x00")
+		b.WriteString(user)
+	}
+
+	return b.String(), true
+}
+
+// cacheResponseWriter copies the response body
+type cacheResponseWriter struct {
+	http.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *cacheResponseWriter) Write(b []byte) (int, error) {
+	w.buf.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (r *AppStruct) GetResponseCacheStore() CacheStore {
+	return r.responseCacheStore
+}
+
+// SetResponseCacheStore replaces the default cache.LRUStore with RESPONSE_CACHE_MAX_ENTRIES, ex: with one shared store
+func (r *AppStruct) SetResponseCacheStore(store CacheStore) {
+	r.responseCacheStore = store
+}
+
+// CacheInvalidate removes the cached responses of the paths starting with prefix, ex: "/api/articles"
+func (r *AppStruct) CacheInvalidate(prefix string) error {
+	if err := r.responseCacheStore.DeletePrefix(prefix); err != nil {
+		return errors.Wrap(err, "catu.App.CacheInvalidate error on delete "+prefix)
+	}
+	return nil
+}
+
+// SetResourceCache caches the GET responses of one resource registered with SetResource for ttl, 0 disables the cache.
+// Successful Create, Update and Delete requests invalidate the resource responses
+func (r *AppStruct) SetResourceCache(name string, ttl time.Duration) error {
+	resource := r.GetResource(name)
+	if resource == nil {
+		return errors.New("catu.App.SetResourceCache resource not found: " + name)
+	}
+
+	resource.CacheTTL = ttl
+	return nil
+}
+
+// resourceCache middleware caches the responses of resources with CacheTTL
+func resourceCache(app App, name string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			resource := app.GetResource(name)
+			if resource == nil || resource.CacheTTL == 0 {
+				return next(c)
+			}
+
+			return serveCached(app, c, next, &ResponseCacheConfig{TTL: resource.CacheTTL})
+		}
+	}
+}
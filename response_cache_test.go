@@ -0,0 +1,239 @@
+package catu
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type cacheTestController struct {
+	idCodecTestController
+	calls int64
+}
+
+func (ctl *cacheTestController) Query(c echo.Context) error {
+	n := atomic.AddInt64(&ctl.calls, 1)
+	return c.String(http.StatusOK, "articles "+strconv.FormatInt(n, 10))
+}
+
+func TestResponseCache(t *testing.T) {
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+
+	var calls int64
+	g := app.GetRouter().Group("/public")
+	g.Use(ResponseCache(app, ResponseCacheConfig{TTL: time.Minute, Headers: []string{"X-Version"}}))
+	g.GET("/items", func(c echo.Context) error {
+		n := atomic.AddInt64(&calls, 1)
+		c.Response().Header().Set("X-Version", "v1")
+		c.Response().Header().Set("X-Other", "o")
+		return c.String(http.StatusOK, "items "+c.Request().Header.Get(echo.HeaderAccept)+" "+strconv.FormatInt(n, 10))
+	})
+	g.GET("/login", func(c echo.Context) error {
+		atomic.AddInt64(&calls, 1)
+		c.SetCookie(&http.Cookie{Name: "s", Value: "1"})
+		return c.String(http.StatusOK, "login")
+	})
+	g.GET("/broken", func(c echo.Context) error {
+		atomic.AddInt64(&calls, 1)
+		return &HTTPError{Code: http.StatusBadGateway, Message: "Bad Gateway"}
+	})
+
+	request := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Should serve the cached response without the handler", func(t *testing.T) {
+		rec := request("/public/items?b=2&a=1", nil)
+		assert.Equal(t, "items  1", rec.Body.String())
+		assert.Equal(t, "MISS", rec.Header().Get(HeaderCache))
+
+		rec = request("/public/items?a=1&b=2", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "items  1", rec.Body.String())
+		assert.Equal(t, "HIT", rec.Header().Get(HeaderCache))
+		assert.Equal(t, "v1", rec.Header().Get("X-Version"))
+		assert.Equal(t, "", rec.Header().Get("X-Other"))
+		assert.Equal(t, echo.MIMETextPlainCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, int64(1), atomic.LoadInt64(&calls))
+	})
+
+	t.Run("Should vary on the query string and Accept header", func(t *testing.T) {
+		assert.Equal(t, "items  2", request("/public/items?a=2", nil).Body.String())
+		assert.Equal(t, "items text/html 3", request("/public/items?a=1&b=2", map[string]string{echo.HeaderAccept: "text/html"}).Body.String())
+		assert.Equal(t, "items text/html 3", request("/public/items?a=1&b=2", map[string]string{echo.HeaderAccept: "text/html"}).Body.String())
+	})
+
+	t.Run("Should skip the authenticated requests", func(t *testing.T) {
+		auth := map[string]string{echo.HeaderAuthorization: "Bearer token"}
+		assert.Equal(t, "items  4", request("/public/items?a=1&b=2", auth).Body.String())
+		assert.Equal(t, "", request("/public/items?a=1&b=2", auth).Header().Get(HeaderCache))
+	})
+
+	t.Run("Should not cache errors and responses with cookies", func(t *testing.T) {
+		before := atomic.LoadInt64(&calls)
+		request("/public/login", nil)
+		request("/public/login", nil)
+		request("/public/broken", nil)
+		assert.Equal(t, http.StatusBadGateway, request("/public/broken", nil).Code)
+		assert.Equal(t, before+4, atomic.LoadInt64(&calls))
+	})
+
+	t.Run("Should invalidate the paths with one prefix", func(t *testing.T) {
+		assert.Nil(t, app.CacheInvalidate("/public/items"))
+		rec := request("/public/items?a=1&b=2", nil)
+		assert.Equal(t, "MISS", rec.Header().Get(HeaderCache))
+	})
+
+	t.Run("Should vary on the request locale", func(t *testing.T) {
+		before := atomic.LoadInt64(&calls)
+		pt := map[string]string{"Accept-Language": "pt-BR"}
+		assert.Equal(t, "MISS", request("/public/items?locale=1", pt).Header().Get(HeaderCache))
+		assert.Equal(t, "HIT", request("/public/items?locale=1", pt).Header().Get(HeaderCache))
+		assert.Equal(t, "MISS", request("/public/items?locale=1", map[string]string{"Accept-Language": "en"}).Header().Get(HeaderCache))
+		// one other pt-br header
+		assert.Equal(t, "HIT", request("/public/items?locale=1", map[string]string{"Accept-Language": "pt"}).Header().Get(HeaderCache))
+		assert.Equal(t, before+2, atomic.LoadInt64(&calls))
+	})
+
+	t.Run("Should vary on the authenticated user", func(t *testing.T) {
+		app := newTestApp(t)
+		// authenticates the X-Test-User header
+		app.GetEvents().On("bindMiddlewares", event.ListenerFunc(func(e event.Event) error {
+			app.GetRouter().Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					if id := c.Request().Header.Get("X-Test-User"); id != "" {
+						c.(*RequestContext).SetAuthenticatedUser(&testUser{ID: id})
+					}
+					return next(c)
+				}
+			})
+			return nil
+		}), event.Low)
+		assert.Nil(t, app.Bootstrap())
+
+		g := app.GetRouter().Group("/me", ResponseCache(app, ResponseCacheConfig{VaryByUser: true}))
+		g.GET("", func(c echo.Context) error {
+			return c.String(http.StatusOK, "user "+c.(*RequestContext).AuthenticatedUser.GetID()+" "+time.Now().String())
+		})
+
+		get := func(user string) string {
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			req.Header.Set("X-Test-User", user)
+			rec := httptest.NewRecorder()
+			app.GetRouter().ServeHTTP(rec, req)
+			return rec.Body.String()
+		}
+
+		one := get("1")
+		assert.Equal(t, one, get("1"))
+		assert.NotEqual(t, one, get("2"))
+	})
+}
+
+func TestResponseCacheTenants(t *testing.T) {
+	t.Setenv("TENANTS", "acme,globex")
+	app := newTestApp(t)
+	app.SetTenantResolver(&PathTenantResolver{})
+	assert.Nil(t, app.Bootstrap())
+
+	var calls int64
+	g := app.GetRouter().Group("/public", ResponseCache(app, ResponseCacheConfig{}))
+	g.GET("/items", func(c echo.Context) error {
+		n := atomic.AddInt64(&calls, 1)
+		return c.String(http.StatusOK, GetTenant(c).ID+" "+strconv.FormatInt(n, 10))
+	})
+
+	get := func(host, path string) string {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	t.Run("Should vary on the tenant and host", func(t *testing.T) {
+		assert.Equal(t, "acme 1", get("example.com", "/acme/public/items"))
+		assert.Equal(t, "globex 2", get("example.com", "/globex/public/items"))
+		assert.Equal(t, "acme 1", get("example.com", "/acme/public/items"))
+		assert.Equal(t, "acme 3", get("other.example.com", "/acme/public/items"))
+	})
+}
+
+func TestResourceCache(t *testing.T) {
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+
+	ctl := &cacheTestController{}
+	api := app.GetRouterGroup("api")
+	assert.Nil(t, app.SetResource("articles", ctl, api.Group("/articles")))
+	assert.NotNil(t, app.SetResourceCache("unknown", time.Minute))
+
+	request := func(method, path string) string {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	assert.Equal(t, "/api/articles", app.GetResource("articles").Path)
+	assert.Equal(t, "articles 1", request(http.MethodGet, "/api/articles"))
+	assert.Equal(t, "articles 2", request(http.MethodGet, "/api/articles"))
+
+	assert.Nil(t, app.SetResourceCache("articles", time.Minute))
+	assert.Equal(t, "articles 3", request(http.MethodGet, "/api/articles"))
+	assert.Equal(t, "articles 3", request(http.MethodGet, "/api/articles"))
+
+	t.Run("Should invalidate the resource responses after one change", func(t *testing.T) {
+		request(http.MethodPost, "/api/articles")
+		assert.Equal(t, "articles 4", request(http.MethodGet, "/api/articles"))
+		assert.Equal(t, "articles 4", request(http.MethodGet, "/api/articles"))
+	})
+}
+
+func BenchmarkResponseCache(b *testing.B) {
+	b.Setenv("TEMPLATE_DISABLE", "true")
+	b.Setenv("DB_URI", filepath.Join(b.TempDir(), "test.sqlite"))
+	app := Init(&AppOptions{})
+	if err := app.Bootstrap(); err != nil {
+		b.Fatal(err)
+	}
+
+	var calls int64
+	handler := func(c echo.Context) error {
+		atomic.AddInt64(&calls, 1)
+		// one database query
+		time.Sleep(time.Millisecond)
+		return c.String(http.StatusOK, "items")
+	}
+	app.GetRouter().GET("/cached", handler, ResponseCache(app, ResponseCacheConfig{TTL: time.Hour}))
+	app.GetRouter().GET("/uncached", handler)
+
+	for _, path := range []string{"/uncached", "/cached"} {
+		b.Run(path[1:], func(b *testing.B) {
+			atomic.StoreInt64(&calls, 0)
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				app.GetRouter().ServeHTTP(httptest.NewRecorder(), req)
+			}
+			b.ReportMetric(float64(atomic.LoadInt64(&calls))/float64(b.N), "handlerCalls/op")
+		})
+	}
+}
@@ -0,0 +1,417 @@
+package catu
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gookit/event"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// EventTaskFinished is triggered after each scheduled task run, with the task "name", the "duration" and the "error"
+const EventTaskFinished = "task.finished"
+
+// ScheduledTask is one App.Schedule task function, ctx is canceled after the task timeout and in the shutdown
+type ScheduledTask func(ctx context.Context) error
+
+// scheduledTask is one App.Schedule task
+type scheduledTask struct {
+	name     string
+	spec     string
+	schedule *cronSchedule
+	fn       ScheduledTask
+	// zero for the SCHEDULER_TIMEOUT
+	timeout time.Duration
+	next    time.Time
+	// 1 while the task runs, the next runs are skipped
+	running int32
+}
+
+// scheduler runs the App.Schedule tasks
+type scheduler struct {
+	mu    sync.Mutex
+	tasks map[string]*scheduledTask
+	// wakes up the loop after one new task
+	wake   chan struct{}
+	cancel context.CancelFunc
+	// the loop and the running tasks
+	wg  sync.WaitGroup
+	now func() time.Time
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		tasks: map[string]*scheduledTask{},
+		wake:  make(chan struct{}, 1),
+		now:   time.Now,
+	}
+}
+
+// Schedule runs fn in the cronSpec times, ex: app.Schedule("sitemap", "0 3 * * *", fn).
+// cronSpec is one standard 5-field cron spec (minute hour day-of-month month day-of-week) or one of
+// @yearly, @monthly, @weekly, @daily and @hourly. The tasks start after the Bootstrap, one task never runs twice at the same time
+func (r *AppStruct) Schedule(name, cronSpec string, fn ScheduledTask) error {
+	schedule, err := parseCronSpec(cronSpec)
+	if err != nil {
+		return errors.Wrap(err, "catu.App.Schedule invalid cron spec for "+name)
+	}
+
+	s := r.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := schedule.Next(s.now())
+	if next.IsZero() {
+		return errors.New("catu.App.Schedule cron spec never runs for " + name + ": " + cronSpec)
+	}
+	if s.tasks[name] != nil {
+		return errors.New("catu.App.Schedule task already scheduled: " + name)
+	}
+
+	s.tasks[name] = &scheduledTask{
+		name:     name,
+		spec:     cronSpec,
+		schedule: schedule,
+		fn:       fn,
+		next:     next,
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// SetScheduleTimeout sets the timeout of one scheduled task, default SCHEDULER_TIMEOUT seconds
+func (r *AppStruct) SetScheduleTimeout(name string, timeout time.Duration) error {
+	s := r.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task := s.tasks[name]
+	if task == nil {
+		return errors.New("catu.App.SetScheduleTimeout task not found: " + name)
+	}
+	task.timeout = timeout
+	return nil
+}
+
+// startScheduler starts the scheduled tasks until the shutdown, SCHEDULER_DISABLED=true disables it,
+// ex: in all replicas but one
+func (r *AppStruct) startScheduler() {
+	if r.Configuration.GetBool("SCHEDULER_DISABLED") {
+		logrus.Debug("catu.App.startScheduler scheduler disabled")
+		return
+	}
+
+	s := r.scheduler
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	r.Events.On("shutdown", event.ListenerFunc(func(e event.Event) error {
+		r.stopScheduler()
+		return nil
+	}), event.High)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		r.runScheduler(ctx)
+	}()
+}
+
+// stopScheduler cancels the running tasks and waits for them
+func (r *AppStruct) stopScheduler() {
+	s := r.scheduler
+	s.mu.Lock()
+	cancel := s.cancel
+	s.cancel = nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	s.wg.Wait()
+}
+
+func (r *AppStruct) runScheduler(ctx context.Context) {
+	s := r.scheduler
+
+	for {
+		now := s.now()
+		// checks the new tasks at least once a minute
+		next := now.Add(time.Minute)
+		s.mu.Lock()
+		for _, task := range s.tasks {
+			if task.next.Before(next) {
+				next = task.next
+			}
+		}
+		s.mu.Unlock()
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+
+		r.runDueTasks(ctx, s.now())
+	}
+}
+
+// runDueTasks starts the tasks with next run before now, skipping the running tasks
+func (r *AppStruct) runDueTasks(ctx context.Context, now time.Time) {
+	s := r.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defaultTimeout := time.Duration(r.Configuration.GetInt64F("SCHEDULER_TIMEOUT", 600)) * time.Second
+
+	for _, task := range s.tasks {
+		if task.next.After(now) {
+			continue
+		}
+		task.next = task.schedule.Next(now)
+
+		if !atomic.CompareAndSwapInt32(&task.running, 0, 1) {
+			logrus.WithFields(logrus.Fields{
+				"task": task.name,
+			}).Warn("catu.App.Schedule task still running, skipping this run")
+			continue
+		}
+
+		timeout := task.timeout
+		if timeout == 0 {
+			timeout = defaultTimeout
+		}
+
+		s.wg.Add(1)
+		go func(task *scheduledTask) {
+			defer s.wg.Done()
+			defer atomic.StoreInt32(&task.running, 0)
+			r.runScheduledTask(ctx, task, timeout)
+		}(task)
+	}
+}
+
+func (r *AppStruct) runScheduledTask(ctx context.Context, task *scheduledTask, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := runTaskFunc(ctx, task.fn)
+	duration := time.Since(start)
+
+	fields := logrus.Fields{
+		"task":     task.name,
+		"duration": duration.String(),
+	}
+	if err != nil {
+		fields["error"] = fmt.Sprintf("%+v\n", err)
+		logrus.WithFields(fields).Error("catu.App.Schedule task error")
+	} else {
+		logrus.WithFields(fields).Debug("catu.App.Schedule task finished")
+	}
+
+	if eventErr, _ := r.Events.Fire(EventTaskFinished, event.M{"app": r, "name": task.name, "duration": duration, "error": err}); eventErr != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": fmt.Sprintf("%+v\n", eventErr),
+		}).Error("catu.App.Schedule error on task finished event")
+	}
+}
+
+func runTaskFunc(ctx context.Context, fn ScheduledTask) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("task panic: %v", rec)
+		}
+	}()
+
+	return fn(ctx)
+}
+
+// cronSchedule is one parsed cron spec, with one bit for each allowed value
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// with both restricted, one day matches the day of month or the day of week
+	domStar, dowStar bool
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var cronDayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+func parseCronSpec(spec string) (*cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errors.New("expected 5 fields, got " + strconv.Itoa(len(fields)) + ": " + spec)
+	}
+
+	s := &cronSchedule{
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}
+
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, errors.Wrap(err, "minute")
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, errors.Wrap(err, "hour")
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, errors.Wrap(err, "day of month")
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, errors.Wrap(err, "month")
+	}
+	if s.dow, err = parseCronField(fields[4], 0, 7, cronDayNames); err != nil {
+		return nil, errors.Wrap(err, "day of week")
+	}
+	// 7 is sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	return s, nil
+}
+
+// parseCronField parses one comma separated list of "*", "n" or "n-m" with one optional "/step"
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rangePart = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, errors.New("invalid step: " + part)
+			}
+		}
+
+		start, end := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if start, err = parseCronValue(bounds[0], names); err != nil {
+				return 0, err
+			}
+			if end, err = parseCronValue(bounds[1], names); err != nil {
+				return 0, err
+			}
+		default:
+			v, err := parseCronValue(rangePart, names)
+			if err != nil {
+				return 0, err
+			}
+			start = v
+			// "n/step" is "n-max/step"
+			if step == 1 {
+				end = v
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return 0, errors.New("out of range: " + part)
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseCronValue(v string, names map[string]int) (int, error) {
+	if n, ok := names[strings.ToLower(v)]; ok {
+		return n, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, errors.New("invalid value: " + v)
+	}
+	return n, nil
+}
+
+// Next returns the first matching minute after t, zero if nothing matches in 5 years, ex: "0 0 30 2 *"
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,181 @@
+package catu
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gookit/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCronSpec(t *testing.T) {
+	base := time.Date(2022, time.March, 15, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2022, time.March, 15, 10, 8, 0, 0, time.UTC)},
+		{"*/5 * * * *", time.Date(2022, time.March, 15, 10, 10, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2022, time.March, 16, 3, 0, 0, 0, time.UTC)},
+		{"30 9-17 * * mon-fri", time.Date(2022, time.March, 15, 10, 30, 0, 0, time.UTC)},
+		{"0 0 1 jan,jul *", time.Date(2022, time.July, 1, 0, 0, 0, 0, time.UTC)},
+		// 2022-03-20 is one sunday
+		{"0 12 * * 7", time.Date(2022, time.March, 20, 12, 0, 0, 0, time.UTC)},
+		// day of month or day of week when both are restricted
+		{"0 0 1 * 5", time.Date(2022, time.March, 18, 0, 0, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2022, time.March, 15, 10, 25, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2022, time.March, 15, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2022, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		s, err := parseCronSpec(tt.spec)
+		assert.Nil(t, err, tt.spec)
+		assert.Equal(t, tt.next, s.Next(base), tt.spec)
+	}
+
+	t.Run("Should return error for invalid specs", func(t *testing.T) {
+		for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+			_, err := parseCronSpec(spec)
+			assert.NotNil(t, err, spec)
+		}
+	})
+}
+
+func TestSchedule(t *testing.T) {
+	now := time.Date(2022, time.March, 15, 10, 0, 30, 0, time.UTC)
+
+	newSchedulerApp := func(t *testing.T) *AppStruct {
+		t.Setenv("SCHEDULER_DISABLED", "true")
+		app := newTestApp(t).(*AppStruct)
+		app.scheduler.now = func() time.Time { return now }
+		return app
+	}
+
+	t.Run("Should run the due tasks", func(t *testing.T) {
+		app := newSchedulerApp(t)
+
+		var calls int32
+		assert.Nil(t, app.Schedule("sitemap", "*/5 * * * *", func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			return nil
+		}))
+		assert.NotNil(t, app.Schedule("sitemap", "* * * * *", nil))
+		assert.NotNil(t, app.Schedule("invalid", "* * *", nil))
+		assert.NotNil(t, app.Schedule("never", "0 0 31 2 *", nil))
+
+		finished := make(chan event.Event, 2)
+		app.GetEvents().On(EventTaskFinished, event.ListenerFunc(func(e event.Event) error {
+			finished <- e
+			return nil
+		}))
+
+		ctx := context.Background()
+		app.runDueTasks(ctx, now.Add(2*time.Minute))
+		app.scheduler.wg.Wait()
+		assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+
+		app.runDueTasks(ctx, now.Add(5*time.Minute))
+		app.scheduler.wg.Wait()
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+		e := <-finished
+		assert.Equal(t, "sitemap", e.Get("name"))
+		assert.Nil(t, e.Get("error"))
+		assert.Equal(t, time.Date(2022, time.March, 15, 10, 10, 0, 0, time.UTC), app.scheduler.tasks["sitemap"].next)
+	})
+
+	t.Run("Should skip the runs while the task is running", func(t *testing.T) {
+		app := newSchedulerApp(t)
+
+		var calls int32
+		release := make(chan struct{})
+		assert.Nil(t, app.Schedule("slow", "* * * * *", func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return nil
+		}))
+
+		ctx := context.Background()
+		app.runDueTasks(ctx, now.Add(time.Minute))
+		app.runDueTasks(ctx, now.Add(2*time.Minute))
+		close(release)
+		app.scheduler.wg.Wait()
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+		app.runDueTasks(ctx, now.Add(3*time.Minute))
+		app.scheduler.wg.Wait()
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("Should recover panics and cancel the tasks after the timeout", func(t *testing.T) {
+		app := newSchedulerApp(t)
+
+		assert.Nil(t, app.Schedule("panic", "* * * * *", func(ctx context.Context) error {
+			panic("broken task")
+		}))
+		assert.Nil(t, app.Schedule("timeout", "* * * * *", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}))
+		assert.Nil(t, app.SetScheduleTimeout("timeout", 10*time.Millisecond))
+		assert.NotNil(t, app.SetScheduleTimeout("missing", time.Second))
+
+		var mu sync.Mutex
+		errs := map[string]error{}
+		finished := make(chan struct{}, 2)
+		app.GetEvents().On(EventTaskFinished, event.ListenerFunc(func(e event.Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+			errs[e.Get("name").(string)] = e.Get("error").(error)
+			finished <- struct{}{}
+			return nil
+		}))
+
+		app.runDueTasks(context.Background(), now.Add(time.Minute))
+		app.scheduler.wg.Wait()
+
+		assert.Len(t, finished, 2)
+		assert.Contains(t, errs["panic"].Error(), "broken task")
+		assert.True(t, errors.Is(errs["timeout"], context.DeadlineExceeded))
+	})
+
+	t.Run("Should start after the Bootstrap and stop in the shutdown", func(t *testing.T) {
+		t.Setenv("SCHEDULER_TIMEOUT", "10")
+		app := newTestApp(t).(*AppStruct)
+
+		started := make(chan struct{})
+		var canceled int32
+		assert.Nil(t, app.Schedule("cleanup", "* * * * *", func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			atomic.StoreInt32(&canceled, 1)
+			return ctx.Err()
+		}))
+		// the next run is now
+		app.scheduler.tasks["cleanup"].next = time.Now()
+
+		assert.Nil(t, app.Bootstrap())
+
+		select {
+		case <-started:
+		case <-time.After(2 * time.Second):
+			t.Fatal("task not started")
+		}
+
+		app.shutdown()
+		assert.Equal(t, int32(1), atomic.LoadInt32(&canceled))
+	})
+
+	t.Run("Should not start with SCHEDULER_DISABLED", func(t *testing.T) {
+		app := newSchedulerApp(t)
+		assert.Nil(t, app.Bootstrap())
+		assert.Nil(t, app.scheduler.cancel)
+	})
+}
@@ -0,0 +1,277 @@
+package catu
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// SchemaDrift is the difference between one model schema and its database table
+type SchemaDrift struct {
+	Model string
+	Table string
+	// AutoMigrate call that fixes the drift, ex: db.AutoMigrate(&models.Article{})
+	AutoMigrate    string
+	MissingTable   bool
+	MissingColumns []*SchemaColumnDrift
+	TypeMismatches []*SchemaColumnDrift
+	// SQL statements to add in one migration, empty for missing tables
+	MigrationStub []string
+}
+
+type SchemaColumnDrift struct {
+	Field  string
+	Column string
+	// Expected database type, ex: "text"
+	ExpectedType string
+	// Database type, empty for missing columns
+	ActualType string
+}
+
+type SchemaDriftReport struct {
+	Drifts []*SchemaDrift
+}
+
+func (r *SchemaDriftReport) HasDrift() bool {
+	return len(r.Drifts) > 0
+}
+
+// String returns the readable report with the suggested fixes
+func (r *SchemaDriftReport) String() string {
+	if !r.HasDrift() {
+		return "database schema is up to date"
+	}
+
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "database schema drift detected in %d model(s):\n", len(r.Drifts))
+
+	for _, d := range r.Drifts {
+		fmt.Fprintf(&b, "\nmodel %s (table %s):\n", d.Model, d.Table)
+
+		if d.MissingTable {
+			b.WriteString("  - missing table\n")
+		}
+		for _, c := range d.MissingColumns {
+			fmt.Fprintf(&b, "  - missing column %s (field %s, type %s)\n", c.Column, c.Field, c.ExpectedType)
+		}
+		for _, c := range d.TypeMismatches {
+			fmt.Fprintf(&b, "  - column %s type mismatch: model field %s expects %s, database has %s\n", c.Column, c.Field, c.ExpectedType, c.ActualType)
+		}
+
+		fmt.Fprintf(&b, "  fix: run %s or add one migration", d.AutoMigrate)
+		if len(d.MigrationStub) > 0 {
+			b.WriteString(":\n")
+			for _, s := range d.MigrationStub {
+				fmt.Fprintf(&b, "    %s\n", s)
+			}
+		} else {
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
+
+// DetectSchemaDrift compares the models gorm schema with the database tables.
+// It checks missing tables, missing columns and column types with different families, ex: text in one integer field.
+// Columns are read with the gorm migrator, from sqlite_master in sqlite and from information_schema in MySQL
+func DetectSchemaDrift(db *gorm.DB, models map[string]interface{}) (*SchemaDriftReport, error) {
+	report := &SchemaDriftReport{}
+
+	names := make([]string, 0, len(models))
+	for name := range models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	checked := map[string]bool{}
+
+	for _, name := range names {
+		model := models[name]
+		if model == nil {
+			continue
+		}
+
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, errors.Wrap(err, "catu.DetectSchemaDrift error on parse model "+name)
+		}
+
+		if checked[stmt.Schema.Table] {
+			continue
+		}
+		checked[stmt.Schema.Table] = true
+
+		drift, err := detectModelDrift(db, model, stmt.Schema)
+		if err != nil {
+			return nil, errors.Wrap(err, "catu.DetectSchemaDrift error on check model "+name)
+		}
+
+		if drift != nil {
+			report.Drifts = append(report.Drifts, drift)
+		}
+	}
+
+	return report, nil
+}
+
+func detectModelDrift(db *gorm.DB, model interface{}, s *schema.Schema) (*SchemaDrift, error) {
+	migrator := db.Migrator()
+
+	drift := &SchemaDrift{
+		Model:       s.Name,
+		Table:       s.Table,
+		AutoMigrate: fmt.Sprintf("db.AutoMigrate(&%s{})", s.ModelType.String()),
+	}
+
+	if !migrator.HasTable(model) {
+		drift.MissingTable = true
+		return drift, nil
+	}
+
+	columnTypes, err := migrator.ColumnTypes(model)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]gorm.ColumnType{}
+	for _, c := range columnTypes {
+		columns[strings.ToLower(c.Name())] = c
+	}
+
+	quote := func(name string) string {
+		b := strings.Builder{}
+		db.Dialector.QuoteTo(&b, name)
+		return b.String()
+	}
+
+	for _, dbName := range s.DBNames {
+		field := s.FieldsByDBName[dbName]
+		if field.IgnoreMigration {
+			continue
+		}
+
+		expected := migrator.FullDataTypeOf(field).SQL
+		expectedType := strings.SplitN(strings.TrimSpace(expected), " ", 2)[0]
+
+		c, ok := columns[strings.ToLower(dbName)]
+		if !ok {
+			drift.MissingColumns = append(drift.MissingColumns, &SchemaColumnDrift{
+				Field:        field.Name,
+				Column:       dbName,
+				ExpectedType: expectedType,
+			})
+			drift.MigrationStub = append(drift.MigrationStub, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", quote(s.Table), quote(dbName), strings.TrimSpace(expected)))
+			continue
+		}
+
+		actualType := strings.ToLower(c.DatabaseTypeName())
+		if !compatibleColumnType(field, actualType) {
+			drift.TypeMismatches = append(drift.TypeMismatches, &SchemaColumnDrift{
+				Field:        field.Name,
+				Column:       dbName,
+				ExpectedType: expectedType,
+				ActualType:   actualType,
+			})
+
+			if db.Dialector.Name() == "mysql" {
+				drift.MigrationStub = append(drift.MigrationStub, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s;", quote(s.Table), quote(dbName), strings.TrimSpace(expected)))
+			}
+		}
+	}
+
+	if len(drift.MissingColumns) == 0 && len(drift.TypeMismatches) == 0 {
+		return nil, nil
+	}
+
+	return drift, nil
+}
+
+// column type families, types in the same family or in one compatible family are not reported
+var columnTypeFamilies = []struct {
+	family   string
+	keywords []string
+}{
+	{"bool", []string{"bool"}},
+	{"decimal", []string{"dec", "numeric"}},
+	{"integer", []string{"int", "bit"}},
+	{"float", []string{"real", "float", "double"}},
+	{"time", []string{"date", "time", "year"}},
+	{"bytes", []string{"blob", "binary"}},
+	{"string", []string{"char", "text", "clob", "enum", "set", "json"}},
+}
+
+var compatibleColumnFamilies = map[schema.DataType][]string{
+	schema.Bool:   {"bool", "integer", "decimal"},
+	schema.Int:    {"integer", "decimal"},
+	schema.Uint:   {"integer", "decimal"},
+	schema.Float:  {"float", "decimal"},
+	schema.String: {"string"},
+	schema.Time:   {"time"},
+	schema.Bytes:  {"bytes", "string"},
+}
+
+func columnTypeFamily(dbType string) string {
+	for _, f := range columnTypeFamilies {
+		for _, k := range f.keywords {
+			if strings.Contains(dbType, k) {
+				return f.family
+			}
+		}
+	}
+	return ""
+}
+
+// compatibleColumnType returns true for unknown types, custom field types are not checked
+func compatibleColumnType(field *schema.Field, dbType string) bool {
+	families, ok := compatibleColumnFamilies[field.DataType]
+	if !ok || field.GORMDataType != field.DataType {
+		return true
+	}
+
+	family := columnTypeFamily(dbType)
+	if family == "" {
+		return true
+	}
+
+	for _, f := range families {
+		if f == family {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkSchemaDrift runs the schema drift detection in development, STRICT_SCHEMA=true fails the bootstrap with drift
+func (r *AppStruct) checkSchemaDrift() error {
+	env := r.Configuration.GetF("GO_ENV", "development")
+	if env != "development" && env != "dev" {
+		return nil
+	}
+
+	if r.DB == nil || len(r.Models) == 0 || !r.Configuration.GetBoolF("SCHEMA_DRIFT_CHECK", true) {
+		return nil
+	}
+
+	report, err := DetectSchemaDrift(r.DB, r.Models)
+	if err != nil {
+		return err
+	}
+
+	if !report.HasDrift() {
+		return nil
+	}
+
+	if r.Configuration.GetBool("STRICT_SCHEMA") {
+		return errors.New(report.String())
+	}
+
+	logrus.Warn("catu.App.Bootstrap " + report.String())
+	return nil
+}
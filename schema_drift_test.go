@@ -0,0 +1,96 @@
+package catu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type schemaDriftArticle struct {
+	ID        uint64
+	Title     string
+	Views     int64
+	Published bool
+	CreatedAt time.Time
+}
+
+type schemaDriftAuthor struct {
+	ID   uint64
+	Name string
+}
+
+type schemaDriftTag struct {
+	ID   uint64
+	Name string
+}
+
+func TestDetectSchemaDrift(t *testing.T) {
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+	db := app.GetDB()
+
+	assert.Nil(t, db.AutoMigrate(&schemaDriftTag{}))
+	assert.Nil(t, db.Exec("CREATE TABLE schema_drift_articles (id integer PRIMARY KEY, title integer, published numeric, created_at datetime)").Error)
+
+	models := map[string]interface{}{
+		"articles": &schemaDriftArticle{},
+		"authors":  &schemaDriftAuthor{},
+		"tags":     &schemaDriftTag{},
+	}
+
+	t.Run("Should report missing tables, missing columns and type mismatches", func(t *testing.T) {
+		report, err := DetectSchemaDrift(db, models)
+		assert.Nil(t, err)
+		assert.True(t, report.HasDrift())
+		assert.Len(t, report.Drifts, 2)
+
+		articles := report.Drifts[0]
+		assert.Equal(t, "schema_drift_articles", articles.Table)
+		assert.False(t, articles.MissingTable)
+		assert.Equal(t, []*SchemaColumnDrift{{Field: "Views", Column: "views", ExpectedType: "integer"}}, articles.MissingColumns)
+		assert.Equal(t, []*SchemaColumnDrift{{Field: "Title", Column: "title", ExpectedType: "text", ActualType: "integer"}}, articles.TypeMismatches)
+		assert.Equal(t, []string{"ALTER TABLE `schema_drift_articles` ADD COLUMN `views` integer;"}, articles.MigrationStub)
+
+		authors := report.Drifts[1]
+		assert.Equal(t, "schema_drift_authors", authors.Table)
+		assert.True(t, authors.MissingTable)
+
+		text := report.String()
+		assert.Contains(t, text, "database schema drift detected in 2 model(s)")
+		assert.Contains(t, text, "model schemaDriftArticle (table schema_drift_articles):")
+		assert.Contains(t, text, "  - missing column views (field Views, type integer)")
+		assert.Contains(t, text, "  - column title type mismatch: model field Title expects text, database has integer")
+		assert.Contains(t, text, "fix: run db.AutoMigrate(&catu.schemaDriftArticle{}) or add one migration:")
+		assert.Contains(t, text, "    ALTER TABLE `schema_drift_articles` ADD COLUMN `views` integer;")
+		assert.Contains(t, text, "model schemaDriftAuthor (table schema_drift_authors):\n  - missing table")
+		assert.NotContains(t, text, "schema_drift_tags")
+	})
+
+	t.Run("Should fail the bootstrap check only with STRICT_SCHEMA", func(t *testing.T) {
+		for name, model := range models {
+			app.SetModel(name, model)
+		}
+		a := app.(*AppStruct)
+
+		assert.Nil(t, a.checkSchemaDrift())
+
+		t.Setenv("STRICT_SCHEMA", "true")
+		err := a.checkSchemaDrift()
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "missing table")
+
+		t.Setenv("GO_ENV", "production")
+		assert.Nil(t, a.checkSchemaDrift())
+	})
+
+	t.Run("Should return no drift after migrate", func(t *testing.T) {
+		assert.Nil(t, db.Migrator().DropTable("schema_drift_articles"))
+		assert.Nil(t, db.AutoMigrate(&schemaDriftArticle{}, &schemaDriftAuthor{}))
+
+		report, err := DetectSchemaDrift(db, models)
+		assert.Nil(t, err)
+		assert.False(t, report.HasDrift())
+		assert.Equal(t, "database schema is up to date", report.String())
+	})
+}
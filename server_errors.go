@@ -1,10 +1,16 @@
 package catu
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
 
+	"github.com/go-catupiry/catu/helpers"
+	"github.com/go-catupiry/catu/http_client"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/sirupsen/logrus"
@@ -24,6 +30,8 @@ type HTTPError struct {
 	Code     int         `json:"code"`
 	Message  interface{} `json:"message"`
 	Internal error       `json:"-"` // Stores the error returned by an external dependency
+	// Set in the error responses, see GetRequestID
+	RequestID string `json:"requestId,omitempty"`
 }
 
 // Error makes it compatible with `error` interface.
@@ -61,21 +69,67 @@ func (e *HTTPError) SetInternal(internal error) error {
 	return nil
 }
 
-type ValidationResponse struct {
-	Errors []*ValidationFieldError `json:"errors"`
+// ErrorResponse is the JSON body of all error responses
+type ErrorResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	// Extra information, ex: one HTTPError message that is not one string or the internal error with DEBUG=true
+	Details interface{} `json:"details,omitempty"`
+	// Validation errors
+	Errors    []*ValidationFieldError `json:"errors,omitempty"`
+	RequestID string                  `json:"requestId,omitempty"`
+	// Seconds to wait before one new request, same value of the Retry-After header, see ThrottledError
+	RetryAfterSeconds int64 `json:"retry_after_seconds,omitempty"`
 }
 
+// Deprecated: use ErrorResponse
+type ValidationResponse = ErrorResponse
+
 type ValidationFieldError struct {
-	Field   string `json:"field"`
-	Tag     string `json:"tag"`
+	// Field path with json names, ex: "address.street"
+	Field string `json:"field"`
+	Tag   string `json:"tag"`
+	// Rejected value, empty for sensitive fields.
+	// With VALIDATION_FIELD_NAMES=struct it is the validation tag param
 	Value   string `json:"value"`
+	Param   string `json:"param,omitempty"`
 	Message string `json:"message"`
 }
 
+// ValidationFieldErrors are validation errors without one validated struct, ex: one invalid upload.
+// They are responded with the validation errors shape and the VALIDATION_ERROR_STATUS status code
+type ValidationFieldErrors []*ValidationFieldError
+
+func (e ValidationFieldErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return "validation errors: " + strings.Join(msgs, ", ")
+}
+
 func CustomHTTPErrorHandler(err error, c echo.Context) {
-	logrus.WithFields(logrus.Fields{
-		"err": fmt.Sprintf("%+v\n", err),
-	}).Debug("catu.CustomHTTPErrorHandler running")
+	customHTTPErrorHandler(GetApp(), err, c)
+}
+
+// httpErrorHandler is the router error handler, the errors outside the app context middleware use this app
+func (r *AppStruct) httpErrorHandler(err error, c echo.Context) {
+	customHTTPErrorHandler(r, err, c)
+}
+
+func customHTTPErrorHandler(app App, err error, c echo.Context) {
+	// one error handler panic, ex: one template error, responds one plain JSON error
+	defer func() {
+		if rec := recover(); rec != nil {
+			logrus.WithFields(logrus.Fields{
+				"error":     fmt.Sprintf("%+v\n", err),
+				"panic":     fmt.Sprintf("%v", rec),
+				"stack":     string(debug.Stack()),
+				"requestId": GetRequestID(c),
+			}).Error("catu.CustomHTTPErrorHandler panic on handle error")
+			plainErrorResponse(err, c)
+		}
+	}()
 
 	var ctx *RequestContext
 
@@ -83,24 +137,59 @@ func CustomHTTPErrorHandler(err error, c echo.Context) {
 	case *RequestContext:
 		ctx = v
 	default:
-		ctx = NewRequestContext(&RequestContextOpts{EchoContext: c})
-	}
-
-	code := 0
-	if he, ok := err.(HTTPErrorInterface); ok {
-		code = he.GetCode()
-		if ctx.GetResponseContentType() == "application/json" {
-			c.JSON(code, he)
+		if app == nil {
+			plainErrorResponse(err, c)
 			return
 		}
+		ctx = &RequestContext{}
+		ctx.fill(app, c)
 	}
 
-	if he, ok := err.(*echo.HTTPError); ok {
-		code = he.Code
-		if ctx.GetResponseContentType() == "application/json" {
-			c.JSON(code, he)
+	// the app context middleware didn't run, ex: one error before the route middlewares
+	if ctx.App == nil {
+		if app == nil {
+			plainErrorResponse(err, c)
 			return
 		}
+		ctx = &RequestContext{}
+		ctx.fill(app, c)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"err":       fmt.Sprintf("%+v\n", err),
+		"requestId": GetRequestID(ctx),
+	}).Debug("catu.CustomHTTPErrorHandler running")
+
+	// one started response, ex: one event stream, can't have one error response
+	if c.Response().Committed {
+		logrus.WithFields(logrus.Fields{
+			"error":     fmt.Sprintf("%+v\n", err),
+			"path":      c.Path(),
+			"requestId": GetRequestID(ctx),
+		}).Warn("catu.CustomHTTPErrorHandler error after the response started")
+		return
+	}
+
+	// the conditional requests, see ETag
+	if code := errorStatusCode(handlerApp(c), err); code == http.StatusNotModified {
+		c.NoContent(http.StatusNotModified)
+		return
+	}
+
+	// body reads after the BODY_LIMIT return other errors, ex: the bind errors
+	if errors.Is(err, echo.ErrStatusRequestEntityTooLarge) {
+		err = echo.ErrStatusRequestEntityTooLarge
+	}
+
+	// the open circuit breaker of one http_client.Client, see App.RegisterHTTPService
+	var circuitErr *http_client.CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		err = NewThrottledError(http.StatusServiceUnavailable, ThrottleSourceCircuitOpen, circuitErr.RetryAfter)
+	}
+
+	if ctx.GetResponseContentType() == JSONAPIContentType {
+		jsonAPIErrorHandler(err, ctx)
+		return
 	}
 
 	if ve, ok := err.(validator.ValidationErrors); ok {
@@ -108,8 +197,21 @@ func CustomHTTPErrorHandler(err error, c echo.Context) {
 		return
 	}
 
-	if code == 0 && err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
-		code = 404
+	if fe, ok := err.(ValidationFieldErrors); ok {
+		validationFieldErrors(fe, ctx)
+		return
+	}
+
+	code := errorStatusCode(ctx.App, err)
+
+	if _, ok := err.(*ThrottledError); ok || code == http.StatusTooManyRequests {
+		throttledErrorHandler(err, ctx)
+		return
+	}
+
+	if code != 0 && ctx.GetResponseContentType() == "application/json" {
+		c.JSON(code, NewErrorResponse(ctx, code, err))
+		return
 	}
 
 	switch code {
@@ -119,8 +221,16 @@ func CustomHTTPErrorHandler(err error, c echo.Context) {
 		forbiddenErrorHandler(err, ctx)
 	case 404:
 		notFoundErrorHandler(err, ctx)
+	case 410:
+		goneErrorHandler(err, ctx)
+	case 412:
+		preconditionFailedErrorHandler(err, ctx)
+	case 413:
+		requestEntityTooLargeErrorHandler(err, ctx)
 	case 500:
 		internalServerErrorHandler(err, ctx)
+	case 503:
+		serviceUnavailableErrorHandler(err, ctx)
 	default:
 		logrus.WithFields(logrus.Fields{
 			"error":             fmt.Sprintf("%+v\n", err),
@@ -129,20 +239,44 @@ func CustomHTTPErrorHandler(err error, c echo.Context) {
 			"method":            c.Request().Method,
 			"AuthenticatedUser": ctx.AuthenticatedUser,
 			"roles":             ctx.GetAuthenticatedRoles(),
+			"requestId":         GetRequestID(ctx),
 		}).Warn("customHTTPErrorHandler unknown error status code")
-		c.JSON(http.StatusInternalServerError, &HTTPError{Code: 500, Message: "Unknown Error"})
+
+		if code >= 400 && code < 600 {
+			c.JSON(code, NewErrorResponse(ctx, code, err))
+			return
+		}
+
+		resp := NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		resp.Message = "Unknown Error"
+		c.JSON(http.StatusInternalServerError, resp)
 	}
 }
 
+// plainErrorResponse responds one JSON error without the app, the templates and the translations
+func plainErrorResponse(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	code := errorStatusCode(handlerApp(c), err)
+	if code < 400 || code >= 600 {
+		code = http.StatusInternalServerError
+	}
+
+	c.JSON(code, NewErrorResponse(c, code, err))
+}
+
 func forbiddenErrorHandler(err error, c echo.Context) error {
 	ctx := c.(*RequestContext)
 
 	logParams := logrus.Fields{
-		"err":    fmt.Sprintf("%+v\n", err),
-		"code":   "403",
-		"path":   c.Path(),
-		"method": c.Request().Method,
-		"roles":  ctx.GetAuthenticatedRoles(),
+		"err":       fmt.Sprintf("%+v\n", err),
+		"code":      "403",
+		"path":      c.Path(),
+		"method":    c.Request().Method,
+		"roles":     ctx.GetAuthenticatedRoles(),
+		"requestId": GetRequestID(ctx),
 	}
 
 	if ctx.IsAuthenticated {
@@ -155,17 +289,21 @@ func forbiddenErrorHandler(err error, c echo.Context) error {
 
 	switch ctx.GetResponseContentType() {
 	case "text/html":
-		ctx.Title = "Acesso restrito"
+		ctx.Title = ctx.T("errors.403")
 
 		if err := c.Render(http.StatusForbidden, "403", &TemplateCTX{
 			Ctx: ctx,
 		}); err != nil {
 			c.Logger().Error(err)
+			// without the site/403 template
+			if !c.Response().Committed {
+				c.HTML(http.StatusForbidden, http.StatusText(http.StatusForbidden))
+			}
 		}
 
 		return nil
 	default:
-		c.JSON(http.StatusForbidden, err)
+		c.JSON(http.StatusForbidden, NewErrorResponse(ctx, http.StatusForbidden, err))
 		return nil
 	}
 }
@@ -178,21 +316,26 @@ func unAuthorizedErrorHandler(err error, ctx *RequestContext) error {
 		"method":            ctx.Request().Method,
 		"AuthenticatedUser": ctx.AuthenticatedUser,
 		"roles":             ctx.GetAuthenticatedRoles(),
+		"requestId":         GetRequestID(ctx),
 	}).Info("catu.unAuthorizedErrorHandler running")
 
 	switch ctx.GetResponseContentType() {
 	case "text/html":
-		ctx.Title = "Forbidden"
+		ctx.Title = ctx.T("errors.401")
 
 		if err := ctx.Render(http.StatusUnauthorized, "401", &TemplateCTX{
 			Ctx: ctx,
 		}); err != nil {
 			ctx.Logger().Error(err)
+			// without the site/401 template
+			if !ctx.Response().Committed {
+				ctx.HTML(http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			}
 		}
 
 		return nil
 	default:
-		ctx.JSON(http.StatusUnauthorized, err)
+		ctx.JSON(http.StatusUnauthorized, NewErrorResponse(ctx, http.StatusUnauthorized, err))
 		return nil
 	}
 
@@ -200,59 +343,242 @@ func unAuthorizedErrorHandler(err error, ctx *RequestContext) error {
 
 func notFoundErrorHandler(err error, ctx *RequestContext) error {
 	logrus.WithFields(logrus.Fields{
-		"err":  fmt.Sprintf("%+v\n", err),
-		"code": "404",
+		"err":       fmt.Sprintf("%+v\n", err),
+		"code":      "404",
+		"requestId": GetRequestID(ctx),
 	}).Debug("catu.notFoundErrorHandler running")
 
 	switch ctx.GetResponseContentType() {
 	case "text/html":
-		ctx.Title = "Não encontrado"
+		ctx.Title = ctx.T("errors.404")
 
 		if err := ctx.Render(http.StatusNotFound, "404", &TemplateCTX{
 			Ctx: ctx,
 		}); err != nil {
 			ctx.Logger().Error(err)
+			// without the site/404 template
+			if !ctx.Response().Committed {
+				ctx.HTML(http.StatusNotFound, http.StatusText(http.StatusNotFound))
+			}
+		}
+		return nil
+	default:
+		ctx.JSON(http.StatusNotFound, NewErrorResponse(ctx, http.StatusNotFound, err))
+		return nil
+	}
+}
+
+func goneErrorHandler(err error, ctx *RequestContext) error {
+	logrus.WithFields(logrus.Fields{
+		"err":       fmt.Sprintf("%+v\n", err),
+		"code":      "410",
+		"requestId": GetRequestID(ctx),
+	}).Debug("catu.goneErrorHandler running")
+
+	switch ctx.GetResponseContentType() {
+	case "text/html":
+		ctx.Title = ctx.T("errors.410")
+
+		if err := ctx.Render(http.StatusGone, "410", &TemplateCTX{
+			Ctx: ctx,
+		}); err != nil {
+			ctx.Logger().Error(err)
+			return ctx.String(http.StatusGone, "Gone")
 		}
 		return nil
 	default:
-		ctx.JSON(http.StatusNotFound, &HTTPError{Code: http.StatusNotFound, Message: "Not Found"})
+		ctx.JSON(http.StatusGone, NewErrorResponse(ctx, http.StatusGone, err))
+		return nil
+	}
+}
+
+func preconditionFailedErrorHandler(err error, ctx *RequestContext) error {
+	logrus.WithFields(logrus.Fields{
+		"err":       fmt.Sprintf("%+v\n", err),
+		"code":      "412",
+		"requestId": GetRequestID(ctx),
+	}).Debug("catu.preconditionFailedErrorHandler running")
+
+	switch ctx.GetResponseContentType() {
+	case "text/html":
+		ctx.Title = ctx.T("errors.412")
+
+		if err := ctx.Render(http.StatusPreconditionFailed, "412", &TemplateCTX{
+			Ctx: ctx,
+		}); err != nil {
+			ctx.Logger().Error(err)
+			return ctx.String(http.StatusPreconditionFailed, "Precondition Failed")
+		}
+		return nil
+	default:
+		ctx.JSON(http.StatusPreconditionFailed, NewErrorResponse(ctx, http.StatusPreconditionFailed, err))
+		return nil
+	}
+}
+
+// serviceUnavailableErrorHandler responds the 503 errors without retry hint, ex: one request timeout.
+// The maintenance mode errors are ThrottledErrors, see throttledErrorHandler
+func serviceUnavailableErrorHandler(err error, ctx *RequestContext) error {
+	logrus.WithFields(logrus.Fields{
+		"err":       fmt.Sprintf("%+v\n", err),
+		"code":      "503",
+		"requestId": GetRequestID(ctx),
+	}).Debug("catu.serviceUnavailableErrorHandler running")
+
+	switch ctx.GetResponseContentType() {
+	case "text/html":
+		ctx.Title = ctx.T("errors.503")
+
+		if err := ctx.Render(http.StatusServiceUnavailable, "503", &TemplateCTX{
+			Ctx:     ctx,
+			Message: http.StatusText(http.StatusServiceUnavailable),
+		}); err != nil {
+			ctx.Logger().Error(err)
+			return ctx.String(http.StatusServiceUnavailable, "Service Unavailable")
+		}
+		return nil
+	default:
+		ctx.JSON(http.StatusServiceUnavailable, NewErrorResponse(ctx, http.StatusServiceUnavailable, err))
+		return nil
+	}
+}
+
+func requestEntityTooLargeErrorHandler(err error, ctx *RequestContext) error {
+	logrus.WithFields(logrus.Fields{
+		"err":           fmt.Sprintf("%+v\n", err),
+		"code":          "413",
+		"path":          ctx.Path(),
+		"contentLength": ctx.Request().ContentLength,
+		"requestId":     GetRequestID(ctx),
+	}).Info("catu.requestEntityTooLargeErrorHandler running")
+
+	switch ctx.GetResponseContentType() {
+	case "text/html":
+		ctx.Title = ctx.T("errors.413")
+
+		if err := ctx.Render(http.StatusRequestEntityTooLarge, "413", &TemplateCTX{
+			Ctx: ctx,
+		}); err != nil {
+			ctx.Logger().Error(err)
+			// without the site/413 template
+			if !ctx.Response().Committed {
+				ctx.HTML(http.StatusRequestEntityTooLarge, ctx.Title)
+			}
+		}
+		return nil
+	default:
+		ctx.JSON(http.StatusRequestEntityTooLarge, NewErrorResponse(ctx, http.StatusRequestEntityTooLarge, err))
 		return nil
 	}
 }
 
 func validationError(ve validator.ValidationErrors, err error, ctx *RequestContext) error {
 	logrus.WithFields(logrus.Fields{
-		"err":  fmt.Sprintf("%+v\n", err),
-		"code": "400",
+		"err":       fmt.Sprintf("%+v\n", err),
+		"code":      "400",
+		"requestId": GetRequestID(ctx),
 	}).Debug("catu.validationError running")
 
-	resp := ValidationResponse{}
+	code := validationStatusCode(ctx.App)
+
+	resp := acquireValidationResponse()
+	defer releaseValidationResponse(resp)
+	resp.Code = code
+	resp.Message = translatedStatusText(ctx, code, http.StatusText(code))
+	resp.RequestID = GetRequestID(ctx)
+
+	legacyFieldNames := ctx.App.GetConfiguration().Get("VALIDATION_FIELD_NAMES") == "struct"
+
+	for _, fe := range ve {
+		el := resp.nextFieldError()
+		el.Tag = fe.Tag()
+
+		if legacyFieldNames {
+			el.Message = fe.Error()
+			el.Field = fe.Field()
+			el.Value = fe.Param()
+			continue
+		}
+
+		el.Message = validationMessage(ctx, fe)
+		el.Field = validationFieldPath(fe)
+		el.Param = fe.Param()
 
-	if err != nil {
-		for _, err := range err.(validator.ValidationErrors) {
-			var el ValidationFieldError
-			el.Field = err.Field()
-			el.Tag = err.Tag()
-			el.Value = err.Param()
-			el.Message = err.Error()
-			resp.Errors = append(resp.Errors, &el)
+		if !helpers.IsSensitiveField(fe.Field()) && !helpers.IsSensitiveField(fe.StructField()) {
+			el.Value = fmt.Sprint(fe.Value())
+		}
+	}
+
+	if len(resp.Errors) == 0 {
+		resp.Errors = nil
+	}
+
+	switch ctx.GetResponseContentType() {
+	case "text/html":
+		ctx.Title = ctx.T("errors." + strconv.Itoa(code))
+
+		if err := ctx.Render(code, "400", &TemplateCTX{
+			Ctx: ctx,
+		}); err != nil {
+			ctx.Logger().Error(err)
+			// without the site/400 template
+			if !ctx.Response().Committed {
+				ctx.HTML(code, http.StatusText(code))
+			}
 		}
+
+		return nil
+	default:
+		return ctx.JSON(code, resp)
+	}
+}
+
+func validationFieldErrors(fe ValidationFieldErrors, ctx *RequestContext) error {
+	code := validationStatusCode(ctx.App)
+
+	resp := &ErrorResponse{
+		Code:      code,
+		Message:   translatedStatusText(ctx, code, http.StatusText(code)),
+		Errors:    fe,
+		RequestID: GetRequestID(ctx),
 	}
 
 	switch ctx.GetResponseContentType() {
 	case "text/html":
-		ctx.Title = "Bad request"
+		ctx.Title = ctx.T("errors." + strconv.Itoa(code))
 
-		if err := ctx.Render(http.StatusInternalServerError, "400", &TemplateCTX{
+		if err := ctx.Render(code, "400", &TemplateCTX{
 			Ctx: ctx,
 		}); err != nil {
 			ctx.Logger().Error(err)
+			// without the site/400 template
+			if !ctx.Response().Committed {
+				ctx.HTML(code, http.StatusText(code))
+			}
 		}
 
 		return nil
 	default:
-		return ctx.JSON(http.StatusBadRequest, resp)
+		return ctx.JSON(code, resp)
+	}
+}
+
+// validationMessage returns the field error message in the request locale, ex: "email must be a valid email address"
+func validationMessage(ctx *RequestContext, fe validator.FieldError) string {
+	cv, ok := ctx.App.GetRouter().Validator.(*helpers.CustomValidator)
+	if !ok {
+		return fe.Error()
 	}
+	return cv.Translate(fe, ctx.Locale)
+}
+
+// validationFieldPath returns the field namespace without the root struct name, ex: "address.street"
+func validationFieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return ns
 }
 
 func internalServerErrorHandler(err error, ctx *RequestContext) error {
@@ -268,25 +594,108 @@ func internalServerErrorHandler(err error, ctx *RequestContext) error {
 		"method":            ctx.Request().Method,
 		"AuthenticatedUser": ctx.AuthenticatedUser,
 		"roles":             ctx.GetAuthenticatedRoles(),
+		"requestId":         GetRequestID(ctx),
 	}).Warn("internalServerErrorHandler error")
 
 	switch ctx.GetResponseContentType() {
 	case "text/html":
-		ctx.Title = "Internal server error"
+		ctx.Title = ctx.T("errors.500")
 
 		if err := ctx.Render(http.StatusInternalServerError, "500", &TemplateCTX{
 			Ctx: ctx,
 		}); err != nil {
 			ctx.Logger().Error(err)
+			// without the site/500 template
+			if !ctx.Response().Committed {
+				ctx.HTML(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			}
 		}
 
 		return nil
 	default:
-		if he, ok := err.(*HTTPError); ok {
-			return ctx.JSON(he.Code, &HTTPError{Code: he.Code, Message: he.Message})
+		return ctx.JSON(code, NewErrorResponse(ctx, code, err))
+	}
+}
+
+// NewErrorResponse returns the error response body for err with the status code.
+// The message is the HTTPError or echo.HTTPError message, other errors and 5xx errors have the status text as message,
+// internal error messages are only responded in details with DEBUG=true
+func NewErrorResponse(c echo.Context, code int, err error) *ErrorResponse {
+	resp := &ErrorResponse{
+		Code:      code,
+		Message:   http.StatusText(code),
+		RequestID: GetRequestID(c),
+	}
+
+	if resp.Message == "" {
+		resp.Message = "Unknown Error"
+	}
+
+	// 5xx messages may have internal details
+	if code >= http.StatusInternalServerError {
+		if app := handlerApp(c); err != nil && app != nil && app.GetConfiguration().GetBool("DEBUG") {
+			resp.Details = err.Error()
 		}
+		resp.Message = translatedStatusText(c, code, resp.Message)
+		return resp
+	}
 
-		ctx.JSON(http.StatusInternalServerError, &HTTPError{Code: http.StatusInternalServerError, Message: "Internal Server Error"})
-		return nil
+	var message interface{}
+
+	switch e := err.(type) {
+	case HTTPErrorInterface:
+		message = e.GetMessage()
+	case *echo.HTTPError:
+		message = e.Message
+	}
+
+	switch m := message.(type) {
+	case nil:
+	case string:
+		if m != "" {
+			resp.Message = m
+		}
+	case error:
+		resp.Message = m.Error()
+	default:
+		resp.Details = m
+	}
+
+	resp.Message = translatedStatusText(c, code, resp.Message)
+
+	return resp
+}
+
+// errorStatusCode returns the HTTP status code of the error with the app configuration, 0 if unknown
+func errorStatusCode(app App, err error) int {
+	switch e := err.(type) {
+	case HTTPErrorInterface:
+		return e.GetCode()
+	case *echo.HTTPError:
+		return e.Code
+	case ValidationFieldErrors:
+		return validationStatusCode(app)
+	}
+
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusNotFound
+	}
+
+	// the request deadline or one client cancel
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusServiceUnavailable
+	}
+	if errors.Is(err, context.Canceled) {
+		return http.StatusRequestTimeout
+	}
+
+	return 0
+}
+
+// validationStatusCode is the status of validation errors, 400 or 422 with VALIDATION_ERROR_STATUS=422
+func validationStatusCode(app App) int {
+	if app != nil && app.GetConfiguration().GetInt("VALIDATION_ERROR_STATUS") == http.StatusUnprocessableEntity {
+		return http.StatusUnprocessableEntity
 	}
+	return http.StatusBadRequest
 }
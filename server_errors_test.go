@@ -0,0 +1,225 @@
+package catu
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type validationTestAddress struct {
+	Street string `json:"street" validate:"required"`
+}
+
+type validationTestBody struct {
+	UserEmail string                `json:"user_email" validate:"email"`
+	Password  string                `json:"password" validate:"min=8"`
+	Nickname  string                `form:"nick" validate:"max=3"`
+	Address   validationTestAddress `json:"address"`
+}
+
+func TestValidationError(t *testing.T) {
+	body := `{"user_email":"not-an-email","password":"123","Nickname":"abcdef","address":{"street":""}}`
+
+	request := func(t *testing.T, app App) string {
+		app.GetRouter().POST("/validate", func(c echo.Context) error {
+			b := validationTestBody{}
+			if err := c.Bind(&b); err != nil {
+				return err
+			}
+			return c.Validate(&b)
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/validate", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderXRequestID, "validation-test")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		return rec.Body.String()
+	}
+
+	t.Run("Should return json field names, paths and redacted values", func(t *testing.T) {
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+
+		assert.JSONEq(t, `{"code":400,"message":"Bad Request","errors":[
+			{"field":"user_email","tag":"email","value":"not-an-email","message":"user_email must be a valid email address"},
+			{"field":"password","tag":"min","value":"","param":"8","message":"password must be at least 8 characters in length"},
+			{"field":"nick","tag":"max","value":"abcdef","param":"3","message":"nick must be a maximum of 3 characters in length"},
+			{"field":"address.street","tag":"required","value":"","message":"street is a required field"}
+		], "requestId": "validation-test"}`, request(t, app))
+	})
+
+	t.Run("Should translate the messages with the request locale", func(t *testing.T) {
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		app.GetRouter().POST("/validate", func(c echo.Context) error {
+			return c.Validate(&validationTestBody{UserEmail: "invalid", Password: "12345678", Address: validationTestAddress{Street: "x"}})
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/validate", nil)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderXRequestID, "validation-test")
+		req.Header.Set("Accept-Language", "pt-BR")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.JSONEq(t, `{"code":400,"message":"Requisição inválida","errors":[
+			{"field":"user_email","tag":"email","value":"invalid","message":"user_email deve ser um endereço de e-mail válido"}
+		], "requestId": "validation-test"}`, rec.Body.String())
+	})
+
+	t.Run("Should use the custom validations, messages and slice paths", func(t *testing.T) {
+		type item struct {
+			SKU string `json:"sku" validate:"sku"`
+		}
+		type order struct {
+			Code  string `json:"code" validate:"uppercase_code"`
+			Items []item `json:"items" validate:"dive"`
+		}
+
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		isUpper := func(fl validator.FieldLevel) bool {
+			return strings.ToUpper(fl.Field().String()) == fl.Field().String()
+		}
+		assert.Nil(t, app.RegisterValidation("sku", isUpper))
+		assert.Nil(t, app.RegisterValidation("uppercase_code", isUpper))
+		assert.Nil(t, app.RegisterValidationMessage("sku", "{0} must be one upper case SKU"))
+
+		app.GetRouter().POST("/orders", func(c echo.Context) error {
+			o := order{}
+			if err := c.Bind(&o); err != nil {
+				return err
+			}
+			return c.Validate(&o)
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"code":"a1","items":[{"sku":"A1"},{"sku":"b2"}]}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderXRequestID, "validation-test")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		// tags without message use the generic message
+		assert.JSONEq(t, `{"code":400,"message":"Bad Request","errors":[
+			{"field":"code","tag":"uppercase_code","value":"a1","message":"code is invalid"},
+			{"field":"items[1].sku","tag":"sku","value":"b2","message":"sku must be one upper case SKU"}
+		], "requestId": "validation-test"}`, rec.Body.String())
+	})
+
+	t.Run("Should keep struct field names with VALIDATION_FIELD_NAMES=struct", func(t *testing.T) {
+		t.Setenv("VALIDATION_FIELD_NAMES", "struct")
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+
+		assert.JSONEq(t, `{"code":400,"message":"Bad Request","errors":[
+			{"field":"UserEmail","tag":"email","value":"","message":"Key: 'validationTestBody.UserEmail' Error:Field validation for 'UserEmail' failed on the 'email' tag"},
+			{"field":"Password","tag":"min","value":"8","message":"Key: 'validationTestBody.Password' Error:Field validation for 'Password' failed on the 'min' tag"},
+			{"field":"Nickname","tag":"max","value":"3","message":"Key: 'validationTestBody.Nickname' Error:Field validation for 'Nickname' failed on the 'max' tag"},
+			{"field":"Street","tag":"required","value":"","message":"Key: 'validationTestBody.Address.Street' Error:Field validation for 'Street' failed on the 'required' tag"}
+		], "requestId": "validation-test"}`, request(t, app))
+	})
+}
+
+func TestErrorResponse(t *testing.T) {
+	t.Setenv("VALIDATION_ERROR_STATUS", "422")
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+
+	router := app.GetRouter()
+	router.GET("/errors/401", func(c echo.Context) error {
+		return &HTTPError{Code: http.StatusUnauthorized, Message: "Token expired"}
+	})
+	router.GET("/errors/404", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusNotFound, map[string]string{"resource": "articles"})
+	})
+	router.GET("/errors/409", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusConflict, "Slug already used")
+	})
+	router.GET("/errors/422", func(c echo.Context) error {
+		return c.Validate(&validationTestBody{UserEmail: "invalid", Password: "12345678", Address: validationTestAddress{Street: "x"}})
+	})
+	router.GET("/errors/500", func(c echo.Context) error {
+		return errors.New("dial tcp 10.0.0.1:3306: connection refused")
+	})
+	router.GET("/errors/500-http", func(c echo.Context) error {
+		return &HTTPError{Code: http.StatusInternalServerError, Message: "query failed", Internal: errors.New("syntax error")}
+	})
+
+	request := func(path, contentType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderXRequestID, "error-test")
+		if contentType != "" {
+			req.Header.Set(echo.HeaderContentType, contentType)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	cases := []struct {
+		name  string
+		path  string
+		debug bool
+		code  int
+		body  string
+	}{
+		{"401 with the error message", "/errors/401", false, 401, `{"code":401,"message":"Token expired","requestId":"error-test"}`},
+		{"404 with one non string message in details", "/errors/404", false, 404, `{"code":404,"message":"Not Found","details":{"resource":"articles"},"requestId":"error-test"}`},
+		{"404 for unknown routes", "/errors/unknown", false, 404, `{"code":404,"message":"Not Found","requestId":"error-test"}`},
+		{"other 4xx status codes", "/errors/409", false, 409, `{"code":409,"message":"Slug already used","requestId":"error-test"}`},
+		{"422 for validation errors", "/errors/422", false, 422, `{"code":422,"message":"Unprocessable Entity","errors":[
+			{"field":"user_email","tag":"email","value":"invalid","message":"user_email must be a valid email address"}
+		],"requestId":"error-test"}`},
+		{"500 without internal messages", "/errors/500", false, 500, `{"code":500,"message":"Unknown Error","requestId":"error-test"}`},
+		{"500 without the HTTPError message", "/errors/500-http", false, 500, `{"code":500,"message":"Internal Server Error","requestId":"error-test"}`},
+		{"500 with details in DEBUG", "/errors/500-http", true, 500, `{"code":500,"message":"Internal Server Error","details":"code=500, message=query failed, internal=syntax error","requestId":"error-test"}`},
+	}
+
+	for _, tc := range cases {
+		for _, contentType := range []string{"", echo.MIMEApplicationJSON} {
+			t.Run("Should respond "+tc.name+" "+contentType, func(t *testing.T) {
+				if tc.debug {
+					t.Setenv("DEBUG", "true")
+				}
+
+				rec := request(tc.path, contentType)
+				assert.Equal(t, tc.code, rec.Code)
+				assert.JSONEq(t, tc.body, rec.Body.String())
+			})
+		}
+	}
+
+	t.Run("Should respond JSON:API error documents", func(t *testing.T) {
+		rec := request("/errors/422", JSONAPIContentType)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.JSONEq(t, `{"errors":[{
+			"status":"422","code":"email","title":"Invalid Attribute",
+			"detail":"user_email must be a valid email address",
+			"source":{"pointer":"/data/attributes/user_email"}
+		}],"meta":{"requestId":"error-test"}}`, rec.Body.String())
+
+		rec = request("/errors/500", JSONAPIContentType)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.JSONEq(t, `{"errors":[{"status":"500","title":"Internal Server Error"}],"meta":{"requestId":"error-test"}}`, rec.Body.String())
+	})
+
+	t.Run("Should use the request app configuration", func(t *testing.T) {
+		t.Setenv("DEBUG", "true")
+		newGlobalTestApp(t, map[string]string{"DEBUG": "false"})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		ctx := &RequestContext{EchoContext: router.NewContext(req, httptest.NewRecorder()), App: app}
+		resp := NewErrorResponse(ctx, http.StatusInternalServerError, errors.New("syntax error"))
+		assert.Equal(t, "syntax error", resp.Details)
+	})
+}
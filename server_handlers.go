@@ -1,11 +1,72 @@
 package catu
 
 import (
+	"io/fs"
+	"mime"
 	"net/http"
+	"path"
 
 	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
 )
 
+// HealthCheckHandler responds the health checks report with 200 or 503 if one check fails, see App.RegisterHealthCheck
 func HealthCheckHandler(c echo.Context) error {
-	return c.String(http.StatusOK, "ok")
+	report := handlerApp(c).RunHealthChecks(c.Request().Context())
+	if !report.OK() {
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// ReadyHandler responds 503 until the Bootstrap completes and after the shutdown starts, then the health checks report
+func ReadyHandler(c echo.Context) error {
+	app := handlerApp(c)
+	if !app.IsReady() {
+		return c.JSON(http.StatusServiceUnavailable, &HealthReport{
+			Status: HealthStatusFail,
+			Checks: map[string]*HealthCheckResult{
+				"bootstrap": {Status: HealthStatusFail, Error: "not ready"},
+			},
+		})
+	}
+
+	return HealthCheckHandler(c)
+}
+
+// UploadedFileHandler responds one file of the upload storage, see HandleUpload
+func UploadedFileHandler(c echo.Context) error {
+	name := c.Param("*")
+	if name == "" {
+		return &HTTPError{Code: http.StatusNotFound, Message: "Not Found"}
+	}
+
+	file, err := handlerApp(c).GetUploadStorage().Open(c.Request().Context(), name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &HTTPError{Code: http.StatusNotFound, Message: "Not Found"}
+		}
+		return err
+	}
+	defer file.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = echo.MIMEOctetStream
+	}
+
+	// uploaded HTML files can't run scripts in the app origin
+	c.Response().Header().Set("X-Content-Type-Options", "nosniff")
+	c.Response().Header().Set("Content-Security-Policy", "sandbox")
+	return c.Stream(http.StatusOK, contentType, file)
+}
+
+// handlerApp returns the request App, the RequestContext App is only set after the Bootstrap
+func handlerApp(c echo.Context) App {
+	if ctx, ok := c.(*RequestContext); ok && ctx.App != nil {
+		return ctx.App
+	}
+
+	return GetApp()
 }
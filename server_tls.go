@@ -0,0 +1,121 @@
+package catu
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// getTLSConfig returns the TLS configuration from TLS_AUTOCERT_DOMAINS or TLS_CERT_FILE and TLS_KEY_FILE.
+// Returns nil if TLS is not configured, the autocert manager is only returned with TLS_AUTOCERT_DOMAINS
+func (r *AppStruct) getTLSConfig() (*tls.Config, *autocert.Manager, error) {
+	cfg := r.Configuration
+
+	if domains := cfg.Get("TLS_AUTOCERT_DOMAINS"); domains != "" {
+		hosts := []string{}
+		for _, d := range strings.Split(domains, ",") {
+			if d = strings.TrimSpace(d); d != "" {
+				hosts = append(hosts, d)
+			}
+		}
+
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(hosts...),
+			Cache:      autocert.DirCache(cfg.GetF("TLS_AUTOCERT_CACHE_DIR", ".autocert")),
+			Email:      cfg.Get("TLS_AUTOCERT_EMAIL"),
+		}
+
+		return m.TLSConfig(), m, nil
+	}
+
+	certFile := cfg.Get("TLS_CERT_FILE")
+	keyFile := cfg.Get("TLS_KEY_FILE")
+
+	if certFile == "" && keyFile == "" {
+		return nil, nil, nil
+	}
+
+	if certFile == "" || keyFile == "" {
+		return nil, nil, errors.New("catu.App TLS_CERT_FILE and TLS_KEY_FILE should be set together")
+	}
+
+	for name, file := range map[string]string{"TLS_CERT_FILE": certFile, "TLS_KEY_FILE": keyFile} {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "catu.App unable to read "+name+" "+file)
+		}
+		f.Close()
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "catu.App invalid TLS certificate or key in TLS_CERT_FILE "+certFile+" and TLS_KEY_FILE "+keyFile)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil, nil
+}
+
+// startRedirectServer starts one HTTP server in PORT_REDIRECT that redirects all requests to HTTPS.
+// With autocert it also answers the ACME HTTP challenges
+func (r *AppStruct) startRedirectServer(port string, m *autocert.Manager) (*http.Server, error) {
+	httpsPort := r.Configuration.GetF("PORT", "8080")
+
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		http.Redirect(w, req, "https://"+host+req.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+
+	if m != nil {
+		handler = m.HTTPHandler(handler)
+	}
+
+	ln, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return nil, errors.Wrap(err, "catu.App error on listen PORT_REDIRECT "+port)
+	}
+
+	server := &http.Server{Handler: handler}
+	go func() {
+		err := server.Serve(ln)
+		if err != nil && err != http.ErrServerClosed {
+			logrus.WithFields(logrus.Fields{
+				"error": err.Error(),
+			}).Error("catu.App redirect server error")
+		}
+	}()
+
+	logrus.Info("Redirect server listening on port " + port)
+
+	return server, nil
+}
+
+func shutdownRedirectServer(ctx context.Context, server *http.Server) {
+	if server == nil {
+		return
+	}
+
+	if err := server.Shutdown(ctx); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Error("catu.App error on shutdown redirect server")
+	}
+}
@@ -0,0 +1,141 @@
+package catu
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestCertificate writes one self signed certificate for 127.0.0.1 and returns the cert and key paths
+func writeTestCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Organization: []string{"catu test"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	assert.Nil(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+
+	return certFile, keyFile
+}
+
+func freePort(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	return port
+}
+
+func TestStartHTTPServerTLS(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+
+	t.Run("Should serve HTTPS with HTTP/2 and redirect HTTP requests", func(t *testing.T) {
+		redirectPort := freePort(t)
+		t.Setenv("TLS_CERT_FILE", certFile)
+		t.Setenv("TLS_KEY_FILE", keyFile)
+		t.Setenv("PORT", "8443")
+		t.Setenv("PORT_REDIRECT", redirectPort)
+
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		app.GetRouter().GET("/hello", func(c echo.Context) error {
+			return c.String(http.StatusOK, c.Request().Proto)
+		})
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		serverErr := make(chan error, 1)
+		go func() {
+			serverErr <- app.StartHTTPServerWithListener(ctx, ln)
+		}()
+
+		client := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+				ForceAttemptHTTP2: true,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+
+		res, err := client.Get("https://" + ln.Addr().String() + "/hello")
+		assert.Nil(t, err)
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, 2, res.ProtoMajor)
+		assert.Equal(t, "HTTP/2.0", string(body))
+
+		res, err = client.Get("http://127.0.0.1:" + redirectPort + "/hello?a=1")
+		assert.Nil(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusMovedPermanently, res.StatusCode)
+		assert.Equal(t, "https://127.0.0.1:8443/hello?a=1", res.Header.Get("Location"))
+
+		cancel()
+		assert.Nil(t, <-serverErr)
+
+		_, err = client.Get("http://127.0.0.1:" + redirectPort + "/hello")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should return explicit errors for invalid cert files", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			cert, key, message string
+		}{
+			"missing key":  {certFile, "", "TLS_CERT_FILE and TLS_KEY_FILE should be set together"},
+			"missing file": {filepath.Join(t.TempDir(), "nope.pem"), keyFile, "unable to read TLS_CERT_FILE"},
+			"invalid pair": {keyFile, certFile, "invalid TLS certificate or key"},
+		} {
+			t.Setenv("TLS_CERT_FILE", tc.cert)
+			t.Setenv("TLS_KEY_FILE", tc.key)
+
+			app := newTestApp(t)
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.Nil(t, err)
+
+			err = app.StartHTTPServerWithListener(context.Background(), ln)
+			assert.NotNil(t, err, name)
+			if err != nil {
+				assert.Contains(t, err.Error(), tc.message, name)
+			}
+		}
+	})
+}
@@ -0,0 +1,520 @@
+package catu
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// echo context key with the request *Session
+const sessionKey = "session"
+
+// browsers limit one cookie to 4096 bytes
+const maxSessionCookieSize = 4096
+
+// Session is the data of one client session.
+// Values are JSON encoded in the stores, so numbers are float64 after one round trip
+type Session struct {
+	ID        string
+	Values    map[string]interface{}
+	ExpiresAt time.Time
+	// true if the request had no valid session
+	IsNew bool
+
+	modified  bool
+	destroyed bool
+}
+
+func (s *Session) Get(key string) interface{} {
+	return s.Values[key]
+}
+
+func (s *Session) GetString(key string) string {
+	v, _ := s.Values[key].(string)
+	return v
+}
+
+func (s *Session) Set(key string, value interface{}) {
+	s.Values[key] = value
+	s.modified = true
+}
+
+func (s *Session) Delete(key string) {
+	delete(s.Values, key)
+	s.modified = true
+}
+
+// SessionStore loads and saves the request session and its cookie
+type SessionStore interface {
+	// Get returns the request session, one new empty session if the cookie is missing, invalid or expired
+	Get(c echo.Context) (*Session, error)
+	// Set saves the session and sets the session cookie
+	Set(c echo.Context, s *Session) error
+	// Delete removes the session and expires the session cookie
+	Delete(c echo.Context, s *Session) error
+	// Regenerate changes the session ID keeping the values, call it on login to prevent session fixation
+	Regenerate(c echo.Context, s *Session) error
+}
+
+// SessionCookieConfig are the session cookie attributes, see NewSessionCookieConfig
+type SessionCookieConfig struct {
+	Name     string
+	Path     string
+	Domain   string
+	MaxAge   time.Duration
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// NewSessionCookieConfig reads the SESSION_COOKIE_* configurations.
+// The cookie is secure by default outside development and SESSION_COOKIE_SAMESITE is lax, strict or none
+func NewSessionCookieConfig(app App) SessionCookieConfig {
+	cfg := app.GetConfiguration()
+	env := cfg.GetF("GO_ENV", "development")
+
+	sameSite := http.SameSiteLaxMode
+	switch strings.ToLower(cfg.Get("SESSION_COOKIE_SAMESITE")) {
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	}
+
+	return SessionCookieConfig{
+		Name:     cfg.GetF("SESSION_COOKIE_NAME", "catu_session"),
+		Path:     "/",
+		Domain:   cfg.Get("SESSION_COOKIE_DOMAIN"),
+		MaxAge:   time.Duration(cfg.GetInt64F("SESSION_MAX_AGE", 86400)) * time.Second,
+		Secure:   cfg.GetBoolF("SESSION_COOKIE_SECURE", env != "development" && env != "dev"),
+		SameSite: sameSite,
+	}
+}
+
+func (cfg *SessionCookieConfig) cookie(value string, expiresAt time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     cfg.Name,
+		Value:    value,
+		Path:     cfg.Path,
+		Domain:   cfg.Domain,
+		Expires:  expiresAt,
+		MaxAge:   int(time.Until(expiresAt).Seconds()),
+		Secure:   cfg.Secure,
+		HttpOnly: true,
+		SameSite: cfg.SameSite,
+	}
+}
+
+func (cfg *SessionCookieConfig) setCookie(c echo.Context, value string, expiresAt time.Time) {
+	c.SetCookie(cfg.cookie(value, expiresAt))
+}
+
+func (cfg *SessionCookieConfig) expireCookie(c echo.Context) {
+	cookie := cfg.cookie("", time.Unix(0, 0))
+	cookie.MaxAge = -1
+	c.SetCookie(cookie)
+}
+
+func (cfg *SessionCookieConfig) newSession() (*Session, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		ID:        id,
+		Values:    map[string]interface{}{},
+		ExpiresAt: time.Now().Add(cfg.MaxAge),
+		IsNew:     true,
+	}, nil
+}
+
+// newSessionID returns one unguessable ID with 256 random bits
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "catu.Session error on generate ID")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+type sessionPayload struct {
+	ID        string                 `json:"id"`
+	Values    map[string]interface{} `json:"values"`
+	ExpiresAt time.Time              `json:"expiresAt"`
+}
+
+// CookieSessionStore saves the session in the cookie, encrypted and authenticated with AES-GCM.
+// Old cookies are valid until they expire, use the DBSessionStore to revoke sessions
+type CookieSessionStore struct {
+	Cookie SessionCookieConfig
+	aead   cipher.AEAD
+}
+
+// NewCookieSessionStore creates one cookie store with one key derived from secret, ex: SESSION_SECRET
+func NewCookieSessionStore(secret string, cookie SessionCookieConfig) (*CookieSessionStore, error) {
+	if secret == "" {
+		return nil, errors.New("catu.NewCookieSessionStore SESSION_SECRET configuration is required")
+	}
+
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "catu.NewCookieSessionStore error on create cipher")
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "catu.NewCookieSessionStore error on create cipher")
+	}
+
+	return &CookieSessionStore{Cookie: cookie, aead: aead}, nil
+}
+
+func (s *CookieSessionStore) Get(c echo.Context) (*Session, error) {
+	cookie, err := c.Cookie(s.Cookie.Name)
+	if err != nil {
+		return s.Cookie.newSession()
+	}
+
+	payload, err := s.decode(cookie.Value)
+	if err != nil || !time.Now().Before(payload.ExpiresAt) {
+		return s.Cookie.newSession()
+	}
+
+	if payload.Values == nil {
+		payload.Values = map[string]interface{}{}
+	}
+
+	return &Session{ID: payload.ID, Values: payload.Values, ExpiresAt: payload.ExpiresAt}, nil
+}
+
+func (s *CookieSessionStore) Set(c echo.Context, session *Session) error {
+	session.ExpiresAt = time.Now().Add(s.Cookie.MaxAge)
+
+	value, err := s.encode(&sessionPayload{ID: session.ID, Values: session.Values, ExpiresAt: session.ExpiresAt})
+	if err != nil {
+		return err
+	}
+
+	if len(value) > maxSessionCookieSize {
+		return errors.New("catu.CookieSessionStore.Set session too large for one cookie, use the db store")
+	}
+
+	s.Cookie.setCookie(c, value, session.ExpiresAt)
+	session.modified = false
+	return nil
+}
+
+func (s *CookieSessionStore) Delete(c echo.Context, session *Session) error {
+	session.destroyed = true
+	s.Cookie.expireCookie(c)
+	return nil
+}
+
+func (s *CookieSessionStore) Regenerate(c echo.Context, session *Session) error {
+	id, err := newSessionID()
+	if err != nil {
+		return err
+	}
+
+	session.ID = id
+	session.modified = true
+	return nil
+}
+
+func (s *CookieSessionStore) encode(p *sessionPayload) (string, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", errors.Wrap(err, "catu.CookieSessionStore error on encode session")
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", errors.Wrap(err, "catu.CookieSessionStore error on generate nonce")
+	}
+
+	// the cookie name is authenticated, so one value can't be moved to other cookie
+	sealed := s.aead.Seal(nonce, nonce, data, []byte(s.Cookie.Name))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (s *CookieSessionStore) decode(value string) (*sessionPayload, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	n := s.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, errors.New("catu.CookieSessionStore invalid cookie")
+	}
+
+	data, err := s.aead.Open(nil, sealed[:n], sealed[n:], []byte(s.Cookie.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &sessionPayload{}
+	return p, json.Unmarshal(data, p)
+}
+
+// SessionRecord is one session saved by the DBSessionStore in the sessions table
+type SessionRecord struct {
+	ID        string    `gorm:"column:id;primaryKey;size:64"`
+	Data      string    `gorm:"column:data;type:text"`
+	ExpiresAt time.Time `gorm:"column:expires_at;index"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+func (SessionRecord) TableName() string {
+	return "sessions"
+}
+
+// DBSessionStore saves the sessions in the database, the cookie only has the session ID
+type DBSessionStore struct {
+	DB     *gorm.DB
+	Cookie SessionCookieConfig
+}
+
+func NewDBSessionStore(db *gorm.DB, cookie SessionCookieConfig) *DBSessionStore {
+	return &DBSessionStore{DB: db, Cookie: cookie}
+}
+
+func (s *DBSessionStore) Get(c echo.Context) (*Session, error) {
+	cookie, err := c.Cookie(s.Cookie.Name)
+	if err != nil || cookie.Value == "" {
+		return s.Cookie.newSession()
+	}
+
+	record := SessionRecord{}
+	err = s.DB.Where("id = ? AND expires_at > ?", cookie.Value, time.Now()).Take(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.Cookie.newSession()
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "catu.DBSessionStore.Get error on load session")
+	}
+
+	session := &Session{ID: record.ID, Values: map[string]interface{}{}, ExpiresAt: record.ExpiresAt}
+	if err := json.Unmarshal([]byte(record.Data), &session.Values); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Warn("catu.DBSessionStore.Get invalid session data, starting one new session")
+		return s.Cookie.newSession()
+	}
+
+	return session, nil
+}
+
+func (s *DBSessionStore) Set(c echo.Context, session *Session) error {
+	data, err := json.Marshal(session.Values)
+	if err != nil {
+		return errors.Wrap(err, "catu.DBSessionStore.Set error on encode session")
+	}
+
+	session.ExpiresAt = time.Now().Add(s.Cookie.MaxAge)
+
+	record := SessionRecord{ID: session.ID, Data: string(data), ExpiresAt: session.ExpiresAt}
+	if err := s.DB.Save(&record).Error; err != nil {
+		return errors.Wrap(err, "catu.DBSessionStore.Set error on save session")
+	}
+
+	s.Cookie.setCookie(c, session.ID, session.ExpiresAt)
+	session.modified = false
+	return nil
+}
+
+func (s *DBSessionStore) Delete(c echo.Context, session *Session) error {
+	session.destroyed = true
+	s.Cookie.expireCookie(c)
+
+	return s.DB.Delete(&SessionRecord{}, "id = ?", session.ID).Error
+}
+
+// Regenerate removes the old session, so one fixed session ID can't be used after the login
+func (s *DBSessionStore) Regenerate(c echo.Context, session *Session) error {
+	id, err := newSessionID()
+	if err != nil {
+		return err
+	}
+
+	if err := s.DB.Delete(&SessionRecord{}, "id = ?", session.ID).Error; err != nil {
+		return errors.Wrap(err, "catu.DBSessionStore.Regenerate error on delete old session")
+	}
+
+	session.ID = id
+	session.modified = true
+	return nil
+}
+
+// DeleteExpired removes the expired sessions, returns the deleted count
+func (s *DBSessionStore) DeleteExpired() (int64, error) {
+	result := s.DB.Where("expires_at <= ?", time.Now()).Delete(&SessionRecord{})
+	return result.RowsAffected, result.Error
+}
+
+// StartCleanup deletes the expired sessions in each interval until stop is called
+func (s *DBSessionStore) StartCleanup(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				count, err := s.DeleteExpired()
+				if err != nil {
+					logrus.WithFields(logrus.Fields{
+						"error": err.Error(),
+					}).Error("catu.DBSessionStore.StartCleanup error on delete expired sessions")
+					continue
+				}
+
+				logrus.WithFields(logrus.Fields{
+					"count": count,
+				}).Debug("catu.DBSessionStore.StartCleanup expired sessions deleted")
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+func (r *AppStruct) GetSessionStore() SessionStore {
+	return r.sessionStore
+}
+
+// SetSessionStore replaces the SESSION_STORE store, set it in the "configuration" event
+func (r *AppStruct) SetSessionStore(store SessionStore) {
+	r.sessionStore = store
+}
+
+// GetSession returns the request session loaded by the session middleware, nil if sessions are disabled
+func GetSession(c echo.Context) *Session {
+	s, _ := c.Get(sessionKey).(*Session)
+	return s
+}
+
+// RegenerateSession changes the request session ID and the CSRF token, call it after the login
+func RegenerateSession(c echo.Context) error {
+	s := GetSession(c)
+	if s == nil {
+		return errors.New("catu.RegenerateSession sessions are disabled")
+	}
+
+	if err := handlerApp(c).GetSessionStore().Regenerate(c, s); err != nil {
+		return err
+	}
+
+	RotateCSRFToken(c)
+	return nil
+}
+
+// DestroySession removes the request session, ex: on logout
+func DestroySession(c echo.Context) error {
+	s := GetSession(c)
+	if s == nil {
+		return nil
+	}
+
+	return handlerApp(c).GetSessionStore().Delete(c, s)
+}
+
+// bindSessions creates the SESSION_STORE store (cookie or db) and adds the session middleware.
+// The db store creates the sessions table in the "migrate" event and deletes expired sessions
+// every SESSION_CLEANUP_INTERVAL seconds, default 3600
+func bindSessions(app App) error {
+	cfg := app.GetConfiguration()
+
+	if app.GetSessionStore() == nil {
+		cookie := NewSessionCookieConfig(app)
+
+		switch cfg.Get("SESSION_STORE") {
+		case "":
+			return nil
+		case "cookie":
+			store, err := NewCookieSessionStore(cfg.Get("SESSION_SECRET"), cookie)
+			if err != nil {
+				return err
+			}
+			app.SetSessionStore(store)
+		case "db":
+			store := NewDBSessionStore(app.GetDB(), cookie)
+			app.SetSessionStore(store)
+
+			app.GetEvents().On("migrate", event.ListenerFunc(func(e event.Event) error {
+				return store.DB.AutoMigrate(&SessionRecord{})
+			}), event.Normal)
+
+			interval := time.Duration(cfg.GetInt64F("SESSION_CLEANUP_INTERVAL", 3600)) * time.Second
+			stop := store.StartCleanup(interval)
+			app.GetEvents().On("shutdown", event.ListenerFunc(func(e event.Event) error {
+				stop()
+				return nil
+			}), event.Normal)
+		default:
+			return errors.New("catu.bindSessions invalid SESSION_STORE: " + cfg.Get("SESSION_STORE"))
+		}
+	}
+
+	app.GetRouter().Use(sessionMiddleware(app))
+	return nil
+}
+
+// sessionMiddleware loads the session before the handlers and saves the changed session before the response headers
+func sessionMiddleware(app App) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			store := app.GetSessionStore()
+
+			session, err := store.Get(c)
+			if err != nil {
+				return err
+			}
+
+			c.Set(sessionKey, session)
+
+			saved := false
+			save := func() {
+				// dry runs don't persist session changes
+				if saved || !session.modified || session.destroyed || IsDryRun(c) {
+					return
+				}
+				saved = true
+
+				if err := store.Set(c, session); err != nil {
+					logrus.WithFields(logrus.Fields{
+						"error":     err.Error(),
+						"requestId": GetRequestID(c),
+					}).Error("catu.sessionMiddleware error on save session")
+				}
+			}
+
+			c.Response().Before(save)
+
+			err = next(c)
+			if !c.Response().Committed {
+				save()
+			}
+
+			return err
+		}
+	}
+}
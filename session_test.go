@@ -0,0 +1,170 @@
+package catu
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessions(t *testing.T) {
+	newSessionApp := func(t *testing.T, store string) App {
+		t.Setenv("SESSION_STORE", store)
+		t.Setenv("SESSION_SECRET", "test-secret")
+		t.Setenv("SESSION_COOKIE_SAMESITE", "strict")
+		t.Setenv("SESSION_COOKIE_SECURE", "true")
+
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		assert.Nil(t, app.Migrate())
+
+		router := app.GetRouter()
+		router.POST("/set", func(c echo.Context) error {
+			s := GetSession(c)
+			s.Set("name", c.QueryParam("name"))
+			s.Set("count", 2)
+			return c.String(http.StatusOK, s.ID)
+		})
+		router.GET("/get", func(c echo.Context) error {
+			s := GetSession(c)
+			return c.JSON(http.StatusOK, map[string]interface{}{"id": s.ID, "isNew": s.IsNew, "values": s.Values})
+		})
+		router.POST("/login", func(c echo.Context) error {
+			if err := RegenerateSession(c); err != nil {
+				return err
+			}
+			GetSession(c).Set("userID", "1")
+			return c.String(http.StatusOK, GetSession(c).ID)
+		})
+		router.POST("/logout", func(c echo.Context) error {
+			if err := DestroySession(c); err != nil {
+				return err
+			}
+			return c.NoContent(http.StatusNoContent)
+		})
+
+		return app
+	}
+
+	request := func(app App, method, path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	type sessionBody struct {
+		ID     string                 `json:"id"`
+		IsNew  bool                   `json:"isNew"`
+		Values map[string]interface{} `json:"values"`
+	}
+
+	getSession := func(app App, cookie *http.Cookie) *sessionBody {
+		body := &sessionBody{}
+		json.Unmarshal(request(app, http.MethodGet, "/get", cookie).Body.Bytes(), body)
+		return body
+	}
+
+	sessionCookie := func(rec *httptest.ResponseRecorder) *http.Cookie {
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == "catu_session" {
+				return c
+			}
+		}
+		return nil
+	}
+
+	for _, store := range []string{"cookie", "db"} {
+		t.Run("Should round trip the session values with the "+store+" store", func(t *testing.T) {
+			app := newSessionApp(t, store)
+
+			rec := request(app, http.MethodGet, "/get", nil)
+			assert.Contains(t, rec.Body.String(), `"isNew":true`)
+			assert.Nil(t, sessionCookie(rec), "unchanged sessions are not saved")
+
+			rec = request(app, http.MethodPost, "/set?name=Alice", nil)
+			cookie := sessionCookie(rec)
+			if !assert.NotNil(t, cookie) {
+				return
+			}
+			assert.True(t, cookie.HttpOnly)
+			assert.True(t, cookie.Secure)
+			assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+			assert.Equal(t, "/", cookie.Path)
+
+			session := getSession(app, cookie)
+			assert.False(t, session.IsNew)
+			assert.Equal(t, rec.Body.String(), session.ID)
+			assert.Equal(t, map[string]interface{}{"name": "Alice", "count": float64(2)}, session.Values)
+
+			t.Run("Should start one new session with one invalid cookie", func(t *testing.T) {
+				session := getSession(app, &http.Cookie{Name: "catu_session", Value: cookie.Value[:len(cookie.Value)-2] + "xx"})
+				assert.True(t, session.IsNew)
+				assert.Empty(t, session.Values)
+			})
+
+			t.Run("Should regenerate the session ID keeping the values", func(t *testing.T) {
+				oldID := getSession(app, cookie).ID
+
+				rec := request(app, http.MethodPost, "/login", cookie)
+				newCookie := sessionCookie(rec)
+				assert.NotEqual(t, oldID, rec.Body.String())
+
+				session := getSession(app, newCookie)
+				assert.Equal(t, rec.Body.String(), session.ID)
+				assert.Equal(t, "1", session.Values["userID"])
+				assert.Equal(t, "Alice", session.Values["name"])
+
+				if store == "db" {
+					// the fixed session ID is removed
+					assert.True(t, getSession(app, cookie).IsNew)
+				}
+
+				rec = request(app, http.MethodPost, "/logout", newCookie)
+				assert.Equal(t, -1, sessionCookie(rec).MaxAge)
+			})
+
+			t.Run("Should use the request app session store", func(t *testing.T) {
+				newGlobalTestApp(t, nil)
+
+				rec := request(app, http.MethodPost, "/login", cookie)
+				assert.Equal(t, http.StatusOK, rec.Code)
+
+				rec = request(app, http.MethodPost, "/logout", sessionCookie(rec))
+				assert.Equal(t, http.StatusNoContent, rec.Code)
+			})
+		})
+	}
+
+	t.Run("Should delete the expired db sessions", func(t *testing.T) {
+		app := newSessionApp(t, "db")
+		store := app.GetSessionStore().(*DBSessionStore)
+
+		assert.Nil(t, store.DB.Create(&SessionRecord{ID: "expired", Data: "{}", ExpiresAt: time.Now().Add(-time.Minute)}).Error)
+		assert.Nil(t, store.DB.Create(&SessionRecord{ID: "valid", Data: "{}", ExpiresAt: time.Now().Add(time.Minute)}).Error)
+
+		assert.True(t, getSession(app, &http.Cookie{Name: "catu_session", Value: "expired"}).IsNew)
+		assert.False(t, getSession(app, &http.Cookie{Name: "catu_session", Value: "valid"}).IsNew)
+
+		count, err := store.DeleteExpired()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), count)
+
+		var total int64
+		store.DB.Model(&SessionRecord{}).Count(&total)
+		assert.Equal(t, int64(1), total)
+	})
+
+	t.Run("Should require the SESSION_SECRET in the cookie store", func(t *testing.T) {
+		_, err := NewCookieSessionStore("", SessionCookieConfig{})
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "SESSION_SECRET configuration is required")
+	})
+}
@@ -0,0 +1,78 @@
+package catu
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartHTTPServerWithListener(t *testing.T) {
+	t.Setenv("SHUTDOWN_TIMEOUT", "5s")
+	app := newTestApp(t)
+
+	shutdownFired := false
+	app.GetEvents().On("shutdown", event.ListenerFunc(func(e event.Event) error {
+		shutdownFired = true
+		return nil
+	}), event.Normal)
+
+	assert.Nil(t, app.Bootstrap())
+
+	sqlDB, err := app.GetDB().DB()
+	assert.Nil(t, err)
+
+	started := make(chan struct{})
+	app.GetRouter().GET("/slow", func(c echo.Context) error {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		return c.String(http.StatusOK, "done")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- app.StartHTTPServerWithListener(ctx, ln)
+	}()
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	resChan := make(chan result, 1)
+	go func() {
+		res, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			resChan <- result{err: err}
+			return
+		}
+		defer res.Body.Close()
+		b, _ := ioutil.ReadAll(res.Body)
+		resChan <- result{status: res.StatusCode, body: string(b)}
+	}()
+
+	<-started
+	cancel()
+
+	r := <-resChan
+	assert.Nil(t, r.err)
+	assert.Equal(t, http.StatusOK, r.status)
+	assert.Equal(t, "done", r.body)
+
+	assert.Nil(t, <-serverErr)
+	assert.True(t, shutdownFired)
+
+	assert.NotNil(t, sqlDB.Ping())
+	assert.Nil(t, app.GetDB())
+	assert.Nil(t, app.GetNamedDB("default"))
+}
@@ -0,0 +1,163 @@
+package catu
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	signedURLExpiresParam   = "expires"
+	signedURLBindParam      = "bind"
+	signedURLSignatureParam = "signature"
+)
+
+// time source, replaced in tests
+var signedURLNow = time.Now
+
+// SignedURLParams are the values used to build one signed URL
+type SignedURLParams struct {
+	// Route path params, in the same order of the route path
+	PathParams []interface{}
+	// Extra query params, also protected by the signature
+	Query url.Values
+	// Bind the signature to one client IP, checked with ClientIP, see TRUSTED_PROXIES
+	ClientIP string
+	// Bind the signature to one user ID, checked with the request authenticated user
+	UserID string
+}
+
+// SignedURL generates one temporary URL for a named route, signed with the first key from SIGNED_URL_KEYS.
+// Use the ValidateSignature middleware in the target route
+func (r *AppStruct) SignedURL(routeName string, params *SignedURLParams, expiry time.Duration) (string, error) {
+	keys := getSignedURLKeys(r)
+	if len(keys) == 0 {
+		return "", errors.New("catu.App.SignedURL SIGNED_URL_KEYS configuration is required")
+	}
+
+	if params == nil {
+		params = &SignedURLParams{}
+	}
+
+	path := r.router.Reverse(routeName, params.PathParams...)
+	if path == "" {
+		return "", errors.New("catu.App.SignedURL route not found: " + routeName)
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", errors.Wrap(err, "catu.App.SignedURL invalid route path")
+	}
+
+	query := url.Values{}
+	for k, v := range params.Query {
+		query[k] = v
+	}
+
+	query.Set(signedURLExpiresParam, strconv.FormatInt(signedURLNow().Add(expiry).Unix(), 10))
+
+	bind := []string{}
+	if params.ClientIP != "" {
+		bind = append(bind, "ip")
+	}
+	if params.UserID != "" {
+		bind = append(bind, "user")
+	}
+	if len(bind) > 0 {
+		query.Set(signedURLBindParam, strings.Join(bind, "."))
+	}
+
+	query.Set(signedURLSignatureParam, signURL(keys[0], u.Path, query, params.ClientIP, params.UserID))
+
+	return strings.TrimRight(r.Options.BaseURL, "/") + u.Path + "?" + query.Encode(), nil
+}
+
+// ValidateSignature middleware for routes accessed with App.SignedURL links.
+// Responds with 403 for invalid or tampered links and 410 for expired links
+func ValidateSignature(app App) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			query := c.QueryParams()
+			signature := query.Get(signedURLSignatureParam)
+			expires, err := strconv.ParseInt(query.Get(signedURLExpiresParam), 10, 64)
+			if signature == "" || err != nil {
+				return &HTTPError{Code: http.StatusForbidden, Message: "Invalid signature"}
+			}
+
+			var clientIP, userID string
+			for _, b := range strings.Split(query.Get(signedURLBindParam), ".") {
+				switch b {
+				case "ip":
+					clientIP = ClientIP(c)
+				case "user":
+					if ctx, ok := c.(*RequestContext); ok && ctx.AuthenticatedUser != nil {
+						userID = ctx.AuthenticatedUser.GetID()
+					}
+
+					if userID == "" {
+						return &HTTPError{Code: http.StatusForbidden, Message: "Invalid signature"}
+					}
+				}
+			}
+
+			signed := url.Values{}
+			for k, v := range query {
+				if k != signedURLSignatureParam {
+					signed[k] = v
+				}
+			}
+
+			valid := false
+			// any configured key is valid to allow key rotation
+			for _, key := range getSignedURLKeys(app) {
+				expected := signURL(key, c.Request().URL.Path, signed, clientIP, userID)
+				if hmac.Equal([]byte(expected), []byte(signature)) {
+					valid = true
+					break
+				}
+			}
+
+			if !valid {
+				logrus.WithFields(logrus.Fields{
+					"path": c.Request().URL.Path,
+				}).Debug("catu.ValidateSignature invalid signature")
+
+				return &HTTPError{Code: http.StatusForbidden, Message: "Invalid signature"}
+			}
+
+			if signedURLNow().Unix() > expires {
+				return &HTTPError{Code: http.StatusGone, Message: "Link expired"}
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// getSignedURLKeys returns the SIGNED_URL_KEYS comma separated list, the first key is used to sign new URLs
+func getSignedURLKeys(app App) [][]byte {
+	keys := [][]byte{}
+	for _, k := range strings.Split(app.GetConfiguration().Get("SIGNED_URL_KEYS"), ",") {
+		k = strings.TrimSpace(k)
+		if k != "" {
+			keys = append(keys, []byte(k))
+		}
+	}
+
+	return keys
+}
+
+func signURL(key []byte, path string, query url.Values, clientIP, userID string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(path + "?" + query.Encode() + "\n" + clientIP + "\n" + userID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,153 @@
+package catu
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignedURL(t *testing.T) {
+	t.Setenv("SIGNED_URL_KEYS", "new-key, old-key")
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+
+	now := time.Unix(1700000000, 0)
+	signedURLNow = func() time.Time { return now }
+	defer func() { signedURLNow = time.Now }()
+
+	var loggedUser *testUser
+
+	app.GetRouter().GET("/invoices/:id/download", func(c echo.Context) error {
+		return c.String(http.StatusOK, "invoice "+c.Param("id"))
+	}, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if loggedUser != nil {
+				c.(*RequestContext).SetAuthenticatedUser(loggedUser)
+			}
+			return next(c)
+		}
+	}, ValidateSignature(app)).Name = "invoice.download"
+
+	get := func(link string, remoteAddr string) *httptest.ResponseRecorder {
+		u, err := url.Parse(link)
+		assert.Nil(t, err)
+		req := httptest.NewRequest(http.MethodGet, u.RequestURI(), nil)
+		if remoteAddr != "" {
+			req.RemoteAddr = remoteAddr
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Should generate one valid URL", func(t *testing.T) {
+		link, err := app.SignedURL("invoice.download", &SignedURLParams{
+			PathParams: []interface{}{10},
+			Query:      url.Values{"format": {"pdf"}},
+		}, time.Hour)
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(link, "http://localhost:8080/invoices/10/download?"))
+
+		rec := get(link, "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "invoice 10", rec.Body.String())
+	})
+
+	t.Run("Should check expiry boundaries", func(t *testing.T) {
+		link, err := app.SignedURL("invoice.download", &SignedURLParams{PathParams: []interface{}{10}}, time.Minute)
+		assert.Nil(t, err)
+
+		now = time.Unix(1700000000+60, 0)
+		assert.Equal(t, http.StatusOK, get(link, "").Code)
+
+		now = time.Unix(1700000000+61, 0)
+		assert.Equal(t, http.StatusGone, get(link, "").Code)
+
+		now = time.Unix(1700000000, 0)
+	})
+
+	t.Run("Should reject tampered params", func(t *testing.T) {
+		link, err := app.SignedURL("invoice.download", &SignedURLParams{
+			PathParams: []interface{}{10},
+			Query:      url.Values{"format": {"pdf"}},
+		}, time.Minute)
+		assert.Nil(t, err)
+
+		for name, tampered := range map[string]string{
+			"path id":      strings.Replace(link, "/invoices/10/", "/invoices/11/", 1),
+			"query param":  strings.Replace(link, "format=pdf", "format=csv", 1),
+			"added param":  link + "&admin=true",
+			"expires":      strings.Replace(link, "expires=1700000060", "expires=1800000060", 1),
+			"signature":    link[:len(link)-2] + "xx",
+			"no signature": strings.Split(link, "&signature=")[0],
+		} {
+			assert.Equal(t, http.StatusForbidden, get(tampered, "").Code, name)
+		}
+	})
+
+	t.Run("Should accept rotated keys", func(t *testing.T) {
+		t.Setenv("SIGNED_URL_KEYS", "old-key")
+		oldLink, err := app.SignedURL("invoice.download", &SignedURLParams{PathParams: []interface{}{10}}, time.Minute)
+		assert.Nil(t, err)
+
+		t.Setenv("SIGNED_URL_KEYS", "new-key,old-key")
+		assert.Equal(t, http.StatusOK, get(oldLink, "").Code)
+
+		t.Setenv("SIGNED_URL_KEYS", "new-key")
+		assert.Equal(t, http.StatusForbidden, get(oldLink, "").Code)
+	})
+
+	t.Run("Should bind the URL to one client IP", func(t *testing.T) {
+		link, err := app.SignedURL("invoice.download", &SignedURLParams{
+			PathParams: []interface{}{10},
+			ClientIP:   "10.0.0.1",
+		}, time.Minute)
+		assert.Nil(t, err)
+
+		assert.Equal(t, http.StatusOK, get(link, "10.0.0.1:1234").Code)
+		assert.Equal(t, http.StatusForbidden, get(link, "10.0.0.2:1234").Code)
+		assert.Equal(t, http.StatusForbidden, get(strings.Replace(link, "bind=ip&", "", 1), "10.0.0.2:1234").Code)
+
+		// one spoofed proxy header is not the client IP
+		u, _ := url.Parse(link)
+		req := httptest.NewRequest(http.MethodGet, u.RequestURI(), nil)
+		req.Header.Set(echo.HeaderXForwardedFor, "10.0.0.1")
+		req.Header.Set(echo.HeaderXRealIP, "10.0.0.1")
+		req.RemoteAddr = "10.0.0.2:1234"
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("Should bind the URL to one user", func(t *testing.T) {
+		link, err := app.SignedURL("invoice.download", &SignedURLParams{
+			PathParams: []interface{}{10},
+			UserID:     "7",
+		}, time.Minute)
+		assert.Nil(t, err)
+
+		assert.Equal(t, http.StatusForbidden, get(link, "").Code)
+
+		loggedUser = &testUser{ID: "8"}
+		assert.Equal(t, http.StatusForbidden, get(link, "").Code)
+
+		loggedUser = &testUser{ID: "7"}
+		assert.Equal(t, http.StatusOK, get(link, "").Code)
+		loggedUser = nil
+	})
+
+	t.Run("Should return error for unknown routes or without keys", func(t *testing.T) {
+		_, err := app.SignedURL("unknown", nil, time.Minute)
+		assert.NotNil(t, err)
+
+		t.Setenv("SIGNED_URL_KEYS", "")
+		_, err = app.SignedURL("invoice.download", &SignedURLParams{PathParams: []interface{}{10}}, time.Minute)
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,71 @@
+package catu
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// SoftDeleteController is one HTTPController with the soft delete routes, optional in SoftDelete resources
+type SoftDeleteController interface {
+	// Restore one soft deleted record, the controller should use one Unscoped query
+	Restore(c echo.Context) error
+	// ForceDelete deletes one record permanently, the controller should use one Unscoped delete
+	ForceDelete(c echo.Context) error
+}
+
+// Trash lists the soft deleted records with the controller Query and one Unscoped request database, see RequestContext.GetDB
+func (r *HTTPResource) Trash(c echo.Context) error {
+	ctx, err := r.softDeleteContext(c, r.Name+".restore")
+	if err != nil {
+		return err
+	}
+
+	db := ctx.GetDB().Unscoped().Where(r.DeletedAtColumn + " IS NOT NULL").Session(&gorm.Session{})
+	ctx.Set(requestDBKey, db)
+
+	return r.GetController().Query(c)
+}
+
+func (r *HTTPResource) Restore(c echo.Context) error {
+	if _, err := r.softDeleteContext(c, r.Name+".restore"); err != nil {
+		return err
+	}
+
+	ctl, ok := r.GetController().(SoftDeleteController)
+	if !ok {
+		return &HTTPError{Code: http.StatusNotImplemented, Message: "Restore is not supported"}
+	}
+
+	err := ctl.Restore(c)
+	triggerResourceEvent(c, EventResourceRestored, r.Name, err)
+	r.invalidateCache(c, err)
+	return err
+}
+
+func (r *HTTPResource) ForceDelete(c echo.Context) error {
+	if _, err := r.softDeleteContext(c, r.Name+".forceDelete"); err != nil {
+		return err
+	}
+
+	ctl, ok := r.GetController().(SoftDeleteController)
+	if !ok {
+		return &HTTPError{Code: http.StatusNotImplemented, Message: "Force delete is not supported"}
+	}
+
+	err := ctl.ForceDelete(c)
+	triggerResourceEvent(c, EventResourceDeleted, r.Name, err)
+	r.invalidateCache(c, err)
+	return err
+}
+
+// softDeleteContext checks the soft delete route permission
+func (r *HTTPResource) softDeleteContext(c echo.Context, permission string) (*RequestContext, error) {
+	ctx, ok := c.(*RequestContext)
+	if !ok || !ctx.Can(permission) {
+		return nil, &HTTPError{Code: http.StatusForbidden, Message: http.StatusText(http.StatusForbidden)}
+	}
+
+	return ctx, nil
+}
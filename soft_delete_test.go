@@ -0,0 +1,154 @@
+package catu
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-catupiry/catu/acl"
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type softDeleteNote struct {
+	ID        uint64 `gorm:"primaryKey" json:"id"`
+	Title     string `json:"title"`
+	DeletedAt gorm.DeletedAt
+}
+
+type softDeleteTestController struct {
+	idCodecTestController
+}
+
+func (ctl *softDeleteTestController) Query(c echo.Context) error {
+	notes := []softDeleteNote{}
+	if err := c.(*RequestContext).GetDB().Order("id").Find(&notes).Error; err != nil {
+		return err
+	}
+
+	titles := []string{}
+	for _, n := range notes {
+		titles = append(titles, n.Title)
+	}
+	return c.JSON(http.StatusOK, titles)
+}
+
+func (ctl *softDeleteTestController) Delete(c echo.Context) error {
+	if err := c.(*RequestContext).GetDB().Delete(&softDeleteNote{}, c.Param("id")).Error; err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (ctl *softDeleteTestController) Restore(c echo.Context) error {
+	result := c.(*RequestContext).GetDB().Unscoped().Model(&softDeleteNote{}).
+		Where("id = ? AND deleted_at IS NOT NULL", c.Param("id")).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return &HTTPError{Code: http.StatusNotFound, Message: http.StatusText(http.StatusNotFound)}
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (ctl *softDeleteTestController) ForceDelete(c echo.Context) error {
+	if err := c.(*RequestContext).GetDB().Unscoped().Delete(&softDeleteNote{}, c.Param("id")).Error; err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func TestSoftDeleteResource(t *testing.T) {
+	app := newTestApp(t)
+	// authenticates the X-Test-User header with the editor role
+	app.GetEvents().On("bindMiddlewares", event.ListenerFunc(func(e event.Event) error {
+		app.GetRouter().Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				if id := c.Request().Header.Get("X-Test-User"); id != "" {
+					c.(*RequestContext).SetAuthenticatedUserAndFillRoles(&testUser{ID: id, Roles: []string{"editor"}})
+				}
+				return next(c)
+			}
+		})
+		return nil
+	}), event.Low)
+
+	api := app.GetRouterGroup("api")
+	assert.Nil(t, app.SetResourceWithOptions("notes", &softDeleteTestController{}, api.Group("/notes"), ResourceOptions{SoftDelete: true}))
+	assert.Nil(t, app.SetResource("pages", &resourceTestController{name: "pages"}, api.Group("/pages")))
+	assert.Nil(t, app.Bootstrap())
+	assert.Nil(t, app.SetRole("editor", acl.Role{Name: "editor", Permissions: []string{"notes.restore", "notes.forceDelete", "pages.restore"}}))
+
+	db := app.GetDB()
+	assert.Nil(t, db.AutoMigrate(&softDeleteNote{}))
+	assert.Nil(t, db.Create(&[]softDeleteNote{{Title: "first"}, {Title: "second"}, {Title: "third"}}).Error)
+
+	restored := []string{}
+	app.GetEvents().On(EventResourceRestored, event.ListenerFunc(func(e event.Event) error {
+		restored = append(restored, e.Get("resource").(string)+":"+e.Get("id").(string))
+		return nil
+	}))
+
+	request := func(method, path string, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if user != "" {
+			req.Header.Set("X-Test-User", user)
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	titles := func(path string) []string {
+		rec := request(http.MethodGet, path, "1")
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+		list := []string{}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &list))
+		return list
+	}
+
+	t.Run("Should run the trash and restore cycle", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, "/api/notes/1", "1").Code)
+		assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, "/api/notes/2", "1").Code)
+		assert.Equal(t, []string{"third"}, titles("/api/notes"))
+		assert.Equal(t, []string{"first", "second"}, titles("/api/notes/trash"))
+
+		assert.Equal(t, http.StatusNoContent, request(http.MethodPost, "/api/notes/1/restore", "1").Code)
+		assert.Equal(t, []string{"first", "third"}, titles("/api/notes"))
+		assert.Equal(t, []string{"second"}, titles("/api/notes/trash"))
+		assert.Equal(t, []string{"notes:1"}, restored)
+		// not deleted records
+		assert.Equal(t, http.StatusNotFound, request(http.MethodPost, "/api/notes/3/restore", "1").Code)
+
+		assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, "/api/notes/2/force", "1").Code)
+		assert.Equal(t, []string{}, titles("/api/notes/trash"))
+		var count int64
+		assert.Nil(t, db.Unscoped().Model(&softDeleteNote{}).Count(&count).Error)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("Should check the permissions", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/api/notes/trash", "").Code)
+		assert.Equal(t, http.StatusForbidden, request(http.MethodPost, "/api/notes/1/restore", "").Code)
+		assert.Equal(t, http.StatusForbidden, request(http.MethodDelete, "/api/notes/1/force", "").Code)
+	})
+
+	t.Run("Should bind the soft delete routes only with the SoftDelete option", func(t *testing.T) {
+		assert.Equal(t, "trash", request(http.MethodGet, "/api/pages/trash", "1").Body.String())
+
+		paths := []string{}
+		for _, route := range app.GetRouter().Routes() {
+			paths = append(paths, route.Method+" "+route.Path)
+		}
+		assert.Contains(t, paths, "POST /api/notes/:id/restore")
+		assert.Contains(t, paths, "DELETE /api/notes/:id/force")
+		assert.NotContains(t, paths, "POST /api/pages/:id/restore")
+		assert.NotContains(t, paths, "DELETE /api/pages/:id/force")
+	})
+}
@@ -0,0 +1,167 @@
+package catu
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// MIMETextEventStream is the server-sent events content type
+const MIMETextEventStream = "text/event-stream"
+
+// ErrSSEClosed is returned by the SSEStream writes after the client disconnect or Close
+var ErrSSEClosed = errors.New("catu.SSEStream stream closed")
+
+// SSEStream writes server-sent events to one response, see NewSSEStream
+type SSEStream struct {
+	c      echo.Context
+	mu     sync.Mutex
+	closed bool
+	// stops the heartbeat
+	stop          chan struct{}
+	heartbeatDone chan struct{}
+}
+
+// NewSSEStream starts one server-sent events response, ex:
+//
+//	stream, err := catu.NewSSEStream(c)
+//	if err != nil {
+//		return err
+//	}
+//	defer stream.Close()
+//	stream.Send("progress", "1", state)
+//
+// One heartbeat comment is sent every SSE_HEARTBEAT_INTERVAL seconds (default 15, 0 disables it).
+// Event streams are not compressed, the handler must Close the stream before returning
+func NewSSEStream(c echo.Context) (*SSEStream, error) {
+	res := c.Response()
+	if res.Committed {
+		return nil, errors.New("catu.NewSSEStream response already started")
+	}
+	if _, ok := res.Writer.(http.Flusher); !ok {
+		return nil, errors.New("catu.NewSSEStream response writer is not one http.Flusher")
+	}
+
+	header := res.Header()
+	header.Set(echo.HeaderContentType, MIMETextEventStream)
+	header.Set(echo.HeaderCacheControl, "no-cache")
+	header.Set(echo.HeaderConnection, "keep-alive")
+	// disables the nginx proxy buffering
+	header.Set("X-Accel-Buffering", "no")
+	header.Del(echo.HeaderContentLength)
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	s := &SSEStream{c: c, stop: make(chan struct{})}
+
+	interval := time.Duration(handlerApp(c).GetConfiguration().GetInt64F("SSE_HEARTBEAT_INTERVAL", 15)) * time.Second
+	if interval > 0 {
+		s.heartbeatDone = make(chan struct{})
+		go s.heartbeat(interval)
+	}
+
+	return s, nil
+}
+
+// Done is closed after the client disconnect
+func (s *SSEStream) Done() <-chan struct{} {
+	return s.c.Request().Context().Done()
+}
+
+// Send writes one event with the JSON encoded data and flushes it, event and id are optional
+func (s *SSEStream) Send(event, id string, data interface{}) error {
+	if strings.ContainsAny(event, "\r\n") || strings.ContainsAny(id, "\r\n") {
+		return errors.New("catu.SSEStream.Send invalid event or id with line breaks")
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return errors.Wrap(err, "catu.SSEStream.Send error on encode data")
+	}
+
+	var b strings.Builder
+	if id != "" {
+		b.WriteString("id: " + id + "\n")
+	}
+	if event != "" {
+		b.WriteString("event: " + event + "\n")
+	}
+	b.WriteString("data: ")
+	b.Write(payload)
+	b.WriteString("\n\n")
+
+	return s.write(b.String())
+}
+
+// Comment writes one comment line, ignored by the clients
+func (s *SSEStream) Comment(text string) error {
+	return s.write(": " + strings.ReplaceAll(text, "\n", " ") + "\n\n")
+}
+
+func (s *SSEStream) write(frame string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || s.c.Request().Context().Err() != nil {
+		return ErrSSEClosed
+	}
+
+	res := s.c.Response()
+	if _, err := res.Write([]byte(frame)); err != nil {
+		return ErrSSEClosed
+	}
+	res.Flush()
+	return nil
+}
+
+// Close stops the heartbeat, next writes return ErrSSEClosed
+func (s *SSEStream) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.stop)
+	s.mu.Unlock()
+
+	if s.heartbeatDone != nil {
+		<-s.heartbeatDone
+	}
+}
+
+func (s *SSEStream) heartbeat(interval time.Duration) {
+	defer close(s.heartbeatDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.Done():
+			return
+		case <-ticker.C:
+			if err := s.Comment("heartbeat"); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"error":     fmt.Sprintf("%+v\n", err),
+					"requestId": GetRequestID(s.c),
+				}).Debug("catu.SSEStream heartbeat stopped")
+				return
+			}
+		}
+	}
+}
+
+// isEventStreamRequest skips the compression of server-sent events requests
+func isEventStreamRequest(c echo.Context) bool {
+	return strings.Contains(c.Request().Header.Get(echo.HeaderAccept), MIMETextEventStream)
+}
@@ -0,0 +1,145 @@
+package catu
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSEStream(t *testing.T) {
+	request := func(app App, path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderAccept, MIMETextEventStream)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Should stream the events", func(t *testing.T) {
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		app.GetRouter().GET("/events", func(c echo.Context) error {
+			stream, err := NewSSEStream(c)
+			if err != nil {
+				return err
+			}
+			defer stream.Close()
+
+			stream.Send("progress", "1", map[string]int{"current": 1})
+			stream.Send("", "", "done")
+			stream.Comment("last\nline")
+			assert.NotNil(t, stream.Send("invalid\nevent", "", nil))
+			return nil
+		})
+
+		rec := request(app, "/events", map[string]string{echo.HeaderAcceptEncoding: "gzip"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.True(t, rec.Flushed)
+		assert.Equal(t, MIMETextEventStream, rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, "no-cache", rec.Header().Get(echo.HeaderCacheControl))
+		assert.Equal(t, "keep-alive", rec.Header().Get(echo.HeaderConnection))
+		assert.Equal(t, "", rec.Header().Get(echo.HeaderContentEncoding))
+
+		events := strings.Split(rec.Body.String(), "\n\n")
+		assert.Equal(t, []string{
+			"id: 1\nevent: progress\ndata: {\"current\":1}",
+			"data: \"done\"",
+			": last line",
+			"",
+		}, events)
+	})
+
+	t.Run("Should not compress the streams with the Compression middleware", func(t *testing.T) {
+		t.Setenv("COMPRESSION_ENABLED", "true")
+		t.Setenv("COMPRESSION_MIN_SIZE", "1")
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		app.GetRouter().GET("/events", func(c echo.Context) error {
+			stream, err := NewSSEStream(c)
+			if err != nil {
+				return err
+			}
+			defer stream.Close()
+			return stream.Send("message", "", strings.Repeat("a", 100))
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/events", nil)
+		req.Header.Set(echo.HeaderAcceptEncoding, "gzip")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		assert.Equal(t, "", rec.Header().Get(echo.HeaderContentEncoding))
+		assert.Contains(t, rec.Body.String(), "event: message\ndata: \"aaa")
+	})
+
+	t.Run("Should send the heartbeat comments", func(t *testing.T) {
+		t.Setenv("SSE_HEARTBEAT_INTERVAL", "1")
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		app.GetRouter().GET("/events", func(c echo.Context) error {
+			stream, err := NewSSEStream(c)
+			if err != nil {
+				return err
+			}
+			defer stream.Close()
+			time.Sleep(1200 * time.Millisecond)
+			return nil
+		})
+
+		rec := request(app, "/events", nil)
+		assert.Equal(t, ": heartbeat\n\n", rec.Body.String())
+	})
+
+	t.Run("Should stop after the client disconnect", func(t *testing.T) {
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		app.GetRouter().GET("/events", func(c echo.Context) error {
+			stream, err := NewSSEStream(c)
+			if err != nil {
+				return err
+			}
+			defer stream.Close()
+
+			assert.Nil(t, stream.Send("first", "", nil))
+			cancel()
+			<-stream.Done()
+			assert.Equal(t, ErrSSEClosed, stream.Send("second", "", nil))
+			return nil
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		assert.Equal(t, "event: first\ndata: null\n\n", rec.Body.String())
+	})
+
+	t.Run("Should not write the error response in one started stream", func(t *testing.T) {
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		app.GetRouter().GET("/events", func(c echo.Context) error {
+			stream, err := NewSSEStream(c)
+			if err != nil {
+				return err
+			}
+			defer stream.Close()
+
+			stream.Send("first", "", nil)
+			return errors.New("broken stream")
+		})
+
+		rec := request(app, "/events", map[string]string{echo.HeaderContentType: echo.MIMEApplicationJSON})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "event: first\ndata: null\n\n", rec.Body.String())
+	})
+}
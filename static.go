@@ -0,0 +1,188 @@
+package catu
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// fingerprinted assets never change, one year
+const staticImmutableMaxAge = 365 * 24 * 60 * 60
+
+// staticMount is one ServeStatic directory
+type staticMount struct {
+	prefix string
+	dir    string
+	// with STATIC_FINGERPRINT, file name by fingerprinted name and fingerprinted name by file name
+	files        map[string]string
+	fingerprints map[string]string
+}
+
+// ServeStatic serves the dir files in the prefix path, ex: app.ServeStatic("/public", "public").
+// Responses have Cache-Control with STATIC_MAX_AGE seconds (default 86400) and one ETag.
+// With STATIC_FINGERPRINT=true the files are hashed and the assetURL template function returns the fingerprinted URLs,
+// ex: "/public/app-ab12cd34.css", cached as immutable
+func (r *AppStruct) ServeStatic(prefix, dir string) error {
+	prefix = "/" + strings.Trim(prefix, "/")
+	if prefix == "/" {
+		prefix = ""
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return errors.Wrap(err, "catu.App.ServeStatic error on open dir")
+	}
+	if !info.IsDir() {
+		return errors.New("catu.App.ServeStatic not one directory: " + dir)
+	}
+
+	mount := &staticMount{prefix: prefix, dir: dir}
+
+	if r.Configuration.GetBool("STATIC_FINGERPRINT") {
+		if err := mount.fingerprint(); err != nil {
+			return err
+		}
+	}
+
+	maxAge := r.Configuration.GetInt64F("STATIC_MAX_AGE", 86400)
+	handler := mount.handler(maxAge)
+	r.router.GET(prefix+"/*", handler)
+	r.router.HEAD(prefix+"/*", handler)
+
+	r.staticMounts = append(r.staticMounts, mount)
+
+	logrus.WithFields(logrus.Fields{
+		"prefix":       prefix,
+		"dir":          dir,
+		"fingerprints": len(mount.fingerprints),
+	}).Debug("catu.App.ServeStatic serving static files")
+
+	return nil
+}
+
+// AssetURL returns the URL of one ServeStatic file, fingerprinted with STATIC_FINGERPRINT, ex: "/public/app-ab12cd34.css".
+// It is the assetURL template function
+func (r *AppStruct) AssetURL(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+
+	for _, m := range r.staticMounts {
+		if f, ok := m.fingerprints[name]; ok {
+			return m.prefix + "/" + f
+		}
+	}
+
+	for _, m := range r.staticMounts {
+		if _, err := os.Stat(filepath.Join(m.dir, filepath.FromSlash(name))); err == nil {
+			return m.prefix + "/" + name
+		}
+	}
+
+	if len(r.staticMounts) > 0 {
+		return r.staticMounts[0].prefix + "/" + name
+	}
+	return "/" + name
+}
+
+// fingerprint hashes the dir files, ex: "css/app.css" is "css/app-ab12cd34.css"
+func (m *staticMount) fingerprint() error {
+	m.files = map[string]string{}
+	m.fingerprints = map[string]string{}
+
+	return filepath.WalkDir(m.dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(m.dir, file)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		hash, err := hashFile(file)
+		if err != nil {
+			return errors.Wrap(err, "catu.App.ServeStatic error on hash "+file)
+		}
+
+		ext := path.Ext(name)
+		fingerprinted := strings.TrimSuffix(name, ext) + "-" + hash[:8] + ext
+
+		m.files[fingerprinted] = name
+		m.fingerprints[name] = fingerprinted
+		return nil
+	})
+}
+
+func hashFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (m *staticMount) handler(maxAge int64) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		name, err := url.PathUnescape(c.Param("*"))
+		if err != nil {
+			return &HTTPError{Code: http.StatusNotFound, Message: "Not Found"}
+		}
+
+		// rejects the directory traversal attempts, ex: "../.env"
+		for _, segment := range strings.Split(filepath.ToSlash(name), "/") {
+			if segment == ".." {
+				return &HTTPError{Code: http.StatusNotFound, Message: "Not Found"}
+			}
+		}
+		name = strings.TrimPrefix(path.Clean("/"+name), "/")
+
+		cacheControl := "public, max-age=" + strconv.FormatInt(maxAge, 10)
+		if original, ok := m.files[name]; ok {
+			name = original
+			cacheControl = "public, max-age=" + strconv.Itoa(staticImmutableMaxAge) + ", immutable"
+		}
+
+		file, err := os.Open(filepath.Join(m.dir, filepath.FromSlash(name)))
+		if err != nil {
+			return &HTTPError{Code: http.StatusNotFound, Message: "Not Found", Internal: err}
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return &HTTPError{Code: http.StatusNotFound, Message: "Not Found"}
+		}
+
+		header := c.Response().Header()
+		header.Set("Cache-Control", cacheControl)
+		header.Set("ETag", `"`+strconv.FormatInt(info.ModTime().UnixNano(), 36)+"-"+strconv.FormatInt(info.Size(), 36)+`"`)
+
+		// responds 304 for one matching If-None-Match or If-Modified-Since
+		http.ServeContent(c.Response(), c.Request(), info.Name(), info.ModTime(), file)
+		return nil
+	}
+}
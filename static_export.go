@@ -0,0 +1,416 @@
+package catu
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Resource mutation events, triggered after one successful resource Create, Update, Delete or Restore.
+// The event data has the "resource" name and the "id" route param
+const (
+	EventResourceCreated  = "resource.created"
+	EventResourceUpdated  = "resource.updated"
+	EventResourceDeleted  = "resource.deleted"
+	EventResourceRestored = "resource.restored"
+)
+
+// name of the manifest file written in the static export root
+const staticManifestFile = "manifest.json"
+
+// StaticParamsEnumerator returns the path params of each page of one route, in the same order of the route path.
+// Return one item with empty params for routes without params
+type StaticParamsEnumerator func() ([][]interface{}, error)
+
+// StaticManifest lists the exported pages by path
+type StaticManifest struct {
+	GeneratedAt time.Time                      `json:"generatedAt"`
+	Pages       map[string]*StaticManifestPage `json:"pages"`
+}
+
+type StaticManifestPage struct {
+	Route string `json:"route"`
+	Path  string `json:"path"`
+	// File name in the storage, ex: "about/index.html"
+	File string `json:"file"`
+	// sha256 of the file content
+	Hash       string    `json:"hash"`
+	Size       int       `json:"size"`
+	RenderedAt time.Time `json:"renderedAt"`
+}
+
+type staticRoute struct {
+	name   string
+	params StaticParamsEnumerator
+	// resources that change the route pages
+	resources []string
+}
+
+// StaticRoutes renders the registered HTML routes to static files, see App.StaticRoutes
+type StaticRoutes struct {
+	app    App
+	mu     sync.Mutex
+	routes []*staticRoute
+
+	manifest *StaticManifest
+	storage  Storage
+	pending  sync.WaitGroup
+}
+
+func NewStaticRoutes(app App) *StaticRoutes {
+	return &StaticRoutes{
+		app:      app,
+		manifest: &StaticManifest{Pages: map[string]*StaticManifestPage{}},
+	}
+}
+
+// StaticRoutes returns the static export registry
+func (r *AppStruct) StaticRoutes() *StaticRoutes {
+	return r.staticRoutes
+}
+
+// Register adds one named GET route to the static export.
+// params can be nil for routes without path params and resources are the resource names that change the route pages,
+// used by the incremental mode, see Watch.
+// Routes that respond 401 or 403 to one anonymous request are rejected, the export only has public pages
+func (s *StaticRoutes) Register(routeName string, params StaticParamsEnumerator, resources ...string) error {
+	if !s.hasGETRoute(routeName) {
+		return errors.New("catu.StaticRoutes.Register GET route not found: " + routeName)
+	}
+
+	route := &staticRoute{name: routeName, params: params, resources: resources}
+
+	paths, err := s.routePaths(route)
+	if err != nil {
+		return err
+	}
+
+	if len(paths) > 0 {
+		rec := s.serve(paths[0])
+		if rec.Code == http.StatusUnauthorized || rec.Code == http.StatusForbidden {
+			return errors.New("catu.StaticRoutes.Register route requires authentication: " + routeName)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.routes {
+		if r.name == routeName {
+			return errors.New("catu.StaticRoutes.Register route already registered: " + routeName)
+		}
+	}
+
+	s.routes = append(s.routes, route)
+	return nil
+}
+
+func (s *StaticRoutes) hasGETRoute(name string) bool {
+	for _, r := range s.app.GetRouter().Routes() {
+		if r.Name == name && r.Method == http.MethodGet {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *StaticRoutes) routePaths(route *staticRoute) ([]string, error) {
+	list := [][]interface{}{{}}
+	if route.params != nil {
+		var err error
+		list, err = route.params()
+		if err != nil {
+			return nil, errors.Wrap(err, "catu.StaticRoutes error on list params of route "+route.name)
+		}
+	}
+
+	paths := make([]string, 0, len(list))
+	for _, p := range list {
+		paths = append(paths, s.app.GetRouter().Reverse(route.name, p...))
+	}
+
+	return paths, nil
+}
+
+// serve renders one path with the app router, with all middlewares, layouts and template functions
+func (s *StaticRoutes) serve(urlPath string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, urlPath, nil)
+	req.Header.Set(echo.HeaderAccept, echo.MIMETextHTML)
+
+	rec := httptest.NewRecorder()
+	s.app.GetRouter().ServeHTTP(rec, req)
+
+	return rec
+}
+
+// Export renders all registered routes and writes the pages and the manifest in storage
+func (s *StaticRoutes) Export(ctx context.Context, storage Storage) (*StaticManifest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	manifest := &StaticManifest{Pages: map[string]*StaticManifestPage{}}
+
+	for _, route := range s.routes {
+		if err := s.renderRoute(ctx, storage, manifest, route); err != nil {
+			return nil, err
+		}
+	}
+
+	s.manifest = manifest
+
+	if err := s.writeManifest(ctx, storage); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// Rerender renders only the routes changed by the resource and updates the manifest in storage
+func (s *StaticRoutes) Rerender(ctx context.Context, storage Storage, resource string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, route := range s.routes {
+		if !route.hasResource(resource) {
+			continue
+		}
+
+		// pages of removed records are kept, they are removed in the next full export
+		if err := s.renderRoute(ctx, storage, s.manifest, route); err != nil {
+			return err
+		}
+		count++
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"resource": resource,
+		"routes":   count,
+	}).Debug("catu.StaticRoutes.Rerender done")
+
+	if count == 0 {
+		return nil
+	}
+
+	return s.writeManifest(ctx, storage)
+}
+
+// Watch starts the incremental mode, resource mutation events re-render the affected routes in storage.
+// Rendering runs in background after the mutation response, use Wait to wait for it
+func (s *StaticRoutes) Watch(storage Storage) {
+	s.mu.Lock()
+	alreadyWatching := s.storage != nil
+	s.storage = storage
+	s.mu.Unlock()
+
+	if alreadyWatching {
+		return
+	}
+
+	listener := event.ListenerFunc(func(e event.Event) error {
+		resource, _ := e.Get("resource").(string)
+
+		s.pending.Add(1)
+		go func() {
+			defer s.pending.Done()
+
+			s.mu.Lock()
+			storage := s.storage
+			s.mu.Unlock()
+
+			if err := s.Rerender(context.Background(), storage, resource); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"resource": resource,
+					"event":    e.Name(),
+					"error":    err,
+				}).Error("catu.StaticRoutes.Watch error on re-render routes")
+			}
+		}()
+
+		return nil
+	})
+
+	events := s.app.GetEvents()
+	events.On(EventResourceCreated, listener)
+	events.On(EventResourceUpdated, listener)
+	events.On(EventResourceDeleted, listener)
+	events.On(EventResourceRestored, listener)
+}
+
+// Wait waits the background re-renders started by the resource events
+func (s *StaticRoutes) Wait() {
+	s.pending.Wait()
+}
+
+// Manifest returns the last exported manifest
+func (s *StaticRoutes) Manifest() *StaticManifest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.manifest
+}
+
+func (s *StaticRoutes) renderRoute(ctx context.Context, storage Storage, manifest *StaticManifest, route *staticRoute) error {
+	paths, err := s.routePaths(route)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range paths {
+		rec := s.serve(p)
+		if rec.Code != http.StatusOK {
+			return errors.Errorf("catu.StaticRoutes error on render %s (route %s): status %d", p, route.name, rec.Code)
+		}
+
+		file := staticFileName(p)
+		if file == "" {
+			return errors.New("catu.StaticRoutes invalid page path: " + p)
+		}
+
+		content := rec.Body.Bytes()
+		hash := sha256.Sum256(content)
+
+		if err := storage.Save(ctx, file, bytes.NewReader(content), rec.Header().Get(echo.HeaderContentType)); err != nil {
+			return errors.Wrap(err, "catu.StaticRoutes error on store "+file)
+		}
+
+		manifest.Pages[p] = &StaticManifestPage{
+			Route:      route.name,
+			Path:       p,
+			File:       file,
+			Hash:       hex.EncodeToString(hash[:]),
+			Size:       len(content),
+			RenderedAt: time.Now(),
+		}
+	}
+
+	return nil
+}
+
+func (s *StaticRoutes) writeManifest(ctx context.Context, storage Storage) error {
+	s.manifest.GeneratedAt = time.Now()
+
+	content, err := json.MarshalIndent(s.manifest, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "catu.StaticRoutes error on encode manifest")
+	}
+
+	return storage.Save(ctx, staticManifestFile, bytes.NewReader(content), echo.MIMEApplicationJSON)
+}
+
+func (r *staticRoute) hasResource(name string) bool {
+	for _, res := range r.resources {
+		if res == name {
+			return true
+		}
+	}
+	return false
+}
+
+// staticFileName returns the storage file of one page path, ex: "/about" is "about/index.html".
+// Paths with one file extension are kept, returns one empty string for paths outside the export root
+func staticFileName(urlPath string) string {
+	if i := strings.IndexAny(urlPath, "?#"); i >= 0 {
+		urlPath = urlPath[:i]
+	}
+
+	for _, part := range strings.Split(urlPath, "/") {
+		if part == ".." {
+			return ""
+		}
+	}
+
+	p := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+
+	if p == "" {
+		return "index.html"
+	}
+
+	if path.Ext(p) != "" {
+		return p
+	}
+
+	return p + "/index.html"
+}
+
+// ExportStatic renders the registered static routes to one directory, default STATIC_EXPORT_DIR or "static".
+// The app should be bootstrapped before the export
+func ExportStatic(app App, dir string) (*StaticManifest, error) {
+	if dir == "" {
+		dir = app.GetConfiguration().GetF("STATIC_EXPORT_DIR", "static")
+	}
+
+	manifest, err := app.StaticRoutes().Export(context.Background(), &DirStorage{Dir: dir})
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(manifest.Pages))
+	for p := range manifest.Pages {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	logrus.WithFields(logrus.Fields{
+		"dir":   dir,
+		"pages": paths,
+	}).Info("catu.ExportStatic done")
+
+	return manifest, nil
+}
+
+// triggerResourceEvent fires one resource mutation event if the request succeeded
+// or after the bulk transaction in bulk items, see deferBulkEffect
+func triggerResourceEvent(c echo.Context, eventName, resource string, err error) {
+	if err != nil || c.Response().Status >= http.StatusBadRequest {
+		return
+	}
+
+	fire := func(ctx context.Context) {
+		fireResourceEvent(ctx, c, eventName, resource)
+	}
+	if !deferBulkEffect(c, fire) {
+		fire(c.Request().Context())
+	}
+}
+
+// fireResourceEvent fires one resource event with the ctx request context
+func fireResourceEvent(ctx context.Context, c echo.Context, eventName, resource string) {
+	app := handlerApp(c)
+	if app == nil {
+		return
+	}
+
+	if s := GetDryRun(c); s != nil {
+		s.Record("event", eventName, event.M{"resource": resource, "id": c.Param("id")})
+		return
+	}
+
+	// the response is sent, the listener errors are logged
+	if err := app.TriggerSync(ctx, eventName, event.M{
+		"resource": resource,
+		"id":       c.Param("id"),
+	}); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event":     eventName,
+			"resource":  resource,
+			"id":        c.Param("id"),
+			"error":     err.Error(),
+			"requestId": GetRequestID(c),
+		}).Error("catu.triggerResourceEvent error on " + eventName + " event")
+	}
+}
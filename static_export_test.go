@@ -0,0 +1,241 @@
+package catu
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryStorage is one Storage that keeps the files in memory
+type memoryStorage struct {
+	files map[string][]byte
+	puts  []string
+}
+
+func (s *memoryStorage) Save(ctx context.Context, name string, content io.Reader, contentType string) error {
+	b, err := ioutil.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	s.files[name] = b
+	s.puts = append(s.puts, name)
+	return nil
+}
+
+func (s *memoryStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	b, ok := s.files[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (s *memoryStorage) Delete(ctx context.Context, name string) error {
+	delete(s.files, name)
+	return nil
+}
+
+func TestStaticRoutes(t *testing.T) {
+	newStaticApp := func(t *testing.T) (App, map[string]string) {
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+
+		titles := map[string]string{"first": "First", "second": "Second"}
+
+		router := app.GetRouter()
+		router.GET("/", func(c echo.Context) error {
+			return c.HTML(http.StatusOK, "<h1>Home</h1>")
+		}).Name = "home"
+		router.GET("/about", func(c echo.Context) error {
+			return c.HTML(http.StatusOK, "<h1>About "+GetRequestID(c)+"</h1>")
+		}).Name = "about"
+		router.GET("/pages/:slug", func(c echo.Context) error {
+			title, ok := titles[c.Param("slug")]
+			if !ok {
+				return echo.NewHTTPError(http.StatusNotFound)
+			}
+			return c.HTML(http.StatusOK, "<h1>"+title+"</h1>")
+		}).Name = "page"
+		router.GET("/account", func(c echo.Context) error {
+			if !c.(*RequestContext).IsAuthenticated {
+				return echo.NewHTTPError(http.StatusUnauthorized)
+			}
+			return c.HTML(http.StatusOK, "<h1>Account</h1>")
+		}).Name = "account"
+
+		assert.Nil(t, app.SetResource("pages", &resourceTestController{name: "pages"}, app.GetRouterGroup("api").Group("/pages")))
+
+		return app, titles
+	}
+
+	pageParams := func() ([][]interface{}, error) {
+		return [][]interface{}{{"first"}, {"second"}}, nil
+	}
+
+	t.Run("Should reject unknown and authenticated routes", func(t *testing.T) {
+		app, _ := newStaticApp(t)
+
+		err := app.StaticRoutes().Register("unknown", nil)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "GET route not found: unknown")
+
+		err = app.StaticRoutes().Register("account", nil)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "route requires authentication: account")
+
+		assert.Nil(t, app.StaticRoutes().Register("home", nil))
+		err = app.StaticRoutes().Register("home", nil)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "route already registered: home")
+	})
+
+	t.Run("Should export all routes with the manifest", func(t *testing.T) {
+		app, _ := newStaticApp(t)
+		dir := t.TempDir()
+
+		assert.Nil(t, app.StaticRoutes().Register("home", nil))
+		assert.Nil(t, app.StaticRoutes().Register("about", nil))
+		assert.Nil(t, app.StaticRoutes().Register("page", pageParams, "pages"))
+
+		manifest, err := ExportStatic(app, dir)
+		assert.Nil(t, err)
+		assert.Len(t, manifest.Pages, 4)
+
+		files := map[string]string{
+			"/":             "index.html",
+			"/about":        "about/index.html",
+			"/pages/first":  "pages/first/index.html",
+			"/pages/second": "pages/second/index.html",
+		}
+
+		for p, file := range files {
+			content, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(file)))
+			assert.Nil(t, err)
+
+			hash := sha256.Sum256(content)
+			page := manifest.Pages[p]
+			if assert.NotNil(t, page, p) {
+				assert.Equal(t, file, page.File)
+				assert.Equal(t, hex.EncodeToString(hash[:]), page.Hash)
+				assert.Equal(t, len(content), page.Size)
+			}
+		}
+
+		second, _ := ioutil.ReadFile(filepath.Join(dir, "pages", "second", "index.html"))
+		assert.Equal(t, "<h1>Second</h1>", string(second))
+
+		// rendered with the app middlewares
+		about, _ := ioutil.ReadFile(filepath.Join(dir, "about", "index.html"))
+		assert.Regexp(t, "<h1>About .+</h1>", string(about))
+
+		manifestFile, err := ioutil.ReadFile(filepath.Join(dir, "manifest.json"))
+		assert.Nil(t, err)
+
+		stored := StaticManifest{}
+		assert.Nil(t, json.Unmarshal(manifestFile, &stored))
+		assert.Len(t, stored.Pages, 4)
+		assert.Equal(t, "page", stored.Pages["/pages/first"].Route)
+		assert.Equal(t, manifest.Pages["/pages/first"].Hash, stored.Pages["/pages/first"].Hash)
+	})
+
+	t.Run("Should fail the export if one page fails", func(t *testing.T) {
+		app, _ := newStaticApp(t)
+
+		slug := "first"
+		assert.Nil(t, app.StaticRoutes().Register("page", func() ([][]interface{}, error) {
+			return [][]interface{}{{slug}}, nil
+		}))
+
+		slug = "missing"
+		_, err := app.StaticRoutes().Export(context.Background(), &memoryStorage{files: map[string][]byte{}})
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "error on render /pages/missing (route page): status 404")
+	})
+
+	t.Run("Should re-render only the affected routes on resource events", func(t *testing.T) {
+		app, titles := newStaticApp(t)
+		storage := &memoryStorage{files: map[string][]byte{}}
+
+		assert.Nil(t, app.StaticRoutes().Register("home", nil))
+		assert.Nil(t, app.StaticRoutes().Register("page", pageParams, "pages"))
+
+		_, err := app.StaticRoutes().Export(context.Background(), storage)
+		assert.Nil(t, err)
+		firstHash := app.StaticRoutes().Manifest().Pages["/pages/first"].Hash
+
+		app.StaticRoutes().Watch(storage)
+		storage.puts = nil
+
+		titles["first"] = "First updated"
+
+		req := httptest.NewRequest(http.MethodPatch, "/api/pages/1", nil)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		app.StaticRoutes().Wait()
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.ElementsMatch(t, []string{"pages/first/index.html", "pages/second/index.html", "manifest.json"}, storage.puts)
+		assert.Equal(t, "<h1>First updated</h1>", string(storage.files["pages/first/index.html"]))
+
+		manifest := StaticManifest{}
+		assert.Nil(t, json.Unmarshal(storage.files["manifest.json"], &manifest))
+		assert.Len(t, manifest.Pages, 3)
+		assert.NotEqual(t, firstHash, manifest.Pages["/pages/first"].Hash)
+
+		t.Run("Should ignore the other resources", func(t *testing.T) {
+			assert.Nil(t, app.SetResource("authors", &resourceTestController{name: "authors"}, app.GetRouterGroup("api").Group("/authors")))
+			storage.puts = nil
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/authors/1", nil)
+			app.GetRouter().ServeHTTP(httptest.NewRecorder(), req)
+
+			app.GetEvents().MustTrigger(EventResourceUpdated, map[string]interface{}{"resource": "tags"})
+			app.StaticRoutes().Wait()
+
+			assert.Empty(t, storage.puts)
+		})
+	})
+}
+
+func TestTriggerResourceEvent(t *testing.T) {
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+
+	called := 0
+	app.GetEvents().On(EventResourceUpdated, event.ListenerFunc(func(e event.Event) error {
+		called++
+		return errors.New("listener error")
+	}))
+
+	t.Run("Should log the listener errors without panic", func(t *testing.T) {
+		c := app.GetRouter().NewContext(httptest.NewRequest(http.MethodPatch, "/api/pages/1", nil), httptest.NewRecorder())
+		c.Response().WriteHeader(http.StatusOK)
+
+		assert.NotPanics(t, func() {
+			triggerResourceEvent(c, EventResourceUpdated, "pages", nil)
+		})
+		assert.Equal(t, 1, called)
+	})
+}
+
+func TestStaticFileName(t *testing.T) {
+	assert.Equal(t, "index.html", staticFileName("/"))
+	assert.Equal(t, "about/index.html", staticFileName("/about/"))
+	assert.Equal(t, "feed.xml", staticFileName("/feed.xml"))
+	assert.Equal(t, "pages/a/index.html", staticFileName("/pages/a?page=2"))
+	assert.Equal(t, "", staticFileName("/pages/../../etc"))
+}
@@ -0,0 +1,112 @@
+package catu
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeStatic(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "public")
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "css"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "app.js"), []byte("console.log(1)"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "css", "site.css"), []byte("body{}"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "secret.txt"), []byte("secret"), 0644))
+
+	request := func(app App, path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Should serve the files with cache headers", func(t *testing.T) {
+		t.Setenv("STATIC_MAX_AGE", "600")
+		app := newTestApp(t)
+		assert.Nil(t, app.Bootstrap())
+		assert.Nil(t, app.ServeStatic("/public", dir))
+
+		rec := request(app, "/public/css/site.css", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "body{}", rec.Body.String())
+		assert.Equal(t, "text/css; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, "public, max-age=600", rec.Header().Get("Cache-Control"))
+
+		etag := rec.Header().Get("ETag")
+		assert.NotEmpty(t, etag)
+		rec = request(app, "/public/css/site.css", map[string]string{"If-None-Match": etag})
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Equal(t, "/public/css/site.css", app.AssetURL("css/site.css"))
+
+		t.Run("Should respond 404 for missing files and directories", func(t *testing.T) {
+			for _, path := range []string{"/public/missing.css", "/public/css"} {
+				rec := request(app, path, nil)
+				assert.Equal(t, http.StatusNotFound, rec.Code, path)
+				assert.Contains(t, rec.Body.String(), `"code":404`)
+			}
+		})
+
+		t.Run("Should reject directory traversal attempts", func(t *testing.T) {
+			for _, path := range []string{
+				"/public/../secret.txt",
+				"/public/%2e%2e/secret.txt",
+				"/public/css/..%2f..%2fsecret.txt",
+				"/public/css/%2e%2e%2f%2e%2e%2fsecret.txt",
+				"/public/..%5csecret.txt",
+			} {
+				rec := request(app, path, nil)
+				assert.Equal(t, http.StatusNotFound, rec.Code, path)
+				assert.NotContains(t, rec.Body.String(), "secret", path)
+			}
+		})
+	})
+
+	t.Run("Should return error for one missing directory", func(t *testing.T) {
+		app := newTestApp(t)
+		assert.NotNil(t, app.ServeStatic("/assets", filepath.Join(root, "missing")))
+	})
+
+	t.Run("Should serve the fingerprinted files", func(t *testing.T) {
+		t.Setenv("STATIC_FINGERPRINT", "true")
+		templates := t.TempDir()
+		assert.Nil(t, os.MkdirAll(filepath.Join(templates, "site"), 0755))
+		assert.Nil(t, os.WriteFile(filepath.Join(templates, "site", "assets.html"), []byte(`<link href="{{ assetURL "css/site.css" }}">`), 0644))
+
+		app := newTestApp(t)
+		t.Setenv("TEMPLATE_DISABLE", "false")
+		t.Setenv("TEMPLATE_FOLDER", templates)
+		assert.Nil(t, app.ServeStatic("public", dir))
+		assert.Nil(t, app.Bootstrap())
+
+		url := app.AssetURL("css/site.css")
+		assert.Regexp(t, regexp.MustCompile(`^/public/css/site-[0-9a-f]{8}\.css$`), url)
+		assert.Regexp(t, regexp.MustCompile(`^/public/app-[0-9a-f]{8}\.js$`), app.AssetURL("/app.js"))
+		assert.Equal(t, "/public/missing.css", app.AssetURL("missing.css"))
+
+		var buf bytes.Buffer
+		assert.Nil(t, app.RenderTemplate(&buf, "assets", nil))
+		assert.Equal(t, `<link href="`+url+`">`, buf.String())
+
+		rec := request(app, url, nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "body{}", rec.Body.String())
+		assert.Equal(t, "public, max-age=31536000, immutable", rec.Header().Get("Cache-Control"))
+
+		// the original name is still served
+		rec = request(app, "/public/css/site.css", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "public, max-age=86400", rec.Header().Get("Cache-Control"))
+	})
+}
@@ -0,0 +1,65 @@
+package catu
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// Storage stores the uploaded files and the static export files, ex: one local directory, one S3 bucket or one CDN.
+// Open returns one error wrapping fs.ErrNotExist for missing files
+type Storage interface {
+	Save(ctx context.Context, name string, content io.Reader, contentType string) error
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// DirStorage stores the files in one local directory
+type DirStorage struct {
+	Dir string
+}
+
+// Save writes one file in the directory
+func (s *DirStorage) Save(ctx context.Context, name string, content io.Reader, contentType string) error {
+	file := s.path(name)
+
+	return SideEffect(ctx, "storage", "write "+file, nil, func() error {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return errors.Wrap(err, "catu.DirStorage.Save error on create dir")
+		}
+
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return errors.Wrap(err, "catu.DirStorage.Save error on create file")
+		}
+
+		if _, err := io.Copy(f, content); err != nil {
+			f.Close()
+			os.Remove(file)
+			return errors.Wrap(err, "catu.DirStorage.Save error on write file")
+		}
+
+		return f.Close()
+	})
+}
+
+func (s *DirStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(s.path(name))
+}
+
+func (s *DirStorage) Delete(ctx context.Context, name string) error {
+	file := s.path(name)
+
+	return SideEffect(ctx, "storage", "delete "+file, nil, func() error {
+		return os.Remove(file)
+	})
+}
+
+// path returns the file path inside the directory, names with ".." can't leave it
+func (s *DirStorage) path(name string) string {
+	return filepath.Join(s.Dir, filepath.FromSlash(path.Clean("/"+name)))
+}
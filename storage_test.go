@@ -0,0 +1,28 @@
+package catu
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirStorage(t *testing.T) {
+	root := t.TempDir()
+	storage := &DirStorage{Dir: filepath.Join(root, "files")}
+
+	t.Run("Should keep the files with .. inside the directory", func(t *testing.T) {
+		assert.Nil(t, storage.Save(context.Background(), "../../outside.html", strings.NewReader("page"), "text/html"))
+
+		_, err := os.Stat(filepath.Join(root, "outside.html"))
+		assert.True(t, os.IsNotExist(err))
+
+		content, err := ioutil.ReadFile(filepath.Join(root, "files", "outside.html"))
+		assert.Nil(t, err)
+		assert.Equal(t, "page", string(content))
+	})
+}
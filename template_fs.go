@@ -0,0 +1,105 @@
+package catu
+
+import (
+	"html/template"
+	"io/fs"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// templateFS is one fs.FS with templates, ex: one plugin embed.FS
+type templateFS struct {
+	fsys fs.FS
+	// Folder inside fsys with the templates, removed from the template names
+	dir string
+	// Source name in logs
+	source string
+}
+
+// AddTemplateFS adds the .html templates of the prefix folder inside fsys, ex: one plugin embed.FS.
+// The template names are the same of the TEMPLATE_FOLDER, ex: with prefix "templates" the file
+// "templates/site/widget.html" is the "site/widget" template.
+//
+// The templates are loaded in LoadTemplates in this order, templates with the same name override the previous:
+//  1. the template FSes in the order they were added
+//  2. the TEMPLATE_FOLDER or the LoadTemplatesFromFS fs.FS
+//
+// So the app templates override the plugin templates. Add the template FSes before the Bootstrap
+func (r *AppStruct) AddTemplateFS(fsys fs.FS, prefix string) {
+	if prefix == "" {
+		prefix = "."
+	}
+
+	r.templateFSes = append(r.templateFSes, &templateFS{
+		fsys:   fsys,
+		dir:    prefix,
+		source: "fs:" + prefix,
+	})
+}
+
+// LoadTemplatesFromFS replaces the TEMPLATE_FOLDER with the templates of the root folder inside fsys, ex: one embed.FS,
+// so the binary don't need one templates folder.
+// Called before the Bootstrap it only sets the templates source, after it the current templates are kept on parse errors
+func (r *AppStruct) LoadTemplatesFromFS(fsys fs.FS, root string) error {
+	if root == "" {
+		root = "."
+	}
+
+	base := &templateFS{
+		fsys:   fsys,
+		dir:    root,
+		source: "fs:" + root,
+	}
+
+	// the template functions are set in the Bootstrap
+	if r.router.Renderer == nil {
+		r.templateBase = base
+		return nil
+	}
+
+	tpls, err := r.parseTemplatesWithBase(base)
+	if err != nil {
+		return errors.Wrap(err, "catu.App.LoadTemplatesFromFS error on parse templates")
+	}
+
+	r.templateBase = base
+	// embedded templates don't change
+	r.templateWatch = nil
+	r.setTemplates(tpls)
+	return nil
+}
+
+// parseTemplates parses the template FSes and the TEMPLATE_FOLDER, see AddTemplateFS
+func (r *AppStruct) parseTemplates() (*template.Template, error) {
+	return r.parseTemplatesWithBase(r.templateBase)
+}
+
+func (r *AppStruct) parseTemplatesWithBase(base *templateFS) (*template.Template, error) {
+	if base == nil {
+		rootDir := r.Configuration.GetF("TEMPLATE_FOLDER", "./themes")
+		base = &templateFS{
+			fsys:   os.DirFS(rootDir),
+			dir:    ".",
+			source: "disk:" + rootDir,
+		}
+	}
+
+	tpls := template.New("")
+	sources := map[string]string{}
+
+	for _, t := range append(r.templateFSes, base) {
+		err := findAndParseTemplates(tpls, t.fsys, t.dir, t.source, sources, r.templateFunctions)
+		if err != nil {
+			return tpls, errors.Wrap(err, "error on parse "+t.source+" templates")
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"count":   len(tpls.Templates()),
+		"sources": len(r.templateFSes) + 1,
+	}).Debug("catu.App.parseTemplates templates parsed")
+
+	return tpls, nil
+}
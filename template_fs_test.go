@@ -0,0 +1,85 @@
+package catu
+
+import (
+	"bytes"
+	"embed"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//go:embed testdata/templates testdata/plugins
+var testTemplatesFS embed.FS
+
+func TestTemplateFS(t *testing.T) {
+	newTemplateApp := func(t *testing.T, folder string) App {
+		app := newTestApp(t)
+		t.Setenv("TEMPLATE_DISABLE", "false")
+		t.Setenv("TEMPLATE_FOLDER", folder)
+		return app
+	}
+
+	render := func(t *testing.T, app App, name string) string {
+		var buf bytes.Buffer
+		assert.Nil(t, app.RenderTemplate(&buf, name, "item"))
+		return buf.String()
+	}
+
+	t.Run("Should load the templates from one embed.FS", func(t *testing.T) {
+		app := newTemplateApp(t, filepath.Join(t.TempDir(), "missing"))
+		assert.Nil(t, app.LoadTemplatesFromFS(testTemplatesFS, "testdata/templates"))
+		assert.Nil(t, app.Bootstrap())
+
+		assert.Equal(t, "embedded page <b>item</b>", render(t, app, "page"))
+		assert.Equal(t, "<b>item</b>", render(t, app, "partials/item"))
+	})
+
+	t.Run("Should reload the templates from one embed.FS after the bootstrap", func(t *testing.T) {
+		app := newTemplateApp(t, t.TempDir())
+		assert.Nil(t, app.Bootstrap())
+		assert.Nil(t, app.GetTemplates().Lookup("site/page"))
+
+		assert.Nil(t, app.LoadTemplatesFromFS(testTemplatesFS, "testdata/templates"))
+		assert.Equal(t, "embedded page <b>item</b>", render(t, app, "page"))
+
+		invalid := fstest.MapFS{"site/page.html": &fstest.MapFile{Data: []byte("{{ .")}}
+		assert.NotNil(t, app.LoadTemplatesFromFS(invalid, ""))
+		assert.Equal(t, "embedded page <b>item</b>", render(t, app, "page"))
+	})
+
+	t.Run("Should override the template FSes in the registration order", func(t *testing.T) {
+		app := newTemplateApp(t, t.TempDir())
+		app.AddTemplateFS(testTemplatesFS, "testdata/plugins/a")
+		app.AddTemplateFS(testTemplatesFS, "testdata/plugins/b")
+		assert.Nil(t, app.Bootstrap())
+
+		assert.Equal(t, "plugin b widget", render(t, app, "widget"))
+		assert.Equal(t, "plugin a page", render(t, app, "page"))
+	})
+
+	t.Run("Should override the template FSes with the TEMPLATE_FOLDER", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.Nil(t, os.MkdirAll(filepath.Join(dir, "site"), 0755))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "site", "page.html"), []byte("disk page"), 0644))
+
+		app := newTemplateApp(t, dir)
+		app.AddTemplateFS(testTemplatesFS, "testdata/plugins/a")
+		assert.Nil(t, app.Bootstrap())
+
+		assert.Equal(t, "disk page", render(t, app, "page"))
+		assert.Equal(t, "plugin a widget", render(t, app, "widget"))
+	})
+
+	t.Run("Should override the template FSes with the LoadTemplatesFromFS templates", func(t *testing.T) {
+		app := newTemplateApp(t, t.TempDir())
+		app.AddTemplateFS(testTemplatesFS, "testdata/plugins/a")
+		assert.Nil(t, app.LoadTemplatesFromFS(testTemplatesFS, "testdata/templates"))
+		assert.Nil(t, app.Bootstrap())
+
+		assert.Equal(t, "embedded page <b>item</b>", render(t, app, "page"))
+		assert.Equal(t, "plugin a widget", render(t, app, "widget"))
+	})
+}
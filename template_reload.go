@@ -0,0 +1,77 @@
+package catu
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// templateWatch detects template file changes with TEMPLATE_WATCH=true, use it only in development
+type templateWatch struct {
+	rootDir string
+
+	mu        sync.Mutex
+	signature uint64
+}
+
+// changed returns true if one template file was added, removed or modified since the last call
+func (w *templateWatch) changed() bool {
+	h := fnv.New64a()
+
+	filepath.Walk(w.rootDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.HasSuffix(path, ".html") {
+			fmt.Fprintf(h, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+		}
+		return nil
+	})
+
+	signature := h.Sum64()
+	if signature == w.signature {
+		return false
+	}
+
+	w.signature = signature
+	return true
+}
+
+// ReloadTemplates parses the templates again and replaces the current templates.
+// On parse errors the current templates are kept, so the server keeps rendering the last valid templates
+func (r *AppStruct) ReloadTemplates() error {
+	tpls, err := r.parseTemplates()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Error("catu.App.ReloadTemplates error on parse templates, keeping the current templates")
+		return errors.Wrap(err, "catu.App.ReloadTemplates error on parse templates")
+	}
+
+	r.setTemplates(tpls)
+
+	logrus.WithFields(logrus.Fields{
+		"count": len(tpls.Templates()),
+	}).Debug("catu.App.ReloadTemplates templates reloaded")
+
+	return nil
+}
+
+// reloadChangedTemplates reloads the templates before one render if TEMPLATE_WATCH is enabled and one file changed.
+// Concurrent renders wait for one reload
+func (r *AppStruct) reloadChangedTemplates() {
+	w := r.templateWatch
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.changed() {
+		r.ReloadTemplates()
+	}
+}
@@ -0,0 +1,88 @@
+package catu
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadTemplates(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "site", "page.html")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(file), 0755))
+
+	mtime := time.Now()
+	writeTemplate := func(content string) {
+		assert.Nil(t, os.WriteFile(file, []byte(content), 0644))
+		// the same second writes may keep the mtime
+		mtime = mtime.Add(time.Second)
+		assert.Nil(t, os.Chtimes(file, mtime, mtime))
+	}
+
+	render := func(app App) string {
+		var buf bytes.Buffer
+		assert.Nil(t, app.RenderTemplate(&buf, "page", "world"))
+		return buf.String()
+	}
+
+	writeTemplate("Hello {{ . }}")
+
+	app := newTestApp(t)
+	t.Setenv("TEMPLATE_DISABLE", "false")
+	t.Setenv("TEMPLATE_FOLDER", dir)
+	t.Setenv("TEMPLATE_WATCH", "true")
+	assert.Nil(t, app.Bootstrap())
+
+	t.Run("Should render the changed template file", func(t *testing.T) {
+		assert.Equal(t, "Hello world", render(app))
+
+		writeTemplate("Bye {{ . }}")
+		assert.Equal(t, "Bye world", render(app))
+	})
+
+	t.Run("Should keep the current templates on parse errors", func(t *testing.T) {
+		writeTemplate("Broken {{ .")
+		assert.Equal(t, "Bye world", render(app))
+		assert.NotNil(t, app.ReloadTemplates())
+		assert.Equal(t, "Bye world", render(app))
+
+		writeTemplate("Fixed {{ . }}")
+		assert.Equal(t, "Fixed world", render(app))
+	})
+
+	t.Run("Should render while the templates are reloaded", func(t *testing.T) {
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				assert.Equal(t, "Fixed world", render(app))
+			}()
+			go func() {
+				defer wg.Done()
+				assert.Nil(t, app.ReloadTemplates())
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("Should not reload without TEMPLATE_WATCH", func(t *testing.T) {
+		app := newTestApp(t)
+		t.Setenv("TEMPLATE_DISABLE", "false")
+		t.Setenv("TEMPLATE_FOLDER", dir)
+		t.Setenv("TEMPLATE_WATCH", "false")
+		assert.Nil(t, app.Bootstrap())
+		assert.Equal(t, "Fixed world", render(app))
+
+		writeTemplate("Changed {{ . }}")
+		assert.Equal(t, "Fixed world", render(app))
+
+		assert.Nil(t, app.ReloadTemplates())
+		assert.Equal(t, "Changed world", render(app))
+	})
+}
@@ -0,0 +1,336 @@
+package catu
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// echo context key with the request tenant
+const tenantKey = "catu.tenant"
+
+// Tenant database modes, see TENANT_DB_MODE
+const (
+	TenantDBShared   = "shared"
+	TenantDBDatabase = "database"
+)
+
+type tenantContextKey struct{}
+
+// Tenant is one customer of one multi-tenant app, see TENANT_RESOLVER
+type Tenant struct {
+	ID string `gorm:"column:id;primaryKey;size:100" json:"id"`
+	// Database name in the TENANT_DB_MODE=database, started with InitDatabase and the DB_URI_[NAME] configuration.
+	// Default the tenant ID
+	DBName string `gorm:"column:db_name;size:100" json:"dbName"`
+
+	db *gorm.DB
+}
+
+func (Tenant) TableName() string {
+	return "tenants"
+}
+
+// TenantResolver returns the tenant ID of one request, empty for requests without one tenant.
+// It runs before the routing, so it can rewrite the request path
+type TenantResolver interface {
+	ResolveTenant(c echo.Context) (string, error)
+}
+
+// SubdomainTenantResolver resolves the tenant from the first host label, ex: "acme" in acme.example.com
+type SubdomainTenantResolver struct {
+	// App domain, ex: "example.com". Empty to use the first label of hosts with 3 or more labels
+	Domain string
+}
+
+func (s *SubdomainTenantResolver) ResolveTenant(c echo.Context) (string, error) {
+	host := c.Request().Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	if s.Domain != "" {
+		sub := strings.TrimSuffix(host, "."+strings.ToLower(s.Domain))
+		if sub == host || strings.Contains(sub, ".") {
+			return "", nil
+		}
+		return sub, nil
+	}
+
+	labels := strings.Split(host, ".")
+	if len(labels) < 3 {
+		return "", nil
+	}
+	return labels[0], nil
+}
+
+// HeaderTenantResolver resolves the tenant from one request header, ex: X-Tenant-ID
+type HeaderTenantResolver struct {
+	Header string
+}
+
+func (h *HeaderTenantResolver) ResolveTenant(c echo.Context) (string, error) {
+	return c.Request().Header.Get(h.Header), nil
+}
+
+// PathTenantResolver resolves the tenant from the first path segment and removes it from the request path,
+// ex: /acme/api/articles is the /api/articles route of the "acme" tenant
+type PathTenantResolver struct{}
+
+func (p *PathTenantResolver) ResolveTenant(c echo.Context) (string, error) {
+	req := c.Request()
+	segments := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2)
+	if segments[0] == "" {
+		return "", nil
+	}
+
+	req.URL.Path = "/"
+	if len(segments) == 2 {
+		req.URL.Path += segments[1]
+	}
+	req.URL.RawPath = ""
+
+	return segments[0], nil
+}
+
+// SetTenantResolver replaces the TENANT_RESOLVER resolver, nil disables the tenancy. Set it before the Bootstrap
+func (r *AppStruct) SetTenantResolver(resolver TenantResolver) {
+	r.tenantResolver = resolver
+}
+
+func (r *AppStruct) GetTenantResolver() TenantResolver {
+	return r.tenantResolver
+}
+
+// RegisterTenant adds or replaces one tenant. In the TENANT_DB_MODE=database the tenant database is started
+// after the Bootstrap database, see InitDatabase
+func (r *AppStruct) RegisterTenant(tenant Tenant) error {
+	if tenant.ID == "" {
+		return errors.New("catu.App.RegisterTenant tenant ID is required")
+	}
+	if tenant.DBName == "" {
+		tenant.DBName = tenant.ID
+	}
+
+	if r.GetDB() != nil && r.Configuration.GetF("TENANT_DB_MODE", TenantDBShared) == TenantDBDatabase {
+		if r.GetNamedDB(tenant.DBName) == nil {
+			if err := r.InitDatabase(tenant.DBName, "", false); err != nil {
+				return errors.Wrap(err, "catu.App.RegisterTenant error on start the database of the tenant "+tenant.ID)
+			}
+		}
+		tenant.db = r.GetNamedDB(tenant.DBName)
+	}
+
+	r.tenantsMu.Lock()
+	defer r.tenantsMu.Unlock()
+
+	if r.tenants == nil {
+		r.tenants = map[string]*Tenant{}
+	}
+	r.tenants[tenant.ID] = &tenant
+
+	return nil
+}
+
+// GetTenant returns one registered tenant or nil
+func (r *AppStruct) GetTenant(id string) *Tenant {
+	r.tenantsMu.RLock()
+	defer r.tenantsMu.RUnlock()
+
+	return r.tenants[id]
+}
+
+// tenantIDs returns the registered tenant IDs sorted
+func (r *AppStruct) tenantIDs() []string {
+	r.tenantsMu.RLock()
+	defer r.tenantsMu.RUnlock()
+
+	ids := make([]string, 0, len(r.tenants))
+	for id := range r.tenants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// MigrateTenant runs the migrations in the database of one tenant with TENANT_DB_MODE=database: the SetModel models
+// with AUTO_MIGRATE=true, the audit_logs table and the "migrateTenant" event listeners, with the "tenant" and the "db".
+// The jobs, sessions, roles and tenants tables stay in the default database. Tenants in the shared database are skipped
+func (r *AppStruct) MigrateTenant(id string) error {
+	tenant := r.GetTenant(id)
+	if tenant == nil {
+		return errors.New("catu.App.MigrateTenant tenant not found: " + id)
+	}
+	if tenant.db == nil {
+		return nil
+	}
+
+	if r.Configuration.GetBool("AUTO_MIGRATE") {
+		if err := r.migrateModels(tenant.db); err != nil {
+			return errors.Wrap(err, "catu.App.MigrateTenant error on migrate the models of the tenant "+id)
+		}
+	}
+
+	// the audit logs are saved in the request transaction, in the tenant database
+	if r.hasAuditedResources() {
+		if err := tenant.db.AutoMigrate(&AuditLog{}); err != nil {
+			return errors.Wrap(err, "catu.App.MigrateTenant error on migrate the audit logs of the tenant "+id)
+		}
+	}
+
+	if err, _ := r.Events.Fire("migrateTenant", event.M{"app": r, "tenant": tenant, "db": tenant.db}); err != nil {
+		return errors.Wrap(err, "catu.App.MigrateTenant error on migrateTenant event of the tenant "+id)
+	}
+
+	return nil
+}
+
+// TenantDB returns the request tenant database in the TENANT_DB_MODE=database or the default database.
+// RequestContext.GetDB uses it, so the controllers, transactions and dry runs use the tenant database
+func (r *AppStruct) TenantDB(c echo.Context) *gorm.DB {
+	if tenant := GetTenant(c); tenant != nil && tenant.db != nil {
+		return tenant.db
+	}
+
+	return r.GetDB()
+}
+
+// GetTenant returns the request tenant, nil without the tenancy or in the public routes, ex: /health
+func GetTenant(c echo.Context) *Tenant {
+	if ctx, ok := c.(*RequestContext); ok && ctx.Tenant != nil {
+		return ctx.Tenant
+	}
+
+	tenant, _ := c.Get(tenantKey).(*Tenant)
+	return tenant
+}
+
+// TenantFromContext returns the tenant of one request context, ex: in the jobs enqueued by one request
+func TenantFromContext(ctx context.Context) *Tenant {
+	tenant, _ := ctx.Value(tenantContextKey{}).(*Tenant)
+	return tenant
+}
+
+// initTenancy creates the TENANT_RESOLVER resolver (subdomain, header or path, default disabled) and loads the tenants.
+// The TENANTS_SOURCE=config (default) loads the TENANTS list, ex: "acme:acme_db,globex", and
+// the TENANTS_SOURCE=db loads the tenants table, created in the "migrate" event
+func (r *AppStruct) initTenancy() error {
+	cfg := r.Configuration
+
+	if r.tenantResolver == nil {
+		switch resolver := cfg.Get("TENANT_RESOLVER"); resolver {
+		case "":
+			return nil
+		case "subdomain":
+			r.tenantResolver = &SubdomainTenantResolver{Domain: cfg.Get("TENANT_DOMAIN")}
+		case "header":
+			r.tenantResolver = &HeaderTenantResolver{Header: cfg.GetF("TENANT_HEADER", "X-Tenant-ID")}
+		case "path":
+			r.tenantResolver = &PathTenantResolver{}
+		default:
+			return errors.New("catu.App invalid TENANT_RESOLVER: " + resolver)
+		}
+	}
+
+	// the tenants registered before the Bootstrap start their databases
+	r.tenantsMu.RLock()
+	registered := make([]Tenant, 0, len(r.tenants))
+	for _, tenant := range r.tenants {
+		registered = append(registered, *tenant)
+	}
+	r.tenantsMu.RUnlock()
+	for _, tenant := range registered {
+		if err := r.RegisterTenant(tenant); err != nil {
+			return err
+		}
+	}
+
+	switch source := cfg.GetF("TENANTS_SOURCE", "config"); source {
+	case "config":
+		for _, item := range cfg.GetStringSlice("TENANTS", ",", nil) {
+			parts := strings.SplitN(strings.TrimSpace(item), ":", 2)
+			tenant := Tenant{ID: parts[0]}
+			if len(parts) == 2 {
+				tenant.DBName = parts[1]
+			}
+			if err := r.RegisterTenant(tenant); err != nil {
+				return err
+			}
+		}
+	case "db":
+		r.Events.On("migrate", event.ListenerFunc(func(e event.Event) error {
+			if err := r.GetDB().AutoMigrate(&Tenant{}); err != nil {
+				return err
+			}
+			return r.ReloadTenants()
+		}), event.Normal)
+
+		// the tenants table may not exist before the first migration
+		if err := r.ReloadTenants(); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err.Error(),
+			}).Error("catu.App.initTenancy error on load tenants")
+		}
+	default:
+		return errors.New("catu.App invalid TENANTS_SOURCE: " + source)
+	}
+
+	r.router.Pre(r.tenantMiddleware())
+
+	return nil
+}
+
+// ReloadTenants registers the tenants of the tenants table
+func (r *AppStruct) ReloadTenants() error {
+	tenants := []Tenant{}
+	if err := r.GetDB().Find(&tenants).Error; err != nil {
+		return errors.Wrap(err, "catu.App.ReloadTenants error on find tenants")
+	}
+
+	for _, tenant := range tenants {
+		if err := r.RegisterTenant(tenant); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tenantMiddleware resolves the request tenant before the routing, requests without one registered tenant respond 404.
+// The health routes don't have tenants
+func (r *AppStruct) tenantMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			switch c.Request().URL.Path {
+			case "/health", "/ready":
+				return next(c)
+			}
+
+			id, err := r.tenantResolver.ResolveTenant(c)
+			if err != nil {
+				return errors.Wrap(err, "catu.tenantMiddleware error on resolve tenant")
+			}
+
+			tenant := r.GetTenant(id)
+			if tenant == nil {
+				return &HTTPError{Code: http.StatusNotFound, Message: http.StatusText(http.StatusNotFound)}
+			}
+
+			c.Set(tenantKey, tenant)
+			req := c.Request()
+			c.SetRequest(req.WithContext(context.WithValue(req.Context(), tenantContextKey{}, tenant)))
+
+			return next(c)
+		}
+	}
+}
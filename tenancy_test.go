@@ -0,0 +1,196 @@
+package catu
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type tenancyTestNote struct {
+	ID    uint64 `gorm:"primaryKey" json:"id"`
+	Title string `json:"title"`
+}
+
+func TestTenancy(t *testing.T) {
+	newTenancyApp := func(t *testing.T) App {
+		app := newTestApp(t)
+		dir := t.TempDir()
+		t.Setenv("TENANT_RESOLVER", "subdomain")
+		t.Setenv("TENANT_DOMAIN", "example.com")
+		t.Setenv("TENANT_DB_MODE", "database")
+		t.Setenv("TENANTS", "acme:acme_db,globex")
+		t.Setenv("DB_URI_ACME_DB", filepath.Join(dir, "acme.sqlite"))
+		t.Setenv("DB_URI_GLOBEX", filepath.Join(dir, "globex.sqlite"))
+
+		app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+			app.GetRouter().GET("/notes", func(c echo.Context) error {
+				notes := []tenancyTestNote{}
+				if err := app.TenantDB(c).Order("id").Find(&notes).Error; err != nil {
+					return err
+				}
+				return c.JSON(http.StatusOK, notes)
+			})
+			app.GetRouter().POST("/notes", func(c echo.Context) error {
+				note := tenancyTestNote{Title: c.FormValue("title")}
+				if err := c.(*RequestContext).GetDB().Create(&note).Error; err != nil {
+					return err
+				}
+				return c.JSON(http.StatusCreated, note)
+			})
+			return nil
+		}))
+
+		assert.Nil(t, app.Bootstrap())
+		for _, name := range []string{"acme_db", "globex"} {
+			assert.Nil(t, app.GetNamedDB(name).AutoMigrate(&tenancyTestNote{}))
+		}
+		return app
+	}
+
+	request := func(app App, method, host, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Host = host
+		req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Should resolve the subdomain tenant and use its database", func(t *testing.T) {
+		app := newTenancyApp(t)
+
+		assert.Equal(t, http.StatusCreated, request(app, http.MethodPost, "acme.example.com", "/notes?title=acme-note").Code)
+		assert.Equal(t, http.StatusCreated, request(app, http.MethodPost, "globex.example.com:8080", "/notes?title=globex-note").Code)
+
+		rec := request(app, http.MethodGet, "acme.example.com", "/notes")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[{"id":1,"title":"acme-note"}]`, rec.Body.String())
+
+		rec = request(app, http.MethodGet, "GLOBEX.example.com", "/notes")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[{"id":1,"title":"globex-note"}]`, rec.Body.String())
+
+		var count int64
+		assert.Nil(t, app.GetDB().Migrator().AutoMigrate(&tenancyTestNote{}))
+		assert.Nil(t, app.GetDB().Model(&tenancyTestNote{}).Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("Should migrate the tenant databases", func(t *testing.T) {
+		t.Setenv("AUTO_MIGRATE", "true")
+		t.Setenv("SCHEMA_DRIFT_CHECK", "false")
+		app := newTestApp(t)
+		dir := t.TempDir()
+		t.Setenv("TENANT_RESOLVER", "header")
+		t.Setenv("TENANT_DB_MODE", "database")
+		t.Setenv("TENANTS", "acme:acme_db,globex")
+		t.Setenv("DB_URI_ACME_DB", filepath.Join(dir, "acme.sqlite"))
+		t.Setenv("DB_URI_GLOBEX", filepath.Join(dir, "globex.sqlite"))
+		app.SetModel("note", &tenancyTestNote{})
+
+		migrated := []string{}
+		app.GetEvents().On("migrateTenant", event.ListenerFunc(func(e event.Event) error {
+			migrated = append(migrated, e.Get("tenant").(*Tenant).ID)
+			return nil
+		}))
+		assert.Nil(t, app.Bootstrap())
+		assert.Nil(t, app.Migrate())
+
+		assert.Equal(t, []string{"acme", "globex"}, migrated)
+		for _, name := range []string{"default", "acme_db", "globex"} {
+			assert.True(t, app.GetNamedDB(name).Migrator().HasTable(&tenancyTestNote{}), name)
+		}
+
+		assert.Error(t, app.MigrateTenant("initech"))
+	})
+
+	t.Run("Should run the dry runs in the tenant database", func(t *testing.T) {
+		app := newTenancyApp(t)
+
+		req := httptest.NewRequest(http.MethodPost, "/notes", nil)
+		req.Header.Set(HeaderDryRun, "true")
+		rec := httptest.NewRecorder()
+		ctx := &RequestContext{}
+		ctx.fill(app, app.GetRouter().NewContext(req, rec))
+		ctx.Tenant = app.(*AppStruct).GetTenant("acme")
+		ctx.SetAuthenticatedUserAndFillRoles(&testUser{ID: "1", Roles: []string{"administrator"}})
+
+		err := DryRun(app)(func(c echo.Context) error {
+			if err := c.(*RequestContext).GetDB().Create(&tenancyTestNote{Title: "preview"}).Error; err != nil {
+				return err
+			}
+			return c.NoContent(http.StatusCreated)
+		})(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var count int64
+		assert.Nil(t, app.TenantDB(ctx).Model(&tenancyTestNote{}).Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("Should respond 404 to unknown tenants and keep the health check without tenant", func(t *testing.T) {
+		app := newTenancyApp(t)
+
+		assert.Equal(t, http.StatusNotFound, request(app, http.MethodGet, "initech.example.com", "/notes").Code)
+		assert.Equal(t, http.StatusNotFound, request(app, http.MethodGet, "example.com", "/notes").Code)
+		assert.Equal(t, http.StatusOK, request(app, http.MethodGet, "initech.example.com", "/health").Code)
+	})
+
+	t.Run("Should resolve the path tenant and remove it from the route path", func(t *testing.T) {
+		app := newTestApp(t)
+		t.Setenv("TENANTS", "acme")
+		app.SetTenantResolver(&PathTenantResolver{})
+
+		app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+			app.GetRouter().GET("/api/whoami", func(c echo.Context) error {
+				return c.String(http.StatusOK, GetTenant(c).ID+" "+TenantFromContext(c.Request().Context()).ID)
+			})
+			return nil
+		}))
+		assert.Nil(t, app.Bootstrap())
+
+		rec := request(app, http.MethodGet, "example.com", "/acme/api/whoami")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "acme acme", rec.Body.String())
+		assert.Equal(t, http.StatusNotFound, request(app, http.MethodGet, "example.com", "/globex/api/whoami").Code)
+	})
+
+	t.Run("Should render the tenant template overrides", func(t *testing.T) {
+		app := newTestApp(t)
+		t.Setenv("TEMPLATE_DISABLE", "false")
+		t.Setenv("TEMPLATE_FOLDER", t.TempDir())
+		t.Setenv("TENANTS", "acme,globex")
+		app.SetTenantResolver(&HeaderTenantResolver{Header: "X-Tenant-ID"})
+
+		templates := fstest.MapFS{
+			"site/home.html":              &fstest.MapFile{Data: []byte(`<h1>home</h1>`)},
+			"tenants/acme/site/home.html": &fstest.MapFile{Data: []byte(`<h1>acme home</h1>`)},
+		}
+		assert.Nil(t, app.LoadTemplatesFromFS(templates, ""))
+		app.SetDefaultLayout("")
+
+		app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+			app.GetRouter().GET("/", func(c echo.Context) error {
+				return RenderPage(c, http.StatusOK, "home", nil)
+			})
+			return nil
+		}))
+		assert.Nil(t, app.Bootstrap())
+
+		for tenant, body := range map[string]string{"acme": "<h1>acme home</h1>", "globex": "<h1>home</h1>"} {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(echo.HeaderAccept, echo.MIMETextHTML)
+			req.Header.Set("X-Tenant-ID", tenant)
+			rec := httptest.NewRecorder()
+			app.GetRouter().ServeHTTP(rec, req)
+			assert.Equal(t, body, rec.Body.String(), tenant)
+		}
+	})
+}
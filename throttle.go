@@ -0,0 +1,197 @@
+package catu
+
+import (
+	crand "crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// ThrottledError sources
+const (
+	ThrottleSourceRateLimit   = "rate_limit"
+	ThrottleSourceMaintenance = "maintenance"
+	ThrottleSourceCircuitOpen = "circuit_open"
+	ThrottleSourceConcurrency = "concurrency"
+)
+
+// ThrottledError is the error of all features that ask clients to retry later, responded with 429 or 503.
+// The error handler sets the Retry-After header and retry_after_seconds in the body with the same value
+type ThrottledError struct {
+	HTTPError
+	// Feature that throttled the request, ex: ThrottleSourceRateLimit
+	Source string
+	// Retry hint, the response adds one jitter to it
+	RetryAfter time.Duration
+}
+
+func NewThrottledError(code int, source string, retryAfter time.Duration) *ThrottledError {
+	return &ThrottledError{
+		HTTPError:  HTTPError{Code: code, Message: http.StatusText(code)},
+		Source:     source,
+		RetryAfter: retryAfter,
+	}
+}
+
+var (
+	throttleCountsMu sync.Mutex
+	throttleCounts   = map[string]int64{}
+)
+
+// ThrottleCounts returns the throttled responses count by source since the process start
+func ThrottleCounts() map[string]int64 {
+	throttleCountsMu.Lock()
+	defer throttleCountsMu.Unlock()
+
+	counts := make(map[string]int64, len(throttleCounts))
+	for k, v := range throttleCounts {
+		counts[k] = v
+	}
+	return counts
+}
+
+func countThrottle(source string) {
+	if source == "" {
+		source = "unknown"
+	}
+
+	throttleCountsMu.Lock()
+	throttleCounts[source]++
+	throttleCountsMu.Unlock()
+}
+
+// retryAfterSeconds returns the retry hint with one random jitter up to THROTTLE_JITTER_PERCENT (default 10) percent,
+// so throttled clients don't retry at the same second. Returns 0 for errors without retry hint
+func retryAfterSeconds(app App, retryAfter time.Duration) int64 {
+	if retryAfter <= 0 {
+		return 0
+	}
+
+	percent := app.GetConfiguration().GetInt64F("THROTTLE_JITTER_PERCENT", 10)
+	if percent > 0 {
+		retryAfter += time.Duration(rand.Int63n(int64(retryAfter)*percent/100 + 1))
+	}
+
+	return durationSeconds(retryAfter)
+}
+
+// throttledErrorHandler responds ThrottledErrors and other 429 errors
+func throttledErrorHandler(err error, ctx *RequestContext) error {
+	code := http.StatusTooManyRequests
+	source := ""
+	var retryAfter int64
+
+	if e, ok := err.(*ThrottledError); ok {
+		code = e.Code
+		source = e.Source
+		retryAfter = retryAfterSeconds(ctx.App, e.RetryAfter)
+	}
+
+	countThrottle(source)
+
+	logrus.WithFields(logrus.Fields{
+		"err":        fmt.Sprintf("%+v\n", err),
+		"code":       code,
+		"source":     source,
+		"retryAfter": retryAfter,
+		"path":       ctx.Path(),
+		"ip":         ctx.RealIP(),
+		"requestId":  GetRequestID(ctx),
+	}).Debug("catu.throttledErrorHandler running")
+
+	if retryAfter > 0 {
+		ctx.Response().Header().Set(echo.HeaderRetryAfter, strconv.FormatInt(retryAfter, 10))
+	}
+
+	switch ctx.GetResponseContentType() {
+	case "text/html":
+		ctx.Title = ctx.T("errors." + strconv.Itoa(code))
+		ctx.Set("retryAfterSeconds", retryAfter)
+
+		// the maintenance page is the site 503 page
+		name := "throttled"
+		if source == ThrottleSourceMaintenance {
+			name = "503"
+		}
+
+		message := translatedStatusText(ctx, code, throttledMessage(err, code))
+		if err := ctx.Render(code, name, &TemplateCTX{
+			Ctx:     ctx,
+			Message: message,
+		}); err != nil {
+			ctx.Logger().Error(err)
+			return ctx.HTML(code, throttledHTML(ctx, retryAfter, message))
+		}
+		return nil
+	default:
+		resp := NewErrorResponse(ctx, code, err)
+		resp.RetryAfterSeconds = retryAfter
+		if _, ok := err.(*ThrottledError); ok && code >= http.StatusInternalServerError {
+			resp.Message = throttledMessage(err, code)
+		}
+		return ctx.JSON(code, resp)
+	}
+}
+
+// throttledMessage returns the ThrottledError message, 503 messages are generic but the ThrottledError message
+// is safe to respond, ex: the maintenance message
+func throttledMessage(err error, code int) string {
+	if e, ok := err.(*ThrottledError); ok {
+		if m, ok := e.Message.(string); ok && m != "" {
+			return m
+		}
+	}
+
+	return http.StatusText(code)
+}
+
+// throttledHTML is the default page without one "throttled" template, with one countdown until the retry.
+// The countdown script has one nonce allowed in the page Content-Security-Policy
+func throttledHTML(ctx *RequestContext, retryAfter int64, message string) string {
+	title := html.EscapeString(ctx.Title)
+
+	body := ""
+	if message != "" && message != ctx.Title {
+		body = "<p>" + html.EscapeString(message) + "</p>"
+	}
+
+	if retryAfter <= 0 {
+		return "<!DOCTYPE html><html><head><title>" + title + "</title></head><body><h1>" + title + "</h1>" + body +
+			"<p>" + html.EscapeString(ctx.T("errors.retryLater")) + "</p></body></html>"
+	}
+
+	seconds := strconv.FormatInt(retryAfter, 10)
+	retryIn := strings.Replace(html.EscapeString(ctx.T("errors.retryIn", retryAfter)), seconds,
+		`<span id="retry-after">`+seconds+"</span>", 1)
+
+	script := ""
+	if nonce, err := newScriptNonce(); err == nil {
+		header := ctx.Response().Header()
+		if header.Get("Content-Security-Policy") == "" {
+			header.Set("Content-Security-Policy", "script-src 'nonce-"+nonce+"'")
+		}
+		script = `<script nonce="` + nonce + `">(function(){var s=` + seconds + `,e=document.getElementById("retry-after");` +
+			`var t=setInterval(function(){s--;e.textContent=s;if(s<=0)clearInterval(t)},1000)})()</script>`
+	}
+
+	return "<!DOCTYPE html><html><head><title>" + title + "</title>" +
+		`<meta http-equiv="refresh" content="` + seconds + `"></head><body><h1>` + title + "</h1>" + body +
+		"<p>" + retryIn + "</p>" + script + "</body></html>"
+}
+
+func newScriptNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
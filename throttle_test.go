@@ -0,0 +1,142 @@
+package catu
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottledError(t *testing.T) {
+	t.Setenv("RATE_LIMIT", "1")
+	t.Setenv("RATE_LIMIT_WINDOW", "20")
+
+	app := newTestApp(t)
+	// without the "throttled" template the HTML responses use the default page
+	t.Setenv("TEMPLATE_DISABLE", "false")
+	t.Setenv("TEMPLATE_FOLDER", t.TempDir())
+	assert.Nil(t, app.Bootstrap())
+
+	router := app.GetRouter()
+	router.GET("/maintenance", func(c echo.Context) error {
+		err := NewThrottledError(http.StatusServiceUnavailable, ThrottleSourceMaintenance, 60*time.Second)
+		err.Message = "Down for maintenance"
+		return err
+	})
+	router.GET("/upstream", func(c echo.Context) error {
+		return NewThrottledError(http.StatusServiceUnavailable, ThrottleSourceCircuitOpen, 30*time.Second)
+	})
+	router.GET("/busy", func(c echo.Context) error {
+		return NewThrottledError(http.StatusServiceUnavailable, ThrottleSourceConcurrency, 2*time.Second)
+	})
+	router.GET("/jsonapi", func(c echo.Context) error {
+		c.(*RequestContext).SetResponseContentType(JSONAPIContentType)
+		return NewThrottledError(http.StatusServiceUnavailable, ThrottleSourceConcurrency, 2*time.Second)
+	})
+
+	request := func(path, contentType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderContentType, contentType)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// the rate limiter accepts the first request
+	request("/api", echo.MIMEApplicationJSON)
+
+	sources := []struct {
+		source  string
+		path    string
+		code    int
+		hint    int64
+		message string
+	}{
+		{ThrottleSourceRateLimit, "/api", http.StatusTooManyRequests, 20, "Too Many Requests"},
+		{ThrottleSourceMaintenance, "/maintenance", http.StatusServiceUnavailable, 60, "Down for maintenance"},
+		{ThrottleSourceCircuitOpen, "/upstream", http.StatusServiceUnavailable, 30, "Service Unavailable"},
+		{ThrottleSourceConcurrency, "/busy", http.StatusServiceUnavailable, 2, "Service Unavailable"},
+	}
+
+	before := ThrottleCounts()
+
+	for _, s := range sources {
+		t.Run("Should respond the same retry hint in header and body for "+s.source, func(t *testing.T) {
+			rec := request(s.path, echo.MIMEApplicationJSON)
+			assert.Equal(t, s.code, rec.Code)
+
+			retryAfter, err := strconv.ParseInt(rec.Header().Get(echo.HeaderRetryAfter), 10, 64)
+			assert.Nil(t, err)
+			// 10% jitter
+			assert.GreaterOrEqual(t, retryAfter, s.hint)
+			assert.LessOrEqual(t, retryAfter, s.hint+s.hint/10+1)
+
+			body := ErrorResponse{}
+			assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, s.code, body.Code)
+			assert.Equal(t, s.message, body.Message)
+			assert.Equal(t, retryAfter, body.RetryAfterSeconds)
+
+			rec = request(s.path, echo.MIMETextHTML)
+			assert.Equal(t, s.code, rec.Code)
+			seconds := rec.Header().Get(echo.HeaderRetryAfter)
+			assert.Contains(t, rec.Body.String(), "<title>"+http.StatusText(s.code)+"</title>")
+			assert.Contains(t, rec.Body.String(), `Please try again in <span id="retry-after">`+seconds+`</span> seconds.`)
+			assert.Contains(t, rec.Body.String(), `<meta http-equiv="refresh" content="`+seconds+`">`)
+
+			// the countdown script nonce is allowed in the page CSP
+			nonce := strings.TrimSuffix(strings.TrimPrefix(rec.Header().Get("Content-Security-Policy"), "script-src 'nonce-"), "'")
+			assert.NotEmpty(t, nonce)
+			assert.Contains(t, rec.Body.String(), `<script nonce="`+nonce+`">`)
+		})
+	}
+
+	t.Run("Should count the throttled responses by source", func(t *testing.T) {
+		after := ThrottleCounts()
+		for _, s := range sources {
+			assert.Equal(t, before[s.source]+2, after[s.source], s.source)
+		}
+	})
+
+	t.Run("Should translate the default page", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/upstream", nil)
+		req.Header.Set(echo.HeaderContentType, echo.MIMETextHTML)
+		req.Header.Set("Accept-Language", "pt-BR")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Contains(t, rec.Body.String(), "<title>Serviço indisponível</title>")
+		seconds := rec.Header().Get(echo.HeaderRetryAfter)
+		assert.Contains(t, rec.Body.String(), `Tente novamente em <span id="retry-after">`+seconds+`</span> segundos.`)
+	})
+
+	t.Run("Should add the retry hint in JSON:API errors", func(t *testing.T) {
+		rec := request("/jsonapi", echo.MIMEApplicationJSON)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+		doc := JSONAPIErrorDocument{}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+		assert.Equal(t, rec.Header().Get(echo.HeaderRetryAfter), strconv.Itoa(int(doc.Errors[0].Meta["retry_after_seconds"].(float64))))
+	})
+
+	t.Run("Should respond 429 errors without retry hint", func(t *testing.T) {
+		router.GET("/plain-429", func(c echo.Context) error {
+			return echo.NewHTTPError(http.StatusTooManyRequests)
+		})
+
+		rec := request("/plain-429", echo.MIMEApplicationJSON)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "", rec.Header().Get(echo.HeaderRetryAfter))
+		assert.NotContains(t, rec.Body.String(), "retry_after_seconds")
+
+		rec = request("/plain-429", echo.MIMETextHTML)
+		assert.Contains(t, rec.Body.String(), "Please try again later.")
+	})
+}
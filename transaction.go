@@ -0,0 +1,157 @@
+package catu
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// MySQL deadlock error number, the transaction can run again
+const mysqlDeadlockError = 1213
+
+type txContextKey struct{}
+
+// errTxStatus rolls back the request transaction of one response without error and with one non 2xx status
+var errTxStatus = errors.New("catu.TransactionMiddleware non 2xx response")
+
+// Transaction runs fn in one database transaction, committed if fn returns nil and rolled back on errors and panics.
+// Deadlocks run fn again until DB_TRANSACTION_ATTEMPTS, so fn must only change the database.
+// Calls inside one transaction, with the tx context or one request with TransactionMiddleware, reuse the current transaction
+func (r *AppStruct) Transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return runTransaction(ctx, r.GetDB(), r.Configuration.GetIntF("DB_TRANSACTION_ATTEMPTS", 3), fn)
+}
+
+// runTransaction runs fn in one db transaction with the deadlock attempts
+func runTransaction(ctx context.Context, db *gorm.DB, attempts int, fn func(tx *gorm.DB) error) error {
+	if tx, ok := ctx.Value(txContextKey{}).(*gorm.DB); ok {
+		return recoverTransaction(tx, fn)
+	}
+	if isTransaction(db) {
+		return recoverTransaction(db.WithContext(ctx), fn)
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts || attempt == 1; attempt++ {
+		err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			tx = tx.WithContext(context.WithValue(ctx, txContextKey{}, tx))
+			return recoverTransaction(tx, fn)
+		})
+
+		if !isDeadlockError(err) {
+			return err
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"attempt": attempt,
+			"error":   err.Error(),
+		}).Warn("catu.runTransaction deadlock")
+	}
+
+	return err
+}
+
+// recoverTransaction returns the fn panics as errors, to roll back the transaction
+func recoverTransaction(tx *gorm.DB, fn func(tx *gorm.DB) error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = errors.Errorf("catu.Transaction panic: %v", v)
+		}
+	}()
+
+	return fn(tx)
+}
+
+// isTransaction returns true if db is one open transaction
+func isTransaction(db *gorm.DB) bool {
+	_, ok := db.Statement.ConnPool.(gorm.TxCommitter)
+	return ok
+}
+
+func isDeadlockError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDeadlockError
+}
+
+// GetTX returns the request transaction of the TransactionMiddleware or the request database, see RequestContext.GetDB.
+// Without the app context the request tenant database is used, see TenantDB
+func GetTX(c echo.Context) *gorm.DB {
+	if ctx, ok := c.(*RequestContext); ok {
+		return ctx.GetDB()
+	}
+
+	if db, ok := c.Get(requestDBKey).(*gorm.DB); ok {
+		return db
+	}
+
+	return handlerApp(c).TenantDB(c)
+}
+
+// TransactionMiddleware runs the POST, PUT, PATCH and DELETE requests in one database transaction, see GetTX.
+// The transaction is committed on 2xx responses and rolled back on errors and other status.
+// The response is buffered until the commit, so one commit error returns one error response.
+// Enable it in all routes with DB_REQUEST_TRANSACTIONS=true or use it in one route group
+func TransactionMiddleware(app App) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			switch c.Request().Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			default:
+				return next(c)
+			}
+
+			db := GetTX(c)
+			// one dry run or one parent group transaction
+			if isTransaction(db) {
+				return next(c)
+			}
+
+			res := c.Response()
+			w := res.Writer
+			buf := &dryRunResponseWriter{ResponseWriter: w}
+			res.Writer = buf
+
+			req := c.Request()
+			// the handler runs once, the deadlock attempts are only for App.Transaction
+			err := runTransaction(req.Context(), db, 1, func(tx *gorm.DB) error {
+				c.Set(requestDBKey, tx)
+				c.SetRequest(req.WithContext(tx.Statement.Context))
+
+				if err := next(c); err != nil {
+					return err
+				}
+				if res.Status < 200 || res.Status >= 300 {
+					return errTxStatus
+				}
+				return nil
+			})
+
+			res.Writer = w
+			c.Set(requestDBKey, db)
+			c.SetRequest(req)
+
+			if err != nil && err != errTxStatus {
+				// one commit error after the handler response
+				if res.Committed {
+					res.Committed = false
+					res.Size = 0
+				}
+				return err
+			}
+
+			if buf.code != 0 {
+				w.WriteHeader(buf.code)
+			}
+			_, err = w.Write(buf.body.Bytes())
+			return err
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package catu
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type txTestOrder struct {
+	ID    uint64 `gorm:"primaryKey"`
+	Title string
+}
+
+type txTestItem struct {
+	ID      uint64 `gorm:"primaryKey"`
+	OrderID uint64
+}
+
+func TestTransaction(t *testing.T) {
+	t.Setenv("DB_REQUEST_TRANSACTIONS", "true")
+	app := newTestApp(t)
+
+	var nestedSameTx bool
+
+	app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+		router := app.GetRouter()
+		// creates one order and one item, the status query param sets the response status
+		router.POST("/orders", func(c echo.Context) error {
+			tx := GetTX(c)
+			order := txTestOrder{Title: c.QueryParam("title")}
+			if err := tx.Create(&order).Error; err != nil {
+				return err
+			}
+
+			err := app.Transaction(c.Request().Context(), func(nested *gorm.DB) error {
+				nestedSameTx = nested.Statement.ConnPool == tx.Statement.ConnPool
+				return nested.Create(&txTestItem{OrderID: order.ID}).Error
+			})
+			if err != nil {
+				return err
+			}
+
+			switch c.QueryParam("result") {
+			case "error":
+				return errors.New("item validation failed")
+			case "bad":
+				return c.NoContent(http.StatusBadRequest)
+			}
+			return c.NoContent(http.StatusCreated)
+		})
+		return nil
+	}))
+
+	assert.Nil(t, app.Bootstrap())
+	db := app.GetDB()
+	assert.Nil(t, db.AutoMigrate(&txTestOrder{}, &txTestItem{}))
+
+	count := func(model interface{}) int64 {
+		var n int64
+		assert.Nil(t, db.Model(model).Count(&n).Error)
+		return n
+	}
+
+	post := func(query string) int {
+		req := httptest.NewRequest(http.MethodPost, "/orders?"+query, nil)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	t.Run("Should commit the request transaction on 2xx responses", func(t *testing.T) {
+		assert.Equal(t, http.StatusCreated, post("title=first"))
+		assert.True(t, nestedSameTx)
+		assert.Equal(t, int64(1), count(&txTestOrder{}))
+		assert.Equal(t, int64(1), count(&txTestItem{}))
+	})
+
+	t.Run("Should roll back the request transaction on errors", func(t *testing.T) {
+		assert.Equal(t, http.StatusInternalServerError, post("title=second&result=error"))
+		assert.Equal(t, int64(1), count(&txTestOrder{}))
+		assert.Equal(t, int64(1), count(&txTestItem{}))
+	})
+
+	t.Run("Should roll back the request transaction on non 2xx status", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, post("title=third&result=bad"))
+		assert.Equal(t, int64(1), count(&txTestOrder{}))
+	})
+
+	t.Run("Should roll back panics", func(t *testing.T) {
+		err := app.Transaction(context.Background(), func(tx *gorm.DB) error {
+			assert.Nil(t, tx.Create(&txTestOrder{Title: "panic"}).Error)
+			panic("boom")
+		})
+		assert.EqualError(t, err, "catu.Transaction panic: boom")
+		assert.Equal(t, int64(1), count(&txTestOrder{}))
+	})
+
+	t.Run("Should retry deadlocks", func(t *testing.T) {
+		t.Setenv("DB_TRANSACTION_ATTEMPTS", "3")
+
+		attempts := 0
+		err := app.Transaction(context.Background(), func(tx *gorm.DB) error {
+			attempts++
+			if attempts < 3 {
+				return &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+			}
+			return tx.Create(&txTestOrder{Title: "retried"}).Error
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, int64(2), count(&txTestOrder{}))
+
+		attempts = 0
+		err = app.Transaction(context.Background(), func(tx *gorm.DB) error {
+			attempts++
+			return &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+		})
+		assert.NotNil(t, err)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("Should use the request tenant database without the app context", func(t *testing.T) {
+		tenantDB := db.Session(&gorm.Session{})
+
+		c := app.GetRouter().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+		assert.Same(t, db, GetTX(c))
+
+		c.Set(tenantKey, &Tenant{ID: "acme", db: tenantDB})
+		assert.Same(t, tenantDB, GetTX(c))
+	})
+}
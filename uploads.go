@@ -0,0 +1,173 @@
+package catu
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// UploadsURLPath is the route path prefix of the uploaded files, see UploadedFileHandler
+const UploadsURLPath = "/public/files"
+
+// http.DetectContentType reads up to 512 bytes
+const sniffLen = 512
+
+// GetUploadStorage returns the uploads storage, default one DirStorage in the UPLOAD_PATH directory
+func (r *AppStruct) GetUploadStorage() Storage {
+	return r.uploadStorage
+}
+
+// SetUploadStorage replaces the UPLOAD_PATH storage, ex: with one S3 storage plugin
+func (r *AppStruct) SetUploadStorage(s Storage) {
+	r.uploadStorage = s
+}
+
+type UploadOptions struct {
+	// Max file size in bytes, default UPLOAD_MAX_SIZE or 10MB
+	MaxSize int64
+	// MIME types sniffed from the file content, ex: "image/png" or "image/*". Empty allows all types
+	AllowedTypes []string
+	// Storage dir of the file, ex: "avatars"
+	Dir string
+	// Default: the app upload storage, see App.SetUploadStorage
+	Storage Storage
+}
+
+// UploadedFile is the metadata of one stored upload
+type UploadedFile struct {
+	// Storage name, ex: "avatars/Xv3...q.png"
+	Name string `json:"name"`
+	// Client file name
+	OriginalName string `json:"originalName"`
+	Size         int64  `json:"size"`
+	ContentType  string `json:"contentType"`
+	// Path of the UploadedFileHandler route, ex: "/public/files/avatars/Xv3...q.png"
+	URL string `json:"url"`
+}
+
+var uploadExtRegex = regexp.MustCompile(`^\.[a-z0-9]{1,10}$`)
+
+// HandleUpload validates and stores the multipart file of the form field with one unique name.
+// The MIME type is sniffed from the file content, the client file name and content type are ignored.
+// Invalid files return ValidationFieldErrors, responded as validation errors
+func HandleUpload(c echo.Context, field string, opts UploadOptions) (*UploadedFile, error) {
+	app := handlerApp(c)
+
+	if opts.MaxSize == 0 {
+		opts.MaxSize = app.GetConfiguration().GetInt64F("UPLOAD_MAX_SIZE", 10<<20)
+	}
+	if opts.Storage == nil {
+		opts.Storage = app.GetUploadStorage()
+	}
+
+	fh, err := c.FormFile(field)
+	if err != nil {
+		if err == http.ErrMissingFile || err == http.ErrNotMultipart {
+			return nil, uploadError(field, "required", "", "", "file is required")
+		}
+		return nil, &HTTPError{Code: http.StatusBadRequest, Message: "Invalid multipart form", Internal: err}
+	}
+
+	if fh.Size > opts.MaxSize {
+		return nil, uploadError(field, "max", strconv.FormatInt(opts.MaxSize, 10), strconv.FormatInt(fh.Size, 10),
+			"file size must be at most "+strconv.FormatInt(opts.MaxSize, 10)+" bytes")
+	}
+
+	file, err := fh.Open()
+	if err != nil {
+		return nil, errors.Wrap(err, "catu.HandleUpload error on open file")
+	}
+	defer file.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, errors.Wrap(err, "catu.HandleUpload error on read file")
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	if !uploadTypeAllowed(mediaType, opts.AllowedTypes) {
+		return nil, uploadError(field, "mimetype", strings.Join(opts.AllowedTypes, " "), mediaType,
+			"file type "+mediaType+" is not allowed")
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	name := path.Join(opts.Dir, id+uploadExt(fh.Filename, mediaType))
+
+	// the sniffed bytes and the rest of the file, without loading it in memory
+	content := io.MultiReader(bytes.NewReader(head), file)
+	if err := opts.Storage.Save(c.Request().Context(), name, content, contentType); err != nil {
+		return nil, errors.Wrap(err, "catu.HandleUpload error on save file")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"name":        name,
+		"size":        fh.Size,
+		"contentType": contentType,
+		"requestId":   GetRequestID(c),
+	}).Debug("catu.HandleUpload file stored")
+
+	return &UploadedFile{
+		Name:         name,
+		OriginalName: fh.Filename,
+		Size:         fh.Size,
+		ContentType:  contentType,
+		URL:          UploadsURLPath + "/" + name,
+	}, nil
+}
+
+func uploadError(field, tag, param, value, message string) error {
+	return ValidationFieldErrors{{
+		Field:   field,
+		Tag:     tag,
+		Param:   param,
+		Value:   value,
+		Message: message,
+	}}
+}
+
+func uploadTypeAllowed(mediaType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	for _, t := range allowed {
+		if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*"))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// uploadExt returns the client file extension if it matches the sniffed type, the file route responds the extension type
+func uploadExt(filename, mediaType string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if !uploadExtRegex.MatchString(ext) {
+		return ""
+	}
+
+	extType, _, _ := mime.ParseMediaType(mime.TypeByExtension(ext))
+	if extType != mediaType {
+		return ""
+	}
+
+	return ext
+}
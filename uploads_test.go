@@ -0,0 +1,130 @@
+package catu
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// 1x1 PNG
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89" +
+	"\x00\x00\x00\rIDATx\x9cc\xf8\x0f\x00\x00\x01\x01\x00\x05\x18\xd8N\x00\x00\x00\x00IEND\xaeB`\x82")
+
+func TestHandleUpload(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("UPLOAD_PATH", dir)
+	t.Setenv("UPLOAD_MAX_SIZE", "1024")
+	app := newTestApp(t)
+	assert.Nil(t, app.Bootstrap())
+
+	app.GetRouter().POST("/avatars", func(c echo.Context) error {
+		file, err := HandleUpload(c, "avatar", UploadOptions{
+			AllowedTypes: []string{"image/*"},
+			Dir:          "avatars",
+		})
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusCreated, file)
+	})
+
+	upload := func(field, filename string, content []byte) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		w := multipart.NewWriter(body)
+		if field != "" {
+			part, _ := w.CreateFormFile(field, filename)
+			part.Write(content)
+		}
+		w.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/avatars", body)
+		req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+		req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	validationErrors := func(rec *httptest.ResponseRecorder) *ErrorResponse {
+		resp := &ErrorResponse{}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), resp))
+		return resp
+	}
+
+	t.Run("Should store one valid file", func(t *testing.T) {
+		rec := upload("avatar", "me.PNG", testPNG)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		file := &UploadedFile{}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), file))
+		assert.True(t, strings.HasPrefix(file.Name, "avatars/"))
+		assert.True(t, strings.HasSuffix(file.Name, ".png"))
+		assert.Equal(t, "me.PNG", file.OriginalName)
+		assert.Equal(t, int64(len(testPNG)), file.Size)
+		assert.Equal(t, "image/png", file.ContentType)
+		assert.Equal(t, "/public/files/"+file.Name, file.URL)
+
+		stored, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(file.Name)))
+		assert.Nil(t, err)
+		assert.Equal(t, testPNG, stored)
+
+		t.Run("Should serve the file in the public files route", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, file.URL, nil)
+			rec := httptest.NewRecorder()
+			app.GetRouter().ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "image/png", rec.Header().Get(echo.HeaderContentType))
+			assert.Equal(t, testPNG, rec.Body.Bytes())
+
+			for _, path := range []string{"/public/files/avatars/missing.png", "/public/files/../" + filepath.Base(dir)} {
+				req = httptest.NewRequest(http.MethodGet, path, nil)
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+				rec = httptest.NewRecorder()
+				app.GetRouter().ServeHTTP(rec, req)
+				assert.Equal(t, http.StatusNotFound, rec.Code, path)
+			}
+		})
+	})
+
+	t.Run("Should validate the sniffed type", func(t *testing.T) {
+		rec := upload("avatar", "script.png", []byte("<html><script>alert(1)</script></html>"))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, []*ValidationFieldError{{
+			Field:   "avatar",
+			Tag:     "mimetype",
+			Param:   "image/*",
+			Value:   "text/html",
+			Message: "file type text/html is not allowed",
+		}}, validationErrors(rec).Errors)
+	})
+
+	t.Run("Should validate the max size", func(t *testing.T) {
+		rec := upload("avatar", "big.png", append(testPNG, make([]byte, 1024)...))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		resp := validationErrors(rec)
+		assert.Equal(t, "Bad Request", resp.Message)
+		assert.Equal(t, "max", resp.Errors[0].Tag)
+		assert.Equal(t, "1024", resp.Errors[0].Param)
+	})
+
+	t.Run("Should require the file", func(t *testing.T) {
+		rec := upload("", "", nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "required", validationErrors(rec).Errors[0].Tag)
+	})
+
+	t.Run("Should drop one extension that doesn't match the content", func(t *testing.T) {
+		assert.Equal(t, "", uploadExt("photo.html", "image/png"))
+		assert.Equal(t, "", uploadExt("photo", "image/png"))
+		assert.Equal(t, ".jpg", uploadExt("photo.JPG", "image/jpeg"))
+	})
+}
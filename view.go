@@ -5,10 +5,10 @@ import (
 	"fmt"
 	"html/template"
 	"io"
-	"io/ioutil"
+	"io/fs"
 	"math"
-	"os"
-	"path/filepath"
+	"net/http"
+	"path"
 	"strconv"
 	"strings"
 
@@ -27,10 +27,14 @@ type TemplateCTX struct {
 	Ctx         interface{}
 	Record      interface{}
 	Records     interface{}
+	// The rendered content template in the layout, {{ .Content }}, and the rendered layout in the "html" template
+	Content template.HTML
+	// Error pages message, ex: the maintenance message in the site/503 template
+	Message string
 }
 
 type TemplateRenderer struct {
-	templates *template.Template
+	app *AppStruct
 }
 
 func (t *TemplateRenderer) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
@@ -48,10 +52,12 @@ func (t *TemplateRenderer) Render(w io.Writer, name string, data interface{}, c
 		htmlContext := data.(*TemplateCTX)
 		htmlContext.EchoContext = c
 
+		t.app.reloadChangedTemplates()
+
 		logrus.WithFields(logrus.Fields{
 			"name":          name,
 			"htmlContext":   htmlContext,
-			"len templates": len(t.templates.Templates()),
+			"len templates": len(t.app.GetTemplates().Templates()),
 		}).Debug("Render")
 
 		ctx := htmlContext.Ctx.(*RequestContext)
@@ -62,25 +68,37 @@ func (t *TemplateRenderer) Render(w io.Writer, name string, data interface{}, c
 			logrus.WithFields(logrus.Fields{
 				"error": fmt.Sprintf("%+v\n", errors.Wrap(err, "catu.theme.Render error on render template")),
 				"name":  name,
+				"theme": ctx.Theme,
 			}).Error("catu.theme.Render error on execute template")
-			return err
+			return errors.Wrap(err, "catu.theme.Render error on execute template "+name)
 		}
 
 		ctx.Content = template.HTML(contentBuffer.String())
+		htmlContext.Content = ctx.Content
+
+		// one empty layout renders only the content template
+		if ctx.Layout != "" {
+			var layoutBuffer bytes.Buffer
+			err = ctx.RenderTemplate(&layoutBuffer, ctx.Layout, htmlContext)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"error":  err,
+					"name":   name,
+					"theme":  ctx.Theme,
+					"layout": ctx.Layout,
+				}).Error("catu.theme.Render error on execute layout template")
+				return errors.Wrap(err, "catu.theme.Render error on execute layout template "+ctx.Layout)
+			}
 
-		var layoutBuffer bytes.Buffer
-		err = ctx.RenderTemplate(&layoutBuffer, ctx.Layout, htmlContext)
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"error":  err,
-				"name":   name,
-				"theme":  ctx.Theme,
-				"layout": ctx.Layout,
-			}).Error("catu.theme.Render error on execute layout template")
-			return err
+			ctx.Content = template.HTML(layoutBuffer.String())
+			htmlContext.Content = ctx.Content
 		}
 
-		ctx.Content = template.HTML(layoutBuffer.String())
+		// themes without the "html" document template respond the layout
+		if t.app.GetTemplates().Lookup(path.Join(ctx.Theme, "html")) == nil {
+			_, err = io.WriteString(w, string(ctx.Content))
+			return err
+		}
 
 		return ctx.RenderTemplate(w, "html", htmlContext)
 	}
@@ -88,36 +106,119 @@ func (t *TemplateRenderer) Render(w io.Writer, name string, data interface{}, c
 	return nil
 }
 
-func findAndParseTemplates(rootDir string, funcMap template.FuncMap) (*template.Template, error) {
-	cleanRoot := filepath.Clean(rootDir)
-	pfx := len(cleanRoot) + 1
-	root := template.New("")
+// RenderPage renders one content template inside the request layout, see App.SetDefaultLayout and RequestContext.Layout.
+// The data is the TemplateCTX.Record, or one *TemplateCTX used as is, and the layout gets the rendered content in
+// {{ .Content }}. Requests without one HTML response type respond data as JSON, ex:
+//
+//	return catu.RenderPage(c, http.StatusOK, "articles/view", article)
+//
+// Template errors respond 500 and are logged with the template name
+func RenderPage(c echo.Context, status int, templateName string, data interface{}) error {
+	ctx, ok := c.(*RequestContext)
+	if !ok || !strings.HasPrefix(ctx.GetResponseContentType(), echo.MIMETextHTML) {
+		if htmlContext, ok := data.(*TemplateCTX); ok {
+			data = htmlContext.Record
+		}
+		return c.JSON(status, data)
+	}
 
-	err := filepath.Walk(cleanRoot, func(path string, info os.FileInfo, e1 error) error {
-		if info != nil && !info.IsDir() && strings.HasSuffix(path, ".html") {
-			if e1 != nil {
-				return e1
-			}
+	htmlContext, ok := data.(*TemplateCTX)
+	if !ok {
+		htmlContext = &TemplateCTX{Record: data}
+	}
+	htmlContext.Ctx = ctx
 
-			b, e2 := ioutil.ReadFile(path)
-			if e2 != nil {
-				return e2
-			}
+	if err := c.Render(status, templateName, htmlContext); err != nil {
+		return &HTTPError{
+			Code:     http.StatusInternalServerError,
+			Message:  http.StatusText(http.StatusInternalServerError),
+			Internal: err,
+		}
+	}
+
+	return nil
+}
+
+// templatePartial is the "partial" template function, it renders one theme template with only the data.
+// The request context, passed after the data or as the data TemplateCTX, selects the request theme and the
+// tenant template overrides, without it the app theme is used, ex:
+//
+//	{{ partial "partials/tag" .Record.Tag .Ctx }}
+//	{{ partial "partials/header" . }}
+func (r *AppStruct) templatePartial(name string, data interface{}, args ...interface{}) (template.HTML, error) {
+	templateName := path.Join(r.Theme, name)
+	if ctx := partialRequestContext(data, args); ctx != nil {
+		templateName = ctx.templateName(name)
+	}
+
+	var htmlBuffer bytes.Buffer
+	if err := r.GetTemplates().ExecuteTemplate(&htmlBuffer, templateName, data); err != nil {
+		return "", errors.Wrap(err, "catu.partial error on render template "+name)
+	}
 
-			name := path[pfx:]
-			name = strings.Replace(name, ".html", "", 1)
+	return template.HTML(htmlBuffer.String()), nil
+}
 
-			t := root.New(name).Funcs(funcMap)
-			_, e2 = t.Parse(string(b))
-			if e2 != nil {
-				return e2
+// partialRequestContext returns the request context of the partial arguments or of one TemplateCTX data
+func partialRequestContext(data interface{}, args []interface{}) *RequestContext {
+	for _, v := range append(args, data) {
+		switch v := v.(type) {
+		case *RequestContext:
+			return v
+		case *TemplateCTX:
+			if ctx, ok := v.Ctx.(*RequestContext); ok {
+				return ctx
 			}
 		}
+	}
+	return nil
+}
+
+// findAndParseTemplates parses the .html files of one fs.FS dir, the template names are the file paths relative to dir without
+// the extension, ex: "site/layouts/default". The files override the templates with the same name in tpls, see App.AddTemplateFS
+func findAndParseTemplates(tpls *template.Template, fsys fs.FS, dir, source string, sources map[string]string, funcMap template.FuncMap) error {
+	dir = path.Clean(dir)
+
+	return fs.WalkDir(fsys, dir, func(file string, d fs.DirEntry, e1 error) error {
+		// missing folders have no templates
+		if d == nil {
+			return nil
+		}
+		if e1 != nil {
+			return e1
+		}
+		if d.IsDir() || !strings.HasSuffix(file, ".html") {
+			return nil
+		}
+
+		b, e2 := fs.ReadFile(fsys, file)
+		if e2 != nil {
+			return e2
+		}
+
+		name := file
+		if dir != "." {
+			name = file[len(dir)+1:]
+		}
+		name = strings.Replace(name, ".html", "", 1)
+
+		if previous, ok := sources[name]; ok {
+			logrus.WithFields(logrus.Fields{
+				"name":       name,
+				"source":     source,
+				"overridden": previous,
+			}).Debug("catu.findAndParseTemplates template overridden")
+		}
+		sources[name] = source
+
+		t := tpls.New(name).Funcs(funcMap)
+		_, e2 = t.Parse(string(b))
+		if e2 != nil {
+			return e2
+		}
 
 		return nil
 	})
-
-	return root, err
 }
 
 func renderPager(ctx *RequestContext, r *pagination.Pager, queryString string) template.HTML {
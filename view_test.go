@@ -0,0 +1,98 @@
+package catu
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type renderPageTestArticle struct {
+	Title string `json:"title"`
+	Tag   string `json:"tag"`
+}
+
+func TestRenderPage(t *testing.T) {
+	app := newTestApp(t)
+	t.Setenv("TEMPLATE_DISABLE", "false")
+	t.Setenv("TEMPLATE_FOLDER", t.TempDir())
+
+	templates := fstest.MapFS{
+		"site/layouts/main.html":    &fstest.MapFile{Data: []byte(`<main>{{ .Content }}</main>`)},
+		"site/layouts/bare.html":    &fstest.MapFile{Data: []byte(`<div class="bare">{{ .Content }}</div>`)},
+		"site/articles/view.html":   &fstest.MapFile{Data: []byte(`<h1>{{ .Record.Title }}</h1>{{ partial "partials/tag" .Record.Tag }}`)},
+		"site/partials/tag.html":    &fstest.MapFile{Data: []byte(`<span>{{ . }}</span>`)},
+		"site/articles/broken.html": &fstest.MapFile{Data: []byte(`{{ partial "partials/missing" . }}`)},
+		"dark/articles/view.html":   &fstest.MapFile{Data: []byte(`{{ partial "partials/tag" .Record.Tag .Ctx }}{{ partial "partials/title" . }}`)},
+		"dark/partials/tag.html":    &fstest.MapFile{Data: []byte(`<em>{{ . }}</em>`)},
+		"dark/partials/title.html":  &fstest.MapFile{Data: []byte(`<h2>{{ .Record.Title }}</h2>`)},
+		"dark/layouts/main.html":    &fstest.MapFile{Data: []byte(`<main class="dark">{{ .Content }}</main>`)},
+	}
+	assert.Nil(t, app.LoadTemplatesFromFS(templates, ""))
+	app.SetDefaultLayout("layouts/main")
+
+	article := &renderPageTestArticle{Title: "Hello <World>", Tag: "news"}
+
+	app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+		router := app.GetRouter()
+		router.GET("/articles/1", func(c echo.Context) error {
+			if c.QueryParam("layout") != "" {
+				c.(*RequestContext).Layout = c.QueryParam("layout")
+			}
+			if c.QueryParam("theme") != "" {
+				c.(*RequestContext).Theme = c.QueryParam("theme")
+			}
+			return RenderPage(c, http.StatusOK, "articles/view", article)
+		})
+		router.GET("/articles/missing", func(c echo.Context) error {
+			return RenderPage(c, http.StatusOK, "articles/missing", article)
+		})
+		router.GET("/articles/broken", func(c echo.Context) error {
+			return RenderPage(c, http.StatusOK, "articles/broken", article)
+		})
+		return nil
+	}))
+
+	assert.Nil(t, app.Bootstrap())
+
+	request := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderAccept, accept)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Should render the content inside the layout with the partials", func(t *testing.T) {
+		rec := request("/articles/1", echo.MIMETextHTML)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `<main><h1>Hello &lt;World&gt;</h1><span>news</span></main>`, rec.Body.String())
+	})
+
+	t.Run("Should use the request layout", func(t *testing.T) {
+		rec := request("/articles/1?layout=layouts/bare", echo.MIMETextHTML)
+		assert.Equal(t, `<div class="bare"><h1>Hello &lt;World&gt;</h1><span>news</span></div>`, rec.Body.String())
+	})
+
+	t.Run("Should render the partials of the request theme", func(t *testing.T) {
+		rec := request("/articles/1?theme=dark", echo.MIMETextHTML)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `<main class="dark"><em>news</em><h2>Hello &lt;World&gt;</h2></main>`, rec.Body.String())
+	})
+
+	t.Run("Should respond JSON without one HTML response type", func(t *testing.T) {
+		rec := request("/articles/1", echo.MIMEApplicationJSON)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"title":"Hello <World>","tag":"news"}`, rec.Body.String())
+	})
+
+	t.Run("Should respond 500 with missing templates", func(t *testing.T) {
+		assert.Equal(t, http.StatusInternalServerError, request("/articles/missing", echo.MIMETextHTML).Code)
+		assert.Equal(t, http.StatusInternalServerError, request("/articles/broken", echo.MIMETextHTML).Code)
+		assert.Equal(t, http.StatusInternalServerError, request("/articles/1?layout=layouts/missing", echo.MIMETextHTML).Code)
+	})
+}
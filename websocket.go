@@ -0,0 +1,363 @@
+package catu
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gookit/event"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// messages queued for one connection, slower clients are disconnected
+const wsSendBufferSize = 32
+
+// WSMessage is the JSON envelope of the WebSocket messages, ex: {"type":"notification","data":{"id":1}}
+type WSMessage struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Bind decodes the message data in v
+func (m *WSMessage) Bind(v interface{}) error {
+	return json.Unmarshal(m.Data, v)
+}
+
+// WSHandler handles one message received from one connection, returned errors close the connection
+type WSHandler func(conn *WSConn, msg *WSMessage) error
+
+// WSConn is one WebSocket connection of one authenticated user
+type WSConn struct {
+	ID     string
+	UserID string
+	// authenticated user roles at the connection time
+	Roles []string
+
+	ws        *websocket.Conn
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Send queues one message to the connection
+func (c *WSConn) Send(msgType string, data interface{}) error {
+	payload, err := encodeWSMessage(msgType, data)
+	if err != nil {
+		return err
+	}
+	return c.sendRaw(payload)
+}
+
+func (c *WSConn) sendRaw(payload []byte) error {
+	select {
+	case <-c.done:
+		return errors.New("catu.WSConn.Send connection closed")
+	default:
+	}
+
+	select {
+	case c.send <- payload:
+		return nil
+	default:
+		// one slow client can't block the broadcasts
+		c.Close()
+		return errors.New("catu.WSConn.Send send buffer full, connection closed")
+	}
+}
+
+// Close stops the connection, the client receives one close message
+func (c *WSConn) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+func encodeWSMessage(msgType string, data interface{}) ([]byte, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "catu.WSConn error on encode "+msgType+" message")
+	}
+	return json.Marshal(&WSMessage{Type: msgType, Data: raw})
+}
+
+// Hub tracks the WebSocket connections by user ID, see App.GetWebSocketHub
+type Hub struct {
+	app   App
+	mu    sync.RWMutex
+	conns map[string]map[*WSConn]struct{}
+}
+
+func NewHub(app App) *Hub {
+	return &Hub{app: app, conns: map[string]map[*WSConn]struct{}{}}
+}
+
+func (h *Hub) register(c *WSConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.conns[c.UserID] == nil {
+		h.conns[c.UserID] = map[*WSConn]struct{}{}
+	}
+	h.conns[c.UserID][c] = struct{}{}
+}
+
+func (h *Hub) unregister(c *WSConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.conns[c.UserID], c)
+	if len(h.conns[c.UserID]) == 0 {
+		delete(h.conns, c.UserID)
+	}
+}
+
+// Count returns the number of open connections
+func (h *Hub) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	count := 0
+	for _, conns := range h.conns {
+		count += len(conns)
+	}
+	return count
+}
+
+// UserConnections returns the open connections of one user
+func (h *Hub) UserConnections(userID string) []*WSConn {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	conns := make([]*WSConn, 0, len(h.conns[userID]))
+	for c := range h.conns[userID] {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// BroadcastToUser sends one message to all the user connections, returns the number of connections
+func (h *Hub) BroadcastToUser(userID, msgType string, data interface{}) (int, error) {
+	return h.broadcast(msgType, data, func(c *WSConn) bool {
+		return c.UserID == userID
+	})
+}
+
+// BroadcastToRole sends one message to the connections with the role, the role must be in the app RolesList
+func (h *Hub) BroadcastToRole(role, msgType string, data interface{}) (int, error) {
+	if h.app.GetRole(role) == nil && role != "authenticated" {
+		return 0, errors.New("catu.Hub.BroadcastToRole role not found: " + role)
+	}
+
+	return h.broadcast(msgType, data, func(c *WSConn) bool {
+		for _, r := range c.Roles {
+			if r == role {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// BroadcastToPermission sends one message to the connections with the permission, see App.Can
+func (h *Hub) BroadcastToPermission(permission, msgType string, data interface{}) (int, error) {
+	return h.broadcast(msgType, data, func(c *WSConn) bool {
+		return h.app.Can(permission, c.Roles)
+	})
+}
+
+func (h *Hub) broadcast(msgType string, data interface{}, filter func(c *WSConn) bool) (int, error) {
+	payload, err := encodeWSMessage(msgType, data)
+	if err != nil {
+		return 0, err
+	}
+
+	h.mu.RLock()
+	var targets []*WSConn
+	for _, conns := range h.conns {
+		for c := range conns {
+			if filter(c) {
+				targets = append(targets, c)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	count := 0
+	for _, c := range targets {
+		if c.sendRaw(payload) == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CloseAll closes all the connections, it is called in the app shutdown
+func (h *Hub) CloseAll() {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, conns := range h.conns {
+		for c := range conns {
+			c.Close()
+		}
+	}
+}
+
+// GetWebSocketHub returns the hub with the SetWebSocketRoute connections
+func (r *AppStruct) GetWebSocketHub() *Hub {
+	return r.webSocketHub
+}
+
+// SetWebSocketRoute upgrades the path requests to WebSocket connections tracked by the hub, handler receives the client messages.
+// Only authenticated users can connect. The server pings the clients every WEBSOCKET_PING_INTERVAL seconds (default 30)
+// and closes connections without pong in WEBSOCKET_PONG_TIMEOUT seconds (default 60)
+func (r *AppStruct) SetWebSocketRoute(path string, handler WSHandler) {
+	cfg := r.Configuration
+	pingInterval := time.Duration(cfg.GetInt64F("WEBSOCKET_PING_INTERVAL", 30)) * time.Second
+	pongTimeout := time.Duration(cfg.GetInt64F("WEBSOCKET_PONG_TIMEOUT", 60)) * time.Second
+	maxMessageSize := cfg.GetInt64F("WEBSOCKET_MAX_MESSAGE_SIZE", 65536)
+
+	upgrader := websocket.Upgrader{
+		HandshakeTimeout: 10 * time.Second,
+	}
+
+	r.webSocketOnce.Do(func() {
+		r.Events.On("shutdown", event.ListenerFunc(func(e event.Event) error {
+			r.webSocketHub.CloseAll()
+			return nil
+		}), event.High)
+	})
+
+	r.router.GET(path, func(c echo.Context) error {
+		ctx := c.(*RequestContext)
+		if !ctx.IsAuthenticated || ctx.AuthenticatedUser == nil {
+			return &HTTPError{Code: http.StatusUnauthorized, Message: "Unauthorized"}
+		}
+
+		ws, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+		if err != nil {
+			// the upgrader responds the handshake errors
+			logrus.WithFields(logrus.Fields{
+				"error":     err.Error(),
+				"requestId": GetRequestID(c),
+			}).Debug("catu.App.SetWebSocketRoute error on upgrade")
+			return nil
+		}
+
+		conn := &WSConn{
+			ID:     uuid.New().String(),
+			UserID: ctx.AuthenticatedUser.GetID(),
+			Roles:  append([]string{}, *ctx.GetAuthenticatedRoles()...),
+			ws:     ws,
+			send:   make(chan []byte, wsSendBufferSize),
+			done:   make(chan struct{}),
+		}
+
+		hub := r.webSocketHub
+		hub.register(conn)
+		defer hub.unregister(conn)
+
+		logrus.WithFields(logrus.Fields{
+			"id":     conn.ID,
+			"userId": conn.UserID,
+		}).Debug("catu.App.SetWebSocketRoute connection opened")
+
+		writerDone := make(chan struct{})
+		go func() {
+			defer close(writerDone)
+			conn.writeLoop(pingInterval)
+		}()
+
+		conn.readLoop(handler, pongTimeout, maxMessageSize)
+		conn.Close()
+		<-writerDone
+		ws.Close()
+
+		logrus.WithFields(logrus.Fields{
+			"id":     conn.ID,
+			"userId": conn.UserID,
+		}).Debug("catu.App.SetWebSocketRoute connection closed")
+
+		return nil
+	})
+}
+
+func (c *WSConn) readLoop(handler WSHandler, pongTimeout time.Duration, maxMessageSize int64) {
+	c.ws.SetReadLimit(maxMessageSize)
+	c.ws.SetReadDeadline(time.Now().Add(pongTimeout))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(pongTimeout))
+	})
+
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logrus.WithFields(logrus.Fields{
+					"id":    c.ID,
+					"error": err.Error(),
+				}).Debug("catu.WSConn connection error")
+			}
+			return
+		}
+
+		msg := &WSMessage{}
+		if err := json.Unmarshal(data, msg); err != nil || msg.Type == "" {
+			c.Send("error", map[string]string{"message": "invalid message, expected one JSON object with type"})
+			continue
+		}
+
+		if handler == nil {
+			continue
+		}
+		if err := handler(c, msg); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"id":    c.ID,
+				"type":  msg.Type,
+				"error": fmt.Sprintf("%+v\n", err),
+			}).Warn("catu.WSConn handler error, closing the connection")
+			return
+		}
+	}
+}
+
+func (c *WSConn) writeLoop(pingInterval time.Duration) {
+	const writeTimeout = 10 * time.Second
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case payload := <-c.send:
+			c.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := c.ws.WriteMessage(websocket.TextMessage, payload); err != nil {
+				c.Close()
+				c.ws.Close()
+				return
+			}
+		case <-ticker.C:
+			c.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.Close()
+				c.ws.Close()
+				return
+			}
+		case <-c.done:
+			c.ws.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, ""),
+				time.Now().Add(writeTimeout),
+			)
+			// unblocks the read loop
+			c.ws.SetReadDeadline(time.Now().Add(time.Second))
+			return
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package catu
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-catupiry/catu/acl"
+	"github.com/gookit/event"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebSocket(t *testing.T) {
+	newWSServer := func(t *testing.T) (App, string) {
+		app := newTestApp(t)
+		// authenticates the X-Test-User and X-Test-Roles headers
+		app.GetEvents().On("bindMiddlewares", event.ListenerFunc(func(e event.Event) error {
+			app.GetRouter().Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					if id := c.Request().Header.Get("X-Test-User"); id != "" {
+						var roles []string
+						if r := c.Request().Header.Get("X-Test-Roles"); r != "" {
+							roles = strings.Split(r, ",")
+						}
+						c.(*RequestContext).SetAuthenticatedUserAndFillRoles(&testUser{ID: id, Roles: roles})
+					}
+					return next(c)
+				}
+			})
+			return nil
+		}), event.Low)
+		assert.Nil(t, app.Bootstrap())
+		assert.Nil(t, app.SetRole("editor", acl.Role{Name: "editor", Permissions: []string{"content.update"}}))
+
+		app.SetWebSocketRoute("/ws", func(conn *WSConn, msg *WSMessage) error {
+			var data map[string]string
+			if err := msg.Bind(&data); err != nil {
+				return err
+			}
+			return conn.Send("echo", data)
+		})
+
+		server := httptest.NewServer(app.GetRouter())
+		t.Cleanup(server.Close)
+		return app, "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	}
+
+	dial := func(t *testing.T, url, user, roles string) *websocket.Conn {
+		header := http.Header{}
+		header.Set("X-Test-User", user)
+		header.Set("X-Test-Roles", roles)
+		conn, _, err := websocket.DefaultDialer.Dial(url, header)
+		assert.Nil(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+
+	read := func(t *testing.T, conn *websocket.Conn) *WSMessage {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		msg := &WSMessage{}
+		assert.Nil(t, conn.ReadJSON(msg))
+		return msg
+	}
+
+	waitConnections := func(t *testing.T, hub *Hub, count int) {
+		assert.Eventually(t, func() bool { return hub.Count() == count }, 2*time.Second, 5*time.Millisecond)
+	}
+
+	t.Run("Should broadcast to the user connections", func(t *testing.T) {
+		app, url := newWSServer(t)
+		hub := app.GetWebSocketHub()
+
+		one := dial(t, url, "1", "")
+		other := dial(t, url, "1", "")
+		two := dial(t, url, "2", "")
+		waitConnections(t, hub, 3)
+		assert.Len(t, hub.UserConnections("1"), 2)
+
+		count, err := hub.BroadcastToUser("1", "notification", map[string]int{"id": 10})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, count)
+
+		for _, conn := range []*websocket.Conn{one, other} {
+			msg := read(t, conn)
+			assert.Equal(t, "notification", msg.Type)
+			assert.JSONEq(t, `{"id":10}`, string(msg.Data))
+		}
+
+		// the user 2 receives only its messages
+		hub.BroadcastToUser("2", "notification", map[string]int{"id": 20})
+		msg := read(t, two)
+		assert.JSONEq(t, `{"id":20}`, string(msg.Data))
+
+		t.Run("Should unregister the closed connections", func(t *testing.T) {
+			two.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			two.Close()
+			waitConnections(t, hub, 2)
+			assert.Empty(t, hub.UserConnections("2"))
+		})
+	})
+
+	t.Run("Should broadcast to the roles and permissions", func(t *testing.T) {
+		app, url := newWSServer(t)
+		hub := app.GetWebSocketHub()
+
+		editor := dial(t, url, "1", "editor")
+		dial(t, url, "2", "")
+		waitConnections(t, hub, 2)
+
+		count, err := hub.BroadcastToRole("editor", "review", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, "review", read(t, editor).Type)
+
+		count, err = hub.BroadcastToPermission("content.update", "updated", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, "updated", read(t, editor).Type)
+
+		count, err = hub.BroadcastToRole("authenticated", "all", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, count)
+
+		_, err = hub.BroadcastToRole("missing", "review", nil)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should handle the client messages", func(t *testing.T) {
+		_, url := newWSServer(t)
+		conn := dial(t, url, "1", "")
+
+		assert.Nil(t, conn.WriteJSON(map[string]interface{}{"type": "ping", "data": map[string]string{"text": "hello"}}))
+		msg := read(t, conn)
+		assert.Equal(t, "echo", msg.Type)
+		assert.JSONEq(t, `{"text":"hello"}`, string(msg.Data))
+
+		assert.Nil(t, conn.WriteMessage(websocket.TextMessage, []byte("invalid")))
+		assert.Equal(t, "error", read(t, conn).Type)
+	})
+
+	t.Run("Should reject unauthenticated connections", func(t *testing.T) {
+		_, url := newWSServer(t)
+
+		_, res, err := websocket.DefaultDialer.Dial(url, nil)
+		assert.NotNil(t, err)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("Should ping the clients", func(t *testing.T) {
+		t.Setenv("WEBSOCKET_PING_INTERVAL", "1")
+		_, url := newWSServer(t)
+		conn := dial(t, url, "1", "")
+
+		pinged := make(chan struct{}, 1)
+		conn.SetPingHandler(func(string) error {
+			pinged <- struct{}{}
+			return nil
+		})
+		// the ping handler runs while reading
+		go conn.ReadMessage()
+
+		select {
+		case <-pinged:
+		case <-time.After(3 * time.Second):
+			t.Fatal("ping not received")
+		}
+	})
+
+	t.Run("Should close the connections in the shutdown", func(t *testing.T) {
+		app, url := newWSServer(t)
+		conn := dial(t, url, "1", "")
+		waitConnections(t, app.GetWebSocketHub(), 1)
+
+		app.(*AppStruct).shutdown()
+
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, _, err := conn.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+		waitConnections(t, app.GetWebSocketHub(), 0)
+	})
+}